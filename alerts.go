@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Файл расходов за день в каталоге состояния
+const spendLedgerName = "spend.json"
+
+// Время ожидания оповещения (вебхук, команда)
+const alertTimeout = 10 * time.Second
+
+// Виды оповещений о квоте
+const (
+	AlertBudget    = "budget"
+	AlertRateLimit = "rate_limit"
+)
+
+// Пороги оповещений о квоте из секции [ALERTS]
+type AlertConfig struct {
+	// Дневной бюджет в долларах (0 - пороги расходов не проверяются)
+	DailyBudgetUSD float64
+	// Пороги расходов в процентах дневного бюджета, по возрастанию
+	Thresholds []float64
+	// Количество ответов 429 подряд, после которого отправляется оповещение (0 - не проверяется)
+	Consecutive429 int
+	// Получатели оповещений: адрес вебхука (POST JSON), команда и почта из [EMAIL]
+	Webhook string
+	Command string
+	Email   bool
+}
+
+// Оповещения включены, если задан хотя бы один порог
+func (a AlertConfig) enabled() bool {
+	return a.DailyBudgetUSD > 0 || a.Consecutive429 > 0
+}
+
+// Чтение секции [ALERTS]
+func loadAlertConfig(section *ini.Section) (AlertConfig, error) {
+	alerts := AlertConfig{
+		DailyBudgetUSD: section.Key("daily_budget_usd").MustFloat64(0),
+		Consecutive429: section.Key("consecutive_429").MustInt(0),
+		Webhook:        section.Key("webhook").String(),
+		Command:        section.Key("command").String(),
+		Email:          section.Key("email").MustBool(false),
+	}
+	if alerts.DailyBudgetUSD < 0 {
+		return alerts, errorf("daily_budget_usd в секции [ALERTS] не может быть отрицательным: %g", alerts.DailyBudgetUSD)
+	}
+	if alerts.Consecutive429 < 0 {
+		return alerts, errorf("consecutive_429 в секции [ALERTS] не может быть отрицательным: %d", alerts.Consecutive429)
+	}
+	for _, s := range strings.Split(section.Key("budget_thresholds").MustString("80,100"), ",") {
+		if s = strings.TrimSuffix(strings.TrimSpace(s), "%"); s == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(s, 64)
+		if err != nil || p <= 0 {
+			return alerts, errorf("некорректный порог в budget_thresholds секции [ALERTS]: %q", s)
+		}
+		alerts.Thresholds = append(alerts.Thresholds, p)
+	}
+	sort.Float64s(alerts.Thresholds)
+	return alerts, nil
+}
+
+// Оповещение о квоте: передается вебхуку и команде как JSON
+type quotaAlert struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	RunID   string    `json:"run_id,omitempty"`
+	// Расходы за день, дневной бюджет и пересеченный порог в процентах
+	SpentUSD       float64 `json:"spent_usd,omitempty"`
+	DailyBudgetUSD float64 `json:"daily_budget_usd,omitempty"`
+	Threshold      float64 `json:"threshold_percent,omitempty"`
+	// Количество ответов 429 подряд
+	Consecutive int `json:"consecutive,omitempty"`
+}
+
+// Расходы за день: общие для запусков и экземпляров с одним каталогом состояния
+type spendLedger struct {
+	Date     string  `json:"date"`
+	SpentUSD float64 `json:"spent_usd"`
+	// Пороги, о которых уже отправлено оповещение за этот день
+	Fired []float64 `json:"fired,omitempty"`
+}
+
+// Проверка порогов квоты во время запуска: оповещение отправляется сразу при
+// пересечении порога, каждый порог расходов - один раз за день
+type quotaAlerts struct {
+	mu     sync.Mutex
+	config *Config
+	runID  string
+	// Путь файла расходов за день ("" - расходы учитываются только в пределах запуска)
+	path   string
+	ledger spendLedger
+	// Ответов 429 подряд и отправлено ли оповещение о текущей серии
+	streak  int
+	alerted bool
+	now     func() time.Time
+}
+
+// Проверка порогов квоты запуска; nil, если пороги не заданы
+func newQuotaAlerts(config *Config, runID string) *quotaAlerts {
+	if !config.Alerts.enabled() {
+		return nil
+	}
+	a := &quotaAlerts{config: config, runID: runID, now: time.Now}
+	if config.StateDir != "" {
+		a.path = filepath.Join(config.StateDir, spendLedgerName)
+	}
+	return a
+}
+
+// Учет затрат файла и проверка порогов дневного бюджета
+func (a *quotaAlerts) RecordCost(usd float64) {
+	if a == nil || a.config.Alerts.DailyBudgetUSD <= 0 || usd <= 0 {
+		return
+	}
+	a.mu.Lock()
+	var crossed []float64
+	update := func() error {
+		if a.path != "" {
+			a.ledger = spendLedger{}
+			if data, err := os.ReadFile(a.path); err == nil {
+				if err := json.Unmarshal(data, &a.ledger); err != nil {
+					warnf("Предупреждение: некорректный файл расходов %s: %v", a.path, err)
+					a.ledger = spendLedger{}
+				}
+			}
+		}
+		crossed = a.add(usd)
+		if a.path == "" {
+			return nil
+		}
+		data, err := json.MarshalIndent(a.ledger, "", "  ")
+		if err != nil {
+			return err
+		}
+		return safeWriteFile(a.path, data, 0644)
+	}
+	var err error
+	if a.path != "" {
+		if err = os.MkdirAll(filepath.Dir(a.path), 0755); err == nil {
+			err = withFileLock(a.path, update)
+		}
+	} else {
+		err = update()
+	}
+	spent := a.ledger.SpentUSD
+	a.mu.Unlock()
+	if err != nil {
+		warnf("Предупреждение: не удалось сохранить расходы за день: %v", err)
+	}
+
+	budget := a.config.Alerts.DailyBudgetUSD
+	for _, p := range crossed {
+		a.notify(quotaAlert{Kind: AlertBudget, SpentUSD: spent, DailyBudgetUSD: budget, Threshold: p,
+			Message: trf("израсходовано $%.2f из дневного бюджета $%.2f (порог %g%%)", spent, budget, p)})
+	}
+}
+
+// Добавление затрат к расходам за день; возвращает впервые пересеченные пороги
+func (a *quotaAlerts) add(usd float64) []float64 {
+	today := a.now().Format(time.DateOnly)
+	if a.ledger.Date != today {
+		a.ledger = spendLedger{Date: today}
+	}
+	a.ledger.SpentUSD += usd
+	var crossed []float64
+	for _, p := range a.config.Alerts.Thresholds {
+		if a.ledger.SpentUSD*100 < p*a.config.Alerts.DailyBudgetUSD || slices.Contains(a.ledger.Fired, p) {
+			continue
+		}
+		a.ledger.Fired = append(a.ledger.Fired, p)
+		crossed = append(crossed, p)
+	}
+	return crossed
+}
+
+// Учет результата запроса: серия ответов 429 подряд; err == nil - запрос выполнен
+func (a *quotaAlerts) RecordResult(err error) {
+	if a == nil || a.config.Alerts.Consecutive429 <= 0 {
+		return
+	}
+	a.mu.Lock()
+	if err == nil || errorCategory(err) != ErrorRateLimit {
+		a.streak, a.alerted = 0, false
+		a.mu.Unlock()
+		return
+	}
+	a.streak++
+	streak := a.streak
+	fire := streak >= a.config.Alerts.Consecutive429 && !a.alerted
+	if fire {
+		a.alerted = true
+	}
+	a.mu.Unlock()
+	if fire {
+		a.notify(quotaAlert{Kind: AlertRateLimit, Consecutive: streak,
+			Message: trf("подряд %d ответов 429: превышен лимит запросов провайдера", streak)})
+	}
+}
+
+// Отправка оповещения в журнал, поток состояния и настроенным получателям;
+// ошибки отправки не прерывают обработку
+func (a *quotaAlerts) notify(alert quotaAlert) {
+	alert.Time = a.now()
+	alert.RunID = a.runID
+	warnf("Оповещение о квоте: %s", alert.Message)
+	statusEvents.Emit(statusEvent{Time: alert.Time, Event: EventAlert, RunID: alert.RunID, Status: alert.Kind,
+		Message: alert.Message, CostUSD: alert.SpentUSD})
+
+	ac := a.config.Alerts
+	if ac.Webhook != "" {
+		if err := postAlert(a.config, ac.Webhook, alert); err != nil {
+			warnf("Предупреждение: не удалось отправить оповещение на вебхук: %v", err)
+		}
+	}
+	if ac.Command != "" {
+		if err := runAlertCommand(ac.Command, alert); err != nil {
+			warnf("Предупреждение: ошибка команды оповещения: %v", err)
+		}
+	}
+	if ac.Email && a.config.Email.enabled() {
+		ec := a.config.Email
+		msg := formatEmail(ec.From, ec.To, trf("rich: оповещение о квоте - %s", alert.Message), alert.Message+"\n", alert.Time)
+		if err := sendEmail(ec, msg); err != nil {
+			warnf("Предупреждение: не удалось отправить оповещение по почте: %v", err)
+		}
+	}
+}
+
+// Отправка оповещения на вебхук: POST с JSON телом
+func postAlert(config *Config, url string, alert quotaAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := config.Network.client(alertTimeout).Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errorf("вебхук вернул статус %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Запуск команды оповещения: JSON оповещения передается в стандартный ввод,
+// вид и текст - в переменных окружения RICH_ALERT_KIND и RICH_ALERT_MESSAGE
+func runAlertCommand(command string, alert quotaAlert) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "RICH_ALERT_KIND="+alert.Kind, "RICH_ALERT_MESSAGE="+alert.Message)
+	cmd.Stdin = bytes.NewReader(body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errorf("команда %s завершилась с ошибкой: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestQuotaAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var received []quotaAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert quotaAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("некорректное тело оповещения: %v", err)
+		}
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	config := &Config{StateDir: filepath.Join(t.TempDir(), ".rich"), Alerts: AlertConfig{DailyBudgetUSD: 1,
+		Thresholds: []float64{50, 100}, Consecutive429: 2, Webhook: server.URL}}
+	day := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	newAlerts := func(run string) *quotaAlerts {
+		a := newQuotaAlerts(config, run)
+		a.now = func() time.Time { return day }
+		return a
+	}
+
+	// Порог 50% пересечен один раз, следующий запуск того же дня продолжает счет
+	first := newAlerts("r1")
+	first.RecordCost(0.3)
+	first.RecordCost(0.3)
+	first.RecordCost(0.1)
+	second := newAlerts("r2")
+	second.RecordCost(0.3)
+	if len(received) != 2 || received[0].Threshold != 50 || received[1].Threshold != 100 || received[1].RunID != "r2" {
+		t.Fatalf("оповещения о бюджете: %+v", received)
+	}
+	if received[1].SpentUSD < 0.99 || received[1].Kind != AlertBudget {
+		t.Errorf("расходы за день в оповещении: %+v", received[1])
+	}
+
+	// Новый день начинает расходы заново
+	day = day.Add(24 * time.Hour)
+	next := newAlerts("r3")
+	next.RecordCost(0.6)
+	if len(received) != 3 || received[2].Threshold != 50 || received[2].SpentUSD > 0.61 {
+		t.Fatalf("расходы нового дня: %+v", received)
+	}
+
+	// Серия ответов 429: одно оповещение на серию, успешный запрос прерывает серию
+	limited := &apiStatusError{StatusCode: http.StatusTooManyRequests}
+	received = nil
+	next.RecordResult(limited)
+	next.RecordResult(errorf("ошибка при чтении файла: %w", limited))
+	next.RecordResult(limited)
+	next.RecordResult(nil)
+	next.RecordResult(limited)
+	if len(received) != 1 || received[0].Kind != AlertRateLimit || received[0].Consecutive != 2 {
+		t.Fatalf("оповещения о сериях 429: %+v", received)
+	}
+	next.RecordResult(limited)
+	if len(received) != 2 {
+		t.Errorf("после прерванной серии ожидалось новое оповещение, получено %d", len(received))
+	}
+}
+
+func TestLoadAlertConfig(t *testing.T) {
+	cfg, err := ini.Load([]byte("[ALERTS]\ndaily_budget_usd = 20\nbudget_thresholds = 100, 80%\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := loadAlertConfig(cfg.Section("ALERTS"))
+	if err != nil {
+		t.Fatalf("loadAlertConfig() вернул ошибку: %v", err)
+	}
+	if !alerts.enabled() || len(alerts.Thresholds) != 2 || alerts.Thresholds[0] != 80 {
+		t.Errorf("пороги оповещений: %+v", alerts)
+	}
+
+	cfg, _ = ini.Load([]byte("[ALERTS]\nbudget_thresholds = много\n"))
+	if _, err := loadAlertConfig(cfg.Section("ALERTS")); err == nil {
+		t.Error("ожидалась ошибка для некорректного порога")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Текст ответа Anthropic Messages API: текстовые блоки content объединяются
+// по порядку, блоки других типов (tool_use, thinking) пропускаются
+func anthropicText(responseData map[string]interface{}) (string, error) {
+	contentArray, ok := responseData["content"].([]interface{})
+	if !ok || len(contentArray) == 0 {
+		return "", errorf("некорректный формат ответа Anthropic API: отсутствует поле content или оно пустое")
+	}
+
+	var text strings.Builder
+	found := false
+	for _, item := range contentArray {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			return "", errorf("некорректный формат элемента content в ответе Anthropic API")
+		}
+		if blockType, _ := block["type"].(string); blockType != "" && blockType != "text" {
+			continue
+		}
+		blockText, ok := block["text"].(string)
+		if !ok {
+			return "", errorf("некорректный формат поля text в ответе Anthropic API")
+		}
+		text.WriteString(blockText)
+		found = true
+	}
+	if !found {
+		return "", errorf("ответ Anthropic API не содержит текстовых блоков")
+	}
+	return text.String(), nil
+}
+
+// Событие потокового ответа Anthropic (stream = true)
+type anthropicStreamEvent struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content_block"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Message struct {
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Чтение потокового ответа Anthropic (server-sent events): текст собирается
+// из дельт текстовых блоков, токены - из событий message_start и message_delta
+// (false - поток не содержал данных о токенах)
+func readAnthropicStream(r io.Reader) (string, Usage, bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var text strings.Builder
+	var usage Usage
+	textBlocks := map[int]bool{}
+	hasUsage, stopped := false, false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			// Строки event:, комментарии и разделители событий не нужны:
+			// тип события повторяется в поле type данных
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return "", Usage{}, false, errorf("ошибка при разборе события потока Anthropic API: %v", err)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+			usage.CompletionTokens = event.Message.Usage.OutputTokens
+			hasUsage = true
+		case "content_block_start":
+			if event.ContentBlock.Type == "text" {
+				textBlocks[event.Index] = true
+				text.WriteString(event.ContentBlock.Text)
+			}
+		case "content_block_delta":
+			if textBlocks[event.Index] && event.Delta.Type == "text_delta" {
+				text.WriteString(event.Delta.Text)
+			}
+		case "message_delta":
+			if event.Usage.OutputTokens > 0 {
+				usage.CompletionTokens = event.Usage.OutputTokens
+				hasUsage = true
+			}
+		case "message_stop":
+			stopped = true
+		case "error":
+			return "", Usage{}, false, errorf("ошибка в потоке Anthropic API: %s: %s", event.Error.Type, event.Error.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, false, errorf("ошибка при чтении потока Anthropic API: %w", err)
+	}
+	if !stopped {
+		return "", Usage{}, false, errorf("поток Anthropic API прерван до события message_stop")
+	}
+	if len(textBlocks) == 0 {
+		return "", Usage{}, false, errorf("ответ Anthropic API не содержит текстовых блоков")
+	}
+	return text.String(), usage, hasUsage, nil
+}
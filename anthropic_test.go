@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicText(t *testing.T) {
+	var response map[string]interface{}
+	raw := `{"content": [
+		{"type": "text", "text": "Первая часть. "},
+		{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {"q": "x"}},
+		{"type": "text", "text": "Вторая часть."}
+	]}`
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("Некорректный JSON: %v", err)
+	}
+	text, err := anthropicText(response)
+	if err != nil {
+		t.Fatalf("anthropicText() вернул ошибку: %v", err)
+	}
+	if text != "Первая часть. Вторая часть." {
+		t.Errorf("Неожиданный текст: %q", text)
+	}
+
+	onlyTool := map[string]interface{}{"content": []interface{}{
+		map[string]interface{}{"type": "tool_use", "name": "lookup"},
+	}}
+	if _, err := anthropicText(onlyTool); err == nil {
+		t.Error("Ответ без текстовых блоков должен возвращать ошибку")
+	}
+	if _, err := anthropicText(map[string]interface{}{}); err == nil {
+		t.Error("Ответ без content должен возвращать ошибку")
+	}
+}
+
+func TestReadAnthropicStream(t *testing.T) {
+	stream := `event: message_start
+data: {"type": "message_start", "message": {"usage": {"input_tokens": 25, "output_tokens": 1}}}
+
+event: content_block_start
+data: {"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Привет"}}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": ", мир"}}
+
+event: content_block_stop
+data: {"type": "content_block_stop", "index": 0}
+
+event: content_block_start
+data: {"type": "content_block_start", "index": 1, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {}}}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{\"q\": 1}"}}
+
+event: content_block_stop
+data: {"type": "content_block_stop", "index": 1}
+
+event: message_delta
+data: {"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 15}}
+
+event: message_stop
+data: {"type": "message_stop"}
+`
+	text, usage, ok, err := readAnthropicStream(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readAnthropicStream() вернул ошибку: %v", err)
+	}
+	if text != "Привет, мир" {
+		t.Errorf("Неожиданный текст: %q", text)
+	}
+	if !ok || usage.PromptTokens != 25 || usage.CompletionTokens != 15 {
+		t.Errorf("Неожиданные токены: %+v (ok=%v)", usage, ok)
+	}
+
+	// Поток без message_stop считается оборванным
+	truncated := strings.Split(stream, "event: message_delta")[0]
+	if _, _, _, err := readAnthropicStream(strings.NewReader(truncated)); err == nil {
+		t.Error("Оборванный поток должен возвращать ошибку")
+	}
+
+	overloaded := `event: error
+data: {"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}
+`
+	if _, _, _, err := readAnthropicStream(strings.NewReader(overloaded)); err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Ожидалась ошибка из события error, получено: %v", err)
+	}
+}
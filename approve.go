@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Проверка директории очереди проверки (review_dir): директория создается и не
+// должна совпадать с выходной или находиться внутри нее (и наоборот), иначе
+// непроверенные результаты попадут в оглавление, контрольные суммы и публикацию
+func (c *Config) checkReviewDir(outputDir string) error {
+	if c.OutputLayout == OutputLayoutPerRun {
+		return errorf("review_dir несовместим с output_layout = per-run")
+	}
+	if !c.Sinks.WritesLocal() {
+		return errorf("очередь проверки (review_dir) требует приемника local")
+	}
+	reviewDir, err := filepath.Abs(c.ReviewDir)
+	if err != nil || !isPathSafe(reviewDir) {
+		return errorf("небезопасный путь директории проверки: %s", c.ReviewDir)
+	}
+	if isSubpath(outputDir, reviewDir) || isSubpath(reviewDir, outputDir) {
+		return errorf("директория проверки %s и выходная директория %s не должны быть вложены друг в друга", reviewDir, outputDir)
+	}
+	if err := os.MkdirAll(reviewDir, 0755); err != nil {
+		return errorf("не удалось создать директорию проверки: %v", err)
+	}
+	return nil
+}
+
+// Путь path совпадает с директорией dir или находится внутри нее
+func isSubpath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && (rel == "." || isRelPathSafe(rel))
+}
+
+// Результат в очереди проверки: последнее обогащение файла записано в review_dir
+// и еще не одобрено
+type reviewItem struct {
+	RunID string
+	// Запись журнала об обогащении; Output - файл в директории проверки
+	Entry journalEntry
+	// Конфигурация корня файла, путь исходного файла относительно входной
+	// директории корня и путь результата в выходной директории
+	Root   *Config
+	Rel    string
+	Target string
+}
+
+// Результаты в очереди проверки по журналам запусков в порядке путей файлов
+func reviewQueue(config *Config) ([]reviewItem, error) {
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	var items []reviewItem
+	for key, rec := range latest {
+		rootConfig, rootRel := config.rootForKey(key)
+		if rootConfig.ReviewDir == "" {
+			continue
+		}
+		reviewDir, err := filepath.Abs(rootConfig.ReviewDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути директории проверки: %v", err)
+		}
+		outputDir, err := filepath.Abs(rootConfig.OutputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+		}
+		rel, err := filepath.Rel(reviewDir, rec.Entry.Output)
+		if err != nil || !isRelPathSafe(rel) {
+			continue
+		}
+		if _, err := os.Stat(rec.Entry.Output); err != nil {
+			continue
+		}
+		items = append(items, reviewItem{RunID: rec.RunID, Entry: rec.Entry, Root: rootConfig, Rel: rootRel, Target: filepath.Join(outputDir, rel)})
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].Entry.Input < items[b].Entry.Input })
+	return items, nil
+}
+
+// Поиск результата в очереди по пути исходного файла (относительно входной
+// директории) или файла в директории проверки
+func findReviewItem(items []reviewItem, arg string) (reviewItem, bool) {
+	abs, _ := filepath.Abs(arg)
+	for _, item := range items {
+		if item.Entry.Input == normalizeRelPath(arg) || samePath(abs, item.Entry.Output) {
+			return item, true
+		}
+	}
+	return reviewItem{}, false
+}
+
+// Итоговая проверка результата перед одобрением: причины, по которым результат
+// нельзя перенести в выходную директорию без --force
+func (item reviewItem) Check() []string {
+	var problems []string
+	content, err := os.ReadFile(item.Entry.Output)
+	if err != nil {
+		return []string{trf("не удалось прочитать %s: %v", item.Entry.Output, err)}
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		problems = append(problems, tr("результат пуст"))
+	}
+	if isMarkdownPath(item.Entry.Output) {
+		fm, _, _ := parseFrontmatter(content)
+		if review := fm[ReviewKey]; review != "" {
+			problems = append(problems, trf("результат отмечен для проверки (%s: %s): удалите поле после проверки", ReviewKey, review))
+		}
+		if hasMergeMarkers(string(content)) {
+			problems = append(problems, tr("в результате остались маркеры конфликтов слияния"))
+		}
+	}
+	if _, err := os.Stat(item.Entry.Output + ConflictSuffix); err == nil {
+		problems = append(problems, trf("новое обогащение %s не слито с правками (rich merge)", item.Entry.Output+ConflictSuffix))
+	}
+
+	// Исходный файл из внешнего источника (архив, ref git, S3) не сверяется
+	if item.Root.InputSource == "" && item.Entry.InputHash != "" {
+		input, err := os.ReadFile(filepath.Join(item.Root.InputDir, filepath.FromSlash(item.Rel)))
+		switch {
+		case os.IsNotExist(err):
+			problems = append(problems, tr("исходный файл удален после обогащения"))
+		case err != nil:
+			problems = append(problems, trf("не удалось прочитать исходный файл: %v", err))
+		case contentHash(input) != item.Entry.InputHash:
+			problems = append(problems, tr("исходный файл изменен после обогащения"))
+		}
+	}
+	return problems
+}
+
+// Строки маркеров конфликтов слияния в документе
+func hasMergeMarkers(doc string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(doc))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		switch strings.TrimRight(scanner.Text(), "\r") {
+		case mergeMarkerEdited, mergeMarkerPrevious, mergeMarkerNew:
+			return true
+		}
+	}
+	return false
+}
+
+// Одобрение результата: файл и его части и карточки переносятся из директории
+// проверки в выходную директорию, прежний результат сохраняется в резервной копии
+// запуска одобрения. Запись журнала делает перенесенный файл последним результатом,
+// поэтому rich undo возвращает его в очередь проверки
+func approveItem(journal *runJournal, item reviewItem) (journalEntry, []string, error) {
+	content, err := os.ReadFile(item.Entry.Output)
+	if err != nil {
+		return journalEntry{}, nil, errorf("не удалось прочитать %s: %v", item.Entry.Output, err)
+	}
+	backup, err := journal.Backup(item.Target, item.Entry.Input)
+	if err != nil {
+		return journalEntry{}, nil, errorf("ошибка при резервном копировании выходного файла: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.Target), 0755); err != nil {
+		return journalEntry{}, nil, withCategory(ErrorIO, errorf("ошибка при создании выходной директории: %v", err))
+	}
+	if err := moveFile(item.Entry.Output, item.Target); err != nil {
+		return journalEntry{}, nil, withCategory(ErrorIO, errorf("не удалось перенести %s: %v", item.Entry.Output, err))
+	}
+	written := []string{item.Target}
+
+	// Части результата (max_output_size) и карточки лежат рядом с результатом
+	pages := 1
+	for ; ; pages++ {
+		page := pagePath(item.Entry.Output, pages+1)
+		if _, err := os.Stat(page); err != nil {
+			break
+		}
+		if err := moveFile(page, pagePath(item.Target, pages+1)); err != nil {
+			return journalEntry{}, written, withCategory(ErrorIO, errorf("не удалось перенести часть результата %s: %v", page, err))
+		}
+		written = append(written, pagePath(item.Target, pages+1))
+	}
+	if isMarkdownPath(item.Target) {
+		removeStalePages(item.Target, pages)
+	}
+	entry := journalEntry{Input: item.Entry.Input, Output: item.Target, Status: StatusEnriched, InputHash: item.Entry.InputHash,
+		OutputHash: contentHash(content), PromptHash: item.Entry.PromptHash, Model: item.Entry.Model, Backup: backup,
+		Experiment: item.Entry.Experiment, Variant: item.Entry.Variant, Approved: item.Entry.Output}
+	if item.Entry.Cards != "" {
+		if _, err := os.Stat(item.Entry.Cards); err == nil {
+			cards := filepath.Join(filepath.Dir(item.Target), filepath.Base(item.Entry.Cards))
+			if err := moveFile(item.Entry.Cards, cards); err != nil {
+				warnf("Предупреждение: не удалось перенести карточки %s: %v", item.Entry.Cards, err)
+			} else {
+				entry.Cards = cards
+				written = append(written, cards)
+			}
+		}
+	}
+	return entry, written, nil
+}
+
+// rich approve [--all] [--force] [файл...]: перенос проверенных результатов из
+// директории проверки в выходную директорию; без файлов - список очереди
+func runApproveCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("approve", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	all := fs.Bool("all", false, tr("Одобрить все результаты в очереди проверки"))
+	force := fs.Bool("force", false, tr("Одобрить результаты, не прошедшие итоговую проверку"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.ReviewDir == "" {
+		return errorf("очередь проверки не настроена (секция [DIRECTORIES], ключ review_dir)")
+	}
+	queue, err := reviewQueue(config)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 && !*all {
+		if len(queue) == 0 {
+			fmt.Fprintln(out, tr("Очередь проверки пуста"))
+			return nil
+		}
+		for _, item := range queue {
+			line := fmt.Sprintf("%s  %s  (%s %s)", item.Entry.Input, item.Entry.Output, tr("запуск"), item.RunID)
+			if problems := item.Check(); len(problems) > 0 {
+				line += ": " + strings.Join(problems, "; ")
+			}
+			fmt.Fprintln(out, line)
+		}
+		fmt.Fprintf(out, tr("Результатов на проверке: %d\n"), len(queue))
+		return nil
+	}
+
+	selected := queue
+	if !*all {
+		selected = nil
+		for _, arg := range fs.Args() {
+			item, ok := findReviewItem(queue, arg)
+			if !ok {
+				return errorf("%s нет в очереди проверки", arg)
+			}
+			selected = append(selected, item)
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(out, tr("Очередь проверки пуста"))
+		return nil
+	}
+
+	// Итоговая проверка до начала переноса
+	var approved []reviewItem
+	rejected := 0
+	for _, item := range selected {
+		problems := item.Check()
+		if len(problems) > 0 && !*force {
+			fmt.Fprintf(out, tr("%s не одобрен: %s\n"), item.Entry.Input, strings.Join(problems, "; "))
+			rejected++
+			continue
+		}
+		approved = append(approved, item)
+	}
+
+	if len(approved) > 0 {
+		if err := approveItems(config, *configPath, approved, out); err != nil {
+			return err
+		}
+	}
+	if rejected > 0 {
+		return errorf("не одобрено результатов: %d (--force одобряет без итоговой проверки)", rejected)
+	}
+	return nil
+}
+
+// Перенос одобренных результатов отдельным запуском: приемники результатов
+// получают файлы только после одобрения, оглавление и контрольные суммы выходной
+// директории обновляются
+func approveItems(config *Config, configPath string, items []reviewItem, out io.Writer) error {
+	journal, err := startRun(config.StateDir, configPath, config.Operator)
+	if err != nil {
+		return err
+	}
+	sinks, err := newSinkSet(config, out)
+	if err != nil {
+		return err
+	}
+	roots := make(map[string]*Config)
+	for _, item := range items {
+		entry, written, err := approveItem(journal, item)
+		if err != nil {
+			return err
+		}
+		if err := journal.Record(entry); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, tr("%s одобрен: %s\n"), item.Entry.Input, item.Target)
+		roots[item.Root.RootName] = item.Root
+
+		if err := sinks.Publish(approvedOutput(item, entry, written)); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+	sinks.Finish(journal.ID, true)
+	if err := journal.Finish(); err != nil {
+		return err
+	}
+
+	for _, rootConfig := range roots {
+		outputDir, err := filepath.Abs(rootConfig.OutputDir)
+		if err == nil && config.IndexFile != "" {
+			err = updateIndex(rootConfig, outputDir)
+		}
+		if err == nil && config.ChecksumFile != "" {
+			err = updateChecksums(rootConfig, outputDir)
+		}
+		if err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+	fmt.Fprintf(out, tr("Одобрено результатов: %d (rich undo --run %s)\n"), len(items), journal.ID)
+	return nil
+}
+
+// Одобренный результат для приемников
+func approvedOutput(item reviewItem, entry journalEntry, written []string) sinkOutput {
+	content, _ := os.ReadFile(entry.Output)
+	out := sinkOutput{Key: item.Entry.Input, RelPath: normalizeRelPath(item.Rel), Content: content, Written: written}
+	if outputDir, err := filepath.Abs(item.Root.OutputDir); err == nil {
+		out.OutputDir = outputDir
+		if r, err := filepath.Rel(outputDir, entry.Output); err == nil && isRelPathSafe(r) {
+			out.RelPath = normalizeRelPath(r)
+		}
+	}
+	if input, err := os.ReadFile(filepath.Join(item.Root.InputDir, filepath.FromSlash(item.Rel))); err == nil {
+		fileConfig, _, _ := resolveFileConfig(item.Root, item.Rel, input)
+		out.File = fileConfig.File
+		out.File.EnrichedWords = documentWords(string(content), out.File.Language)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApproveCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "todo")
+	reviewDir := filepath.Join(tmpDir, "review")
+	outputDir := filepath.Join(tmpDir, "done")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	if err := os.MkdirAll(filepath.Join(inputDir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"notes/a.md": "# Заметка A\n", "b.md": "# Заметка B\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": map[string]string{
+			"role": "assistant", "content": "# Заметка\n\nОбогащенный текст заметки."}}}})
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\nreview_dir = " + reviewDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + stateDir +
+		"\n[EXCLUSIONS]\nexcluded_files =\n[REPORT]\nfile = " + filepath.Join(tmpDir, "rich.report.json") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.MaxTokens = 100
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	// Результаты ждут проверки в review_dir
+	reviewed := filepath.Join(reviewDir, "notes", "a.md")
+	approved := filepath.Join(outputDir, "notes", "a.md")
+	if _, err := os.Stat(reviewed); err != nil {
+		t.Fatalf("результат не записан в директорию проверки: %v", err)
+	}
+	if _, err := os.Stat(approved); !os.IsNotExist(err) {
+		t.Fatal("результат записан в выходную директорию до одобрения")
+	}
+	var out bytes.Buffer
+	if err := runApproveCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "notes/a.md") || !strings.Contains(out.String(), "b.md") {
+		t.Errorf("список очереди проверки:\n%s", out.String())
+	}
+
+	// Итоговая проверка: отметка rich_review не дает одобрить результат без --force
+	data, _ := os.ReadFile(filepath.Join(reviewDir, "b.md"))
+	if err := os.WriteFile(filepath.Join(reviewDir, "b.md"), []byte(setFrontmatterField(string(data), ReviewKey, ReviewGuard)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runApproveCommand([]string{"--config", configPath, "--all"}, &out); err == nil {
+		t.Error("ожидалась ошибка для результата, не прошедшего проверку")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "b.md")); !os.IsNotExist(err) {
+		t.Error("результат, не прошедший проверку, перенесен в выходную директорию")
+	}
+	if _, err := os.Stat(reviewed); !os.IsNotExist(err) {
+		t.Error("одобренный результат остался в директории проверки")
+	}
+	if _, err := os.Stat(approved); err != nil {
+		t.Fatalf("одобренный результат не перенесен: %v", err)
+	}
+
+	// Состояние: последний результат - файл в выходной директории
+	latest, err := latestOutputs(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := latest["notes/a.md"]; !samePath(rec.Entry.Output, approved) || rec.Entry.Approved != reviewed {
+		t.Errorf("запись об одобрении: %+v", rec.Entry)
+	}
+	if _, problems, err := verifyOutputs(config); err != nil || len(problems) != 0 {
+		t.Errorf("verifyOutputs() = %v, %v", problems, err)
+	}
+
+	// Отмена одобрения возвращает результат в очередь
+	runs, err := listRuns(stateDir)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("запусков %d (%v)", len(runs), err)
+	}
+	if restored, skipped, err := undoRun(runs[1], configPath, false); err != nil || restored != 1 || len(skipped) != 0 {
+		t.Fatalf("undoRun() = %d, %v, %v", restored, skipped, err)
+	}
+	if _, err := os.Stat(reviewed); err != nil {
+		t.Errorf("результат не возвращен в очередь проверки: %v", err)
+	}
+	if _, err := os.Stat(approved); !os.IsNotExist(err) {
+		t.Error("результат остался в выходной директории после отмены")
+	}
+	queue, err := reviewQueue(config)
+	if err != nil || len(queue) != 2 {
+		t.Errorf("очередь после отмены: %v, %v", queue, err)
+	}
+
+	// --force одобряет результат с отметкой для проверки
+	out.Reset()
+	if err := runApproveCommand([]string{"--config", configPath, "--force", "b.md"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "b.md")); err != nil {
+		t.Errorf("результат не одобрен с --force: %v", err)
+	}
+}
+
+func TestReviewItemCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	input := filepath.Join(tmpDir, "a.md")
+	output := filepath.Join(tmpDir, "review", "a.md")
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(input, []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := "# A\n\n" + mergeMarkerEdited + "\nправка\n" + mergeMarkerSplit + "\nновое\n" + mergeMarkerNew + "\n"
+	if err := os.WriteFile(output, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output+ConflictSuffix, []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	item := reviewItem{Entry: journalEntry{Input: "a.md", Output: output, InputHash: contentHash([]byte("# B"))},
+		Root: &Config{InputDir: tmpDir}, Rel: "a.md"}
+	if problems := item.Check(); len(problems) != 3 {
+		t.Errorf("Check() = %q, ожидалось три причины", problems)
+	}
+}
+
+func TestCheckReviewDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "done")
+	tests := []struct {
+		config Config
+		ok     bool
+	}{
+		{Config{ReviewDir: filepath.Join(tmpDir, "review")}, true},
+		{Config{ReviewDir: filepath.Join(outputDir, "review")}, false},
+		{Config{ReviewDir: tmpDir}, false},
+		{Config{ReviewDir: outputDir}, false},
+		{Config{ReviewDir: filepath.Join(tmpDir, "review"), OutputLayout: OutputLayoutPerRun}, false},
+		{Config{ReviewDir: filepath.Join(tmpDir, "review"), Sinks: sinksConfig{Sinks: []string{SinkStdout}}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.checkReviewDir(outputDir); (err == nil) != tt.ok {
+			t.Errorf("checkReviewDir(%s) = %v", tt.config.ReviewDir, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Инструкция модели для ответа на вопрос по фрагментам документов
+const askInstruction = `Answer the question using only the numbered excerpts from the knowledge base below. Cite the excerpts that support each statement with their numbers in square brackets, for example [2] or [1][3]. If the excerpts do not contain the answer, say so plainly instead of guessing. Answer in the language of the question, concisely, in markdown.`
+
+// Ссылка на фрагмент в ответе модели: [1], [2, 3]
+var askCitationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Ответ на вопрос по обогащенным документам
+type askAnswer struct {
+	Text string
+	// Фрагменты, отправленные модели (номер ссылки - индекс + 1)
+	Sources []scoredChunk
+	// Номера фрагментов, на которые ссылается ответ
+	Cited []int
+	Usage Usage
+}
+
+// Промпт с пронумерованными фрагментами документов
+func askPrompt(sources []scoredChunk) string {
+	var b strings.Builder
+	b.WriteString(askInstruction)
+	b.WriteString("\n\nExcerpts:\n")
+	for i, s := range sources {
+		fmt.Fprintf(&b, "\n[%d] %s\n%s\n", i+1, s.Source, s.Text)
+	}
+	b.WriteString("\nQuestion:")
+	return b.String()
+}
+
+// Номера фрагментов, на которые ссылается ответ, в порядке первого упоминания
+func citedSources(answer string, count int) []int {
+	var cited []int
+	seen := map[int]bool{}
+	for _, m := range askCitationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, part := range strings.Split(m[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n < 1 || n > count || seen[n] {
+				continue
+			}
+			seen[n] = true
+			cited = append(cited, n)
+		}
+	}
+	return cited
+}
+
+// Поиск фрагментов, похожих на вопрос, и запрос ответа с ссылками на них
+func askCorpus(config *Config, embedder Embedder, question string, top int, limiter *RateLimiter) (*askAnswer, error) {
+	chunks, err := outputChunks(config)
+	if err != nil {
+		return nil, err
+	}
+	ranked, err := rankChunks(embedder, question, chunks)
+	if err != nil {
+		return nil, err
+	}
+	if top > 0 && len(ranked) > top {
+		ranked = ranked[:top]
+	}
+	answer := &askAnswer{Sources: ranked}
+	if len(ranked) == 0 {
+		return answer, nil
+	}
+
+	askConfig := *config
+	askConfig.Prompt = askPrompt(ranked)
+	// Примеры и правила ответа относятся к обогащению, а не к ответам на вопросы
+	askConfig.Examples = nil
+	askConfig.OutputRules = outputRules{}
+	text, usage, err := enrichContentWithUsage(&askConfig, question, limiter)
+	answer.Usage = usage
+	if err != nil {
+		return answer, err
+	}
+	answer.Text = strings.TrimSpace(text)
+	answer.Cited = citedSources(answer.Text, len(ranked))
+	return answer, nil
+}
+
+// rich ask [--top N] [--all-sources] "вопрос": ответ на вопрос по обогащенным
+// документам со ссылками на них
+func runAskCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	top := fs.Int("top", 8, tr("Количество фрагментов документов, отправляемых модели"))
+	allSources := fs.Bool("all-sources", false, tr("Показать все отправленные фрагменты, а не только процитированные"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	question := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if question == "" {
+		return errorf("не задан вопрос: rich ask \"вопрос\"")
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	embedder, err := newEmbedder(config)
+	if err != nil {
+		return err
+	}
+
+	answer, err := askCorpus(config, embedder, question, *top, config.newRateLimiter())
+	if err != nil {
+		return err
+	}
+	if len(answer.Sources) == 0 {
+		fmt.Fprintln(out, tr("В обогащенных документах не найдено фрагментов по вопросу"))
+		return nil
+	}
+	fmt.Fprintln(out, answer.Text)
+
+	shown := answer.Cited
+	if *allSources || len(shown) == 0 {
+		shown = nil
+		for i := range answer.Sources {
+			shown = append(shown, i+1)
+		}
+	}
+	fmt.Fprintf(out, "\n%s\n", tr("Источники:"))
+	for _, n := range shown {
+		s := answer.Sources[n-1]
+		fmt.Fprintf(out, "[%d] %s (%.2f)\n    %s\n", n, s.Source, s.Score, snippet(s.Text, searchSnippetRunes))
+	}
+	if cost := answer.Usage.Cost(config); cost > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), cost)
+	}
+	return nil
+}
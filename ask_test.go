@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCitedSources(t *testing.T) {
+	answer := "Решили перейти на Postgres [2]. Сроки - август [1, 2][7] и [x]; см. также [1]."
+	if got := citedSources(answer, 3); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("citedSources() = %v, ожидалось [2 1]", got)
+	}
+	if got := citedSources("Ответа нет", 3); got != nil {
+		t.Errorf("citedSources() без ссылок = %v", got)
+	}
+}
+
+func TestRunAskCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "done")
+	files := map[string]string{
+		"meetings/db.md":  "# Миграция базы данных\n\nРешили перейти с MySQL на Postgres до августа.\n\n```old\nоригинал\n```",
+		"food/pancake.md": "# Блины\n\nМука, молоко, яйца и щепотка соли.",
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `[1] meetings/db.md`) || strings.Contains(string(body), "оригинал") ||
+			!strings.Contains(string(body), "Что решили по базе данных?") {
+			t.Errorf("неожиданный запрос: %s", body)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Перейти на Postgres до августа [1]."}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runAskCommand([]string{"--config", configPath, "Что решили по базе данных?"}, &out); err != nil {
+		t.Fatalf("runAskCommand() вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Перейти на Postgres до августа [1].\n\nИсточники:\n[1] meetings/db.md (") ||
+		strings.Contains(out.String(), "pancake") {
+		t.Errorf("неожиданный вывод:\n%s", out.String())
+	}
+	if err := runAskCommand([]string{"--config", configPath}, &out); err == nil {
+		t.Error("ожидалась ошибка для пустого вопроса")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Разделители документов в пакетном запросе
+const (
+	batchStartPrefix = "<<<RICH FILE "
+	batchEndPrefix   = "<<<RICH END "
+	batchSuffix      = ">>>"
+)
+
+// Сколько следующих кандидатов просматривается при наборе пакета
+const batchLookahead = 50
+
+// Инструкция модели для пакетного запроса
+const batchInstruction = `The input below contains several independent markdown documents. Each document starts with a line "<<<RICH FILE N>>>" and ends with a line "<<<RICH END N>>>". Apply the instructions above to each document separately and return every result wrapped in the same delimiter lines with the same numbers, in the same order, without any text outside the delimiters.`
+
+// Результат обогащения файла в составе пакета
+type batchResult struct {
+	Content string
+	Usage   Usage
+}
+
+// Пакетная обработка маленьких файлов: несколько файлов отправляются одним запросом,
+// ответ разбирается по файлам и используется при их обычной обработке
+type batcher struct {
+	config    *Config
+	outputDir string
+	// Копии похожих документов, которые пропускаются без обращения к API
+	skipped map[string]duplicate
+
+	mu      sync.Mutex
+	results map[string]batchResult
+}
+
+// Создание пакетной обработки; nil, если она выключена или несовместима с настройками
+func newBatcher(config *Config, outputDir string) *batcher {
+	if config.BatchMaxBytes <= 0 || config.BatchSize < 2 {
+		return nil
+	}
+	// Контекст и выдержки связанных документов подбираются для каждого файла отдельно
+	if config.ContextDir != "" || config.LinkedDocs {
+		infof("Пакетная обработка отключена: несовместима с context_dir и linked_docs")
+		return nil
+	}
+	// Файлы пакета обогащаются одним промптом, а в эксперименте промпты чередуются
+	if config.Experiment.Enabled() {
+		infof("Пакетная обработка отключена: несовместима с экспериментом с промптами")
+		return nil
+	}
+	return &batcher{config: config, outputDir: outputDir, results: make(map[string]batchResult)}
+}
+
+// Получение готового результата файла из пакета (результат выдается один раз)
+func (b *batcher) Take(path string) (batchResult, bool) {
+	if b == nil {
+		return batchResult{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.results[path]
+	if ok {
+		delete(b.results, path)
+	}
+	return r, ok
+}
+
+// Проверка наличия готового результата файла
+func (b *batcher) has(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.results[path]
+	return ok
+}
+
+// Элемент пакета
+type batchItem struct {
+	Path    string
+	Content string
+}
+
+// Проверка, подходит ли файл для пакетной обработки; возвращает ключ группы
+// (файлы пакета должны иметь одинаковые промпт и модель)
+func (b *batcher) eligible(c candidate) (string, *Config, string, bool) {
+	// Документы других форматов конвертируются в markdown только при обработке
+	if c.Info == nil || c.Info.Size() > int64(b.config.BatchMaxBytes) || !isMarkdownPath(c.Path) {
+		return "", nil, "", false
+	}
+	if _, dup := b.skipped[c.RelPath]; dup {
+		return "", nil, "", false
+	}
+	content, err := os.ReadFile(c.Path)
+	if err != nil || validateContentSize(content, b.config.maxFileSize()) != nil {
+		return "", nil, "", false
+	}
+	if _, small := isTooSmall(b.config, content); small {
+		return "", nil, "", false
+	}
+	if _, skipped := b.config.Workflow.Skipped(content); skipped {
+		return "", nil, "", false
+	}
+	if b.config.Policy.Blocks(content) {
+		return "", nil, "", false
+	}
+	if len(findSections(b.config, string(content))) > 0 || documentMode(b.config, string(content)) != ModeFull {
+		return "", nil, "", false
+	}
+	// Ранее обогащенные файлы в инкрементальном режиме обрабатываются по разделам
+	if b.config.Incremental {
+		if _, err := os.Stat(filepath.Join(b.outputDir, c.RelPath)); err == nil {
+			return "", nil, "", false
+		}
+	}
+
+	fileConfig, _, _ := resolveFileConfig(b.config, c.RelPath, content)
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%g\x00%d", fileConfig.Prompt, fileConfig.ModelName,
+		fileConfig.ModelAPIURL, fileConfig.Temperature, fileConfig.MaxTokens)
+	return key, &fileConfig, string(content), true
+}
+
+// Подготовка пакета, начинающегося с первого кандидата: следующие подходящие файлы
+// с теми же промптом и моделью отправляются одним запросом. Просматриваются только
+// кандидаты, укладывающиеся в оставшийся бюджет файлов (limit, 0 - без ограничения)
+func (b *batcher) Prefetch(cands []candidate, limit int, limiter *RateLimiter) {
+	if b == nil || len(cands) == 0 || b.has(cands[0].Path) {
+		return
+	}
+	window := min(len(cands), batchLookahead)
+	if limit > 0 {
+		window = min(window, limit)
+	}
+
+	key, fileConfig, content, ok := b.eligible(cands[0])
+	if !ok {
+		return
+	}
+	items := []batchItem{{Path: cands[0].Path, Content: content}}
+	for _, c := range cands[1:window] {
+		if len(items) >= b.config.BatchSize {
+			break
+		}
+		if b.has(c.Path) {
+			continue
+		}
+		k, _, content, ok := b.eligible(c)
+		if !ok || k != key {
+			continue
+		}
+		items = append(items, batchItem{Path: c.Path, Content: content})
+	}
+	if len(items) < 2 {
+		return
+	}
+
+	logf("Пакетный запрос: %d файлов", len(items))
+	batchConfig := *fileConfig
+	batchConfig.Prompt = fileConfig.Prompt + "\n\n" + batchInstruction
+	// Примеры показывают ответ для одного документа и не подходят к формату пакета
+	batchConfig.Examples = nil
+	// Правила ответа применяются к результату каждого файла, а не к ответу целиком
+	batchConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&batchConfig, buildBatchContent(items), limiter)
+	if err != nil {
+		warnf("Предупреждение: ошибка пакетного запроса, файлы будут обработаны по отдельности: %v", err)
+		return
+	}
+
+	parts := parseBatchResponse(response, len(items))
+	total := 0
+	for _, item := range items {
+		total += len(item.Content)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, item := range items {
+		part, ok := parts[i+1]
+		if !ok {
+			warnf("Предупреждение: в ответе пакетного запроса нет результата для %s, файл будет обработан отдельно", item.Path)
+			continue
+		}
+		content := fileConfig.OutputRules.Apply(part)
+		if fileConfig.OutputRules.isRefusal(content) {
+			warnf("Предупреждение: в ответе пакетного запроса отказ для %s, файл будет обработан отдельно", item.Path)
+			continue
+		}
+		b.results[item.Path] = batchResult{Content: content, Usage: usage.Share(len(item.Content), total)}
+	}
+}
+
+// Объединение документов пакета с разделителями
+func buildBatchContent(items []batchItem) string {
+	var sb strings.Builder
+	for i, item := range items {
+		fmt.Fprintf(&sb, "%s%d%s\n%s\n%s%d%s\n\n", batchStartPrefix, i+1, batchSuffix,
+			strings.TrimRight(item.Content, "\n"), batchEndPrefix, i+1, batchSuffix)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Разбор ответа пакетного запроса: результаты по номерам документов (с 1)
+func parseBatchResponse(response string, count int) map[int]string {
+	parts := make(map[int]string)
+	lines := strings.Split(response, "\n")
+	current := 0
+	var body []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if n, ok := batchMarkerNumber(trimmed, batchStartPrefix); ok {
+			current, body = n, nil
+			continue
+		}
+		if n, ok := batchMarkerNumber(trimmed, batchEndPrefix); ok {
+			if n == current && n >= 1 && n <= count {
+				if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+					parts[n] = text
+				}
+			}
+			current, body = 0, nil
+			continue
+		}
+		if current > 0 {
+			body = append(body, line)
+		}
+	}
+	return parts
+}
+
+// Номер документа из строки-разделителя
+func batchMarkerNumber(line, prefix string) (int, bool) {
+	rest, ok := strings.CutPrefix(line, prefix)
+	if !ok {
+		return 0, false
+	}
+	rest, ok = strings.CutSuffix(rest, batchSuffix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(rest))
+	return n, err == nil
+}
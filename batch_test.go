@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildAndParseBatch(t *testing.T) {
+	items := []batchItem{{Path: "a.md", Content: "# A\n"}, {Path: "b.md", Content: "# B"}}
+	content := buildBatchContent(items)
+	expected := "<<<RICH FILE 1>>>\n# A\n<<<RICH END 1>>>\n\n<<<RICH FILE 2>>>\n# B\n<<<RICH END 2>>>"
+	if content != expected {
+		t.Errorf("Некорректное содержимое пакета:\n%q\nожидалось\n%q", content, expected)
+	}
+
+	response := "Вот результат:\n<<<RICH FILE 2>>>\n# B+\n\nТекст\n<<<RICH END 2>>>\n<<<RICH FILE 1>>>\n# A+\n<<<RICH END 1>>>\n<<<RICH FILE 3>>>\nлишний\n<<<RICH END 3>>>\n<<<RICH FILE 4>>>\nбез конца"
+	parts := parseBatchResponse(response, 3)
+	if len(parts) != 3 || parts[1] != "# A+" || parts[2] != "# B+\n\nТекст" {
+		t.Errorf("Некорректный разбор ответа: %#v", parts)
+	}
+	if parts := parseBatchResponse(response, 2); len(parts) != 2 {
+		t.Errorf("Документы с номерами больше числа файлов должны игнорироваться: %#v", parts)
+	}
+}
+
+func TestUsageShare(t *testing.T) {
+	u := Usage{PromptTokens: 100, CompletionTokens: 50}
+	if got := u.Share(1, 4); got.PromptTokens != 25 || got.CompletionTokens != 12 {
+		t.Errorf("Некорректная доля: %+v", got)
+	}
+}
+
+func TestProcessDirectoryBatchesSmallFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	files := map[string]string{
+		"a.md":   "# Заметка A",
+		"b.md":   "# Заметка B",
+		"c.md":   "# Заметка C",
+		"big.md": "# Большая\n\n" + strings.Repeat("Длинный текст. ", 100),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var request struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Некорректный запрос: %v", err)
+			return
+		}
+		prompt := request.Prompt
+		var answer strings.Builder
+		if strings.Contains(prompt, batchStartPrefix) {
+			for i := 1; strings.Contains(prompt, fmt.Sprintf("%s%d%s", batchStartPrefix, i, batchSuffix)); i++ {
+				fmt.Fprintf(&answer, "<<<RICH FILE %d>>>\nПакетный результат %d\n<<<RICH END %d>>>\n", i, i, i)
+			}
+		} else {
+			answer.WriteString("Отдельный результат")
+		}
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": answer.String()}},
+			},
+			"usage": map[string]interface{}{"prompt_tokens": 300, "completion_tokens": 300},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:      inputDir,
+		OutputDir:     outputDir,
+		ModelName:     "gpt-3.5-turbo",
+		ModelAPIURL:   server.URL + "/v1/chat/completions",
+		APIKey:        "test_key",
+		Prompt:        "Test prompt",
+		BatchMaxBytes: 100,
+		BatchSize:     5,
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	// a, b, c - один пакетный запрос, big.md - отдельный
+	if requests != 2 {
+		t.Errorf("Ожидалось 2 запроса к API, получено %d", requests)
+	}
+	for i, name := range []string{"a.md", "b.md", "c.md"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("Не удалось прочитать результат %s: %v", name, err)
+		}
+		if !strings.HasPrefix(string(data), fmt.Sprintf("Пакетный результат %d\n\n```old\n%s", i+1, files[name])) {
+			t.Errorf("Некорректный результат %s:\n%s", name, data)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "big.md")); !strings.HasPrefix(string(data), "Отдельный результат") {
+		t.Errorf("Большой файл должен обрабатываться отдельно:\n%s", data)
+	}
+}
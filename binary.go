@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// Размер начала файла, по которому определяется двоичное содержимое
+const binarySniffSize = 8000
+
+// Доля управляющих символов в начале файла, начиная с которой файл считается двоичным
+const maxControlRatio = 0.1
+
+// Проверка, что файл похож на двоичный (изображение, архив, исполняемый файл с
+// расширением .md): возвращает причину. По первым binarySniffSize байтам: нулевой
+// байт или больше maxControlRatio управляющих символов (кроме табуляции, перевода
+// строки и страницы). Некорректный UTF-8 не учитывается: текст в однобайтовой
+// кодировке не считается двоичным
+func sniffBinary(content []byte) (string, bool) {
+	head := content
+	if len(head) > binarySniffSize {
+		head = head[:binarySniffSize]
+	}
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return tr("файл в кодировке UTF-16, сохраните его в UTF-8"), true
+	}
+	if i := bytes.IndexByte(head, 0); i >= 0 {
+		return trf("нулевой байт в позиции %d (%s)", i, sniffedType(head)), true
+	}
+	control := 0
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' || b == 0x7F {
+			control++
+		}
+	}
+	if len(head) > 0 && float64(control)/float64(len(head)) > maxControlRatio {
+		return trf("управляющих символов %d из %d байт (%s)", control, len(head), sniffedType(head)), true
+	}
+	return "", false
+}
+
+// Тип содержимого по сигнатуре для сообщения об ошибке
+func sniffedType(head []byte) string {
+	kind := http.DetectContentType(head)
+	if i := strings.IndexByte(kind, ';'); i >= 0 {
+		kind = kind[:i]
+	}
+	return kind
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffBinary(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), make([]byte, 64)...)
+	testCases := []struct {
+		name    string
+		content []byte
+		binary  bool
+		reason  string
+	}{
+		{"markdown", []byte("# Заметка\n\n\tСписок:\r\n- пункт\f\n"), false, ""},
+		{"windows-1251", []byte{'#', ' ', 0xcf, 0xf0, 0xe8, 0xe2, 0xe5, 0xf2, '\n'}, false, ""},
+		{"пустой", nil, false, ""},
+		{"png", png, true, "image/png"},
+		{"utf-16", []byte{0xFF, 0xFE, '#', 0, ' ', 0}, true, "UTF-16"},
+		{"управляющие символы", []byte("ab\x01\x02\x03\x04\x05\x06cd"), true, "управляющих символов 6 из 10"},
+		{"нулевой байт после начала", append([]byte(strings.Repeat("текст ", binarySniffSize)), 0), false, ""},
+	}
+	for _, tc := range testCases {
+		reason, binary := sniffBinary(tc.content)
+		if binary != tc.binary || !strings.Contains(reason, tc.reason) {
+			t.Errorf("%s: sniffBinary() = %q, %v; ожидалось %v с причиной %q", tc.name, reason, binary, tc.binary, tc.reason)
+		}
+	}
+}
+
+// Двоичный файл с расширением .md не отправляется в API и не записывается в результат
+func TestProcessFileRefusesBinary(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "image.md")
+	outputPath := filepath.Join(tmpDir, "out", "image.md")
+	if err := os.WriteFile(inputPath, append([]byte("GIF89a\x01\x00\x01\x00"), make([]byte, 32)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions", MaxTokens: 100}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err == nil || !strings.Contains(err.Error(), "не похож на текстовый") || !strings.Contains(err.Error(), "image/gif") {
+		t.Fatalf("Ожидалась ошибка двоичного файла, получено %v", err)
+	}
+	if errorCategory(err) != ErrorValidation || result.Status != StatusFailed {
+		t.Errorf("Неожиданная категория %q или статус %q", errorCategory(err), result.Status)
+	}
+	if requests != 0 {
+		t.Errorf("Двоичный файл не должен отправляться в API, запросов: %d", requests)
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		t.Error("Результат для двоичного файла не должен записываться")
+	}
+}
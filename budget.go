@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+)
+
+// Бюджет одного запуска: ограничение числа обогащенных файлов и затрат в долларах
+type runBudget struct {
+	mu       sync.Mutex
+	maxFiles int
+	maxUSD   float64
+	files    int
+	spent    float64
+}
+
+// Создание бюджета запуска по настройкам конфигурации
+func newRunBudget(config *Config) *runBudget {
+	return &runBudget{
+		maxFiles: config.MaxFiles,
+		maxUSD:   config.MaxUSD,
+	}
+}
+
+// Проверка исчерпания бюджета перед отправкой очередного файла
+func (b *runBudget) Exhausted() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxFiles > 0 && b.files >= b.maxFiles {
+		return trf("достигнут лимит файлов на запуск (%d)", b.maxFiles), true
+	}
+	if b.maxUSD > 0 && b.spent >= b.maxUSD {
+		return trf("достигнут лимит затрат на запуск ($%.4f из $%.4f)", b.spent, b.maxUSD), true
+	}
+	return "", false
+}
+
+// Учет файла: стоимость его запросов учитывается всегда, а место в лимите файлов
+// занимает только обогащенный файл. Файл с ошибкой API или отклоненным результатом
+// не расходует лимит файлов, чтобы временные ошибки не завершали запуск без результатов
+func (b *runBudget) Record(cost float64, enriched bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if enriched {
+		b.files++
+	}
+	b.spent += cost
+}
+
+// Лимит файлов не будет превышен, если отправить еще один файл, пока inFlight
+// файлов в обработке: их места в бюджете заняты до учета итога
+func (b *runBudget) Admits(inFlight int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.maxFiles <= 0 || b.files+inFlight < b.maxFiles
+}
+
+// Оставшееся число файлов в бюджете запуска (0 - без ограничения)
+func (b *runBudget) RemainingFiles() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxFiles <= 0 {
+		return 0
+	}
+	return max(b.maxFiles-b.files, 0)
+}
+
+// Суммарные затраты за запуск в долларах
+func (b *runBudget) Spent() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
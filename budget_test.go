@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunBudget(t *testing.T) {
+	t.Run("MaxFiles", func(t *testing.T) {
+		budget := newRunBudget(&Config{MaxFiles: 2})
+		// Файл с ошибкой не занимает место в лимите файлов
+		budget.Record(0, false)
+		for i := 0; i < 2; i++ {
+			if _, exhausted := budget.Exhausted(); exhausted {
+				t.Fatalf("Бюджет исчерпан раньше времени на файле %d", i)
+			}
+			budget.Record(0, true)
+		}
+		reason, exhausted := budget.Exhausted()
+		if !exhausted || !strings.Contains(reason, "лимит файлов") {
+			t.Errorf("Ожидалось исчерпание лимита файлов, получено %q", reason)
+		}
+	})
+
+	t.Run("MaxUSD", func(t *testing.T) {
+		budget := newRunBudget(&Config{MaxUSD: 1})
+		budget.Record(0.6, false)
+		if _, exhausted := budget.Exhausted(); exhausted {
+			t.Fatal("Бюджет исчерпан раньше времени")
+		}
+		budget.Record(0.6, false)
+		reason, exhausted := budget.Exhausted()
+		if !exhausted || !strings.Contains(reason, "лимит затрат") {
+			t.Errorf("Ожидалось исчерпание лимита затрат, получено %q", reason)
+		}
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		budget := newRunBudget(&Config{})
+		for i := 0; i < 100; i++ {
+			budget.Record(10, true)
+		}
+		if _, exhausted := budget.Exhausted(); exhausted {
+			t.Error("Бюджет без ограничений не должен исчерпываться")
+		}
+	})
+}
+
+// Файлы с ошибками API не расходуют -max-files: запуск обогащает следующий файл
+func TestMaxFilesSkipsFailed(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "Сбой") {
+			http.Error(w, `{"error": "bad request"}`, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range []string{"Сбой", "Сбой", "Текст", "Текст"} {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("note%d.md", i)), []byte(fmt.Sprintf("# Заметка %d\n\n%s", i, text)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100, MaxFiles: 1}
+
+	var failed *filesFailedError
+	if err := processDirectory(config, configPath); !errors.As(err, &failed) {
+		t.Fatalf("Ожидались файлы с ошибками, получено %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Запросов к API: %d, ожидалось 3", got)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "note2.md")); err != nil {
+		t.Errorf("Файл после ошибок не обогащен: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "note3.md")); !os.IsNotExist(err) {
+		t.Error("Лимит файлов превышен")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Проверочный файл ([CANARY]): перед запуском обогащается один файл, и остальные
+// файлы обрабатываются только после проверки результата
+type CanaryConfig struct {
+	// Файл входной директории ("" - без проверочного файла)
+	File string
+	// Продолжать без подтверждения, если проверочный файл обогащен без ошибок
+	Auto bool
+}
+
+// Чтение секции [CANARY]
+func loadCanaryConfig(section *ini.Section) CanaryConfig {
+	return CanaryConfig{
+		File: strings.TrimSpace(section.Key("file").String()),
+		Auto: section.Key("auto").MustBool(false),
+	}
+}
+
+// Запуск отменен после проверки результата проверочного файла
+var errCanaryDeclined = errors.New("canary declined")
+
+// Обогащение проверочного файла и подтверждение запуска: результат выводится в out,
+// а запуск продолжается с auto, yes или после подтверждения из in (nil - стандартный
+// ввод не терминал). После проверки список исключений config обновляется, чтобы
+// файл не обогащался повторно
+func runCanary(config *Config, configPath string, yes bool, in io.Reader, out io.Writer) error {
+	rootConfig, inputPath, relPath, err := previewInput(config, config.Canary.File)
+	if err != nil {
+		return withCategory(ErrorConfig, errorf("проверочный файл: %v", err))
+	}
+	if inputDir, err := filepath.Abs(rootConfig.InputDir); err != nil || !samePath(filepath.Join(inputDir, relPath), inputPath) {
+		return withCategory(ErrorConfig, errorf("проверочный файл %s должен находиться во входной директории", config.Canary.File))
+	}
+	key := rootKey(rootConfig.RootName, normalizeRelPath(relPath))
+
+	// Итог проверочного файла и запуск, которым он обработан
+	var finished statusEvent
+	var runID string
+	observeStatusEvents(func(ev statusEvent) {
+		switch {
+		case ev.Event == EventRunStarted:
+			runID = ev.RunID
+		case ev.Event == EventFileFinished && pathKey(ev.Path) == pathKey(key):
+			finished = ev
+		}
+	})
+	infof("Проверочный файл: %s", key)
+	err = reprocessFiles(config, configPath, []string{key})
+	observeStatusEvents(nil)
+	switch {
+	case finished.Error != "":
+		return errorf("проверочный файл %s не обогащен: %s", key, finished.Error)
+	case err != nil:
+		return errorf("проверочный файл %s не обогащен: %v", key, err)
+	case finished.Status != StatusEnriched && finished.Status != StatusConflict:
+		return withCategory(ErrorConfig, errorf("проверочный файл %s не обогащен (%s), выберите другой файл", key, finished.Status))
+	}
+
+	// Результат проверочного файла
+	if data, err := os.ReadFile(finished.Output); err == nil {
+		fmt.Fprintf(out, "\n"+tr("Результат проверочного файла %s (%s):")+"\n\n%s\n", key, finished.Output, strings.TrimRight(string(data), "\n"))
+	}
+	fmt.Fprintf(out, "\n"+tr("Токены: %d входных, %d выходных; стоимость: $%.4f\n"), finished.PromptTokens, finished.CompletionTokens, finished.CostUSD)
+
+	switch {
+	case config.Canary.Auto || yes:
+	case in == nil:
+		return withCategory(ErrorConfig, errorf("подтвердите запуск после проверочного файла параметром -yes или включите auto в секции [CANARY]"))
+	case !confirmCanary(in, out):
+		if runID != "" && config.StateDir != "" {
+			infof("Запуск отменен; результат проверочного файла можно отменить: rich undo --run %s", runID)
+		} else {
+			infof("Запуск отменен")
+		}
+		return errCanaryDeclined
+	}
+
+	// Проверочный файл добавлен в список исключений
+	current, err := loadConfig(configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	config.ExcludedFiles = current.ExcludedFiles
+	return nil
+}
+
+// Подтверждение обработки остальных файлов в стандартном вводе
+func confirmCanary(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, tr("Обработать остальные файлы? [y/N]: "))
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes", "д", "да":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunCanary(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		for _, name := range []string{"Первая", "Вторая", "Проверочная"} {
+			if strings.Contains(string(body), name) {
+				requested = append(requested, name)
+			}
+		}
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено\n\nРезультат модели"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(filepath.Join(inputDir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.md":            "# Первая\n\nТекст первой заметки",
+		"b.md":            "# Вторая\n\nТекст второй заметки",
+		"notes/canary.md": "# Проверочная\n\nТекст проверочной заметки",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[CANARY]\nfile = notes/canary.md\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := func() *Config {
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		return config
+	}
+
+	// Отказ после проверки: обработан только проверочный файл
+	var out bytes.Buffer
+	if err := runCanary(load(), configPath, false, strings.NewReader("n\n"), &out); !errors.Is(err, errCanaryDeclined) {
+		t.Fatalf("Ожидалась отмена запуска, получено: %v", err)
+	}
+	if len(requested) != 1 || requested[0] != "Проверочная" {
+		t.Errorf("Ожидался запрос только для проверочного файла: %v", requested)
+	}
+	if !strings.Contains(out.String(), "Результат модели") || !strings.Contains(out.String(), "[y/N]") {
+		t.Errorf("Результат проверочного файла не выведен:\n%s", out.String())
+	}
+
+	// Без терминала и -yes запуск не продолжается
+	if err := runCanary(load(), configPath, false, nil, io.Discard); err == nil || errors.Is(err, errCanaryDeclined) {
+		t.Errorf("Ожидалась ошибка без подтверждения, получено: %v", err)
+	}
+
+	// Подтверждение: проверочный файл обогащается заново, остальные - в основном запуске
+	requested = nil
+	config := load()
+	if err := runCanary(config, configPath, false, strings.NewReader("да\n"), io.Discard); err != nil {
+		t.Fatalf("runCanary() вернул ошибку: %v", err)
+	}
+	if !isExcluded(config, "notes/canary.md") {
+		t.Error("Проверочный файл должен попасть в список исключений основного запуска")
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if strings.Join(requested, ",") != "Проверочная,Первая,Вторая" {
+		t.Errorf("Проверочный файл должен обогащаться первым и один раз: %v", requested)
+	}
+
+	// Файл вне входной директории
+	config = load()
+	config.Canary.File = configPath
+	if err := runCanary(config, configPath, true, nil, io.Discard); err == nil {
+		t.Error("Ожидалась ошибка для файла вне входной директории")
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Маркеры раздела изменений, раздел заменяется при повторной обработке
+const (
+	ChangesStartMarker = "<!-- rich:changes -->"
+	ChangesEndMarker   = "<!-- rich:changes-end -->"
+)
+
+// Куда записывается список изменений
+const (
+	// Раздел в конце каждого обогащенного документа
+	ChangelogSection = "section"
+	// Общий файл изменений запусков в выходной директории
+	ChangelogFile = "file"
+	ChangelogBoth = "both"
+)
+
+// Файл изменений запусков по умолчанию
+const defaultChangelogFile = "CHANGELOG.md"
+
+// Максимальный размер изменений, отправляемых модели (символов)
+const changelogMaxDiffRunes = 12000
+
+// Минимальная длина слова, по которому пункт списка сверяется с изменениями, и
+// длина основы слова: слова с общей основой считаются совпадающими, чтобы пункт
+// "добавлен раздел о репликации" подтверждался заголовком "Репликация"
+const (
+	changelogMinWordRunes = 4
+	changelogStemRunes    = 5
+)
+
+// Инструкция модели для списка изменений
+const changelogInstruction = `Below is a line diff between the original and the enriched version of a markdown document: lines starting with "+" were added, lines starting with "-" were removed. Summarize what changed as up to %d short bullet points in the language of the document. Describe only changes that appear in the diff, do not repeat unchanged content. Reply with the bullet points only, one per line, each starting with "- ".`
+
+// Список изменений из секции [CHANGELOG]
+type ChangelogConfig struct {
+	Enabled bool
+	// section, file или both
+	Target string
+	// Путь файла изменений относительно выходной директории
+	File string
+	// Максимум пунктов на документ
+	MaxItems int
+	// Заголовок раздела в документе
+	Heading string
+}
+
+// Чтение секции [CHANGELOG]
+func loadChangelogConfig(section *ini.Section) (ChangelogConfig, error) {
+	changelog := ChangelogConfig{
+		Enabled:  section.Key("enabled").MustBool(false),
+		Target:   strings.ToLower(section.Key("target").MustString(ChangelogSection)),
+		File:     section.Key("file").MustString(defaultChangelogFile),
+		MaxItems: section.Key("max_items").MustInt(5),
+		Heading:  section.Key("heading").MustString("Что изменилось"),
+	}
+	switch changelog.Target {
+	case ChangelogSection, ChangelogFile, ChangelogBoth:
+	default:
+		return changelog, errorf("неизвестное значение target %q в секции [CHANGELOG]: ожидалось section, file или both", changelog.Target)
+	}
+	if changelog.MaxItems <= 0 {
+		return changelog, errorf("max_items в секции [CHANGELOG] должен быть положительным: %d", changelog.MaxItems)
+	}
+	if !isRelPathSafe(changelog.File) {
+		return changelog, errorf("файл изменений должен задаваться путем внутри выходной директории: %s", changelog.File)
+	}
+	return changelog, nil
+}
+
+// Раздел изменений добавляется в документ
+func (c ChangelogConfig) inSection() bool {
+	return c.Enabled && (c.Target == ChangelogSection || c.Target == ChangelogBoth)
+}
+
+// Изменения записываются в общий файл
+func (c ChangelogConfig) inFile() bool {
+	return c.Enabled && (c.Target == ChangelogFile || c.Target == ChangelogBoth)
+}
+
+// Путь общего файла изменений
+func (c *Config) changelogFile() string {
+	return filepath.Join(c.OutputDir, c.Changelog.File)
+}
+
+// Служебный файл выходной директории (оглавление или файл изменений), который не
+// является обогащенным документом; rel - путь относительно выходной директории.
+// Файл изменений ведется только в выходной директории основного корня
+func (c *Config) isServiceFile(rel string) bool {
+	if c.IndexFile != "" && pathKey(rel) == pathKey(c.IndexFile) {
+		return true
+	}
+	return c.RootName == "" && c.Changelog.inFile() && pathKey(rel) == pathKey(c.Changelog.File)
+}
+
+// Добавленные и удаленные строки: строки, которых нет в другой версии (с учетом
+// повторов), в порядке следования; пустые строки и отступы не учитываются
+func lineChanges(original, enriched string) (added, removed []string) {
+	count := func(text string) map[string]int {
+		counts := make(map[string]int)
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				counts[line]++
+			}
+		}
+		return counts
+	}
+	diff := func(text string, other map[string]int) []string {
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if other[line] > 0 {
+				other[line]--
+				continue
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	return diff(enriched, count(original)), diff(original, count(enriched))
+}
+
+// Изменения в виде diff для модели, не длиннее changelogMaxDiffRunes
+func formatLineChanges(added, removed []string) string {
+	var b strings.Builder
+	for _, line := range removed {
+		b.WriteString("- " + line + "\n")
+	}
+	for _, line := range added {
+		b.WriteString("+ " + line + "\n")
+	}
+	text := b.String()
+	if runes := []rune(text); len(runes) > changelogMaxDiffRunes {
+		text = string(runes[:changelogMaxDiffRunes]) + "\n...\n"
+	}
+	return text
+}
+
+// Запрос списка изменений документа. Пункты, не подтвержденные изменениями (ни одно
+// значимое слово пункта не встречается в добавленных или удаленных строках),
+// отбрасываются. Без изменений запрос не выполняется
+func summarizeChanges(config *Config, original, enriched string, limiter *RateLimiter) ([]string, Usage, error) {
+	added, removed := lineChanges(original, enriched)
+	if len(added) == 0 && len(removed) == 0 {
+		return nil, Usage{}, nil
+	}
+	changelogConfig := *config
+	changelogConfig.Prompt = fmt.Sprintf(changelogInstruction, config.Changelog.MaxItems)
+	// Примеры и правила ответа относятся к обогащению, а не к списку изменений
+	changelogConfig.Examples = nil
+	changelogConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&changelogConfig, formatLineChanges(added, removed), limiter)
+	if err != nil {
+		return nil, usage, err
+	}
+	items := parseBulletList(response)
+	valid := validateChanges(items, append(added, removed...))
+	if dropped := len(items) - len(valid); dropped > 0 {
+		logf("Отброшено пунктов списка изменений, не подтвержденных изменениями: %d", dropped)
+	}
+	if len(valid) > config.Changelog.MaxItems {
+		valid = valid[:config.Changelog.MaxItems]
+	}
+	return valid, usage, nil
+}
+
+// Пункты маркированного или нумерованного списка из ответа модели
+func parseBulletList(response string) []string {
+	var items []string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		item, ok := strings.CutPrefix(line, "- ")
+		if !ok {
+			item, ok = strings.CutPrefix(line, "* ")
+		}
+		if !ok {
+			if dot := strings.Index(line, ". "); dot > 0 && dot <= 3 && strings.Trim(line[:dot], "0123456789") == "" {
+				item, ok = line[dot+2:], true
+			}
+		}
+		if item = strings.TrimSpace(item); ok && item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Пункты, подтвержденные изменениями: основа хотя бы одного слова пункта длиной от
+// changelogMinWordRunes символов встречается в измененных строках
+func validateChanges(items, changed []string) []string {
+	stems := make(map[string]bool)
+	for _, w := range tokenize(strings.Join(changed, "\n")) {
+		stems[changelogStem(w)] = true
+	}
+	var valid []string
+	for _, item := range items {
+		for _, w := range tokenize(item) {
+			if len([]rune(w)) >= changelogMinWordRunes && stems[changelogStem(w)] {
+				valid = append(valid, item)
+				break
+			}
+		}
+	}
+	return valid
+}
+
+// Основа слова для сверки пунктов списка с изменениями
+func changelogStem(word string) string {
+	if runes := []rune(word); len(runes) > changelogStemRunes {
+		return string(runes[:changelogStemRunes])
+	}
+	return word
+}
+
+// Раздел изменений документа
+func renderChanges(heading string, items []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", heading)
+	for _, item := range items {
+		b.WriteString("- " + item + "\n")
+	}
+	return b.String()
+}
+
+// Удаление ранее добавленного раздела изменений
+func stripChanges(text string) string {
+	start := strings.LastIndex(text, ChangesStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], ChangesEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(ChangesEndMarker)
+	return strings.TrimRight(text[:start], "\n") + text[end:]
+}
+
+// Добавление раздела изменений в конец документа (с заменой предыдущего)
+func withChanges(text, section string) string {
+	text = strings.TrimRight(stripChanges(text), "\n")
+	return text + "\n\n" + ChangesStartMarker + "\n" + strings.TrimSpace(section) + "\n" + ChangesEndMarker + "\n"
+}
+
+// Изменения документов запуска для общего файла изменений
+type runChangelog struct {
+	mu      sync.Mutex
+	entries map[string]changelogEntry
+}
+
+// Изменения одного документа
+type changelogEntry struct {
+	// Абсолютный путь результата
+	Output string
+	Items  []string
+}
+
+// Создание списка изменений запуска
+func newRunChangelog() *runChangelog {
+	return &runChangelog{entries: make(map[string]changelogEntry)}
+}
+
+// Добавление изменений документа по пути в общем состоянии запуска
+func (c *runChangelog) Add(key, outputPath string, items []string) {
+	if c == nil || len(items) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[normalizeRelPath(key)] = changelogEntry{Output: outputPath, Items: items}
+}
+
+// Запись изменений запуска в начало файла изменений (новые запуски сверху)
+func (c *runChangelog) Save(path, runID string, now time.Time) error {
+	if c == nil || len(c.entries) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	if runID != "" {
+		fmt.Fprintf(&b, tr("## Запуск %s (%s)\n\n"), runID, now.Format(time.DateTime))
+	} else {
+		fmt.Fprintf(&b, tr("## Запуск %s\n\n"), now.Format(time.DateTime))
+	}
+	for _, key := range keys {
+		e := c.entries[key]
+		if rel, err := filepath.Rel(filepath.Dir(path), e.Output); err == nil && isRelPathSafe(rel) {
+			fmt.Fprintf(&b, "### [%s](%s)\n\n", escapeLinkText(key), escapeLinkPath(filepath.ToSlash(rel)))
+		} else {
+			fmt.Fprintf(&b, "### %s\n\n", key)
+		}
+		for _, item := range e.Items {
+			b.WriteString("- " + item + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return withFileLock(path, func() error {
+		previous, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return errorf("не удалось прочитать файл изменений: %v", err)
+		}
+		head, rest := tr("# Изменения\n\n"), string(previous)
+		if strings.HasPrefix(rest, "# ") {
+			if i := strings.Index(rest, "\n\n"); i >= 0 {
+				head, rest = rest[:i+2], rest[i+2:]
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return errorf("не удалось записать файл изменений: %v", err)
+		}
+		if err := safeWriteFile(path, []byte(head+b.String()+rest), 0644); err != nil {
+			return errorf("не удалось записать файл изменений: %v", err)
+		}
+		return nil
+	})
+}
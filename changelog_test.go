@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineChanges(t *testing.T) {
+	original := "# Kafka\n\nБрокер хранит партиции.\n\n- пункт\n- пункт\n"
+	enriched := "# Kafka\n\n  Брокер хранит партиции.\n\n## Репликация\n\nРеплики копируют данные.\n- пункт\n"
+	added, removed := lineChanges(original, enriched)
+	if want := []string{"## Репликация", "Реплики копируют данные."}; !reflect.DeepEqual(added, want) {
+		t.Errorf("добавленные строки: %q, ожидалось %q", added, want)
+	}
+	if want := []string{"- пункт"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("удаленные строки: %q, ожидалось %q", removed, want)
+	}
+}
+
+func TestValidateChanges(t *testing.T) {
+	items := parseBulletList("Изменения:\n- Добавлен раздел о репликации\n* Исправлены опечатки\n2. Описаны реплики и копирование данных\n")
+	if len(items) != 3 {
+		t.Fatalf("пункты списка: %q", items)
+	}
+	valid := validateChanges(items, []string{"## Репликация", "Реплики копируют данные."})
+	want := []string{"Добавлен раздел о репликации", "Описаны реплики и копирование данных"}
+	if !reflect.DeepEqual(valid, want) {
+		t.Errorf("подтвержденные пункты: %q, ожидалось %q", valid, want)
+	}
+}
+
+func TestWithChanges(t *testing.T) {
+	doc := withChanges("# Заметка\n", renderChanges("Что изменилось", []string{"Добавлен пример"}))
+	doc = withChanges(doc, renderChanges("Что изменилось", []string{"Исправлены опечатки"}))
+	if strings.Count(doc, ChangesStartMarker) != 1 || strings.Contains(doc, "Добавлен пример") || !strings.Contains(doc, "- Исправлены опечатки\n") {
+		t.Errorf("раздел не заменен:\n%s", doc)
+	}
+	if got := stripChanges(doc); got != "# Заметка\n" {
+		t.Errorf("stripChanges() = %q", got)
+	}
+}
+
+func TestChangelogFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "kafka.md"), []byte("# Kafka\n\nБрокер хранит партиции."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		content := "# Kafka\\n\\nБрокер хранит партиции.\\n\\n## Репликация\\n\\nРеплики копируют данные."
+		if strings.Contains(string(body), "Summarize what changed") {
+			content = "- Добавлен раздел о репликации\\n- Улучшен стиль"
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Changelog: ChangelogConfig{Enabled: true, Target: ChangelogBoth, File: "CHANGELOG.md", MaxItems: 5, Heading: "Что изменилось"}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(outputDir, "kafka.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "## Что изменилось\n\n- Добавлен раздел о репликации\n") || strings.Contains(string(output), "Улучшен стиль") {
+		t.Errorf("раздел изменений документа:\n%s", output)
+	}
+	changelog, err := os.ReadFile(filepath.Join(outputDir, "CHANGELOG.md"))
+	if err != nil {
+		t.Fatalf("файл изменений не записан: %v", err)
+	}
+	if !strings.HasPrefix(string(changelog), "# Изменения\n\n## Запуск ") || !strings.Contains(string(changelog), "### [kafka.md](kafka.md)\n\n- Добавлен раздел о репликации\n") {
+		t.Errorf("файл изменений:\n%s", changelog)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Имя файла контрольных сумм выходной директории по умолчанию
+const defaultChecksumFile = "MANIFEST.sha256"
+
+// Контрольная сумма SHA-256 файла
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Обновление файла контрольных сумм выходной директории в формате sha256sum
+// ("<хэш>  <путь>", пути через / по алфавиту), который проверяется командой
+// sha256sum -c. Файл заменяется атомарно и не перезаписывается без изменений.
+// Скрытые директории и временные файлы записи не учитываются
+func updateChecksums(config *Config, outputDir string) error {
+	checksumPath := filepath.Join(outputDir, config.ChecksumFile)
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		return nil
+	}
+	var lines []string
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != outputDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || p == checksumPath || (strings.HasPrefix(d.Name(), "temp_") && strings.HasSuffix(d.Name(), ".tmp")) {
+			return nil
+		}
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, sum+"  "+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return errorf("не удалось вычислить контрольные суммы %s: %v", outputDir, err)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][66:] < lines[j][66:] })
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		fmt.Fprintln(&buf, line)
+	}
+	if prev, err := os.ReadFile(checksumPath); err == nil && bytes.Equal(prev, buf.Bytes()) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(checksumPath), 0755); err != nil {
+		return errorf("не удалось сохранить контрольные суммы: %v", err)
+	}
+	if err := safeWriteFile(checksumPath, buf.Bytes(), 0644); err != nil {
+		return errorf("не удалось сохранить контрольные суммы: %v", err)
+	}
+	return nil
+}
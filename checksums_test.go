@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateChecksums(t *testing.T) {
+	outputDir := t.TempDir()
+	files := map[string]string{
+		"a.md":            "# A",
+		"notes/b.md":      "# B",
+		"notes/b.md.new":  "# B новое",
+		".git/config":     "скрытый",
+		"temp_123.tmp":    "временный",
+		"MANIFEST.sha256": "старый",
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	config := &Config{ChecksumFile: defaultChecksumFile}
+	if err := updateChecksums(config, outputDir); err != nil {
+		t.Fatalf("updateChecksums() вернул ошибку: %v", err)
+	}
+	manifestPath := filepath.Join(outputDir, defaultChecksumFile)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sum("# A") + "  a.md\n" + sum("# B") + "  notes/b.md\n" + sum("# B новое") + "  notes/b.md.new\n"
+	if string(data) != want {
+		t.Errorf("Неожиданные контрольные суммы:\n%s\nожидалось:\n%s", data, want)
+	}
+
+	// Без изменений файл не перезаписывается
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(manifestPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateChecksums(config, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(manifestPath); !info.ModTime().Equal(old) {
+		t.Error("Файл контрольных сумм не должен перезаписываться без изменений")
+	}
+
+	// Измененный вне запуска файл получает новую сумму
+	if err := os.WriteFile(filepath.Join(outputDir, "a.md"), []byte("# A изменен"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateChecksums(config, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(manifestPath)
+	if !strings.HasPrefix(string(data), sum("# A изменен")+"  a.md\n") {
+		t.Errorf("Сумма измененного файла не обновлена:\n%s", data)
+	}
+
+	// Выходной директории еще нет
+	if err := updateChecksums(config, filepath.Join(outputDir, "missing")); err != nil {
+		t.Errorf("Для несуществующей директории ожидался nil, получено: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Режимы передачи контекста между частями документа, который обогащается по частям
+const (
+	// Части обогащаются независимо
+	ChunkContextNone = "none"
+	// Перед промптом передается конец результата предыдущей части
+	ChunkContextPrevious = "previous"
+	// Предыдущие части и их результаты передаются сообщениями диалога
+	ChunkContextConversation = "conversation"
+)
+
+// Проверка режима контекста частей
+func validateChunkContext(mode string) error {
+	switch mode {
+	case ChunkContextNone, ChunkContextPrevious, ChunkContextConversation:
+		return nil
+	}
+	return errorf("некорректное значение chunk_context %q: ожидалось %s, %s или %s",
+		mode, ChunkContextNone, ChunkContextPrevious, ChunkContextConversation)
+}
+
+// Контекст обогащения по частям: результаты уже обработанных частей документа,
+// чтобы терминология и тон совпадали во всем собранном документе
+type chunkContext struct {
+	mode string
+	// Бюджет контекста в токенах
+	budget int
+	// Обработанные части: оригинал и результат
+	parts []fewShotExample
+}
+
+// Контекст частей документа по настройкам конфигурации
+func newChunkContext(config *Config) *chunkContext {
+	return &chunkContext{mode: config.ChunkContext, budget: config.ChunkContextTokens}
+}
+
+// Запоминание обработанной части
+func (c *chunkContext) Add(original, enriched string) {
+	if c.mode == ChunkContextNone || c.mode == "" {
+		return
+	}
+	c.parts = append(c.parts, fewShotExample{Original: original, Enriched: strings.TrimSpace(enriched)})
+}
+
+// Конфигурация запроса очередной части с контекстом предыдущих. В режиме
+// conversation передаются последние пары, которые помещаются в бюджет; если не
+// помещается ни одна или формат API не поддерживает диалог - конец предыдущего результата
+func (c *chunkContext) Apply(config *Config) *Config {
+	if len(c.parts) == 0 || c.budget <= 0 {
+		return config
+	}
+	withContext := *config
+	if c.mode == ChunkContextConversation {
+		if config.provider().api().Conversation() {
+			used, from := 0, len(c.parts)
+			for from > 0 {
+				part := c.parts[from-1]
+				used += estimateTokens(userMessage(config.Prompt, part.Original)) + estimateTokens(part.Enriched)
+				if used > c.budget {
+					break
+				}
+				from--
+			}
+			if from < len(c.parts) {
+				withContext.Examples = append(slices.Clone(config.Examples), c.parts[from:]...)
+				return &withContext
+			}
+		}
+	}
+	tail := textTail(c.parts[len(c.parts)-1].Enriched, c.budget)
+	withContext.Prompt = "Enriched text of the preceding part of this document (keep its terminology and tone, do not repeat it):\n\n" +
+		tail + "\n\n---\n\n" + config.Prompt
+	return &withContext
+}
+
+// Конец текста не больше maxTokens токенов, начиная с границы слова
+func textTail(text string, maxTokens int) string {
+	runes := []rune(text)
+	keep := maxTokens * 4
+	if len(runes) <= keep {
+		return text
+	}
+	runes = runes[len(runes)-keep:]
+	if i := slices.IndexFunc(runes, unicode.IsSpace); i >= 0 {
+		runes = runes[i:]
+	}
+	return "…" + strings.TrimLeftFunc(string(runes), unicode.IsSpace)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkContextPrevious(t *testing.T) {
+	config := &Config{Prompt: "Обогати", ChunkContext: ChunkContextPrevious, ChunkContextTokens: 10}
+	c := newChunkContext(config)
+	if got := c.Apply(config); got != config {
+		t.Error("для первой части конфигурация не должна меняться")
+	}
+	c.Add("часть 1", strings.Repeat("начало ", 20)+"последние слова результата")
+	got := c.Apply(config)
+	if !strings.HasSuffix(got.Prompt, "Обогати") || !strings.Contains(got.Prompt, "последние слова результата") {
+		t.Errorf("промпт должен начинаться с конца предыдущего результата: %q", got.Prompt)
+	}
+	if config.Prompt != "Обогати" {
+		t.Error("исходная конфигурация не должна меняться")
+	}
+}
+
+func TestChunkContextConversation(t *testing.T) {
+	config := &Config{Provider: providerOpenAICompatible, Prompt: "P", ChunkContext: ChunkContextConversation, ChunkContextTokens: 12,
+		Examples: []fewShotExample{{Name: "ex", Original: "o", Enriched: "e"}}}
+	c := newChunkContext(config)
+	c.Add("первая часть", "первый результат")
+	c.Add("вторая часть", "второй результат")
+	got := c.Apply(config)
+	// В бюджет 12 токенов помещается только последняя пара
+	if len(got.Examples) != 2 || got.Examples[0].Name != "ex" || got.Examples[1].Original != "вторая часть" {
+		t.Errorf("ожидались пример конфигурации и последняя часть, получено %+v", got.Examples)
+	}
+	if len(config.Examples) != 1 {
+		t.Error("примеры исходной конфигурации не должны меняться")
+	}
+
+	// Пара больше бюджета: передается конец предыдущего результата
+	c.Add("третья часть", strings.Repeat("длинный результат ", 20))
+	got = c.Apply(config)
+	if len(got.Examples) != 1 || !strings.Contains(got.Prompt, "длинный результат") {
+		t.Errorf("ожидался конец результата в промпте, получено %q и %+v", got.Prompt, got.Examples)
+	}
+}
+
+func TestChunkContextNone(t *testing.T) {
+	config := &Config{Prompt: "P", ChunkContext: ChunkContextNone, ChunkContextTokens: 100}
+	c := newChunkContext(config)
+	c.Add("часть", "результат")
+	if got := c.Apply(config); got != config {
+		t.Error("в режиме none контекст не передается")
+	}
+	if err := validateChunkContext("summary"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного режима")
+	}
+}
+
+func TestSplitForContextReservesChunkContext(t *testing.T) {
+	content := strings.Repeat("## Раздел\n\n"+strings.Repeat("Текст раздела. ", 100)+"\n\n", 12)
+	config := &Config{ModelName: "local", ContextWindow: 4000, MaxOutputTokens: 1400, MaxTokens: 1400}
+	plain := splitForContext(config, content)
+	config.ChunkContext, config.ChunkContextTokens = ChunkContextPrevious, 1000
+	reserved := splitForContext(config, content)
+	if len(reserved) <= len(plain) {
+		t.Errorf("с контекстом частей ожидалось больше частей: %d против %d", len(reserved), len(plain))
+	}
+	if strings.Join(reserved, "") != content {
+		t.Error("части должны в сумме давать исходный документ")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Маркеры раздела источников, раздел заменяется при повторной обработке
+const (
+	CitationsStartMarker = "<!-- rich:citations -->"
+	CitationsEndMarker   = "<!-- rich:citations-end -->"
+)
+
+// Маркер неподтвержденного источника в документе
+const UnverifiedCitationMarker = "<!-- rich:unverified -->"
+
+// Действия с неподтвержденными источниками
+const (
+	// Источник не добавляется в документ
+	CitationsDrop = "drop"
+	// Источник добавляется с маркером <!-- rich:unverified -->
+	CitationsFlag = "flag"
+)
+
+// Максимальный размер страницы, из которой извлекается заголовок
+const citationMaxPageBytes = 512 << 10
+
+// Инструкция модели для подбора источников
+const citationsInstruction = `Suggest up to %d reliable sources that support the key claims of the markdown document below: official documentation, standards, papers or well-known reference sites. Use only real URLs you are confident exist, never invent them. Reply with a single JSON object {"citations": [{"title": "page title", "url": "https://...", "claim": "short statement from the document it supports"}]} and nothing else.`
+
+// Заголовок страницы: <title> и og:title
+var (
+	pageTitlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	pageOGTitlePattern = regexp.MustCompile(`(?is)<meta[^>]+property=["']og:title["'][^>]+content=["']([^"']*)["']`)
+)
+
+// Источники из секции [CITATIONS]
+type CitationConfig struct {
+	Enabled bool
+	// Максимум источников на документ
+	MaxItems int
+	// drop или flag
+	Action string
+	// Заголовок раздела источников
+	Heading string
+	// Доля слов предложенного заголовка, которые должны встретиться в заголовке страницы
+	MinTitleMatch float64
+	// Время ожидания проверки одного адреса
+	Timeout time.Duration
+}
+
+// Чтение секции [CITATIONS]
+func loadCitationConfig(section *ini.Section) (CitationConfig, error) {
+	citations := CitationConfig{
+		Enabled:       section.Key("enabled").MustBool(false),
+		MaxItems:      section.Key("max_items").MustInt(5),
+		Action:        strings.ToLower(section.Key("unverified").MustString(CitationsDrop)),
+		Heading:       section.Key("heading").MustString("Источники"),
+		MinTitleMatch: section.Key("min_title_match").MustFloat64(0.5),
+		Timeout:       section.Key("timeout").MustDuration(10 * time.Second),
+	}
+	if citations.Action != CitationsDrop && citations.Action != CitationsFlag {
+		return citations, errorf("неизвестное значение unverified %q в секции [CITATIONS]: ожидалось drop или flag", citations.Action)
+	}
+	if citations.MaxItems <= 0 {
+		return citations, errorf("max_items в секции [CITATIONS] должен быть положительным: %d", citations.MaxItems)
+	}
+	if citations.MinTitleMatch < 0 || citations.MinTitleMatch > 1 {
+		return citations, errorf("некорректное значение min_title_match %g: ожидалось от 0 до 1", citations.MinTitleMatch)
+	}
+	return citations, nil
+}
+
+// Источник, предложенный моделью, и итог его проверки
+type citation struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Claim    string `json:"claim,omitempty"`
+	Verified bool   `json:"verified"`
+	// Причина, по которой источник не подтвержден
+	Reason string `json:"reason,omitempty"`
+}
+
+// Запрос источников для обогащенного документа
+func suggestCitations(config *Config, doc string, limiter *RateLimiter) ([]citation, Usage, error) {
+	citationsConfig := *config
+	citationsConfig.Prompt = fmt.Sprintf(citationsInstruction, config.Citations.MaxItems)
+	// Примеры и правила ответа относятся к обогащению, а не к источникам
+	citationsConfig.Examples = nil
+	citationsConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&citationsConfig, stripCitations(doc), limiter)
+	if err != nil {
+		return nil, usage, err
+	}
+	citations, err := parseCitations(response, config.Citations.MaxItems)
+	return citations, usage, err
+}
+
+// Разбор ответа модели: JSON объект с источниками, возможно окруженный текстом.
+// Источники без заголовка или с адресом не http(s) и повторы отбрасываются
+func parseCitations(response string, maxItems int) ([]citation, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, errorf("в ответе модели нет источников: %q", snippet(response, searchSnippetRunes))
+	}
+	var data struct {
+		Citations []citation `json:"citations"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &data); err != nil {
+		return nil, errorf("некорректный ответ модели с источниками: %v", err)
+	}
+	var citations []citation
+	seen := make(map[string]bool)
+	for _, c := range data.Citations {
+		c.Title, c.URL, c.Claim = strings.TrimSpace(c.Title), strings.TrimSpace(c.URL), strings.TrimSpace(c.Claim)
+		c.Verified, c.Reason = false, ""
+		u, err := url.Parse(c.URL)
+		if c.Title == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || seen[c.URL] {
+			continue
+		}
+		seen[c.URL] = true
+		if len(citations) < maxItems {
+			citations = append(citations, c)
+		}
+	}
+	return citations, nil
+}
+
+// Проверка источников: адрес отвечает HTTP 200, а заголовок страницы похож на
+// предложенный. Результаты проверки адресов запоминаются на время запуска
+type citationVerifier struct {
+	client   *http.Client
+	minMatch float64
+	mu       sync.Mutex
+	cache    map[string]string
+}
+
+// Создание проверки источников
+func newCitationVerifier(config *Config) *citationVerifier {
+	return &citationVerifier{
+		client:   config.Network.client(config.Citations.Timeout),
+		minMatch: config.Citations.MinTitleMatch,
+		cache:    make(map[string]string),
+	}
+}
+
+// Проверка источников; заполняет Verified и Reason
+func (v *citationVerifier) Verify(citations []citation) {
+	for i := range citations {
+		key := citations[i].URL + "\x00" + citations[i].Title
+		v.mu.Lock()
+		reason, ok := v.cache[key]
+		v.mu.Unlock()
+		if !ok {
+			reason = v.check(citations[i])
+			v.mu.Lock()
+			v.cache[key] = reason
+			v.mu.Unlock()
+		}
+		citations[i].Verified, citations[i].Reason = reason == "", reason
+	}
+}
+
+// Причина, по которой источник не подтвержден ("" - источник подтвержден)
+func (v *citationVerifier) check(c citation) string {
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return trf("ошибка запроса: %v", err)
+	}
+	setUserAgent(req)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return trf("ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	// Заголовок проверяется только у HTML страниц (у PDF и других документов его нет)
+	if !strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		return ""
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, citationMaxPageBytes))
+	if err != nil {
+		return trf("ошибка запроса: %v", err)
+	}
+	title := pageTitle(string(page))
+	if title == "" {
+		return ""
+	}
+	if match := titleMatch(c.Title, title); match < v.minMatch {
+		return trf("заголовок страницы %q не похож на %q", snippet(title, searchSnippetRunes), c.Title)
+	}
+	return ""
+}
+
+// Заголовок HTML страницы (<title> и og:title)
+func pageTitle(page string) string {
+	var parts []string
+	for _, re := range []*regexp.Regexp{pageTitlePattern, pageOGTitlePattern} {
+		if m := re.FindStringSubmatch(page); m != nil {
+			if t := strings.Join(strings.Fields(html.UnescapeString(m[1])), " "); t != "" {
+				parts = append(parts, t)
+			}
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Доля слов предложенного заголовка, встречающихся в заголовке страницы
+func titleMatch(suggested, page string) float64 {
+	words := tokenize(suggested)
+	if len(words) == 0 {
+		return 0
+	}
+	pageWords := make(map[string]bool)
+	for _, w := range tokenize(page) {
+		pageWords[w] = true
+	}
+	found := 0
+	for _, w := range words {
+		if pageWords[w] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(words))
+}
+
+// Раздел источников: подтвержденные источники и, при unverified = flag,
+// неподтвержденные с маркером
+func renderCitations(cc CitationConfig, citations []citation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", cc.Heading)
+	for _, c := range citations {
+		if !c.Verified && cc.Action != CitationsFlag {
+			continue
+		}
+		fmt.Fprintf(&b, "- [%s](%s)", escapeLinkText(c.Title), escapeLinkPath(c.URL))
+		if !c.Verified {
+			b.WriteString(" " + UnverifiedCitationMarker)
+		}
+		if c.Claim != "" {
+			b.WriteString(" - " + c.Claim)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Источники, которые попадут в документ
+func (cc CitationConfig) shown(citations []citation) int {
+	n := 0
+	for _, c := range citations {
+		if c.Verified || cc.Action == CitationsFlag {
+			n++
+		}
+	}
+	return n
+}
+
+// Удаление ранее добавленного раздела источников
+func stripCitations(text string) string {
+	start := strings.LastIndex(text, CitationsStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], CitationsEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(CitationsEndMarker)
+	return strings.TrimRight(text[:start], "\n") + text[end:]
+}
+
+// Добавление раздела источников в конец документа (с заменой предыдущего)
+func withCitations(text, section string) string {
+	text = strings.TrimRight(stripCitations(text), "\n")
+	return text + "\n\n" + CitationsStartMarker + "\n" + strings.TrimSpace(section) + "\n" + CitationsEndMarker + "\n"
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCitations(t *testing.T) {
+	response := "Вот источники:\n```json\n" + `{"citations": [
+		{"title": "Kafka Documentation", "url": "https://kafka.apache.org/documentation/", "claim": "Брокер хранит партиции"},
+		{"title": "Повтор", "url": "https://kafka.apache.org/documentation/"},
+		{"title": "", "url": "https://example.com"},
+		{"title": "Локальный файл", "url": "file:///etc/passwd"},
+		{"title": "Wikipedia", "url": "https://en.wikipedia.org/wiki/Apache_Kafka"}
+	]}` + "\n```"
+	citations, err := parseCitations(response, 5)
+	if err != nil {
+		t.Fatalf("parseCitations() вернул ошибку: %v", err)
+	}
+	if len(citations) != 2 || citations[0].Claim != "Брокер хранит партиции" || citations[1].Title != "Wikipedia" {
+		t.Errorf("источники: %+v", citations)
+	}
+	if _, err := parseCitations("Источников нет", 5); err == nil {
+		t.Error("ожидалась ошибка для ответа без JSON")
+	}
+}
+
+func TestCitationVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kafka":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html><head><title>Apache Kafka &amp; Documentation</title></head></html>"))
+		case "/paper.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4"))
+		case "/other":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<title>Рецепты пирогов</title>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	v := newCitationVerifier(&Config{Citations: CitationConfig{MinTitleMatch: 0.5}})
+	citations := []citation{
+		{Title: "Kafka Documentation", URL: server.URL + "/kafka"},
+		{Title: "Статья", URL: server.URL + "/paper.pdf"},
+		{Title: "Kafka Streams Guide", URL: server.URL + "/other"},
+		{Title: "Missing", URL: server.URL + "/missing"},
+	}
+	v.Verify(citations)
+	for i, want := range []bool{true, true, false, false} {
+		if citations[i].Verified != want {
+			t.Errorf("источник %s: verified = %v (%s), ожидалось %v", citations[i].URL, citations[i].Verified, citations[i].Reason, want)
+		}
+	}
+	if citations[3].Reason != "HTTP 404" {
+		t.Errorf("причина для отсутствующей страницы: %q", citations[3].Reason)
+	}
+
+	cc := CitationConfig{Heading: "Источники", Action: CitationsFlag}
+	md := renderCitations(cc, citations[2:3])
+	if !strings.Contains(md, "- [Kafka Streams Guide]("+server.URL+"/other) "+UnverifiedCitationMarker+"\n") {
+		t.Errorf("неподтвержденный источник не помечен:\n%s", md)
+	}
+	cc.Action = CitationsDrop
+	if cc.shown(citations[2:]) != 0 || strings.Contains(renderCitations(cc, citations), "/other") {
+		t.Error("неподтвержденный источник не должен добавляться при unverified = drop")
+	}
+}
+
+func TestCitationsInserted(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "kafka.md"), []byte("# Kafka\n\nБрокер хранит партиции."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<title>Kafka Documentation</title>"))
+			return
+		case "/v1/chat/completions":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		content := "# Kafka\\n\\nОбогащенный текст"
+		if strings.Contains(string(body), "reliable sources") {
+			base := "http://" + r.Host
+			content = `{\"citations\": [{\"title\": \"Kafka Documentation\", \"url\": \"` + base + `/docs\", \"claim\": \"Партиции\"}, {\"title\": \"Выдуманная статья\", \"url\": \"` + base + `/fake\"}]}`
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Citations: CitationConfig{Enabled: true, MaxItems: 5, Action: CitationsDrop, Heading: "Источники", MinTitleMatch: 0.5}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "kafka.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CitationsStartMarker + "\n## Источники\n\n- [Kafka Documentation](" + server.URL + "/docs) - Партиции\n" + CitationsEndMarker
+	if !strings.Contains(string(data), want) || strings.Contains(string(data), "/fake") {
+		t.Errorf("раздел источников:\n%s", data)
+	}
+}
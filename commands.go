@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Подкоманды CLI; без подкоманды выполняется обработка директории
+var commands = map[string]func(args []string, out io.Writer) error{
+	"status":   runStatusCommand,
+	"stats":    runStatsCommand,
+	"undo":     runUndoCommand,
+	"models":   runModelsCommand,
+	"doctor":   runDoctorCommand,
+	"sweep":    runSweepCommand,
+	"reenrich": runReenrichCommand,
+	"orphans":  runOrphansCommand,
+	"state":    runStateCommand,
+	"search":   runSearchCommand,
+	"ask":      runAskCommand,
+	"export":   runExportCommand,
+	"service":  runServiceCommand,
+	"db":       runDBCommand,
+	"label":    runLabelCommand,
+	"compile":  runCompileCommand,
+	"rollup":   runRollupCommand,
+	"split":    runSplitCommand,
+	"merge":    runMergeCommand,
+	"approve":  runApproveCommand,
+	"section":  runSectionCommand,
+	"verify":   runVerifyCommand,
+	"init":     runInitCommand,
+	"preview":  runPreviewCommand,
+	"secret":   runSecretCommand,
+	"config":   runConfigCommand,
+	"version":  runVersionCommand,
+}
+
+// rich export <формат>: выгрузка результатов в другом формате
+func runExportCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "site":
+			return runExportSiteCommand(args[1:], out)
+		case "jsonl":
+			return runExportJSONLCommand(args[1:], out)
+		}
+	}
+	return errorf("укажите формат экспорта: rich export site или rich export jsonl")
+}
+
+// Загрузка конфигурации подкоманды и проверка каталога состояния
+func loadCommandConfig(configPath string) (*Config, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if config.StateDir == "" {
+		return nil, errorf("каталог состояния не задан (секция [STATE], ключ dir)")
+	}
+	return config, nil
+}
+
+// rich status [--run <id>] [--stale-prompt]: список запусков, подробности одного запуска
+// или результаты, полученные с устаревшей версией промпта
+func runStatusCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	runID := fs.String("run", "", tr("Идентификатор запуска"))
+	stalePrompt := fs.Bool("stale-prompt", false, tr("Показать результаты, полученные с устаревшей версией промпта"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *stalePrompt {
+		stale, err := stalePromptOutputs(config)
+		if err != nil {
+			return errorf("не удалось проверить версии промпта: %v", err)
+		}
+		if len(stale) == 0 {
+			fmt.Fprintln(out, tr("Все результаты получены с текущей версией промпта"))
+			return nil
+		}
+		for _, s := range stale {
+			fmt.Fprintf(out, tr("%s -> %s: промпт %s, текущий %s (запуск %s, модель %s)\n"),
+				s.Entry.Input, s.Entry.Output, s.Entry.PromptHash, s.CurrentPromptHash, s.RunID, s.Entry.Model)
+		}
+		fmt.Fprintf(out, tr("Устаревших результатов: %d\n"), len(stale))
+		return nil
+	}
+
+	if *runID == "" {
+		runs, err := listRuns(config.StateDir)
+		if err != nil {
+			return errorf("не удалось прочитать список запусков: %v", err)
+		}
+		if len(runs) == 0 {
+			fmt.Fprintln(out, tr("Запусков не найдено"))
+			return nil
+		}
+		for _, j := range runs {
+			fmt.Fprintln(out, runSummary(j))
+		}
+		return nil
+	}
+
+	j, err := loadRun(config.StateDir, *runID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, runSummary(j))
+	for _, e := range j.Entries {
+		line := fmt.Sprintf("  %-20s %s", e.Status, e.Input)
+		if e.Output != "" {
+			line += " -> " + e.Output
+		}
+		if e.Undone {
+			line += tr(" (отменено)")
+		}
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// Краткое описание запуска в одну строку
+func runSummary(j *runJournal) string {
+	enriched, skipped, failed := j.Counts()
+	state := tr("не завершен")
+	if j.FinishedAt != nil {
+		state = trf("завершен %s", j.FinishedAt.Format(time.DateTime))
+	}
+	if j.UndoneAt != nil {
+		state += trf(", отменен %s", j.UndoneAt.Format(time.DateTime))
+	}
+	if j.Operator != "" {
+		state += trf(", оператор %s", j.Operator)
+	}
+	return trf("%s  начат %s, %s; обогащено %d, пропущено %d, ошибок %d",
+		j.ID, j.StartedAt.Format(time.DateTime), state, enriched, skipped, failed)
+}
+
+// rich undo --run <id> [--force]: отмена изменений одного запуска
+func runUndoCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	runID := fs.String("run", "", tr("Идентификатор отменяемого запуска"))
+	force := fs.Bool("force", false, tr("Отменять изменения файлов, измененных после запуска"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runID == "" {
+		return errorf("не указан идентификатор запуска (--run)")
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	j, err := loadRun(config.StateDir, *runID)
+	if err != nil {
+		return err
+	}
+
+	restored, skipped, err := undoRun(j, *configPath, *force)
+	fmt.Fprintf(out, tr("Запуск %s: отменены изменения %d файлов\n"), j.ID, restored)
+	for _, path := range skipped {
+		fmt.Fprintf(out, tr("  пропущен %s: выходной файл изменен после запуска (используйте --force)\n"), path)
+	}
+	return err
+}
+
+// Выполнение подкоманды, если она указана первым аргументом
+func runCommand(args []string) (handled bool) {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := cmd(args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, tr("Ошибка: %v\n"), err)
+		os.Exit(1)
+	}
+	return true
+}
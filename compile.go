@@ -0,0 +1,387 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Манифест сборки: директория с этим файлом собирается в один документ
+const CompileManifestFileName = ".rich-compile.md"
+
+// Маркеры списка исходных заметок собранного документа; в начальном маркере
+// хранится хэш источников для пропуска неизмененных сборок
+const (
+	CompiledStartMarker = "<!-- rich:compiled"
+	CompiledEndMarker   = "<!-- rich:compiled-end -->"
+)
+
+// Инструкция модели для сборки заметок в один документ
+const compileInstruction = `Below are several markdown notes from one folder, each preceded by a comment with its file name, in the order they should be read. Synthesize them into one coherent document: merge repeated information, keep every decision, figure, date, name and open question, resolve the order of events, group related topics under headings and remove chatter that carries no information. Do not invent facts that are not in the notes. Write in the language of the notes and reply with the document only.`
+
+// Пункт списка манифеста: "- путь", "1. путь", [[заметка]] или [текст](путь)
+var (
+	compileListItemPattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(.+?)\s*$`)
+	compileWikiPattern     = regexp.MustCompile(`^\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]$`)
+	compileLinkPattern     = regexp.MustCompile(`^\[[^\]]*\]\(\s*<?([^)>]+?)>?\s*\)$`)
+)
+
+// Манифест сборки директории
+type compileManifest struct {
+	// Директория относительно входной директории
+	RelDir string
+	// Заголовок собранного документа (поле title)
+	Title string
+	// Путь результата относительно выходной директории (поле output)
+	Output string
+	// Заметки в порядке манифеста (относительно входной директории); пустой
+	// список - все заметки директории по алфавиту
+	Files []string
+	// Дополнительные указания модели (текст манифеста вне списка)
+	Instructions string
+}
+
+// Чтение манифеста сборки директории
+func loadCompileManifest(inputDir, relDir string) (*compileManifest, error) {
+	m := &compileManifest{RelDir: filepath.Clean(relDir)}
+	data, err := os.ReadFile(filepath.Join(inputDir, m.RelDir, CompileManifestFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errorf("не удалось прочитать манифест сборки %s: %v", m.RelDir, err)
+	}
+	fm, body, _ := parseFrontmatter(data)
+	m.Title = strings.TrimSpace(fm["title"])
+	m.Output = strings.TrimSpace(fm["output"])
+	if m.Output == "" {
+		if m.RelDir == "." {
+			return nil, errorf("для сборки корня входной директории укажите output в %s", CompileManifestFileName)
+		}
+		m.Output = m.RelDir + ".md"
+	}
+	if !isRelPathSafe(m.Output) || !isMarkdownPath(m.Output) {
+		return nil, errorf("output манифеста сборки %s должен быть путем к .md внутри выходной директории: %s", m.RelDir, m.Output)
+	}
+
+	var instructions []string
+	for _, line := range strings.Split(string(body), "\n") {
+		match := compileListItemPattern.FindStringSubmatch(line)
+		if match == nil {
+			instructions = append(instructions, line)
+			continue
+		}
+		ref := match[1]
+		if w := compileWikiPattern.FindStringSubmatch(ref); w != nil {
+			ref = strings.TrimSpace(w[1])
+			if filepath.Ext(ref) == "" {
+				ref += ".md"
+			}
+		} else if l := compileLinkPattern.FindStringSubmatch(ref); l != nil {
+			ref = l[1]
+		}
+		if !isMarkdownPath(ref) {
+			// Пункт списка без пути к заметке - часть указаний
+			instructions = append(instructions, line)
+			continue
+		}
+		rel := filepath.Join(m.RelDir, filepath.FromSlash(ref))
+		if !isRelPathSafe(rel) {
+			return nil, errorf("заметка %s манифеста сборки %s вне входной директории", ref, m.RelDir)
+		}
+		m.Files = append(m.Files, rel)
+	}
+	m.Instructions = strings.TrimSpace(strings.Join(instructions, "\n"))
+	return m, nil
+}
+
+// Заметки сборки: из манифеста или все markdown файлы директории и поддиректорий
+func (m *compileManifest) sources(inputDir string) ([]string, error) {
+	if len(m.Files) > 0 {
+		for _, rel := range m.Files {
+			if _, err := os.Stat(filepath.Join(inputDir, rel)); err != nil {
+				return nil, errorf("заметка %s из манифеста сборки %s не найдена", normalizeRelPath(rel), m.RelDir)
+			}
+		}
+		return m.Files, nil
+	}
+	var files []string
+	root := filepath.Join(inputDir, m.RelDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownPath(d.Name()) || d.Name() == CompileManifestFileName || d.Name() == DirPromptFileName {
+			return nil
+		}
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при обходе директории сборки %s: %v", m.RelDir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Директории с манифестом сборки во входной директории
+func findCompilations(inputDir string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != inputDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == CompileManifestFileName {
+			rel, err := filepath.Rel(inputDir, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при поиске манифестов сборки: %v", err)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// Итог сборки директории
+type compileResult struct {
+	Output  string
+	Sources []string
+	// Заметки, не отправленные в модель по политике содержимого
+	Blocked   []string
+	Unchanged bool
+	Usage     Usage
+}
+
+// Сборка заметок директории в один документ
+func compileDirectory(config *Config, m *compileManifest, force bool, limiter *RateLimiter) (*compileResult, error) {
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+	outputPath := filepath.Join(config.OutputDir, m.Output)
+	result := &compileResult{Output: outputPath}
+	files, err := m.sources(inputDir)
+	if err != nil {
+		return result, err
+	}
+
+	// Заметки с комментарием-именем файла в порядке манифеста
+	var notes strings.Builder
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(inputDir, rel))
+		if err != nil {
+			return result, errorf("ошибка при чтении файла: %w", err)
+		}
+		if matches := config.Policy.Check(data); blockedByPolicy(matches) {
+			warnf("Предупреждение: заметка %s не включена в сборку %s по политике содержимого", normalizeRelPath(rel), m.RelDir)
+			result.Blocked = append(result.Blocked, normalizeRelPath(rel))
+			continue
+		}
+		_, body, _ := parseFrontmatter(data)
+		fmt.Fprintf(&notes, "<!-- file: %s -->\n%s\n\n", normalizeRelPath(rel), strings.TrimSpace(string(body)))
+		result.Sources = append(result.Sources, normalizeRelPath(rel))
+	}
+	if len(result.Sources) == 0 {
+		return result, withCategory(ErrorValidation, errorf("в директории сборки %s нет заметок", m.RelDir))
+	}
+
+	compileConfig := *config
+	compileConfig.Prompt = compileInstruction
+	if m.Title != "" {
+		compileConfig.Prompt += fmt.Sprintf("\n\nThe document title is %q.", m.Title)
+	}
+	if m.Instructions != "" {
+		compileConfig.Prompt += "\n\n" + m.Instructions
+	}
+	// Примеры и правила ответа относятся к обогащению отдельных заметок
+	compileConfig.Examples = nil
+	compileConfig.OutputRules = outputRules{}
+
+	// Сборка повторяется только при изменении заметок, манифеста или модели
+	hash := contentHash([]byte(compileConfig.Prompt + "\x00" + compileConfig.ModelName + "\x00" + notes.String()))[:16]
+	if !force {
+		if previous, err := os.ReadFile(outputPath); err == nil && compiledHash(string(previous)) == hash {
+			result.Unchanged = true
+			return result, nil
+		}
+	}
+
+	doc, usage, err := enrichContentWithUsage(&compileConfig, strings.TrimSpace(notes.String()), limiter)
+	result.Usage = usage
+	if err != nil {
+		return result, err
+	}
+	doc = strings.TrimSpace(postProcess(config, doc))
+	if m.Title != "" && !strings.HasPrefix(doc, "# ") {
+		doc = "# " + m.Title + "\n\n" + doc
+	}
+	doc = withCompiledSources(doc, hash, renderCompiledSources(inputDir, outputPath, result.Sources))
+	if config.Disclosure {
+		doc = withDisclosure(doc, renderDisclosure(config.DisclosureTemplate, disclosureInfo{
+			Model:  compileConfig.ModelName,
+			Date:   time.Now(),
+			Prompt: compileConfig.Prompt,
+		}))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return result, withCategory(ErrorIO, errorf("ошибка при создании директории: %v", err))
+	}
+	if err := safeWriteFile(outputPath, []byte(doc), 0644); err != nil {
+		return result, withCategory(ErrorIO, err)
+	}
+	return result, nil
+}
+
+// Сработало ли правило политики, запрещающее отправку
+func blockedByPolicy(matches []policyMatch) bool {
+	for _, m := range matches {
+		if m.Action == PolicyBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// Список исходных заметок со ссылками относительно собранного документа
+func renderCompiledSources(inputDir, outputPath string, sources []string) string {
+	var b strings.Builder
+	b.WriteString(tr("Собрано из заметок:"))
+	b.WriteString("\n")
+	for _, rel := range sources {
+		fmt.Fprintf(&b, "\n- %s", relativeLink(outputPath, filepath.Join(inputDir, filepath.FromSlash(rel))))
+	}
+	return b.String()
+}
+
+// Добавление списка исходных заметок в конец документа
+func withCompiledSources(doc, hash, sources string) string {
+	return strings.TrimRight(doc, "\n") + "\n\n" + CompiledStartMarker + " " + hash + " -->\n" + sources + "\n" + CompiledEndMarker + "\n"
+}
+
+// Хэш источников собранного документа ("" - документ собран не Rich)
+func compiledHash(doc string) string {
+	start := strings.LastIndex(doc, CompiledStartMarker+" ")
+	if start < 0 {
+		return ""
+	}
+	rest := doc[start+len(CompiledStartMarker)+1:]
+	end := strings.Index(rest, " -->")
+	if end < 0 {
+		return ""
+	}
+	return rest[:end]
+}
+
+// rich compile [--force] [--dry-run] [директория...]: сборка заметок директорий
+// с манифестом .rich-compile.md (или указанных директорий) в один документ
+func runCompileCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("compile", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	force := fs.Bool("force", false, tr("Собрать заново, даже если заметки не изменились"))
+	dryRun := fs.Bool("dry-run", false, tr("Только показать заметки сборок в порядке манифеста"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		if dirs, err = findCompilations(inputDir); err != nil {
+			return err
+		}
+		if len(dirs) == 0 {
+			fmt.Fprintf(out, tr("Во входной директории нет манифестов сборки %s\n"), CompileManifestFileName)
+			return nil
+		}
+	}
+	var manifests []*compileManifest
+	for _, dir := range dirs {
+		rel := dir
+		if filepath.IsAbs(dir) {
+			if rel, err = filepath.Rel(inputDir, dir); err != nil {
+				return errorf("директория %s вне входной директории", dir)
+			}
+		}
+		if info, err := os.Stat(filepath.Join(inputDir, rel)); err != nil || !info.IsDir() || !isRelPathSafe(rel) {
+			return errorf("директория %s не найдена во входной директории", dir)
+		}
+		m, err := loadCompileManifest(inputDir, rel)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, m)
+	}
+
+	if *dryRun {
+		for _, m := range manifests {
+			files, err := m.sources(inputDir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, tr("%s -> %s (заметок: %d)\n"), normalizeRelPath(m.RelDir), normalizeRelPath(m.Output), len(files))
+			for _, f := range files {
+				fmt.Fprintf(out, "  %s\n", normalizeRelPath(f))
+			}
+		}
+		return nil
+	}
+
+	limiter := config.newRateLimiter()
+	var failed int
+	var spent float64
+	for _, m := range manifests {
+		result, err := compileDirectory(config, m, *force, limiter)
+		if result != nil {
+			spent += result.Usage.Cost(config)
+		}
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, tr("%s: ошибка сборки: %v\n"), normalizeRelPath(m.RelDir), err)
+		case result.Unchanged:
+			fmt.Fprintf(out, tr("%s: заметки не изменились, сборка пропущена\n"), normalizeRelPath(m.RelDir))
+		default:
+			fmt.Fprintf(out, tr("%s -> %s: собрано заметок %d\n"), normalizeRelPath(m.RelDir), result.Output, len(result.Sources))
+		}
+	}
+	if spent > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), spent)
+	}
+	if failed > 0 {
+		return errorf("не удалось собрать директорий: %d", failed)
+	}
+	return nil
+}
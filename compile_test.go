@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoadCompileManifest(t *testing.T) {
+	inputDir := t.TempDir()
+	dir := filepath.Join(inputDir, "meetings", "q3")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "---\ntitle: Отчет за квартал\n---\nСделай акцент на решениях.\n\n1. [[kickoff]]\n2. [Ретро](retro%20final.md)\n- sync.md\n- не заметка\n"
+	if err := os.WriteFile(filepath.Join(dir, CompileManifestFileName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadCompileManifest(inputDir, filepath.Join("meetings", "q3"))
+	if err != nil {
+		t.Fatalf("loadCompileManifest() вернул ошибку: %v", err)
+	}
+	want := []string{"meetings/q3/kickoff.md", "meetings/q3/retro%20final.md", "meetings/q3/sync.md"}
+	var got []string
+	for _, f := range m.Files {
+		got = append(got, normalizeRelPath(f))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("заметки манифеста: %q, ожидалось %q", got, want)
+	}
+	if m.Title != "Отчет за квартал" || normalizeRelPath(m.Output) != "meetings/q3.md" {
+		t.Errorf("заголовок %q, результат %q", m.Title, m.Output)
+	}
+	if m.Instructions != "Сделай акцент на решениях.\n\n- не заметка" {
+		t.Errorf("указания манифеста: %q", m.Instructions)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, CompileManifestFileName), []byte("- ../../../secret.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCompileManifest(inputDir, filepath.Join("meetings", "q3")); err == nil {
+		t.Error("ожидалась ошибка для заметки вне входной директории")
+	}
+	if _, err := loadCompileManifest(inputDir, "."); err == nil {
+		t.Error("ожидалась ошибка для сборки корня без output")
+	}
+}
+
+func TestCompileCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	dir := filepath.Join(inputDir, "meetings")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		CompileManifestFileName: "---\ntitle: Итоги встреч\n---\n- b.md\n- a.md\n",
+		"a.md":                  "---\ndate: 2024-07-02\n---\nРешили перейти на Postgres.\n",
+		"b.md":                  "Созвон по миграции: сроки до августа.\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		b := strings.Index(string(body), "file: meetings/b.md")
+		a := strings.Index(string(body), "file: meetings/a.md")
+		if b < 0 || a < b || strings.Contains(string(body), "date: 2024") || !strings.Contains(string(body), "Итоги встреч") {
+			t.Errorf("заметки отправлены не в порядке манифеста: %s", body)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "## Миграция\n\nПереход на Postgres до августа."}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runCompileCommand([]string{"--config", configPath, "--dry-run"}, &out); err != nil {
+		t.Fatalf("rich compile --dry-run вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "meetings/b.md\n  meetings/a.md") || requests.Load() != 0 {
+		t.Errorf("вывод --dry-run:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runCompileCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("rich compile вернул ошибку: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "meetings.md"))
+	if err != nil {
+		t.Fatalf("собранный документ не создан: %v\n%s", err, out.String())
+	}
+	doc := string(data)
+	if !strings.HasPrefix(doc, "# Итоги встреч\n\n## Миграция") || !strings.Contains(doc, "- [b.md](../input/meetings/b.md)\n- [a.md](../input/meetings/a.md)\n"+CompiledEndMarker) {
+		t.Errorf("собранный документ:\n%s", doc)
+	}
+
+	// Повторная сборка без изменений заметок не выполняет запрос
+	out.Reset()
+	if err := runCompileCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("повторный rich compile вернул ошибку: %v", err)
+	}
+	if requests.Load() != 1 || !strings.Contains(out.String(), "сборка пропущена") {
+		t.Errorf("запросов %d, вывод:\n%s", requests.Load(), out.String())
+	}
+	if err := runCompileCommand([]string{"--config", configPath, "--force", "meetings"}, &out); err != nil {
+		t.Fatalf("rich compile --force вернул ошибку: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("--force не выполнил сборку заново: запросов %d", requests.Load())
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Значения compress_requests секции [NETWORK]
+const (
+	// Тело запроса сжимается, если провайдер принимает сжатые запросы (по умолчанию)
+	CompressAuto = "auto"
+	// Тело запроса сжимается для любого API (прокси и шлюзы, которые распаковывают запросы)
+	CompressOn = "on"
+	// Тело запроса не сжимается
+	CompressOff = "off"
+)
+
+// Тела запросов меньше этого размера не сжимаются: выигрыш меньше затрат
+const compressMinBytes = 1024
+
+// Сжатия ответа, которые rich запрашивает и распаковывает сам
+const acceptEncoding = "gzip, deflate"
+
+// Проверка значения compress_requests
+func validateCompressRequests(mode string) error {
+	switch mode {
+	case CompressAuto, CompressOn, CompressOff:
+		return nil
+	}
+	return errorf("некорректное значение compress_requests %q: ожидалось auto, on или off", mode)
+}
+
+// Сжатие тела запроса к API провайдера
+func (n networkConfig) compressRequests(p *provider) bool {
+	switch n.CompressRequests {
+	case CompressOn:
+		return true
+	case CompressOff:
+		return false
+	}
+	return p != nil && p.GzipRequests
+}
+
+// Тело запроса, сжатое gzip
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Распакованное тело ответа вместе с исходным телом для закрытия
+type decodedBody struct {
+	io.Reader
+	raw io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	if c, ok := b.Reader.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return b.raw.Close()
+}
+
+// Распаковка тела ответа по Content-Encoding: gzip и deflate (zlib или без заголовка
+// zlib, как отвечают некоторые серверы). Ответ, который уже распаковал HTTP клиент,
+// не меняется; после распаковки заголовок Content-Encoding удаляется, поэтому
+// повторный вызов ничего не делает
+func decodeResponseBody(resp *http.Response) error {
+	if resp.Uncompressed {
+		return nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var reader io.Reader
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return withCategory(ErrorProvider, errorf("ошибка распаковки ответа API (%s): %v", encoding, err))
+		}
+		reader = zr
+	case "deflate":
+		br := bufio.NewReader(resp.Body)
+		// Заголовок zlib: метод сжатия 8 и контрольная сумма двух байтов, кратная 31
+		if head, err := br.Peek(2); err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return withCategory(ErrorProvider, errorf("ошибка распаковки ответа API (%s): %v", encoding, err))
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(br)
+		}
+	default:
+		return withCategory(ErrorProvider, errorf("неподдерживаемое сжатие ответа API: %s", encoding))
+	}
+	resp.Body = &decodedBody{Reader: reader, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
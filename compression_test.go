@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponseBody(t *testing.T) {
+	const text = `{"ok": true}`
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw":     func(w io.Writer) io.WriteCloser { zw, _ := flate.NewWriter(w, flate.DefaultCompression); return zw },
+	}
+	for name, newWriter := range compress {
+		var buf bytes.Buffer
+		zw := newWriter(&buf)
+		_, _ = zw.Write([]byte(text))
+		_ = zw.Close()
+		encoding := name
+		if name == "raw" {
+			encoding = "deflate"
+		}
+		resp := &http.Response{Header: http.Header{"Content-Encoding": {encoding}}, Body: io.NopCloser(&buf)}
+		body, err := readResponseBody(resp, 1024)
+		if err != nil || string(body) != text {
+			t.Errorf("%s: readResponseBody() = %q, %v", name, body, err)
+		}
+		// Повторная распаковка не выполняется
+		if err := decodeResponseBody(resp); err != nil || resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%s: повторный decodeResponseBody() = %v", name, err)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"br"}}, Body: io.NopCloser(strings.NewReader("x"))}
+	if err := decodeResponseBody(resp); err == nil || errorCategory(err) != ErrorProvider {
+		t.Errorf("ожидалась ошибка для неподдерживаемого сжатия: %v", err)
+	}
+}
+
+func TestCompressedModelRequest(t *testing.T) {
+	var gotEncoding, gotAccept, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding, gotAccept = r.Header.Get("Content-Encoding"), r.Header.Get("Accept-Encoding")
+		body := io.Reader(r.Body)
+		if gotEncoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		gotBody = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Обогащено"}}]}`))
+		_ = zw.Close()
+	}))
+	defer server.Close()
+
+	note := strings.Repeat("Длинная заметка о миграции. ", 100)
+	for _, mode := range []string{CompressOn, CompressAuto} {
+		config := &Config{ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+			Network: networkConfig{CompressRequests: mode}}
+		text, _, err := requestModel(config, note, nil, NewRateLimiter(0))
+		if err != nil || text != "Обогащено" {
+			t.Fatalf("%s: requestModel() = %q, %v", mode, text, err)
+		}
+		// openai-compatible не объявляет поддержку сжатых запросов
+		wantEncoding := map[string]string{CompressOn: "gzip", CompressAuto: ""}[mode]
+		if gotEncoding != wantEncoding || gotAccept != acceptEncoding || !strings.Contains(gotBody, "Длинная заметка") {
+			t.Errorf("%s: Content-Encoding %q, Accept-Encoding %q, тело %d байт", mode, gotEncoding, gotAccept, len(gotBody))
+		}
+	}
+}
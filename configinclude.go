@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Ключ в начале файла конфигурации со списком базовых конфигураций через запятую:
+// include = base.cfg, models.cfg (пути относительно включающего файла)
+const configIncludeKey = "include"
+
+// Файлы конфигурации в порядке применения: включенные файлы (с их собственными
+// включениями) раньше включающего, каждый следующий переопределяет предыдущие
+func configIncludeChain(configPath string) ([]string, error) {
+	var chain, seen []string
+	var visit func(path string, stack []string) error
+	visit = func(path string, stack []string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(stack, abs) {
+			return errorf("циклическое включение конфигурации: %s", strings.Join(append(stack, abs), " -> "))
+		}
+		if slices.Contains(seen, abs) {
+			return nil
+		}
+		cfg, err := loadConfigFile(path, false)
+		if err != nil {
+			return errorf("не удалось загрузить файл конфигурации %s: %v", path, err)
+		}
+		for _, include := range splitList(cfg.Section(ini.DefaultSection).Key(configIncludeKey).String()) {
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			if err := visit(include, append(stack, abs)); err != nil {
+				return err
+			}
+		}
+		chain, seen = append(chain, path), append(seen, abs)
+		return nil
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err := visit(configPath, nil); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// Загрузка конфигурации с включениями. Порядок приоритета (от меньшего к большему):
+// включенные файлы, файл конфигурации, переменные RICH_<СЕКЦИЯ>_<КЛЮЧ>; подстановка
+// ${VAR} выполняется в итоговых значениях. Списки excluded_files объединяются, так как
+// обработанные файлы дописываются только в основной файл конфигурации
+func loadConfigSources(configPath string, overrides []envOverride) (*ini.File, []string, error) {
+	chain, err := configIncludeChain(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := make([]interface{}, 0, len(chain))
+	var excluded []string
+	for _, path := range chain {
+		sources = append(sources, path)
+		if len(chain) > 1 {
+			part, err := loadConfigFile(path, false)
+			if err != nil {
+				return nil, nil, errorf("не удалось загрузить файл конфигурации %s: %v", path, err)
+			}
+			for _, file := range splitList(part.Section("EXCLUSIONS").Key("excluded_files").String()) {
+				if !slices.Contains(excluded, file) {
+					excluded = append(excluded, file)
+				}
+			}
+		}
+	}
+	cfg := ini.Empty(ini.LoadOptions{Loose: true, SpaceBeforeInlineComment: true})
+	if len(sources) > 0 {
+		if err := cfg.Append(sources[0], sources[1:]...); err != nil {
+			return nil, nil, errorf("не удалось загрузить файл конфигурации: %v", err)
+		}
+	}
+	if len(excluded) > 0 {
+		cfg.Section("EXCLUSIONS").Key("excluded_files").SetValue(strings.Join(excluded, ", "))
+	}
+	applyEnvConfig(cfg, overrides)
+	if err := expandConfigEnv(cfg); err != nil {
+		return nil, nil, err
+	}
+	return cfg, chain, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	if err := os.MkdirAll(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(shared, "base.cfg"): "[MODEL]\nname = gpt-4o\ntemperature = 0.2\napi_key = sk-base\n\n" +
+			"[PROMPT]\ntext = Базовый промпт\n\n[EXCLUSIONS]\nexcluded_files = README.md\n",
+		filepath.Join(dir, "rich.cfg"): "include = shared/base.cfg\n\n[DIRECTORIES]\ninput_dir = " + dir + "\n\n" +
+			"[MODEL]\ntemperature = 0.5\n\n[EXCLUSIONS]\nexcluded_files = done.md\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "rich.cfg")
+	t.Setenv("RICH_MODEL_NAME", "gpt-4o-mini")
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.ModelName != "gpt-4o-mini" || config.Temperature != 0.5 || config.Prompt != "Базовый промпт" {
+		t.Errorf("некорректный порядок приоритета: модель %s, температура %v, промпт %q", config.ModelName, config.Temperature, config.Prompt)
+	}
+	if !isExcluded(config, "README.md") || !isExcluded(config, "done.md") {
+		t.Errorf("списки исключений должны объединяться: %v", config.ExcludedFiles)
+	}
+
+	var out bytes.Buffer
+	if err := runConfigCommand([]string{"show", "--config", configPath, "--effective"}, &out); err != nil {
+		t.Fatalf("config show вернул ошибку: %v", err)
+	}
+	for _, want := range []string{"name = gpt-4o-mini   ; RICH_MODEL_NAME", "temperature = 0.5   ; " + configPath,
+		"text = Базовый промпт   ; " + filepath.Join(shared, "base.cfg"), "api_key = ***"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("в выводе нет %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "sk-base") {
+		t.Error("ключ API не должен выводиться в открытом виде")
+	}
+
+	// Циклическое включение
+	if err := os.WriteFile(filepath.Join(shared, "base.cfg"), []byte("include = ../rich.cfg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(configPath); err == nil || !strings.Contains(err.Error(), "->") {
+		t.Errorf("ожидалась ошибка циклического включения, получено %v", err)
+	}
+}
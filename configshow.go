@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Источник каждого параметра итоговой конфигурации: файл или переменная окружения
+func configOrigins(chain []string, overrides []envOverride) (map[string]string, error) {
+	origins := map[string]string{}
+	for _, path := range chain {
+		cfg, err := loadConfigFile(path, false)
+		if err != nil {
+			return nil, errorf("не удалось загрузить файл конфигурации %s: %v", path, err)
+		}
+		for _, section := range cfg.Sections() {
+			for _, key := range section.Keys() {
+				origins[section.Name()+"."+key.Name()] = path
+			}
+		}
+	}
+	for _, o := range overrides {
+		origins[o.Section+"."+o.Key] = envConfigPrefix + o.Section + "_" + strings.ToUpper(o.Key)
+	}
+	return origins, nil
+}
+
+// Значение параметра для вывода: ключи API, пароли и пароли в адресах скрываются;
+// ссылки на хранилища секретов выводятся как есть
+func maskConfigValue(name, value string) string {
+	if value == "" || strings.HasPrefix(value, keychainPrefix) || strings.HasPrefix(value, encryptedPrefix) || isRemoteSecretRef(value) {
+		return value
+	}
+	name = strings.ToLower(name)
+	if name == "api_key" || name == "secret_key" || name == "password" || strings.HasSuffix(name, "_token") || strings.HasSuffix(name, "_secret") {
+		return "***"
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "***")
+			return strings.Replace(u.String(), "%2A%2A%2A", "***", 1)
+		}
+	}
+	return value
+}
+
+// Вывод итоговой конфигурации в формате INI с источником каждого параметра
+func writeEffectiveConfig(out io.Writer, cfg *ini.File, origins map[string]string) {
+	for _, section := range cfg.Sections() {
+		keys := section.Keys()
+		if len(keys) == 0 {
+			continue
+		}
+		if section.Name() != ini.DefaultSection {
+			fmt.Fprintf(out, "[%s]\n", section.Name())
+		}
+		for _, key := range keys {
+			value := maskConfigValue(key.Name(), key.Value())
+			if strings.Contains(value, "\n") {
+				value = `"""` + value + `"""`
+			}
+			origin := origins[section.Name()+"."+key.Name()]
+			if origin == "" {
+				origin = tr("по умолчанию")
+			}
+			fmt.Fprintf(out, "%s = %s   ; %s\n", key.Name(), value, origin)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// rich config show [--effective=false] [-order ...]: итоговая конфигурация с учетом
+// включений, переменных окружения, параметров командной строки и значений по умолчанию
+// или файл конфигурации как есть
+func runConfigCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "show" {
+		return errorf("укажите действие: rich config show [--effective]")
+	}
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	configPath := fs.String("config", envString("RICH_CONFIG", "rich.cfg"), tr("Путь к файлу конфигурации"))
+	effective := fs.Bool("effective", true, tr("Показать итоговую конфигурацию с учетом включений и переменных окружения"))
+	// Параметры командной строки запуска, переопределяющие ключи конфигурации
+	cliFlags := []struct {
+		Flag, Section, Key string
+		Value              *string
+	}{
+		{"order", "PROCESSING", "order", fs.String("order", "", tr("Порядок обработки: alphabetical, newest, oldest, smallest, priority"))},
+		{"modified-after", "PROCESSING", "modified_after", fs.String("modified-after", "", tr("Обрабатывать только файлы, измененные после даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)"))},
+		{"modified-before", "PROCESSING", "modified_before", fs.String("modified-before", "", tr("Обрабатывать только файлы, измененные до даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)"))},
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if !*effective {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return errorf("не удалось прочитать файл конфигурации: %v", err)
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	overrides := envConfigOverrides(os.Environ())
+	if _, err := os.Stat(*configPath); os.IsNotExist(err) && len(overrides) == 0 {
+		return errorf("файл конфигурации не найден: %s", *configPath)
+	}
+	cfg, chain, err := loadConfigSources(*configPath, overrides)
+	if err != nil {
+		return err
+	}
+	origins, err := configOrigins(chain, overrides)
+	if err != nil {
+		return err
+	}
+	for _, f := range cliFlags {
+		if *f.Value != "" {
+			cfg.Section(f.Section).Key(f.Key).SetValue(*f.Value)
+			origins[f.Section+"."+f.Key] = "-" + f.Flag
+		}
+	}
+	// Разбор дописывает значения по умолчанию; ошибка выводится после конфигурации
+	_, parseErr := parseConfig(cfg, *configPath)
+
+	if len(chain) > 0 {
+		fmt.Fprintf(out, tr("; Итоговая конфигурация, файлы в порядке применения: %s\n\n"), strings.Join(chain, ", "))
+	}
+	writeEffectiveConfig(out, cfg, origins)
+	if parseErr != nil {
+		return errorf("ошибка загрузки конфигурации: %v", parseErr)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+)
+
+// Действия с результатом, измененным вручную после обогащения
+const (
+	// Новое обогащение сохраняется рядом в файл .new, результат не меняется (по умолчанию)
+	OnConflictNew = "new"
+	// Результат перезаписывается, ручные правки остаются только в резервной копии запуска
+	OnConflictOverwrite = "overwrite"
+)
+
+// Суффикс файла с новым обогащением результата, измененного вручную
+const ConflictSuffix = ".new"
+
+// Проверка действия с результатом, измененным вручную
+func validateOnConflict(action string) error {
+	if action != OnConflictNew && action != OnConflictOverwrite {
+		return errorf("некорректное значение on_conflict %q: ожидалось %s или %s", action, OnConflictNew, OnConflictOverwrite)
+	}
+	return nil
+}
+
+// Проверка, что выходной файл изменен после последнего обогащения: его хэш
+// отличается от записанного в журнале запуска, который его создал. Без каталога
+// состояния или записи о файле конфликт не определяется
+func (s *session) outputConflict(key, outputPath string) bool {
+	rec, ok := s.recorded[normalizeRelPath(key)]
+	if !ok || rec.Entry.OutputHash == "" || !samePath(rec.Entry.Output, outputPath) {
+		return false
+	}
+	current, err := os.ReadFile(outputPath)
+	if err != nil {
+		return false
+	}
+	// Копию с отметкой об ошибке (on_failure) заменяет следующее обогащение
+	return contentHash(current) != rec.Entry.OutputHash && !hasFailureMarker(current)
+}
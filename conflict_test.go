@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputConflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Заметка"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := "Первое обогащение"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := json.Marshal(map[string]interface{}{"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": answer}}}})
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		ReportFile: filepath.Join(tmpDir, "report.json")}
+	run := func() {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+	run()
+
+	// Ручная правка результата: повторное обогащение сохраняется рядом в файл .new
+	output := filepath.Join(outputDir, "a.md")
+	if err := os.WriteFile(output, []byte("Правка редактора"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	answer = "Второе обогащение"
+	run()
+	if data, _ := os.ReadFile(output); string(data) != "Правка редактора" {
+		t.Errorf("результат с ручной правкой перезаписан: %q", data)
+	}
+	if data, _ := os.ReadFile(output + ConflictSuffix); !strings.HasPrefix(string(data), "Второе обогащение") {
+		t.Errorf("новое обогащение в файле .new: %q", data)
+	}
+
+	var report runReport
+	data, err := os.ReadFile(config.ReportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Totals.Conflicts != 1 || len(report.Files) != 1 || report.Files[0].Status != StatusConflict ||
+		!samePath(report.Files[0].Conflict, output+ConflictSuffix) {
+		t.Errorf("конфликт в отчете: %+v, %+v", report.Totals, report.Files)
+	}
+
+	// Отмена запуска удаляет только файл .new
+	runs, err := listRuns(config.StateDir)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("запусков %d (%v)", len(runs), err)
+	}
+	if restored, skipped, err := undoRun(runs[1], configPath, false); err != nil || restored != 1 || len(skipped) != 0 {
+		t.Fatalf("undoRun() = %d, %v, %v", restored, skipped, err)
+	}
+	if _, err := os.Stat(output + ConflictSuffix); !os.IsNotExist(err) {
+		t.Error("файл .new не удален при отмене")
+	}
+	if data, _ := os.ReadFile(output); string(data) != "Правка редактора" {
+		t.Errorf("результат изменен при отмене: %q", data)
+	}
+
+	// on_conflict = overwrite перезаписывает результат
+	config.OnConflict = OnConflictOverwrite
+	run()
+	if data, _ := os.ReadFile(output); !strings.HasPrefix(string(data), "Второе обогащение") {
+		t.Errorf("результат не перезаписан с on_conflict = overwrite: %q", data)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Действия с конфликтными копиями синхронизации (conflict_copies секции [PROCESSING])
+const (
+	// Конфликтные копии не обрабатываются (по умолчанию)
+	ConflictCopiesSkip = "skip"
+	// Версии из конфликтных копий объединяются моделью с основной заметкой в один
+	// обогащенный документ, который отмечается для проверки
+	ConflictCopiesMerge = "merge"
+)
+
+// Поле frontmatter результата, требующего проверки человеком, и отметка результата,
+// объединенного из конфликтных копий
+const (
+	ReviewKey           = "rich_review"
+	ReviewConflictMerge = "conflict-merge"
+)
+
+// Инструкция для модели перед версиями из конфликтных копий
+const conflictMergeInstruction = "Conflicting versions of this note left by a file sync tool. Merge them with the note below into one document: keep every fact and edit that appears in any version exactly once, prefer the wording of the note below where versions contradict, then enrich the merged document. Versions:"
+
+// Имена конфликтных копий: Dropbox ("note (conflicted copy 2024-01-01).md",
+// "note (Anna's conflicted copy).md") и Syncthing
+// ("note.sync-conflict-20240101-120000-ABCDEFG.md")
+var conflictCopyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(.+?) \([^()/]*[Cc]onflicted copy[^()/]*\)((?:\.[^./ ]+)*)$`),
+	regexp.MustCompile(`^(.+?)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]{7})?((?:\.[^./ ]+)*)$`),
+}
+
+// Проверка действия с конфликтными копиями
+func validateConflictCopies(action string) error {
+	if action != ConflictCopiesSkip && action != ConflictCopiesMerge {
+		return errorf("некорректное значение conflict_copies %q: ожидалось %s или %s", action, ConflictCopiesSkip, ConflictCopiesMerge)
+	}
+	return nil
+}
+
+// Имя основной заметки по имени конфликтной копии; false - файл не конфликтная копия
+func conflictCopyOriginal(name string) (string, bool) {
+	for _, re := range conflictCopyPatterns {
+		if m := re.FindStringSubmatch(name); m != nil {
+			return m[1] + m[2], true
+		}
+	}
+	return "", false
+}
+
+// Конфликтные копии заметки в ее директории, по имени
+func conflictCopies(inputPath string) []string {
+	entries, err := os.ReadDir(filepath.Dir(inputPath))
+	if err != nil {
+		return nil
+	}
+	base := filepath.Base(inputPath)
+	var copies []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if original, ok := conflictCopyOriginal(e.Name()); ok && original == base {
+			copies = append(copies, filepath.Join(filepath.Dir(inputPath), e.Name()))
+		}
+	}
+	sort.Strings(copies)
+	return copies
+}
+
+// Обработанная заметка обогащается заново, если после ее результата появилась или
+// изменилась конфликтная копия (только conflict_copies = merge)
+func (c *Config) conflictMergePending(inputPath, outputPath string) bool {
+	if c.ConflictCopies != ConflictCopiesMerge {
+		return false
+	}
+	out, err := os.Stat(outputPath)
+	if err != nil {
+		return false
+	}
+	for _, copyPath := range conflictCopies(inputPath) {
+		if info, err := os.Stat(copyPath); err == nil && info.ModTime().After(out.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Версии заметки из конфликтных копий для промпта; копии, запрещенные политикой
+// содержимого или нечитаемые, пропускаются
+func readConflictCopies(config *Config, inputPath string) []contextChunk {
+	var chunks []contextChunk
+	for _, copyPath := range conflictCopies(inputPath) {
+		data, err := readMarkdownFile(copyPath)
+		if err != nil {
+			warnf("Предупреждение: не удалось прочитать конфликтную копию %s: %v", copyPath, err)
+			continue
+		}
+		if config.Policy.Blocks(data) {
+			logf("Конфликтная копия %s запрещена политикой содержимого и не объединяется", copyPath)
+			continue
+		}
+		chunks = append(chunks, contextChunk{Source: filepath.Base(copyPath), Text: strings.TrimSpace(string(data))})
+	}
+	return chunks
+}
+
+// Состояние заметки с учетом ее конфликтных копий: в режиме наблюдения появление
+// или изменение копии считается изменением основной заметки
+func withConflictCopies(state watchedFile, copies []watchedFile) watchedFile {
+	for _, c := range copies {
+		state.Size += c.Size
+		if c.ModTime.After(state.ModTime) {
+			state.ModTime = c.ModTime
+		}
+	}
+	return state
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConflictCopyOriginal(t *testing.T) {
+	testCases := []struct {
+		name     string
+		original string
+		ok       bool
+	}{
+		{"note (conflicted copy).md", "note.md", true},
+		{"note (conflicted copy 2024-01-01).md", "note.md", true},
+		{"my note (Anna's Conflicted copy 2024-01-01).md", "my note.md", true},
+		{"archive.2019 (conflicted copy).md.gz", "archive.2019.md.gz", true},
+		{"note.sync-conflict-20240101-120000-ABCDEFG.md", "note.md", true},
+		{"note.sync-conflict-20240101-120000.md", "note.md", true},
+		{"note.md", "", false},
+		{"note (copy).md", "", false},
+		{"conflicted copy.md", "", false},
+	}
+	for _, tc := range testCases {
+		original, ok := conflictCopyOriginal(tc.name)
+		if ok != tc.ok || original != tc.original {
+			t.Errorf("conflictCopyOriginal(%q) = %q, %v; ожидалось %q, %v", tc.name, original, ok, tc.original, tc.ok)
+		}
+	}
+	if err := validateConflictCopies("keep"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного значения conflict_copies")
+	}
+}
+
+// Конфликтные копии не обрабатываются отдельно; при conflict_copies = merge они
+// передаются модели вместе с основной заметкой, а результат отмечается для проверки
+func TestConflictCopiesMerge(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	for _, action := range []string{ConflictCopiesSkip, ConflictCopiesMerge} {
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+		tmpDir := t.TempDir()
+		inputDir := filepath.Join(tmpDir, "input")
+		outputDir := filepath.Join(tmpDir, "output")
+		if err := os.MkdirAll(inputDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			"a.md":                                 "# Заметка\n\nВерсия с ноутбука",
+			"a (conflicted copy 2024-01-01).md":    "# Заметка\n\nВерсия с телефона",
+			"b.md":                                 "# Другая заметка\n\nБез конфликтов",
+			"b.sync-conflict-20240101-120000-A.md": "не копия: неверный идентификатор устройства",
+		}
+		for name, text := range files {
+			if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		configPath := filepath.Join(tmpDir, "rich.cfg")
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+			"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+			"[PROCESSING]\nconflict_copies = " + action + "\n[EXCLUSIONS]\nbuiltin_ignore = false\nexcluded_files =\n" +
+			"[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(outputDir, "a (conflicted copy 2024-01-01).md")); err == nil {
+			t.Errorf("%s: конфликтная копия не должна обрабатываться отдельно", action)
+		}
+		mu.Lock()
+		if len(bodies) != 3 {
+			t.Fatalf("%s: ожидалось 3 запроса к API, получено %d", action, len(bodies))
+		}
+		merged := false
+		for _, body := range bodies {
+			merged = merged || strings.Contains(body, "Версия с телефона")
+		}
+		mu.Unlock()
+		if merged != (action == ConflictCopiesMerge) {
+			t.Errorf("%s: версия из конфликтной копии в запросе: %v", action, merged)
+		}
+		a, err := os.ReadFile(filepath.Join(outputDir, "a.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		flagged := strings.Contains(string(a), ReviewKey+": "+ReviewConflictMerge)
+		if flagged != (action == ConflictCopiesMerge) {
+			t.Errorf("%s: отметка для проверки в результате: %v\n%s", action, flagged, a)
+		}
+		if b, _ := os.ReadFile(filepath.Join(outputDir, "b.md")); strings.Contains(string(b), ReviewKey) {
+			t.Errorf("%s: заметка без конфликтных копий не должна отмечаться:\n%s", action, b)
+		}
+		if action == ConflictCopiesSkip {
+			continue
+		}
+
+		// Новая конфликтная копия обработанной заметки вызывает повторное объединение
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+		copyPath := filepath.Join(inputDir, "a.sync-conflict-20240102-120000-ABCDEFG.md")
+		if err := os.WriteFile(copyPath, []byte("# Заметка\n\nВерсия с планшета"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		future := time.Now().Add(time.Hour)
+		if err := os.Chtimes(copyPath, future, future); err != nil {
+			t.Fatal(err)
+		}
+		config, err = loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+		mu.Lock()
+		if len(bodies) != 1 || !strings.Contains(bodies[0], "Версия с планшета") || !strings.Contains(bodies[0], "Версия с телефона") {
+			t.Errorf("Ожидалось повторное объединение a.md со всеми копиями: %v", bodies)
+		}
+		mu.Unlock()
+	}
+}
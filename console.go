@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Уровень сообщения: подробные сообщения пишутся только в файл журнала,
+// остальные дублируются в консоль
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// ANSI коды цветов консоли
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// Консольный вывод: краткие строки по файлам, предупреждения и ошибки
+// (цветом, если консоль его поддерживает) отдельно от подробного файла журнала
+type consoleOutput struct {
+	mu    sync.Mutex
+	out   io.Writer
+	color bool
+}
+
+// Консоль процесса; nil - все сообщения идут только в стандартный журнал (log)
+var console *consoleOutput
+
+// Создание консольного вывода
+func newConsole(out io.Writer, color bool) *consoleOutput {
+	return &consoleOutput{out: out, color: color}
+}
+
+// Выбор цветного вывода: выключен флагом -no-color, переменной NO_COLOR,
+// TERM=dumb и при выводе не в терминал (перенаправление в файл или канал)
+func useColor(noColor bool, f *os.File) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return enableConsoleColors(f)
+}
+
+// Стандартный вывод занят данными (поток состояния, приемник stdout): журнал
+// и консоль переносятся в stderr
+func releaseStdout(noColor bool) {
+	if log.Writer() == os.Stdout {
+		log.SetOutput(os.Stderr)
+	}
+	if console != nil && console.out == io.Writer(os.Stdout) {
+		console = newConsole(os.Stderr, useColor(noColor, os.Stderr))
+	}
+}
+
+// Раскраска текста, если цвет включен
+func (c *consoleOutput) paint(code, text string) string {
+	if !c.color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// Вывод строки в консоль
+func (c *consoleOutput) println(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintln(c.out, line)
+}
+
+// Вывод сообщения журнала в консоль по уровню
+func (c *consoleOutput) message(level logLevel, msg string) {
+	switch level {
+	case levelWarn:
+		c.println(c.paint(ansiYellow, msg))
+	case levelError:
+		c.println(c.paint(ansiRed, msg))
+	default:
+		c.println(msg)
+	}
+}
+
+// Краткая строка с результатом обработки файла
+func (c *consoleOutput) FileResult(relPath string, result *fileResult, cost float64, err error) {
+	if c == nil {
+		return
+	}
+	relPath = normalizeRelPath(relPath)
+	switch {
+	case err != nil:
+		c.println(c.paint(ansiRed, "✗ "+relPath+": "+err.Error()))
+	case result.Status == StatusEnriched:
+		line := c.paint(ansiGreen, "✓") + " " + relPath
+		if tokens := result.Usage.PromptTokens + result.Usage.CompletionTokens; tokens > 0 {
+			detail := trf("%d токенов", tokens)
+			if cost > 0 {
+				detail += fmt.Sprintf(", $%.4f", cost)
+			}
+			line += " " + c.paint(ansiDim, "("+detail+")")
+		}
+		if n := len(result.BrokenLinks); n > 0 {
+			line += " " + c.paint(ansiYellow, trf("битых ссылок: %d", n))
+		}
+		c.println(line)
+	case result.Status == StatusConflict:
+		c.println(c.paint(ansiYellow, "! "+relPath+": "+trf("изменен вручную, новый результат в %s", filepath.Base(result.Conflict))))
+	default:
+		c.println(c.paint(ansiDim, "· "+relPath+" "+strings.TrimPrefix(result.Status, "skipped: ")))
+	}
+}
+
+// Запись сообщения в журнал и, если уровень не подробный, в консоль
+func logMessage(level logLevel, msg string) {
+	// Глубина 3: logMessage - функция уровня - вызывающий код
+	if err := log.Output(3, msg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if console != nil && level > levelDebug {
+		console.message(level, msg)
+	}
+}
+
+// Подробное сообщение (только файл журнала, если консольный вывод включен)
+func logf(format string, args ...any) {
+	logMessage(levelDebug, trf(format, args...))
+}
+
+// Сообщение о ходе работы (журнал и консоль)
+func infof(format string, args ...any) {
+	logMessage(levelInfo, trf(format, args...))
+}
+
+// Предупреждение (журнал и консоль, желтым)
+func warnf(format string, args ...any) {
+	logMessage(levelWarn, trf(format, args...))
+}
+
+// Ошибка, не прерывающая работу (журнал и консоль, красным)
+func logErrorf(format string, args ...any) {
+	logMessage(levelError, trf(format, args...))
+}
+
+// Запись ошибки в журнал и консоль и завершение программы с кодом по категории
+// ошибки среди аргументов (1 - категория не определена)
+func fatalf(format string, args ...any) {
+	logMessage(levelError, trf(format, args...))
+	code := exitFailure
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			code = exitCode(err)
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConsoleFileResult(t *testing.T) {
+	var buf bytes.Buffer
+	c := newConsole(&buf, false)
+
+	c.FileResult(`docs\a.md`, &fileResult{Status: StatusEnriched, Usage: Usage{PromptTokens: 100, CompletionTokens: 50}}, 0.0012, nil)
+	c.FileResult("b.md", &fileResult{Status: StatusSkippedTooSmall}, 0, nil)
+	c.FileResult("c.md", &fileResult{Status: StatusFailed}, 0, errors.New("таймаут"))
+
+	want := "✓ docs/a.md (150 токенов, $0.0012)\n· b.md too small\n✗ c.md: таймаут\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Вывод без цвета:\n%q\nожидалось\n%q", got, want)
+	}
+
+	buf.Reset()
+	c = newConsole(&buf, true)
+	c.FileResult("c.md", &fileResult{Status: StatusFailed}, 0, errors.New("таймаут"))
+	if got := buf.String(); got != ansiRed+"✗ c.md: таймаут"+ansiReset+"\n" {
+		t.Errorf("Ошибка должна выводиться красным: %q", got)
+	}
+
+	// Без консольного вывода строки по файлам не печатаются
+	var none *consoleOutput
+	none.FileResult("a.md", &fileResult{Status: StatusEnriched}, 0, nil)
+}
+
+func TestLogLevels(t *testing.T) {
+	var logBuf, consoleBuf bytes.Buffer
+	oldConsole, oldOutput, oldFlags := console, log.Writer(), log.Flags()
+	defer func() {
+		console = oldConsole
+		log.SetOutput(oldOutput)
+		log.SetFlags(oldFlags)
+	}()
+	log.SetOutput(&logBuf)
+	log.SetFlags(0)
+	console = newConsole(&consoleBuf, true)
+
+	logf("Обработка %s", "a.md")
+	infof("Обработано файлов: %d", 1)
+	warnf("Предупреждение: %v", "мало места")
+	logErrorf("Ошибка удаления временного файла: %v", "занят")
+
+	wantLog := "Обработка a.md\nОбработано файлов: 1\nПредупреждение: мало места\nОшибка удаления временного файла: занят\n"
+	if got := logBuf.String(); got != wantLog {
+		t.Errorf("Файл журнала:\n%q\nожидалось\n%q", got, wantLog)
+	}
+	wantConsole := "Обработано файлов: 1\n" +
+		ansiYellow + "Предупреждение: мало места" + ansiReset + "\n" +
+		ansiRed + "Ошибка удаления временного файла: занят" + ansiReset + "\n"
+	if got := consoleBuf.String(); got != wantConsole {
+		t.Errorf("Консоль:\n%q\nожидалось\n%q", got, wantConsole)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("Не удалось создать файл: %v", err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	if useColor(false, f) {
+		t.Error("Вывод в файл не должен быть цветным")
+	}
+	if useColor(true, os.Stdout) {
+		t.Error("-no-color должен выключать цвет")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if useColor(false, os.Stdout) {
+		t.Error("NO_COLOR должен выключать цвет")
+	}
+	if !strings.Contains(newConsole(nil, true).paint(ansiGreen, "ok"), ansiGreen) {
+		t.Error("При включенном цвете текст должен раскрашиваться")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// Терминалы Unix поддерживают ANSI цвета без дополнительной настройки
+func enableConsoleColors(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Срок, на который файл закрепляется за экземпляром, по умолчанию
+const defaultClaimTTL = 30 * time.Minute
+
+// Настройки совместной работы нескольких экземпляров через Redis из секции [REDIS]
+type RedisConfig struct {
+	URL      string
+	Password string
+	// Префикс ключей: экземпляры с одним префиксом делят лимит запросов и файлы
+	Namespace string
+	// Распределять файлы между экземплярами (иначе - только общий лимит запросов)
+	ShareWork bool
+	// Срок закрепления файла за экземпляром
+	ClaimTTL time.Duration
+}
+
+// Общий для экземпляров лимит запросов в минуту: счетчик запросов в текущем
+// окне и общая пауза после ответа 429
+type sharedLimiter struct {
+	client    *redisClient
+	prefix    string
+	perMinute int
+	// Длина окна счетчика (минута; в тестах - меньше)
+	window time.Duration
+}
+
+// Ожидание места в общем лимите; при недоступности Redis остается только локальный лимит
+func (l *sharedLimiter) Wait() {
+	for {
+		pause, err := l.client.Int("PTTL", l.prefix+":pause")
+		if err != nil {
+			warnf("Предупреждение: общий лимит запросов недоступен, используется локальный: %v", err)
+			return
+		}
+		if pause > 0 {
+			time.Sleep(time.Duration(pause) * time.Millisecond)
+			continue
+		}
+
+		slot := time.Now().UnixNano() / int64(l.window)
+		key := fmt.Sprintf("%s:ratelimit:%d", l.prefix, slot)
+		n, err := l.client.Int("INCR", key)
+		if err != nil {
+			warnf("Предупреждение: общий лимит запросов недоступен, используется локальный: %v", err)
+			return
+		}
+		if n == 1 {
+			// Счетчик живет два окна: хватает с запасом при расхождении часов
+			_, _ = l.client.Do("PEXPIRE", key, strconv.FormatInt(2*l.window.Milliseconds(), 10))
+		}
+		if n <= int64(l.perMinute) {
+			return
+		}
+		next := time.Unix(0, (slot+1)*int64(l.window))
+		logf("Общий лимит запросов исчерпан, ожидание %v", time.Until(next).Round(time.Millisecond))
+		time.Sleep(time.Until(next))
+	}
+}
+
+// Общая пауза всех экземпляров до сброса лимита провайдера
+func (l *sharedLimiter) PauseFor(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if _, err := l.client.Do("SET", l.prefix+":pause", "1", "PX", strconv.FormatInt(d.Milliseconds()+1, 10)); err != nil {
+		warnf("Предупреждение: не удалось передать паузу другим экземплярам: %v", err)
+	}
+}
+
+// Закрепление файлов за экземпляром, чтобы несколько экземпляров не обрабатывали
+// один файл
+type workClaims struct {
+	client *redisClient
+	prefix string
+	// Идентификатор экземпляра: хост, процесс и запуск
+	owner string
+	ttl   time.Duration
+}
+
+// Снятие закрепления только своим экземпляром
+const releaseClaimScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+
+// Закрепление файла; false - файл уже в работе у другого экземпляра
+func (w *workClaims) Claim(relPath string) (bool, error) {
+	reply, err := w.client.Do("SET", w.prefix+":claim:"+normalizeRelPath(relPath), w.owner, "NX", "PX", strconv.FormatInt(w.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, errorf("не удалось закрепить файл %s: %v", relPath, err)
+	}
+	return reply == "OK", nil
+}
+
+// Снятие закрепления (после ошибки файл может взять другой экземпляр)
+func (w *workClaims) Release(relPath string) error {
+	if _, err := w.client.Do("EVAL", releaseClaimScript, "1", w.prefix+":claim:"+normalizeRelPath(relPath), w.owner); err != nil {
+		return errorf("не удалось снять закрепление файла %s: %v", relPath, err)
+	}
+	return nil
+}
+
+// Подключение ограничителя запросов к общему лимиту и, если включено, распределение
+// файлов между экземплярами
+func setupRedisCoordination(config *Config, limiter *RateLimiter, runID string) (*workClaims, error) {
+	rc := config.Redis
+	client, err := newRedisClient(rc.URL)
+	if err != nil {
+		return nil, err
+	}
+	if rc.Password != "" {
+		client.password = rc.Password
+	}
+	if _, err := client.Do("PING"); err != nil {
+		return nil, err
+	}
+	limiter.shared = &sharedLimiter{client: client, prefix: rc.Namespace, perMinute: config.requestsPerMinute(), window: time.Minute}
+	infof("Общий лимит запросов через Redis: %d в минуту (%s)", config.requestsPerMinute(), rc.Namespace)
+	if !rc.ShareWork {
+		return nil, nil
+	}
+
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", host, os.Getpid())
+	if runID != "" {
+		owner += ":" + runID
+	}
+	return &workClaims{client: client, prefix: rc.Namespace, owner: owner, ttl: rc.ClaimTTL}, nil
+}
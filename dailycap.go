@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Файл расхода запросов и токенов за последние сутки в каталоге состояния
+const dailyUsageName = "daily_usage.json"
+
+// Окно суточного лимита и шаг учета расхода: запросы часа выходят из окна вместе
+const (
+	dailyCapWindow = 24 * time.Hour
+	dailyCapBucket = time.Hour
+)
+
+// Суточный лимит запросов к API и токенов (daily_max_requests, daily_max_tokens
+// секции [PROCESSING]): скользящее окно последних 24 часов, независимо от лимитов
+// в минуту
+type DailyCapConfig struct {
+	// Запросов за сутки (0 - без ограничения)
+	MaxRequests int
+	// Токенов за сутки (0 - без ограничения)
+	MaxTokens int
+}
+
+// Суточный лимит задан
+func (d DailyCapConfig) enabled() bool {
+	return d.MaxRequests > 0 || d.MaxTokens > 0
+}
+
+// Расход за один час окна
+type usageBucket struct {
+	Hour     time.Time `json:"hour"`
+	Requests int       `json:"requests"`
+	Tokens   int       `json:"tokens"`
+}
+
+// Расход запросов и токенов за последние сутки. С каталогом состояния расход общий
+// для запусков и экземпляров и сохраняется после перезапуска службы, без него -
+// в пределах процесса (все запуски режима наблюдения)
+type dailyUsage struct {
+	mu      sync.Mutex
+	limits  DailyCapConfig
+	path    string
+	buckets []usageBucket
+	now     func() time.Time
+}
+
+// Расход процессов без каталога состояния
+var processDailyUsage = &dailyUsage{now: time.Now}
+
+// Учет суточного лимита по конфигурации; nil, если лимит не задан
+func newDailyUsage(config *Config) *dailyUsage {
+	if !config.DailyCap.enabled() {
+		return nil
+	}
+	if config.StateDir == "" {
+		processDailyUsage.mu.Lock()
+		processDailyUsage.limits = config.DailyCap
+		processDailyUsage.mu.Unlock()
+		return processDailyUsage
+	}
+	return &dailyUsage{limits: config.DailyCap, path: filepath.Join(config.StateDir, dailyUsageName), now: time.Now}
+}
+
+// Чтение, изменение и сохранение расхода под блокировкой файла; часы старше окна
+// отбрасываются
+func (d *dailyUsage) update(fn func()) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	apply := func() error {
+		if d.path != "" {
+			d.buckets = nil
+			if data, err := os.ReadFile(d.path); err == nil {
+				if err := json.Unmarshal(data, &d.buckets); err != nil {
+					warnf("Предупреждение: некорректный файл расхода за сутки %s: %v", d.path, err)
+					d.buckets = nil
+				}
+			}
+		}
+		start := d.now().Add(-dailyCapWindow)
+		kept := d.buckets[:0]
+		for _, b := range d.buckets {
+			if b.Hour.Add(dailyCapBucket).After(start) {
+				kept = append(kept, b)
+			}
+		}
+		d.buckets = kept
+		if fn == nil {
+			return nil
+		}
+		fn()
+		if d.path == "" {
+			return nil
+		}
+		data, err := json.MarshalIndent(d.buckets, "", "  ")
+		if err != nil {
+			return err
+		}
+		return safeWriteFile(d.path, data, 0644)
+	}
+	if d.path == "" {
+		return apply()
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	return withFileLock(d.path, apply)
+}
+
+// Учет выполненного запроса к API и его токенов
+func (d *dailyUsage) Record(usage Usage) {
+	if d == nil {
+		return
+	}
+	err := d.update(func() {
+		hour := d.now().UTC().Truncate(dailyCapBucket)
+		if n := len(d.buckets); n > 0 && d.buckets[n-1].Hour.Equal(hour) {
+			d.buckets[n-1].Requests++
+			d.buckets[n-1].Tokens += usage.PromptTokens + usage.CompletionTokens
+			return
+		}
+		d.buckets = append(d.buckets, usageBucket{Hour: hour, Requests: 1, Tokens: usage.PromptTokens + usage.CompletionTokens})
+	})
+	if err != nil {
+		warnf("Предупреждение: не удалось сохранить расход за сутки: %v", err)
+	}
+}
+
+// Запросы и токены в окне (вызывается под d.mu)
+func (d *dailyUsage) totals() (requests, tokens int) {
+	for _, b := range d.buckets {
+		requests += b.Requests
+		tokens += b.Tokens
+	}
+	return requests, tokens
+}
+
+// Лимит запросов не будет превышен, если отправить еще один файл, пока inFlight
+// файлов в обработке: на каждый из них резервируется хотя бы один запрос
+func (d *dailyUsage) Admits(inFlight int) bool {
+	if d == nil || d.limits.MaxRequests <= 0 || inFlight == 0 {
+		return true
+	}
+	if err := d.update(nil); err != nil {
+		warnf("Предупреждение: не удалось прочитать расход за сутки: %v", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	requests, _ := d.totals()
+	return requests+inFlight < d.limits.MaxRequests
+}
+
+// Проверка исчерпания суточного лимита перед отправкой очередного файла: причина
+// и время, когда в окне освободится место
+func (d *dailyUsage) Exhausted() (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	if err := d.update(nil); err != nil {
+		warnf("Предупреждение: не удалось прочитать расход за сутки: %v", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	requests, tokens := d.totals()
+	// Место освобождается, когда из окна выходит самый старый час
+	resume := d.now()
+	if len(d.buckets) > 0 {
+		resume = d.buckets[0].Hour.Add(dailyCapBucket + dailyCapWindow)
+	}
+	at := resume.Local().Format("2006-01-02 15:04")
+	if d.limits.MaxRequests > 0 && requests >= d.limits.MaxRequests {
+		return trf("достигнут суточный лимит запросов к API (%d из %d за 24 часа), продолжение после %s", requests, d.limits.MaxRequests, at), true
+	}
+	if d.limits.MaxTokens > 0 && tokens >= d.limits.MaxTokens {
+		return trf("достигнут суточный лимит токенов (%d из %d за 24 часа), продолжение после %s", tokens, d.limits.MaxTokens, at), true
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDailyUsageWindow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)
+	config := &Config{StateDir: t.TempDir(), DailyCap: DailyCapConfig{MaxRequests: 3, MaxTokens: 1000}}
+	d := newDailyUsage(config)
+	d.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		d.Record(Usage{PromptTokens: 100, CompletionTokens: 50})
+	}
+	if reason, exhausted := d.Exhausted(); exhausted {
+		t.Fatalf("Лимит не должен быть исчерпан: %s", reason)
+	}
+
+	// Расход общий для экземпляров с одним каталогом состояния
+	other := newDailyUsage(config)
+	other.now = d.now
+	now = now.Add(3 * time.Hour)
+	other.Record(Usage{PromptTokens: 10})
+	if _, exhausted := d.Exhausted(); !exhausted {
+		t.Fatal("Ожидалось исчерпание лимита запросов")
+	}
+
+	// Через сутки после первых запросов они выходят из окна
+	now = now.Add(22 * time.Hour)
+	if reason, exhausted := d.Exhausted(); exhausted {
+		t.Errorf("Запросы старше суток не должны учитываться: %s", reason)
+	}
+
+	// Лимит токенов
+	d.Record(Usage{PromptTokens: 995})
+	if _, exhausted := d.Exhausted(); !exhausted {
+		t.Error("Ожидалось исчерпание лимита токенов")
+	}
+
+	if newDailyUsage(&Config{}) != nil {
+		t.Error("Без лимита учет не ведется")
+	}
+}
+
+// Запуск останавливается при исчерпании суточного лимита, оставшиеся файлы не обрабатываются
+func TestDailyCapStopsRun(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("note%d.md", i)), []byte(fmt.Sprintf("# Заметка %d\n\nТекст", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[PROCESSING]\ndaily_max_requests = 2\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	for run := 0; run < 2; run++ {
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Ожидалось 2 запроса к API за сутки, получено %d", calls.Load())
+	}
+	reloaded, _ := loadConfig(configPath)
+	if len(reloaded.ExcludedFiles) != 2 {
+		t.Errorf("Необработанные файлы не должны попадать в список исключений: %v", reloaded.ExcludedFiles)
+	}
+}
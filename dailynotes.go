@@ -0,0 +1,378 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Инструкция модели для обзора недели по дневниковым заметкам
+const weeklyRollupInstruction = `Below are daily journal notes for one week, each preceded by a comment with its date and file name, in chronological order. Write a "week in review": the main events, decisions and results of the week, progress on recurring topics, open questions and tasks carried over to the next week, and notable ideas worth revisiting. Refer to days by date, keep names and figures exact and do not invent facts that are not in the notes. Write in the language of the notes and reply with the document only.`
+
+// Дневниковые заметки из секции [DAILY_NOTES]
+type DailyNotesConfig struct {
+	Enabled bool
+	// Шаблоны путей дневниковых заметок относительно input_dir (пусто - все
+	// заметки с датой в имени файла или поле date frontmatter)
+	Paths []ignorePattern
+	// Количество предыдущих дней, обогащенные заметки которых добавляются в контекст,
+	// и число слов из каждой заметки
+	ContextDays  int
+	ContextWords int
+	// Обзор недели по воскресеньям в режиме службы и его директория относительно output_dir
+	Rollup    bool
+	RollupDir string
+}
+
+// Чтение секции [DAILY_NOTES]
+func loadDailyNotesConfig(section *ini.Section) (DailyNotesConfig, error) {
+	dc := DailyNotesConfig{
+		Enabled:      section.Key("enabled").MustBool(false),
+		ContextDays:  section.Key("context_days").MustInt(3),
+		ContextWords: section.Key("context_words").MustInt(200),
+		Rollup:       section.Key("weekly_rollup").MustBool(true),
+		RollupDir:    strings.Trim(section.Key("rollup_dir").MustString("weekly"), "/ "),
+	}
+	for _, value := range strings.Split(section.Key("paths").String(), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		p, ok, err := parseIgnorePattern(value)
+		if err != nil || !ok || p.negate {
+			return dc, errorf("некорректный шаблон пути %q в секции [DAILY_NOTES]", value)
+		}
+		dc.Paths = append(dc.Paths, p)
+	}
+	if dc.ContextDays < 0 {
+		return dc, errorf("context_days в секции [DAILY_NOTES] не может быть отрицательным: %d", dc.ContextDays)
+	}
+	if dc.ContextWords <= 0 {
+		return dc, errorf("context_words в секции [DAILY_NOTES] должен быть положительным: %d", dc.ContextWords)
+	}
+	if dc.RollupDir == "" || !isRelPathSafe(filepath.FromSlash(dc.RollupDir)) {
+		return dc, errorf("rollup_dir в секции [DAILY_NOTES] должен быть директорией внутри выходной директории: %q", dc.RollupDir)
+	}
+	return dc, nil
+}
+
+// Дата дневниковой заметки; false - файл не считается дневниковой заметкой
+func (dc DailyNotesConfig) noteDate(relPath string, fm Frontmatter) (time.Time, bool) {
+	slashPath := filepath.ToSlash(filepath.Clean(relPath))
+	if slashPath == dc.RollupDir || strings.HasPrefix(slashPath, dc.RollupDir+"/") {
+		return time.Time{}, false
+	}
+	if len(dc.Paths) > 0 {
+		matched := false
+		for _, p := range dc.Paths {
+			matched = matched || p.re.MatchString(slashPath)
+		}
+		if !matched {
+			return time.Time{}, false
+		}
+	}
+	date, ok := noteDate(relPath, fm)
+	if !ok {
+		return time.Time{}, false
+	}
+	return dayOf(date), true
+}
+
+// Начало календарного дня
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Дневниковая заметка входной директории
+type dailyNote struct {
+	Date time.Time
+	// Путь относительно входной директории
+	RelPath string
+}
+
+// Дневниковые заметки корня по датам; обогащенный текст читается из выходной
+// директории при обращении, поэтому доступны и заметки, обработанные в этом запуске
+type dailyNoteIndex struct {
+	config    *Config
+	outputDir string
+	notes     []dailyNote
+}
+
+// Индекс дневниковых заметок входной директории (один раз за запуск корня)
+func buildDailyNoteIndex(config *Config, inputDir, outputDir string) (*dailyNoteIndex, error) {
+	ix := &dailyNoteIndex{config: config, outputDir: outputDir}
+	err := filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != inputDir && (strings.HasPrefix(d.Name(), ".") || samePath(path, outputDir)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownPath(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return nil
+		}
+		// Чтение frontmatter нужно только без даты в имени файла
+		var fm Frontmatter
+		if fileNameDate.FindString(d.Name()) == "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			fm, _, _ = parseFrontmatter(data)
+		}
+		if date, ok := config.DailyNotes.noteDate(rel, fm); ok {
+			ix.notes = append(ix.notes, dailyNote{Date: date, RelPath: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при индексации дневниковых заметок: %v", err)
+	}
+	sort.SliceStable(ix.notes, func(i, j int) bool {
+		if !ix.notes[i].Date.Equal(ix.notes[j].Date) {
+			return ix.notes[i].Date.Before(ix.notes[j].Date)
+		}
+		return ix.notes[i].RelPath < ix.notes[j].RelPath
+	})
+	logf("Дневниковых заметок: %d", len(ix.notes))
+	return ix, nil
+}
+
+// Заметки с датой в полуинтервале [from, to)
+func (ix *dailyNoteIndex) Between(from, to time.Time) []dailyNote {
+	var notes []dailyNote
+	for _, n := range ix.notes {
+		if !n.Date.Before(from) && n.Date.Before(to) {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// Обогащенный текст заметки из выходной директории (без блока с оригиналом и
+// frontmatter); false - заметка еще не обогащалась
+func (ix *dailyNoteIndex) enriched(titles *titleMap, relPath string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(ix.outputDir, titledRelPath(ix.config, titles, relPath)))
+	if err != nil {
+		return "", false
+	}
+	text := string(data)
+	if prev, ok := parseEnrichedOutput(text); ok {
+		text = prev.Enriched
+	}
+	_, body, _ := parseFrontmatter([]byte(text))
+	text = strings.TrimSpace(string(body))
+	return text, text != ""
+}
+
+// Выдержки из обогащенных заметок предыдущих дней для заметки с датой date
+func (ix *dailyNoteIndex) Context(titles *titleMap, date time.Time) []contextChunk {
+	days, words := ix.config.DailyNotes.ContextDays, ix.config.DailyNotes.ContextWords
+	if days == 0 {
+		return nil
+	}
+	var chunks []contextChunk
+	for _, n := range ix.Between(date.AddDate(0, 0, -days), date) {
+		text, ok := ix.enriched(titles, n.RelPath)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(text)
+		excerpt := strings.Join(fields[:min(len(fields), words)], " ")
+		if len(fields) > words {
+			excerpt += " ..."
+		}
+		chunks = append(chunks, contextChunk{Source: n.Date.Format("2006-01-02") + " " + filepath.ToSlash(n.RelPath), Text: excerpt})
+	}
+	return chunks
+}
+
+// Границы недели (понедельник - воскресенье), в которую входит день
+func weekBounds(day time.Time) (start, end time.Time) {
+	day = dayOf(day)
+	offset := (int(day.Weekday()) + 6) % 7
+	start = day.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 6)
+}
+
+// Путь обзора недели относительно выходной директории: <rollup_dir>/<год>-W<неделя>.md
+func weeklyRollupPath(dc DailyNotesConfig, day time.Time) string {
+	year, week := dayOf(day).ISOWeek()
+	return filepath.Join(filepath.FromSlash(dc.RollupDir), fmt.Sprintf("%d-W%02d.md", year, week))
+}
+
+// Итог обзора недели
+type rollupResult struct {
+	Output    string
+	Sources   []string
+	Unchanged bool
+	Usage     Usage
+}
+
+// Обзор недели, в которую входит day, по обогащенным дневниковым заметкам (для еще не
+// обогащенных - по исходным). Обзор пересоздается только при изменении заметок недели
+func writeWeeklyRollup(config *Config, day time.Time, force bool, limiter *RateLimiter) (*rollupResult, error) {
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+	outputDir, err := filepath.Abs(config.OutputDir)
+	if err != nil {
+		return nil, errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+	}
+	outputPath := filepath.Join(outputDir, weeklyRollupPath(config.DailyNotes, day))
+	result := &rollupResult{Output: outputPath}
+	ix, err := buildDailyNoteIndex(config, inputDir, outputDir)
+	if err != nil {
+		return result, err
+	}
+	titles, err := loadTitleMap(config.titlesFile())
+	if err != nil {
+		return result, err
+	}
+
+	start, end := weekBounds(day)
+	var notes strings.Builder
+	for _, n := range ix.Between(start, end.AddDate(0, 0, 1)) {
+		text, ok := ix.enriched(titles, n.RelPath)
+		if !ok {
+			data, err := os.ReadFile(filepath.Join(inputDir, n.RelPath))
+			if err != nil {
+				return result, errorf("ошибка при чтении файла: %w", err)
+			}
+			_, body, _ := parseFrontmatter(data)
+			text = strings.TrimSpace(string(body))
+		}
+		if matches := config.Policy.Check([]byte(text)); blockedByPolicy(matches) {
+			warnf("Предупреждение: заметка %s не включена в обзор недели по политике содержимого", normalizeRelPath(n.RelPath))
+			continue
+		}
+		fmt.Fprintf(&notes, "<!-- %s: %s -->\n%s\n\n", n.Date.Format("2006-01-02"), normalizeRelPath(n.RelPath), text)
+		result.Sources = append(result.Sources, normalizeRelPath(n.RelPath))
+	}
+	if len(result.Sources) == 0 {
+		return result, withCategory(ErrorValidation, errorf("за неделю %s - %s нет дневниковых заметок",
+			start.Format("2006-01-02"), end.Format("2006-01-02")))
+	}
+
+	rollupConfig := *config
+	rollupConfig.Prompt = weeklyRollupInstruction
+	// Примеры и правила ответа относятся к обогащению отдельных заметок
+	rollupConfig.Examples = nil
+	rollupConfig.OutputRules = outputRules{}
+
+	hash := contentHash([]byte(rollupConfig.Prompt + "\x00" + rollupConfig.ModelName + "\x00" + notes.String()))[:16]
+	if !force {
+		if previous, err := os.ReadFile(outputPath); err == nil && compiledHash(string(previous)) == hash {
+			result.Unchanged = true
+			return result, nil
+		}
+	}
+
+	doc, usage, err := enrichContentWithUsage(&rollupConfig, strings.TrimSpace(notes.String()), limiter)
+	result.Usage = usage
+	if err != nil {
+		return result, err
+	}
+	doc = strings.TrimSpace(postProcess(config, doc))
+	year, week := start.ISOWeek()
+	frontmatter := fmt.Sprintf("---\ntitle: %q\ndate: %s\n---\n\n", trf("Обзор недели %d-W%02d", year, week), end.Format("2006-01-02"))
+	doc = withCompiledSources(frontmatter+doc, hash, renderCompiledSources(inputDir, outputPath, result.Sources))
+	if config.Disclosure {
+		doc = withDisclosure(doc, renderDisclosure(config.DisclosureTemplate, disclosureInfo{
+			Model:  rollupConfig.ModelName,
+			Date:   time.Now(),
+			Prompt: rollupConfig.Prompt,
+		}))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return result, withCategory(ErrorIO, errorf("ошибка при создании директории: %v", err))
+	}
+	if err := safeWriteFile(outputPath, []byte(doc), 0644); err != nil {
+		return result, withCategory(ErrorIO, err)
+	}
+	return result, nil
+}
+
+// Обзор недели после запуска службы: по воскресеньям пишется обзор текущей недели,
+// повторные запуски в тот же день обновляют его при изменении заметок
+func serviceWeeklyRollup(config *Config, now time.Time) {
+	if !config.DailyNotes.Enabled || !config.DailyNotes.Rollup || now.Weekday() != time.Sunday {
+		return
+	}
+	for _, rootConfig := range config.inputRoots() {
+		result, err := writeWeeklyRollup(rootConfig, now, false, config.newRateLimiter())
+		switch {
+		case err != nil:
+			warnf("Предупреждение: не удалось подготовить обзор недели: %v", err)
+		case !result.Unchanged:
+			infof("Обзор недели записан в %s (заметок: %d)", result.Output, len(result.Sources))
+		}
+	}
+}
+
+// rich rollup [--date ГГГГ-ММ-ДД] [--force]: обзор недели по дневниковым заметкам
+func runRollupCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("rollup", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	date := fs.String("date", "", tr("День недели обзора в формате ГГГГ-ММ-ДД (по умолчанию - прошедшая неделя)"))
+	force := fs.Bool("force", false, tr("Подготовить обзор заново, даже если заметки не изменились"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if !config.DailyNotes.Enabled {
+		return errorf("дневниковые заметки выключены: задайте enabled = true в секции [DAILY_NOTES]")
+	}
+	day := time.Now().AddDate(0, 0, -7)
+	if *date != "" {
+		if day, err = time.ParseInLocation("2006-01-02", *date, time.Local); err != nil {
+			return errorf("некорректная дата %q: ожидался формат ГГГГ-ММ-ДД", *date)
+		}
+	}
+
+	limiter := config.newRateLimiter()
+	var failed int
+	var spent float64
+	for _, rootConfig := range config.inputRoots() {
+		result, err := writeWeeklyRollup(rootConfig, day, *force, limiter)
+		if result != nil {
+			spent += result.Usage.Cost(config)
+		}
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, tr("Ошибка обзора недели: %v\n"), err)
+		case result.Unchanged:
+			fmt.Fprintf(out, tr("%s: заметки не изменились, обзор пропущен\n"), result.Output)
+		default:
+			fmt.Fprintf(out, tr("%s: обзор недели по заметкам %d\n"), result.Output, len(result.Sources))
+		}
+	}
+	if spent > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), spent)
+	}
+	if failed > 0 {
+		return errorf("не удалось подготовить обзоров недели: %d", failed)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadDailyNotesConfig(t *testing.T) {
+	load := func(text string) (DailyNotesConfig, error) {
+		t.Helper()
+		cfg, err := ini.Load([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loadDailyNotesConfig(cfg.Section("DAILY_NOTES"))
+	}
+
+	dc, err := load("[DAILY_NOTES]\nenabled = true\npaths = journal/**\n")
+	if err != nil || !dc.Enabled || dc.ContextDays != 3 || dc.RollupDir != "weekly" || len(dc.Paths) != 1 {
+		t.Fatalf("Настройки по умолчанию: %+v, %v", dc, err)
+	}
+	day := time.Date(2024, 5, 3, 0, 0, 0, 0, time.Local)
+	if date, ok := dc.noteDate("journal/2024-05-03.md", nil); !ok || !date.Equal(day) {
+		t.Errorf("Дата из имени файла: %v, %v", date, ok)
+	}
+	if date, ok := dc.noteDate("journal/today.md", Frontmatter{"date": "2024-05-03T21:30:00+03:00"}); !ok || !date.Equal(day) {
+		t.Errorf("Дата из frontmatter: %v, %v", date, ok)
+	}
+	for _, path := range []string{"blog/2024-05-03.md", "journal/notes.md"} {
+		if _, ok := dc.noteDate(path, nil); ok {
+			t.Errorf("%s не должна считаться дневниковой заметкой", path)
+		}
+	}
+
+	for _, text := range []string{
+		"[DAILY_NOTES]\ncontext_days = -1\n",
+		"[DAILY_NOTES]\ncontext_words = 0\n",
+		"[DAILY_NOTES]\nrollup_dir = ../weekly\n",
+		"[DAILY_NOTES]\npaths = !journal/**\n",
+	} {
+		if _, err := load(text); err == nil {
+			t.Errorf("Конфигурация %q должна возвращать ошибку", text)
+		}
+	}
+}
+
+func TestWeekBounds(t *testing.T) {
+	start, end := weekBounds(time.Date(2024, 5, 1, 15, 0, 0, 0, time.Local))
+	if start.Format("2006-01-02 Mon") != "2024-04-29 Mon" || end.Format("2006-01-02 Mon") != "2024-05-05 Sun" {
+		t.Errorf("Неделя 2024-05-01: %s - %s", start, end)
+	}
+	if got := weeklyRollupPath(DailyNotesConfig{RollupDir: "weekly"}, end); got != filepath.Join("weekly", "2024-W18.md") {
+		t.Errorf("Путь обзора недели: %s", got)
+	}
+}
+
+// Дневник из заметок с готовыми результатами; обрабатывается только заметка 2024-05-03
+func writeDailyNotes(t *testing.T, serverURL string) (configPath, inputDir, outputDir string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	inputDir = filepath.Join(tmpDir, "input")
+	outputDir = filepath.Join(tmpDir, "output")
+	for _, day := range []string{"2024-04-28", "2024-05-01", "2024-05-02", "2024-05-03"} {
+		for dir, content := range map[string]string{inputDir: "# " + day + "\n\nnote " + day, outputDir: "# " + day + "\n\nenriched-" + day + "\n\n```old\nnote " + day + "\n```"} {
+			if day == "2024-05-03" && dir == outputDir {
+				continue
+			}
+			path := filepath.Join(dir, "journal", day+".md")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	configPath = filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + serverURL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[EXCLUSIONS]\nexcluded_files = journal/2024-04-28.md, journal/2024-05-01.md, journal/2024-05-02.md\n" +
+		"[DAILY_NOTES]\nenabled = true\ncontext_days = 2\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configPath, inputDir, outputDir
+}
+
+func TestDailyNotesContext(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath, _, _ := writeDailyNotes(t, server.URL)
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if len(bodies) != 1 {
+		t.Fatalf("Ожидался один запрос к модели, получено %d", len(bodies))
+	}
+	body := bodies[0]
+	if !strings.Contains(body, "enriched-2024-05-01") || !strings.Contains(body, "enriched-2024-05-02") {
+		t.Errorf("В контексте нет заметок предыдущих дней: %s", body)
+	}
+	if strings.Contains(body, "enriched-2024-04-28") || strings.Contains(body, "```old") {
+		t.Errorf("Заметки вне окна context_days и оригиналы не добавляются в контекст: %s", body)
+	}
+}
+
+func TestRollupCommand(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		prompts = append(prompts, string(body))
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "## Итоги недели"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath, _, outputDir := writeDailyNotes(t, server.URL)
+	var out bytes.Buffer
+	if err := runRollupCommand([]string{"--config", configPath, "--date", "2024-05-01"}, &out); err != nil {
+		t.Fatalf("rich rollup вернул ошибку: %v\n%s", err, out.String())
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "weekly", "2024-W18.md"))
+	if err != nil || !strings.Contains(string(data), "## Итоги недели") || !strings.Contains(string(data), "date: 2024-05-05") {
+		t.Fatalf("Обзор недели: %q, %v", data, err)
+	}
+	// В обзор входят заметки недели: обогащенные и еще не обогащенная 2024-05-03
+	if len(prompts) != 1 || strings.Contains(prompts[0], "2024-04-28") || !strings.Contains(prompts[0], "enriched-2024-05-02") ||
+		!strings.Contains(prompts[0], "note 2024-05-03") {
+		t.Errorf("Запрос обзора недели: %v", prompts)
+	}
+
+	out.Reset()
+	if err := runRollupCommand([]string{"--config", configPath, "--date", "2024-05-05"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || !strings.Contains(out.String(), "обзор пропущен") {
+		t.Errorf("Обзор без изменений заметок не должен пересоздаваться: %s", out.String())
+	}
+	if err := runRollupCommand([]string{"--config", configPath, "--date", "2024-06-01"}, &out); err == nil {
+		t.Error("Неделя без заметок должна быть ошибкой")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Пара оригинал - результат для набора данных
+type datasetRecord struct {
+	Input  string      `json:"input"`
+	Output string      `json:"output"`
+	Meta   datasetMeta `json:"meta"`
+}
+
+// Сведения о результате из журнала запуска
+type datasetMeta struct {
+	// Путь исходного файла в общем состоянии
+	Path       string    `json:"path"`
+	RunID      string    `json:"run_id"`
+	EnrichedAt time.Time `json:"enriched_at"`
+	Model      string    `json:"model,omitempty"`
+	PromptHash string    `json:"prompt_hash,omitempty"`
+	// Результат изменен после обогащения (например, исправлен при проверке)
+	Edited bool `json:"edited,omitempty"`
+	// Оригинал взят из блока old результата (output) или из входного файла (input)
+	OriginalFrom string `json:"original_from"`
+	// Оценка проверяющего (accepted, rejected) и комментарий к ней
+	Label string `json:"label,omitempty"`
+	Note  string `json:"note,omitempty"`
+}
+
+// Фильтры выгрузки набора данных
+type datasetFilter struct {
+	// Только результаты модели ("" - все)
+	Model string
+	// Пропускать результаты, измененные после обогащения
+	SkipEdited bool
+	// Только результаты с оценкой проверяющего
+	Labeled bool
+	// Только результаты с указанной оценкой ("" - любые)
+	Label string
+}
+
+// Последние результаты обогащения из журналов запусков в виде пар оригинал -
+// результат с оценками проверяющих. Из результата убираются служебные разделы
+// (подпись, источники, связанные заметки, список изменений). Возвращает
+// количество записанных пар
+func exportDataset(config *Config, filter datasetFilter, w io.Writer) (int, error) {
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return 0, errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+	paths := make([]string, 0, len(latest))
+	for rel := range latest {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	labels, err := currentLabels(config.StateDir, latest)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	count := 0
+	for _, rel := range paths {
+		rec := latest[rel]
+		if filter.Model != "" && rec.Entry.Model != filter.Model {
+			continue
+		}
+		label := labels[rel]
+		if (filter.Labeled && label.Label == "") || (filter.Label != "" && label.Label != filter.Label) {
+			continue
+		}
+		record, ok := datasetRecordFor(config, rel, rec)
+		if !ok || (filter.SkipEdited && record.Meta.Edited) {
+			continue
+		}
+		record.Meta.Label, record.Meta.Note = label.Label, label.Note
+		if err := enc.Encode(record); err != nil {
+			return count, errorf("ошибка записи набора данных: %v", err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Пара для одного результата; false, если результат или оригинал недоступны
+func datasetRecordFor(config *Config, rel string, rec outputRecord) (datasetRecord, bool) {
+	data, err := os.ReadFile(rec.Entry.Output)
+	if err != nil {
+		logf("Пропуск %s: результат недоступен: %v", rel, err)
+		return datasetRecord{}, false
+	}
+	record := datasetRecord{Meta: datasetMeta{
+		Path:       rel,
+		RunID:      rec.RunID,
+		EnrichedAt: rec.At,
+		Model:      rec.Entry.Model,
+		PromptHash: rec.Entry.PromptHash,
+		Edited:     rec.Entry.OutputHash != "" && contentHash(data) != rec.Entry.OutputHash,
+	}}
+
+	enriched := string(data)
+	if prev, ok := parseEnrichedOutput(enriched); ok {
+		enriched, record.Input, record.Meta.OriginalFrom = prev.Enriched, prev.Original, "output"
+	} else {
+		rootConfig, rootRel := config.rootForKey(rel)
+		original, err := os.ReadFile(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rootRel)))
+		if err != nil {
+			logf("Пропуск %s: оригинал недоступен: %v", rel, err)
+			return datasetRecord{}, false
+		}
+		record.Input, record.Meta.OriginalFrom = string(original), "input"
+	}
+	for _, strip := range []func(string) string{stripDisclosure, stripRelated, stripChanges, stripCitations, stripSourceLink} {
+		enriched = strip(enriched)
+	}
+	record.Output = strings.TrimRight(enriched, "\n") + "\n"
+	return record, true
+}
+
+// rich export jsonl [--out file] [--model name] [--skip-edited] [--labeled]
+// [--label accepted|rejected]: пары оригинал - результат для наборов данных
+// дообучения и оценки
+func runExportJSONLCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export jsonl", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	outPath := fs.String("out", "", tr("Файл для записи (по умолчанию - стандартный вывод)"))
+	model := fs.String("model", "", tr("Только результаты указанной модели"))
+	skipEdited := fs.Bool("skip-edited", false, tr("Пропускать результаты, измененные после обогащения"))
+	labeled := fs.Bool("labeled", false, tr("Только результаты с оценкой проверяющего"))
+	label := fs.String("label", "", tr("Только результаты с указанной оценкой"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *label != "" && *label != LabelAccepted && *label != LabelRejected {
+		return errorf("неизвестная оценка %q: ожидалось accepted или rejected", *label)
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	filter := datasetFilter{Model: *model, SkipEdited: *skipEdited, Labeled: *labeled, Label: *label}
+
+	if *outPath == "" {
+		_, err := exportDataset(config, filter, out)
+		return err
+	}
+	f, err := os.Create(*outPath)
+	if err != nil {
+		return errorf("не удалось создать файл набора данных: %v", err)
+	}
+	bw := bufio.NewWriter(f)
+	count, err := exportDataset(config, filter, bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Набор данных сохранен в %s: пар %d\n"), *outPath, count)
+	return nil
+}
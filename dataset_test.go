@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportDataset(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"a.md": "# A\n\nОригинал A", "b.md": "# B\n\nОригинал B"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный <текст>"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelName: "test-model", ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Disclosure: true, DisclosureTemplate: "*Дополнено ИИ*"}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	// Исправленный при проверке результат отмечается как измененный
+	bPath := filepath.Join(outputDir, "b.md")
+	data, _ := os.ReadFile(bPath)
+	if err := os.WriteFile(bPath, []byte(strings.Replace(string(data), "Обогащенный", "Исправленный", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	count, err := exportDataset(config, datasetFilter{}, &buf)
+	if err != nil {
+		t.Fatalf("exportDataset() вернул ошибку: %v", err)
+	}
+	var records []datasetRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r datasetRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("некорректная строка JSONL %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	if count != 2 || len(records) != 2 {
+		t.Fatalf("ожидалось 2 пары, получено %d (%d)", len(records), count)
+	}
+	a := records[0]
+	if a.Input != "# A\n\nОригинал A" || a.Output != "Обогащенный <текст>\n" || a.Meta.Path != "a.md" ||
+		a.Meta.Model != "test-model" || a.Meta.RunID == "" || a.Meta.OriginalFrom != "output" || a.Meta.Edited {
+		t.Errorf("пара для a.md: %+v", a)
+	}
+	if !records[1].Meta.Edited || records[1].Output != "Исправленный <текст>\n" {
+		t.Errorf("пара для b.md: %+v", records[1])
+	}
+
+	buf.Reset()
+	if count, _ := exportDataset(config, datasetFilter{SkipEdited: true}, &buf); count != 1 {
+		t.Errorf("с пропуском измененных результатов ожидалась 1 пара, получено %d", count)
+	}
+	if count, _ := exportDataset(config, datasetFilter{Model: "other"}, &buf); count != 0 {
+		t.Errorf("для другой модели ожидалось 0 пар, получено %d", count)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Действия с похожими документами
+const (
+	// Только сообщить о похожих документах, обработать все
+	DedupReport = "report"
+	// Пропустить документы, похожие на уже обработанный
+	DedupSkip = "skip"
+)
+
+// Документ, почти совпадающий с другим документом запуска
+type duplicate struct {
+	// Относительный путь документа, который обрабатывается вместо этого
+	Of         string
+	Similarity float64
+}
+
+// Поиск почти одинаковых входных документов по косинусному сходству векторов.
+// Из группы похожих документов первый в порядке обработки считается основным,
+// остальные - его копиями; результат по относительным путям копий
+func findDuplicates(candidates []candidate, embedder Embedder, threshold float64) (map[string]duplicate, error) {
+	var docs []candidate
+	var texts []string
+	for _, c := range candidates {
+		// Документы других форматов конвертируются в markdown только при обработке
+		if !isMarkdownPath(c.Path) {
+			continue
+		}
+		content, err := os.ReadFile(c.Path)
+		if err != nil {
+			return nil, errorf("ошибка при чтении файла: %v", err)
+		}
+		// Метаданные frontmatter не учитываются: копии часто отличаются только ими
+		_, body, _ := parseFrontmatter(content)
+		if text := strings.TrimSpace(string(body)); text != "" {
+			docs = append(docs, c)
+			texts = append(texts, text)
+		}
+	}
+	if len(docs) < 2 {
+		return nil, nil
+	}
+
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return nil, errorf("ошибка при построении векторов документов: %v", err)
+	}
+
+	duplicates := map[string]duplicate{}
+	for i := range docs {
+		if _, ok := duplicates[docs[i].RelPath]; ok {
+			continue
+		}
+		for j := i + 1; j < len(docs); j++ {
+			if _, ok := duplicates[docs[j].RelPath]; ok {
+				continue
+			}
+			if sim := cosineSimilarity(vectors[i], vectors[j]); sim >= threshold {
+				duplicates[docs[j].RelPath] = duplicate{Of: docs[i].RelPath, Similarity: sim}
+				infof("Похожие документы: %s совпадает с %s на %.0f%%", normalizeRelPath(docs[j].RelPath), normalizeRelPath(docs[i].RelPath), sim*100)
+			}
+		}
+	}
+	return duplicates, nil
+}
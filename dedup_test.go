@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const dedupNote = "Настройка резервного копирования базы данных: ежедневные снимки, хранение тридцать дней, проверка восстановления раз в неделю."
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md": dedupNote,
+		"b.md": "---\ntitle: Копия\n---\n" + dedupNote,
+		"c.md": "Рецепт блинов: мука, молоко, яйца, щепотка соли и сахара.",
+	}
+	var candidates []candidate
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл: %v", err)
+		}
+		candidates = append(candidates, candidate{Path: path, RelPath: name})
+	}
+
+	duplicates, err := findDuplicates(candidates, localEmbedder{}, 0.95)
+	if err != nil {
+		t.Fatalf("findDuplicates() вернул ошибку: %v", err)
+	}
+	if len(duplicates) != 1 || duplicates["b.md"].Of != "a.md" || duplicates["b.md"].Similarity < 0.95 {
+		t.Errorf("Ожидалась одна копия b.md документа a.md, получено %+v", duplicates)
+	}
+}
+
+func TestProcessDirectorySkipsDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(dedupNote), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл: %v", err)
+		}
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	reportPath := filepath.Join(tmpDir, "report.json")
+	config := &Config{
+		InputDir:       inputDir,
+		OutputDir:      filepath.Join(tmpDir, "output"),
+		ModelAPIURL:    server.URL + "/v1/chat/completions",
+		ReportFile:     reportPath,
+		DedupThreshold: 0.95,
+		DedupAction:    DedupSkip,
+	}
+	if err := processDirectory(config, filepath.Join(tmpDir, "test.cfg")); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Копия документа не должна отправляться в API, запросов: %d", requests)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать отчет: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Некорректный отчет: %v", err)
+	}
+	skipped := false
+	for _, e := range report.Files {
+		if e.Path == "b.md" {
+			skipped = e.Status == StatusSkippedDuplicate && e.DuplicateOf == "a.md"
+		}
+	}
+	if !skipped {
+		t.Errorf("Копия должна быть пропущена со ссылкой на основной документ: %+v", report.Files)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Действия с результатом удаленного входного файла в режиме наблюдения (on_delete)
+const (
+	// Результат остается без изменений (по умолчанию)
+	OnDeleteKeep = "keep"
+	// В frontmatter результата записывается дата удаления исходного файла
+	OnDeleteMark = "mark"
+	// Результат переносится в корзину
+	OnDeleteTrash = "trash"
+)
+
+// Поле frontmatter результата, исходный файл которого удален (on_delete = mark)
+const SourceDeletedKey = "rich_source_deleted"
+
+// Формат имени поддиректории корзины: результаты, перенесенные в одну минуту
+const trashDirLayout = "2006-01-02T15-04"
+
+// Проверка действия с результатом удаленного входного файла
+func validateOnDelete(action string) error {
+	if action != OnDeleteKeep && action != OnDeleteMark && action != OnDeleteTrash {
+		return errorf("некорректное значение on_delete %q: ожидалось %s, %s или %s", action, OnDeleteKeep, OnDeleteMark, OnDeleteTrash)
+	}
+	return nil
+}
+
+// Директория корзины: trash_dir или trash в каталоге состояния
+func (c *Config) trashDir() string {
+	if c.TrashDir != "" {
+		return c.TrashDir
+	}
+	return filepath.Join(c.StateDir, "trash")
+}
+
+// Обработка результатов входных файлов, удаленных во время наблюдения: результат
+// помечается или переносится в корзину по on_delete, а файл убирается из списка
+// исключений и файла состояния, чтобы одноименный новый файл обогащался заново
+func handleDeletedInputs(config *Config, configPath string, keys []string, now time.Time) error {
+	var latest map[string]outputRecord
+	var manifest *stateManifest
+	if config.StateDir != "" {
+		var err error
+		if latest, err = latestOutputs(config.StateDir); err != nil {
+			return err
+		}
+		if manifest, err = loadStateManifest(config.StateDir); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		outputPath := recordedOutputPath(config, latest, key)
+		if _, err := os.Stat(outputPath); err == nil {
+			switch config.OnDelete {
+			case OnDeleteMark:
+				err = markSourceDeleted(outputPath, now)
+			case OnDeleteTrash:
+				var trashed string
+				trashed, err = moveToTrash(config, outputPath, key, now)
+				if err == nil {
+					infof("Результат удаленного файла %s перенесен в корзину: %s", key, trashed)
+				}
+			}
+			if err != nil {
+				warnf("Предупреждение: результат удаленного файла %s: %v", key, err)
+				continue
+			}
+		}
+		if err := removeFromExcludedFiles(configPath, key); err != nil {
+			return errorf("не удалось убрать %s из списка исключений: %v", key, err)
+		}
+		if manifest != nil {
+			manifest.Forget(key)
+		}
+		logf("Входной файл удален: %s", key)
+	}
+	if manifest != nil {
+		return manifest.Flush()
+	}
+	return nil
+}
+
+// Выходной файл входного файла: по последнему результату в журналах
+// запусков (учитывает маршруты), иначе - по выходной директории корня
+func recordedOutputPath(config *Config, latest map[string]outputRecord, key string) string {
+	if rec, ok := latest[normalizeRelPath(key)]; ok && rec.Entry.Output != "" {
+		return rec.Entry.Output
+	}
+	rootConfig, rel := config.rootForKey(key)
+	return resultPathFor(config, filepath.Join(rootConfig.OutputDir, filepath.FromSlash(rel)))
+}
+
+// Отметка об удалении исходного файла в frontmatter результата
+func markSourceDeleted(outputPath string, now time.Time) error {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+	marked := setFrontmatterField(string(data), SourceDeletedKey, now.Format("2006-01-02"))
+	return safeWriteFile(outputPath, []byte(marked), info.Mode().Perm())
+}
+
+// Перенос результата в корзину с сохранением пути файла: <корзина>/<время>/<путь>
+func moveToTrash(config *Config, outputPath, key string, now time.Time) (string, error) {
+	target := filepath.Join(config.trashDir(), now.Format(trashDirLayout), filepath.FromSlash(normalizeRelPath(key)))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := moveFile(outputPath, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
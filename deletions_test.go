@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleDeletedInputs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "notes/b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка\n\nТекст заметки"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n[PROCESSING]\non_delete = mark\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	for _, name := range []string{"a.md", "notes/b.md"} {
+		if err := os.Remove(filepath.Join(inputDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.Local)
+
+	// mark: результат остается с отметкой об удалении исходного файла
+	if err := handleDeletedInputs(config, configPath, []string{"a.md"}, now); err != nil {
+		t.Fatalf("handleDeletedInputs() вернул ошибку: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "a.md"))
+	if err != nil || !strings.Contains(string(data), SourceDeletedKey+": 2024-06-01") {
+		t.Errorf("Результат должен быть помечен: %s, %v", data, err)
+	}
+
+	// trash: результат переносится в корзину с сохранением пути
+	config.OnDelete = OnDeleteTrash
+	if err := handleDeletedInputs(config, configPath, []string{"notes/b.md"}, now); err != nil {
+		t.Fatalf("handleDeletedInputs() вернул ошибку: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "notes", "b.md")); !os.IsNotExist(err) {
+		t.Errorf("Результат должен быть убран из выходной директории: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".rich", "trash", "2024-06-01T10-00", "notes", "b.md")); err != nil {
+		t.Errorf("Результат не найден в корзине: %v", err)
+	}
+
+	// Файлы убраны из списка исключений и файла состояния
+	reloaded, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.ExcludedFiles) != 0 {
+		t.Errorf("Удаленные файлы должны быть убраны из списка исключений: %v", reloaded.ExcludedFiles)
+	}
+	manifest, err := loadStateManifest(config.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 0 {
+		t.Errorf("Удаленные файлы должны быть убраны из файла состояния: %v", manifest.Files)
+	}
+}
+
+func TestValidateOnDelete(t *testing.T) {
+	for _, action := range []string{OnDeleteKeep, OnDeleteMark, OnDeleteTrash} {
+		if err := validateOnDelete(action); err != nil {
+			t.Errorf("validateOnDelete(%q) вернул ошибку: %v", action, err)
+		}
+	}
+	if err := validateOnDelete("delete"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного действия")
+	}
+}
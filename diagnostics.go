@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// HTTP обработчик профилей net/http/pprof на отдельном мультиплексоре
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Запуск HTTP сервера профилирования /debug/pprof/; возвращает функцию остановки.
+// Профили раскрывают внутреннее состояние процесса, поэтому адрес лучше привязывать
+// к localhost
+func startPprofServer(addr string) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errorf("не удалось открыть адрес профилирования %s: %v", addr, err)
+	}
+	server := &http.Server{Handler: pprofHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logErrorf("Ошибка сервера профилирования: %v", err)
+		}
+	}()
+	infof("Профилирование доступно на http://%s/debug/pprof/", ln.Addr())
+	return func() { server.Close() }, nil
+}
+
+// Периодическая запись в журнал количества горутин и размера кучи; возвращает
+// функцию остановки, которая дожидается последней записи
+func startRuntimeStats(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logRuntimeStats()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Запись в журнал текущего состояния среды выполнения
+func logRuntimeStats() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	const mb = 1 << 20
+	logf("Состояние процесса: горутин %d, куча %.1f МБ (объектов %d), получено от ОС %.1f МБ, сборок мусора %d",
+		runtime.NumGoroutine(), float64(m.HeapAlloc)/mb, m.HeapObjects, float64(m.Sys)/mb, m.NumGC)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnostics(t *testing.T) {
+	server := httptest.NewServer(pprofHandler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("запрос профиля вернул ошибку: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "goroutine profile") {
+		t.Errorf("некорректный ответ профиля горутин: %d %.100s", resp.StatusCode, body)
+	}
+
+	var buf bytes.Buffer
+	oldOutput := log.Writer()
+	defer log.SetOutput(oldOutput)
+	log.SetOutput(&buf)
+	stop := startRuntimeStats(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	if !strings.Contains(buf.String(), "горутин") {
+		t.Errorf("состояние среды выполнения не записано в журнал: %q", buf.String())
+	}
+
+	opts, err := parseServiceOptions("service run", []string{"-pprof", "localhost:6060", "-runtime-stats", "5m"})
+	if err != nil || opts.PprofAddr != "localhost:6060" || opts.RuntimeStats != 5*time.Minute {
+		t.Errorf("параметры диагностики службы: %+v, %v", opts, err)
+	}
+}
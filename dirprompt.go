@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Имя файла с промптом для файлов директории и ее поддиректорий
+const DirPromptFileName = ".rich-prompt.md"
+
+// Режимы промпта директории (поле mode во frontmatter)
+const (
+	DirPromptExtend  = "extend"
+	DirPromptReplace = "replace"
+)
+
+// Промпт директории: дополняет промпт верхних уровней или заменяет его
+type dirPrompt struct {
+	Text    string
+	Replace bool
+}
+
+// Промпты директорий на пути от корня входной директории к файлу: сначала верхние
+func dirPromptLayers(inputDir, relPath string) []dirPrompt {
+	dirs := []string{"."}
+	rel := path.Dir(normalizeRelPath(relPath))
+	if rel != "." {
+		parts := strings.Split(rel, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+
+	var layers []dirPrompt
+	for _, dir := range dirs {
+		file := filepath.Join(inputDir, filepath.FromSlash(dir), DirPromptFileName)
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			warnf("Предупреждение: не удалось прочитать %s: %v", file, err)
+			continue
+		}
+		fm, body, _ := parseFrontmatter(data)
+		mode := strings.ToLower(strings.TrimSpace(fm["mode"]))
+		if mode != "" && mode != DirPromptExtend && mode != DirPromptReplace {
+			warnf("Предупреждение: неизвестный режим %q в %s, промпт будет дополнен", mode, file)
+		}
+		text := strings.TrimSpace(string(body))
+		if text == "" {
+			continue
+		}
+		layers = append(layers, dirPrompt{Text: text, Replace: mode == DirPromptReplace})
+	}
+	return layers
+}
+
+// Промпт с учетом промптов директорий
+func applyDirPrompts(prompt string, layers []dirPrompt) string {
+	for _, layer := range layers {
+		if layer.Replace || strings.TrimSpace(prompt) == "" {
+			prompt = layer.Text
+			continue
+		}
+		prompt = strings.TrimRight(prompt, "\n") + "\n\n" + layer.Text
+	}
+	return prompt
+}
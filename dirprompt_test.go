@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveFileConfigDirPrompts(t *testing.T) {
+	inputDir := t.TempDir()
+	files := map[string]string{
+		DirPromptFileName:                               "Пиши кратко.",
+		filepath.Join("api", DirPromptFileName):         "Добавь примеры запросов.",
+		filepath.Join("legal", DirPromptFileName):       "---\nmode: replace\n---\nНе меняй формулировки.",
+		filepath.Join("legal", "old", "placeholder.md"): "",
+	}
+	for name, content := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать файл: %v", err)
+		}
+	}
+	config := &Config{InputDir: inputDir, Prompt: "Обогати документ.", LanguagePrompts: map[string]string{"en": "Enrich the document."}}
+
+	tests := []struct {
+		relPath string
+		want    string
+	}{
+		{"readme.md", "Обогати документ.\n\nПиши кратко."},
+		{"api/v1/users.md", "Обогати документ.\n\nПиши кратко.\n\nДобавь примеры запросов."},
+		{"legal/old/terms.md", "Не меняй формулировки."},
+	}
+	for _, tt := range tests {
+		fileConfig, _, _ := resolveFileConfig(config, tt.relPath, []byte("Текст документа на русском языке."))
+		if fileConfig.Prompt != tt.want {
+			t.Errorf("%s: промпт %q, ожидался %q", tt.relPath, fileConfig.Prompt, tt.want)
+		}
+	}
+
+	// Промпт директории дополняет и языковой вариант, не изменяя общую конфигурацию
+	fileConfig, _, _ := resolveFileConfig(config, "api/intro.md", []byte("This is an English document about the API."))
+	if !strings.HasPrefix(fileConfig.Prompt, "Enrich the document.") || !strings.HasSuffix(fileConfig.Prompt, "Добавь примеры запросов.") {
+		t.Errorf("Языковой вариант не дополнен: %q", fileConfig.Prompt)
+	}
+	if config.LanguagePrompts["en"] != "Enrich the document." {
+		t.Errorf("Изменен общий языковой вариант: %q", config.LanguagePrompts["en"])
+	}
+}
+
+func TestCollectCandidatesSkipsDirPrompts(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, name := range []string{"a.md", DirPromptFileName} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Текст"), 0644); err != nil {
+			t.Fatalf("Не удалось создать файл: %v", err)
+		}
+	}
+	candidates, err := collectCandidates(&Config{}, inputDir, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("collectCandidates() вернул ошибку: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].RelPath != "a.md" {
+		t.Errorf("Ожидался только a.md, получено %+v", candidates)
+	}
+}
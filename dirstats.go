@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Файл накопленной статистики обработки по директориям в каталоге состояния
+const dirStatsFile = "dirstats.json"
+
+// Накопленная статистика одной директории за все запуски
+type dirStat struct {
+	// Обработок файлов: обогащено, пропущено, с ошибкой
+	Files    int `json:"files"`
+	Enriched int `json:"enriched"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	// Токены и стоимость запросов к API
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	// Запусков, обработавших файлы директории, и последний из них
+	Runs    int       `json:"runs"`
+	LastRun string    `json:"last_run,omitempty"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+// Доля обработок с ошибкой среди обработок с запросом к API
+func (s dirStat) FailureRate() float64 {
+	if s.Enriched+s.Failed == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Enriched+s.Failed)
+}
+
+// Суммирование статистики
+func (s *dirStat) add(other dirStat) {
+	s.Files += other.Files
+	s.Enriched += other.Enriched
+	s.Skipped += other.Skipped
+	s.Failed += other.Failed
+	s.PromptTokens += other.PromptTokens
+	s.CompletionTokens += other.CompletionTokens
+	s.CostUSD += other.CostUSD
+	s.Runs += other.Runs
+	if other.LastAt.After(s.LastAt) {
+		s.LastRun, s.LastAt = other.LastRun, other.LastAt
+	}
+}
+
+// Статистика запуска по директориям: в конце запуска добавляется к накопленной в файле
+type dirStats struct {
+	mu    sync.Mutex
+	path  string
+	runID string
+	dirs  map[string]*dirStat
+}
+
+// Статистика нового запуска с накоплением в каталоге состояния
+func newDirStats(stateDir, runID string) *dirStats {
+	return &dirStats{path: filepath.Join(stateDir, dirStatsFile), runID: runID, dirs: make(map[string]*dirStat)}
+}
+
+// Учет итога файла в статистике его директории
+func (d *dirStats) Record(key string, result *fileResult, err error, cost float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dir := path.Dir(normalizeRelPath(key))
+	s, ok := d.dirs[dir]
+	if !ok {
+		s = &dirStat{Runs: 1, LastRun: d.runID}
+		d.dirs[dir] = s
+	}
+	s.Files++
+	switch {
+	case err != nil:
+		s.Failed++
+	case result.Status == StatusEnriched || result.Status == StatusConflict:
+		s.Enriched++
+	default:
+		s.Skipped++
+	}
+	s.PromptTokens += result.Usage.PromptTokens
+	s.CompletionTokens += result.Usage.CompletionTokens
+	s.CostUSD += cost
+	s.LastAt = time.Now().UTC()
+}
+
+// Добавление статистики запуска к накопленной; файл обновляется под блокировкой,
+// чтобы одновременные запуски не теряли данные друг друга
+func (d *dirStats) Save() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.dirs) == 0 {
+		return nil
+	}
+	err := withFileLock(d.path, func() error {
+		total, err := readDirStats(d.path)
+		if err != nil {
+			return err
+		}
+		for dir, s := range d.dirs {
+			t := total[dir]
+			t.add(*s)
+			total[dir] = t
+		}
+		data, err := json.MarshalIndent(total, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+			return err
+		}
+		return safeWriteFile(d.path, data, 0644)
+	})
+	if err != nil {
+		return errorf("не удалось сохранить статистику директорий: %v", err)
+	}
+	d.dirs = make(map[string]*dirStat)
+	return nil
+}
+
+// Чтение накопленной статистики; без файла возвращается пустая статистика
+func readDirStats(file string) (map[string]dirStat, error) {
+	total := make(map[string]dirStat)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return total, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &total); err != nil {
+		return nil, errorf("поврежден файл статистики %s: %v", file, err)
+	}
+	return total, nil
+}
+
+// Статистика, сгруппированная по первым depth компонентам пути директории (0 - без группировки)
+func groupDirStats(stats map[string]dirStat, depth int) map[string]dirStat {
+	if depth <= 0 {
+		return stats
+	}
+	grouped := make(map[string]dirStat, len(stats))
+	for dir, s := range stats {
+		if parts := strings.Split(dir, "/"); len(parts) > depth {
+			dir = strings.Join(parts[:depth], "/")
+		}
+		g := grouped[dir]
+		// Запуски групп не суммируются: один запуск мог обработать несколько директорий группы
+		runs := max(g.Runs, s.Runs)
+		g.add(s)
+		g.Runs = runs
+		grouped[dir] = g
+	}
+	return grouped
+}
+
+// Порядок директорий в выводе rich stats --by-dir
+var dirStatsOrders = map[string]func(a, b dirStat) bool{
+	"cost":     func(a, b dirStat) bool { return a.CostUSD > b.CostUSD },
+	"tokens":   func(a, b dirStat) bool { return a.PromptTokens+a.CompletionTokens > b.PromptTokens+b.CompletionTokens },
+	"files":    func(a, b dirStat) bool { return a.Files > b.Files },
+	"failures": func(a, b dirStat) bool { return a.FailureRate() > b.FailureRate() },
+}
+
+// rich stats [--by-dir] [--depth N] [--sort cost|tokens|files|failures] [--json]:
+// накопленная за все запуски статистика обработки, итого или по директориям
+func runStatsCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	byDir := fs.Bool("by-dir", false, tr("Статистика по директориям"))
+	depth := fs.Int("depth", 0, tr("Группировать директории по первым N компонентам пути (0 - без группировки)"))
+	order := fs.String("sort", "cost", tr("Порядок директорий: cost, tokens, files или failures"))
+	asJSON := fs.Bool("json", false, tr("Вывод в формате JSON"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	less, ok := dirStatsOrders[*order]
+	if !ok {
+		return errorf("некорректный порядок %q: ожидалось cost, tokens, files или failures", *order)
+	}
+	if *depth < 0 {
+		return errorf("--depth не может быть отрицательным: %d", *depth)
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	stats, err := readDirStats(filepath.Join(config.StateDir, dirStatsFile))
+	if err != nil {
+		return errorf("не удалось прочитать статистику директорий: %v", err)
+	}
+	if !*byDir {
+		var total dirStat
+		for _, s := range stats {
+			total.add(s)
+		}
+		stats = map[string]dirStat{"": total}
+	} else {
+		stats = groupDirStats(stats, *depth)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if !*byDir {
+			return enc.Encode(stats[""])
+		}
+		return enc.Encode(stats)
+	}
+	if !*byDir {
+		s := stats[""]
+		fmt.Fprintf(out, tr("Обработок файлов: %d (обогащено %d, пропущено %d, с ошибкой %d)\n"), s.Files, s.Enriched, s.Skipped, s.Failed)
+		fmt.Fprintf(out, tr("Токены: %d входных, %d выходных; стоимость: $%.4f\n"), s.PromptTokens, s.CompletionTokens, s.CostUSD)
+		fmt.Fprintf(out, tr("Доля ошибок: %.1f%%\n"), 100*s.FailureRate())
+		return nil
+	}
+	if len(stats) == 0 {
+		fmt.Fprintln(out, tr("Статистика пока не накоплена"))
+		return nil
+	}
+
+	dirs := make([]string, 0, len(stats))
+	for dir := range stats {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		a, b := stats[dirs[i]], stats[dirs[j]]
+		if less(a, b) != less(b, a) {
+			return less(a, b)
+		}
+		return dirs[i] < dirs[j]
+	})
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, tr("ДИРЕКТОРИЯ\tФАЙЛЫ\tОШИБКИ\tТОКЕНЫ\tСТОИМОСТЬ\tЗАПУСКИ"))
+	for _, dir := range dirs {
+		s := stats[dir]
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%d\t$%.4f\t%d\n", dir, s.Files, 100*s.FailureRate(),
+			s.PromptTokens+s.CompletionTokens, s.CostUSD, s.Runs)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirStats(t *testing.T) {
+	stateDir := t.TempDir()
+	for _, runID := range []string{"run-1", "run-2"} {
+		d := newDirStats(stateDir, runID)
+		d.Record("docs/api/a.md", &fileResult{Status: StatusEnriched, Usage: Usage{PromptTokens: 100, CompletionTokens: 50}}, nil, 0.5)
+		d.Record("docs/api/b.md", &fileResult{Status: StatusFailed}, errors.New("ошибка"), 0)
+		d.Record("docs/guide/c.md", &fileResult{Status: StatusSkippedTooSmall}, nil, 0)
+		d.Record("readme.md", &fileResult{Status: StatusEnriched, Usage: Usage{PromptTokens: 10}}, nil, 0.1)
+		if err := d.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := readDirStats(filepath.Join(stateDir, dirStatsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := stats["docs/api"]
+	if api.Files != 4 || api.Enriched != 2 || api.Failed != 2 || api.PromptTokens != 200 || api.Runs != 2 || api.LastRun != "run-2" {
+		t.Errorf("Статистика docs/api: %+v", api)
+	}
+	if api.FailureRate() != 0.5 || stats["."].CostUSD < 0.19 || stats["docs/guide"].Skipped != 2 {
+		t.Errorf("Статистика: %+v", stats)
+	}
+
+	grouped := groupDirStats(stats, 1)
+	docs := grouped["docs"]
+	if len(grouped) != 2 || docs.Files != 6 || docs.Runs != 2 {
+		t.Errorf("Группировка по первому компоненту: %+v", grouped)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}], "usage": {"prompt_tokens": 1000, "completion_tokens": 500}}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	for _, name := range []string{"api/a.md", "api/b.md", "guide/c.md"} {
+		path := filepath.Join(inputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# "+name+"\n\nТекст заметки"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\ninput_price = 1\noutput_price = 2\n[STATE]\ndir = " + filepath.Join(tmpDir, "state") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runStatsCommand([]string{"--config", configPath, "--by-dir"}, &out); err != nil {
+		t.Fatalf("rich stats вернул ошибку: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "api ") || !strings.Contains(lines[1], "$0.0040") || !strings.HasPrefix(lines[2], "guide ") {
+		t.Errorf("Директории должны выводиться по убыванию стоимости:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runStatsCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "3") || !strings.Contains(out.String(), "$0.0060") {
+		t.Errorf("Итоговая статистика:\n%s", out.String())
+	}
+	if err := runStatsCommand([]string{"--config", configPath, "--by-dir", "--sort", "name"}, &out); err == nil {
+		t.Error("Некорректный порядок должен быть ошибкой")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Маркеры блока раскрытия использования ИИ, блок заменяется при повторной обработке
+const (
+	DisclosureStartMarker = "<!-- rich:disclosure -->"
+	DisclosureEndMarker   = "<!-- rich:disclosure-end -->"
+)
+
+// Шаблон подписи по умолчанию
+const defaultDisclosureTemplate = "*Документ дополнен с помощью ИИ: модель {{model}}, {{date}}, версия промпта {{prompt_hash}}.*"
+
+// Данные для подстановки в шаблон подписи
+type disclosureInfo struct {
+	Model    string
+	Date     time.Time
+	Prompt   string
+	Route    string
+	Language string
+	RunID    string
+	// Сведения о файле для остальных подстановок ({{path}}, {{fm.<поле>}})
+	File fileContext
+}
+
+// Короткий хэш промпта для отслеживания его версий
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Формирование подписи по шаблону: {{model}}, {{date}}, {{prompt_hash}}, {{route}}, {{language}}, {{run_id}}
+// и подстановки сведений о файле
+func renderDisclosure(template string, info disclosureInfo) string {
+	if template == "" {
+		template = defaultDisclosureTemplate
+	}
+	text := strings.NewReplacer(
+		"{{model}}", info.Model,
+		"{{date}}", info.Date.Format("2006-01-02"),
+		"{{prompt_hash}}", promptHash(info.Prompt),
+		"{{route}}", info.Route,
+		"{{language}}", info.Language,
+		"{{run_id}}", info.RunID,
+	).Replace(template)
+	return info.File.Expand(text)
+}
+
+// Удаление ранее добавленной подписи
+func stripDisclosure(text string) string {
+	start := strings.LastIndex(text, DisclosureStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], DisclosureEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(DisclosureEndMarker)
+	return strings.TrimRight(text[:start], "\n") + text[end:]
+}
+
+// Добавление подписи в конец обогащенного документа (с заменой предыдущей)
+func withDisclosure(text, footer string) string {
+	text = strings.TrimRight(stripDisclosure(text), "\n")
+	return text + "\n\n" + DisclosureStartMarker + "\n" + strings.TrimSpace(footer) + "\n" + DisclosureEndMarker + "\n"
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDisclosure(t *testing.T) {
+	info := disclosureInfo{
+		Model:    "gpt-4o",
+		Date:     time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC),
+		Prompt:   "Дополни заметку",
+		Route:    "technical",
+		Language: "ru",
+	}
+	got := renderDisclosure("{{model}} {{date}} {{prompt_hash}} {{route}} {{language}}", info)
+	expected := "gpt-4o 2024-05-17 " + promptHash("Дополни заметку") + " technical ru"
+	if got != expected {
+		t.Errorf("Некорректная подпись: %q, ожидалось %q", got, expected)
+	}
+	if len(promptHash("x")) != 12 || promptHash("x") == promptHash("y") {
+		t.Error("Некорректный хэш промпта")
+	}
+	if def := renderDisclosure("", info); !strings.Contains(def, "gpt-4o") || !strings.Contains(def, "2024-05-17") {
+		t.Errorf("Шаблон по умолчанию не содержит модель и дату: %q", def)
+	}
+}
+
+func TestWithDisclosureReplacesPrevious(t *testing.T) {
+	text := withDisclosure("# Заметка\n\nТекст\n", "Подпись 1")
+	expected := "# Заметка\n\nТекст\n\n" + DisclosureStartMarker + "\nПодпись 1\n" + DisclosureEndMarker + "\n"
+	if text != expected {
+		t.Errorf("Некорректный документ с подписью:\n%q\nожидалось\n%q", text, expected)
+	}
+
+	again := withDisclosure(text, "Подпись 2")
+	if strings.Count(again, DisclosureStartMarker) != 1 || !strings.Contains(again, "Подпись 2") || strings.Contains(again, "Подпись 1") {
+		t.Errorf("Подпись должна заменяться, а не дублироваться:\n%s", again)
+	}
+	if stripDisclosure(again) != "# Заметка\n\nТекст\n" {
+		t.Errorf("Некорректное удаление подписи: %q", stripDisclosure(again))
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Допустимое расхождение локальных часов с часами сервера API
+const maxClockSkew = 5 * time.Minute
+
+// Результаты проверок
+const (
+	checkOK   = "OK"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// Результат одной проверки самодиагностики
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+	// Подсказка по устранению проблемы
+	Hint string
+}
+
+// Самодиагностика: директории, права на запись, доступность API, ключ и часы
+func runDoctor(config *Config, configPath string) []doctorCheck {
+	var checks []doctorCheck
+
+	// Входная директория
+	if info, err := os.Stat(config.InputDir); err != nil || !info.IsDir() {
+		checks = append(checks, doctorCheck{tr("Входная директория"), checkFail, config.InputDir,
+			tr("создайте директорию или исправьте input_dir в секции [DIRECTORIES]")})
+	} else {
+		checks = append(checks, doctorCheck{Name: tr("Входная директория"), Status: checkOK, Detail: config.InputDir})
+	}
+
+	// Права на запись: результаты, состояние, конфигурация (excluded_files), отчет
+	writable := []struct{ name, dir string }{
+		{tr("Запись в выходную директорию"), config.OutputDir},
+		{tr("Запись в директорию конфигурации"), filepath.Dir(configPath)},
+	}
+	if config.StateDir != "" {
+		writable = append(writable, struct{ name, dir string }{tr("Запись в каталог состояния"), config.StateDir})
+	}
+	if config.ReportFile != "" {
+		writable = append(writable, struct{ name, dir string }{tr("Запись отчета"), filepath.Dir(config.ReportFile)})
+	}
+	for _, w := range writable {
+		if err := checkWritable(w.dir); err != nil {
+			checks = append(checks, doctorCheck{w.name, checkFail, err.Error(),
+				tr("проверьте права доступа к директории или укажите другой путь в конфигурации")})
+		} else {
+			checks = append(checks, doctorCheck{Name: w.name, Status: checkOK, Detail: w.dir})
+		}
+	}
+
+	// Программа pandoc для документов других форматов
+	if config.Pandoc.Enabled {
+		if path, err := exec.LookPath(config.Pandoc.Binary); err != nil {
+			checks = append(checks, doctorCheck{"pandoc", checkWarn, err.Error(),
+				tr("установите pandoc или укажите путь ключом binary секции [PANDOC]")})
+		} else {
+			checks = append(checks, doctorCheck{Name: "pandoc", Status: checkOK, Detail: path})
+		}
+	}
+
+	// Программа hunspell для проверки орфографии
+	if config.Spellcheck.Enabled {
+		if path, err := exec.LookPath(config.Spellcheck.Binary); err != nil {
+			checks = append(checks, doctorCheck{"hunspell", checkWarn, err.Error(),
+				tr("установите hunspell и словари языков или укажите путь ключом binary секции [SPELLCHECK]")})
+		} else {
+			checks = append(checks, doctorCheck{Name: "hunspell", Status: checkOK, Detail: path})
+		}
+	}
+
+	// Доступность API и расхождение часов по заголовку Date ответа сервера
+	serverTime, err := probeAPI(config.Network, config.ModelAPIURL)
+	if err != nil {
+		checks = append(checks, doctorCheck{tr("Доступность API"), checkFail, err.Error(),
+			tr("проверьте api_url в секции [MODEL], подключение к сети и настройки прокси (HTTPS_PROXY)")})
+		return checks
+	}
+	checks = append(checks, doctorCheck{Name: tr("Доступность API"), Status: checkOK, Detail: config.ModelAPIURL})
+	checks = append(checks, checkClockSkew(serverTime, time.Now()))
+
+	// Проверка ключа минимальным запросом
+	checks = append(checks, checkAPIKey(config))
+	return checks
+}
+
+// Проверка возможности создать файл в директории
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".rich-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Запрос к серверу API (любой HTTP ответ означает доступность); возвращает время сервера
+func probeAPI(network networkConfig, apiURL string) (time.Time, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return time.Time{}, errorf("некорректный api_url: %q", apiURL)
+	}
+	client := network.client(10 * time.Second)
+	req, err := http.NewRequest("GET", u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return time.Time{}, errorf("некорректный api_url: %q", apiURL)
+	}
+	setUserAgent(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, errorf("сервер недоступен: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return serverTime, nil
+}
+
+// Проверка расхождения локальных часов с часами сервера
+func checkClockSkew(serverTime, now time.Time) doctorCheck {
+	if serverTime.IsZero() {
+		return doctorCheck{tr("Системные часы"), checkWarn, tr("сервер не сообщил время (заголовок Date)"), ""}
+	}
+	skew := now.Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	detail := trf("расхождение с сервером %s", skew.Round(time.Second))
+	if skew > maxClockSkew {
+		return doctorCheck{tr("Системные часы"), checkFail, detail,
+			tr("синхронизируйте часы (NTP): большое расхождение нарушает TLS и фильтры по дате изменения")}
+	}
+	return doctorCheck{Name: tr("Системные часы"), Status: checkOK, Detail: detail}
+}
+
+// Проверка ключа и имени модели минимальным запросом к API
+func checkAPIKey(config *Config) doctorCheck {
+	name := tr("Ключ API и модель")
+	probe := *config
+	probe.Prompt = "Reply with the single word OK."
+	probe.MaxTokens = 16
+	probe.AutoMaxTokens = false
+	probe.Examples = nil
+	_, usage, err := enrichContentWithUsage(&probe, "ping", NewRateLimiter(RequestsPerMinute))
+	if err == nil {
+		return doctorCheck{Name: name, Status: checkOK, Detail: trf("модель %s ответила (%d токенов)", config.ModelName, usage.PromptTokens+usage.CompletionTokens)}
+	}
+
+	hint := tr("проверьте параметры секции [MODEL]")
+	msg := err.Error()
+	var statusErr *apiStatusError
+	status := 0
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode
+	}
+	switch {
+	case config.provider() != nil && config.provider().accessToken != nil:
+		hint = tr("проверьте учетные данные Google: credentials_file, GOOGLE_APPLICATION_CREDENTIALS или gcloud auth application-default login, а также project и location")
+	case config.APIKey == "" && config.provider() != nil && config.provider().KeyEnv != "":
+		hint = trf("ключ не задан: укажите api_key, api_key_env или переменную окружения %s", config.provider().KeyEnv)
+	case config.APIKey == "":
+		hint = tr("ключ не задан: укажите api_key или api_key_env (провайдер не определен по api_url, его можно задать параметром provider)")
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		hint = tr("ключ отклонен провайдером: проверьте api_key и права ключа")
+	case status == http.StatusNotFound, strings.Contains(msg, "model"):
+		hint = tr("проверьте имя модели: список доступных моделей выводит rich models")
+	case status == http.StatusTooManyRequests:
+		hint = tr("превышен лимит запросов или исчерпана квота провайдера")
+	}
+	return doctorCheck{name, checkFail, msg, hint}
+}
+
+// rich doctor: самодиагностика с подсказками по устранению проблем
+func runDoctorCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(out, tr("[%s] Конфигурация: %v\n"), checkFail, err)
+		return errorf("конфигурация не загружена")
+	}
+	fmt.Fprintf(out, tr("[%s] Конфигурация: %s\n"), checkOK, *configPath)
+
+	failed := 0
+	for _, c := range runDoctor(config, *configPath) {
+		line := fmt.Sprintf("[%s] %s", c.Status, c.Name)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Fprintln(out, line)
+		if c.Hint != "" {
+			fmt.Fprintf(out, "       -> %s\n", c.Hint)
+		}
+		if c.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errorf("не пройдено проверок: %d", failed)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunDoctor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer good_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "OK"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "in")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Ошибка создания директории: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	writeConfig := func(key string) {
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "out") +
+			"\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") +
+			"\n[REPORT]\nfile = " + filepath.Join(tmpDir, "report.json") +
+			"\n[MODEL]\nname = test-model\napi_url = " + server.URL + "/v1/chat/completions\napi_key = " + key + "\n"
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatalf("Ошибка записи конфигурации: %v", err)
+		}
+	}
+
+	writeConfig("good_key")
+	var out bytes.Buffer
+	if err := runDoctorCommand([]string{"-config", configPath}, &out); err != nil {
+		t.Fatalf("doctor вернул ошибку при корректной конфигурации: %v\n%s", err, out.String())
+	}
+	if strings.Contains(out.String(), checkFail) {
+		t.Errorf("Все проверки должны пройти:\n%s", out.String())
+	}
+
+	writeConfig("bad_key")
+	out.Reset()
+	if err := runDoctorCommand([]string{"-config", configPath}, &out); err == nil {
+		t.Fatalf("doctor должен вернуть ошибку при неверном ключе:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[FAIL] Ключ API и модель") || !strings.Contains(out.String(), "ключ отклонен") {
+		t.Errorf("Нет проверки ключа с подсказкой:\n%s", out.String())
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if c := checkClockSkew(now.Add(-30*time.Second), now); c.Status != checkOK {
+		t.Errorf("Небольшое расхождение должно проходить проверку: %+v", c)
+	}
+	if c := checkClockSkew(now.Add(10*time.Minute), now); c.Status != checkFail || c.Hint == "" {
+		t.Errorf("Большое расхождение должно давать ошибку с подсказкой: %+v", c)
+	}
+	if c := checkClockSkew(time.Time{}, now); c.Status != checkWarn {
+		t.Errorf("Неизвестное время сервера - предупреждение: %+v", c)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Строк сравнения одного файла в выводе -dry-run -diff
+const dryRunDiffLines = 60
+
+// Параметры пробного запуска (-dry-run)
+type dryRunOptions struct {
+	// Обогатить заново файлы, у которых уже есть результат, и сравнить с текущим результатом
+	Diff bool
+	// Директория файлов сравнения ("" - сравнение только выводится)
+	DiffDir string
+}
+
+// Входной файл, у которого уже есть результат
+type existingOutput struct {
+	// Путь в общем состоянии запуска
+	Key string
+	// Текущий результат
+	Output string
+}
+
+// Входные файлы всех корней, у которых уже есть результат, включая файлы из списка
+// исключений. Учитываются фильтры запуска (-modified-after, -shard, .richignore)
+func filesWithOutputs(config *Config) ([]existingOutput, error) {
+	var latest map[string]outputRecord
+	if config.StateDir != "" {
+		var err error
+		if latest, err = latestOutputs(config.StateDir); err != nil {
+			return nil, err
+		}
+	}
+	var found []existingOutput
+	for _, rootConfig := range config.inputRoots() {
+		inputDir, err := filepath.Abs(rootConfig.InputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+		cands, err := collectCandidates(rootConfig, inputDir, rootConfig.OutputDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cands {
+			key := rootKey(rootConfig.RootName, normalizeRelPath(c.RelPath))
+			output := recordedOutputPath(config, latest, key)
+			if info, err := os.Stat(output); err == nil && !info.IsDir() {
+				found = append(found, existingOutput{Key: key, Output: output})
+			}
+		}
+	}
+	return found, nil
+}
+
+// Пробный запуск: без -diff выводятся файлы, которые будут обработаны, запросы к API
+// не отправляются. С -diff файлы, у которых уже есть результат, обогащаются заново во
+// временную директорию, и новое обогащение сравнивается с текущим результатом.
+// Выходная директория, список исключений и каталог состояния не изменяются
+func runDryRun(config *Config, opts dryRunOptions, out io.Writer) error {
+	if config.InputSource != "" {
+		return withCategory(ErrorConfig, errorf("-dry-run работает только с входной директорией input_dir, а не с input_source"))
+	}
+	if !opts.Diff {
+		pending, err := pendingFiles(config)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, tr("Файлы для обработки: %d\n"), len(pending))
+		for _, c := range pending {
+			fmt.Fprintf(out, "  %s\n", c.RelPath)
+		}
+		fmt.Fprintln(out, tr("Запросы к API не отправлялись, файлы не изменены"))
+		return nil
+	}
+
+	targets, err := filesWithOutputs(config)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(out, tr("Файлов с результатами для сравнения нет"))
+		return nil
+	}
+	scratch, err := os.MkdirTemp("", "rich-diff-")
+	if err != nil {
+		return withCategory(ErrorIO, errorf("не удалось создать временную директорию: %v", err))
+	}
+	defer os.RemoveAll(scratch)
+
+	keys := make([]string, len(targets))
+	for i, t := range targets {
+		keys[i] = t.Key
+	}
+	dc := sampleConfig(config, filepath.Join(scratch, "output"), keys)
+	dc.ExcludedFiles = nil
+	dc.ReportFile = ""
+	dc.ChecksumFile = ""
+
+	// Новые результаты по путям в общем состоянии
+	var mu sync.Mutex
+	fresh := make(map[string]string, len(targets))
+	observeStatusEvents(func(ev statusEvent) {
+		if ev.Event == EventFileFinished && ev.Status == StatusEnriched && ev.Output != "" {
+			mu.Lock()
+			fresh[pathKey(ev.Path)] = ev.Output
+			mu.Unlock()
+		}
+	})
+	infof("Сравнение с текущими результатами: %d файлов", len(targets))
+	runErr := processDirectory(dc, filepath.Join(scratch, "rich.cfg"))
+	observeStatusEvents(nil)
+
+	changed, same, missing := 0, 0, 0
+	for _, t := range targets {
+		next, ok := fresh[pathKey(t.Key)]
+		if !ok {
+			missing++
+			fmt.Fprintf(out, tr("%s: новое обогащение не получено\n"), t.Key)
+			continue
+		}
+		current, err := readMarkdownFile(t.Output)
+		if err != nil {
+			return errorf("ошибка при чтении файла: %v", err)
+		}
+		enriched, err := readMarkdownFile(next)
+		if err != nil {
+			return errorf("ошибка при чтении файла: %v", err)
+		}
+		if string(current) == string(enriched) {
+			same++
+			fmt.Fprintf(out, tr("%s: без изменений\n"), t.Key)
+			continue
+		}
+		changed++
+		fmt.Fprintf(out, "\n=== %s (%s)\n%s\n", t.Key, t.Output, formatLineDiff(string(current), string(enriched), dryRunDiffLines))
+		if opts.DiffDir != "" {
+			path := filepath.Join(opts.DiffDir, filepath.FromSlash(t.Key)+".diff")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return withCategory(ErrorIO, errorf("не удалось создать директорию %s: %v", filepath.Dir(path), err))
+			}
+			if err := safeWriteFile(path, []byte(formatLineDiff(string(current), string(enriched), math.MaxInt)), 0644); err != nil {
+				return withCategory(ErrorIO, errorf("не удалось сохранить сравнение %s: %v", path, err))
+			}
+		}
+	}
+	fmt.Fprintf(out, tr("Изменится результатов: %d, без изменений: %d, без нового обогащения: %d\n"), changed, same, missing)
+	if opts.DiffDir != "" && changed > 0 {
+		fmt.Fprintf(out, tr("Сравнения сохранены в %s\n"), opts.DiffDir)
+	}
+	fmt.Fprintln(out, tr("Файлы не изменены"))
+	return runErr
+}
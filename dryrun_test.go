@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Пробный запуск со сравнением обогащает заново только файлы с результатами и не
+// изменяет выходную директорию и список исключений
+func TestDryRunDiff(t *testing.T) {
+	prev := statusEvents
+	t.Cleanup(func() { statusEvents = prev })
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Новый заголовок\n\nТекст"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	for _, dir := range []string{inputDir, outputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, text := range map[string]string{"published.md": "# Заметка\n\nТекст", "draft.md": "# Черновик\n\nТекст"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	published := "# Старый заголовок\n\nТекст\n\n```old\n# Заметка\n\nТекст\n```"
+	outputPath := filepath.Join(outputDir, "published.md")
+	if err := os.WriteFile(outputPath, []byte(published), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[EXCLUSIONS]\nexcluded_files = published.md\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Без -diff только список файлов для обработки
+	var out bytes.Buffer
+	if err := runDryRun(config, dryRunOptions{}, &out); err != nil {
+		t.Fatalf("runDryRun() вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "draft.md") || strings.Contains(out.String(), "published.md") || calls.Load() != 0 {
+		t.Errorf("Ожидался список необработанных файлов без запросов к API (%d):\n%s", calls.Load(), out.String())
+	}
+
+	out.Reset()
+	diffDir := filepath.Join(tmpDir, "diffs")
+	if err := runDryRun(config, dryRunOptions{Diff: true, DiffDir: diffDir}, &out); err != nil {
+		t.Fatalf("runDryRun() с -diff вернул ошибку: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Ожидался запрос к API только для файла с результатом, получено %d", calls.Load())
+	}
+	for _, line := range []string{"- # Старый заголовок", "+ # Новый заголовок", "published.md"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("В сравнении нет строки %q:\n%s", line, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "draft.md") {
+		t.Errorf("Файл без результата не должен сравниваться:\n%s", out.String())
+	}
+	saved, err := os.ReadFile(filepath.Join(diffDir, "published.md.diff"))
+	if err != nil || !strings.Contains(string(saved), "+ # Новый заголовок") {
+		t.Errorf("Сравнение не сохранено: %q, %v", saved, err)
+	}
+
+	if data, _ := os.ReadFile(outputPath); string(data) != published {
+		t.Errorf("Результат не должен изменяться:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "draft.md")); err == nil {
+		t.Error("Необработанный файл не должен обрабатываться")
+	}
+	reloaded, _ := loadConfig(configPath)
+	if strings.Join(reloaded.ExcludedFiles, ",") != "published.md" {
+		t.Errorf("Список исключений не должен изменяться: %v", reloaded.ExcludedFiles)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Максимум ошибок, перечисляемых в письме
+const digestMaxFailures = 20
+
+// Настройки рассылки итогов запуска по почте из секции [EMAIL]
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Отправлять письмо только при ошибках обработки
+	OnlyOnFailure bool
+	// Адрес, по которому получатели открывают отчет (например, опубликованный сайт)
+	ReportURL string
+}
+
+// Рассылка включена, если заданы сервер и получатели
+func (e EmailConfig) enabled() bool {
+	return e.Host != "" && len(e.To) > 0
+}
+
+// Тема и текст письма с итогами запуска
+func buildDigest(config *Config, report *runReport) (string, string) {
+	t := report.Totals
+	run := report.RunID
+	if run == "" {
+		run = report.StartedAt.Format(time.DateTime)
+	}
+	subject := trf("rich: запуск %s - обогащено %d, ошибок %d", run, t.Enriched, t.Failed)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, tr("Запуск %s\nНачат: %s, завершен: %s\n\n"), run, report.StartedAt.Format(time.DateTime), report.FinishedAt.Format(time.DateTime))
+	fmt.Fprintf(&b, tr("Файлов: %d, обогащено: %d, пропущено: %d, ошибок: %d\n"), t.Files, t.Enriched, t.Skipped, t.Failed)
+	fmt.Fprintf(&b, tr("Токены: %d входных, %d выходных; стоимость: $%.4f\n"), t.PromptTokens, t.CompletionTokens, t.CostUSD)
+
+	if t.Failed > 0 {
+		b.WriteString(tr("\nОшибки:\n"))
+		shown := 0
+		for _, e := range report.Files {
+			if e.Status != StatusFailed {
+				continue
+			}
+			if shown == digestMaxFailures {
+				fmt.Fprintf(&b, tr("- и еще %d\n"), t.Failed-shown)
+				break
+			}
+			fmt.Fprintf(&b, "- %s: %s\n", e.Path, e.Error)
+			shown++
+		}
+	}
+
+	b.WriteString("\n")
+	if config.Email.ReportURL != "" {
+		fmt.Fprintf(&b, tr("Отчет: %s\n"), config.Email.ReportURL)
+	}
+	if config.ReportFile != "" {
+		path, err := filepath.Abs(config.ReportFile)
+		if err != nil {
+			path = config.ReportFile
+		}
+		fmt.Fprintf(&b, tr("JSON отчет: %s\n"), path)
+	}
+	if report.RunID != "" {
+		fmt.Fprintf(&b, tr("Подробности: rich status --run %s\n"), report.RunID)
+	}
+	return subject, b.String()
+}
+
+// Письмо в формате RFC 5322: тема в кодировке MIME, текст в base64
+func formatEmail(from string, to []string, subject, body string, now time.Time) []byte {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	host := "rich.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		host = from[at+1:]
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: <" + hex.EncodeToString(id) + "@" + host + ">\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return []byte(b.String())
+}
+
+// Отправка письма: порт 465 - TLS с самого начала, остальные - STARTTLS,
+// если сервер его поддерживает
+func sendEmail(ec EmailConfig, msg []byte) error {
+	addr := net.JoinHostPort(ec.Host, strconv.Itoa(ec.Port))
+	tlsConfig := &tls.Config{ServerName: ec.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if ec.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return errorf("не удалось подключиться к SMTP серверу %s: %v", addr, err)
+	}
+	client, err := smtp.NewClient(conn, ec.Host)
+	if err != nil {
+		conn.Close()
+		return errorf("ошибка SMTP сервера %s: %v", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && ec.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return errorf("ошибка STARTTLS: %v", err)
+		}
+	}
+	if ec.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", ec.Username, ec.Password, ec.Host)); err != nil {
+			return errorf("ошибка авторизации на SMTP сервере: %v", err)
+		}
+	}
+	if err := client.Mail(ec.From); err != nil {
+		return errorf("SMTP сервер отклонил отправителя %s: %v", ec.From, err)
+	}
+	for _, rcpt := range ec.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return errorf("SMTP сервер отклонил получателя %s: %v", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errorf("ошибка отправки письма: %v", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errorf("ошибка отправки письма: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return errorf("ошибка отправки письма: %v", err)
+	}
+	return client.Quit()
+}
+
+// Отправка итогов запуска получателям из [EMAIL]
+func sendRunDigest(config *Config, report *runReport) error {
+	ec := config.Email
+	if !ec.enabled() {
+		return nil
+	}
+	if ec.OnlyOnFailure && report.Totals.Failed == 0 {
+		logf("Письмо с итогами не отправлено: ошибок нет")
+		return nil
+	}
+	subject, body := buildDigest(config, report)
+	if err := sendEmail(ec, formatEmail(ec.From, ec.To, subject, body, time.Now())); err != nil {
+		return err
+	}
+	infof("Итоги запуска отправлены на %s", strings.Join(ec.To, ", "))
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Минимальный SMTP сервер: принимает одно письмо и возвращает его текст в канал
+func fakeSMTPServer(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось запустить SMTP сервер: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost\r\n250 8BITMIME")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				messages <- msg.String()
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p, messages
+}
+
+func TestSendRunDigest(t *testing.T) {
+	host, port, messages := fakeSMTPServer(t)
+	config := &Config{
+		ReportFile: "rich.report.json",
+		Email:      EmailConfig{Host: host, Port: port, From: "rich@example.com", To: []string{"team@example.com"}, ReportURL: "https://docs.example.com/site/"},
+	}
+	report := &runReport{RunID: "20250101-120000-abcdef", StartedAt: time.Now(), Files: []reportEntry{
+		{Path: "ok.md", Status: StatusEnriched, PromptTokens: 100, CompletionTokens: 50, CostUSD: 0.01},
+		{Path: "bad.md", Status: StatusFailed, Error: "API запрос вернул статус 500"},
+	}}
+	report.finish()
+
+	if err := sendRunDigest(config, report); err != nil {
+		t.Fatalf("sendRunDigest() вернул ошибку: %v", err)
+	}
+	var raw string
+	select {
+	case raw = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Письмо не получено")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Некорректное письмо: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || !strings.Contains(subject, "20250101-120000-abcdef") {
+		t.Errorf("Неожиданная тема: %q, %v", subject, err)
+	}
+	encoded, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать письмо: %v", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(strings.TrimSpace(string(encoded)), "\r\n", ""))
+	if err != nil {
+		t.Fatalf("Текст письма должен быть в base64: %v", err)
+	}
+	for _, want := range []string{"bad.md: API запрос вернул статус 500", "$0.0100", "https://docs.example.com/site/", "rich status --run 20250101-120000-abcdef"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("В письме нет %q:\n%s", want, body)
+		}
+	}
+
+	// Письмо только при ошибках: без ошибок не отправляется
+	config.Email.OnlyOnFailure = true
+	report.Files = report.Files[:1]
+	report.finish()
+	if err := sendRunDigest(config, report); err != nil {
+		t.Errorf("sendRunDigest() без ошибок вернул ошибку: %v", err)
+	}
+}
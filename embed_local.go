@@ -0,0 +1,101 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Размерность локальных векторов
+const localEmbeddingDim = 512
+
+// Построение векторных представлений текстов
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+}
+
+// Локальные векторные представления без обращения к API:
+// хэширование слов и биграмм в вектор фиксированной размерности
+type localEmbedder struct{}
+
+// Построение векторов для набора текстов
+func (localEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = hashEmbedding(text)
+	}
+	return vectors, nil
+}
+
+// Хэширование токенов текста в нормализованный вектор
+func hashEmbedding(text string) []float32 {
+	vec := make([]float32, localEmbeddingDim)
+	tokens := tokenize(text)
+
+	add := func(token string, weight float32) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(token))
+		sum := h.Sum32()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vec[(sum>>1)%localEmbeddingDim] += sign * weight
+	}
+
+	for i, token := range tokens {
+		add(token, 1)
+		if i > 0 {
+			add(tokens[i-1]+" "+token, 0.5)
+		}
+	}
+	normalize(vec)
+	return vec
+}
+
+// Разбиение текста на нормализованные слова (короткие слова отбрасываются)
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) > 2 {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// Нормализация вектора до единичной длины
+func normalize(vec []float32) {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
+
+// Косинусное сходство векторов
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestLocalEmbedder(t *testing.T) {
+	vectors, err := localEmbedder{}.Embed([]string{
+		"настройка сервера postgres и репликации",
+		"репликация postgres настройка сервера",
+		"рецепт яблочного пирога с корицей",
+	})
+	if err != nil {
+		t.Fatalf("Embed() вернул ошибку: %v", err)
+	}
+	if len(vectors) != 3 || len(vectors[0]) != localEmbeddingDim {
+		t.Fatalf("Некорректная размерность векторов")
+	}
+
+	similar := cosineSimilarity(vectors[0], vectors[1])
+	different := cosineSimilarity(vectors[0], vectors[2])
+	if similar <= different {
+		t.Errorf("Похожие тексты должны быть ближе: %.3f <= %.3f", similar, different)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float32{1, 0}, []float32{1, 0}); got != 1 {
+		t.Errorf("Сходство одинаковых векторов должно быть 1, получено %f", got)
+	}
+	if got := cosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("Сходство ортогональных векторов должно быть 0, получено %f", got)
+	}
+	if got := cosineSimilarity([]float32{1}, []float32{1, 0}); got != 0 {
+		t.Errorf("Сходство векторов разной длины должно быть 0, получено %f", got)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Источники векторных представлений
+const (
+	embeddingsLocal  = "local"
+	embeddingsOpenAI = "openai"
+	embeddingsOllama = "ollama"
+	embeddingsGemini = "gemini"
+)
+
+// Поддиректория каталога состояния с сохраненными векторами
+const embeddingsDirName = "embeddings"
+
+// Настройки векторных представлений из секции [EMBEDDINGS]
+type EmbeddingConfig struct {
+	// local, openai, ollama или gemini
+	Provider string
+	Model    string
+	// Адрес API ("" - адрес провайдера по умолчанию)
+	APIURL string
+	APIKey string
+	// Текстов в одном запросе к API
+	BatchSize int
+}
+
+// Модели и адреса по умолчанию для источников векторов
+var embeddingDefaults = map[string]struct {
+	Model  string
+	URL    string
+	KeyEnv string
+}{
+	embeddingsOpenAI: {"text-embedding-3-small", "https://api.openai.com/v1/embeddings", "OPENAI_API_KEY"},
+	embeddingsOllama: {"nomic-embed-text", "http://localhost:11434/api/embed", ""},
+	embeddingsGemini: {"text-embedding-004", "https://generativelanguage.googleapis.com/v1beta", "GEMINI_API_KEY"},
+}
+
+// Векторные представления через API провайдера
+type apiEmbedder struct {
+	config EmbeddingConfig
+	client *http.Client
+	// Максимальный размер ответа API ([NETWORK] max_response_size)
+	maxResponse int64
+}
+
+// Построение источника векторов по конфигурации; векторы API сохраняются в
+// каталоге состояния и не запрашиваются повторно для того же текста
+func newEmbedder(config *Config) (Embedder, error) {
+	ec := config.Embeddings
+	if ec.Provider == "" || ec.Provider == embeddingsLocal {
+		return localEmbedder{}, nil
+	}
+	if _, ok := embeddingDefaults[ec.Provider]; !ok {
+		return nil, errorf("неизвестный источник векторов %q: поддерживаются local, openai, ollama, gemini", ec.Provider)
+	}
+	embedder := &apiEmbedder{
+		config:      ec,
+		client:      config.Network.client(0),
+		maxResponse: config.Network.maxResponseBytes(),
+	}
+	if config.StateDir == "" {
+		return embedder, nil
+	}
+	store, err := openVectorStore(config.StateDir, ec.Provider+"/"+ec.Model)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedEmbedder{embedder: embedder, store: store}, nil
+}
+
+// Построение векторов для набора текстов пачками по BatchSize
+func (e *apiEmbedder) Embed(texts []string) ([][]float32, error) {
+	batch := e.config.BatchSize
+	if batch <= 0 {
+		batch = len(texts)
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batch {
+		end := min(start+batch, len(texts))
+		part, err := e.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(part) != end-start {
+			return nil, errorf("API векторов вернул %d векторов вместо %d", len(part), end-start)
+		}
+		vectors = append(vectors, part...)
+	}
+	return vectors, nil
+}
+
+// Один запрос к API векторов
+func (e *apiEmbedder) embedBatch(texts []string) ([][]float32, error) {
+	url := e.config.APIURL
+	var body interface{}
+	switch e.config.Provider {
+	case embeddingsGemini:
+		// batchEmbedContents: адрес строится по базовому адресу API и модели
+		model := "models/" + strings.TrimPrefix(e.config.Model, "models/")
+		url = strings.TrimRight(url, "/") + "/" + model + ":batchEmbedContents"
+		requests := make([]map[string]interface{}, len(texts))
+		for i, text := range texts {
+			requests[i] = map[string]interface{}{
+				"model":   model,
+				"content": map[string]interface{}{"parts": []map[string]string{{"text": text}}},
+			}
+		}
+		body = map[string]interface{}{"requests": requests}
+	default:
+		// OpenAI /v1/embeddings и Ollama /api/embed принимают одинаковое тело
+		body = map[string]interface{}{"model": e.config.Model, "input": texts}
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, errorf("ошибка при подготовке JSON запроса: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setUserAgent(req)
+	if e.config.APIKey != "" {
+		if e.config.Provider == embeddingsGemini {
+			req.Header.Set("x-goog-api-key", e.config.APIKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+		}
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, errorf("ошибка при запросе векторов: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := readResponseBody(resp, e.maxResponse)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &apiStatusError{StatusCode: resp.StatusCode, Body: string(data), Message: openAIErrorMessage(data)}
+	}
+	if err := checkJSONResponse(resp, data); err != nil {
+		return nil, err
+	}
+	return parseEmbeddings(e.config.Provider, data)
+}
+
+// Векторы из ответа API в порядке входных текстов
+func parseEmbeddings(provider string, data []byte) ([][]float32, error) {
+	var response struct {
+		// OpenAI
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		// Ollama и Gemini
+		Embeddings json.RawMessage `json:"embeddings"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, errorf("некорректный ответ API векторов: %v", err)
+	}
+
+	var vectors [][]float32
+	switch provider {
+	case embeddingsOpenAI:
+		vectors = make([][]float32, len(response.Data))
+		for _, d := range response.Data {
+			if d.Index < 0 || d.Index >= len(vectors) {
+				return nil, errorf("некорректный ответ API векторов: индекс %d вне диапазона", d.Index)
+			}
+			vectors[d.Index] = d.Embedding
+		}
+	case embeddingsOllama:
+		if err := json.Unmarshal(response.Embeddings, &vectors); err != nil {
+			return nil, errorf("некорректный ответ API векторов: %v", err)
+		}
+	case embeddingsGemini:
+		var gemini []struct {
+			Values []float32 `json:"values"`
+		}
+		if err := json.Unmarshal(response.Embeddings, &gemini); err != nil {
+			return nil, errorf("некорректный ответ API векторов: %v", err)
+		}
+		for _, g := range gemini {
+			vectors = append(vectors, g.Values)
+		}
+	}
+	for _, v := range vectors {
+		if len(v) == 0 {
+			return nil, errorf("некорректный ответ API векторов: пустой вектор")
+		}
+	}
+	return vectors, nil
+}
+
+// Локальное хранилище векторов по хэшу текста: векторы разных моделей несравнимы,
+// поэтому у каждой модели свой файл
+type vectorStore struct {
+	mu   sync.Mutex
+	path string
+
+	Model   string               `json:"model"`
+	Vectors map[string][]float32 `json:"vectors"`
+}
+
+// Имя файла хранилища: небезопасные для файловой системы символы модели заменяются
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Открытие хранилища векторов модели в каталоге состояния (пустое, если файла нет)
+func openVectorStore(stateDir, model string) (*vectorStore, error) {
+	name := unsafeFileChars.ReplaceAllString(model, "_") + ".json"
+	s := &vectorStore{
+		path:    filepath.Join(stateDir, embeddingsDirName, name),
+		Model:   model,
+		Vectors: map[string][]float32{},
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errorf("ошибка при чтении хранилища векторов: %v", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, errorf("ошибка при разборе хранилища векторов %s: %v", s.path, err)
+	}
+	if s.Vectors == nil {
+		s.Vectors = map[string][]float32{}
+	}
+	return s, nil
+}
+
+// Ключ текста в хранилище
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Сохранение хранилища в каталог состояния
+func (s *vectorStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return errorf("не удалось создать директорию хранилища векторов: %v", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return errorf("ошибка при подготовке хранилища векторов: %v", err)
+	}
+	if err := safeWriteFile(s.path, data, 0644); err != nil {
+		return errorf("ошибка при записи хранилища векторов: %v", err)
+	}
+	return nil
+}
+
+// Источник векторов с сохранением результатов в хранилище
+type cachedEmbedder struct {
+	embedder Embedder
+	store    *vectorStore
+}
+
+// Построение векторов: из хранилища, недостающие - через источник с последующим сохранением
+func (c *cachedEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	var missing []string
+	var missingIdx []int
+	c.store.mu.Lock()
+	for i, text := range texts {
+		if v, ok := c.store.Vectors[textHash(text)]; ok {
+			vectors[i] = v
+		} else {
+			missing = append(missing, text)
+			missingIdx = append(missingIdx, i)
+		}
+	}
+	c.store.mu.Unlock()
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	computed, err := c.embedder.Embed(missing)
+	if err != nil {
+		return nil, err
+	}
+	c.store.mu.Lock()
+	for j, i := range missingIdx {
+		vectors[i] = computed[j]
+		c.store.Vectors[textHash(missing[j])] = computed[j]
+	}
+	c.store.mu.Unlock()
+	logf("Построено векторов: %d, из хранилища: %d", len(missing), len(texts)-len(missing))
+	if err := c.store.Save(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAPIEmbedders(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Input    []string `json:"input"`
+			Requests []struct {
+				Model string `json:"model"`
+			} `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Некорректный запрос: %v", err)
+		}
+		switch {
+		case r.URL.Path == "/v1/embeddings":
+			if r.Header.Get("Authorization") != "Bearer openai-key" {
+				t.Errorf("Нет ключа OpenAI: %q", r.Header.Get("Authorization"))
+			}
+			// Порядок векторов задается полем index
+			_, _ = w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+		case r.URL.Path == "/api/embed":
+			_, _ = w.Write([]byte(`{"model": "nomic-embed-text", "embeddings": [[1, 0], [0, 1]]}`))
+		case strings.HasSuffix(r.URL.Path, "/models/text-embedding-004:batchEmbedContents"):
+			if r.Header.Get("x-goog-api-key") != "gemini-key" || len(req.Requests) != 2 || req.Requests[0].Model != "models/text-embedding-004" {
+				t.Errorf("Неожиданный запрос Gemini: %+v", req)
+			}
+			_, _ = w.Write([]byte(`{"embeddings": [{"values": [1, 0]}, {"values": [0, 1]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	configs := []EmbeddingConfig{
+		{Provider: embeddingsOpenAI, Model: "text-embedding-3-small", APIURL: server.URL + "/v1/embeddings", APIKey: "openai-key"},
+		{Provider: embeddingsOllama, Model: "nomic-embed-text", APIURL: server.URL + "/api/embed"},
+		{Provider: embeddingsGemini, Model: "text-embedding-004", APIURL: server.URL + "/v1beta", APIKey: "gemini-key"},
+	}
+	for _, ec := range configs {
+		embedder, err := newEmbedder(&Config{Embeddings: ec})
+		if err != nil {
+			t.Fatalf("newEmbedder(%s) вернул ошибку: %v", ec.Provider, err)
+		}
+		vectors, err := embedder.Embed([]string{"первый", "второй"})
+		if err != nil {
+			t.Fatalf("Embed(%s) вернул ошибку: %v", ec.Provider, err)
+		}
+		if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+			t.Errorf("Неожиданные векторы %s: %v", ec.Provider, vectors)
+		}
+	}
+
+	// Векторы сохраняются в каталоге состояния и не запрашиваются повторно
+	stateDir := t.TempDir()
+	config := &Config{StateDir: stateDir, Embeddings: EmbeddingConfig{Provider: embeddingsOllama, Model: "nomic-embed-text", APIURL: server.URL + "/api/embed"}}
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		embedder, err := newEmbedder(config)
+		if err != nil {
+			t.Fatalf("newEmbedder() вернул ошибку: %v", err)
+		}
+		if _, err := embedder.Embed([]string{"первый", "второй"}); err != nil {
+			t.Fatalf("Embed() вернул ошибку: %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Сохраненные векторы не должны запрашиваться повторно, запросов: %d", calls.Load())
+	}
+	store, err := openVectorStore(stateDir, "ollama/nomic-embed-text")
+	if err != nil || len(store.Vectors) != 2 {
+		t.Errorf("Хранилище векторов: %d векторов, %v", len(store.Vectors), err)
+	}
+}
+
+func TestEmbedderBatches(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sizes = append(sizes, len(req.Input))
+		vectors := make([][]float32, len(req.Input))
+		for i := range vectors {
+			vectors[i] = []float32{1}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": vectors})
+	}))
+	defer server.Close()
+
+	embedder, err := newEmbedder(&Config{Embeddings: EmbeddingConfig{Provider: embeddingsOllama, APIURL: server.URL, BatchSize: 2}})
+	if err != nil {
+		t.Fatalf("newEmbedder() вернул ошибку: %v", err)
+	}
+	vectors, err := embedder.Embed([]string{"a", "b", "c"})
+	if err != nil || len(vectors) != 3 {
+		t.Fatalf("Embed() = %d векторов, %v", len(vectors), err)
+	}
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("Тексты должны отправляться пачками по batch_size: %v", sizes)
+	}
+	if _, err := newEmbedder(&Config{Embeddings: EmbeddingConfig{Provider: "word2vec"}}); err == nil {
+		t.Error("Неизвестный источник векторов должен возвращать ошибку")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Префикс переменных окружения с параметрами конфигурации: RICH_<СЕКЦИЯ>_<КЛЮЧ>,
+// например RICH_MODEL_API_KEY или RICH_DIRECTORIES_INPUT_DIR
+const envConfigPrefix = "RICH_"
+
+// Секции конфигурации, которые можно задать через переменные окружения
+var envConfigSections = []string{
+	"DIRECTORIES", "EXCLUSIONS", "MODEL", "PROCESSING", "CONTEXT", "EMBEDDINGS", "SECTIONS",
+	"POSTPROCESS", "LINKS", "DISCLOSURE", "STATE", "INTERFACE", "INDEX", "REPORT", "DATABASE", "EMAIL", "REDIS", "PROMPT",
+	"NETWORK", "ALERTS", "TITLES", "RELATED", "FLASHCARDS", "CHANGELOG", "STYLE", "CITATIONS", "PANDOC", "PDF", "GZIP", "OCR",
+	"DAILY_NOTES",
+	"WORKFLOW", "CANARY", "CHECKSUMS", "WATCH", "EXPERIMENT", "TYPOGRAPHY", "SPELLCHECK",
+}
+
+// Параметр конфигурации из переменной окружения
+type envOverride struct {
+	Section, Key, Value string
+}
+
+// Параметры конфигурации из переменных окружения; переменные других секций
+// (RICH_LANG, RICH_SMTP_PASSWORD) не учитываются
+func envConfigOverrides(environ []string) []envOverride {
+	var overrides []envOverride
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, envConfigPrefix) {
+			continue
+		}
+		name = strings.TrimPrefix(name, envConfigPrefix)
+		for _, section := range envConfigSections {
+			if key, found := strings.CutPrefix(name, section+"_"); found && key != "" {
+				overrides = append(overrides, envOverride{Section: section, Key: strings.ToLower(key), Value: value})
+				break
+			}
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Section != overrides[j].Section {
+			return overrides[i].Section < overrides[j].Section
+		}
+		return overrides[i].Key < overrides[j].Key
+	})
+	return overrides
+}
+
+// Применение параметров из переменных окружения поверх файла конфигурации
+func applyEnvConfig(cfg *ini.File, overrides []envOverride) {
+	for _, o := range overrides {
+		cfg.Section(o.Section).Key(o.Key).SetValue(o.Value)
+		logf("Параметр [%s] %s задан переменной окружения", o.Section, o.Key)
+	}
+}
+
+// Подстановка переменной окружения в значении конфигурации: ${VAR} или ${VAR:-значение},
+// $${VAR} оставляет текст ${VAR} как есть
+var configEnvRe = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// Подстановка переменных окружения во все значения конфигурации, чтобы один шаблон
+// конфигурации разворачивался в разных окружениях. Не заданная переменная без
+// значения по умолчанию - ошибка: пустой каталог или адрес проявился бы позже
+func expandConfigEnv(cfg *ini.File) error {
+	for _, section := range cfg.Sections() {
+		for _, key := range section.Keys() {
+			value := key.Value()
+			if !strings.Contains(value, "${") {
+				continue
+			}
+			var missing string
+			expanded := configEnvRe.ReplaceAllStringFunc(value, func(ref string) string {
+				if ref == "$${" {
+					return "${"
+				}
+				m := configEnvRe.FindStringSubmatch(ref)
+				if v, ok := os.LookupEnv(m[1]); ok && (v != "" || !strings.Contains(ref, ":-")) {
+					return v
+				}
+				if strings.Contains(ref, ":-") {
+					return m[2]
+				}
+				if missing == "" {
+					missing = m[1]
+				}
+				return ""
+			})
+			if missing != "" {
+				return errorf("переменная окружения %s не задана (параметр %s секции [%s])", missing, key.Name(), section.Name())
+			}
+			key.SetValue(expanded)
+		}
+	}
+	return nil
+}
+
+// Значение переменной окружения или значение по умолчанию
+func envString(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// Логическое значение переменной окружения (1, true, yes); пустое или некорректное - false
+func envBool(name string) bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	if value == "yes" || value == "on" {
+		return true
+	}
+	b, _ := strconv.ParseBool(value)
+	return b
+}
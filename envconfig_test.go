@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestEnvConfigOverrides(t *testing.T) {
+	overrides := envConfigOverrides([]string{
+		"RICH_MODEL_API_KEY=secret",
+		"RICH_DIRECTORIES_INPUT_DIR=/data/in",
+		"RICH_LANG=en",
+		"RICH_SMTP_PASSWORD=x",
+		"RICH_MODEL_=empty",
+		"PATH=/usr/bin",
+	})
+	want := []envOverride{
+		{Section: "DIRECTORIES", Key: "input_dir", Value: "/data/in"},
+		{Section: "MODEL", Key: "api_key", Value: "secret"},
+	}
+	if len(overrides) != len(want) {
+		t.Fatalf("Получено %+v, ожидалось %+v", overrides, want)
+	}
+	for i := range want {
+		if overrides[i] != want[i] {
+			t.Errorf("Параметр %d: получено %+v, ожидалось %+v", i, overrides[i], want[i])
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	if _, err := loadConfig(configPath); err == nil {
+		t.Fatal("Без файла и переменных окружения ожидалась ошибка")
+	}
+
+	t.Setenv("RICH_DIRECTORIES_INPUT_DIR", filepath.Join(tmpDir, "in"))
+	t.Setenv("RICH_MODEL_NAME", "gpt-4o")
+	t.Setenv("RICH_MODEL_API_KEY", "env_key")
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() без файла вернул ошибку: %v", err)
+	}
+	if config.InputDir != filepath.Join(tmpDir, "in") || config.ModelName != "gpt-4o" || config.APIKey != "env_key" {
+		t.Errorf("Параметры из окружения не применены: %+v", config)
+	}
+
+	// Список обработанных файлов сохраняется в создаваемый файл конфигурации
+	if err := addToExcludedFiles(configPath, "a.md"); err != nil {
+		t.Fatalf("addToExcludedFiles() вернул ошибку: %v", err)
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Файл конфигурации не создан: %v", err)
+	}
+	if got := cfg.Section("EXCLUSIONS").Key("excluded_files").String(); got != "a.md" {
+		t.Errorf("excluded_files = %q, ожидалось a.md", got)
+	}
+	if cfg.Section("MODEL").HasKey("api_key") {
+		t.Error("Ключ API из окружения не должен записываться в файл")
+	}
+
+	// Переменные окружения имеют приоритет над файлом
+	if err := os.WriteFile(configPath, []byte("[MODEL]\nname = gpt-3.5-turbo\n"), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+	if config, err = loadConfig(configPath); err != nil || config.ModelName != "gpt-4o" {
+		t.Errorf("Ожидалась модель из окружения, получено %v (ошибка %v)", config, err)
+	}
+}
+
+func TestEnvBool(t *testing.T) {
+	for value, want := range map[string]bool{"1": true, "true": true, "yes": true, "": false, "0": false, "maybe": false} {
+		t.Setenv("RICH_TEST_BOOL", value)
+		if got := envBool("RICH_TEST_BOOL"); got != want {
+			t.Errorf("envBool(%q) = %v, ожидалось %v", value, got, want)
+		}
+	}
+}
+
+func TestExpandConfigEnv(t *testing.T) {
+	t.Setenv("RICH_TEST_ROOT", "/srv/notes")
+	t.Setenv("RICH_TEST_EMPTY", "")
+	cfg, err := ini.Load([]byte("[DIRECTORIES]\ninput_dir = ${RICH_TEST_ROOT}/in\noutput_dir = ${RICH_TEST_OUT:-./out}\n\n" +
+		"[MODEL]\napi_url = http://${RICH_TEST_EMPTY:-localhost}:8080\nprompt = Цена $5, шаблон $${HOME}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expandConfigEnv(cfg); err != nil {
+		t.Fatalf("expandConfigEnv() вернул ошибку: %v", err)
+	}
+	want := map[string]string{
+		"input_dir":  "/srv/notes/in",
+		"output_dir": "./out",
+		"api_url":    "http://localhost:8080",
+		"prompt":     "Цена $5, шаблон ${HOME}",
+	}
+	for _, section := range []string{"DIRECTORIES", "MODEL"} {
+		for _, key := range cfg.Section(section).Keys() {
+			if key.Value() != want[key.Name()] {
+				t.Errorf("%s: получено %q, ожидалось %q", key.Name(), key.Value(), want[key.Name()])
+			}
+		}
+	}
+
+	cfg, _ = ini.Load([]byte("[DIRECTORIES]\ninput_dir = ${RICH_TEST_MISSING}/in\n"))
+	if err := expandConfigEnv(cfg); err == nil {
+		t.Error("ожидалась ошибка для не заданной переменной")
+	}
+}
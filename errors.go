@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Категории ошибок для отчета о запуске и кода завершения процесса
+const (
+	ErrorConfig     = "config"
+	ErrorNetwork    = "network"
+	ErrorAuth       = "auth"
+	ErrorRateLimit  = "rate_limit"
+	ErrorProvider   = "provider"
+	ErrorValidation = "validation"
+	ErrorIO         = "io"
+	// Ошибка, которую не удалось отнести к другим категориям
+	ErrorInternal = "internal"
+)
+
+// Коды завершения процесса: 1 - внутренняя ошибка, 9 - ошибки файлов разных категорий
+const (
+	exitFailure       = 1
+	exitMixedFailures = 9
+)
+
+// Коды завершения по категориям ошибок
+var exitCodes = map[string]int{
+	ErrorInternal:   exitFailure,
+	ErrorConfig:     2,
+	ErrorNetwork:    3,
+	ErrorAuth:       4,
+	ErrorRateLimit:  5,
+	ErrorProvider:   6,
+	ErrorValidation: 7,
+	ErrorIO:         8,
+}
+
+// Ошибка с явно заданной категорией
+type categoryError struct {
+	category string
+	err      error
+}
+
+func (e *categoryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.err
+}
+
+// Отнесение ошибки к категории; nil остается nil
+func withCategory(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categoryError{category: category, err: err}
+}
+
+// Категория ошибки: заданная явно, по статусу ответа API или по типу ошибки
+func errorCategory(err error) string {
+	var ce *categoryError
+	if errors.As(err, &ce) {
+		return ce.category
+	}
+	var se *apiStatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrorAuth
+		case http.StatusTooManyRequests:
+			return ErrorRateLimit
+		}
+		return ErrorProvider
+	}
+	var re *refusalError
+	if errors.As(err, &re) {
+		return ErrorProvider
+	}
+	var pe *fs.PathError
+	var le *os.LinkError
+	if errors.As(err, &pe) || errors.As(err, &le) {
+		return ErrorIO
+	}
+	// Ошибки сетевых соединений и HTTP клиента (файловые ошибки тоже реализуют net.Error)
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return ErrorNetwork
+	}
+	return ErrorInternal
+}
+
+// Ошибки файлов запуска: запуск завершен, но часть файлов не обработана
+type filesFailedError struct {
+	// Количество файлов с ошибками по категориям
+	Categories map[string]int
+}
+
+func (e *filesFailedError) Error() string {
+	total := 0
+	keys := make([]string, 0, len(e.Categories))
+	for category, n := range e.Categories {
+		total += n
+		keys = append(keys, category)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, category := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", category, e.Categories[category])
+	}
+	return trf("файлов с ошибками: %d (%s)", total, strings.Join(parts, ", "))
+}
+
+// Код завершения процесса для ошибки: по категории ошибки, для ошибок файлов - по
+// их общей категории (или exitMixedFailures, если категорий несколько)
+func exitCode(err error) int {
+	var fe *filesFailedError
+	if errors.As(err, &fe) {
+		if len(fe.Categories) != 1 {
+			return exitMixedFailures
+		}
+		for category := range fe.Categories {
+			return exitCodes[category]
+		}
+	}
+	return exitCodes[errorCategory(err)]
+}
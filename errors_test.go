@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorCategory(t *testing.T) {
+	_, readErr := os.ReadFile(filepath.Join(t.TempDir(), "нет.md"))
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	_, netErr := http.Get(server.URL)
+
+	tests := []struct {
+		name     string
+		err      error
+		category string
+		code     int
+	}{
+		{"конфигурация", withCategory(ErrorConfig, errorf("некорректный порядок")), ErrorConfig, 2},
+		{"сеть", errorf("ошибка при выполнении HTTP запроса: %w", netErr), ErrorNetwork, 3},
+		{"авторизация", &apiStatusError{StatusCode: http.StatusUnauthorized}, ErrorAuth, 4},
+		{"лимит", &apiStatusError{StatusCode: http.StatusTooManyRequests}, ErrorRateLimit, 5},
+		{"провайдер", &apiStatusError{StatusCode: http.StatusInternalServerError}, ErrorProvider, 6},
+		{"отказ модели", &refusalError{Response: "не могу"}, ErrorProvider, 6},
+		{"проверка", withCategory(ErrorValidation, errorf("результат отклонен проверкой: %s", "длина")), ErrorValidation, 7},
+		{"файл", errorf("ошибка при чтении файла: %w", readErr), ErrorIO, 8},
+		{"без категории", errorf("ошибка при разборе JSON ответа: %v", readErr), ErrorInternal, 1},
+		{"явная категория важнее типа", withCategory(ErrorAuth, netErr), ErrorAuth, 4},
+	}
+	for _, tt := range tests {
+		if got := errorCategory(tt.err); got != tt.category {
+			t.Errorf("%s: категория %q, ожидалась %q", tt.name, got, tt.category)
+		}
+		if got := exitCode(tt.err); got != tt.code {
+			t.Errorf("%s: код завершения %d, ожидался %d", tt.name, got, tt.code)
+		}
+	}
+
+	// Ошибки файлов: код общей категории или 9 для разных категорий
+	single := &filesFailedError{Categories: map[string]int{ErrorNetwork: 3}}
+	if code := exitCode(errorf("транзакция отменена, результаты не сохранены: %w", single)); code != 3 {
+		t.Errorf("код завершения для ошибок одной категории: %d", code)
+	}
+	mixed := &filesFailedError{Categories: map[string]int{ErrorNetwork: 1, ErrorIO: 2}}
+	if code := exitCode(mixed); code != exitMixedFailures {
+		t.Errorf("код завершения для ошибок разных категорий: %d", code)
+	}
+	if msg := mixed.Error(); msg != "файлов с ошибками: 3 (io: 2, network: 1)" {
+		t.Errorf("сообщение об ошибках файлов: %q", msg)
+	}
+}
+
+func TestReportErrorCategories(t *testing.T) {
+	report := newRunReport()
+	config := &Config{}
+	report.Add(config, "a.md", &fileResult{Status: StatusFailed}, &apiStatusError{StatusCode: http.StatusTooManyRequests})
+	report.Add(config, "b.md", &fileResult{Status: StatusFailed}, &apiStatusError{StatusCode: http.StatusTooManyRequests})
+	report.Add(config, "c.md", &fileResult{Status: StatusEnriched}, nil)
+	report.finish()
+	if report.Files[0].ErrorCategory != ErrorRateLimit || report.Files[2].ErrorCategory != "" {
+		t.Errorf("категории ошибок файлов: %q, %q", report.Files[0].ErrorCategory, report.Files[2].ErrorCategory)
+	}
+	if len(report.Totals.Errors) != 1 || report.Totals.Errors[ErrorRateLimit] != 2 {
+		t.Errorf("итоги по категориям ошибок: %v", report.Totals.Errors)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Пример обогащения (оригинал и результат), который передается модели предыдущими
+// сообщениями диалога перед обрабатываемым документом
+type fewShotExample struct {
+	Name     string
+	Original string
+	Enriched string
+}
+
+// Чтение примеров из секций [EXAMPLE.<имя>] в порядке следования в конфигурации:
+// original и enriched - текст примера, original_file и enriched_file - файлы
+// (относительно директории конфигурации)
+func loadExamples(cfg *ini.File, configDir string) ([]fewShotExample, error) {
+	var examples []fewShotExample
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "EXAMPLE.")
+		if !ok {
+			continue
+		}
+		example := fewShotExample{Name: name}
+		for _, field := range []struct {
+			key    string
+			target *string
+		}{{"original", &example.Original}, {"enriched", &example.Enriched}} {
+			*field.target = section.Key(field.key).String()
+			file := section.Key(field.key + "_file").String()
+			if file == "" || *field.target != "" {
+				continue
+			}
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(configDir, file)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, errorf("не удалось прочитать пример %s: %v", name, err)
+			}
+			*field.target = string(data)
+		}
+		if strings.TrimSpace(example.Original) == "" || strings.TrimSpace(example.Enriched) == "" {
+			return nil, errorf("в примере %s должны быть заданы original (original_file) и enriched (enriched_file)", name)
+		}
+		examples = append(examples, example)
+	}
+	return examples, nil
+}
+
+// Сообщение пользователя в том же виде, что и запрос с документом: промпт и текст
+func userMessage(prompt, content string) string {
+	return prompt + "\n\n" + content
+}
+
+// Сообщения диалога: примеры (запрос и ответ) и запрос с документом. assistantRole -
+// роль ответа модели в формате провайдера (assistant, у Gemini - model)
+func chatMessages(config *Config, content, assistantRole string) []map[string]string {
+	messages := make([]map[string]string, 0, 2*len(config.Examples)+1)
+	for _, ex := range config.Examples {
+		messages = append(messages,
+			map[string]string{"role": "user", "content": userMessage(config.Prompt, ex.Original)},
+			map[string]string{"role": assistantRole, "content": ex.Enriched})
+	}
+	return append(messages, map[string]string{"role": "user", "content": userMessage(config.Prompt, content)})
+}
+
+// Полный текст запроса с примерами для оценки токенов
+func requestText(config *Config, content string) string {
+	var b strings.Builder
+	for _, ex := range config.Examples {
+		b.WriteString(userMessage(config.Prompt, ex.Original))
+		b.WriteString("\n\n")
+		b.WriteString(ex.Enriched)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(userMessage(config.Prompt, content))
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadExamples(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "after.md"), []byte("# Заметка\n\nОбогащено"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := ini.Load([]byte("[EXAMPLE.first]\noriginal = заметка\nenriched = обогащенная заметка\n\n" +
+		"[EXAMPLE.second]\noriginal = второй\nenriched_file = after.md\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples, err := loadExamples(cfg, dir)
+	if err != nil {
+		t.Fatalf("loadExamples() вернул ошибку: %v", err)
+	}
+	if len(examples) != 2 || examples[0].Name != "first" || examples[1].Name != "second" {
+		t.Fatalf("ожидались примеры first и second по порядку, получено %+v", examples)
+	}
+	if examples[1].Enriched != "# Заметка\n\nОбогащено" {
+		t.Errorf("enriched_file не прочитан: %q", examples[1].Enriched)
+	}
+
+	cfg, _ = ini.Load([]byte("[EXAMPLE.broken]\noriginal = только оригинал\n"))
+	if _, err := loadExamples(cfg, dir); err == nil {
+		t.Error("ожидалась ошибка для примера без enriched")
+	}
+	cfg, _ = ini.Load([]byte("[EXAMPLE.missing]\noriginal = текст\nenriched_file = nope.md\n"))
+	if _, err := loadExamples(cfg, dir); err == nil {
+		t.Error("ожидалась ошибка для отсутствующего файла примера")
+	}
+}
+
+func TestEnrichContentSendsExamples(t *testing.T) {
+	var messages []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("некорректный запрос: %v", err)
+		}
+		messages = body.Messages
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ответ"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Provider:    providerOpenAICompatible,
+		ModelName:   "gpt-4o-mini",
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens:   1000,
+		Prompt:      "Обогати",
+		Examples:    []fewShotExample{{Name: "a", Original: "до", Enriched: "после"}},
+	}
+	if _, err := enrichContent(config, "документ", NewRateLimiter(10)); err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	want := []map[string]string{
+		{"role": "user", "content": "Обогати\n\nдо"},
+		{"role": "assistant", "content": "после"},
+		{"role": "user", "content": "Обогати\n\nдокумент"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("ожидалось %d сообщения, получено %+v", len(want), messages)
+	}
+	for i := range want {
+		if messages[i]["role"] != want[i]["role"] || messages[i]["content"] != want[i]["content"] {
+			t.Errorf("сообщение %d: ожидалось %v, получено %v", i, want[i], messages[i])
+		}
+	}
+}
+
+func TestGeminiRequestExamples(t *testing.T) {
+	config := &Config{Prompt: "P", Examples: []fewShotExample{{Original: "до", Enriched: "после"}}}
+	data, err := json.Marshal(geminiRequest(chatMessages(config, "док", "model"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if !strings.Contains(s, `"role":"model"`) || strings.Index(s, "после") > strings.Index(s, "док") {
+		t.Errorf("ответ примера должен идти с ролью model перед документом: %s", s)
+	}
+}
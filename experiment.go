@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/ini.v1"
+)
+
+// Варианты промпта в эксперименте
+const (
+	// Текущий промпт файла
+	VariantA = "A"
+	// Промпт prompt_b секции [EXPERIMENT]
+	VariantB = "B"
+)
+
+// Поле frontmatter результата с вариантом промпта
+const VariantKey = "rich_prompt_variant"
+
+// Имя эксперимента по умолчанию
+const defaultExperimentName = "ab"
+
+// Уровень значимости, при котором один вариант считается лучше другого
+const experimentSignificance = 0.05
+
+// Эксперимент с промптами из секции [EXPERIMENT]: файлы по очереди обогащаются
+// текущим промптом (A) и промптом prompt_b (B)
+type ExperimentConfig struct {
+	// Имя эксперимента в журнале запусков: оценки разных экспериментов не смешиваются
+	Name string
+	// Промпт варианта B ("" - эксперимент выключен)
+	PromptB string
+}
+
+// Чтение секции [EXPERIMENT]
+func loadExperimentConfig(section *ini.Section) ExperimentConfig {
+	return ExperimentConfig{
+		Name:    section.Key("name").MustString(defaultExperimentName),
+		PromptB: strings.TrimSpace(section.Key("prompt_b").String()),
+	}
+}
+
+// Эксперимент включен
+func (e ExperimentConfig) Enabled() bool {
+	return e.PromptB != ""
+}
+
+// Назначение вариантов файлам запуска
+type promptExperiment struct {
+	config ExperimentConfig
+	next   atomic.Int64
+}
+
+// Назначение вариантов; nil, если эксперимент выключен
+func newPromptExperiment(config *Config) *promptExperiment {
+	if !config.Experiment.Enabled() {
+		return nil
+	}
+	return &promptExperiment{config: config.Experiment}
+}
+
+// Вариант для очередного файла: варианты чередуются, поэтому в каждом запуске
+// они получают поровну файлов. Файлы маршрутов в эксперименте не участвуют
+func (e *promptExperiment) Assign(fileConfig *Config, route *Route) string {
+	if e == nil || route != nil {
+		return ""
+	}
+	if e.next.Add(1)%2 == 0 {
+		fileConfig.Prompt = fileConfig.File.Expand(e.config.PromptB)
+		fileConfig.LanguagePrompts = nil
+		return VariantB
+	}
+	return VariantA
+}
+
+// Итоги варианта по оценкам проверяющих
+type variantOutcome struct {
+	Variant  string
+	Outputs  int
+	Accepted int
+	Rejected int
+}
+
+// Оцененные результаты варианта
+func (v variantOutcome) Labeled() int {
+	return v.Accepted + v.Rejected
+}
+
+// Доля принятых среди оцененных результатов
+func (v variantOutcome) AcceptRate() float64 {
+	if v.Labeled() == 0 {
+		return 0
+	}
+	return float64(v.Accepted) / float64(v.Labeled())
+}
+
+// Итоги вариантов экспериментов по последним результатам файлов и их действующим
+// оценкам: эксперимент -> вариант -> итоги
+func experimentOutcomes(latest map[string]outputRecord, labels map[string]outputLabel) map[string]map[string]*variantOutcome {
+	outcomes := make(map[string]map[string]*variantOutcome)
+	for rel, rec := range latest {
+		if rec.Entry.Variant == "" {
+			continue
+		}
+		byVariant := outcomes[rec.Entry.Experiment]
+		if byVariant == nil {
+			byVariant = make(map[string]*variantOutcome)
+			outcomes[rec.Entry.Experiment] = byVariant
+		}
+		o := byVariant[rec.Entry.Variant]
+		if o == nil {
+			o = &variantOutcome{Variant: rec.Entry.Variant}
+			byVariant[rec.Entry.Variant] = o
+		}
+		o.Outputs++
+		switch labels[rel].Label {
+		case LabelAccepted:
+			o.Accepted++
+		case LabelRejected:
+			o.Rejected++
+		}
+	}
+	return outcomes
+}
+
+// Двусторонний z-тест разности долей принятых результатов двух вариантов: p-value
+// (1, если данных для сравнения нет)
+func acceptRatePValue(a, b variantOutcome) float64 {
+	na, nb := float64(a.Labeled()), float64(b.Labeled())
+	if na == 0 || nb == 0 {
+		return 1
+	}
+	pooled := float64(a.Accepted+b.Accepted) / (na + nb)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/na + 1/nb))
+	if se == 0 {
+		return 1
+	}
+	z := (b.AcceptRate() - a.AcceptRate()) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// rich label stats [--experiment имя]: доля принятых результатов по вариантам
+// промпта и значимость разницы между ними
+func runLabelStatsCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("label stats", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	only := fs.String("experiment", "", tr("Только указанный эксперимент"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+	labels, err := currentLabels(config.StateDir, latest)
+	if err != nil {
+		return err
+	}
+	outcomes := experimentOutcomes(latest, labels)
+	names := make([]string, 0, len(outcomes))
+	for name := range outcomes {
+		if *only == "" || name == *only {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(out, tr("Нет результатов экспериментов с промптами"))
+		return nil
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, tr("Эксперимент %s")+"\n", name)
+		fmt.Fprintf(out, "  %-8s %10s %8s %8s %9s %8s\n", tr("Вариант"), tr("Результатов"), tr("Оценено"), tr("Принято"), tr("Отклонено"), tr("Принято, %"))
+		a, b := outcomes[name][VariantA], outcomes[name][VariantB]
+		for _, o := range []*variantOutcome{a, b} {
+			if o == nil {
+				continue
+			}
+			fmt.Fprintf(out, "  %-8s %10d %8d %8d %9d %8.1f\n", o.Variant, o.Outputs, o.Labeled(), o.Accepted, o.Rejected, 100*o.AcceptRate())
+		}
+		if a == nil || b == nil || a.Labeled() == 0 || b.Labeled() == 0 {
+			fmt.Fprintln(out, "  "+tr("Для сравнения нужны оценки результатов обоих вариантов"))
+			continue
+		}
+		p := acceptRatePValue(*a, *b)
+		diff := 100 * (b.AcceptRate() - a.AcceptRate())
+		switch {
+		case p >= experimentSignificance:
+			fmt.Fprintf(out, "  "+tr("Разница B-A: %+.1f п.п., p = %.3f: различие не значимо, нужно больше оценок")+"\n", diff, p)
+		case diff > 0:
+			fmt.Fprintf(out, "  "+tr("Разница B-A: %+.1f п.п., p = %.3f: вариант B лучше")+"\n", diff, p)
+		default:
+			fmt.Fprintf(out, "  "+tr("Разница B-A: %+.1f п.п., p = %.3f: вариант A лучше")+"\n", diff, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcceptRatePValue(t *testing.T) {
+	a := variantOutcome{Variant: VariantA, Accepted: 30, Rejected: 20}
+	b := variantOutcome{Variant: VariantB, Accepted: 45, Rejected: 5}
+	if p := acceptRatePValue(a, b); p > 0.001 {
+		t.Errorf("Разница 60%% и 90%% на 50 оценках должна быть значимой: p = %g", p)
+	}
+	if p := acceptRatePValue(a, a); p != 1 {
+		t.Errorf("Одинаковые доли: p = %g, ожидалось 1", p)
+	}
+	small := variantOutcome{Variant: VariantB, Accepted: 2, Rejected: 1}
+	if p := acceptRatePValue(a, small); p < experimentSignificance {
+		t.Errorf("Три оценки не должны давать значимой разницы: p = %g", p)
+	}
+	if p := acceptRatePValue(a, variantOutcome{Variant: VariantB}); p != 1 {
+		t.Errorf("Без оценок варианта: p = %g, ожидалось 1", p)
+	}
+}
+
+// Файлы по очереди обогащаются промптами A и B, результат и журнал запуска
+// отмечаются вариантом, а оценки проверяющих собираются по вариантам
+func TestPromptExperiment(t *testing.T) {
+	var mu sync.Mutex
+	prompts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		for _, v := range []string{"вариант A", "вариант B"} {
+			if strings.Contains(string(body), v) {
+				prompts[v]++
+			}
+		}
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a.md", "b.md", "c.md", "d.md"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка "+name+"\n\nТекст заметки для обогащения"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[PROMPT]\ntext = Обогати заметку, вариант A\n" +
+		"[EXPERIMENT]\nname = tone\nprompt_b = Обогати заметку кратко, вариант B\n" +
+		"[STATE]\ndir = " + filepath.Join(tmpDir, "state") + "\n[EXCLUSIONS]\nexcluded_files =\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if prompts["вариант A"] != 2 || prompts["вариант B"] != 2 {
+		t.Errorf("Варианты должны чередоваться: %v", prompts)
+	}
+
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byVariant := make(map[string][]string)
+	for _, name := range names {
+		rec := latest[name]
+		if rec.Entry.Experiment != "tone" {
+			t.Errorf("%s: эксперимент в журнале %q", name, rec.Entry.Experiment)
+		}
+		byVariant[rec.Entry.Variant] = append(byVariant[rec.Entry.Variant], name)
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), VariantKey+": "+rec.Entry.Variant) {
+			t.Errorf("%s: результат должен быть отмечен вариантом %s:\n%s", name, rec.Entry.Variant, data)
+		}
+	}
+	if len(byVariant[VariantA]) != 2 || len(byVariant[VariantB]) != 2 {
+		t.Fatalf("Неожиданное распределение вариантов: %v", byVariant)
+	}
+
+	// Оценки проверяющих собираются по вариантам
+	labels := make(map[string]outputLabel)
+	for _, name := range byVariant[VariantA] {
+		labels[name] = outputLabel{Label: LabelRejected, At: time.Now(), RunID: latest[name].RunID}
+	}
+	for _, name := range byVariant[VariantB] {
+		labels[name] = outputLabel{Label: LabelAccepted, At: time.Now(), RunID: latest[name].RunID}
+	}
+	if err := saveLabels(config.StateDir, labels); err != nil {
+		t.Fatal(err)
+	}
+	outcomes := experimentOutcomes(latest, labels)["tone"]
+	if a, b := outcomes[VariantA], outcomes[VariantB]; a == nil || b == nil || a.Rejected != 2 || b.Accepted != 2 {
+		t.Fatalf("Неожиданные итоги вариантов: %+v", outcomes)
+	}
+	var out bytes.Buffer
+	if err := runLabelStatsCommand([]string{"-config", configPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Эксперимент tone") || !strings.Contains(out.String(), "100.0") {
+		t.Errorf("Неожиданный вывод rich label stats:\n%s", out.String())
+	}
+}
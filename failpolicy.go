@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Политики реакции на ошибки файлов (on_error)
+const (
+	// Ошибки файлов не останавливают запуск
+	OnErrorContinue = "continue"
+	// Запуск останавливается после первой ошибки файла
+	OnErrorFailFast = "fail-fast"
+	// Запуск останавливается после N ошибок или первой системной ошибки
+	OnErrorFailAfter = "fail-after"
+)
+
+// Число системных ошибок подряд с начала запуска, после которого запуск
+// останавливается как при неверной настройке провайдера
+const defaultSystemicAfter = 3
+
+// Системная ошибка: она повторится для каждого следующего файла (неверный ключ
+// API, ошибка конфигурации, неверный адрес или модель, имя сервера не найдено),
+// в отличие от ошибок отдельных файлов (размер, кодировка, ответ модели)
+func isSystemicError(err error) bool {
+	switch errorCategory(err) {
+	case ErrorAuth, ErrorConfig:
+		return true
+	}
+	var se *apiStatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return true
+	}
+	var de *net.DNSError
+	return errors.As(err, &de)
+}
+
+// Политика реакции на ошибки файлов
+type failurePolicy struct {
+	Mode string
+	// Ошибок до остановки для fail-after
+	After int
+}
+
+// Разбор значения on_error: continue, fail-fast или fail-after N
+func parseFailurePolicy(value string) (failurePolicy, error) {
+	fields := strings.Fields(strings.ToLower(strings.TrimSpace(value)))
+	switch {
+	case len(fields) == 0:
+		return failurePolicy{Mode: OnErrorContinue}, nil
+	case len(fields) == 1 && (fields[0] == OnErrorContinue || fields[0] == OnErrorFailFast):
+		return failurePolicy{Mode: fields[0]}, nil
+	case len(fields) == 2 && fields[0] == OnErrorFailAfter:
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return failurePolicy{}, errorf("некорректное число ошибок в on_error %q: ожидалось целое число от 1", value)
+		}
+		return failurePolicy{Mode: OnErrorFailAfter, After: n}, nil
+	}
+	return failurePolicy{}, errorf("некорректное значение on_error %q: ожидалось continue, fail-fast или fail-after N", value)
+}
+
+// Описание политики для журнала
+func (p failurePolicy) String() string {
+	if p.Mode == OnErrorFailAfter {
+		return p.Mode + " " + strconv.Itoa(p.After)
+	}
+	return p.Mode
+}
+
+// Учет ошибок файлов запуска по политике on_error. Независимо от политики запуск
+// останавливается, если первые systemicAfter файлов с запросами к API завершились
+// системной ошибкой: провайдер настроен неверно, и остальные файлы тоже не обработаются
+type failureTracker struct {
+	mu            sync.Mutex
+	policy        failurePolicy
+	systemicAfter int
+	failed        int
+	// Системных ошибок до первого успешного файла
+	systemic  int
+	succeeded bool
+	reason    string
+}
+
+// Создание учета ошибок запуска; systemicAfter 0 выключает остановку по системным ошибкам
+func newFailureTracker(policy failurePolicy, systemicAfter int) *failureTracker {
+	return &failureTracker{policy: policy, systemicAfter: systemicAfter}
+}
+
+// Учет итога файла с запросами к API; после срабатывания политики причина
+// остановки сохраняется
+func (t *failureTracker) Record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		t.succeeded = true
+		return
+	}
+	t.failed++
+	systemic := isSystemicError(err)
+	if systemic && !t.succeeded {
+		t.systemic++
+	}
+	if t.reason != "" {
+		return
+	}
+	category := errorCategory(err)
+	switch {
+	case t.systemicAfter > 0 && !t.succeeded && t.systemic >= t.systemicAfter:
+		t.reason = trf("провайдер настроен неверно: первые %d файлов завершились системной ошибкой (%s): %v", t.systemic, category, err)
+	case t.policy.Mode == OnErrorFailFast:
+		t.reason = trf("ошибка обработки файла, категория %s (on_error = %s)", category, t.policy)
+	case t.policy.Mode != OnErrorFailAfter:
+	case systemic:
+		t.reason = trf("системная ошибка категории %s (on_error = %s)", category, t.policy)
+	case t.failed >= t.policy.After:
+		t.reason = trf("файлов с ошибкой: %d (on_error = %s)", t.failed, t.policy)
+	}
+}
+
+// Политика не будет нарушена, если отправить еще один файл, пока inFlight файлов в
+// обработке: каждый из них может завершиться ошибкой. При fail-fast файлы поэтому
+// отправляются по одному, а до первого успешного файла в обработке не больше файлов,
+// чем осталось до остановки по системным ошибкам
+func (t *failureTracker) Admits(inFlight int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.systemicAfter > 0 && !t.succeeded && t.systemic+inFlight >= t.systemicAfter {
+		return false
+	}
+	switch t.policy.Mode {
+	case OnErrorFailFast:
+		return inFlight == 0
+	case OnErrorFailAfter:
+		return t.failed+inFlight < t.policy.After
+	}
+	return true
+}
+
+// Причина остановки запуска; false - запуск продолжается
+func (t *failureTracker) Stopped() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reason, t.reason != ""
+}
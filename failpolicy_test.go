@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    failurePolicy
+		wantErr bool
+	}{
+		{"", failurePolicy{Mode: OnErrorContinue}, false},
+		{"continue", failurePolicy{Mode: OnErrorContinue}, false},
+		{"Fail-Fast", failurePolicy{Mode: OnErrorFailFast}, false},
+		{"fail-after 5", failurePolicy{Mode: OnErrorFailAfter, After: 5}, false},
+		{" fail-after   2 ", failurePolicy{Mode: OnErrorFailAfter, After: 2}, false},
+		{"fail-after", failurePolicy{}, true},
+		{"fail-after 0", failurePolicy{}, true},
+		{"fail-after x", failurePolicy{}, true},
+		{"stop", failurePolicy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFailurePolicy(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFailurePolicy(%q) ошибка = %v, ожидалась ошибка: %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseFailurePolicy(%q) = %+v, ожидалось %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	providerErr := &apiStatusError{StatusCode: http.StatusInternalServerError}
+	authErr := &apiStatusError{StatusCode: http.StatusUnauthorized}
+
+	continueTracker := newFailureTracker(failurePolicy{Mode: OnErrorContinue}, 0)
+	for i := 0; i < 10; i++ {
+		continueTracker.Record(authErr)
+	}
+	if _, stopped := continueTracker.Stopped(); stopped {
+		t.Error("continue не должен останавливать запуск")
+	}
+
+	fast := newFailureTracker(failurePolicy{Mode: OnErrorFailFast}, 0)
+	fast.Record(nil)
+	if _, stopped := fast.Stopped(); stopped {
+		t.Error("Успешный файл не должен останавливать запуск")
+	}
+	fast.Record(providerErr)
+	if _, stopped := fast.Stopped(); !stopped {
+		t.Error("fail-fast должен останавливать запуск после первой ошибки")
+	}
+
+	after := newFailureTracker(failurePolicy{Mode: OnErrorFailAfter, After: 3}, 0)
+	after.Record(providerErr)
+	after.Record(errors.New("ошибка"))
+	if _, stopped := after.Stopped(); stopped {
+		t.Error("fail-after 3 не должен останавливать запуск после двух ошибок")
+	}
+	after.Record(providerErr)
+	if _, stopped := after.Stopped(); !stopped {
+		t.Error("fail-after 3 должен останавливать запуск после трех ошибок")
+	}
+
+	systemic := newFailureTracker(failurePolicy{Mode: OnErrorFailAfter, After: 100}, 0)
+	systemic.Record(withCategory(ErrorConfig, errors.New("нет ключа")))
+	if _, stopped := systemic.Stopped(); !stopped {
+		t.Error("Системная ошибка должна останавливать запуск сразу")
+	}
+}
+
+func TestFailureTrackerSystemic(t *testing.T) {
+	authErr := &apiStatusError{StatusCode: http.StatusUnauthorized}
+	notFound := &apiStatusError{StatusCode: http.StatusNotFound}
+	dnsErr := fmt.Errorf("ошибка запроса: %w", &net.DNSError{Err: "no such host", Name: "api.example.com", IsNotFound: true})
+	fileErr := withCategory(ErrorValidation, errors.New("файл слишком большой"))
+	for _, err := range []error{authErr, notFound, dnsErr, withCategory(ErrorConfig, errors.New("нет ключа"))} {
+		if !isSystemicError(err) {
+			t.Errorf("Ошибка %v должна считаться системной", err)
+		}
+	}
+	for _, err := range []error{fileErr, &apiStatusError{StatusCode: http.StatusInternalServerError}, errors.New("ошибка")} {
+		if isSystemicError(err) {
+			t.Errorf("Ошибка %v не должна считаться системной", err)
+		}
+	}
+
+	// Системные ошибки с начала запуска останавливают его и при on_error = continue;
+	// ошибки отдельных файлов не учитываются
+	tracker := newFailureTracker(failurePolicy{Mode: OnErrorContinue}, 3)
+	tracker.Record(notFound)
+	tracker.Record(fileErr)
+	tracker.Record(dnsErr)
+	if _, stopped := tracker.Stopped(); stopped {
+		t.Error("Две системные ошибки не должны останавливать запуск при systemic_after = 3")
+	}
+	tracker.Record(notFound)
+	if reason, stopped := tracker.Stopped(); !stopped || !strings.Contains(reason, "провайдер настроен неверно") {
+		t.Errorf("Три системные ошибки с начала запуска должны останавливать его, причина: %q", reason)
+	}
+
+	// После успешного файла провайдер считается настроенным верно
+	tracker = newFailureTracker(failurePolicy{Mode: OnErrorContinue}, 2)
+	tracker.Record(nil)
+	for i := 0; i < 5; i++ {
+		tracker.Record(notFound)
+	}
+	if _, stopped := tracker.Stopped(); stopped {
+		t.Error("Системные ошибки после успешного файла не должны останавливать запуск при on_error = continue")
+	}
+}
+
+func TestFailurePolicyRun(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		http.Error(w, `{"error": "invalid api key"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md", "d.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка\n\nТекст"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[NETWORK]\nretries = 0\n[PROCESSING]\non_error = fail-after 3\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+
+	err = processDirectory(config, configPath)
+	var failed *filesFailedError
+	if !errors.As(err, &failed) || failed.Categories[ErrorAuth] != 1 {
+		t.Fatalf("Ожидалась одна ошибка авторизации, получено: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("После ошибки авторизации запуск должен остановиться, запросов к API: %d", calls)
+	}
+
+	// on_error = continue: запуск останавливается после systemic_after системных ошибок подряд
+	config.OnError = failurePolicy{Mode: OnErrorContinue}
+	config.SystemicAfter = 2
+	calls = 0
+	err = processDirectory(config, configPath)
+	if !errors.As(err, &failed) || failed.Categories[ErrorAuth] != 2 {
+		t.Fatalf("Ожидались две ошибки авторизации, получено: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("После двух системных ошибок запуск должен остановиться, запросов к API: %d", calls)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Действия с файлом, обогащение которого не удалось (on_failure секции [PROCESSING])
+const (
+	// Выходной файл не записывается (по умолчанию)
+	OnFailureSkip = "skip"
+	// В выходной файл записывается оригинал с отметкой об ошибке
+	OnFailureOriginal = "original"
+	// Записывается прежнее обогащение файла с отметкой об ошибке, без него - оригинал
+	OnFailureCached = "cached"
+)
+
+// Начало отметки о неудачном обогащении в выходном файле
+const failureMarkerPrefix = "<!-- rich: enrichment failed: "
+
+// Наибольшая длина причины в отметке
+const failureMarkerMaxReason = 300
+
+// Проверка действия с файлом, обогащение которого не удалось
+func validateOnFailure(action string) error {
+	if action != OnFailureSkip && action != OnFailureOriginal && action != OnFailureCached {
+		return errorf("некорректное значение on_failure %q: ожидалось %s, %s или %s", action, OnFailureSkip, OnFailureOriginal, OnFailureCached)
+	}
+	return nil
+}
+
+// Отметка о неудачном обогащении: HTML комментарий в одну строку, причина без
+// переводов строк и без "--", которые закрыли бы комментарий раньше времени
+func failureMarker(reason error) string {
+	text := strings.Join(strings.Fields(reason.Error()), " ")
+	text = strings.ReplaceAll(text, "--", "- -")
+	if r := []rune(text); len(r) > failureMarkerMaxReason {
+		text = string(r[:failureMarkerMaxReason]) + "…"
+	}
+	return failureMarkerPrefix + text + " -->"
+}
+
+// Вставка отметки в начало документа после frontmatter: поля frontmatter остаются
+// доступны генераторам сайтов
+func withFailureMarker(doc []byte, marker string) []byte {
+	_, body, _ := parseFrontmatter(doc)
+	head := doc[:len(doc)-len(body)]
+	var b bytes.Buffer
+	b.Write(head)
+	b.WriteString(marker + "\n\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+// Выходной файл записан после неудачного обогащения и содержит отметку
+func hasFailureMarker(content []byte) bool {
+	_, body, _ := parseFrontmatter(content)
+	return bytes.HasPrefix(bytes.TrimLeft(body, " \t\r\n"), []byte(failureMarkerPrefix))
+}
+
+// Запись копии файла, обогащение которого окончательно не удалось, чтобы в выходной
+// директории не было пропусков: оригинал или прежнее обогащение с отметкой ошибки.
+// Результат, измененный вручную, не перезаписывается; файл остается необработанным
+// и обогащается следующим запуском
+func (s *session) writeFallback(config *Config, key, inputPath, outputDir, outputPath string, result *fileResult, failure error) {
+	if config.OnFailure == "" || config.OnFailure == OnFailureSkip || result.Status != StatusFailed || s.txn != nil || !config.Sinks.WritesLocal() {
+		return
+	}
+	if !isMarkdownPath(inputPath) || !isMarkdownPath(outputPath) {
+		return
+	}
+	// Результат, переименованный по заголовку или по шаблону маршрута
+	if result.Output != "" {
+		rel := result.Output
+		if config.RootName != "" {
+			rel = strings.TrimPrefix(rel, config.RootName+"/")
+		}
+		outputPath = filepath.Join(outputDir, filepath.FromSlash(rel))
+	}
+	if current, err := os.ReadFile(outputPath); err == nil && !hasFailureMarker(current) && s.outputConflict(key, outputPath) {
+		warnf("Предупреждение: %s изменен вручную, копия после ошибки обогащения не записана", outputPath)
+		return
+	}
+
+	content, source := s.cachedOutput(config, key), OnFailureCached
+	if config.OnFailure != OnFailureCached || content == nil {
+		data, err := os.ReadFile(inputPath)
+		if err != nil {
+			warnf("Предупреждение: не удалось прочитать %s для копии после ошибки: %v", inputPath, err)
+			return
+		}
+		content, source = data, OnFailureOriginal
+	}
+	content = withFailureMarker(content, failureMarker(failure))
+
+	if s.journal != nil {
+		backup, err := s.journal.Backup(outputPath, key)
+		if err != nil {
+			warnf("Предупреждение: ошибка при резервном копировании выходного файла: %v", err)
+			return
+		}
+		result.Backup = backup
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		warnf("Предупреждение: ошибка при создании выходной директории: %v", err)
+		return
+	}
+	if err := safeWriteFile(outputPath, content, 0644); err != nil {
+		warnf("Предупреждение: не удалось записать копию после ошибки обогащения %s: %v", outputPath, err)
+		return
+	}
+	result.Fallback, result.Written, result.OutputHash = outputPath, outputPath, contentHash(content)
+	if source == OnFailureCached {
+		warnf("Предупреждение: обогащение %s не удалось, в %s оставлено прежнее обогащение с отметкой об ошибке", key, outputPath)
+	} else {
+		warnf("Предупреждение: обогащение %s не удалось, в %s записан оригинал с отметкой об ошибке", key, outputPath)
+	}
+}
+
+// Прежнее обогащение файла по журналу запусков: копия из каталога состояния или
+// выходной файл, не измененный после записи (nil - прежнего обогащения нет)
+func (s *session) cachedOutput(config *Config, key string) []byte {
+	rec, ok := s.recorded[normalizeRelPath(key)]
+	if !ok || rec.Entry.OutputHash == "" {
+		return nil
+	}
+	if config.StateDir != "" {
+		if data, ok := loadOutputSnapshot(config.StateDir, rec.Entry.OutputHash); ok {
+			return data
+		}
+	}
+	if data, err := os.ReadFile(rec.Entry.Output); err == nil && contentHash(data) == rec.Entry.OutputHash {
+		return data
+	}
+	return nil
+}
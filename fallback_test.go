@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFailureMarker(t *testing.T) {
+	marker := failureMarker(errors.New("ответ API:\n--> 400 Bad Request"))
+	if marker != failureMarkerPrefix+"ответ API: - -> 400 Bad Request -->" {
+		t.Errorf("failureMarker() = %q", marker)
+	}
+	doc := withFailureMarker([]byte("---\ntitle: A\n---\n# A\n"), marker)
+	if !strings.HasPrefix(string(doc), "---\ntitle: A\n---\n"+marker+"\n\n# A") {
+		t.Errorf("отметка не после frontmatter:\n%s", doc)
+	}
+	if !hasFailureMarker(doc) || !hasFailureMarker(withFailureMarker([]byte("# A"), marker)) {
+		t.Error("отметка не найдена")
+	}
+	if hasFailureMarker([]byte("# A\n\n" + marker)) {
+		t.Error("отметка в конце документа принята за отметку rich")
+	}
+	if err := validateOnFailure("ignore"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного значения on_failure")
+	}
+}
+
+func TestOnFailureFallback(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.md": "# A\n\nТекст A", "b.md": "# B\n\nТекст B"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fail, answer := map[string]bool{"b.md": true}, "Первое обогащение"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		for _, m := range req.Messages {
+			if (fail["a.md"] && strings.Contains(m.Content, "Текст A")) || (fail["b.md"] && strings.Contains(m.Content, "Текст B")) {
+				http.Error(w, `{"error": "bad request"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": map[string]string{
+			"role": "assistant", "content": answer}}}})
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100,
+		OnFailure: OnFailureCached, ReportFile: filepath.Join(tmpDir, "report.json")}
+	run := func() {
+		t.Helper()
+		var failed *filesFailedError
+		if err := processDirectory(config, configPath); err != nil && !errors.As(err, &failed) {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+
+	// Файл без прежнего обогащения: в выходной директории оригинал с отметкой
+	run()
+	outputB := filepath.Join(outputDir, "b.md")
+	data, err := os.ReadFile(outputB)
+	if err != nil || !hasFailureMarker(data) || !strings.Contains(string(data), "Текст B") {
+		t.Fatalf("копия после ошибки: %q, %v", data, err)
+	}
+	report := &runReport{}
+	if data, err := os.ReadFile(config.ReportFile); err != nil || json.Unmarshal(data, report) != nil {
+		t.Fatalf("отчет о запуске: %v", err)
+	}
+	fallbacks := 0
+	for _, f := range report.Files {
+		if f.Fallback != "" {
+			fallbacks++
+			if f.Path != "b.md" || f.Status != StatusFailed || !samePath(f.Fallback, outputB) {
+				t.Errorf("копия в отчете: %+v", f)
+			}
+		}
+	}
+	if fallbacks != 1 {
+		t.Errorf("копий в отчете %d, ожидалась одна", fallbacks)
+	}
+	if _, problems, err := verifyOutputs(config); err != nil || len(problems) != 1 || problems[0].Reason != VerifyFailed {
+		t.Errorf("verifyOutputs() = %v, %v", problems, err)
+	}
+
+	// Прежнее обогащение остается в выходной директории с отметкой об ошибке
+	fail = map[string]bool{"a.md": true}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# A\n\nТекст A изменен"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	answer = "Второе обогащение"
+	run()
+	outputA := filepath.Join(outputDir, "a.md")
+	data, _ = os.ReadFile(outputA)
+	if !hasFailureMarker(data) || !strings.Contains(string(data), "Первое обогащение") {
+		t.Errorf("копия прежнего обогащения:\n%s", data)
+	}
+	if data, _ := os.ReadFile(outputB); hasFailureMarker(data) || !strings.Contains(string(data), "Второе обогащение") {
+		t.Errorf("копия с отметкой не заменена обогащением:\n%s", data)
+	}
+
+	// Копия с отметкой не считается ручной правкой: следующее обогащение ее заменяет
+	fail = nil
+	run()
+	if data, _ := os.ReadFile(outputA); hasFailureMarker(data) || !strings.Contains(string(data), "Второе обогащение") {
+		t.Errorf("копия с отметкой не заменена обогащением:\n%s", data)
+	}
+	if _, err := os.Stat(outputA + ConflictSuffix); !os.IsNotExist(err) {
+		t.Error("копия с отметкой принята за ручную правку")
+	}
+
+	// Результат, измененный вручную, не заменяется копией
+	fail = map[string]bool{"a.md": true}
+	if err := os.WriteFile(outputA, []byte("Правка редактора"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# A\n\nТекст A изменен снова"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run()
+	if data, _ := os.ReadFile(outputA); string(data) != "Правка редактора" {
+		t.Errorf("ручная правка заменена копией после ошибки:\n%s", data)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Сведения о файле, одинаковые для всех точек расширения: подстановок промпта,
+// шаблона подписи, шаблона output маршрута и команды приемника command
+type fileContext struct {
+	// Путь относительно входной директории с прямыми слешами, имя файла без
+	// расширения и директория
+	Path string `json:"path"`
+	Name string `json:"name"`
+	Dir  string `json:"dir"`
+	// Теги и поля frontmatter исходного документа
+	Tags        []string    `json:"tags"`
+	Frontmatter Frontmatter `json:"frontmatter"`
+	// Слов в исходном документе и в результате (0 до обогащения)
+	Words         int `json:"words"`
+	EnrichedWords int `json:"enriched_words"`
+	// Язык исходного документа ("" - не определен) и маршрут ("" - без маршрута)
+	Language string `json:"language"`
+	Route    string `json:"route"`
+}
+
+// Подстановки сведений о файле; поля frontmatter - {{fm.<поле>}}
+var fileVariables = []string{"{{path}}", "{{name}}", "{{dir}}", "{{tags}}", "{{words}}", "{{enriched_words}}",
+	"{{language}}", "{{language_name}}", "{{route}}", "{{fm.<поле>}}"}
+
+// Подстановка сведений о файле: {{имя}} или {{fm.<поле>}} без пробелов внутри скобок
+var fileVariablePattern = regexp.MustCompile(`\{\{(fm\.[^{}\s]+|[a-z_]+)\}\}`)
+
+// Сведения о файле relPath по его содержимому
+func newFileContext(relPath string, content []byte, lang, route string) fileContext {
+	fm, body, _ := parseFrontmatter(content)
+	slashPath := filepath.ToSlash(relPath)
+	return fileContext{
+		Path:        slashPath,
+		Name:        strings.TrimSuffix(path.Base(slashPath), path.Ext(slashPath)),
+		Dir:         path.Dir(slashPath),
+		Tags:        fm.List("tags"),
+		Frontmatter: fm,
+		Words:       computeTextMetrics(string(body), lang).Words,
+		Language:    lang,
+		Route:       route,
+	}
+}
+
+// Слов в документе без frontmatter
+func documentWords(text, lang string) int {
+	_, body, _ := parseFrontmatter([]byte(text))
+	return computeTextMetrics(string(body), lang).Words
+}
+
+// Значение подстановки без скобок; false - подстановка неизвестна
+func (f fileContext) lookup(name string) (string, bool) {
+	if field, ok := strings.CutPrefix(name, "fm."); ok {
+		return f.Frontmatter[field], true
+	}
+	switch name {
+	case "path":
+		return f.Path, true
+	case "name":
+		return f.Name, true
+	case "dir":
+		return f.Dir, true
+	case "tags":
+		return strings.Join(f.Tags, ", "), true
+	case "words":
+		return strconv.Itoa(f.Words), true
+	case "enriched_words":
+		return strconv.Itoa(f.EnrichedWords), true
+	case "language":
+		if f.Language == "" {
+			return "unknown", true
+		}
+		return f.Language, true
+	case "language_name":
+		if n, ok := languageNames[f.Language]; ok {
+			return n, true
+		}
+		return "the document's original language", true
+	case "route":
+		return f.Route, true
+	}
+	return "", false
+}
+
+// Замена подстановок сведениями о файле; неизвестные подстановки остаются как есть
+func (f fileContext) Expand(text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return fileVariablePattern.ReplaceAllStringFunc(text, func(v string) string {
+		if value, ok := f.lookup(v[2 : len(v)-2]); ok {
+			return value
+		}
+		return v
+	})
+}
+
+// Переменные окружения команд: RICH_FILE_PATH, RICH_FILE_TAGS (через запятую),
+// RICH_FILE_FRONTMATTER (объект JSON) и остальные поля
+func (f fileContext) Env() []string {
+	fm, _ := json.Marshal(f.Frontmatter)
+	if f.Frontmatter == nil {
+		fm = []byte("{}")
+	}
+	return []string{
+		"RICH_FILE_PATH=" + f.Path,
+		"RICH_FILE_NAME=" + f.Name,
+		"RICH_FILE_DIR=" + f.Dir,
+		"RICH_FILE_TAGS=" + strings.Join(f.Tags, ","),
+		"RICH_FILE_FRONTMATTER=" + string(fm),
+		"RICH_FILE_WORDS=" + strconv.Itoa(f.Words),
+		"RICH_FILE_ENRICHED_WORDS=" + strconv.Itoa(f.EnrichedWords),
+		"RICH_FILE_LANGUAGE=" + f.Language,
+		"RICH_FILE_ROUTE=" + f.Route,
+	}
+}
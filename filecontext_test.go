@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileContextExpand(t *testing.T) {
+	content := []byte("---\ntitle: Заметка\ntags: [go, api]\naudience: разработчики\n---\n# Заметка\n\nТекст из пяти слов здесь.\n")
+	file := newFileContext(filepath.Join("docs", "api", "note.md"), content, "ru", "technical")
+	if file.Path != "docs/api/note.md" || file.Name != "note" || file.Dir != "docs/api" || file.Words != 5 {
+		t.Fatalf("newFileContext() = %+v", file)
+	}
+
+	got := file.Expand("{{path}} {{name}} {{dir}} [{{tags}}] {{words}} {{language}} {{language_name}} {{route}} {{fm.audience}} {{fm.missing}}|{{audience}} {{ path }}")
+	want := "docs/api/note.md note docs/api [go, api] 5 ru Russian technical разработчики |{{audience}} {{ path }}"
+	if got != want {
+		t.Errorf("Expand() = %q\nожидалось %q", got, want)
+	}
+	if got := (fileContext{}).Expand("{{language}} / {{language_name}}"); got != "unknown / the document's original language" {
+		t.Errorf("Язык не определен: %q", got)
+	}
+
+	env := make(map[string]string)
+	for _, kv := range file.Env() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	var fm map[string]string
+	if err := json.Unmarshal([]byte(env["RICH_FILE_FRONTMATTER"]), &fm); err != nil || fm["audience"] != "разработчики" {
+		t.Errorf("RICH_FILE_FRONTMATTER = %q, %v", env["RICH_FILE_FRONTMATTER"], err)
+	}
+	if env["RICH_FILE_TAGS"] != "go,api" || env["RICH_FILE_ROUTE"] != "technical" || env["RICH_FILE_WORDS"] != "5" {
+		t.Errorf("Переменные окружения: %v", env)
+	}
+}
+
+func TestFileContextExtensionPoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("команда приемника - сценарий sh")
+	}
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "blog"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	note := "---\ntitle: Итоги года\ntags: [итоги]\naudience: друзья\n---\n# Итоги года\n\nКороткая заметка об итогах года.\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "blog", "year.md"), []byte(note), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		prompt = string(body)
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": map[string]string{
+			"role": "assistant", "content": "# Итоги года\n\nПодробная заметка об итогах прошедшего года."}}}})
+	}))
+	defer server.Close()
+
+	hookOut := filepath.Join(tmpDir, "hook.txt")
+	hook := filepath.Join(tmpDir, "hook.sh")
+	script := "#!/bin/sh\necho \"$RICH_OUTPUT_PATH $RICH_FILE_PATH $RICH_FILE_ROUTE $RICH_FILE_TAGS $RICH_FILE_WORDS\" > " + hookOut + "\n"
+	if err := os.WriteFile(hook, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelName: "test-model", ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100,
+		Prompt:     "Enrich for {{fm.audience}}.",
+		Disclosure: true, DisclosureTemplate: "*{{path}}: {{words}} -> {{enriched_words}}*",
+		Routes: []Route{{Name: "blog", Tags: []string{"итоги"}, Prompt: "Enrich {{name}} for {{fm.audience}} ({{route}})."}},
+		Sinks:  sinksConfig{Sinks: []string{SinkLocal, SinkCommand}, Command: hook, CommandTimeout: 10 * time.Second}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if !strings.Contains(prompt, "Enrich year for друзья (blog).") {
+		t.Errorf("Подстановки промпта не заменены: %s", prompt)
+	}
+	output, err := os.ReadFile(filepath.Join(outputDir, "blog", "year.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "*blog/year.md: 5 -> 6*") {
+		t.Errorf("Подпись не содержит сведений о файле:\n%s", output)
+	}
+	hookData, err := os.ReadFile(hookOut)
+	if err != nil {
+		t.Fatalf("Команда приемника не запускалась: %v", err)
+	}
+	if got := strings.TrimSpace(string(hookData)); got != "blog/year.md blog/year.md blog итоги 5" {
+		t.Errorf("Окружение команды: %q", got)
+	}
+}
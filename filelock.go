@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sync"
+)
+
+// Блокировки файлов внутри процесса (flock не различает потоки одного процесса)
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*sync.Mutex)
+)
+
+// Выполнение fn под эксклюзивной блокировкой файла: внутри процесса - мьютексом,
+// между процессами - блокировкой файла <path>.lock
+func withFileLock(path string, fn func() error) error {
+	fileLocksMu.Lock()
+	mu, ok := fileLocks[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		fileLocks[path] = mu
+	}
+	fileLocksMu.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestAddToExcludedFilesConcurrent(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- addToExcludedFiles(configPath, fmt.Sprintf("file%02d.md", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("addToExcludedFiles() вернул ошибку: %v", err)
+		}
+	}
+
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Ошибка чтения конфигурации: %v", err)
+	}
+	excluded := strings.Split(cfg.Section("EXCLUSIONS").Key("excluded_files").String(), ",")
+	if len(excluded) != n {
+		t.Errorf("Ожидалось %d исключенных файлов, получено %d: %v", n, len(excluded), excluded)
+	}
+}
+
+func TestWithFileLockSerializes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	var wg sync.WaitGroup
+	inside := 0
+	maxInside := 0
+	var mu sync.Mutex
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := withFileLock(path, func() error {
+				mu.Lock()
+				inside++
+				if inside > maxInside {
+					maxInside = inside
+				}
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				inside--
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("withFileLock() вернул ошибку: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInside != 1 {
+		t.Errorf("Под блокировкой одновременно выполнялось %d функций", maxInside)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Файл блокировки старше этого срока считается оставшимся после аварийного завершения
+const staleLockAge = time.Minute
+
+// Эксклюзивная блокировка через создание файла блокировки (flock на Windows недоступен)
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(2 * staleLockAge)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errorf("не удалось создать файл блокировки: %v", err)
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errorf("не удалось заблокировать файл %s: превышено время ожидания", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые форматы абсолютных дат для фильтров по времени изменения
+var timeFilterLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Разбор значения фильтра по времени: абсолютная дата или относительный срок ("36h", "7d")
+func parseTimeFilter(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeFilterLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	// Относительный срок в днях
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	// Относительный срок в формате Go (например, 90m или 36h)
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, errorf("некорректное значение даты: %s (ожидается YYYY-MM-DD, RFC3339 или срок вида 36h, 7d)", value)
+}
+
+// Проверка попадания времени изменения файла в заданный интервал
+func modTimeInRange(modTime, after, before time.Time) bool {
+	if !after.IsZero() && !modTime.After(after) {
+		return false
+	}
+	if !before.IsZero() && !modTime.Before(before) {
+		return false
+	}
+	return true
+}
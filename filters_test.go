@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeFilter(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.Local)
+
+	testCases := []struct {
+		value    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"", time.Time{}, false},
+		{"2024-06-01", time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), false},
+		{"2024-06-01 08:30", time.Date(2024, 6, 1, 8, 30, 0, 0, time.Local), false},
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"36h", now.Add(-36 * time.Hour), false},
+		{"вчера", time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseTimeFilter(tc.value, now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseTimeFilter(%q) ошибка = %v, ожидалась ошибка: %v", tc.value, err, tc.wantErr)
+			}
+			if !got.Equal(tc.expected) {
+				t.Errorf("parseTimeFilter(%q) = %v, ожидалось %v", tc.value, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestModTimeInRange(t *testing.T) {
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	after := base.AddDate(0, 0, -1)
+	before := base.AddDate(0, 0, 1)
+
+	if !modTimeInRange(base, time.Time{}, time.Time{}) {
+		t.Error("Без фильтров файл должен проходить")
+	}
+	if !modTimeInRange(base, after, before) {
+		t.Error("Файл внутри интервала должен проходить")
+	}
+	if modTimeInRange(base, before, time.Time{}) {
+		t.Error("Файл старше modified-after не должен проходить")
+	}
+	if modTimeInRange(base, time.Time{}, after) {
+		t.Error("Файл новее modified-before не должен проходить")
+	}
+}
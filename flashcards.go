@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Виды карточек
+const (
+	// Вопрос и ответ (тип записи Anki Basic)
+	CardsQA = "qa"
+	// Пропуски в тексте {{c1::...}} (тип записи Anki Cloze)
+	CardsCloze = "cloze"
+)
+
+// Форматы файла карточек
+const (
+	CardsTSV = "tsv"
+	CardsCSV = "csv"
+)
+
+// Инструкции модели для извлечения карточек
+const (
+	cardsQAInstruction    = `Extract up to %d question and answer pairs that test the key facts and ideas of the markdown document below, for spaced repetition flashcards. Each question must be understandable without the document, each answer short. Write them in the language of the document. Reply with a single JSON object {"cards": [{"question": "...", "answer": "..."}]} and nothing else.`
+	cardsClozeInstruction = `Extract up to %d cloze deletion flashcards that test the key facts and ideas of the markdown document below. Each card is one self-contained sentence in the language of the document where key terms are wrapped as {{c1::term}} (use c1, c2, ... for several deletions in one card). Reply with a single JSON object {"cards": [{"text": "..."}]} and nothing else.`
+)
+
+// Извлечение карточек для интервального повторения из секции [FLASHCARDS]
+type FlashcardConfig struct {
+	Enabled bool
+	// qa или cloze
+	Kind string
+	// Максимум карточек на документ
+	MaxCards int
+	// tsv или csv
+	Format string
+	// Колода Anki ("" - выбирается при импорте)
+	Deck string
+}
+
+// Чтение секции [FLASHCARDS]
+func loadFlashcardConfig(section *ini.Section) (FlashcardConfig, error) {
+	cards := FlashcardConfig{
+		Enabled:  section.Key("enabled").MustBool(false),
+		Kind:     strings.ToLower(section.Key("kind").MustString(CardsQA)),
+		MaxCards: section.Key("max_cards").MustInt(10),
+		Format:   strings.ToLower(section.Key("format").MustString(CardsTSV)),
+		Deck:     section.Key("deck").String(),
+	}
+	if cards.Kind != CardsQA && cards.Kind != CardsCloze {
+		return cards, errorf("неизвестный вид карточек %q в секции [FLASHCARDS]: ожидалось qa или cloze", cards.Kind)
+	}
+	if cards.Format != CardsTSV && cards.Format != CardsCSV {
+		return cards, errorf("неизвестный формат карточек %q в секции [FLASHCARDS]: ожидалось tsv или csv", cards.Format)
+	}
+	if cards.MaxCards <= 0 {
+		return cards, errorf("max_cards в секции [FLASHCARDS] должен быть положительным: %d", cards.MaxCards)
+	}
+	return cards, nil
+}
+
+// Карточка: вопрос и ответ или текст с пропусками
+type flashcard struct {
+	Question string `json:"question,omitempty"`
+	Answer   string `json:"answer,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+// Запрос карточек по обогащенному документу
+func extractFlashcards(config *Config, doc string, limiter *RateLimiter) ([]flashcard, Usage, error) {
+	cardsConfig := *config
+	instruction := cardsQAInstruction
+	if config.Flashcards.Kind == CardsCloze {
+		instruction = cardsClozeInstruction
+	}
+	cardsConfig.Prompt = fmt.Sprintf(instruction, config.Flashcards.MaxCards)
+	// Примеры и правила ответа относятся к обогащению, а не к карточкам
+	cardsConfig.Examples = nil
+	cardsConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&cardsConfig, doc, limiter)
+	if err != nil {
+		return nil, usage, err
+	}
+	cards, err := parseFlashcards(response, config.Flashcards.Kind, config.Flashcards.MaxCards)
+	return cards, usage, err
+}
+
+// Разбор ответа модели: JSON объект с карточками, возможно окруженный текстом или
+// блоком кода. Неполные карточки и карточки с пропусками без {{c..::}} отбрасываются
+func parseFlashcards(response, kind string, maxCards int) ([]flashcard, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, errorf("в ответе модели нет карточек: %q", snippet(response, searchSnippetRunes))
+	}
+	var data struct {
+		Cards []flashcard `json:"cards"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &data); err != nil {
+		return nil, errorf("некорректный ответ модели с карточками: %v", err)
+	}
+	var cards []flashcard
+	invalid := 0
+	for _, c := range data.Cards {
+		c.Question, c.Answer, c.Text = strings.TrimSpace(c.Question), strings.TrimSpace(c.Answer), strings.TrimSpace(c.Text)
+		valid := c.Question != "" && c.Answer != ""
+		if kind == CardsCloze {
+			valid = strings.Contains(c.Text, "{{c") && strings.Contains(c.Text, "::")
+		}
+		if !valid {
+			invalid++
+		} else if len(cards) < maxCards {
+			cards = append(cards, c)
+		}
+	}
+	if invalid > 0 {
+		logf("Отброшено неполных карточек: %d", invalid)
+	}
+	return cards, nil
+}
+
+// Путь файла карточек рядом с выходным файлом: notes/a.md -> notes/a.cards.tsv
+func cardsPath(outputPath, format string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".cards." + format
+}
+
+// Файл карточек для импорта в Anki: заголовки с разделителем, типом записи и
+// колонкой тегов, затем по строке на карточку. Тег - путь документа (вложенные
+// директории - иерархия тегов Anki через ::)
+func renderFlashcards(fc FlashcardConfig, relPath string, cards []flashcard) []byte {
+	var b bytes.Buffer
+	separator, comma := "tab", '\t'
+	if fc.Format == CardsCSV {
+		separator, comma = "comma", ','
+	}
+	notetype := "Basic"
+	if fc.Kind == CardsCloze {
+		notetype = "Cloze"
+	}
+	fmt.Fprintf(&b, "#separator:%s\n#html:false\n#notetype:%s\n", separator, notetype)
+	if fc.Deck != "" {
+		fmt.Fprintf(&b, "#deck:%s\n", fc.Deck)
+	}
+	b.WriteString("#tags column:3\n")
+
+	rel := normalizeRelPath(relPath)
+	tag := strings.ReplaceAll(strings.TrimSuffix(rel, path.Ext(rel)), "/", "::")
+	tag = strings.Join(strings.Fields(tag), "_")
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	for _, c := range cards {
+		record := []string{c.Question, c.Answer, tag}
+		if fc.Kind == CardsCloze {
+			record = []string{c.Text, "", tag}
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	return b.Bytes()
+}
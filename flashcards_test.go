@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFlashcards(t *testing.T) {
+	response := "```json\n{\"cards\": [{\"question\": \"Что хранит брокер?\", \"answer\": \"Партиции\"}, {\"question\": \"Без ответа\"}]}\n```"
+	cards, err := parseFlashcards(response, CardsQA, 10)
+	if err != nil {
+		t.Fatalf("parseFlashcards() вернул ошибку: %v", err)
+	}
+	if len(cards) != 1 || cards[0].Answer != "Партиции" {
+		t.Errorf("карточки: %+v", cards)
+	}
+
+	cloze := `{"cards": [{"text": "Брокер хранит {{c1::партиции}}"}, {"text": "Без пропусков"}, {"text": "{{c1::Реплики}} копируют данные"}]}`
+	if cards, _ = parseFlashcards(cloze, CardsCloze, 1); len(cards) != 1 || !strings.Contains(cards[0].Text, "партиции") {
+		t.Errorf("карточки с пропусками: %+v", cards)
+	}
+	if _, err := parseFlashcards("Карточек нет", CardsQA, 10); err == nil {
+		t.Error("ожидалась ошибка для ответа без JSON")
+	}
+}
+
+func TestRenderFlashcards(t *testing.T) {
+	cards := []flashcard{{Question: "Что такое\tпартиция?", Answer: "Часть \"топика\""}}
+	got := string(renderFlashcards(FlashcardConfig{Kind: CardsQA, Format: CardsTSV, Deck: "Kafka"}, "notes/my kafka.md", cards))
+	want := "#separator:tab\n#html:false\n#notetype:Basic\n#deck:Kafka\n#tags column:3\n\"Что такое\tпартиция?\"\t\"Часть \"\"топика\"\"\"\tnotes::my_kafka\n"
+	if got != want {
+		t.Errorf("файл карточек:\n%q\nожидалось\n%q", got, want)
+	}
+	got = string(renderFlashcards(FlashcardConfig{Kind: CardsCloze, Format: CardsCSV}, "a.md", []flashcard{{Text: "{{c1::Kafka}} - брокер"}}))
+	if !strings.Contains(got, "#separator:comma\n") || !strings.Contains(got, "#notetype:Cloze\n") || !strings.HasSuffix(got, "{{c1::Kafka}} - брокер,,a\n") {
+		t.Errorf("файл карточек с пропусками:\n%s", got)
+	}
+}
+
+func TestFlashcardsWrittenWithOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "kafka.md"), []byte("# Kafka\n\nБрокер хранит партиции."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		content := "# Kafka\\n\\nОбогащенный текст"
+		if strings.Contains(string(body), "question and answer pairs") {
+			content = `{\"cards\": [{\"question\": \"Что хранит брокер?\", \"answer\": \"Партиции\"}]}`
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"), Transactional: true,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Flashcards: FlashcardConfig{Enabled: true, Kind: CardsQA, MaxCards: 5, Format: CardsTSV}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "kafka.cards.tsv"))
+	if err != nil {
+		t.Fatalf("файл карточек не записан: %v", err)
+	}
+	if !strings.HasSuffix(string(data), "Что хранит брокер?\tПартиции\tkafka\n") {
+		t.Errorf("файл карточек:\n%s", data)
+	}
+	if output, _ := os.ReadFile(filepath.Join(outputDir, "kafka.md")); strings.Contains(string(output), "Партиции\"") {
+		t.Errorf("карточки попали в обогащенный документ:\n%s", output)
+	}
+}
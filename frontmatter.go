@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Поля YAML frontmatter markdown-файла (простые пары ключ: значение и списки)
+type Frontmatter map[string]string
+
+// Разбор frontmatter в начале markdown-файла.
+// Возвращает поля, тело документа без frontmatter и признак наличия frontmatter.
+func parseFrontmatter(content []byte) (Frontmatter, []byte, bool) {
+	text := string(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return Frontmatter{}, content, false
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	fm := Frontmatter{}
+	lastKey := ""
+	var listItems []string
+
+	flushList := func() {
+		if lastKey != "" && len(listItems) > 0 {
+			fm[lastKey] = "[" + strings.Join(listItems, ", ") + "]"
+		}
+		listItems = nil
+	}
+
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		offset += len(line)
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		if trimmed == "---" || trimmed == "..." {
+			flushList()
+			return fm, []byte(text[offset:]), true
+		}
+
+		// Элемент блочного списка
+		if item := strings.TrimSpace(trimmed); strings.HasPrefix(item, "- ") && lastKey != "" {
+			listItems = append(listItems, unquote(strings.TrimSpace(item[2:])))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.HasPrefix(trimmed, " ") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		flushList()
+		lastKey = strings.TrimSpace(key)
+		fm[lastKey] = unquote(strings.TrimSpace(value))
+	}
+
+	// Незакрытый frontmatter считаем обычным содержимым
+	return Frontmatter{}, content, false
+}
+
+// Удаление кавычек вокруг значения
+func unquote(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// Значение поля как список (формат [a, b], блочный список или строка через запятую)
+func (fm Frontmatter) List(key string) []string {
+	value := strings.TrimSpace(fm[key])
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if value == "" {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Замена значения поля frontmatter документа; поле добавляется в конец frontmatter,
+// документ без frontmatter получает frontmatter из одного поля
+func setFrontmatterField(doc, key, value string) string {
+	field := key + ": " + value
+	if _, _, ok := parseFrontmatter([]byte(doc)); !ok {
+		return "---\n" + field + "\n---\n" + doc
+	}
+	bom := ""
+	if strings.HasPrefix(doc, "\xef\xbb\xbf") {
+		bom, doc = "\xef\xbb\xbf", doc[3:]
+	}
+	lines := strings.SplitAfter(doc, "\n")
+	newline := "\n"
+	if strings.HasSuffix(lines[0], "\r\n") {
+		newline = "\r\n"
+	}
+	out := []string{lines[0]}
+	replaced, inField := false, false
+	for i, line := range lines[1:] {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "---" || trimmed == "..." {
+			if !replaced {
+				out = append(out, field+newline)
+			}
+			out = append(out, lines[i+1:]...)
+			break
+		}
+		// Продолжение заменяемого поля (элементы блочного списка)
+		if inField && (strings.HasPrefix(trimmed, " ") || strings.HasPrefix(trimmed, "-")) {
+			continue
+		}
+		inField = false
+		if k, _, ok := strings.Cut(trimmed, ":"); ok && !strings.HasPrefix(trimmed, " ") && strings.TrimSpace(k) == key {
+			if !replaced {
+				out = append(out, field+newline)
+			}
+			replaced, inField = true, true
+			continue
+		}
+		out = append(out, line)
+	}
+	return bom + strings.Join(out, "")
+}
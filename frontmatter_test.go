@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestParseFrontmatter(t *testing.T) {
+	t.Run("СFrontmatter", func(t *testing.T) {
+		content := []byte("---\ntitle: \"Заметка\"\npriority: 5\ntags:\n  - go\n  - 'api'\n---\n# Текст\n")
+		fm, body, ok := parseFrontmatter(content)
+		if !ok {
+			t.Fatal("Ожидалось наличие frontmatter")
+		}
+		if fm["title"] != "Заметка" {
+			t.Errorf("Ожидалось title='Заметка', получено '%s'", fm["title"])
+		}
+		if fm["priority"] != "5" {
+			t.Errorf("Ожидалось priority='5', получено '%s'", fm["priority"])
+		}
+		tags := fm.List("tags")
+		if len(tags) != 2 || tags[0] != "go" || tags[1] != "api" {
+			t.Errorf("Некорректный список тегов: %v", tags)
+		}
+		if string(body) != "# Текст\n" {
+			t.Errorf("Некорректное тело документа: %q", body)
+		}
+	})
+
+	t.Run("ВстроенныйСписок", func(t *testing.T) {
+		fm, _, _ := parseFrontmatter([]byte("---\ntags: [a, b, c]\n---\n"))
+		if tags := fm.List("tags"); len(tags) != 3 {
+			t.Errorf("Ожидалось 3 тега, получено %v", tags)
+		}
+	})
+
+	t.Run("БезFrontmatter", func(t *testing.T) {
+		content := []byte("# Заголовок\n---\n")
+		fm, body, ok := parseFrontmatter(content)
+		if ok || len(fm) != 0 || string(body) != string(content) {
+			t.Error("Документ без frontmatter должен возвращаться без изменений")
+		}
+	})
+
+	t.Run("НезакрытыйFrontmatter", func(t *testing.T) {
+		content := []byte("---\ntitle: x\n")
+		if _, body, ok := parseFrontmatter(content); ok || string(body) != string(content) {
+			t.Error("Незакрытый frontmatter должен считаться обычным содержимым")
+		}
+	})
+}
+
+func TestSetFrontmatterField(t *testing.T) {
+	cases := []struct {
+		name, doc, want string
+	}{
+		{"Замена", "---\ntitle: Заметка\nstatus: ready\n---\n# Текст\n", "---\ntitle: Заметка\nstatus: enriched\n---\n# Текст\n"},
+		{"Добавление", "---\ntitle: Заметка\n---\n# Текст\n", "---\ntitle: Заметка\nstatus: enriched\n---\n# Текст\n"},
+		{"БлочныйСписок", "---\nstatus:\n  - a\n  - b\ntags: [x]\n---\n", "---\nstatus: enriched\ntags: [x]\n---\n"},
+		{"CRLF", "---\r\nstatus: draft\r\n---\r\nТекст", "---\r\nstatus: enriched\r\n---\r\nТекст"},
+		{"БезFrontmatter", "# Текст\n", "---\nstatus: enriched\n---\n# Текст\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := setFrontmatterField(c.doc, "status", "enriched")
+			if got != c.want {
+				t.Errorf("setFrontmatterField() = %q, ожидалось %q", got, c.want)
+			}
+			if fm, _, _ := parseFrontmatter([]byte(got)); fm["status"] != "enriched" {
+				t.Errorf("Поле не читается из результата: %v", fm)
+			}
+		})
+	}
+}
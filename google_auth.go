@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Область доступа токена для Vertex AI
+	googleCloudScope = "https://www.googleapis.com/auth/cloud-platform"
+	// Адрес выдачи токенов OAuth 2.0 Google по умолчанию
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	// Сервер метаданных Compute Engine, GKE и Cloud Run
+	googleMetadataHost = "metadata.google.internal"
+	// Токен обновляется заранее, чтобы не истечь во время запроса
+	googleTokenRefreshMargin = time.Minute
+)
+
+// Учетные данные Google из JSON файла: ключ сервисного аккаунта или
+// пользовательские учетные данные gcloud auth application-default login
+type googleCredentials struct {
+	Type string `json:"type"`
+	// service_account
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	// authorized_user
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// Токен доступа с моментом истечения
+type googleToken struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// Кэш токенов по источнику учетных данных: токен живет около часа и
+// используется всеми запросами запуска
+var googleTokens = struct {
+	mu     sync.Mutex
+	tokens map[string]googleToken
+}{tokens: map[string]googleToken{}}
+
+// Путь к файлу учетных данных по правилам Application Default Credentials:
+// credentials_file из конфигурации, GOOGLE_APPLICATION_CREDENTIALS, файл gcloud;
+// "" - файла нет, токен запрашивается у сервера метаданных
+func googleCredentialsPath(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if env := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); env != "" {
+		return env
+	}
+	var dir string
+	if runtime.GOOS == "windows" {
+		dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+	if dir == "" {
+		return ""
+	}
+	wellKnown := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(wellKnown); err == nil {
+		return wellKnown
+	}
+	return ""
+}
+
+// Загрузка учетных данных Google из JSON файла
+func loadGoogleCredentials(path string) (*googleCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf("не удалось прочитать учетные данные Google %s: %v", path, err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, errorf("некорректный файл учетных данных Google %s: %v", path, err)
+	}
+	switch creds.Type {
+	case "service_account":
+		if creds.ClientEmail == "" || creds.PrivateKey == "" {
+			return nil, errorf("в ключе сервисного аккаунта %s нет client_email или private_key", path)
+		}
+	case "authorized_user":
+		if creds.RefreshToken == "" {
+			return nil, errorf("в учетных данных %s нет refresh_token", path)
+		}
+	default:
+		return nil, errorf("неподдерживаемый тип учетных данных Google %q в %s", creds.Type, path)
+	}
+	return &creds, nil
+}
+
+// Токен доступа Google для запросов к Vertex AI (с кэшированием до истечения)
+func googleAccessToken(credentialsFile string) (string, error) {
+	path := googleCredentialsPath(credentialsFile)
+	key := path
+	if key == "" {
+		key = "metadata"
+	}
+
+	googleTokens.mu.Lock()
+	defer googleTokens.mu.Unlock()
+	if tok, ok := googleTokens.tokens[key]; ok && time.Until(tok.Expiry) > googleTokenRefreshMargin {
+		return tok.AccessToken, nil
+	}
+
+	var tok googleToken
+	var err error
+	if path == "" {
+		tok, err = metadataToken()
+	} else {
+		var creds *googleCredentials
+		if creds, err = loadGoogleCredentials(path); err == nil {
+			if creds.Type == "service_account" {
+				tok, err = serviceAccountToken(creds)
+			} else {
+				tok, err = refreshUserToken(creds)
+			}
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	googleTokens.tokens[key] = tok
+	logf("Получен токен доступа Google, действует до %s", tok.Expiry.Format(time.RFC3339))
+	return tok.AccessToken, nil
+}
+
+// Проект Google Cloud из учетных данных ("" - не указан)
+func googleCredentialsProject(credentialsFile string) string {
+	path := googleCredentialsPath(credentialsFile)
+	if path == "" {
+		return ""
+	}
+	creds, err := loadGoogleCredentials(path)
+	if err != nil {
+		return ""
+	}
+	if creds.ProjectID != "" {
+		return creds.ProjectID
+	}
+	return creds.QuotaProjectID
+}
+
+// Токен сервисного аккаунта: JWT, подписанный ключом аккаунта (RS256), обменивается
+// на токен доступа (RFC 7523)
+func serviceAccountToken(creds *googleCredentials) (googleToken, error) {
+	tokenURL := creds.TokenURI
+	if tokenURL == "" {
+		tokenURL = googleTokenURL
+	}
+	assertion, err := signServiceAccountJWT(creds, tokenURL, time.Now())
+	if err != nil {
+		return googleToken{}, err
+	}
+	return requestGoogleToken(tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// Подписанный JWT сервисного аккаунта для обмена на токен доступа
+func signServiceAccountJWT(creds *googleCredentials, audience string, now time.Time) (string, error) {
+	key, err := parseGooglePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if creds.PrivateKeyID != "" {
+		header["kid"] = creds.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": googleCloudScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errorf("ошибка подписи JWT сервисного аккаунта: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Закрытый ключ RSA сервисного аккаунта в формате PEM (PKCS #8 или PKCS #1)
+func parseGooglePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errorf("некорректный private_key сервисного аккаунта: ожидался PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errorf("некорректный private_key сервисного аккаунта: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errorf("некорректный private_key сервисного аккаунта: ожидался ключ RSA")
+	}
+	return key, nil
+}
+
+// Токен по пользовательским учетным данным gcloud (refresh_token)
+func refreshUserToken(creds *googleCredentials) (googleToken, error) {
+	return requestGoogleToken(googleTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {creds.ClientID},
+		"client_secret": {creds.ClientSecret},
+		"refresh_token": {creds.RefreshToken},
+	})
+}
+
+// Токен сервисного аккаунта среды выполнения от сервера метаданных
+func metadataToken() (googleToken, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = googleMetadataHost
+	}
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return googleToken{}, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return googleToken{}, errorf("учетные данные Google не найдены: задайте credentials_file или GOOGLE_APPLICATION_CREDENTIALS (сервер метаданных недоступен: %v)", err)
+	}
+	defer resp.Body.Close()
+	return decodeGoogleToken(resp)
+}
+
+// Запрос токена у сервера OAuth 2.0
+func requestGoogleToken(tokenURL string, form url.Values) (googleToken, error) {
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return googleToken{}, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setUserAgent(req)
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return googleToken{}, errorf("ошибка при получении токена Google: %v", err)
+	}
+	defer resp.Body.Close()
+	return decodeGoogleToken(resp)
+}
+
+// Разбор ответа с токеном доступа
+func decodeGoogleToken(resp *http.Response) (googleToken, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return googleToken{}, errorf("ошибка при получении токена Google: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return googleToken{}, errorf("получение токена Google вернуло статус %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil || data.AccessToken == "" {
+		return googleToken{}, errorf("некорректный ответ с токеном Google: %s", strings.TrimSpace(string(body)))
+	}
+	return googleToken{AccessToken: data.AccessToken, Expiry: time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)}, nil
+}
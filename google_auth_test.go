@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Тестовый ключ сервисного аккаунта с адресом выдачи токенов tokenURL
+func writeServiceAccountKey(t *testing.T, key *rsa.PrivateKey, tokenURL string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать ключ: %v", err)
+	}
+	creds := map[string]string{
+		"type":           "service_account",
+		"project_id":     "rich-test",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "rich@rich-test.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	}
+	data, err := json.Marshal(creds)
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать учетные данные: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Не удалось записать учетные данные: %v", err)
+	}
+	return path
+}
+
+// Обработчик выдачи токенов: проверяет подпись и утверждения JWT сервисного аккаунта
+func tokenHandler(t *testing.T, key *rsa.PrivateKey, calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "bad assertion", http.StatusBadRequest)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Errorf("Некорректные утверждения JWT: %v", err)
+		}
+		if claims["iss"] != "rich@rich-test.iam.gserviceaccount.com" || claims["scope"] != googleCloudScope || !strings.HasSuffix(claims["aud"].(string), "/token") {
+			t.Errorf("Неожиданные утверждения JWT: %v", claims)
+		}
+		_, _ = w.Write([]byte(`{"access_token": "ya29.test", "expires_in": 3600, "token_type": "Bearer"}`))
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+	var calls atomic.Int32
+	server := httptest.NewServer(tokenHandler(t, key, &calls))
+	defer server.Close()
+	path := writeServiceAccountKey(t, key, server.URL+"/token")
+
+	for i := 0; i < 2; i++ {
+		token, err := googleAccessToken(path)
+		if err != nil {
+			t.Fatalf("googleAccessToken() вернул ошибку: %v", err)
+		}
+		if token != "ya29.test" {
+			t.Errorf("Неожиданный токен: %q", token)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Токен должен кэшироваться до истечения, запросов: %d", calls.Load())
+	}
+	if project := googleCredentialsProject(path); project != "rich-test" {
+		t.Errorf("Проект из учетных данных: %q", project)
+	}
+}
+
+func TestGoogleCredentialsErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"type": "external_account"}`), 0600); err != nil {
+		t.Fatalf("Не удалось записать файл: %v", err)
+	}
+	if _, err := googleAccessToken(bad); err == nil || !strings.Contains(err.Error(), "external_account") {
+		t.Errorf("Ожидалась ошибка неподдерживаемого типа, получено: %v", err)
+	}
+
+	// Без файла учетных данных токен запрашивается у сервера метаданных
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token": "ya29.metadata", "expires_in": 3599}`))
+	}))
+	defer server.Close()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	if token, err := googleAccessToken(""); err != nil || token != "ya29.metadata" {
+		t.Errorf("Токен сервера метаданных: %q, %v", token, err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Действия при срабатывании проверки результата
+const (
+	// Результат не записывается, файл получает статус failed
+	GuardFail = "fail"
+	// Результат записывается, в журнал выводится предупреждение
+	GuardWarn = "warn"
+)
+
+// Проверка результата обогащения против оригинала: почти неизмененный ответ
+// (модель ничего не сделала), ответ вне допустимых границ длины или потерянные
+// блоки кода
+type outputGuard struct {
+	// Порог сходства с оригиналом от 0 до 1 (0 - проверка выключена)
+	MaxSimilarity float64
+	// Допустимое отношение числа слов результата к оригиналу (0 - без ограничения)
+	MinRatio float64
+	MaxRatio float64
+	// Блоки кода оригинала должны сохраниться в результате без изменений
+	KeepCodeBlocks bool
+	// Повторный запрос с описанием ошибки перед отклонением результата
+	Repair bool
+	Action string
+}
+
+// Отметка результата, не прошедшего проверку при action = warn (поле rich_review)
+const ReviewGuard = "guard"
+
+// Нарушение проверки результата: причина для журнала и отчета и указание модели
+// для повторного запроса
+type guardIssue struct {
+	Reason     string
+	Correction string
+}
+
+// Проверка действия при срабатывании
+func validateGuardAction(action string) error {
+	if action != GuardFail && action != GuardWarn {
+		return errorf("некорректное значение action %q в секции [GUARD]: ожидалось %s или %s", action, GuardFail, GuardWarn)
+	}
+	return nil
+}
+
+// Причина отклонения результата или "" если результат допустим
+func (g outputGuard) Check(original, enriched string, metrics *qualityMetrics) string {
+	if issue, ok := g.inspect(original, enriched, metrics); ok {
+		return issue.Reason
+	}
+	return ""
+}
+
+// Первое нарушение проверки результата
+func (g outputGuard) inspect(original, enriched string, metrics *qualityMetrics) (guardIssue, bool) {
+	if g.MaxSimilarity > 0 {
+		if sim := textSimilarity(original, enriched); sim >= g.MaxSimilarity {
+			return guardIssue{
+				Reason:     trf("результат почти совпадает с оригиналом (сходство %.2f)", sim),
+				Correction: "Your previous answer was almost identical to the original note. Actually enrich the note: clarify, structure and expand it while keeping its facts.",
+			}, true
+		}
+	}
+	if g.KeepCodeBlocks {
+		if missing := missingCodeBlocks(original, enriched); missing > 0 {
+			return guardIssue{
+				Reason:     trf("в результате потеряны или изменены блоки кода оригинала: %d", missing),
+				Correction: fmt.Sprintf("Your previous answer removed or changed %d code block(s) of the original. Keep all code blocks verbatim.", missing),
+			}, true
+		}
+	}
+	if metrics == nil || metrics.Original.Words == 0 {
+		return guardIssue{}, false
+	}
+	ratio := float64(metrics.Enriched.Words) / float64(metrics.Original.Words)
+	if g.MinRatio > 0 && ratio < g.MinRatio {
+		return guardIssue{
+			Reason: trf("результат слишком короткий: %d слов против %d в оригинале", metrics.Enriched.Words, metrics.Original.Words),
+			Correction: fmt.Sprintf("Your previous answer had %d words while the original has %d. Do not shorten or summarize the note: keep all of its content, at least %d words.",
+				metrics.Enriched.Words, metrics.Original.Words, int(math.Ceil(g.MinRatio*float64(metrics.Original.Words)))),
+		}, true
+	}
+	if g.MaxRatio > 0 && ratio > g.MaxRatio {
+		return guardIssue{
+			Reason: trf("результат слишком длинный: %d слов против %d в оригинале", metrics.Enriched.Words, metrics.Original.Words),
+			Correction: fmt.Sprintf("Your previous answer had %d words while the original has %d. Stay closer to the original: at most %d words.",
+				metrics.Enriched.Words, metrics.Original.Words, int(g.MaxRatio*float64(metrics.Original.Words))),
+		}, true
+	}
+	return guardIssue{}, false
+}
+
+// Промпт повторного запроса: исходный промпт и указание, что исправить
+func repairPrompt(prompt string, issue guardIssue) string {
+	return strings.TrimRight(prompt, "\n") + "\n\n" + issue.Correction
+}
+
+// Содержимое блоков кода (```) документа
+func codeBlocks(text string) []string {
+	var blocks []string
+	var current []string
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = nil
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			current = append(current, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return blocks
+}
+
+// Число блоков кода оригинала, которых нет в результате в неизменном виде
+func missingCodeBlocks(original, enriched string) int {
+	kept := make(map[string]int)
+	for _, b := range codeBlocks(enriched) {
+		kept[b]++
+	}
+	missing := 0
+	for _, b := range codeBlocks(original) {
+		if kept[b] > 0 {
+			kept[b]--
+		} else {
+			missing++
+		}
+	}
+	return missing
+}
+
+// Сходство текстов по парам соседних слов (коэффициент Дайса, 0..1); регистр,
+// пунктуация и разметка не учитываются
+func textSimilarity(a, b string) float64 {
+	ba, bb := wordBigrams(a), wordBigrams(b)
+	total := 0
+	for _, n := range ba {
+		total += n
+	}
+	for _, n := range bb {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	common := 0
+	for k, n := range ba {
+		common += min(n, bb[k])
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// Пары соседних слов текста с количеством повторов
+func wordBigrams(text string) map[string]int {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	bigrams := make(map[string]int)
+	if len(words) == 1 {
+		bigrams[words[0]]++
+	}
+	for i := 1; i < len(words); i++ {
+		bigrams[words[i-1]+" "+words[i]]++
+	}
+	return bigrams
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextSimilarity(t *testing.T) {
+	original := "# Заметка\n\nВстреча с командой в пятницу, обсудить план релиза."
+	if sim := textSimilarity(original, "# заметка\nВстреча с командой в пятницу — обсудить план релиза!"); sim < 0.99 {
+		t.Errorf("тексты, отличающиеся разметкой и регистром, должны совпадать: %.2f", sim)
+	}
+	enriched := original + "\n\n## План\n\n1. Проверить тесты.\n2. Обновить документацию и журнал изменений.\n3. Выпустить версию."
+	if sim := textSimilarity(original, enriched); sim > 0.8 {
+		t.Errorf("обогащенный текст не должен считаться неизмененным: %.2f", sim)
+	}
+}
+
+func TestOutputGuardCheck(t *testing.T) {
+	original := "Встреча с командой в пятницу, обсудить план релиза и сроки."
+	guard := outputGuard{MaxSimilarity: 0.95, MinRatio: 0.5, MaxRatio: 3}
+	check := func(enriched string) string {
+		return guard.Check(original, enriched, compareMetrics(original, enriched, "ru"))
+	}
+	if reason := check(original + "\n"); reason == "" {
+		t.Error("неизмененный результат должен отклоняться")
+	}
+	if reason := check("Встреча."); reason == "" {
+		t.Error("слишком короткий результат должен отклоняться")
+	}
+	if reason := check(strings.Repeat("Длинное пояснение без конца. ", 20)); reason == "" {
+		t.Error("слишком длинный результат должен отклоняться")
+	}
+	if reason := check(original + " Подготовить презентацию и отчет о тестировании."); reason != "" {
+		t.Errorf("допустимый результат отклонен: %s", reason)
+	}
+	if reason := (outputGuard{}).Check(original, original, nil); reason != "" {
+		t.Errorf("без порогов проверка не выполняется: %s", reason)
+	}
+}
+
+func TestProcessFileRejectsUnchangedOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "note.md")
+	outputPath := filepath.Join(tmpDir, "out", "note.md")
+	original := "# Заметка\n\nВстреча с командой в пятницу, обсудить план релиза."
+	if err := os.WriteFile(inputPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": original}}},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens: 100, Guard: outputGuard{MaxSimilarity: 0.98, Action: GuardFail}}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err == nil || result.Status != StatusFailed {
+		t.Fatalf("ожидалась ошибка проверки результата, получено %v (%s)", err, result.Status)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("отклоненный результат не должен записываться")
+	}
+
+	// В режиме warn результат записывается
+	config.Guard.Action = GuardWarn
+	if _, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000))); err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		t.Errorf("в режиме warn результат должен записываться: %v", err)
+	}
+}
+
+func TestMissingCodeBlocks(t *testing.T) {
+	original := "# Заметка\n\n```go\nfmt.Println(1)\n```\n\nТекст\n\n```\nmake\n```\n"
+	if n := missingCodeBlocks(original, "# Обогащено\n\n```go\nfmt.Println(1)\n```\n\nПояснение\n\n```sh\nmake\n```"); n != 0 {
+		t.Errorf("Блоки кода сохранены, получено потерянных: %d", n)
+	}
+	if n := missingCodeBlocks(original, "# Обогащено\n\n```go\nfmt.Println(2)\n```"); n != 2 {
+		t.Errorf("Ожидалось 2 потерянных блока, получено %d", n)
+	}
+	guard := outputGuard{KeepCodeBlocks: true}
+	issue, rejected := guard.inspect(original, "# Обогащено", nil)
+	if !rejected || !strings.Contains(issue.Correction, "2 code block") {
+		t.Errorf("Ожидалось указание сохранить 2 блока кода: %+v", issue)
+	}
+}
+
+// Отклоненный проверкой ответ исправляется одним повторным запросом с описанием ошибки
+func TestProcessFileRepairsRejectedOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "note.md")
+	outputPath := filepath.Join(tmpDir, "out", "note.md")
+	original := "# Заметка\n\nВстреча с командой в пятницу, обсудить план релиза.\n\n```sh\nmake release\n```"
+	if err := os.WriteFile(inputPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var requests []string
+	fixed := "# Заметка\n\nВстреча с командой в пятницу: обсуждаем план релиза, сроки и ответственных.\n\n```sh\nmake release\n```"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		prompt := ""
+		for _, m := range body.Messages {
+			prompt += m.Content
+		}
+		requests = append(requests, prompt)
+		reply := "# Заметка\n\nВстреча с командой в пятницу: обсуждаем план релиза, сроки и ответственных."
+		if strings.Contains(prompt, "Keep all code blocks verbatim") {
+			reply = fixed
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens: 100, Guard: outputGuard{KeepCodeBlocks: true, Repair: true, Action: GuardFail}}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	if len(requests) != 2 || !strings.Contains(requests[1], "removed or changed 1 code block") {
+		t.Fatalf("Ожидался повторный запрос с описанием ошибки, запросы: %q", requests)
+	}
+	if result.Repair == "" {
+		t.Error("Причина повторного запроса должна попадать в результат")
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil || !strings.HasPrefix(string(data), fixed) {
+		t.Errorf("Ожидался исправленный результат, получено %q (%v)", data, err)
+	}
+
+	// Ответ снова не прошел проверку: при action = warn результат записывается с отметкой
+	// для проверки, повторный запрос выполняется только один раз
+	requests = nil
+	fixed = "# Заметка\n\nБез кода"
+	config.Guard.Action = GuardWarn
+	if _, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000))); err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Ожидалось 2 запроса, получено %d", len(requests))
+	}
+	if data, _ := os.ReadFile(outputPath); !strings.Contains(string(data), ReviewKey+": "+ReviewGuard) {
+		t.Errorf("Результат должен быть отмечен для проверки:\n%s", data)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Расширение сжатых заметок markdown
+const gzipMarkdownExt = ".md.gz"
+
+// Максимальный размер распакованной заметки: защита от сжатых файлов, которые
+// распаковываются в гигабайты
+const maxGunzipSize = 256 << 20
+
+// Сжатые заметки markdown из секции [GZIP]
+type GzipConfig struct {
+	Enabled bool
+	// Результат сжатой заметки сохраняется сжатым (.md.gz); false - в markdown (.md)
+	CompressOutput bool
+}
+
+// Чтение секции [GZIP]
+func loadGzipConfig(section *ini.Section) GzipConfig {
+	return GzipConfig{
+		Enabled:        section.Key("enabled").MustBool(false),
+		CompressOutput: section.Key("compress_output").MustBool(false),
+	}
+}
+
+// Сжатая заметка markdown (по расширению .md.gz)
+func isGzipMarkdownPath(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), gzipMarkdownExt)
+}
+
+// Путь результата сжатой заметки: без compress_output - в markdown рядом
+// (notes/2019.md.gz -> notes/2019.md)
+func (g GzipConfig) outputPath(outputPath string) string {
+	if g.CompressOutput {
+		return outputPath
+	}
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+}
+
+// Расширение результата для шаблонов имен: у сжатой заметки - .md.gz целиком
+func outputExt(outputPath string) string {
+	if isGzipMarkdownPath(outputPath) {
+		return gzipMarkdownExt
+	}
+	return filepath.Ext(outputPath)
+}
+
+// Распаковка содержимого сжатой заметки
+func gunzipBytes(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, maxGunzipSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxGunzipSize {
+		return nil, errorf("распакованный файл больше %d байт", maxGunzipSize)
+	}
+	return out, nil
+}
+
+// Сжатие результата. Заголовок gzip не содержит имени и времени, поэтому один и тот
+// же результат дает одинаковые байты (хэш результата, контрольные суммы)
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Чтение заметки markdown; сжатая заметка (.md.gz) распаковывается
+func readMarkdownFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isGzipMarkdownPath(path) {
+		return data, err
+	}
+	return gunzipBytes(data)
+}
+
+// Результат по пути входного файла в выходной директории: результат сжатой заметки
+// без compress_output хранится в markdown
+func resultPathFor(config *Config, outputPath string) string {
+	if config.Gzip.Enabled && isGzipMarkdownPath(outputPath) {
+		return config.Gzip.outputPath(outputPath)
+	}
+	return outputPath
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func gzipTestData(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = "note.md"
+	if _, err := zw.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzipPaths(t *testing.T) {
+	if !isGzipMarkdownPath("notes/2019.MD.gz") || isGzipMarkdownPath("backup.tar.gz") || isGzipMarkdownPath("note.md") {
+		t.Error("Неожиданное определение сжатых заметок")
+	}
+	if got := (GzipConfig{}).outputPath("out/2019.md.gz"); got != "out/2019.md" {
+		t.Errorf("outputPath() = %q", got)
+	}
+	if got := (GzipConfig{CompressOutput: true}).outputPath("out/2019.md.gz"); got != "out/2019.md.gz" {
+		t.Errorf("outputPath() с compress_output = %q", got)
+	}
+	if got := outputExt("out/2019.md.gz"); got != ".md.gz" {
+		t.Errorf("outputExt() = %q", got)
+	}
+	packed, err := gzipBytes([]byte("# Заметка"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := gzipBytes([]byte("# Заметка"))
+	if !bytes.Equal(packed, again) {
+		t.Error("Сжатие одного содержимого должно давать одинаковые байты")
+	}
+	if text, err := gunzipBytes(packed); err != nil || string(text) != "# Заметка" {
+		t.Errorf("gunzipBytes() = %q, %v", text, err)
+	}
+	if _, err := gunzipBytes([]byte("# не сжато")); err == nil {
+		t.Error("Ожидалась ошибка для несжатых данных")
+	}
+}
+
+// Сжатые заметки распаковываются при чтении; результат сохраняется в markdown
+// или сжатым по compress_output
+func TestGzipInputs(t *testing.T) {
+	var calls atomic.Int32
+	var lastBody atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody.Store(string(body))
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	for _, compress := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		inputDir := filepath.Join(tmpDir, "input")
+		outputDir := filepath.Join(tmpDir, "output")
+		if err := os.MkdirAll(filepath.Join(inputDir, "archive"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(inputDir, "archive", "2019.md.gz"), gzipTestData(t, "# Архив 2019\n\nСтарая заметка из выгрузки"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		configPath := filepath.Join(tmpDir, "rich.cfg")
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+			"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+			"[GZIP]\nenabled = true\n"
+		if compress {
+			cfg += "compress_output = true\n"
+		}
+		cfg += "[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		before := calls.Load()
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+		if calls.Load() != before+1 {
+			t.Fatalf("Ожидался один запрос к API, получено %d", calls.Load()-before)
+		}
+		if body, _ := lastBody.Load().(string); !strings.Contains(body, "Старая заметка из выгрузки") {
+			t.Errorf("В модель должен отправляться распакованный текст: %s", body)
+		}
+
+		outputPath := filepath.Join(outputDir, "archive", "2019.md")
+		if compress {
+			outputPath += ".gz"
+		}
+		data, err := readMarkdownFile(outputPath)
+		if err != nil {
+			t.Fatalf("Нет результата %s: %v", outputPath, err)
+		}
+		if !strings.HasPrefix(string(data), "# Обогащено") || !strings.Contains(string(data), "Старая заметка из выгрузки") {
+			t.Errorf("Неожиданный результат:\n%s", data)
+		}
+		if !compress {
+			if _, err := os.Stat(outputPath + ".gz"); err == nil {
+				t.Error("Без compress_output сжатый результат не должен создаваться")
+			}
+		}
+		reloaded, _ := loadConfig(configPath)
+		if strings.Join(reloaded.ExcludedFiles, ",") != "archive/2019.md.gz" {
+			t.Errorf("Неожиданный список исключений: %v", reloaded.ExcludedFiles)
+		}
+	}
+
+	// Без [GZIP] enabled сжатые файлы не обрабатываются
+	config := &Config{}
+	if config.isInputFile("2019.md.gz") {
+		t.Error("Сжатые заметки без [GZIP] enabled не должны обрабатываться")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Состояние процесса для проверок оркестратора (Docker, Kubernetes)
+type healthState struct {
+	mu     sync.Mutex
+	ready  bool
+	status string
+	// Счетчики обработки для /stats (nil - /stats недоступен)
+	stats func() interface{}
+}
+
+// Обновление состояния по уведомлению в формате sd_notify (READY=1, RELOADING=1,
+// STOPPING=1, STATUS=...)
+func (h *healthState) Notify(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if status, ok := strings.CutPrefix(state, "STATUS="); ok {
+		h.status = status
+		return
+	}
+	switch state {
+	case "READY=1":
+		h.ready = true
+	case "RELOADING=1", "STOPPING=1":
+		h.ready = false
+	}
+}
+
+// HTTP обработчик проверок: /healthz - процесс работает, /readyz - конфигурация
+// загружена и обработка не останавливается, /stats - счетчики обработки в JSON
+// (в режиме наблюдения)
+func (h *healthState) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		ready, status := h.ready, h.status
+		h.mu.Unlock()
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		fmt.Fprintln(w, "ready")
+		if status != "" {
+			fmt.Fprintln(w, status)
+		}
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		stats := h.stats
+		h.mu.Unlock()
+		if stats == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(stats())
+	})
+	return mux
+}
+
+// Подключение счетчиков обработки к /stats
+func (h *healthState) SetStats(stats func() interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats = stats
+}
+
+// Запуск HTTP сервера проверок состояния; возвращает функцию остановки
+func startHealthServer(addr string, h *healthState) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errorf("не удалось открыть адрес проверки состояния %s: %v", addr, err)
+	}
+	server := &http.Server{Handler: h.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logErrorf("Ошибка сервера проверки состояния: %v", err)
+		}
+	}()
+	logf("Проверки состояния доступны на %s (/healthz, /readyz)", ln.Addr())
+	return func() { server.Close() }, nil
+}
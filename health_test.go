@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	h := &healthState{}
+	server := httptest.NewServer(h.Handler())
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Ошибка запроса %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz вернул %d", code)
+	}
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz до загрузки конфигурации вернул %d, ожидалось 503", code)
+	}
+
+	h.Notify("READY=1")
+	h.Notify("STATUS=Следующий запуск в 12:00:00")
+	code, body := get("/readyz")
+	if code != http.StatusOK || !strings.Contains(body, "12:00:00") {
+		t.Errorf("/readyz после READY=1 вернул %d: %q", code, body)
+	}
+
+	h.Notify("STOPPING=1")
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz при остановке вернул %d, ожидалось 503", code)
+	}
+}
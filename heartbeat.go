@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Интервал сообщений о долгом ожидании ответа API по умолчанию
+const defaultHeartbeat = 15 * time.Second
+
+// Сообщения о долгом ожидании ответа: пока запрос к API не завершен, каждые
+// config.Heartbeat в консоль и журнал выводится, сколько времени ждет ответа файл,
+// а в поток состояния - событие file_waiting. Долгая генерация иначе выглядит
+// как зависание. Возвращаемая функция останавливает сообщения
+func startHeartbeat(config *Config, sent time.Time) (stop func()) {
+	if config.Heartbeat <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(config.Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				elapsed := now.Sub(sent).Round(time.Second)
+				if config.CurrentFile != "" {
+					infof("Ожидание ответа API для %s: %s", config.CurrentFile, elapsed)
+				} else {
+					infof("Ожидание ответа API: %s", elapsed)
+				}
+				statusEvents.Emit(statusEvent{Event: EventFileWaiting, Path: config.CurrentFile, DurationMS: elapsed.Milliseconds()})
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(120 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	var consoleBuf bytes.Buffer
+	oldConsole := console
+	console = newConsole(&consoleBuf, false)
+	defer func() { console = oldConsole }()
+
+	config := &Config{ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Heartbeat: 30 * time.Millisecond, CurrentFile: "notes/long.md"}
+	if _, _, err := requestModel(config, "note", nil, NewRateLimiter(0)); err != nil {
+		t.Fatalf("requestModel() вернул ошибку: %v", err)
+	}
+	// Сообщения прекращаются после ответа
+	time.Sleep(60 * time.Millisecond)
+	lines := strings.Count(consoleBuf.String(), "Ожидание ответа API для notes/long.md")
+	if lines < 2 || lines > 4 {
+		t.Errorf("сообщений об ожидании: %d\n%s", lines, consoleBuf.String())
+	}
+
+	consoleBuf.Reset()
+	config.Heartbeat = 0
+	if _, _, err := requestModel(config, "note", nil, NewRateLimiter(0)); err != nil {
+		t.Fatal(err)
+	}
+	if consoleBuf.Len() != 0 {
+		t.Errorf("с heartbeat = 0 выводятся сообщения:\n%s", consoleBuf.String())
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Язык сообщений по умолчанию: строки в коде написаны на русском
+const defaultUILanguage = "ru"
+
+// Каталоги переводов сообщений: ключ - исходная строка (формат) на русском
+var messageCatalogs = map[string]map[string]string{
+	"en": messagesEN,
+}
+
+// Текущий язык журнала, ошибок и вывода подкоманд
+var uiLanguage = defaultUILanguage
+
+// Поддерживаемые языки сообщений
+func supportedUILanguages() []string {
+	langs := []string{defaultUILanguage}
+	for lang := range messageCatalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Выбор языка сообщений: "ru", "en" или "auto" (по переменным окружения)
+func setUILanguage(lang string) error {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" || lang == "auto" {
+		lang = localeLanguage()
+	}
+	if _, ok := messageCatalogs[lang]; !ok && lang != defaultUILanguage {
+		return errorf("неподдерживаемый язык сообщений: %s (доступны: %s)", lang, strings.Join(supportedUILanguages(), ", "))
+	}
+	uiLanguage = lang
+	return nil
+}
+
+// Язык сообщений по окружению: RICH_LANG, затем LC_ALL, LC_MESSAGES и LANG
+// (en_US.UTF-8 -> en); неизвестные и неподдерживаемые языки - язык по умолчанию
+func localeLanguage() string {
+	if lang := strings.ToLower(strings.TrimSpace(os.Getenv("RICH_LANG"))); lang != "" {
+		return lang
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if value == "C" || value == "POSIX" {
+			return defaultUILanguage
+		}
+		lang := strings.ToLower(value)
+		if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+			lang = lang[:i]
+		}
+		if _, ok := messageCatalogs[lang]; ok {
+			return lang
+		}
+		return defaultUILanguage
+	}
+	return defaultUILanguage
+}
+
+// Перевод сообщения на текущий язык; без перевода возвращается исходная строка
+func tr(msg string) string {
+	if catalog, ok := messageCatalogs[uiLanguage]; ok {
+		if translated, ok := catalog[msg]; ok {
+			return translated
+		}
+	}
+	return msg
+}
+
+// Форматирование переведенного сообщения
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// Ошибка с переведенным сообщением (%w поддерживается)
+func errorf(format string, args ...any) error {
+	return fmt.Errorf(tr(format), args...)
+}
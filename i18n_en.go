@@ -0,0 +1,1396 @@
+package main
+
+// Перевод сообщений на английский
+var messagesEN = map[string]string{
+	// Обработка директории и файлов
+	"Запуск с конфигурацией из: %s":                                 "Starting with configuration from: %s",
+	"Обработка завершена":                                           "Processing finished",
+	"Обработка %s":                                                  "Processing %s",
+	"Начат запуск %s":                                               "Run %s started",
+	"Запуск %s завершен (rich status --run %s, rich undo --run %s)": "Run %s finished (rich status --run %s, rich undo --run %s)",
+	"Обработано файлов: %d":                                         "Files processed: %d",
+	"Пропущено файлов: %d":                                          "Files skipped: %d",
+	"Затраты за запуск: $%.4f":                                      "Run cost: $%.4f",
+	"Остановка обработки: %s":                                       "Stopping processing: %s",
+	"Ошибка при обработке %s: %v":                                   "Error processing %s: %v",
+	"Сохранено обогащенное содержимое в %s":                         "Enriched content saved to %s",
+	"Маршрут для %s: %s":                                            "Route for %s: %s",
+	"Язык документа %s: %s":                                         "Document language of %s: %s",
+	"Пропуск %s: %v":                                                "Skipping %s: %v",
+	"Пропуск директории %s: найден пустой %s":                       "Skipping directory %s: empty %s found",
+	"Пропуск исключенного файла: %s":                                "Skipping excluded file: %s",
+	"Пропуск исключенной директории: %s":                            "Skipping excluded directory: %s",
+	"Пропуск файла %s (skipped: too small): %s":                     "Skipping file %s (skipped: too small): %s",
+	"Пропуск файла %s: содержимое не изменилось":                    "Skipping file %s: content unchanged",
+	"Файл изменился после обогащения: %s":                           "File changed since enrichment: %s",
+	"Обогащение разделов %s: %d":                                    "Enriching sections of %s: %d",
+	"Документ %s не помещается в контекст модели, обогащение по частям: %d":             "Document %s does not fit the model context, enriching in parts: %d",
+	"Изменения в %s затрагивают большую часть документа, выполняется полное обогащение": "Changes in %s affect most of the document, enriching it in full",
+	"Инкрементальное обогащение: изменено разделов %d, удалено %d":                      "Incremental enrichment: %d sections changed, %d removed",
+	"Индекс контекста построен: %d фрагментов из %s":                                    "Context index built: %d chunks from %s",
+	"Пакетная обработка отключена: несовместима с context_dir и linked_docs":            "Batching disabled: incompatible with context_dir and linked_docs",
+	"Пакетный запрос: %d файлов":                                                        "Batch request: %d files",
+	"Получен ответ API: статус %d, размер %d байт":                                      "API response received: status %d, size %d bytes",
+	"Получен потоковый ответ API: %d символов":                                          "Streamed API response received: %d characters",
+	"Получен сигнал %v":      "Received signal %v",
+	"Обработка возобновлена": "Processing resumed",
+	"Обработка приостановлена: новые файлы не будут отправляться до возобновления": "Processing paused: no new files will be sent until resumed",
+	"Отчет о запуске сохранен в %s":                                                      "Run report saved to %s",
+	"Найдено битых ссылок в обогащенных документах: %d":                                  "Broken links found in enriched documents: %d",
+	"Изменение метрик в среднем на файл: слова %+.1f, заголовки %+.1f, читаемость %+.1f": "Average metric change per file: words %+.1f, headings %+.1f, readability %+.1f",
+	"Сравнение: %s, промпт %s, температура %g":                                           "Sweep: %s, prompt %s, temperature %g",
+
+	// Предупреждения
+	"Предупреждение: %v":                             "Warning: %v",
+	"Предупреждение: битая ссылка в %s: %s (%s, %s)": "Warning: broken link in %s: %s (%s, %s)",
+	"Предупреждение: в ответе пакетного запроса нет результата для %s, файл будет обработан отдельно":                              "Warning: batch response has no result for %s, the file will be processed separately",
+	"Предупреждение: в ответе пакетного запроса отказ для %s, файл будет обработан отдельно":                                       "Warning: batch response contains a refusal for %s, the file will be processed separately",
+	"Предупреждение: файл %s отмечен политикой содержимого: %s":                                                                    "Warning: file %s was flagged by the content policy: %s",
+	"Предупреждение: не удалось проиндексировать входную директорию для вики-ссылок: %v":                                           "Warning: failed to index the input directory for wiki links: %v",
+	"Предупреждение: результат обогащения %s вызывает сомнения: %s":                                                                "Warning: the enrichment result of %s is questionable: %s",
+	"Предупреждение: результат обогащения %s отклонен: %s":                                                                         "Warning: the enrichment result of %s was rejected: %s",
+	"Предупреждение: модель отказалась выполнить запрос, повтор с уточненным промптом":                                             "Warning: the model refused the request, retrying with a clarified prompt",
+	"Предупреждение: задан --max-usd, но цены input_price/output_price не указаны в секции [MODEL]":                                "Warning: --max-usd is set, but input_price/output_price are not specified in the [MODEL] section",
+	"Предупреждение: модель %s отсутствует в таблице, для max_tokens = auto используется %d (задайте context_window и max_output)": "Warning: model %s is not in the table, max_tokens = auto uses %d (set context_window and max_output)",
+	"Предупреждение: не удалось добавить файл в список исключений: %v":                                                             "Warning: failed to add the file to the exclusion list: %v",
+	"Предупреждение: API %s не поддерживает диалог из нескольких сообщений, примеры [EXAMPLE.*] не отправляются":                   "Warning: the %s API does not support multi-message conversations, [EXAMPLE.*] examples are not sent",
+	"Предупреждение: не удалось прочитать %s: %v":                                                                                  "Warning: failed to read %s: %v",
+	"Предупреждение: неизвестный режим %q в %s, промпт будет дополнен":                                                             "Warning: unknown mode %q in %s, the prompt will be extended",
+	"Предупреждение: неизвестна дата выпуска модели %s (%s), файл пропущен":                                                        "Warning: release date of model %s is unknown (%s), file skipped",
+	"Предупреждение: ошибка пакетного запроса, файлы будут обработаны по отдельности: %v":                                          "Warning: batch request failed, files will be processed separately: %v",
+	"Предупреждение: ошибка при инкрементальном обогащении %s: %v":                                                                 "Warning: incremental enrichment of %s failed: %v",
+	"Предупреждение: ошибка при обогащении раздела %d файла %s: %v":                                                                "Warning: failed to enrich section %d of %s: %v",
+	"Предупреждение: ошибка при обогащении содержимого %s: %v":                                                                     "Warning: failed to enrich the content of %s: %v",
+	"Предупреждение: ошибка при обогащении части %d файла %s: %v":                                                                  "Warning: failed to enrich part %d of %s: %v",
+	"из оригинала":      "from the original",
+	"добавлена моделью": "added by the model",
+
+	// Ошибки запуска программы
+	"Не удалось открыть файл журнала: %v":                                "Failed to open the log file: %v",
+	"Ошибка загрузки конфигурации: %v":                                   "Failed to load configuration: %v",
+	"Ошибка в параметрах командной строки: %v":                           "Invalid command line arguments: %v",
+	"Ошибка в параметре -modified-after: %v":                             "Invalid -modified-after value: %v",
+	"Ошибка в параметре -modified-before: %v":                            "Invalid -modified-before value: %v",
+	"Ошибка обработки директории: %v":                                    "Directory processing failed: %v",
+	"Ошибка закрытия временного файла: %v":                               "Failed to close the temporary file: %v",
+	"Ошибка закрытия тела ответа: %v":                                    "Failed to close the response body: %v",
+	"Ошибка закрытия файла журнала: %v":                                  "Failed to close the log file: %v",
+	"Ошибка удаления временного файла: %v":                               "Failed to remove the temporary file: %v",
+	"Ошибка при повторной попытке добавить файл в список исключений: %v": "Retry of adding the file to the exclusion list failed: %v",
+	"Ошибка: %v\n": "Error: %v\n",
+
+	// Параметры командной строки
+	"Выключить цветной вывод в консоль": "Disable colored console output",
+	"Вывод в формате JSON":              "Output in JSON format",
+	"коммит: %s\n":                      "commit: %s\n",
+	"дата сборки: %s\n":                 "build date: %s\n",
+	"Путь к файлу конфигурации":         "Path to the configuration file",
+	"Максимальное количество файлов за запуск (0 - без ограничений)":                           "Maximum number of files per run (0 - unlimited)",
+	"Максимальные затраты за запуск в долларах (0 - без ограничений)":                          "Maximum cost per run in dollars (0 - unlimited)",
+	"Порядок обработки: alphabetical, newest, oldest, smallest, priority":                      "Processing order: alphabetical, newest, oldest, smallest, priority",
+	"Обрабатывать только файлы, измененные после даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)": "Process only files modified after the date (YYYY-MM-DD, RFC3339 or age: 36h, 7d)",
+	"Обрабатывать только файлы, измененные до даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)":    "Process only files modified before the date (YYYY-MM-DD, RFC3339 or age: 36h, 7d)",
+	"Правила приоритета очереди через запятую: шаблон=приоритет (например, inbox/**=10)":       "Comma-separated queue priority rules: pattern=priority (for example, inbox/**=10)",
+	"Идентификатор запуска":                                                "Run ID",
+	"Идентификатор отменяемого запуска":                                    "ID of the run to undo",
+	"Показать результаты, полученные с устаревшей версией промпта":         "Show outputs produced with an outdated prompt version",
+	"Отменять изменения файлов, измененных после запуска":                  "Undo changes to files modified after the run",
+	"Показывать только модели, содержащие подстроку":                       "Show only models containing the substring",
+	"Температуры через запятую (по умолчанию - из конфигурации)":           "Comma-separated temperatures (default - from the configuration)",
+	"Файлы промптов через запятую (по умолчанию - промпт из конфигурации)": "Comma-separated prompt files (default - the configured prompt)",
+	"Количество файлов в выборке (0 - все)":                                "Number of files in the sample (0 - all)",
+	"Начальное значение для случайной выборки":                             "Seed for the random sample",
+	"Директория набора результатов (по умолчанию sweep-<время>)":           "Output directory of the sweep (default sweep-<time>)",
+	"Файлы, обогащенные моделью, выпущенной раньше указанной":              "Files enriched by a model released before the given one",
+	"Файлы, обогащенные с устаревшей версией промпта":                      "Files enriched with an outdated prompt version",
+	"Только показать выбранные файлы":                                      "Only list the selected files",
+	"Количество найденных документов":                                      "Number of documents to show",
+	"Директория для сайта":                                                 "Directory for the site",
+	"Путь к JSON отчету о запуске (по умолчанию - из конфигурации)":        "Path to the JSON run report (default: from the configuration)",
+
+	// Подкоманды
+	"Все результаты получены с текущей версией промпта":        "All outputs were produced with the current prompt version",
+	"%s -> %s: промпт %s, текущий %s (запуск %s, модель %s)\n": "%s -> %s: prompt %s, current %s (run %s, model %s)\n",
+	"Устаревших результатов: %d\n":                             "Stale outputs: %d\n",
+	"Запусков не найдено":                                      "No runs found",
+	" (отменено)":                                              " (undone)",
+	"не завершен":                                              "not finished",
+	"завершен %s":                                              "finished %s",
+	", отменен %s":                                             ", undone %s",
+	"%s  начат %s, %s; обогащено %d, пропущено %d, ошибок %d":  "%s  started %s, %s; enriched %d, skipped %d, failed %d",
+	"Запуск %s: отменены изменения %d файлов\n":                "Run %s: changes to %d files undone\n",
+	"  пропущен %s: выходной файл изменен после запуска (используйте --force)\n":   "  skipped %s: output file changed after the run (use --force)\n",
+	"Нет файлов для повторного обогащения":                                         "No files to re-enrich",
+	"Файлы для повторного обогащения: %d\n":                                        "Files to re-enrich: %d\n",
+	"укажите --stale-prompt, --model-older-than или шаблоны путей":                 "specify --stale-prompt, --model-older-than or path patterns",
+	"не задано ни одного условия выбора файлов":                                    "no file selection criteria given",
+	"не указан идентификатор запуска (--run)":                                      "run ID is not specified (--run)",
+	"\nПредупреждение: модель %q из конфигурации не найдена в списке провайдера\n": "\nWarning: configured model %q is not in the provider's model list\n",
+	"МОДЕЛЬ\tКОНТЕКСТ\tОТВЕТ\tВХОД $/1M\tВЫХОД $/1M":                               "MODEL\tCONTEXT\tOUTPUT\tINPUT $/1M\tOUTPUT $/1M",
+	"Файлов: %d, вариантов: %d, запросов: %d\n":                                    "Files: %d, variants: %d, requests: %d\n",
+	"Результаты сохранены в %s (index.md, summary.json), ошибок: %d\n":             "Results saved to %s (index.md, summary.json), errors: %d\n",
+	"во входной директории нет файлов для сравнения":                               "the input directory has no files to compare",
+	"Ничего не найдено":                                               "Nothing found",
+	"не задан поисковый запрос: rich search \"запрос\"":               "no search query given: rich search \"query\"",
+	"укажите формат экспорта: rich export site или rich export jsonl": "specify the export format: rich export site or rich export jsonl",
+	"Сайт сохранен в %s: документов %d (откройте index.html)\n":       "Site saved to %s: %d documents (open index.html)\n",
+
+	// Сводка сравнения промптов и температур (index.md)
+	"# Сравнение промптов и температур\n\nМодель: %s, файлов: %d, создано: %s\n\n": "# Prompt and temperature sweep\n\nModel: %s, files: %d, created: %s\n\n",
+	"## Средние по вариантам\n\n":   "## Averages by variant\n\n",
+	"\n## %s\n\n[Оригинал](%s)\n\n": "\n## %s\n\n[Original](%s)\n\n",
+	"| Промпт | Температура | Слова Δ | Заголовки Δ | Читаемость Δ | Результат |\n":                      "| Prompt | Temperature | Words Δ | Headings Δ | Readability Δ | Result |\n",
+	"| Промпт | Температура | Слова Δ | Заголовки Δ | Читаемость Δ | Токены | Стоимость, $ | Ошибки |\n": "| Prompt | Temperature | Words Δ | Headings Δ | Readability Δ | Tokens | Cost, $ | Errors |\n",
+	"| %s | %g | %+d | %+d | %+.1f | [открыть](%s) |\n": "| %s | %g | %+d | %+d | %+.1f | [open](%s) |\n",
+	"| %s | %g | - | - | - | ошибка: %s |\n":            "| %s | %g | - | - | - | error: %s |\n",
+
+	// Статический сайт проверки результатов
+	"Результаты обогащения": "Enrichment results",
+	"Запуск":               "Run",
+	"Начат":                "Started",
+	"Завершен":             "Finished",
+	"Файлов":               "Files",
+	"Обогащено":            "Enriched",
+	"Пропущено":            "Skipped",
+	"Ошибок":               "Failed",
+	"Токены":               "Tokens",
+	"Стоимость, $":         "Cost, $",
+	"Слова Δ на файл":      "Words Δ per file",
+	"Заголовки Δ на файл":  "Headings Δ per file",
+	"Читаемость Δ на файл": "Readability Δ per file",
+	"Документ":             "Document",
+	"Статус":               "Status",
+	"Оригинал":             "Original",
+	"Результат":            "Result",
+	"К списку файлов":      "Back to the file list",
+	"Ошибка":               "Error",
+	"Оригинал не сохранен в выходном файле":            "The original is not stored in the output file",
+	"Отчет о запуске не найден: статистика недоступна": "Run report not found: statistics are unavailable",
+	"ошибка при чтении отчета: %v":                     "error reading the report: %v",
+	"некорректный отчет %s: %v":                        "invalid report %s: %v",
+	"не удалось создать директорию сайта: %v":          "failed to create the site directory: %v",
+	"ошибка при формировании страницы %s: %v":          "error rendering page %s: %v",
+	"ошибка при записи страницы %s: %v":                "error writing page %s: %v",
+
+	// Самодиагностика
+	"[%s] Конфигурация: %s\n":                  "[%s] Configuration: %s\n",
+	"[%s] Конфигурация: %v\n":                  "[%s] Configuration: %v\n",
+	"конфигурация не загружена":                "configuration not loaded",
+	"не пройдено проверок: %d":                 "checks failed: %d",
+	"Входная директория":                       "Input directory",
+	"Запись в выходную директорию":             "Write to the output directory",
+	"Запись в директорию конфигурации":         "Write to the configuration directory",
+	"Запись в каталог состояния":               "Write to the state directory",
+	"Запись отчета":                            "Write the report",
+	"Доступность API":                          "API reachability",
+	"Системные часы":                           "System clock",
+	"Ключ API и модель":                        "API key and model",
+	"%d токенов":                               "%d tokens",
+	"битых ссылок: %d":                         "broken links: %d",
+	"модель %s ответила (%d токенов)":          "model %s replied (%d tokens)",
+	"расхождение с сервером %s":                "skew from the server %s",
+	"сервер не сообщил время (заголовок Date)": "the server did not report its time (Date header)",
+	"сервер недоступен: %v":                    "server unreachable: %v",
+	"некорректный api_url: %q":                 "invalid api_url: %q",
+	"создайте директорию или исправьте input_dir в секции [DIRECTORIES]":                                                                                      "create the directory or fix input_dir in the [DIRECTORIES] section",
+	"проверьте права доступа к директории или укажите другой путь в конфигурации":                                                                             "check the directory permissions or set another path in the configuration",
+	"проверьте api_url в секции [MODEL], подключение к сети и настройки прокси (HTTPS_PROXY)":                                                                 "check api_url in the [MODEL] section, the network connection and proxy settings (HTTPS_PROXY)",
+	"синхронизируйте часы (NTP): большое расхождение нарушает TLS и фильтры по дате изменения":                                                                "synchronize the clock (NTP): a large skew breaks TLS and modification date filters",
+	"проверьте параметры секции [MODEL]":                                                                                                                      "check the [MODEL] section settings",
+	"ключ не задан: укажите api_key, api_key_env или переменную окружения %s":                                                                                 "no key configured: set api_key, api_key_env or the %s environment variable",
+	"проверьте учетные данные Google: credentials_file, GOOGLE_APPLICATION_CREDENTIALS или gcloud auth application-default login, а также project и location": "check the Google credentials: credentials_file, GOOGLE_APPLICATION_CREDENTIALS or gcloud auth application-default login, as well as project and location",
+	"ключ не задан: укажите api_key или api_key_env (провайдер не определен по api_url, его можно задать параметром provider)":                                "no key configured: set api_key or api_key_env (the provider could not be detected from api_url; set it with the provider option)",
+	"ключ отклонен провайдером: проверьте api_key и права ключа":                                                                                              "the provider rejected the key: check api_key and its permissions",
+	"проверьте имя модели: список доступных моделей выводит rich models":                                                                                      "check the model name: rich models lists the available models",
+	"превышен лимит запросов или исчерпана квота провайдера":                                                                                                  "rate limit exceeded or provider quota exhausted",
+
+	// Бюджет запуска и фильтры
+	"достигнут лимит файлов на запуск (%d)":             "per-run file limit reached (%d)",
+	"достигнут лимит затрат на запуск ($%.4f из $%.4f)": "per-run cost limit reached ($%.4f of $%.4f)",
+	"файл пуст": "file is empty",
+	"размер %d байт меньше минимального %d":                                                "size %d bytes is below the minimum %d",
+	"%d слов меньше минимального количества %d":                                            "%d words is below the minimum %d",
+	"неизвестный порядок обработки: %s (допустимо: %s, %s, %s, %s, %s)":                    "unknown processing order: %s (allowed: %s, %s, %s, %s, %s)",
+	"некорректное значение даты: %s (ожидается YYYY-MM-DD, RFC3339 или срок вида 36h, 7d)": "invalid date value: %s (expected YYYY-MM-DD, RFC3339 or an age like 36h, 7d)",
+
+	// Проверка ссылок
+	"некорректная ссылка":                      "invalid link",
+	"якорь не найден":                          "anchor not found",
+	"ссылка ведет за пределы выходного дерева": "link points outside the output tree",
+	"файл не найден":                           "file not found",
+	"заметка не найдена":                       "note not found",
+	"сноска без определения":                   "footnote without a definition",
+	"внешняя ссылка отсутствует в оригинале":   "external link is not present in the original",
+	"ошибка запроса: %v":                       "request error: %v",
+
+	// Конфигурация и маршруты
+	"неизвестный провайдер %q: поддерживаются %s":                                         "unknown provider %q: supported providers are %s",
+	"некорректное значение reasoning_model %q: ожидалось auto, true или false":            "invalid reasoning_model value %q: expected auto, true or false",
+	"некорректное значение reasoning_effort %q: ожидалось %s":                             "invalid reasoning_effort value %q: expected %s",
+	"некорректное значение chunk_context %q: ожидалось %s, %s или %s":                     "invalid chunk_context value %q: expected %s, %s or %s",
+	"некорректное значение heading_level %d: ожидалось от 1 до 6 (0 - не изменять)":       "invalid heading_level value %d: expected 1 to 6 (0 - keep as is)",
+	"в замене %s не задан pattern":                                                        "replacement %s has no pattern",
+	"некорректное регулярное выражение в замене %s: %v":                                   "invalid regular expression in replacement %s: %v",
+	"некорректное регулярное выражение refusal_pattern: %v":                               "invalid refusal_pattern regular expression: %v",
+	"некорректное значение action %q в секции [GUARD]: ожидалось %s или %s":               "invalid action value %q in the [GUARD] section: expected %s or %s",
+	"некорректное значение max_similarity %g: ожидалось от 0 до 1":                        "invalid max_similarity value %g: expected 0 to 1",
+	"неизвестная категория политики %q: поддерживаются %s":                                "unknown policy category %q: supported categories are %s",
+	"некорректное действие политики %q: ожидалось %s или %s":                              "invalid policy action %q: expected %s or %s",
+	"некорректное правило политики %s: задайте регулярное выражение pattern":              "invalid policy rule %s: set a pattern regular expression",
+	"некорректное значение top_p %g: ожидалось от 0 до 1":                                 "invalid top_p value %g: expected a value from 0 to 1",
+	"некорректное значение dedup_threshold %g: ожидалось от 0 до 1":                       "invalid dedup_threshold value %g: expected a value from 0 to 1",
+	"некорректное значение dedup_action %q: ожидалось %s или %s":                          "invalid dedup_action value %q: expected %s or %s",
+	"в секции [EMAIL] должны быть заданы from (или username) и to":                        "the [EMAIL] section must set from (or username) and to",
+	"файлы оглавления должны задаваться путями внутри выходной директории: %s, %s":        "index files must be paths inside the output directory: %s, %s",
+	"Предупреждение: модель %s не поддерживает temperature, параметр не отправляется":     "Warning: model %s does not support temperature, the parameter is not sent",
+	"файл конфигурации не найден: %s":                                                     "configuration file not found: %s",
+	"не удалось загрузить файл конфигурации: %v":                                          "failed to load the configuration file: %v",
+	"ошибка загрузки конфигурации: %v":                                                    "failed to load configuration: %v",
+	"ошибка в параметре modified_after: %v":                                               "invalid modified_after value: %v",
+	"ошибка в параметре modified_before: %v":                                              "invalid modified_before value: %v",
+	"каталог состояния не задан (секция [STATE], ключ dir)":                               "state directory is not set ([STATE] section, dir key)",
+	"неподдерживаемый язык сообщений: %s (доступны: %s)":                                  "unsupported message language: %s (available: %s)",
+	"для маршрута %s не найдена секция [ROUTE.%s]":                                        "route %s: section [ROUTE.%s] not found",
+	"маршрут %s не содержит условий":                                                      "route %s has no conditions",
+	"некорректное условие маршрута %s: %s":                                                "invalid condition in route %s: %s",
+	"некорректная temperature маршрута %s: %v":                                            "invalid temperature in route %s: %v",
+	"не удалось прочитать промпт маршрута %s: %v":                                         "failed to read the prompt of route %s: %v",
+	"в примере %s должны быть заданы original (original_file) и enriched (enriched_file)": "example %s must define original (original_file) and enriched (enriched_file)",
+	"не удалось прочитать пример %s: %v":                                                  "failed to read example %s: %v",
+	"не удалось прочитать промпт %s: %v":                                                  "failed to read prompt %s: %v",
+	"некорректная температура: %q":                                                        "invalid temperature: %q",
+	"некорректный шаблон пути: %q":                                                        "invalid path pattern: %q",
+	"не удалось сохранить временный файл конфигурации: %v":                                "failed to save the temporary configuration file: %v",
+	"не удалось переименовать временный файл конфигурации: %v":                            "failed to rename the temporary configuration file: %v",
+	"не удалось убрать %s из списка исключений: %v":                                       "failed to remove %s from the exclusion list: %v",
+
+	// Пути и файлы
+	"не удалось получить абсолютный путь выходной директории: %v":   "failed to get the absolute path of the output directory: %v",
+	"ошибка при получении абсолютного пути входной директории: %v":  "failed to get the absolute path of the input directory: %v",
+	"ошибка при получении абсолютного пути выходной директории: %v": "failed to get the absolute path of the output directory: %v",
+	"ошибка при получении относительного пути: %v":                  "failed to get the relative path: %v",
+	"небезопасный путь выходной директории: %s":                     "unsafe output directory path: %s",
+	"обнаружен небезопасный путь директории: %s или %s":             "unsafe directory path detected: %s or %s",
+	"обнаружен небезопасный путь: %s или %s":                        "unsafe path detected: %s or %s",
+	"обнаружена попытка path traversal: %s":                         "path traversal attempt detected: %s",
+	"не удалось создать выходную директорию: %v":                    "failed to create the output directory: %v",
+	"ошибка при создании выходной директории: %v":                   "failed to create the output directory: %v",
+	"ошибка при создании директории: %v":                            "failed to create the directory: %v",
+	"ошибка при обходе директории: %v":                              "failed to walk the directory: %v",
+	"ошибка при обходе директории контекста: %v":                    "failed to walk the context directory: %v",
+	"ошибка при обходе выходной директории: %v":                     "failed to walk the output directory: %v",
+	"ошибка при чтении файла: %v":                                   "failed to read the file: %v",
+	"ошибка при чтении файла: %w":                                   "failed to read the file: %w",
+	"ошибка при чтении файла контекста %s: %v":                      "failed to read context file %s: %v",
+	"ошибка чтения %s: %v":                                          "failed to read %s: %v",
+	"ошибка валидации содержимого файла: %v":                        "file content validation failed: %v",
+	"размер файла превышает максимально допустимый (%d байт)":       "file size exceeds the maximum allowed (%d bytes)",
+	"ошибка при записи выходного файла: %v":                         "failed to write the output file: %v",
+	"ошибка при резервном копировании выходного файла: %v":          "failed to back up the output file: %v",
+	"не удалось создать временный файл: %v":                         "failed to create a temporary file: %v",
+	"не удалось записать данные во временный файл: %v":              "failed to write data to the temporary file: %v",
+	"не удалось закрыть временный файл: %v":                         "failed to close the temporary file: %v",
+	"не удалось установить права доступа для временного файла: %v":  "failed to set permissions on the temporary file: %v",
+	"не удалось переименовать временный файл: %v":                   "failed to rename the temporary file: %v",
+	"не удалось открыть %s: %v":                                     "failed to open %s: %v",
+	"не удалось открыть файл журнала: %v":                           "failed to open the log file: %v",
+	"не удалось прочитать %s: %v":                                   "failed to read %s: %v",
+	"не удалось удалить %s: %v":                                     "failed to remove %s: %v",
+
+	// Блокировка файлов
+	"не удалось открыть файл блокировки: %v":                     "failed to open the lock file: %v",
+	"не удалось создать файл блокировки: %v":                     "failed to create the lock file: %v",
+	"не удалось заблокировать файл %s: %v":                       "failed to lock file %s: %v",
+	"не удалось заблокировать файл %s: превышено время ожидания": "failed to lock file %s: timed out",
+
+	// Журнал запусков
+	"не удалось создать директорию запуска: %v":       "failed to create the run directory: %v",
+	"ошибка при подготовке журнала запуска: %v":       "failed to prepare the run journal: %v",
+	"ошибка при записи журнала запуска: %v":           "failed to write the run journal: %v",
+	"не удалось прочитать журнал запуска %s: %v":      "failed to read the journal of run %s: %v",
+	"некорректный журнал запуска %s: %v":              "invalid journal of run %s: %v",
+	"некорректный идентификатор запуска: %q":          "invalid run ID: %q",
+	"запуск %s не найден":                             "run %s not found",
+	"запуск %s уже отменен %s":                        "run %s was already undone at %s",
+	"не удалось прочитать резервную копию %s: %v":     "failed to read backup %s: %v",
+	"недопустимый путь резервной копии в журнале: %s": "invalid backup path in the journal: %s",
+	"не удалось прочитать список запусков: %v":        "failed to read the run list: %v",
+	"не удалось прочитать журналы запусков: %v":       "failed to read the run journals: %v",
+	"не удалось проверить версии промпта: %v":         "failed to check prompt versions: %v",
+	"неизвестна дата выпуска модели %s":               "release date of model %s is unknown",
+
+	// Отчеты
+	"ошибка при подготовке отчета: %v": "failed to prepare the report: %v",
+	"ошибка при записи отчета: %v":     "failed to write the report: %v",
+	"ошибка при подготовке итогов: %v": "failed to prepare the summary: %v",
+
+	// Запросы к API
+	"API запрос вернул статус %d: %s":                                                   "API request returned status %d: %s",
+	"Лимит запросов провайдера исчерпан, пауза %v":                                      "Provider rate limit exhausted, pausing for %v",
+	"Остаток лимита токенов провайдера меньше запроса (~%d токенов), пауза %v":          "Remaining provider token limit is below the request size (~%d tokens), pausing for %v",
+	"запрос списка моделей вернул статус %d: %s":                                        "model list request returned status %d: %s",
+	"ошибка при подготовке JSON запроса: %v":                                            "failed to prepare the JSON request: %v",
+	"ошибка при создании HTTP запроса: %v":                                              "failed to create the HTTP request: %v",
+	"ошибка при выполнении HTTP запроса: %v":                                            "HTTP request failed: %v",
+	"ошибка при выполнении HTTP запроса: %w":                                            "HTTP request failed: %w",
+	"ошибка при чтении ответа API: %w":                                                  "failed to read the API response: %w",
+	"ошибка при разборе JSON ответа: %v":                                                "failed to parse the JSON response: %v",
+	"модель отказалась выполнить запрос: %q":                                            "the model refused the request: %q",
+	"результат отклонен проверкой: %s":                                                  "the result was rejected by the check: %s",
+	"результат почти совпадает с оригиналом (сходство %.2f)":                            "the result is almost identical to the original (similarity %.2f)",
+	"результат слишком длинный: %d слов против %d в оригинале":                          "the result is too long: %d words versus %d in the original",
+	"результат слишком короткий: %d слов против %d в оригинале":                         "the result is too short: %d words versus %d in the original",
+	"ошибка при разборе списка моделей: %v":                                             "failed to parse the model list: %v",
+	"некорректный формат ответа API":                                                    "invalid API response format",
+	"некорректный формат ответа API: отсутствует поле choices или оно пустое":           "invalid API response format: the choices field is missing or empty",
+	"некорректный формат элемента choices в ответе API":                                 "invalid choices element in the API response",
+	"некорректный формат поля message в ответе API":                                     "invalid message field in the API response",
+	"некорректный формат поля content в ответе API":                                     "invalid content field in the API response",
+	"ответ содержит только рассуждения модели без результата: увеличьте max_tokens":     "the response contains only the model's reasoning and no result: increase max_tokens",
+	"Рассуждения модели (%d символов) не включены в результат":                          "Model reasoning (%d characters) was left out of the result",
+	"некорректный формат ответа Anthropic API: отсутствует поле content или оно пустое": "invalid Anthropic API response format: the content field is missing or empty",
+	"некорректный формат элемента content в ответе Anthropic API":                       "invalid content element in the Anthropic API response",
+	"некорректный формат поля text в ответе Anthropic API":                              "invalid text field in the Anthropic API response",
+	"ответ Anthropic API не содержит текстовых блоков":                                  "the Anthropic API response contains no text blocks",
+	"ошибка при разборе события потока Anthropic API: %v":                               "error parsing an Anthropic API stream event: %v",
+	"ошибка в потоке Anthropic API: %s: %s":                                             "error in the Anthropic API stream: %s: %s",
+	"поток Anthropic API прерван до события message_stop":                               "the Anthropic API stream ended before the message_stop event",
+
+	// Учетные данные Google и Vertex AI
+	"Получен токен доступа Google, действует до %s":                                                                                    "Google access token obtained, valid until %s",
+	"в ключе сервисного аккаунта %s нет client_email или private_key":                                                                  "the service account key %s has no client_email or private_key",
+	"в учетных данных %s нет refresh_token":                                                                                            "the credentials in %s have no refresh_token",
+	"для Vertex AI не задан проект: укажите project в секции [MODEL] или GOOGLE_CLOUD_PROJECT":                                         "no project configured for Vertex AI: set project in the [MODEL] section or GOOGLE_CLOUD_PROJECT",
+	"запрос заблокирован Gemini: %s":                                                                                                   "the request was blocked by Gemini: %s",
+	"не удалось прочитать учетные данные Google %s: %v":                                                                                "failed to read Google credentials %s: %v",
+	"некорректный private_key сервисного аккаунта: %v":                                                                                 "invalid service account private_key: %v",
+	"некорректный private_key сервисного аккаунта: ожидался PEM":                                                                       "invalid service account private_key: PEM expected",
+	"некорректный private_key сервисного аккаунта: ожидался ключ RSA":                                                                  "invalid service account private_key: an RSA key expected",
+	"некорректный ответ с токеном Google: %s":                                                                                          "invalid Google token response: %s",
+	"некорректный файл учетных данных Google %s: %v":                                                                                   "invalid Google credentials file %s: %v",
+	"некорректный формат ответа Gemini: отсутствует поле candidates или оно пустое":                                                    "invalid Gemini response format: the candidates field is missing or empty",
+	"некорректный формат элемента candidates в ответе Gemini":                                                                          "invalid candidates element in the Gemini response",
+	"неподдерживаемый тип учетных данных Google %q в %s":                                                                               "unsupported Google credentials type %q in %s",
+	"ответ Gemini не содержит текста (finishReason: %s)":                                                                               "the Gemini response contains no text (finishReason: %s)",
+	"ошибка подписи JWT сервисного аккаунта: %v":                                                                                       "error signing the service account JWT: %v",
+	"ошибка при получении токена Google: %v":                                                                                           "error obtaining a Google token: %v",
+	"получение токена Google вернуло статус %d: %s":                                                                                    "the Google token request returned status %d: %s",
+	"учетные данные Google не найдены: задайте credentials_file или GOOGLE_APPLICATION_CREDENTIALS (сервер метаданных недоступен: %v)": "Google credentials not found: set credentials_file or GOOGLE_APPLICATION_CREDENTIALS (metadata server unavailable: %v)",
+
+	// Контекст проекта
+	"ошибка при построении вектора документа: %v":    "failed to build the document vector: %v",
+	"ошибка при построении векторов контекста: %v":   "failed to build context vectors: %v",
+	"ошибка при индексации связанных документов: %v": "failed to index linked documents: %v",
+
+	// Похожие документы
+	"Найдено похожих документов: %d":                     "Similar documents found: %d",
+	"Похожие документы: %s совпадает с %s на %.0f%%":     "Similar documents: %s matches %s by %.0f%%",
+	"Пропуск файла %s (skipped: duplicate): похож на %s": "Skipping file %s (skipped: duplicate): similar to %s",
+	"Пропуск файла %s (skipped: policy): %s":             "Skipping file %s (skipped: policy): %s",
+	"ошибка при построении векторов документов: %v":      "failed to build document vectors: %v",
+
+	// Оглавление выходной директории
+	"# Оглавление\n\nДокументов: %d\n":                                             "# Index\n\nDocuments: %d\n",
+	"Оглавление %s не изменилось":                                                  "Index %s is unchanged",
+	"Оглавление обновлено: %s (документов %d, разобрано %d)":                       "Index updated: %s (%d documents, %d parsed)",
+	"Предупреждение: манифест оглавления %s поврежден и будет построен заново: %v": "Warning: index manifest %s is corrupted and will be rebuilt: %v",
+	"ошибка при построении оглавления: %v":                                         "failed to build the index: %v",
+	"ошибка при подготовке манифеста оглавления: %v":                               "error preparing the index manifest: %v",
+	"ошибка при записи манифеста оглавления: %v":                                   "error writing the index manifest: %v",
+	"ошибка при записи оглавления: %v":                                             "error writing the index: %v",
+
+	// Рассылка итогов по почте
+	"rich: запуск %s - обогащено %d, ошибок %d":              "rich: run %s - %d enriched, %d failed",
+	"Запуск %s\nНачат: %s, завершен: %s\n\n":                 "Run %s\nStarted: %s, finished: %s\n\n",
+	"Файлов: %d, обогащено: %d, пропущено: %d, ошибок: %d\n": "Files: %d, enriched: %d, skipped: %d, failed: %d\n",
+	"Токены: %d входных, %d выходных; стоимость: $%.4f\n":    "Tokens: %d input, %d output; cost: $%.4f\n",
+	"\nОшибки:\n":                         "\nErrors:\n",
+	"- и еще %d\n":                        "- and %d more\n",
+	"Отчет: %s\n":                         "Report: %s\n",
+	"JSON отчет: %s\n":                    "JSON report: %s\n",
+	"Подробности: rich status --run %s\n": "Details: rich status --run %s\n",
+	"Итоги запуска отправлены на %s":      "Run summary sent to %s",
+	"Письмо с итогами не отправлено: ошибок нет":                      "Summary email not sent: no failures",
+	"Предупреждение: не удалось отправить итоги запуска по почте: %v": "Warning: failed to email the run summary: %v",
+	"не удалось подключиться к SMTP серверу %s: %v":                   "failed to connect to SMTP server %s: %v",
+	"ошибка SMTP сервера %s: %v":                                      "SMTP server %s error: %v",
+	"ошибка STARTTLS: %v":                                                            "STARTTLS error: %v",
+	"ошибка авторизации на SMTP сервере: %v":                                         "SMTP authentication error: %v",
+	"SMTP сервер отклонил отправителя %s: %v":                                        "the SMTP server rejected sender %s: %v",
+	"SMTP сервер отклонил получателя %s: %v":                                         "the SMTP server rejected recipient %s: %v",
+	"ошибка отправки письма: %v":                                                     "error sending email: %v",
+	"неизвестный источник векторов %q: поддерживаются local, openai, ollama, gemini": "unknown embeddings provider %q: supported providers are local, openai, ollama, gemini",
+	"API векторов вернул %d векторов вместо %d":                                      "the embeddings API returned %d vectors instead of %d",
+	"Построено векторов: %d, из хранилища: %d":                                       "Vectors built: %d, from the store: %d",
+	"ошибка при запросе векторов: %v":                                                "error requesting embeddings: %v",
+	"некорректный ответ API векторов: %v":                                            "invalid embeddings API response: %v",
+	"некорректный ответ API векторов: индекс %d вне диапазона":                       "invalid embeddings API response: index %d out of range",
+	"некорректный ответ API векторов: пустой вектор":                                 "invalid embeddings API response: empty vector",
+	"ошибка при чтении хранилища векторов: %v":                                       "error reading the vector store: %v",
+	"ошибка при разборе хранилища векторов %s: %v":                                   "error parsing the vector store %s: %v",
+	"не удалось создать директорию хранилища векторов: %v":                           "failed to create the vector store directory: %v",
+	"ошибка при подготовке хранилища векторов: %v":                                   "error preparing the vector store: %v",
+	"ошибка при записи хранилища векторов: %v":                                       "error writing the vector store: %v",
+
+	// Режим службы
+	"\n# Сохраните юнит в /etc/systemd/system/%s.service и выполните:\n#   systemctl daemon-reload && systemctl enable --now %s\n": "\n# Save the unit to /etc/systemd/system/%s.service and run:\n#   systemctl daemon-reload && systemctl enable --now %s\n",
+	"Выполните: systemctl disable --now %s && rm /etc/systemd/system/%s.service && systemctl daemon-reload\n":                      "Run: systemctl disable --now %s && rm /etc/systemd/system/%s.service && systemctl daemon-reload\n",
+	"Имя службы": "Service name",
+	"Конфигурация перечитана из %s":                                        "Configuration reloaded from %s",
+	"Остановка обработки: служба останавливается":                          "Stopping processing: the service is shutting down",
+	"Пауза между запусками обработки":                                      "Pause between processing runs",
+	"Предупреждение: конфигурация не перечитана, используется прежняя: %v": "Warning: configuration not reloaded, keeping the previous one: %v",
+	"Предупреждение: не удалось сообщить состояние службы: %v":             "Warning: failed to report the service state: %v",
+	"Предупреждение: не удалось удалить PID файл: %v":                      "Warning: failed to remove the PID file: %v",
+	"Путь к PID файлу": "Path to the PID file",
+	"Рабочая директория службы (по умолчанию - текущая)": "Service working directory (default: current directory)",
+	"Следующий запуск в %s":                              "Next run at %s",
+	"Служба %s запущена: обработка каждые %s":            "Service %s started: processing every %s",
+	"Служба %s зарегистрирована: sc start %s - запуск, sc control %s paramchange - перечитать конфигурацию\n": "Service %s registered: sc start %s to start it, sc control %s paramchange to reload the configuration\n",
+	"Служба %s остановлена":                             "Service %s stopped",
+	"Служба %s удалена\n":                               "Service %s removed\n",
+	"не удалось записать PID файл: %v":                  "failed to write the PID file: %v",
+	"не удалось зарегистрировать обработчик службы: %v": "failed to register the service control handler: %v",
+	"не удалось определить путь к программе: %v":        "failed to determine the executable path: %v",
+	"не удалось определить рабочую директорию: %v":      "failed to determine the working directory: %v",
+	"не удалось перейти в рабочую директорию: %v":       "failed to change to the working directory: %v",
+	"не удалось подключиться к диспетчеру служб Windows (rich service run запускается диспетчером, см. rich service install): %v": "failed to connect to the Windows service control manager (rich service run is started by the SCM, see rich service install): %v",
+	"не удалось подключиться к сокету systemd: %v":                "failed to connect to the systemd socket: %v",
+	"не удалось уведомить systemd: %v":                            "failed to notify systemd: %v",
+	"неизвестное действие %q: rich service run|install|uninstall": "unknown action %q: rich service run|install|uninstall",
+	"некорректное имя службы: %v":                                 "invalid service name: %v",
+	"пауза между запусками должна быть положительной: %s":         "the pause between runs must be positive: %s",
+	"процесс %d из PID файла %s еще работает":                     "process %d from PID file %s is still running",
+	"укажите действие: rich service run|install|uninstall":        "specify an action: rich service run|install|uninstall",
+
+	// Работа в контейнере
+	"Адрес HTTP сервера проверок состояния /healthz и /readyz (например, :8080)": "Address of the /healthz and /readyz health check HTTP server (for example, :8080)",
+	"Ошибка запуска проверок состояния: %v":                                      "Failed to start health checks: %v",
+	"Ошибка сервера проверки состояния: %v":                                      "Health check server error: %v",
+	"Параметр [%s] %s задан переменной окружения":                                "Setting [%s] %s is set by an environment variable",
+	"Писать журнал в стандартный вывод вместо rich.log":                          "Write the log to standard output instead of rich.log",
+	"Проверки состояния доступны на %s (/healthz, /readyz)":                      "Health checks available at %s (/healthz, /readyz)",
+	"не удалось открыть адрес проверки состояния %s: %v":                         "failed to listen on health check address %s: %v",
+
+	// Совместная работа экземпляров через Redis
+	"claim_ttl в секции [REDIS] должен быть положительным: %s":                    "claim_ttl in the [REDIS] section must be positive: %s",
+	"Общий лимит запросов исчерпан, ожидание %v":                                  "Shared request limit reached, waiting %v",
+	"Общий лимит запросов через Redis: %d в минуту (%s)":                          "Shared request limit via Redis: %d per minute (%s)",
+	"Пакетная обработка выключена: файлы распределяются между экземплярами":       "Batching disabled: files are shared between instances",
+	"Предупреждение: не удалось передать паузу другим экземплярам: %v":            "Warning: failed to share the pause with other instances: %v",
+	"Предупреждение: общий лимит запросов недоступен, используется локальный: %v": "Warning: shared request limit unavailable, using the local one: %v",
+	"Файл %s обрабатывается другим экземпляром":                                   "File %s is being processed by another instance",
+	"не удалось закрепить файл %s: %v":                                            "failed to claim file %s: %v",
+	"не удалось подключиться к Redis %s: %v":                                      "failed to connect to Redis %s: %v",
+	"не удалось снять закрепление файла %s: %v":                                   "failed to release the claim on file %s: %v",
+	"некорректный адрес Redis %q: ожидается redis://хост:порт/база":               "invalid Redis address %q: expected redis://host:port/db",
+	"некорректный номер базы Redis %q":                                            "invalid Redis database number %q",
+	"некорректный ответ Redis: %q":                                                "invalid Redis reply: %q",
+	"неожиданный ответ Redis на %s: %v":                                           "unexpected Redis reply to %s: %v",
+	"ошибка авторизации в Redis: %v":                                              "Redis authentication error: %v",
+	"ошибка выбора базы Redis: %v":                                                "error selecting the Redis database: %v",
+	"ошибка обмена с Redis: %v":                                                   "Redis communication error: %v",
+	"пустой ответ Redis":                                                          "empty Redis reply",
+
+	// База данных запусков
+	"Вывести результат в JSON":                          "Print the result as JSON",
+	"Запуск %s записан в базу данных %s":                "Run %s recorded in database %s",
+	"Максимальное количество строк встроенного запроса": "Maximum number of rows for built-in queries",
+	"Нет данных": "No data",
+	"Предупреждение: запуск не записан в базу данных: %v":           "Warning: run not recorded in the database: %v",
+	"база данных запусков не задана (секция [DATABASE], ключ file)": "run database is not configured ([DATABASE] section, file key)",
+	"ошибка SQLite: %v: %s": "SQLite error: %v: %s",
+	"программа %s не найдена: установите SQLite или укажите путь ключом sqlite3 секции [DATABASE]": "program %s not found: install SQLite or set its path with the sqlite3 key in the [DATABASE] section",
+	"укажите действие: rich db query <запрос>":                                                     "specify an action: rich db query <query>",
+	"укажите запрос: %s или SQL":                                                                   "specify a query: %s or SQL",
+
+	// Хранение секретов
+	"не удалось получить секрет %s из хранилища ОС: %v":                                  "failed to get secret %s from the OS credential store: %v",
+	"для зашифрованного значения задайте парольную фразу в переменной %s":                "set the passphrase for encrypted values in the %s variable",
+	"некорректное зашифрованное значение":                                                "invalid encrypted value",
+	"не удалось расшифровать значение: неверная парольная фраза или поврежденные данные": "failed to decrypt the value: wrong passphrase or corrupted data",
+	"секрет не задан: передайте его в стандартный ввод":                                  "no secret given: pass it on standard input",
+	"укажите действие: rich secret set <служба>/<учетная запись> | rich secret encrypt":  "specify an action: rich secret set <service>/<account> | rich secret encrypt",
+	"укажите секрет: rich secret set <служба>/<учетная запись>":                          "specify the secret: rich secret set <service>/<account>",
+	"не удалось сохранить секрет в хранилище ОС: %v":                                     "failed to save the secret to the OS credential store: %v",
+	"Секрет сохранен, укажите в конфигурации: api_key = %s\n":                            "Secret saved, set in the configuration: api_key = %s\n",
+	"не удалось зашифровать секрет: %v":                                                  "failed to encrypt the secret: %v",
+	"неизвестное действие %q: rich secret set|encrypt":                                   "unknown action %q: rich secret set|encrypt",
+	"ошибка в параметре api_key: %v":                                                     "invalid api_key parameter: %v",
+	"ошибка в параметре api_key секции [EMBEDDINGS]: %v":                                 "invalid api_key parameter in the [EMBEDDINGS] section: %v",
+	"ошибка в параметре password секции [EMAIL]: %v":                                     "invalid password parameter in the [EMAIL] section: %v",
+	"некорректный api_key маршрута %s: %v":                                               "invalid api_key of route %s: %v",
+	"запись %s/%s не найдена в связке ключей: %v":                                        "entry %s/%s not found in the keychain: %v",
+	"ошибка программы security: %v: %s":                                                  "security program failed: %v: %s",
+	"запись %s/%s не найдена в хранилище Secret Service":                                 "entry %s/%s not found in the Secret Service store",
+	"программа secret-tool не найдена: установите libsecret-tools":                       "secret-tool program not found: install libsecret-tools",
+	"ошибка программы secret-tool: %v: %s":                                               "secret-tool program failed: %v: %s",
+	"запись %s/%s не найдена в диспетчере учетных данных: %v":                            "entry %s/%s not found in Credential Manager: %v",
+	"ошибка диспетчера учетных данных: %v":                                               "Credential Manager error: %v",
+	"не удалось получить секрет %s: %v":                                                  "failed to get secret %s: %v",
+	"Получен секрет %s, действует до %s":                                                 "Secret %s obtained, valid until %s",
+	"секрет %s содержит несколько полей: укажите поле после #":                           "secret %s has several fields: specify the field after #",
+	"в секрете %s нет строкового поля %s":                                                "secret %s has no string field %s",
+	"секрет %s не является объектом JSON, поле %s недоступно":                            "secret %s is not a JSON object, field %s is not available",
+	"ошибка запроса к %s: %v":                                                            "request to %s failed: %v",
+	"%s вернул статус %d: %s":                                                            "%s returned status %d: %s",
+	"некорректный ответ %s: %v":                                                          "invalid %s response: %v",
+	"для ссылки vault: задайте VAULT_ADDR и VAULT_TOKEN (или выполните vault login)":     "for a vault: reference set VAULT_ADDR and VAULT_TOKEN (or run vault login)",
+	"для ссылки aws-sm: задайте AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY":               "for an aws-sm: reference set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY",
+	"для ссылки aws-sm: задайте регион в AWS_REGION или укажите ARN секрета":             "for an aws-sm: reference set the region in AWS_REGION or use the secret ARN",
+	"некорректная ссылка gcp-sm:%s: ожидалось <проект>/<секрет>[/<версия>]":              "invalid gcp-sm:%s reference: expected <project>/<secret>[/<version>]",
+
+	// Переменные окружения в конфигурации
+	"переменная окружения %s не задана (параметр %s секции [%s])": "environment variable %s is not set (key %s in section [%s])",
+
+	// Включение конфигураций
+	"циклическое включение конфигурации: %s":                                   "circular configuration include: %s",
+	"не удалось загрузить файл конфигурации %s: %v":                            "failed to load configuration file %s: %v",
+	"не удалось прочитать файл конфигурации: %v":                               "failed to read the configuration file: %v",
+	"укажите действие: rich config show [--effective]":                         "specify an action: rich config show [--effective]",
+	"Показать итоговую конфигурацию с учетом включений и переменных окружения": "Show the effective configuration including includes and environment variables",
+	"; Итоговая конфигурация, файлы в порядке применения: %s\n\n":              "; Effective configuration, files in order of application: %s\n\n",
+	"по умолчанию": "default",
+
+	// Несколько корней входных файлов
+	"Обработка корня %s: %s -> %s":                  "Processing root %s: %s -> %s",
+	"для корня [DIRECTORIES.%s] не задан input_dir": "input_dir is not set for root [DIRECTORIES.%s]",
+	"некорректное имя корня [DIRECTORIES.%s]: имя не должно содержать разделителей пути": "invalid root name [DIRECTORIES.%s]: the name must not contain path separators",
+
+	// Большие файлы
+	"max_file_size должен быть положительным: %d":                                          "max_file_size must be positive: %d",
+	"некорректное значение oversize %q: ожидалось %s, %s, %s или %s":                       "invalid oversize value %q: expected %s, %s, %s or %s",
+	"Пропуск файла %s (skipped: too large): %d байт при ограничении %d":                    "Skipping file %s (skipped: too large): %d bytes, limit %d",
+	"Предупреждение: файл %s больше %d байт, в модель отправляется начало файла (%d байт)": "Warning: file %s is larger than %d bytes, only its beginning (%d bytes) is sent to the model",
+	"Файл %s больше %d байт и обогащается по частям":                                       "File %s is larger than %d bytes and is enriched in parts",
+
+	// Параметры сети
+	"timeout в секции [NETWORK] должен быть положительным: %s":                                                               "timeout in the [NETWORK] section must be positive: %s",
+	"max_idle_conns в секции [NETWORK] не может быть отрицательным: %d":                                                      "max_idle_conns in the [NETWORK] section cannot be negative: %d",
+	"некорректное значение tls_min_version %q: ожидалось 1.2 или 1.3":                                                        "invalid tls_min_version value %q: expected 1.2 or 1.3",
+	"Предупреждение: проверка TLS сертификатов выключена (insecure_skip_verify), используйте это только на тестовых стендах": "Warning: TLS certificate verification is disabled (insecure_skip_verify), use this only in lab setups",
+
+	// Приоритет очереди
+	"некорректный шаблон приоритета: %s":                             "invalid priority pattern: %s",
+	"некорректный приоритет для %s: %s":                              "invalid priority for %s: %s",
+	"некорректное правило приоритета %q: ожидалось шаблон=приоритет": "invalid priority rule %q: expected pattern=priority",
+	"Предупреждение: некорректное значение %s в %s: %s":              "Warning: invalid %s value in %s: %s",
+	"Ошибка в параметре -priority: %v":                               "Invalid -priority value: %v",
+
+	// Ограничение частоты запросов
+	"tokens_per_minute не может быть отрицательным: %d":                             "tokens_per_minute cannot be negative: %d",
+	"Лимит токенов в минуту: запрос (~%d токенов) ждет %v":                          "Tokens per minute limit: the request (~%d tokens) waits %v",
+	"burst не может быть отрицательным: %d":                                         "burst cannot be negative: %d",
+	"Ожидание ограничителя частоты запросов: %d раз, всего %.1f с, максимум %.1f с": "Rate limiter waits: %d times, %.1f s in total, %.1f s at most",
+
+	// Окна обслуживания провайдера
+	"некорректное окно обслуживания %q: ожидалось [дни] ЧЧ:ММ-ЧЧ:ММ": "invalid maintenance window %q: expected [days] HH:MM-HH:MM",
+	"некорректный день недели в окне обслуживания: %s":               "invalid weekday in maintenance window: %s",
+	"некорректное время в окне обслуживания: %s":                     "invalid time in maintenance window: %s",
+	"некорректный maintenance_timezone %q: %v":                       "invalid maintenance_timezone %q: %v",
+	"Окно обслуживания провайдера до %s: файлы ждут в очереди":       "Provider maintenance window until %s: files are queued",
+	"Окно обслуживания завершено, отправка файлов возобновлена":      "Maintenance window is over, dispatching files again",
+
+	// Хронология обработки файлов
+	"Задержки этапов (p50/p90/p99, мс): %s": "Stage latencies (p50/p90/p99, ms): %s",
+
+	// Диагностика процесса
+	"Адрес HTTP сервера профилирования /debug/pprof/ (например, localhost:6060)":                           "Address of the /debug/pprof/ profiling HTTP server (for example, localhost:6060)",
+	"Интервал записи в журнал количества горутин и размера кучи (0 - выключено)":                           "Interval for logging goroutine count and heap size (0 - disabled)",
+	"не удалось открыть адрес профилирования %s: %v":                                                       "failed to listen on profiling address %s: %v",
+	"Ошибка сервера профилирования: %v":                                                                    "Profiling server error: %v",
+	"Профилирование доступно на http://%s/debug/pprof/":                                                    "Profiling is available at http://%s/debug/pprof/",
+	"Ошибка запуска профилирования: %v":                                                                    "Failed to start profiling: %v",
+	"Состояние процесса: горутин %d, куча %.1f МБ (объектов %d), получено от ОС %.1f МБ, сборок мусора %d": "Process state: %d goroutines, heap %.1f MB (%d objects), %.1f MB obtained from the OS, %d GC cycles",
+
+	// Конвейер записи
+	"размер очереди записи не может быть отрицательным: %d":                                                                "write queue size cannot be negative: %d",
+	"Конвейер: запросы к API %.1f с (ожидание очереди записи %.1f с), запись %.1f с (простой %.1f с), очередь до %d из %d": "Pipeline: API requests %.1f s (waiting for the write queue %.1f s), writing %.1f s (idle %.1f s), queue up to %d of %d",
+
+	// Транзакционный запуск
+	"Сохранять результаты, только если все файлы запуска обработаны без ошибок":        "Save results only if every file of the run is processed without errors",
+	"Обогащенное содержимое %s подготовлено к фиксации транзакции":                     "Enriched content %s is staged for the transaction commit",
+	"Транзакция зафиксирована: сохранено результатов %d":                               "Transaction committed: results saved: %d",
+	"транзакция отменена, результаты не сохранены: %w":                                 "transaction rolled back, results were not saved: %w",
+	"транзакция отменена: обработка остановлена до окончания, результаты не сохранены": "transaction rolled back: processing stopped before completion, results were not saved",
+	"не удалось создать каталог транзакции: %v":                                        "failed to create the transaction directory: %v",
+	"не удалось перенести результат %s: %v":                                            "failed to move result %s: %v",
+	"Ошибка отмены переноса %s: %v":                                                    "Error undoing the move of %s: %v",
+	"Ошибка восстановления прежнего файла %s: %v":                                      "Error restoring the previous file %s: %v",
+	"Ошибка удаления каталога транзакции: %v":                                          "Error removing the transaction directory: %v",
+
+	// Намерения записи
+	"Восстановлено состояние %s: результат записан до аварийного завершения, повторная обработка не нужна": "State recovered for %s: the result was written before the crash, no reprocessing needed",
+	"Незавершенная запись %s: файл будет обработан заново":                                                 "Incomplete write %s: the file will be processed again",
+	"Предупреждение: не удалось удалить намерение записи %s: %v":                                           "Warning: failed to remove write intent %s: %v",
+	"Предупреждение: некорректное намерение записи %s: %v":                                                 "Warning: invalid write intent %s: %v",
+	"не удалось прочитать намерения записи: %v":                                                            "failed to read write intents: %v",
+	"не удалось создать директорию намерений записи: %v":                                                   "failed to create the write intents directory: %v",
+	"ошибка при сохранении намерения записи: %v":                                                           "error saving write intent: %v",
+	"ошибка при подготовке намерения записи: %v":                                                           "error preparing write intent: %v",
+
+	// Категории ошибок
+	"файлов с ошибками: %d (%s)": "files with errors: %d (%s)",
+
+	// Поток состояния
+	"Поток событий обработки файлов в формате NDJSON: stdout, stderr, fd:N или путь файла": "NDJSON stream of file processing events: stdout, stderr, fd:N or a file path",
+	"Ошибка открытия потока состояния: %v":                                                 "Error opening the status stream: %v",
+	"Предупреждение: не удалось записать событие в поток состояния: %v":                    "Warning: failed to write an event to the status stream: %v",
+	"не удалось открыть поток состояния: %v":                                               "failed to open the status stream: %v",
+	"некорректный дескриптор потока состояния: %s (ожидается fd:N, N >= 3)":                "invalid status stream descriptor: %s (expected fd:N, N >= 3)",
+
+	// Оповещения о квоте
+	"consecutive_429 в секции [ALERTS] не может быть отрицательным: %d":  "consecutive_429 in section [ALERTS] cannot be negative: %d",
+	"daily_budget_usd в секции [ALERTS] не может быть отрицательным: %g": "daily_budget_usd in section [ALERTS] cannot be negative: %g",
+	"некорректный порог в budget_thresholds секции [ALERTS]: %q":         "invalid threshold in budget_thresholds of section [ALERTS]: %q",
+	"израсходовано $%.2f из дневного бюджета $%.2f (порог %g%%)":         "spent $%.2f of the daily budget $%.2f (threshold %g%%)",
+	"подряд %d ответов 429: превышен лимит запросов провайдера":          "%d consecutive 429 responses: provider rate limit exceeded",
+	"Оповещение о квоте: %s":                                             "Quota alert: %s",
+	"rich: оповещение о квоте - %s":                                      "rich: quota alert - %s",
+	"Предупреждение: не удалось сохранить расходы за день: %v":           "Warning: failed to save daily spend: %v",
+	"Предупреждение: некорректный файл расходов %s: %v":                  "Warning: invalid spend file %s: %v",
+	"Предупреждение: не удалось отправить оповещение на вебхук: %v":      "Warning: failed to send alert to webhook: %v",
+	"Предупреждение: не удалось отправить оповещение по почте: %v":       "Warning: failed to send alert by email: %v",
+	"Предупреждение: ошибка команды оповещения: %v":                      "Warning: alert command failed: %v",
+	"вебхук вернул статус %d":                                            "webhook returned status %d",
+	"команда %s завершилась с ошибкой: %v: %s":                           "command %s failed: %v: %s",
+
+	// Заголовки и имена файлов
+	"slug_template в секции [TITLES] должен содержать {{slug}}: %q":           "slug_template in section [TITLES] must contain {{slug}}: %q",
+	"slug_template в секции [TITLES] не может содержать разделители пути: %q": "slug_template in section [TITLES] cannot contain path separators: %q",
+	"Предупреждение: не удалось подобрать заголовок для %s: %v":               "Warning: failed to propose a title for %s: %v",
+	"Результат %s сохраняется под именем %s":                                  "Result of %s is saved as %s",
+	"в ответе модели нет заголовка: %q":                                       "model response contains no title: %q",
+	"некорректный ответ модели с заголовком: %v":                              "invalid model response with title: %v",
+	"не удалось прочитать соответствия заголовков: %v":                        "failed to read title mapping: %v",
+	"не удалось сохранить соответствия заголовков: %v":                        "failed to save title mapping: %v",
+	"некорректный файл соответствий заголовков %s: %v":                        "invalid title mapping file %s: %v",
+
+	// Связанные заметки
+	"top_k в секции [RELATED] должен быть положительным: %d":                       "top_k in section [RELATED] must be positive: %d",
+	"Корпус связанных заметок: %d документов":                                      "Related notes corpus: %d documents",
+	"неизвестный формат ссылок %q в секции [RELATED]: ожидалось markdown или wiki": "unknown link format %q in section [RELATED]: expected markdown or wiki",
+	"некорректное значение min_similarity %g: ожидалось от 0 до 1":                 "invalid min_similarity value %g: expected 0 to 1",
+
+	// Карточки
+	"max_cards в секции [FLASHCARDS] должен быть положительным: %d":               "max_cards in section [FLASHCARDS] must be positive: %d",
+	"Отброшено неполных карточек: %d":                                             "Incomplete flashcards dropped: %d",
+	"Предупреждение: не удалось извлечь карточки из %s: %v":                       "Warning: failed to extract flashcards from %s: %v",
+	"Предупреждение: не удалось сохранить карточки %s: %v":                        "Warning: failed to save flashcards %s: %v",
+	"в ответе модели нет карточек: %q":                                            "model response contains no flashcards: %q",
+	"неизвестный вид карточек %q в секции [FLASHCARDS]: ожидалось qa или cloze":   "unknown flashcard kind %q in section [FLASHCARDS]: expected qa or cloze",
+	"неизвестный формат карточек %q в секции [FLASHCARDS]: ожидалось tsv или csv": "unknown flashcard format %q in section [FLASHCARDS]: expected tsv or csv",
+	"некорректный ответ модели с карточками: %v":                                  "invalid model response with flashcards: %v",
+
+	// Журнал изменений
+	"неизвестное значение target %q в секции [CHANGELOG]: ожидалось section, file или both": "unknown target value %q in [CHANGELOG] section: expected section, file or both",
+	"max_items в секции [CHANGELOG] должен быть положительным: %d":                          "max_items in [CHANGELOG] section must be positive: %d",
+	"файл изменений должен задаваться путем внутри выходной директории: %s":                 "changelog file must be a path inside the output directory: %s",
+	"Отброшено пунктов списка изменений, не подтвержденных изменениями: %d":                 "Dropped changelog items not backed by the diff: %d",
+	"Предупреждение: не удалось составить список изменений %s: %v":                          "Warning: failed to build changelog for %s: %v",
+	"## Запуск %s (%s)\n\n": "## Run %s (%s)\n\n",
+	"## Запуск %s\n\n":      "## Run %s\n\n",
+	"# Изменения\n\n":       "# Changelog\n\n",
+	"не удалось прочитать файл изменений: %v": "failed to read changelog file: %v",
+	"не удалось записать файл изменений: %v":  "failed to write changelog file: %v",
+
+	// Режимы outline и skeleton
+	"Заполнение незаполненных разделов %s: %d":                             "Filling unfinished sections of %s: %d",
+	"Обновление оглавления %s":                                             "Refreshing outline of %s",
+	"Предупреждение: ошибка при заполнении разделов %s: %v":                "Warning: failed to fill sections of %s: %v",
+	"Предупреждение: ошибка при построении оглавления %s: %v":              "Warning: failed to build outline of %s: %v",
+	"Пропуск файла %s (skipped: complete): нет незаполненных разделов":     "Skipping file %s (skipped: complete): no unfinished sections",
+	"модель вернула пустое оглавление":                                     "model returned an empty outline",
+	"модель вернула пустой текст раздела %q":                               "model returned empty text for section %q",
+	"неизвестный режим обработки %q: ожидалось full, outline или skeleton": "unknown processing mode %q: expected full, outline or skeleton",
+
+	// Руководство по стилю
+	"Предупреждение: нарушения руководства по стилю в %s: %d": "Warning: style guide violations in %s: %d",
+	"Стиль %s:%d (%s): %s": "Style %s:%d (%s): %s",
+	"не удалось прочитать руководство по стилю: %v":                                            "failed to read style guide: %v",
+	"неизвестное значение oxford_comma %q в секции [STYLE]: ожидалось off, require или forbid": "unknown oxford_comma value %q in [STYLE] section: expected off, require or forbid",
+
+	// Источники
+	"max_items в секции [CITATIONS] должен быть положительным: %d":                     "max_items in [CITATIONS] section must be positive: %d",
+	"Источник %s для %s не подтвержден: %s":                                            "Source %s for %s is not verified: %s",
+	"Предупреждение: не удалось подобрать источники для %s: %v":                        "Warning: failed to suggest sources for %s: %v",
+	"в ответе модели нет источников: %q":                                               "model response contains no sources: %q",
+	"заголовок страницы %q не похож на %q":                                             "page title %q does not match %q",
+	"неизвестное значение unverified %q в секции [CITATIONS]: ожидалось drop или flag": "unknown unverified value %q in [CITATIONS] section: expected drop or flag",
+	"некорректное значение min_title_match %g: ожидалось от 0 до 1":                    "invalid min_title_match value %g: expected 0 to 1",
+	"некорректный ответ модели с источниками: %v":                                      "invalid model response with sources: %v",
+
+	// Набор данных
+	"Набор данных сохранен в %s: пар %d\n":               "Dataset saved to %s: %d pairs\n",
+	"Пропуск %s: оригинал недоступен: %v":                "Skipping %s: original is unavailable: %v",
+	"Пропуск %s: результат недоступен: %v":               "Skipping %s: output is unavailable: %v",
+	"Пропускать результаты, измененные после обогащения": "Skip outputs modified after enrichment",
+	"Только результаты указанной модели":                 "Only outputs of the given model",
+	"Файл для записи (по умолчанию - стандартный вывод)": "Output file (standard output by default)",
+	"не удалось создать файл набора данных: %v":          "failed to create dataset file: %v",
+	"ошибка записи набора данных: %v":                    "failed to write dataset: %v",
+
+	// Метки проверки
+	"Имя проверяющего":     "Reviewer name",
+	"Комментарий к оценке": "Label note",
+	"Нет оценок":           "No labels",
+	"Оценка":               "Label",
+	"Оценки не записаны в базу данных: %v":                                     "Labels were not written to the database: %v",
+	"Только результаты с оценкой проверяющего":                                 "Only outputs labeled by a reviewer",
+	"Только результаты с указанной оценкой":                                    "Only outputs with the given label",
+	"Чтобы оценить результат, выполните одну из команд:":                       "To label this output, run one of:",
+	"не удалось прочитать оценки результатов: %v":                              "failed to read output labels: %v",
+	"не удалось сохранить оценки результатов: %v":                              "failed to save output labels: %v",
+	"неизвестная оценка %q: ожидалось accepted или rejected":                   "unknown label %q: expected accepted or rejected",
+	"неизвестное действие %q: ожидалось accept, reject, clear, list или stats": "unknown action %q: expected accept, reject, clear, list or stats",
+	"некорректный файл оценок %s: %v":                                          "invalid labels file %s: %v",
+	"нет оценки": "not labeled",
+	"нет результата обогащения для %s":                                   "no enrichment output for %s",
+	"укажите действие: rich label accept, reject, clear, list или stats": "specify an action: rich label accept, reject, clear, list or stats",
+	"укажите пути исходных файлов":                                       "specify source file paths",
+	"Оценка %s сохранена: %d\n":                                          "Label %s saved: %d\n",
+	"Оценки сняты: %d\n":                                                 "Labels cleared: %d\n",
+
+	// Конвертация через pandoc
+	"formats в секции [PANDOC] не должен содержать md: markdown обрабатывается без конвертации": "formats in the [PANDOC] section must not contain md: markdown is processed without conversion",
+	"pandoc не завершился за %v":                                              "pandoc did not finish within %v",
+	"timeout в секции [PANDOC] должен быть положительным: %v":                 "timeout in the [PANDOC] section must be positive: %v",
+	"Документ %s (%s) сконвертирован в markdown":                              "Document %s (%s) converted to markdown",
+	"Предупреждение: программа %s не найдена, документы %s не обрабатываются": "Warning: program %s not found, %s documents are not processed",
+	"не удалось прочитать результат pandoc: %v":                               "failed to read pandoc output: %v",
+	"ошибка pandoc: %v: %s":                                            "pandoc error: %v: %s",
+	"ошибка конвертации %s в markdown: %v":                             "failed to convert %s to markdown: %v",
+	"ошибка конвертации результата %s в %s: %v":                        "failed to convert the result %s to %s: %v",
+	"установите pandoc или укажите путь ключом binary секции [PANDOC]": "install pandoc or set its path with the binary key of the [PANDOC] section",
+
+	// Документы PDF
+	"max_pages в секции [PDF] не может быть отрицательным: %d":                               "max_pages in the [PDF] section cannot be negative: %d",
+	"min_text_chars в секции [PDF] не может быть отрицательным: %d":                          "min_text_chars in the [PDF] section cannot be negative: %d",
+	"Из документа %s извлечен текст: страниц %d":                                             "Extracted text from %s: %d pages",
+	"Пропуск файла %s (skipped: no text): в документе PDF нет текстового слоя (страниц: %d)": "Skipping file %s (skipped: no text): the PDF document has no text layer (%d pages)",
+	"в документе PDF не найдены страницы":                                                    "no pages found in the PDF document",
+	"зашифрованные документы PDF не поддерживаются":                                          "encrypted PDF documents are not supported",
+	"не удалось извлечь текст из %s: %v":                                                     "failed to extract text from %s: %v",
+	"файл не является документом PDF":                                                        "the file is not a PDF document",
+	"фильтр %v не поддерживается":                                                            "filter %v is not supported",
+
+	// Распознавание изображений
+	"API %s не поддерживает изображения в запросе":                               "API %s does not support images in requests",
+	"max_image_bytes в секции [OCR] должен быть положительным: %d":               "max_image_bytes in section [OCR] must be positive: %d",
+	"tesseract не завершился за %v":                                              "tesseract did not finish within %v",
+	"Предупреждение: изображение %s заметки %s не найдено во входной директории": "Warning: image %s of note %s not found in the input directory",
+	"Предупреждение: ошибка при распознавании текста %s: %v":                     "Warning: text recognition failed for %s: %v",
+	"Пропуск файла %s (skipped: no text): на изображениях не найден текст":       "Skipping file %s (skipped: no text): no text found in the images",
+	"Распознан текст изображений %s: %d":                                         "Recognized text of images in %s: %d",
+	"изображение %s больше %d байт":                                              "image %s is larger than %d bytes",
+	"маршрут %s: %v": "route %s: %v",
+	"неизвестный способ распознавания %q: ожидалось off, vision или tesseract": "unknown recognition engine %q: expected off, vision or tesseract",
+	"ошибка tesseract: %v: %s":          "tesseract error: %v: %s",
+	"ошибка при чтении изображения: %v": "error reading image: %v",
+	"программа %s не найдена: установите tesseract или укажите путь ключом tesseract секции [OCR]": "program %s not found: install tesseract or set the path with the tesseract key in section [OCR]",
+
+	// Сборка директорий
+	"%s -> %s (заметок: %d)\n":                         "%s -> %s (notes: %d)\n",
+	"%s -> %s: собрано заметок %d\n":                   "%s -> %s: compiled %d notes\n",
+	"%s: заметки не изменились, сборка пропущена\n":    "%s: notes unchanged, compilation skipped\n",
+	"%s: ошибка сборки: %v\n":                          "%s: compilation error: %v\n",
+	"Во входной директории нет манифестов сборки %s\n": "No %s compilation manifests in the input directory\n",
+	"Затраты за запуск: $%.4f\n":                       "Run cost: $%.4f\n",
+	"output манифеста сборки %s должен быть путем к .md внутри выходной директории: %s": "output of compilation manifest %s must be a path to a .md file inside the output directory: %s",
+	"Предупреждение: заметка %s не включена в сборку %s по политике содержимого":        "Warning: note %s is left out of compilation %s by the content policy",
+	"Собрано из заметок:":                                     "Compiled from notes:",
+	"Собрать заново, даже если заметки не изменились":         "Compile again even if the notes have not changed",
+	"Только показать заметки сборок в порядке манифеста":      "Only show the notes of each compilation in manifest order",
+	"в директории сборки %s нет заметок":                      "compilation directory %s has no notes",
+	"директория %s вне входной директории":                    "directory %s is outside the input directory",
+	"директория %s не найдена во входной директории":          "directory %s not found in the input directory",
+	"для сборки корня входной директории укажите output в %s": "set output in %s to compile the root of the input directory",
+	"заметка %s из манифеста сборки %s не найдена":            "note %s from compilation manifest %s not found",
+	"заметка %s манифеста сборки %s вне входной директории":   "note %s of compilation manifest %s is outside the input directory",
+	"не удалось прочитать манифест сборки %s: %v":             "failed to read compilation manifest %s: %v",
+	"не удалось собрать директорий: %d":                       "failed to compile directories: %d",
+	"ошибка при обходе директории сборки %s: %v":              "error walking compilation directory %s: %v",
+	"ошибка при поиске манифестов сборки: %v":                 "error searching for compilation manifests: %v",
+
+	// Разделение документов
+	"%s -> %s: заметок %d\n":      "%s -> %s: %d notes\n",
+	"%s: ошибка разделения: %v\n": "%s: split error: %v\n",
+	"Во входной директории нет документов больше %d байт\n": "No documents larger than %d bytes in the input directory\n",
+	"Источник":   "Source",
+	"Оглавление": "Contents",
+	"Только показать предложенные заметки, файлы не записываются": "Only show the proposed notes without writing files",
+	"в ответе модели нет разметки документа: %q":                  "the model response has no document split: %q",
+	"модель не разделила документ: заметок %d":                    "the model did not split the document: %d notes",
+	"не удалось разделить документов: %d":                         "failed to split documents: %d",
+	"некорректный ответ модели с разметкой документа: %v":         "invalid model response with the document split: %v",
+	"ошибка при получении абсолютного пути: %v":                   "error getting absolute path: %v",
+	"разделяются только markdown документы: %s":                   "only markdown documents can be split: %s",
+	"строка": "line",
+	"файл %s вне входной директории":         "file %s is outside the input directory",
+	"файл %s запрещен политикой содержимого": "file %s is blocked by the content policy",
+
+	// Вопросы по документам
+	"В обогащенных документах не найдено фрагментов по вопросу": "No excerpts related to the question found in the enriched documents",
+	"Источники:": "Sources:",
+	"Количество фрагментов документов, отправляемых модели":            "Number of document excerpts sent to the model",
+	"Показать все отправленные фрагменты, а не только процитированные": "Show all excerpts sent, not only the cited ones",
+	"не задан вопрос: rich ask \"вопрос\"":                             "no question given: rich ask \"question\"",
+
+	// Конфликты с ручными правками
+	"Предупреждение: %s изменен вручную после обогащения, новый результат сохранен в %s": "Warning: %s was edited by hand after enrichment, the new result is saved to %s",
+	"Результаты изменены вручную, новые версии сохранены рядом в файлы %s: %d":           "Results edited by hand, new versions saved next to them as %s files: %d",
+	"изменен вручную, новый результат в %s":                                              "edited by hand, new result in %s",
+	"некорректное значение on_conflict %q: ожидалось %s или %s":                          "invalid on_conflict value %q: expected %s or %s",
+
+	// Слияние ручных правок
+	"  (без изменений)":                                        "  (no changes)",
+	"  ... еще строк: %d\n":                                    "  ... more lines: %d\n",
+	"Исходный документ":                                        "Source document",
+	"Конфликтов с ручными правками нет":                        "No conflicts with manual edits",
+	"Новое обогащение относительно прежнего:":                  "New enrichment compared to the previous one:",
+	"Новое обогащение относительно ручных правок:":             "New enrichment compared to the manual edits:",
+	"Новое обогащение":                                         "New enrichment",
+	"Объединенная версия (%s, конфликтов: %d):":                "Merged version (%s, conflicts: %d):",
+	"Объединенная версия записана в %s (rich undo --run %s)\n": "Merged version written to %s (rich undo --run %s)\n",
+	"Осталось конфликтов: %d, найдите маркеры %s в файле\n":    "Conflicts left: %d, look for %s markers in the file\n",
+	"Предупреждение: модель не разрешила конфликты, предложено слияние с маркерами конфликтов":  "Warning: the model did not resolve the conflicts, proposing a merge with conflict markers",
+	"Предупреждение: не удалось сохранить копию результата: %v":                                 "Warning: failed to save a copy of the result: %v",
+	"Предупреждение: не удалось удалить %s: %v":                                                 "Warning: failed to remove %s: %v",
+	"Предупреждение: слияние моделью не удалось, предложено слияние с маркерами конфликтов: %v": "Warning: merging with the model failed, proposing a merge with conflict markers: %v",
+	"Прежнее обогащение":                              "Previous enrichment",
+	"Применить объединенную версию без подтверждения": "Apply the merged version without confirmation",
+	"Применить объединенную версию? [y/N]: ":          "Apply the merged version? [y/N]: ",
+	"Ручные правки относительно прежнего обогащения:": "Manual edits compared to the previous enrichment:",
+	"Ручные правки":                                   "Manual edits",
+	"Слияние без модели: пересекающиеся правки отмечаются маркерами конфликтов": "Merge without the model: overlapping changes are marked with conflict markers",
+	"Слияние не применено": "Merge not applied",
+	"Только показать объединенную версию, файлы не изменяются": "Only show the merged version, files are not changed",
+	"для %s нет неразрешенного конфликта":                      "no unresolved conflict for %s",
+	"запуск": "run",
+	"копии нет, все различия считаются конфликтами":   "no copy, every difference is a conflict",
+	"копия в каталоге состояния":                      "copy in the state directory",
+	"ошибка при чтении выходного файла: %v":           "error reading output file: %v",
+	"ошибка при чтении исходного файла: %v":           "error reading source file: %v",
+	"ошибка при чтении файла с новым обогащением: %v": "error reading the new enrichment file: %v",
+	"укажите один файл: rich merge <файл>":            "specify one file: rich merge <file>",
+
+	// Проверка результатов
+	"Проверено файлов: %d, неактуальных результатов: %d\n": "Files checked: %d, outdated results: %d\n",
+	"неактуальных результатов: %d":                         "outdated results: %d",
+
+	// Создание конфигурации
+	"Директория исходных заметок": "Directory with source notes",
+	"Директория результатов":      "Directory for results",
+	"Задайте ключ API в переменной окружения %s (или сохраните его командой rich secret set)\n": "Set the API key in the %s environment variable (or store it with rich secret set)\n",
+	"Ключ API будет прочитан из переменной окружения %s\n":                                      "The API key will be read from the %s environment variable\n",
+	"Конфигурация записана в %s: %s, модель %s\n":                                               "Configuration written to %s: %s, model %s\n",
+	"Модель (по умолчанию - рекомендуемая для провайдера)":                                      "Model (default: the recommended one for the provider)",
+	"Модель": "Model",
+	"Не задавать вопросов, использовать флаги и значения по умолчанию": "Do not ask questions, use flags and defaults",
+	"Перезаписать существующий файл конфигурации":                      "Overwrite an existing configuration file",
+	"Провайдер":        "Provider",
+	"Провайдер: ":      "Provider: ",
+	"Провайдеры: %s\n": "Providers: %s\n",
+	"Проверка настройки: rich doctor --config %s\n":               "Check the setup: rich doctor --config %s\n",
+	"не указан провайдер: rich init --provider <%s>":              "no provider specified: rich init --provider <%s>",
+	"созданная конфигурация не загружается: %v":                   "the generated configuration does not load: %v",
+	"файл %s уже существует (используйте --force для перезаписи)": "file %s already exists (use --force to overwrite)",
+
+	// Prompt lint
+	"max_prompt_ratio в секции [PROMPT] должен быть положительным: %g": "max_prompt_ratio in the [PROMPT] section must be positive: %g",
+	"Предупреждение: %s":                            "Warning: %s",
+	"в режиме %s параметр incremental не действует": "incremental has no effect in %s mode",
+	"в режиме %s разделы [SECTIONS] не обогащаются": "[SECTIONS] are not enriched in %s mode",
+	"в режиме outline промпт [PROMPT] не используется: модель получает только инструкцию построения оглавления":            "the [PROMPT] prompt is not used in outline mode: the model only receives the outline instruction",
+	"некорректное значение lint %q: ожидалось error, warn или off":                                                         "invalid lint value %q: expected error, warn or off",
+	"проблем промпта: %d (проверка отключается параметром lint = warn или off в секции [PROMPT])":                          "prompt problems: %d (set lint = warn or off in the [PROMPT] section to disable the check)",
+	"промпт %s (~%d токенов) больше %g от max_tokens (%d): уменьшите промпт или увеличьте max_tokens или max_prompt_ratio": "prompt %s (~%d tokens) exceeds %g of max_tokens (%d): shorten the prompt or increase max_tokens or max_prompt_ratio",
+	"промпт %s (~%d токенов) не помещается в контекстное окно модели %s (%d токенов)":                                      "prompt %s (~%d tokens) does not fit the context window of model %s (%d tokens)",
+	"промпт %s содержит неизвестную подстановку %s: поддерживаются %s":                                                     "prompt %s contains unknown placeholder %s: supported are %s",
+
+	// Request preview
+	"# Запрос %d из %d: %s\n":                   "# Request %d of %d: %s\n",
+	"Для %s запросы к модели не отправляются\n": "No model requests are sent for %s\n",
+	"запрос %d (%s): %v":                        "request %d (%s): %v",
+	"обогащение":                                "enrichment",
+	"оглавление":                                "outline",
+	"раздел %d из %d":                           "section %d of %d",
+	"раздел %s":                                 "section %s",
+	"укажите файл: rich preview <файл>":         "specify a file: rich preview <file>",
+	"файл %s не найден":                         "file %s not found",
+	"часть %d из %d":                            "part %d of %d",
+
+	// Offline queue
+	"offline_after в секции [NETWORK] не может быть отрицательным: %d":                                  "offline_after in the [NETWORK] section cannot be negative: %d",
+	"offline_retry в секции [NETWORK] должен быть положительным: %s":                                    "offline_retry in the [NETWORK] section must be positive: %s",
+	"Обработка файлов, отложенных без соединения с API с %s: %d":                                        "Processing files deferred without an API connection since %s: %d",
+	"Отложенные файлы обработаны":                                                                       "Deferred files processed",
+	"Предупреждение: %v; повтор через %s":                                                               "Warning: %v; retrying in %s",
+	"Предупреждение: нет соединения с API после %d ошибок подряд, обработка остановлена":                "Warning: no connection to the API after %d consecutive errors, processing stopped",
+	"не удалось прочитать очередь отложенных файлов: %v":                                                "failed to read the deferred files queue: %v",
+	"не удалось сохранить очередь отложенных файлов: %v":                                                "failed to save the deferred files queue: %v",
+	"не удалось удалить очередь отложенных файлов: %v":                                                  "failed to remove the deferred files queue: %v",
+	"нет соединения с API (%v): отложено файлов %d, они будут обработаны первыми при следующем запуске": "no connection to the API (%v): %d files deferred, they will be processed first on the next run",
+	"поврежден файл очереди отложенных файлов %s: %v":                                                   "the deferred files queue %s is corrupted: %v",
+
+	// API response limits
+	"API вернул страницу HTML вместо JSON (адрес API указывает не на API модели?): %s":                                   "the API returned an HTML page instead of JSON (does the API URL point to the model API?): %s",
+	"max_response_size в секции [NETWORK] должен быть положительным: %d":                                                 "max_response_size in the [NETWORK] section must be positive: %d",
+	"ответ API больше %d байт (Content-Type %s): проверьте адрес API или увеличьте max_response_size в секции [NETWORK]": "the API response exceeds %d bytes (Content-Type %s): check the API URL or increase max_response_size in the [NETWORK] section",
+	"ответ API не в формате JSON (Content-Type %s): %s":                                                                  "the API response is not JSON (Content-Type %s): %s",
+
+	// Compression
+	"некорректное значение compress_requests %q: ожидалось auto, on или off": "invalid compress_requests value %q: expected auto, on or off",
+	"неподдерживаемое сжатие ответа API: %s":                                 "unsupported API response encoding: %s",
+	"ошибка при сжатии запроса: %v":                                          "failed to compress the request: %v",
+	"ошибка распаковки ответа API (%s): %v":                                  "failed to decompress the API response (%s): %v",
+
+	// Heartbeat
+	"heartbeat в секции [INTERFACE] не может быть отрицательным: %s": "heartbeat in the [INTERFACE] section cannot be negative: %s",
+	"Ожидание ответа API для %s: %s":                                 "Still waiting for the API response for %s: %s elapsed",
+	"Ожидание ответа API: %s":                                        "Still waiting for the API response: %s elapsed",
+
+	// Provider responses
+	"модель вернула вызов инструмента вместо текста ответа": "the model returned a tool call instead of a text response",
+
+	// Output sinks
+	"--transactional работает только с приемником local в секции [OUTPUT]":      "--transactional works only with the local sink in the [OUTPUT] section",
+	"Обогащенное содержимое %s передано приемникам результатов":                 "Enriched content of %s passed to the output sinks",
+	"Предупреждение: приемник результатов %s: %v":                               "Warning: output sink %s: %v",
+	"Приемник git: закоммичено файлов %d в %s":                                  "git sink: committed %d files in %s",
+	"Приемник git: изменений в %s нет, коммит не создан":                        "git sink: no changes in %s, no commit created",
+	"Приемник git: коммит отправлен в удаленный репозиторий":                    "git sink: commit pushed to the remote repository",
+	"Приемник s3: загружен объект %s":                                           "s3 sink: uploaded object %s",
+	"в секции [OUTPUT] не задано ни одного приемника результатов":               "no output sinks are set in the [OUTPUT] section",
+	"выходная директория не находится в репозитории git: %v":                    "the output directory is not inside a git repository: %v",
+	"для приемника s3 задайте s3_bucket в секции [OUTPUT]":                      "for the s3 sink set s3_bucket in the [OUTPUT] section",
+	"не удалось передать %s приемникам результатов: %s":                         "failed to pass %s to the output sinks: %s",
+	"ошибка загрузки %s: %w":                                                    "failed to upload %s: %w",
+	"приемник git коммитит файлы выходной директории и требует приемника local": "the git sink commits files of the output directory and requires the local sink",
+	"приемник git: программа git не найдена: %v":                                "git sink: git executable not found: %v",
+	"git %s: %v: %s": "git %s: %v: %s",
+	"git %s: %v":     "git %s: %v",
+
+	// S3 storage
+	"для S3 задайте access_key и secret_key в секции [S3] или переменные AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY": "for S3 set access_key and secret_key in the [S3] section or the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables",
+	"некорректный адрес endpoint %q в секции [S3]":                                                                  "invalid endpoint address %q in the [S3] section",
+	"некорректный список объектов %s: %v":                                                                           "invalid object listing of %s: %v",
+	"объект %s больше %d байт":                                                                                      "object %s is larger than %d bytes",
+	"ошибка в параметре secret_key секции [S3]: %v":                                                                 "invalid secret_key parameter in the [S3] section: %v",
+	"ошибка получения списка объектов %s: %w":                                                                       "failed to list objects of %s: %w",
+	"ошибка чтения %s: %w":                                                                                          "failed to read %s: %w",
+	"хранилище вернуло статус %d: %s":                                                                               "the storage returned status %d: %s",
+
+	// Input sources
+	"Источник s3: получено объектов %d из %s":                            "s3 source: fetched %d objects from %s",
+	"Предупреждение: не удалось удалить каталог файлов источника %s: %v": "Warning: failed to remove the source files directory %s: %v",
+	"Файлы источника %s выложены в %s":                                   "Files of source %s placed in %s",
+	"в источнике %q не указан бакет: ожидалось s3://<бакет>/<префикс>":   "source %q has no bucket: expected s3://<bucket>/<prefix>",
+	"источник %s: %w": "source %s: %w",
+	"не удалось создать каталог для файлов источника: %v": "failed to create a directory for the source files: %v",
+	"некорректный архив %s: %v":                           "invalid archive %s: %v",
+	"некорректный архив tar: %v":                          "invalid tar archive: %v",
+	"некорректный источник входных файлов %q: ожидалось archive:<путь>, git:<репозиторий>#<ref>, s3://<бакет>/<префикс> или stdin": "invalid input source %q: expected archive:<path>, git:<repository>#<ref>, s3://<bucket>/<prefix> or stdin",
+	"неподдерживаемый формат архива %s: поддерживаются zip, tar, tar.gz":                                                           "unsupported archive format %s: zip, tar, tar.gz are supported",
+	"ошибка чтения %s из архива: %v":       "failed to read %s from the archive: %v",
+	"ошибка чтения стандартного ввода: %v": "failed to read standard input: %v",
+	"программа git не найдена: %v":         "git executable not found: %v",
+	"стандартный ввод пуст":                "standard input is empty",
+	"файлы источника больше %d байт":       "source files exceed %d bytes",
+	"git archive %s: %v: %s":               "git archive %s: %v: %s",
+	"git archive %s: %v":                   "git archive %s: %v",
+
+	// Route output templates
+	"Результат %s сохраняется по шаблону маршрута %s: %s":       "Result %s is saved by the template of route %s: %s",
+	"некорректный шаблон output маршрута %s: %v":                "invalid output template of route %s: %v",
+	"ошибка выходной директории: %v":                            "output directory error: %v",
+	"ошибка шаблона output маршрута %s: %v":                     "output template error in route %s: %v",
+	"шаблон output маршрута %s дал недопустимый путь %q для %s": "output template of route %s produced an invalid path %q for %s",
+
+	// Daily notes
+	"%s: заметки не изменились, обзор пропущен\n":                                              "%s: notes unchanged, rollup skipped\n",
+	"%s: обзор недели по заметкам %d\n":                                                        "%s: week in review from %d notes\n",
+	"context_days в секции [DAILY_NOTES] не может быть отрицательным: %d":                      "context_days in section [DAILY_NOTES] cannot be negative: %d",
+	"context_words в секции [DAILY_NOTES] должен быть положительным: %d":                       "context_words in section [DAILY_NOTES] must be positive: %d",
+	"rollup_dir в секции [DAILY_NOTES] должен быть директорией внутри выходной директории: %q": "rollup_dir in section [DAILY_NOTES] must be a directory inside the output directory: %q",
+	"День недели обзора в формате ГГГГ-ММ-ДД (по умолчанию - прошедшая неделя)":                "A day of the rollup week in YYYY-MM-DD format (default: the past week)",
+	"Дневниковая заметка %s за %s: заметок предыдущих дней в контексте %d":                     "Daily note %s for %s: notes from previous days in context: %d",
+	"Дневниковых заметок: %d":                                                                  "Daily notes: %d",
+	"Обзор недели %d-W%02d":                                                         "Week in review %d-W%02d",
+	"Обзор недели записан в %s (заметок: %d)":                                       "Week in review written to %s (notes: %d)",
+	"Ошибка обзора недели: %v\n":                                                    "Week in review error: %v\n",
+	"Подготовить обзор заново, даже если заметки не изменились":                     "Rebuild the rollup even if the notes have not changed",
+	"Предупреждение: заметка %s не включена в обзор недели по политике содержимого": "Warning: note %s is excluded from the week in review by the content policy",
+	"Предупреждение: не удалось подготовить обзор недели: %v":                       "Warning: failed to prepare the week in review: %v",
+	"дневниковые заметки выключены: задайте enabled = true в секции [DAILY_NOTES]":  "daily notes are disabled: set enabled = true in section [DAILY_NOTES]",
+	"за неделю %s - %s нет дневниковых заметок":                                     "no daily notes for the week %s - %s",
+	"не удалось подготовить обзоров недели: %d":                                     "failed to prepare week reviews: %d",
+	"некорректная дата %q: ожидался формат ГГГГ-ММ-ДД":                              "invalid date %q: expected YYYY-MM-DD",
+	"некорректный шаблон пути %q в секции [DAILY_NOTES]":                            "invalid path pattern %q in section [DAILY_NOTES]",
+	"ошибка при индексации дневниковых заметок: %v":                                 "failed to index daily notes: %v",
+
+	// Потоковые ответы, Ollama и Gemini
+	"некорректный формат ответа Ollama: отсутствует поле message": "invalid Ollama response format: the message field is missing",
+	"нет данных от API дольше %v":                                 "no data from the API for longer than %v",
+	"ошибка Ollama: %s":                            "Ollama error: %s",
+	"ошибка в потоке API: %s":                      "error in the API stream: %s",
+	"ошибка в потоке Gemini: %s":                   "error in the Gemini stream: %s",
+	"ошибка при разборе события потока Gemini: %v": "error parsing a Gemini stream event: %v",
+	"ошибка при разборе строки потока Ollama: %v":  "error parsing an Ollama stream line: %v",
+	"ошибка при разборе фрагмента потока API: %v":  "error parsing an API stream chunk: %v",
+	"ошибка при чтении потока API: %w":             "error reading the API stream: %w",
+	"ошибка при чтении потока Anthropic API: %w":   "error reading the Anthropic API stream: %w",
+	"ошибка при чтении потока Gemini: %w":          "error reading the Gemini stream: %w",
+	"ошибка при чтении потока Ollama: %w":          "error reading the Ollama stream: %w",
+	"поток API прерван до завершения ответа":       "the API stream was interrupted before the response was complete",
+	"поток Gemini не содержит событий":             "the Gemini stream contains no events",
+	"поток Ollama прерван до строки с done":        "the Ollama stream was interrupted before the done line",
+
+	// Параллельная обработка, повторы и продолжение запуска
+	"--resume требует каталога состояния: задайте dir в секции [STATE]":                            "--resume requires a state directory: set dir in the [STATE] section",
+	"retries в секции [NETWORK] не может быть отрицательным: %d":                                   "retries in the [NETWORK] section cannot be negative: %d",
+	"retry_delay в секции [NETWORK] должен быть положительным и не больше retry_max_delay: %s, %s": "retry_delay in the [NETWORK] section must be positive and not greater than retry_max_delay: %s, %s",
+	"workers должен быть от 1 до %d: %d":                                                           "workers must be between 1 and %d: %d",
+	"Временная ошибка API, повтор %d из %d через %v: %v":                                           "Transient API error, retry %d of %d in %v: %v",
+	"Пропуск файла %s: обработан до прерывания запуска":                                            "Skipping file %s: processed before the run was interrupted",
+	"Пропущено файлов, обработанных до прерывания запуска: %d":                                     "Files skipped as processed before the run was interrupted: %d",
+	"не удалось прочитать файл состояния: %v":                                                      "failed to read the state file: %v",
+	"не удалось сохранить файл состояния: %v":                                                      "failed to save the state file: %v",
+	"поврежден файл состояния %s: %v":                                                              "the state file %s is corrupted: %v",
+	"Продолжить прерванный запуск: пропустить файлы, уже обработанные по файлу состояния":          "Resume an interrupted run: skip files already processed according to the state file",
+	"Файлов, обрабатываемых одновременно (0 - workers из секции [PROCESSING])":                     "Files processed concurrently (0 - workers from the [PROCESSING] section)",
+	"Ошибка в параметре -workers: %v":                                                              "Error in the -workers parameter: %v",
+
+	// Статусы frontmatter
+	"статус %q в секции [WORKFLOW] указан и в ready, и в skip или done": "status %q in the [WORKFLOW] section is listed both in ready and in skip or done",
+	"Пропуск файла %s (skipped: status): %s: %q":                        "Skipping file %s (skipped: status): %s: %q",
+
+	// Статистика по директориям
+	"не удалось сохранить статистику директорий: %v":                             "failed to save directory statistics: %v",
+	"поврежден файл статистики %s: %v":                                           "statistics file %s is corrupted: %v",
+	"Статистика по директориям":                                                  "Statistics by directory",
+	"Группировать директории по первым N компонентам пути (0 - без группировки)": "Group directories by the first N path components (0 - no grouping)",
+	"Порядок директорий: cost, tokens, files или failures":                       "Directory order: cost, tokens, files or failures",
+	"некорректный порядок %q: ожидалось cost, tokens, files или failures":        "invalid order %q: expected cost, tokens, files or failures",
+	"--depth не может быть отрицательным: %d":                                    "--depth cannot be negative: %d",
+	"не удалось прочитать статистику директорий: %v":                             "failed to read directory statistics: %v",
+	"Обработок файлов: %d (обогащено %d, пропущено %d, с ошибкой %d)\n":          "File runs: %d (enriched %d, skipped %d, failed %d)\n",
+	"Доля ошибок: %.1f%%\n":                                                      "Failure rate: %.1f%%\n",
+	"Статистика пока не накоплена":                                               "No statistics collected yet",
+	"ДИРЕКТОРИЯ\tФАЙЛЫ\tОШИБКИ\tТОКЕНЫ\tСТОИМОСТЬ\tЗАПУСКИ":                      "DIRECTORY\tFILES\tFAILURES\tTOKENS\tCOST\tRUNS",
+
+	// Режим наблюдения
+	"--watch работает только с входной директорией input_dir, а не с input_source":                                 "--watch works only with the input_dir input directory, not with input_source",
+	"Наблюдать за входными директориями и обрабатывать новые и измененные файлы по мере появления":                 "Watch the input directories and process new and modified files as they appear",
+	"Наблюдение за входными директориями: проверка каждые %s, обработка через %s после последнего изменения файла": "Watching the input directories: checking every %s, processing %s after the last file change",
+	"Наблюдение остановлено":                                                    "Watching stopped",
+	"Новые и измененные файлы: %d":                                              "New and modified files: %d",
+	"Ошибка режима наблюдения: %v":                                              "Watch mode error: %v",
+	"некорректные параметры наблюдения: -watch-debounce %s, -watch-interval %s": "invalid watch parameters: -watch-debounce %s, -watch-interval %s",
+	"ошибка при обходе директории %s: %v":                                       "error walking directory %s: %v",
+
+	// Политика on_error
+	"Ошибка в параметре -on-error: %v": "Invalid -on-error parameter: %v",
+	"Реакция на ошибки файлов: continue, fail-fast или fail-after N (пусто - on_error из секции [PROCESSING])": "Reaction to file errors: continue, fail-fast or fail-after N (empty - on_error from the [PROCESSING] section)",
+	"некорректное значение on_error %q: ожидалось continue, fail-fast или fail-after N":                        "invalid on_error value %q: expected continue, fail-fast or fail-after N",
+	"некорректное число ошибок в on_error %q: ожидалось целое число от 1":                                      "invalid error count in on_error %q: expected an integer of at least 1",
+	"ошибка обработки файла, категория %s (on_error = %s)":                                                     "file processing error, category %s (on_error = %s)",
+	"системная ошибка категории %s (on_error = %s)":                                                            "systemic error of category %s (on_error = %s)",
+	"файлов с ошибкой: %d (on_error = %s)":                                                                     "files failed: %d (on_error = %s)",
+
+	// Части документа
+	"max_chunk_tokens не может быть отрицательным: %d": "max_chunk_tokens cannot be negative: %d",
+	"Всего\t%d\t~%d\n": "Total\t%d\t~%d\n",
+	"Только число запросов и оценка входных токенов, без тел запросов": "Only the request count and input token estimate, without request bodies",
+	"ФАЙЛ\tЗАПРОСЫ\tТОКЕНЫ": "FILE\tREQUESTS\tTOKENS",
+
+	// Системные ошибки
+	"systemic_after не может быть отрицательным: %d":                                      "systemic_after cannot be negative: %d",
+	"провайдер настроен неверно: первые %d файлов завершились системной ошибкой (%s): %v": "provider misconfigured: the first %d files failed with a systemic error (%s): %v",
+
+	// Проверочный файл
+	"Запуск отменен": "Run canceled",
+	"Запуск отменен; результат проверочного файла можно отменить: rich undo --run %s": "Run canceled; the canary file result can be reverted: rich undo --run %s",
+	"Обработать остальные файлы? [y/N]: ":                                             "Process the remaining files? [y/N]: ",
+	"Ошибка проверочного файла: %v":                                                   "Canary file error: %v",
+	"Проверочный файл: %s":                                                            "Canary file: %s",
+	"Продолжить запуск после проверочного файла без подтверждения":                    "Continue the run after the canary file without confirmation",
+	"Результат проверочного файла %s (%s):":                                           "Canary file result %s (%s):",
+	"Сначала обогатить этот файл и продолжить после проверки результата (переопределяет file секции [CANARY])": "Enrich this file first and continue after the result is checked (overrides file from the [CANARY] section)",
+	"подтвердите запуск после проверочного файла параметром -yes или включите auto в секции [CANARY]":          "confirm the run after the canary file with -yes or enable auto in the [CANARY] section",
+	"проверочный файл %s должен находиться во входной директории":                                              "canary file %s must be inside the input directory",
+	"проверочный файл %s не обогащен (%s), выберите другой файл":                                               "canary file %s was not enriched (%s), choose another file",
+	"проверочный файл %s не обогащен: %s":                                                                      "canary file %s was not enriched: %s",
+	"проверочный файл %s не обогащен: %v":                                                                      "canary file %s was not enriched: %v",
+	"проверочный файл: %v": "canary file: %v",
+
+	// Выборка
+	"-sample работает только с входной директорией input_dir, а не с input_source":                   "-sample works only with the input_dir input directory, not with input_source",
+	"Выборка (seed %d): %d файлов, результаты в %s":                                                  "Sample (seed %d): %d files, results in %s",
+	"Директория результатов выборки (по умолчанию sample-<время>)":                                   "Sample results directory (default sample-<time>)",
+	"Начальное значение случайной выборки -sample":                                                   "Random seed for the -sample selection",
+	"Необработанных файлов для выборки нет":                                                          "No pending files to sample",
+	"Обработать случайную выборку из N необработанных файлов в отдельную директорию (0 - все файлы)": "Process a random sample of N pending files into a separate directory (0 - all files)",
+	"Ошибка в параметре -sample: %v":                                                                 "Invalid -sample parameter: %v",
+	"Ошибка обработки выборки: %v":                                                                   "Sample processing error: %v",
+	"Файл выборки: %s": "Sample file: %s",
+	"не удалось создать временную директорию: %v":    "failed to create a temporary directory: %v",
+	"размер выборки не может быть отрицательным: %d": "sample size cannot be negative: %d",
+
+	// Выходная директория
+	"неизвестное значение output_layout: %s (ожидается flat или per-run)": "unknown output_layout value: %s (expected flat or per-run)",
+
+	// Удаленные входные файлы
+	"некорректное значение on_delete %q: ожидалось %s, %s или %s":   "invalid on_delete value %q: expected %s, %s or %s",
+	"для on_delete = trash задайте trash_dir или каталог состояния": "on_delete = trash requires trash_dir or a state directory",
+	"Результат удаленного файла %s перенесен в корзину: %s":         "Output of deleted file %s moved to trash: %s",
+	"Предупреждение: результат удаленного файла %s: %v":             "Warning: output of deleted file %s: %v",
+	"Входной файл удален: %s":                                       "Input file deleted: %s",
+	"Удаленные файлы: %d":                                           "Deleted files: %d",
+	"Ошибка обработки удаленных файлов: %v":                         "Error handling deleted files: %v",
+
+	// Результаты без исходных файлов
+	"Перенести результаты в корзину и убрать записи состояния":                          "Move outputs to trash and remove state entries",
+	"rich orphans работает только с входной директорией input_dir, а не с input_source": "rich orphans only works with input_dir, not input_source",
+	"Результатов и записей состояния без исходных файлов нет":                           "No outputs or state entries without source files",
+	"Результаты без исходных файлов: %d\n":                                              "Outputs without source files: %d\n",
+	"Записи файла состояния удаленных файлов: %d\n":                                     "State file entries of deleted files: %d\n",
+	"Записи excluded_files удаленных файлов: %d\n":                                      "excluded_files entries of deleted files: %d\n",
+	"Для очистки запустите с --prune":                                                   "Run with --prune to clean up",
+	"Результаты перенесены в корзину: %s\n":                                             "Outputs moved to trash: %s\n",
+	"Очистка завершена":                                                                 "Cleanup finished",
+	"для переноса результатов в корзину задайте trash_dir или каталог состояния":        "set trash_dir or a state directory to move outputs to trash",
+	"не удалось перенести %s в корзину: %v":                                             "failed to move %s to trash: %v",
+
+	// Контрольные суммы
+	"не удалось вычислить контрольные суммы %s: %v":                                "failed to compute checksums of %s: %v",
+	"не удалось сохранить контрольные суммы: %v":                                   "failed to save checksums: %v",
+	"файл контрольных сумм должен задаваться путем внутри выходной директории: %s": "the checksum file must be a path inside the output directory: %s",
+
+	// Сброс на диск
+	"не удалось сбросить временный файл на диск: %v": "failed to flush the temporary file to disk: %v",
+
+	// Части и слияние состояния
+	"некорректная часть %q: ожидается K/N, где 1 <= K <= N":                                       "invalid shard %q: expected K/N with 1 <= K <= N",
+	"Обрабатывать только часть K из N файлов (например, 2/5) для обработки на нескольких машинах": "Process only part K of N of the files (e.g. 2/5) to split work across machines",
+	"Ошибка в параметре -shard: %v":                                                               "Invalid -shard parameter: %v",
+	"Часть %s: обрабатываются только файлы этой части":                                            "Shard %s: processing only files of this shard",
+	"не удалось прочитать журналы запусков %s: %v":                                                "failed to read run journals in %s: %v",
+	"не удалось скопировать журнал запуска %s: %v":                                                "failed to copy run journal %s: %v",
+	"укажите файлы конфигурации машин, состояние которых нужно объединить":                        "specify the configuration files of the machines whose state should be merged",
+	"Добавлено в excluded_files: %d, записей файла состояния: %d, журналов запусков: %d\n":        "Added to excluded_files: %d, state file entries: %d, run journals: %d\n",
+
+	// Перенос состояния
+	"не удалось прочитать выгрузку состояния: %v":                                                              "failed to read the state export: %v",
+	"некорректная выгрузка состояния %s: %v":                                                                   "invalid state export %s: %v",
+	"неподдерживаемая версия выгрузки состояния %s: %d":                                                        "unsupported state export version in %s: %d",
+	"некорректное значение --prefer %q: ожидалось %s, %s или %s":                                               "invalid --prefer value %q: expected %s, %s or %s",
+	"укажите команду: rich state export, import, merge или migrate":                                            "specify a command: rich state export, import, merge or migrate",
+	"Файл выгрузки (по умолчанию стандартный вывод)":                                                           "Export file (standard output by default)",
+	"не удалось подготовить выгрузку состояния: %v":                                                            "failed to prepare the state export: %v",
+	"Состояние выгружено в %s: %d файлов в excluded_files, %d записей файла состояния, %d журналов запусков\n": "State exported to %s: %d files in excluded_files, %d state file entries, %d run journals\n",
+	"Запись о файле, который есть в обоих состояниях: newer, ours или theirs":                                  "Entry to keep for files present in both states: newer, ours or theirs",
+	"укажите файлы выгрузки состояния":                                                                         "specify state export files",
+	"Записано в файл состояния: %d\n":                                                                          "Written to the state file: %d\n",
+	"  пропущен %s: входного файла нет\n":                                                                      "  skipped %s: input file not found\n",
+
+	// Перенос состояния
+	"для rich state migrate задайте каталог состояния [STATE] dir": "rich state migrate requires a state directory: set [STATE] dir",
+
+	// Сжатые заметки
+	"не удалось распаковать %s: %v":     "failed to decompress %s: %v",
+	"ошибка сжатия результата %s: %v":   "failed to compress the result %s: %v",
+	"распакованный файл больше %d байт": "decompressed file exceeds %d bytes",
+
+	// Пробный запуск
+	"-dry-run работает только с входной директорией input_dir, а не с input_source": "-dry-run works only with the input_dir input directory, not with input_source",
+	"Файлы для обработки: %d\n":                                                 "Files to process: %d\n",
+	"Запросы к API не отправлялись, файлы не изменены":                          "No API requests were sent, no files were changed",
+	"Файлов с результатами для сравнения нет":                                   "No files with results to compare",
+	"Сравнение с текущими результатами: %d файлов":                              "Comparing with current results: %d files",
+	"%s: новое обогащение не получено\n":                                        "%s: no new enrichment received\n",
+	"%s: без изменений\n":                                                       "%s: unchanged\n",
+	"не удалось сохранить сравнение %s: %v":                                     "failed to save the diff %s: %v",
+	"Изменится результатов: %d, без изменений: %d, без нового обогащения: %d\n": "Results to change: %d, unchanged: %d, without new enrichment: %d\n",
+	"Сравнения сохранены в %s\n":                                                "Diffs saved to %s\n",
+	"Файлы не изменены":                                                         "No files were changed",
+	"Только показать файлы для обработки, запросы к API не отправляются":        "Only list files to process, no API requests are sent",
+	"С -dry-run: обогатить заново файлы с результатами и показать отличия от текущих результатов": "With -dry-run: re-enrich files that have results and show differences from current results",
+	"С -dry-run -diff: сохранить сравнения в директорию":                                          "With -dry-run -diff: save diffs to a directory",
+	"Ошибка в параметре -diff: %v":                                                                "Invalid -diff parameter: %v",
+	"-diff и -diff-dir используются вместе с -dry-run":                                            "-diff and -diff-dir require -dry-run",
+	"Ошибка в параметре -dry-run: %v":                                                             "Invalid -dry-run parameter: %v",
+	"-dry-run нельзя совмещать с -sample и -watch":                                                "-dry-run cannot be combined with -sample or -watch",
+	"Ошибка пробного запуска: %v":                                                                 "Dry run failed: %v",
+	"не удалось создать директорию %s: %v":                                                        "failed to create directory %s: %v",
+
+	// Суточный лимит
+	"Предупреждение: некорректный файл расхода за сутки %s: %v":                           "Warning: invalid daily usage file %s: %v",
+	"Предупреждение: не удалось сохранить расход за сутки: %v":                            "Warning: failed to save daily usage: %v",
+	"Предупреждение: не удалось прочитать расход за сутки: %v":                            "Warning: failed to read daily usage: %v",
+	"достигнут суточный лимит запросов к API (%d из %d за 24 часа), продолжение после %s": "daily API request limit reached (%d of %d in 24 hours), resuming after %s",
+	"достигнут суточный лимит токенов (%d из %d за 24 часа), продолжение после %s":        "daily token limit reached (%d of %d in 24 hours), resuming after %s",
+	"daily_max_requests и daily_max_tokens не могут быть отрицательными: %d, %d":          "daily_max_requests and daily_max_tokens cannot be negative: %d, %d",
+	"Обработка приостановлена: %s; файлы в очереди: %d":                                   "Processing paused: %s; files in queue: %d",
+	"Суточный лимит освободился, обработка продолжается":                                  "Daily limit freed up, processing resumes",
+
+	// Наблюдение
+	"некорректный шаблон в sync_temp_patterns секции [WATCH]: %q (ожидалось имя файла с {name})":              "invalid pattern in sync_temp_patterns of the [WATCH] section: %q (expected a file name with {name})",
+	"Файл %s еще синхронизируется (%s), обработка отложена":                                                   "File %s is still being synced (%s), processing deferred",
+	"Время без изменений файла перед обработкой в режиме наблюдения (переопределяет debounce секции [WATCH])": "Time a file must stay unchanged before processing in watch mode (overrides debounce in the [WATCH] section)",
+	"Интервал проверки входных директорий в режиме наблюдения (переопределяет interval секции [WATCH])":       "Input directory polling interval in watch mode (overrides interval in the [WATCH] section)",
+
+	// Конфликтные копии синхронизации
+	"Заметка %s объединяется с конфликтными копиями: %d":                                    "Merging note %s with sync conflict copies: %d",
+	"Конфликтная копия %s запрещена политикой содержимого и не объединяется":                "Conflict copy %s is blocked by the content policy and is not merged",
+	"Предупреждение: конфликтные копии %s не объединяются: документ обогащается не целиком": "Warning: conflict copies of %s are not merged: the document is not enriched as a whole",
+	"Предупреждение: не удалось прочитать конфликтную копию %s: %v":                         "Warning: failed to read conflict copy %s: %v",
+	"Пропуск конфликтной копии: %s":                                                         "Skipping conflict copy: %s",
+	"некорректное значение conflict_copies %q: ожидалось %s или %s":                         "invalid conflict_copies value %q: expected %s or %s",
+
+	// Повторное обогащение раздела
+	"%q не является заголовком markdown (например \"## Установка\")":                             "%q is not a markdown heading (for example \"## Installation\")",
+	"%s не является результатом обогащения: файла нет в журнале запусков и выходных директориях": "%s is not an enrichment result: the file is not in the run journals or output directories",
+	"Раздел %q не найден во входном файле %s, обогащается текст результата":                      "Section %q not found in input file %s, enriching the result text",
+	"Раздел %s (источник: %s):":                                                   "Section %s (source: %s):",
+	"Раздел обновлен в %s\n":                                                      "Section updated in %s\n",
+	"Раздел обновлен в %s (rich undo --run %s)\n":                                 "Section updated in %s (rich undo --run %s)\n",
+	"Только показать новый раздел, файлы не изменяются":                           "Only show the new section, files are not changed",
+	"входной файл":                                                                "input file",
+	"заголовок %q встречается в документе %d раз":                                 "heading %q occurs %d times in the document",
+	"раздел %q не найден или пуст":                                                "section %q not found or empty",
+	"текущий текст результата":                                                    "current result text",
+	"укажите результат и заголовок раздела: rich section <файл> \"## Заголовок\"": "specify the result and the section heading: rich section <file> \"## Heading\"",
+
+	// Эксперимент с промптами
+	"Вариант": "Variant",
+	"Вариант промпта для %s: %s":                             "Prompt variant for %s: %s",
+	"Для сравнения нужны оценки результатов обоих вариантов": "Comparison needs labeled results of both variants",
+	"Нет результатов экспериментов с промптами":              "No prompt experiment results",
+	"Отклонено": "Rejected",
+	"Оценено":   "Labeled",
+	"Пакетная обработка отключена: несовместима с экспериментом с промптами": "Batch processing disabled: incompatible with the prompt experiment",
+	"Принято":    "Accepted",
+	"Принято, %": "Accepted, %",
+	"Разница B-A: %+.1f п.п., p = %.3f: вариант A лучше":                          "Difference B-A: %+.1f pp, p = %.3f: variant A is better",
+	"Разница B-A: %+.1f п.п., p = %.3f: вариант B лучше":                          "Difference B-A: %+.1f pp, p = %.3f: variant B is better",
+	"Разница B-A: %+.1f п.п., p = %.3f: различие не значимо, нужно больше оценок": "Difference B-A: %+.1f pp, p = %.3f: not significant, more labels needed",
+	"Результатов":                  "Outputs",
+	"Только указанный эксперимент": "Only the specified experiment",
+	"Эксперимент %s":               "Experiment %s",
+
+	// Повторный запрос с исправлением
+	"Предупреждение: ошибка при повторном обогащении %s: %v":                           "Warning: error while re-enriching %s: %v",
+	"Результат обогащения %s исправлен повторным запросом":                             "Enrichment result for %s fixed by a repeated request",
+	"Результат обогащения %s не прошел проверку (%s), повторный запрос с исправлением": "Enrichment result for %s failed the check (%s), retrying with a correction",
+	"в результате потеряны или изменены блоки кода оригинала: %d":                      "code blocks of the original lost or changed in the result: %d",
+
+	// Двоичные файлы
+	"нулевой байт в позиции %d (%s)":                 "null byte at offset %d (%s)",
+	"управляющих символов %d из %d байт (%s)":        "%d control characters in %d bytes (%s)",
+	"файл %s не похож на текстовый: %s":              "file %s does not look like text: %s",
+	"файл в кодировке UTF-16, сохраните его в UTF-8": "the file is encoded in UTF-16, save it as UTF-8",
+
+	// Части результата
+	"max_output_size должен быть 0 или не меньше %d: %d":                 "max_output_size must be 0 or at least %d: %d",
+	"Предупреждение: не удалось удалить прежнюю часть результата %s: %v": "Warning: failed to remove stale result part %s: %v",
+	"Результат %s больше %d байт и записывается частями: %d":             "Result %s is larger than %d bytes and is written in parts: %d",
+	"Удалена прежняя часть результата %s":                                "Removed stale result part %s",
+	"ошибка при записи части результата: %v":                             "error writing result part: %v",
+
+	// Типографика
+	"некорректное значение quotes %q в секции [%s]: ожидалось true, false или четыре символа кавычек, например «»„“": "invalid quotes value %q in section [%s]: expected true, false or four quote characters, e.g. “”‘’",
+
+	// Проверка орфографии
+	"%s -> %s (исправлено)":  "%s -> %s (fixed)",
+	"%s не завершился за %v": "%s did not finish within %v",
+	"timeout в секции [SPELLCHECK] должен быть положительным: %v": "timeout in section [SPELLCHECK] must be positive: %v",
+	"Орфография %s:%d: %s": "Spelling %s:%d: %s",
+	"Предупреждение: не удалось проверить орфографию %s: %v":                                     "Warning: failed to spellcheck %s: %v",
+	"Предупреждение: опечатки в %s: %d":                                                          "Warning: misspellings in %s: %d",
+	"Предупреждение: программа %s не найдена, проверка орфографии выключена":                     "Warning: program %s not found, spellchecking is disabled",
+	"некорректное значение action %q в секции [SPELLCHECK]: ожидалось %s или %s":                 "invalid action value %q in section [SPELLCHECK]: expected %s or %s",
+	"некорректный словарь %q в секции [SPELLCHECK]: ожидалось язык: словарь, например ru: ru_RU": "invalid dictionary %q in section [SPELLCHECK]: expected language: dictionary, e.g. ru: ru_RU",
+	"ошибка %s: %v: %s": "%s error: %v: %s",
+	"установите hunspell и словари языков или укажите путь ключом binary секции [SPELLCHECK]": "install hunspell and the language dictionaries or set its path with the binary key of the [SPELLCHECK] section",
+
+	// Размер запроса
+	"max_request_size в секции [NETWORK] не может быть отрицательным: %d":                                                                                                     "max_request_size in the [NETWORK] section cannot be negative: %d",
+	"тело запроса (%d байт) больше max_request_size в секции [NETWORK] (%d байт): уменьшите документ или изображение либо увеличьте ограничение":                              "the request body (%d bytes) exceeds max_request_size in the [NETWORK] section (%d bytes): reduce the document or image or raise the limit",
+	"запрос (~%d токенов) не помещается в контекстное окно модели %s (%d токенов): для ответа остается %d токенов из необходимых %d, сократите запрос примерно на %d токенов": "request (~%d tokens) does not fit the context window of model %s (%d tokens): %d tokens are left for the response out of the required %d, shorten the request by about %d tokens",
+	"max_tokens уменьшен с %d до %d: запрос ~%d токенов в окне %d токенов модели %s":                                                                                          "max_tokens reduced from %d to %d: request of ~%d tokens in the %d-token window of model %s",
+
+	// Приемник command
+	"неизвестный приемник результатов %q в секции [OUTPUT]: поддерживаются local, stdout, git, s3, command": "unknown output sink %q in the [OUTPUT] section: supported are local, stdout, git, s3, command",
+	"для приемника command задайте command в секции [OUTPUT]":                                               "set command in the [OUTPUT] section for the command sink",
+	"command_timeout в секции [OUTPUT] должен быть положительным: %v":                                       "command_timeout in the [OUTPUT] section must be positive: %v",
+	"команда %s не завершилась за %v":                                                                       "command %s did not finish within %v",
+	"Приемник command: результат %s передан команде %s":                                                     "Command sink: result %s passed to command %s",
+
+	// Манифест запуска
+	"Манифест запуска сохранен в %s":              "Run manifest saved to %s",
+	"ошибка при записи манифеста запуска: %v":     "error writing the run manifest: %v",
+	"ошибка при подготовке манифеста запуска: %v": "error preparing the run manifest: %v",
+
+	// Операторы запусков
+	", оператор %s": ", operator %s",
+	"Пропуск файла %s (skipped: in review): новое обогащение %s ждет проверки оператором %s": "Skipping file %s (skipped: in review): new enrichment %s is awaiting review by operator %s",
+
+	// Очередь проверки
+	"%s не одобрен: %s\n":                                  "%s not approved: %s\n",
+	"%s нет в очереди проверки":                            "%s is not in the review queue",
+	"%s одобрен: %s\n":                                     "%s approved: %s\n",
+	"review_dir несовместим с output_layout = per-run":     "review_dir cannot be combined with output_layout = per-run",
+	"Одобрено результатов: %d (rich undo --run %s)\n":      "Results approved: %d (rich undo --run %s)\n",
+	"Одобрить все результаты в очереди проверки":           "Approve all results in the review queue",
+	"Одобрить результаты, не прошедшие итоговую проверку":  "Approve results that fail the final check",
+	"Очередь проверки пуста":                               "The review queue is empty",
+	"Предупреждение: не удалось перенести карточки %s: %v": "Warning: failed to move flashcards %s: %v",
+	"Результатов на проверке: %d\n":                        "Results awaiting review: %d\n",
+	"в результате остались маркеры конфликтов слияния":     "merge conflict markers are left in the result",
+	"директория проверки %s и выходная директория %s не должны быть вложены друг в друга": "review directory %s and output directory %s must not be nested in each other",
+	"исходный файл изменен после обогащения":                                              "the source file changed after enrichment",
+	"исходный файл удален после обогащения":                                               "the source file was deleted after enrichment",
+	"не одобрено результатов: %d (--force одобряет без итоговой проверки)":                "results not approved: %d (--force approves without the final check)",
+	"не удалось вернуть %s в очередь проверки: %v":                                        "failed to return %s to the review queue: %v",
+	"не удалось перенести %s: %v":                                                         "failed to move %s: %v",
+	"не удалось перенести часть результата %s: %v":                                        "failed to move result part %s: %v",
+	"не удалось прочитать исходный файл: %v":                                              "failed to read the source file: %v",
+	"не удалось создать директорию проверки: %v":                                          "failed to create the review directory: %v",
+	"небезопасный путь директории проверки: %s":                                           "unsafe review directory path: %s",
+	"новое обогащение %s не слито с правками (rich merge)":                                "new enrichment %s is not merged with the edits (rich merge)",
+	"очередь проверки (review_dir) требует приемника local":                               "the review queue (review_dir) requires the local sink",
+	"очередь проверки не настроена (секция [DIRECTORIES], ключ review_dir)":               "the review queue is not configured (section [DIRECTORIES], key review_dir)",
+	"ошибка при получении абсолютного пути директории проверки: %v":                       "failed to get the absolute path of the review directory: %v",
+	"результат отмечен для проверки (%s: %s): удалите поле после проверки":                "the result is flagged for review (%s: %s): remove the field once reviewed",
+	"результат пуст": "the result is empty",
+
+	// Копия после ошибки обогащения
+	"Предупреждение: %s изменен вручную, копия после ошибки обогащения не записана":                    "Warning: %s was edited by hand, fallback copy after the enrichment failure not written",
+	"Предупреждение: не удалось записать копию после ошибки обогащения %s: %v":                         "Warning: failed to write fallback copy %s after the enrichment failure: %v",
+	"Предупреждение: не удалось прочитать %s для копии после ошибки: %v":                               "Warning: failed to read %s for the fallback copy: %v",
+	"Предупреждение: обогащение %s не удалось, в %s записан оригинал с отметкой об ошибке":             "Warning: enrichment of %s failed, the original with a failure marker was written to %s",
+	"Предупреждение: обогащение %s не удалось, в %s оставлено прежнее обогащение с отметкой об ошибке": "Warning: enrichment of %s failed, the previous enrichment with a failure marker was kept in %s",
+	"Предупреждение: ошибка при резервном копировании выходного файла: %v":                             "Warning: error backing up the output file: %v",
+	"Предупреждение: ошибка при создании выходной директории: %v":                                      "Warning: error creating the output directory: %v",
+	"некорректное значение on_failure %q: ожидалось %s, %s или %s":                                     "invalid on_failure value %q: expected %s, %s or %s",
+
+	// Шаблоны исключений
+	"ignore_patterns в секции [EXCLUSIONS]: %v": "ignore_patterns in the [EXCLUSIONS] section: %v",
+	"некорректный шаблон %q: %v":                "invalid pattern %q: %v",
+
+	// Связка ключей macOS
+	"ошибка программы security: %s":                              "security tool error: %s",
+	"секрет для связки ключей не может содержать перевод строки": "a keychain secret cannot contain a line break",
+
+	// Watch interval
+	"некорректное значение min_interval в секции [WATCH]: %s (ожидалась положительная длительность)":                  "invalid min_interval in the [WATCH] section: %s (expected a positive duration)",
+	"interval в секции [WATCH] (%s) меньше min_interval (%s)":                                                         "interval in the [WATCH] section (%s) is below min_interval (%s)",
+	"-watch-interval %s меньше min_interval секции [WATCH] (%s): каждая проверка обходит входные директории целиком":  "-watch-interval %s is below min_interval in the [WATCH] section (%s): every check walks the whole input directories",
+	"Предупреждение: проверка входных директорий заняла %s, больше интервала %s: увеличьте interval в секции [WATCH]": "Warning: checking the input directories took %s, longer than the interval %s: increase interval in the [WATCH] section",
+
+	// Индекс результатов
+	"Предупреждение: не удалось записать индекс результатов: %v": "Warning: failed to write the outputs index: %v",
+	"не удалось удалить индекс результатов: %v":                  "failed to remove the outputs index: %v",
+	"ошибка при подготовке индекса результатов: %v":              "failed to prepare the outputs index: %v",
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Функции, первый аргумент которых - переводимое сообщение
+var translatedFuncs = map[string]bool{
+	"tr": true, "trf": true, "errorf": true,
+	"logf": true, "infof": true, "warnf": true, "logErrorf": true, "fatalf": true,
+}
+
+// Сбор переводимых сообщений из исходного кода пакета
+func collectMessages(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("Ошибка поиска исходных файлов: %v", err)
+	}
+	fset := token.NewFileSet()
+	seen := make(map[string]bool)
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("Ошибка разбора %s: %v", name, err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			ident, ok := call.Fun.(*ast.Ident)
+			if !ok || !translatedFuncs[ident.Name] {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				// Функции перевода и журнала передают сообщения друг другу
+				if name == "i18n.go" || name == "console.go" {
+					return true
+				}
+				t.Errorf("%s: первый аргумент %s должен быть строковым литералом", fset.Position(call.Pos()), ident.Name)
+				return true
+			}
+			msg, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatalf("%s: %v", fset.Position(lit.Pos()), err)
+			}
+			seen[msg] = true
+			return true
+		})
+	}
+	messages := make([]string, 0, len(seen))
+	for msg := range seen {
+		messages = append(messages, msg)
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+var formatVerbRe = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z%]`)
+
+// Глаголы форматирования сообщения по порядку
+func formatVerbs(msg string) []string {
+	return formatVerbRe.FindAllString(msg, -1)
+}
+
+func TestMessageCatalogComplete(t *testing.T) {
+	cyrillic := regexp.MustCompile(`[А-Яа-яЁё]`)
+	for lang, catalog := range messageCatalogs {
+		for _, msg := range collectMessages(t) {
+			if !cyrillic.MatchString(msg) {
+				continue
+			}
+			translated, ok := catalog[msg]
+			if !ok {
+				t.Errorf("[%s] нет перевода: %q", lang, msg)
+				continue
+			}
+			if cyrillic.MatchString(translated) {
+				t.Errorf("[%s] перевод содержит кириллицу: %q", lang, translated)
+			}
+			if got, want := strings.Join(formatVerbs(translated), " "), strings.Join(formatVerbs(msg), " "); got != want {
+				t.Errorf("[%s] глаголы форматирования не совпадают для %q: %q, ожидалось %q", lang, msg, got, want)
+			}
+			if strings.HasSuffix(msg, "\n") != strings.HasSuffix(translated, "\n") {
+				t.Errorf("[%s] перевод %q отличается переводом строки в конце", lang, msg)
+			}
+		}
+	}
+}
+
+func TestMessageCatalogNoStaleEntries(t *testing.T) {
+	used := make(map[string]bool)
+	for _, msg := range collectMessages(t) {
+		used[msg] = true
+	}
+	for lang, catalog := range messageCatalogs {
+		for msg := range catalog {
+			if !used[msg] {
+				t.Errorf("[%s] перевод не используется: %q", lang, msg)
+			}
+		}
+	}
+}
+
+func TestSetUILanguage(t *testing.T) {
+	defer func() { uiLanguage = defaultUILanguage }()
+	for _, name := range []string{"RICH_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		t.Setenv(name, "")
+	}
+
+	if err := setUILanguage("en"); err != nil {
+		t.Fatalf("setUILanguage(en) вернул ошибку: %v", err)
+	}
+	if got := errorf("файл конфигурации не найден: %s", "rich.cfg").Error(); got != "configuration file not found: rich.cfg" {
+		t.Errorf("Ожидалось сообщение на английском, получено %q", got)
+	}
+	if err := setUILanguage("xx"); err == nil {
+		t.Error("Для неподдерживаемого языка ожидалась ошибка")
+	}
+
+	tests := []struct {
+		env, value, want string
+	}{
+		{"LANG", "en_US.UTF-8", "en"},
+		{"LANG", "ru_RU.UTF-8", "ru"},
+		{"LANG", "de_DE.UTF-8", "ru"},
+		{"LANG", "C", "ru"},
+		{"LC_ALL", "en_GB", "en"},
+		{"RICH_LANG", "EN", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			if err := setUILanguage("auto"); err != nil {
+				t.Fatalf("setUILanguage(auto) вернул ошибку: %v", err)
+			}
+			if uiLanguage != tt.want {
+				t.Errorf("Язык %q, ожидался %q", uiLanguage, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Имя файла локальных исключений в директориях входного дерева
+const IgnoreFileName = ".richignore"
+
+// Шаблон исключения в синтаксисе gitignore
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Встроенные шаблоны временных файлов редакторов, файлов блокировки и конфликтных
+// копий синхронизации: такие файлы не обрабатываются ни в обычном запуске, ни в
+// режиме наблюдения
+var defaultIgnorePatterns = []string{
+	"~$*",                  // блокировки Microsoft Office
+	".#*",                  // блокировки Emacs
+	".~lock.*#",            // блокировки LibreOffice
+	"*.swp", "*.swo", "*~", // Vim и резервные копии редакторов
+	"*.tmp",
+	"*.sync-conflict-*",    // конфликты Syncthing
+	"*[Cc]onflicted copy*", // конфликты Dropbox
+}
+
+// Правила исключений из файлов .richignore, собранные при обходе директорий
+type ignoreRules struct {
+	// Общие шаблоны входного дерева (встроенные и ignore_patterns), применяются
+	// до правил .richignore
+	base []ignorePattern
+	// Шаблоны по относительному пути директории (через "/"), в которой лежит .richignore
+	patterns map[string][]ignorePattern
+}
+
+// Создание набора правил исключений с общими шаблонами
+func newIgnoreRules(base ...ignorePattern) *ignoreRules {
+	return &ignoreRules{base: base, patterns: make(map[string][]ignorePattern)}
+}
+
+// Чтение общих шаблонов исключений секции [EXCLUSIONS]: встроенные шаблоны
+// (builtin_ignore, по умолчанию включены) и дополнительные ignore_patterns в
+// синтаксисе .richignore через запятую
+func loadIgnorePatterns(section *ini.Section) ([]ignorePattern, error) {
+	var lines []string
+	if section.Key("builtin_ignore").MustBool(true) {
+		lines = append(lines, defaultIgnorePatterns...)
+	}
+	lines = append(lines, splitList(section.Key("ignore_patterns").String())...)
+	var patterns []ignorePattern
+	for _, line := range lines {
+		p, ok, err := parseIgnorePattern(line)
+		if err != nil {
+			return nil, errorf("ignore_patterns в секции [EXCLUSIONS]: %v", err)
+		}
+		if ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, nil
+}
+
+// Загрузка .richignore из директории. Пустой файл исключает все поддерево директории.
+func (r *ignoreRules) Load(dir, relDir string) (excludeSubtree bool, err error) {
+	file, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errorf("не удалось открыть %s: %v", filepath.Join(dir, IgnoreFileName), err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		p, ok, err := parseIgnorePattern(scanner.Text())
+		if err != nil {
+			return false, errorf("%s:%d: %v", filepath.Join(dir, IgnoreFileName), line, err)
+		}
+		if ok {
+			patterns = append(patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, errorf("ошибка чтения %s: %v", filepath.Join(dir, IgnoreFileName), err)
+	}
+
+	if len(patterns) == 0 {
+		return true, nil
+	}
+	r.patterns[path.Clean(filepath.ToSlash(relDir))] = patterns
+	return false, nil
+}
+
+// Проверка, исключен ли путь общими шаблонами и правилами .richignore.
+// Правила применяются от корня к вложенным директориям, последнее совпадение имеет приоритет.
+func (r *ignoreRules) Match(relPath string, isDir bool) bool {
+	relPath = path.Clean(filepath.ToSlash(relPath))
+
+	// Директории-владельцы правил от корня к самой глубокой
+	dirs := []string{"."}
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+
+	ignored := false
+	for _, p := range r.base {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	for _, dir := range dirs {
+		sub := relPath
+		if dir != "." {
+			sub = strings.TrimPrefix(relPath, dir+"/")
+		}
+		for _, p := range r.patterns[dir] {
+			if p.dirOnly && !isDir {
+				continue
+			}
+			if p.re.MatchString(sub) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Разбор строки .richignore в шаблон (пустые строки и комментарии пропускаются).
+// Ошибка - шаблон не преобразуется в регулярное выражение (например "[z-a]")
+func parseIgnorePattern(line string) (ignorePattern, bool, error) {
+	// Завершающие пробелы игнорируются, если не экранированы
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false, nil
+	}
+
+	var p ignorePattern
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, "\\!"), strings.HasPrefix(line, "\\#"):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false, nil
+	}
+
+	// Шаблон со слешем в начале или середине привязан к директории .richignore
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignorePattern{}, false, errorf("некорректный шаблон %q: %v", line, err)
+	}
+	p.re = re
+	return p, true, nil
+}
+
+// Преобразование glob-шаблона gitignore (*, ?, [...], **) в регулярное выражение
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				atEnd := i+2 == len(glob)
+				switch {
+				case atStart && i+2 < len(glob) && glob[i+2] == '/':
+					// "**/" - ноль или более директорий
+					b.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				case atStart && atEnd:
+					// "/**" в конце - все содержимое
+					b.WriteString(".*")
+					i++
+					continue
+				}
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Глубина относительного пути директории ("." - 0, "a" - 1, "a/b" - 2)
+func pathDepth(relDir string) int {
+	relDir = filepath.Clean(relDir)
+	if relDir == "." {
+		return 0
+	}
+	return strings.Count(relDir, string(filepath.Separator)) + 1
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "notes", "drafts"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директории: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "private"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директории: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "notes", IgnoreFileName), []byte("# комментарий\n*.tmp.md\ndrafts/\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать %s: %v", IgnoreFileName, err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "private", IgnoreFileName), nil, 0644); err != nil {
+		t.Fatalf("Не удалось создать %s: %v", IgnoreFileName, err)
+	}
+
+	rules := newIgnoreRules()
+
+	excluded, err := rules.Load(filepath.Join(tmpDir, "notes"), "notes")
+	if err != nil || excluded {
+		t.Fatalf("Load() для файла с шаблонами: excluded=%v, err=%v", excluded, err)
+	}
+	excluded, err = rules.Load(filepath.Join(tmpDir, "private"), "private")
+	if err != nil || !excluded {
+		t.Fatalf("Пустой %s должен исключать поддерево: excluded=%v, err=%v", IgnoreFileName, excluded, err)
+	}
+	excluded, err = rules.Load(tmpDir, ".")
+	if err != nil || excluded {
+		t.Fatalf("Директория без %s не должна исключаться: excluded=%v, err=%v", IgnoreFileName, excluded, err)
+	}
+
+	testCases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{filepath.Join("notes", "a.md"), false, false},
+		{filepath.Join("notes", "a.tmp.md"), false, true},
+		{filepath.Join("notes", "sub", "b.tmp.md"), false, true},
+		{filepath.Join("notes", "drafts"), true, true},
+		{filepath.Join("notes", "sub", "drafts"), true, true},
+		{filepath.Join("notes", "drafts"), false, false}, // шаблон drafts/ только для директорий
+		{"a.tmp.md", false, false},                       // правила не действуют выше своей директории
+	}
+	for _, tc := range testCases {
+		if got := rules.Match(tc.path, tc.isDir); got != tc.expected {
+			t.Errorf("Match(%s, %v) = %v, ожидалось %v", tc.path, tc.isDir, got, tc.expected)
+		}
+	}
+}
+
+func TestIgnoreGitignoreSemantics(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "docs"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директории: %v", err)
+	}
+	rootRules := "*.md\n!keep.md\n/top.md\narchive/**/old-*.md\n\\#literal.md\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, IgnoreFileName), []byte(rootRules), 0644); err != nil {
+		t.Fatalf("Не удалось создать %s: %v", IgnoreFileName, err)
+	}
+	// Вложенный .richignore переопределяет правила родителя
+	if err := os.WriteFile(filepath.Join(tmpDir, "docs", IgnoreFileName), []byte("!*.md\nsecret.md\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать %s: %v", IgnoreFileName, err)
+	}
+
+	rules := newIgnoreRules()
+	if _, err := rules.Load(tmpDir, "."); err != nil {
+		t.Fatalf("Load() вернул ошибку: %v", err)
+	}
+	if _, err := rules.Load(filepath.Join(tmpDir, "docs"), "docs"); err != nil {
+		t.Fatalf("Load() вернул ошибку: %v", err)
+	}
+
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"note.md", true},
+		{"keep.md", false},
+		{"notes.txt", false},
+		{"#literal.md", true},
+		{filepath.Join("docs", "guide.md"), false},
+		{filepath.Join("docs", "secret.md"), true},
+		{filepath.Join("sub", "keep.md"), false},
+	}
+	for _, tc := range testCases {
+		if got := rules.Match(tc.path, false); got != tc.expected {
+			t.Errorf("Match(%s) = %v, ожидалось %v", tc.path, got, tc.expected)
+		}
+	}
+}
+
+func TestParseIgnorePattern(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"/top.md", "top.md", true},
+		{"/top.md", "sub/top.md", false},
+		{"top.md", "sub/top.md", true},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/*.md", "x/docs/a.md", false},
+		{"**/tmp", "a/b/tmp", true},
+		{"**/tmp", "tmp", true},
+		{"a/**/b.md", "a/b.md", true},
+		{"a/**/b.md", "a/x/y/b.md", true},
+		{"build/**", "build/x/y.md", true},
+		{"note?.md", "note1.md", true},
+		{"note[0-9].md", "notea.md", false},
+		{"note[!0-9].md", "notea.md", true},
+	}
+
+	for _, tc := range testCases {
+		p, ok, err := parseIgnorePattern(tc.pattern)
+		if err != nil || !ok {
+			t.Fatalf("parseIgnorePattern(%q) не распознал шаблон", tc.pattern)
+		}
+		if got := p.re.MatchString(tc.path); got != tc.expected {
+			t.Errorf("Шаблон %q для %q: %v, ожидалось %v", tc.pattern, tc.path, got, tc.expected)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# комментарий"} {
+		if _, ok, _ := parseIgnorePattern(line); ok {
+			t.Errorf("Строка %q не должна считаться шаблоном", line)
+		}
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	testCases := map[string]int{
+		".":                     0,
+		"a":                     1,
+		filepath.Join("a", "b"): 2,
+	}
+	for path, expected := range testCases {
+		if got := pathDepth(path); got != expected {
+			t.Errorf("pathDepth(%s) = %d, ожидалось %d", path, got, expected)
+		}
+	}
+}
+
+func mustLoadIgnorePatterns(t *testing.T, section *ini.Section) []ignorePattern {
+	t.Helper()
+	patterns, err := loadIgnorePatterns(section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patterns
+}
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	cfg, err := ini.Load([]byte("[EXCLUSIONS]\nignore_patterns = *.bak.md, !keep.tmp\n[OFF]\nbuiltin_ignore = false\nignore_patterns = *.bak.md\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := newIgnoreRules(mustLoadIgnorePatterns(t, cfg.Section("EXCLUSIONS"))...)
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"note.md", false},
+		{"~$note.md", true},
+		{filepath.Join("sub", ".#note.md"), true},
+		{".~lock.note.md#", true},
+		{"note.md.swp", true},
+		{"note.md~", true},
+		{"note.tmp", true},
+		{"keep.tmp", false}, // отрицание в ignore_patterns возвращает файл
+		{"note.sync-conflict-20240101-120000-ABCDEF.md", true},
+		{"note (conflicted copy 2024-01-01).md", true},
+		{"note (Иван's Conflicted copy).md", true},
+		{filepath.Join("sub", "old.bak.md"), true},
+	}
+	for _, tc := range testCases {
+		if got := rules.Match(tc.path, false); got != tc.expected {
+			t.Errorf("Match(%s) = %v, ожидалось %v", tc.path, got, tc.expected)
+		}
+	}
+
+	// Без встроенных шаблонов действуют только ignore_patterns
+	rules = newIgnoreRules(mustLoadIgnorePatterns(t, cfg.Section("OFF"))...)
+	if rules.Match("~$note.md", false) || !rules.Match("old.bak.md", false) {
+		t.Error("builtin_ignore = false должен отключать только встроенные шаблоны")
+	}
+
+	// .richignore применяется после общих шаблонов и может вернуть файл
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte("!*~\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules = newIgnoreRules(mustLoadIgnorePatterns(t, cfg.Section("EXCLUSIONS"))...)
+	if _, err := rules.Load(dir, "."); err != nil {
+		t.Fatal(err)
+	}
+	if rules.Match("note.md~", false) {
+		t.Error("Отрицание в .richignore должно возвращать файл, исключенный общими шаблонами")
+	}
+}
+
+func TestMalformedIgnorePattern(t *testing.T) {
+	if _, _, err := parseIgnorePattern("[z-a].md"); err == nil {
+		t.Error("Ожидалась ошибка для шаблона с некорректным диапазоном")
+	}
+
+	// .richignore: ошибка указывает файл и строку
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte("*.tmp\n[z-a].md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := newIgnoreRules().Load(dir, ".")
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, IgnoreFileName)+":2:") {
+		t.Errorf("Load() = %v, ожидалась ошибка с файлом и строкой", err)
+	}
+
+	// ignore_patterns и условия маршрутов
+	cfg, err := ini.Load([]byte("[EXCLUSIONS]\nignore_patterns = [z-a].md\n[ROUTES]\nblog = blog/[z-a]*\n[ROUTE.blog]\nprompt = p\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadIgnorePatterns(cfg.Section("EXCLUSIONS")); err == nil {
+		t.Error("Ожидалась ошибка для некорректного шаблона ignore_patterns")
+	}
+	if _, err := loadRoutes(cfg, dir); err == nil || !strings.Contains(err.Error(), "blog/[z-a]*") {
+		t.Error("Ожидалась ошибка для некорректного условия маршрута")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Доля измененных разделов, при превышении которой документ обогащается заново целиком
+const incrementalMaxChangedShare = 0.5
+
+// Ранее сохраненный результат обогащения: обогащенный текст и оригинал из блока ```old
+type previousOutput struct {
+	Enriched string
+	Original string
+}
+
+// Чтение предыдущего результата обогащения из выходного файла
+func readPreviousOutput(outputPath string) (*previousOutput, bool) {
+	data, err := readMarkdownFile(outputPath)
+	if err != nil {
+		return nil, false
+	}
+	return parseEnrichedOutput(string(data))
+}
+
+// Разбор выходного файла формата "<обогащенный текст>\n\n```old\n<оригинал>\n```"
+func parseEnrichedOutput(data string) (*previousOutput, bool) {
+	const openFence = "\n\n```old\n"
+	const closeFence = "\n```"
+
+	start := strings.LastIndex(data, openFence)
+	if start < 0 || !strings.HasSuffix(data, closeFence) || start+len(openFence) > len(data)-len(closeFence) {
+		return nil, false
+	}
+	original := data[start+len(openFence) : len(data)-len(closeFence)]
+	return &previousOutput{
+		Enriched: data[:start],
+		Original: strings.ReplaceAll(original, "\\`\\`\\`", "```"),
+	}, true
+}
+
+// Проверка, что ранее обогащенный файл изменился с момента последней обработки
+func needsIncrementalUpdate(inputPath, outputPath string) bool {
+	prev, ok := readPreviousOutput(outputPath)
+	if !ok {
+		return false
+	}
+	content, err := readMarkdownFile(inputPath)
+	if err != nil {
+		return false
+	}
+	return prev.Original != string(content)
+}
+
+// Раздел markdown-документа: заголовок (с учетом номера повтора) и полный текст с заголовком
+type mdSection struct {
+	Key  string
+	Text string
+}
+
+// Разбиение документа на разделы по заголовкам (текст до первого заголовка - раздел с ключом "")
+func splitByHeadings(text string) []mdSection {
+	var sections []mdSection
+	seen := make(map[string]int)
+	current := mdSection{}
+	inFence := false
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && isHeadingLine(trimmed) {
+			if current.Key != "" || current.Text != "" {
+				sections = append(sections, current)
+			}
+			key := strings.ToLower(trimmed)
+			seen[key]++
+			if seen[key] > 1 {
+				key = fmt.Sprintf("%s#%d", key, seen[key])
+			}
+			current = mdSection{Key: key}
+		}
+		current.Text += line
+	}
+	if current.Key != "" || current.Text != "" {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// Инкрементальное обогащение: в API отправляются только измененные и новые разделы,
+// результаты подставляются в предыдущую обогащенную версию. Возвращает ok=false,
+// если изменения нельзя сопоставить и документ нужно обогатить заново целиком.
+func enrichIncrementally(config *Config, prev *previousOutput, content string, limiter *RateLimiter) (string, Usage, bool, error) {
+	var usage Usage
+	oldSections := splitByHeadings(prev.Original)
+	newSections := splitByHeadings(content)
+
+	oldByKey := make(map[string]string, len(oldSections))
+	for _, s := range oldSections {
+		oldByKey[s.Key] = s.Text
+	}
+	newKeys := make(map[string]bool, len(newSections))
+	var changed []mdSection
+	for _, s := range newSections {
+		newKeys[s.Key] = true
+		if strings.TrimSpace(oldByKey[s.Key]) != strings.TrimSpace(s.Text) {
+			changed = append(changed, s)
+		}
+	}
+	var removed []string
+	for _, s := range oldSections {
+		if !newKeys[s.Key] {
+			removed = append(removed, s.Key)
+		}
+	}
+
+	// Вступление определяет теги и заголовок всего документа - его изменение требует полной обработки
+	for _, s := range changed {
+		if s.Key == "" {
+			return "", usage, false, nil
+		}
+	}
+	if float64(len(changed)) > incrementalMaxChangedShare*float64(len(newSections)) {
+		return "", usage, false, nil
+	}
+
+	enriched := splitByHeadings(prev.Enriched)
+	indexOf := func(key string) int {
+		for i, s := range enriched {
+			if s.Key == key {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Раздел оригинала без обогащенного аналога с тем же заголовком (модель или
+	// normalize_headings переименовали заголовок): замена добавила бы копию
+	// раздела, а удаление оставило бы прежний текст
+	for _, s := range changed {
+		if _, existed := oldByKey[s.Key]; existed && indexOf(s.Key) < 0 {
+			return "", usage, false, nil
+		}
+	}
+	for _, key := range removed {
+		if indexOf(key) < 0 {
+			return "", usage, false, nil
+		}
+	}
+
+	for _, key := range removed {
+		if i := indexOf(key); i >= 0 {
+			enriched = append(enriched[:i], enriched[i+1:]...)
+		}
+	}
+
+	for _, s := range changed {
+		text, u, err := enrichContentWithUsage(config, s.Text, limiter)
+		usage = usage.Add(u)
+		if err != nil {
+			return "", usage, false, err
+		}
+		section := mdSection{Key: s.Key, Text: strings.TrimRight(text, "\n") + "\n\n"}
+
+		if i := indexOf(s.Key); i >= 0 {
+			enriched[i] = section
+			continue
+		}
+
+		// Новый раздел вставляется после обогащенного аналога предыдущего раздела оригинала
+		insertAt := len(enriched)
+		for j, ns := range newSections {
+			if ns.Key != s.Key {
+				continue
+			}
+			for k := j - 1; k >= 0; k-- {
+				if i := indexOf(newSections[k].Key); i >= 0 {
+					insertAt = i + 1
+					break
+				}
+			}
+			break
+		}
+		enriched = append(enriched[:insertAt], append([]mdSection{section}, enriched[insertAt:]...)...)
+	}
+
+	var b strings.Builder
+	for _, s := range enriched {
+		b.WriteString(s.Text)
+	}
+	logf("Инкрементальное обогащение: изменено разделов %d, удалено %d", len(changed), len(removed))
+	return strings.TrimSpace(b.String()), usage, true, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnrichedOutput(t *testing.T) {
+	original := "# Заметка\n\n```go\nx := 1\n```"
+	escaped := strings.ReplaceAll(original, "```", "\\`\\`\\`")
+	data := "# Обогащено\n\nТекст\n\n```old\n" + escaped + "\n```"
+
+	prev, ok := parseEnrichedOutput(data)
+	if !ok {
+		t.Fatal("Не удалось разобрать выходной файл")
+	}
+	if prev.Enriched != "# Обогащено\n\nТекст" {
+		t.Errorf("Некорректный обогащенный текст: %q", prev.Enriched)
+	}
+	if prev.Original != original {
+		t.Errorf("Некорректный оригинал: %q", prev.Original)
+	}
+
+	if _, ok := parseEnrichedOutput("просто текст"); ok {
+		t.Error("Файл без блока old не должен разбираться")
+	}
+}
+
+func TestSplitByHeadings(t *testing.T) {
+	sections := splitByHeadings("Вступление\n# A\nтекст\n```\n# не заголовок\n```\n## B\n## B\n")
+	keys := make([]string, len(sections))
+	for i, s := range sections {
+		keys[i] = s.Key
+	}
+	if strings.Join(keys, "|") != "|# a|## b|## b#2" {
+		t.Errorf("Некорректные ключи разделов: %q", keys)
+	}
+}
+
+func TestEnrichIncrementally(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "## Установка\n\nНовый обогащенный раздел"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{ModelAPIURL: server.URL + "/v1/chat/completions", Prompt: "P"}
+	prev := &previousOutput{
+		Original: "Вступление\n\n## Обзор\n\nСтарый обзор\n\n## Установка\n\napt install\n\n## Удалено\n\nx\n",
+		Enriched: "#tag\n\n# Заголовок\n\n## Обзор\n\nПодробный обзор\n\n## Установка\n\nСтарый раздел\n\n## Дополнительно\n\nДобавлено моделью\n\n## Удалено\n\nx\n",
+	}
+	content := "Вступление\n\n## Обзор\n\nСтарый обзор\n\n## Установка\n\napt install -y\n"
+
+	enriched, _, ok, err := enrichIncrementally(config, prev, content, NewRateLimiter(10))
+	if err != nil || !ok {
+		t.Fatalf("enrichIncrementally() ok=%v, err=%v", ok, err)
+	}
+	if len(requests) != 1 || !strings.Contains(requests[0], "apt install -y") || strings.Contains(requests[0], "Старый обзор") {
+		t.Errorf("В API должен отправляться только измененный раздел: %q", requests)
+	}
+	for _, want := range []string{"Подробный обзор", "Новый обогащенный раздел", "Добавлено моделью"} {
+		if !strings.Contains(enriched, want) {
+			t.Errorf("В результате отсутствует %q:\n%s", want, enriched)
+		}
+	}
+	for _, unwanted := range []string{"Старый раздел", "## Удалено"} {
+		if strings.Contains(enriched, unwanted) {
+			t.Errorf("В результате не должно быть %q:\n%s", unwanted, enriched)
+		}
+	}
+
+	// Изменение вступления требует полного обогащения
+	_, _, ok, err = enrichIncrementally(config, prev, "Новое вступление\n\n## Обзор\n\nСтарый обзор\n\n## Установка\n\napt install\n\n## Удалено\n\nx\n", NewRateLimiter(10))
+	if err != nil || ok {
+		t.Errorf("Ожидался отказ от инкрементального обогащения, ok=%v, err=%v", ok, err)
+	}
+
+	// Модель переименовала заголовки: измененный и удаленный разделы не сопоставить
+	// с обогащенными, документ обогащается заново целиком
+	renamed := &previousOutput{
+		Original: prev.Original,
+		Enriched: "# Заголовок\n\n## Обзор проекта\n\nПодробный обзор\n\n### Установка\n\nСтарый раздел\n\n## Удалено\n\nx\n",
+	}
+	requests = nil
+	for _, changedContent := range []string{
+		"Вступление\n\n## Обзор\n\nНовый обзор\n\n## Установка\n\napt install\n\n## Удалено\n\nx\n",
+		"Вступление\n\n## Обзор\n\nСтарый обзор\n\n## Удалено\n\nx\n",
+	} {
+		_, _, ok, err = enrichIncrementally(config, renamed, changedContent, NewRateLimiter(10))
+		if err != nil || ok {
+			t.Errorf("Ожидался отказ от инкрементального обогащения при переименованных заголовках, ok=%v, err=%v", ok, err)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("При отказе запросы к API не отправляются: %d", len(requests))
+	}
+}
+
+func TestProcessDirectoryIncremental(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = README.md\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	notePath := filepath.Join(inputDir, "note.md")
+	if err := os.WriteFile(notePath, []byte("Вступление\n\n## A\n\nодин\n\n## B\n\nдва\n\n## C\n\nтри\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "README.md"), []byte("# Исключен пользователем"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "## B\n\nобогащено"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:      inputDir,
+		OutputDir:     outputDir,
+		ModelAPIURL:   server.URL + "/v1/chat/completions",
+		Incremental:   true,
+		ExcludedFiles: []string{"README.md"},
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("Ожидался 1 запрос при первой обработке, получено %d", requests)
+	}
+
+	// Повторный запуск без изменений не обращается к API
+	updated, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+	config.ExcludedFiles = updated.ExcludedFiles
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("Неизмененный файл не должен отправляться повторно, запросов: %d", requests)
+	}
+
+	// Изменение одного раздела отправляет в API только его
+	if err := os.WriteFile(notePath, []byte("Вступление\n\n## A\n\nодин\n\n## B\n\nдва с половиной\n\n## C\n\nтри\n"), 0644); err != nil {
+		t.Fatalf("Не удалось изменить тестовый файл: %v", err)
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Ожидался 1 дополнительный запрос для измененного раздела, всего %d", requests)
+	}
+
+	prev, ok := readPreviousOutput(filepath.Join(outputDir, "note.md"))
+	if !ok || !strings.Contains(prev.Original, "два с половиной") {
+		t.Error("Блок old должен содержать актуальный оригинал")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "README.md")); !os.IsNotExist(err) {
+		t.Error("Файл, исключенный пользователем, не должен обрабатываться")
+	}
+}
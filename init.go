@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Начальные параметры конфигурации провайдера для rich init
+type initTemplate struct {
+	Model string
+	// Адрес API, если у провайдера нет адреса по умолчанию
+	APIURL    string
+	MaxTokens int
+	// Запросов в минуту (0 - лимит провайдера или RequestsPerMinute)
+	RequestsPerMinute int
+}
+
+// Шаблоны конфигурации по провайдерам: недорогая модель общего назначения и
+// лимиты, на которых не срабатывают ограничения бесплатных и начальных тарифов
+var initTemplates = map[string]initTemplate{
+	"openai":                 {Model: "gpt-4o-mini", MaxTokens: 8000, RequestsPerMinute: 60},
+	"anthropic":              {Model: "claude-sonnet-4-0", MaxTokens: 8000, RequestsPerMinute: 40},
+	"openrouter":             {Model: "openai/gpt-4o-mini", MaxTokens: 8000, RequestsPerMinute: 20},
+	"groq":                   {Model: "llama-3.3-70b-versatile", MaxTokens: 8000},
+	"deepseek":               {Model: "deepseek-chat", MaxTokens: 8000, RequestsPerMinute: 30},
+	"xai":                    {Model: "grok-3", MaxTokens: 8000, RequestsPerMinute: 30},
+	"together":               {Model: "meta-llama/Llama-3.3-70B-Instruct-Turbo", MaxTokens: 8000, RequestsPerMinute: 30},
+	"fireworks":              {Model: "accounts/fireworks/models/llama-v3p3-70b-instruct", MaxTokens: 8000, RequestsPerMinute: 30},
+	"mistral":                {Model: "mistral-small-latest", MaxTokens: 8000, RequestsPerMinute: 30},
+	providerVertex:           {Model: "gemini-2.0-flash", MaxTokens: 8000, RequestsPerMinute: 30},
+	"gemini":                 {Model: "gemini-2.0-flash", MaxTokens: 8000, RequestsPerMinute: 10},
+	"ollama":                 {Model: "llama3.2", MaxTokens: 4000, RequestsPerMinute: 60},
+	providerOpenAICompatible: {Model: "local-model", APIURL: "http://localhost:1234/v1/chat/completions", MaxTokens: 4000, RequestsPerMinute: 60},
+}
+
+// Начальный промпт обогащения
+const initPrompt = `Enrich the markdown note below. Keep every fact, link and image of the original, fix obvious mistakes, explain terms that a newcomer would not know, add short examples where they help and structure the text with headings. Do not invent facts. Answer in the language of the note, in markdown, starting with a level one heading with the note title.`
+
+// Параметры новой конфигурации
+type initOptions struct {
+	Provider  string
+	Model     string
+	InputDir  string
+	OutputDir string
+}
+
+// Вопрос с ответом по умолчанию; пустой ответ оставляет значение по умолчанию
+func askInitValue(r *bufio.Reader, out io.Writer, question, def string) string {
+	fmt.Fprintf(out, "%s [%s]: ", question, def)
+	line, _ := r.ReadString('\n')
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+// Опрос параметров, не заданных флагами
+func askInitOptions(opts *initOptions, in io.Reader, out io.Writer) {
+	r := bufio.NewReader(in)
+	if opts.Provider == "" {
+		fmt.Fprintf(out, tr("Провайдеры: %s\n"), strings.Join(providerNames(), ", "))
+		opts.Provider = askInitValue(r, out, tr("Провайдер"), "openai")
+	}
+	if opts.Model == "" {
+		opts.Model = askInitValue(r, out, tr("Модель"), initTemplates[strings.ToLower(opts.Provider)].Model)
+	}
+	opts.InputDir = askInitValue(r, out, tr("Директория исходных заметок"), opts.InputDir)
+	opts.OutputDir = askInitValue(r, out, tr("Директория результатов"), opts.OutputDir)
+}
+
+// Текст конфигурации для провайдера
+func renderInitConfig(opts initOptions) (string, error) {
+	p, ok := providerByName(opts.Provider)
+	tmpl, known := initTemplates[strings.ToLower(opts.Provider)]
+	if !ok || !known {
+		return "", errorf("неизвестный провайдер %q: поддерживаются %s", opts.Provider, strings.Join(providerNames(), ", "))
+	}
+	model := opts.Model
+	if model == "" {
+		model = tmpl.Model
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[DIRECTORIES]\ninput_dir  = %s\noutput_dir = %s\n\n", opts.InputDir, opts.OutputDir)
+	b.WriteString("[EXCLUSIONS]\n# Processed files are added here automatically\nexcluded_files =\n\n")
+	fmt.Fprintf(&b, "[MODEL]\nprovider    = %s\nname        = %s\n", p.Name, model)
+	if tmpl.APIURL != "" {
+		fmt.Fprintf(&b, "api_url     = %s\n", tmpl.APIURL)
+	}
+	if p.KeyEnv != "" {
+		fmt.Fprintf(&b, "# The key is read from the environment variable and is not stored in this file\napi_key_env = %s\n", p.KeyEnv)
+	}
+	if p.Name == providerVertex {
+		b.WriteString("# Vertex AI project and region; without credentials_file Application Default Credentials are used\nproject     =\nlocation    = " + defaultVertexLocation + "\n# credentials_file = service-account.json\n")
+	}
+	fmt.Fprintf(&b, "temperature = 0.7\nmax_tokens  = %d\n", tmpl.MaxTokens)
+	if tmpl.RequestsPerMinute > 0 {
+		fmt.Fprintf(&b, "requests_per_minute = %d\n", tmpl.RequestsPerMinute)
+	}
+	b.WriteString("\n[STATE]\n# Run journals for rich status, rich undo and rich verify\ndir = .rich\n\n")
+	fmt.Fprintf(&b, "[PROMPT]\ntext = \"\"\"%s\"\"\"\n", initPrompt)
+	return b.String(), nil
+}
+
+// Проверка, что стандартный ввод - терминал
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// rich init [--provider имя] [--model модель] [--input dir] [--output dir] [--yes]:
+// создание рабочей конфигурации для провайдера; параметры, не заданные флагами,
+// запрашиваются в терминале
+func runInitCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	opts := initOptions{}
+	fs.StringVar(&opts.Provider, "provider", "", tr("Провайдер: ")+strings.Join(providerNames(), ", "))
+	fs.StringVar(&opts.Model, "model", "", tr("Модель (по умолчанию - рекомендуемая для провайдера)"))
+	fs.StringVar(&opts.InputDir, "input", "./todo", tr("Директория исходных заметок"))
+	fs.StringVar(&opts.OutputDir, "output", "./done", tr("Директория результатов"))
+	yes := fs.Bool("yes", false, tr("Не задавать вопросов, использовать флаги и значения по умолчанию"))
+	force := fs.Bool("force", false, tr("Перезаписать существующий файл конфигурации"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*configPath); err == nil && !*force {
+		return errorf("файл %s уже существует (используйте --force для перезаписи)", *configPath)
+	}
+
+	if !*yes && stdinIsTerminal() {
+		askInitOptions(&opts, os.Stdin, out)
+	}
+	if opts.Provider == "" {
+		return errorf("не указан провайдер: rich init --provider <%s>", strings.Join(providerNames(), "|"))
+	}
+	text, err := renderInitConfig(opts)
+	if err != nil {
+		return err
+	}
+	if err := safeWriteFile(*configPath, []byte(text), 0644); err != nil {
+		return withCategory(ErrorIO, err)
+	}
+	// Созданная конфигурация должна загружаться без ошибок
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("созданная конфигурация не загружается: %v", err)
+	}
+
+	fmt.Fprintf(out, tr("Конфигурация записана в %s: %s, модель %s\n"), *configPath, config.provider().Name, config.ModelName)
+	if p := config.provider(); p.KeyEnv != "" {
+		if os.Getenv(p.KeyEnv) == "" {
+			fmt.Fprintf(out, tr("Задайте ключ API в переменной окружения %s (или сохраните его командой rich secret set)\n"), p.KeyEnv)
+		} else {
+			fmt.Fprintf(out, tr("Ключ API будет прочитан из переменной окружения %s\n"), p.KeyEnv)
+		}
+	}
+	fmt.Fprintf(out, tr("Проверка настройки: rich doctor --config %s\n"), *configPath)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderInitConfig(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range providerNames() {
+		text, err := renderInitConfig(initOptions{Provider: name, InputDir: "./todo", OutputDir: "./done"})
+		if err != nil {
+			t.Errorf("renderInitConfig(%s) вернул ошибку: %v", name, err)
+			continue
+		}
+		if p, _ := providerByName(name); p.KeyEnv != "" && !strings.Contains(text, "api_key_env = "+p.KeyEnv) {
+			t.Errorf("в конфигурации %s нет переменной ключа:\n%s", name, text)
+		}
+		if strings.Contains(text, "api_key =") {
+			t.Errorf("конфигурация %s содержит ключ-заглушку", name)
+		}
+		path := filepath.Join(dir, name+".cfg")
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if config, err := loadConfig(path); err != nil || config.provider() == nil || config.provider().Name != name {
+			t.Errorf("конфигурация %s не загружается: %v", name, err)
+		}
+	}
+	if _, err := renderInitConfig(initOptions{Provider: "nope"}); err == nil {
+		t.Error("ожидалась ошибка для неизвестного провайдера")
+	}
+}
+
+func TestAskInitOptions(t *testing.T) {
+	opts := initOptions{InputDir: "./todo", OutputDir: "./done"}
+	var out bytes.Buffer
+	askInitOptions(&opts, strings.NewReader("anthropic\n\nnotes\n\n"), &out)
+	want := initOptions{Provider: "anthropic", Model: "claude-sonnet-4-0", InputDir: "notes", OutputDir: "./done"}
+	if opts != want {
+		t.Errorf("askInitOptions() = %+v, ожидалось %+v", opts, want)
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	configPath := filepath.Join(t.TempDir(), "rich.cfg")
+	var out bytes.Buffer
+	if err := runInitCommand([]string{"--config", configPath, "--provider", "anthropic", "--yes"}, &out); err != nil {
+		t.Fatalf("rich init вернул ошибку: %v", err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("созданная конфигурация не загружается: %v", err)
+	}
+	if config.ModelAPIURL != "https://api.anthropic.com/v1/messages" || config.ModelName != "claude-sonnet-4-0" ||
+		config.RequestsPerMinute != 40 || config.Prompt == "" {
+		t.Errorf("конфигурация: адрес %s, модель %s, запросов в минуту %d", config.ModelAPIURL, config.ModelName, config.RequestsPerMinute)
+	}
+	if !strings.Contains(out.String(), "ANTHROPIC_API_KEY") {
+		t.Errorf("вывод rich init:\n%s", out.String())
+	}
+
+	if err := runInitCommand([]string{"--config", configPath, "--provider", "openai", "--yes"}, &out); err == nil {
+		t.Error("ожидалась ошибка для существующего файла без --force")
+	}
+	if err := runInitCommand([]string{"--config", configPath, "--provider", "openai", "--yes", "--force"}, &out); err != nil {
+		t.Errorf("rich init --force вернул ошибку: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Поддиректория каталога состояния с намерениями записи
+const intentsDirName = "intents"
+
+// Намерение записи выходного файла: сохраняется перед записью результата и удаляется
+// после добавления файла в excluded_files. Если процесс завершился между записью
+// результата и обновлением списка исключений, следующий запуск находит намерение,
+// проверяет хэши входного и выходного файлов и досохраняет состояние без повторного
+// запроса к API
+type outputIntent struct {
+	// Путь файла в общем состоянии запуска (ключ excluded_files)
+	Key string `json:"key"`
+	// Абсолютные пути входного и выходного файлов
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// Хэши входного файла, по которому получен результат, и записываемого результата
+	InputHash  string    `json:"input_hash"`
+	OutputHash string    `json:"output_hash"`
+	RunID      string    `json:"run_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Путь файла намерения: по хэшу ключа, чтобы повторная запись того же файла
+// заменяла прежнее намерение
+func intentPath(stateDir, key string) string {
+	return filepath.Join(stateDir, intentsDirName, contentHash([]byte(pathKey(key)))[:32]+".json")
+}
+
+// Сохранение намерения записи; возвращает путь файла намерения
+func saveIntent(stateDir string, intent outputIntent) (string, error) {
+	path := intentPath(stateDir, intent.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", errorf("не удалось создать директорию намерений записи: %v", err)
+	}
+	intent.CreatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(intent, "", "  ")
+	if err != nil {
+		return "", errorf("ошибка при подготовке намерения записи: %v", err)
+	}
+	if err := safeWriteFile(path, data, 0644); err != nil {
+		return "", errorf("ошибка при сохранении намерения записи: %v", err)
+	}
+	return path, nil
+}
+
+// Удаление выполненного намерения записи
+func removeIntent(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		warnf("Предупреждение: не удалось удалить намерение записи %s: %v", path, err)
+	}
+}
+
+// Восстановление после аварийного завершения: файлы, результат которых записан, но
+// не добавлен в excluded_files, добавляются в список без повторной обработки, если
+// входной файл не изменился, а выходной совпадает с записанным. Остальные намерения
+// удаляются, и такие файлы обрабатываются заново. Возвращает ключи восстановленных файлов
+func recoverIntents(config *Config, configPath string) ([]string, error) {
+	if config.StateDir == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(config.StateDir, intentsDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errorf("не удалось прочитать намерения записи: %v", err)
+	}
+	var recovered []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		var intent outputIntent
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &intent)
+		}
+		if err != nil {
+			warnf("Предупреждение: некорректное намерение записи %s: %v", path, err)
+			removeIntent(path)
+			continue
+		}
+		if intentCompleted(intent) {
+			if err := addToExcludedFiles(configPath, intent.Key); err != nil {
+				return recovered, err
+			}
+			if !isExcluded(config, intent.Key) {
+				config.ExcludedFiles = append(config.ExcludedFiles, normalizeRelPath(intent.Key))
+			}
+			infof("Восстановлено состояние %s: результат записан до аварийного завершения, повторная обработка не нужна", intent.Key)
+			recovered = append(recovered, intent.Key)
+		} else {
+			logf("Незавершенная запись %s: файл будет обработан заново", intent.Key)
+		}
+		removeIntent(path)
+	}
+	return recovered, nil
+}
+
+// Проверка, что результат намерения записан и получен по текущему содержимому входного файла
+func intentCompleted(intent outputIntent) bool {
+	output, err := os.ReadFile(intent.Output)
+	if err != nil || contentHash(output) != intent.OutputHash {
+		return false
+	}
+	input, err := os.ReadFile(intent.Input)
+	return err == nil && contentHash(input) == intent.InputHash
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoverIntentsAfterCrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	for _, dir := range []string{inputDir, outputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{"a.md": "# Записан до сбоя", "b.md": "# Изменен после сбоя"}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Прошлый запуск записал результаты a.md и b.md и завершился до обновления
+	// списка исключений; b.md после этого изменился
+	for name, text := range files {
+		output := []byte("Обогащенный текст " + name)
+		if err := os.WriteFile(filepath.Join(outputDir, name), output, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := saveIntent(stateDir, outputIntent{Key: name, Input: filepath.Join(inputDir, name),
+			Output: filepath.Join(outputDir, name), InputHash: contentHash([]byte(text)), OutputHash: contentHash(output)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "b.md"), []byte("# Новый текст"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requested = append(requested, string(body))
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: stateDir, WriteQueue: 2,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	// a.md досохранен без запроса к API, b.md обработан заново
+	if len(requested) != 1 || !strings.Contains(requested[0], "Новый текст") {
+		t.Errorf("ожидался один запрос для измененного b.md, получено %d", len(requested))
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "a.md")); string(data) != "Обогащенный текст a.md" {
+		t.Errorf("результат a.md перезаписан: %q", data)
+	}
+	if cfg, _ := os.ReadFile(configPath); !strings.Contains(string(cfg), "a.md") || !strings.Contains(string(cfg), "b.md") {
+		t.Errorf("список исключений не обновлен:\n%s", cfg)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(stateDir, intentsDirName)); len(entries) != 0 {
+		t.Errorf("после запуска остались намерения записи: %d", len(entries))
+	}
+}
@@ -0,0 +1,47 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// Чтение пароля из связки ключей macOS
+func keychainGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", errorf("запись %s/%s не найдена в связке ключей: %v", service, account, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// Сохранение пароля в связке ключей macOS (существующая запись обновляется).
+// Команда передается интерактивному режиму security через стандартный ввод, чтобы
+// пароль не попал в аргументы процесса, видные другим пользователям в ps
+func keychainSet(service, account, secret string) error {
+	if strings.ContainsAny(secret, "\r\n") {
+		return errorf("секрет для связки ключей не может содержать перевод строки")
+	}
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader("add-generic-password -U -s " + securityQuote(service) + " -a " + securityQuote(account) +
+		" -w " + securityQuote(secret) + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// В интерактивном режиме security сообщает об ошибке команды в stderr, а код
+	// завершения может остаться нулевым
+	err := cmd.Run()
+	if msg := strings.TrimSpace(stderr.String()); err != nil || msg != "" {
+		if err == nil {
+			return errorf("ошибка программы security: %s", msg)
+		}
+		return errorf("ошибка программы security: %v: %s", err, msg)
+	}
+	return nil
+}
+
+// Аргумент команды интерактивного режима security в двойных кавычках
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
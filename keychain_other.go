@@ -0,0 +1,36 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// Чтение секрета через Secret Service (GNOME Keyring, KWallet) программой secret-tool
+func keychainGet(service, account string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", errorf("программа secret-tool не найдена: установите libsecret-tools")
+	}
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil || len(out) == 0 {
+		return "", errorf("запись %s/%s не найдена в хранилище Secret Service", service, account)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// Сохранение секрета через Secret Service; secret-tool читает значение из стандартного ввода
+func keychainSet(service, account, secret string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return errorf("программа secret-tool не найдена: установите libsecret-tools")
+	}
+	cmd := exec.Command("secret-tool", "store", "--label=rich "+service+"/"+account, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return errorf("ошибка программы secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Функции диспетчера учетных данных Windows (advapi32)
+var (
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// Константы CRED_* из wincred.h
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// CREDENTIALW
+type windowsCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// Имя учетных данных в диспетчере: <служба>/<учетная запись>
+func credentialTarget(service, account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + "/" + account)
+}
+
+// Чтение секрета из диспетчера учетных данных Windows (универсальные учетные данные)
+func keychainGet(service, account string) (string, error) {
+	target, err := credentialTarget(service, account)
+	if err != nil {
+		return "", err
+	}
+	var cred *windowsCredential
+	if r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		return "", errorf("запись %s/%s не найдена в диспетчере учетных данных: %v", service, account, err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// Сохранение секрета в диспетчере учетных данных Windows
+func keychainSet(service, account, secret string) error {
+	target, err := credentialTarget(service, account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := windowsCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     unsafe.SliceData(blob),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return errorf("ошибка диспетчера учетных данных: %v", err)
+	}
+	return nil
+}
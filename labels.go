@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Оценки результатов при проверке
+const (
+	LabelAccepted = "accepted"
+	LabelRejected = "rejected"
+)
+
+// Файл оценок в каталоге состояния
+const labelsFileName = "labels.json"
+
+// Оценка результата обогащения проверяющим. Оценка относится к результату
+// конкретного запуска: после повторного обогащения файла она устаревает
+type outputLabel struct {
+	Label    string    `json:"label"`
+	Note     string    `json:"note,omitempty"`
+	Reviewer string    `json:"reviewer,omitempty"`
+	At       time.Time `json:"at"`
+	RunID    string    `json:"run_id"`
+}
+
+// Оценка относится к последнему результату файла
+func (l outputLabel) current(rec outputRecord) bool {
+	return l.Label != "" && l.RunID == rec.RunID
+}
+
+// Путь файла оценок
+func labelsPath(stateDir string) string {
+	return filepath.Join(stateDir, labelsFileName)
+}
+
+// Оценки по относительным путям исходных файлов (пустой набор, если оценок нет)
+func loadLabels(stateDir string) (map[string]outputLabel, error) {
+	labels := make(map[string]outputLabel)
+	data, err := os.ReadFile(labelsPath(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return labels, nil
+		}
+		return nil, errorf("не удалось прочитать оценки результатов: %v", err)
+	}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, errorf("некорректный файл оценок %s: %v", labelsPath(stateDir), err)
+	}
+	return labels, nil
+}
+
+// Запись оценок под блокировкой; пустая оценка удаляет запись
+func saveLabels(stateDir string, changes map[string]outputLabel) error {
+	path := labelsPath(stateDir)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return errorf("не удалось сохранить оценки результатов: %v", err)
+	}
+	return withFileLock(path, func() error {
+		labels, err := loadLabels(stateDir)
+		if err != nil {
+			return err
+		}
+		for rel, l := range changes {
+			if l.Label == "" {
+				delete(labels, rel)
+			} else {
+				labels[rel] = l
+			}
+		}
+		data, err := json.MarshalIndent(labels, "", "  ")
+		if err != nil {
+			return errorf("не удалось сохранить оценки результатов: %v", err)
+		}
+		if err := safeWriteFile(path, data, 0644); err != nil {
+			return errorf("не удалось сохранить оценки результатов: %v", err)
+		}
+		return nil
+	})
+}
+
+// Действующие оценки: только оценки последних результатов
+func currentLabels(stateDir string, latest map[string]outputRecord) (map[string]outputLabel, error) {
+	labels, err := loadLabels(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	for rel, l := range labels {
+		if rec, ok := latest[rel]; !ok || !l.current(rec) {
+			delete(labels, rel)
+		}
+	}
+	return labels, nil
+}
+
+// Копия оценок в базе данных запусков для запросов rich db query
+func mirrorLabels(config *Config, changes map[string]outputLabel) error {
+	var b strings.Builder
+	b.WriteString(runDBSchema)
+	b.WriteString("BEGIN;\n")
+	for rel, l := range changes {
+		if l.Label == "" {
+			fmt.Fprintf(&b, "DELETE FROM labels WHERE path = %s;\n", sqlQuote(rel))
+			continue
+		}
+		fmt.Fprintf(&b, "INSERT OR REPLACE INTO labels VALUES (%s, %s, %s, %s, %s, %s);\n",
+			sqlQuote(rel), sqlQuote(l.Label), sqlQuote(l.Note), sqlQuote(l.Reviewer), sqlQuote(l.RunID),
+			sqlQuote(l.At.Format(time.RFC3339)))
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := execSQLite(config, b.String())
+	return err
+}
+
+// Имя проверяющего по умолчанию - пользователь системы
+func defaultReviewer() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return os.Getenv("USERNAME")
+}
+
+// rich label accept|reject|clear [--note text] [--reviewer name] <путь...>,
+// rich label list [--label accepted|rejected], rich label stats: оценки результатов
+// при проверке и их итоги по вариантам промпта
+func runLabelCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errorf("укажите действие: rich label accept, reject, clear, list или stats")
+	}
+	action := args[0]
+	label := ""
+	switch action {
+	case "accept":
+		label = LabelAccepted
+	case "reject":
+		label = LabelRejected
+	case "clear":
+	case "list":
+		return runLabelListCommand(args[1:], out)
+	case "stats":
+		return runLabelStatsCommand(args[1:], out)
+	default:
+		return errorf("неизвестное действие %q: ожидалось accept, reject, clear, list или stats", action)
+	}
+
+	fs := flag.NewFlagSet("label "+action, flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	note := fs.String("note", "", tr("Комментарий к оценке"))
+	reviewer := fs.String("reviewer", defaultReviewer(), tr("Имя проверяющего"))
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errorf("укажите пути исходных файлов")
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+
+	now := time.Now().UTC()
+	changes := make(map[string]outputLabel)
+	for _, arg := range fs.Args() {
+		rel := normalizeRelPath(arg)
+		rec, ok := latest[rel]
+		if !ok {
+			return errorf("нет результата обогащения для %s", arg)
+		}
+		changes[rel] = outputLabel{}
+		if label != "" {
+			changes[rel] = outputLabel{Label: label, Note: *note, Reviewer: *reviewer, At: now, RunID: rec.RunID}
+		}
+	}
+	if err := saveLabels(config.StateDir, changes); err != nil {
+		return err
+	}
+	if config.DatabaseFile != "" {
+		if err := mirrorLabels(config, changes); err != nil {
+			warnf("Оценки не записаны в базу данных: %v", err)
+		}
+	}
+	if label == "" {
+		fmt.Fprintf(out, tr("Оценки сняты: %d\n"), len(changes))
+	} else {
+		fmt.Fprintf(out, tr("Оценка %s сохранена: %d\n"), label, len(changes))
+	}
+	return nil
+}
+
+// rich label list: действующие оценки последних результатов
+func runLabelListCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("label list", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	only := fs.String("label", "", tr("Только результаты с указанной оценкой"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+	labels, err := currentLabels(config.StateDir, latest)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(labels))
+	for rel, l := range labels {
+		if *only == "" || l.Label == *only {
+			paths = append(paths, rel)
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(out, tr("Нет оценок"))
+		return nil
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		l := labels[rel]
+		line := fmt.Sprintf("%-8s  %s  %s", l.Label, l.At.Local().Format("2006-01-02 15:04"), rel)
+		if l.Reviewer != "" {
+			line += "  (" + l.Reviewer + ")"
+		}
+		if l.Note != "" {
+			line += ": " + l.Note
+		}
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLabelCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"a.md": "# A\n\nОригинал A", "b.md": "# B\n\nОригинал B", "c.md": "# C\n\nОригинал C"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfgText := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n\n[STATE]\ndir = " + stateDir +
+		"\n\n[EXCLUSIONS]\nexcluded_files =\n"
+	if err := os.WriteFile(configPath, []byte(cfgText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: stateDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runLabelCommand([]string{"accept", "-config", configPath, "-reviewer", "anna", "a.md"}, &out); err != nil {
+		t.Fatalf("rich label accept вернул ошибку: %v", err)
+	}
+	if err := runLabelCommand([]string{"reject", "-config", configPath, "-note", "потерян пример", "b.md", "c.md"}, &out); err != nil {
+		t.Fatalf("rich label reject вернул ошибку: %v", err)
+	}
+	if err := runLabelCommand([]string{"clear", "-config", configPath, "c.md"}, &out); err != nil {
+		t.Fatalf("rich label clear вернул ошибку: %v", err)
+	}
+	if err := runLabelCommand([]string{"accept", "-config", configPath, "missing.md"}, &out); err == nil {
+		t.Error("ожидалась ошибка для файла без результата")
+	}
+
+	out.Reset()
+	if err := runLabelCommand([]string{"list", "-config", configPath, "-label", LabelRejected}, &out); err != nil {
+		t.Fatalf("rich label list вернул ошибку: %v", err)
+	}
+	if list := out.String(); !strings.Contains(list, "b.md") || !strings.Contains(list, "потерян пример") ||
+		strings.Contains(list, "a.md") || strings.Contains(list, "c.md") {
+		t.Errorf("список отклоненных результатов:\n%s", list)
+	}
+
+	var buf bytes.Buffer
+	if count, err := exportDataset(config, datasetFilter{Labeled: true}, &buf); err != nil || count != 2 {
+		t.Errorf("ожидалось 2 оцененные пары, получено %d (%v)", count, err)
+	}
+	buf.Reset()
+	if count, _ := exportDataset(config, datasetFilter{Label: LabelAccepted}, &buf); count != 1 ||
+		!strings.Contains(buf.String(), `"label":"accepted"`) || !strings.Contains(buf.String(), `"path":"a.md"`) {
+		t.Errorf("принятые пары (%d):\n%s", count, buf.String())
+	}
+
+	siteDir := filepath.Join(tmpDir, "site")
+	if _, err := exportSite(config, nil, siteDir); err != nil {
+		t.Fatalf("exportSite() вернул ошибку: %v", err)
+	}
+	page, _ := os.ReadFile(filepath.Join(siteDir, "files", "b.html"))
+	if !strings.Contains(string(page), `<b class="rejected">rejected</b>`) || !strings.Contains(string(page), "rich label accept b.md") {
+		t.Errorf("страница документа без оценки и команд:\n%s", page)
+	}
+
+	// После повторного обогащения оценка устаревает
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# A\n\nНовый оригинал A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	buf.Reset()
+	if count, _ := exportDataset(config, datasetFilter{Label: LabelAccepted}, &buf); count != 0 {
+		t.Errorf("оценка прежнего результата не должна применяться к новому: %s", buf.String())
+	}
+}
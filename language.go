@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Названия поддерживаемых языков для подстановки в промпт
+var languageNames = map[string]string{
+	"ru": "Russian",
+	"uk": "Ukrainian",
+	"en": "English",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+	"pt": "Portuguese",
+	"zh": "Chinese",
+	"ja": "Japanese",
+	"ko": "Korean",
+}
+
+// Частые служебные слова языков с латинской письменностью
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "in", "that", "it", "for", "with", "this", "are"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "ein", "eine", "auf", "für", "ich"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "pour", "dans", "que", "pas", "avec"},
+	"es": {"el", "los", "las", "y", "es", "por", "una", "para", "con", "que", "del", "como"},
+	"it": {"il", "di", "che", "è", "per", "una", "non", "sono", "con", "della", "gli", "anche"},
+	"pt": {"o", "os", "e", "não", "uma", "para", "com", "que", "do", "da", "em", "são"},
+}
+
+// Определение языка документа по алфавиту и частым словам (код ISO 639-1, "" если не определен)
+func detectLanguage(text string) string {
+	text = stripCodeFences(text)
+
+	var cyrillic, latin, han, kana, hangul int
+	ukrainian := 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case kana > 0 && kana+han >= latin && kana+han >= cyrillic:
+		return "ja"
+	case hangul > latin && hangul > cyrillic:
+		return "ko"
+	case han > latin && han > cyrillic:
+		return "zh"
+	case cyrillic > 0 && cyrillic >= latin:
+		if ukrainian*50 >= cyrillic {
+			return "uk"
+		}
+		return "ru"
+	case latin > 0:
+		return detectLatinLanguage(text)
+	}
+	return ""
+}
+
+// Определение языка с латинской письменностью по частоте служебных слов
+func detectLatinLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		counts[word]++
+	}
+
+	best, bestScore := "en", 0
+	for _, lang := range []string{"en", "de", "fr", "es", "it", "pt"} {
+		score := 0
+		for _, w := range latinStopwords[lang] {
+			score += counts[w]
+		}
+		if score > bestScore {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
+
+// Удаление блоков кода, чтобы код не влиял на определение языка
+func stripCodeFences(text string) string {
+	var b strings.Builder
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// Выбор промпта для языка документа: вариант из секции [PROMPT.<lang>] или общий промпт
+// с подстановкой {{language}} (код языка) и {{language_name}} (название языка)
+func promptForLanguage(config *Config, lang string) string {
+	prompt := config.Prompt
+	if variant, ok := config.LanguagePrompts[lang]; ok {
+		prompt = variant
+	}
+
+	name := languageNames[lang]
+	if lang == "" {
+		lang, name = "unknown", "the document's original language"
+	}
+	prompt = strings.ReplaceAll(prompt, "{{language}}", lang)
+	prompt = strings.ReplaceAll(prompt, "{{language_name}}", name)
+	return prompt
+}
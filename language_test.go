@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"Русский", "# Заметка\n\nЭто тестовый документ о настройке сервера и сети.", "ru"},
+		{"Украинский", "Це тестовий документ про налаштування її мережі та інші речі.", "uk"},
+		{"Английский", "This is the note about the setup of the server and the network.", "en"},
+		{"Немецкий", "Das ist die Notiz über die Einrichtung und der Server ist nicht mit dem Netz verbunden.", "de"},
+		{"Французский", "Le document est une note pour la configuration et les réseaux dans le centre.", "fr"},
+		{"Японский", "これはサーバーの設定に関するメモです。", "ja"},
+		{"Код не влияет", "Это заметка.\n```go\nfunc main() { fmt.Println(\"the and is of to in\") }\n```\n", "ru"},
+		{"Пустой текст", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectLanguage(tc.text); got != tc.expected {
+				t.Errorf("detectLanguage() = %q, ожидалось %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestPromptForLanguage(t *testing.T) {
+	config := &Config{
+		Prompt:          "Answer in {{language_name}} ({{language}}).",
+		LanguagePrompts: map[string]string{"ru": "Отвечай на русском."},
+	}
+
+	if got := promptForLanguage(config, "ru"); got != "Отвечай на русском." {
+		t.Errorf("Ожидался вариант промпта для ru, получено %q", got)
+	}
+	if got := promptForLanguage(config, "en"); got != "Answer in English (en)." {
+		t.Errorf("Ожидалась подстановка языка в общий промпт, получено %q", got)
+	}
+	if got := promptForLanguage(config, ""); got != "Answer in the document's original language (unknown)." {
+		t.Errorf("Некорректная подстановка для неизвестного языка: %q", got)
+	}
+}
+
+func TestLoadConfigLanguagePrompts(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	content := "[DIRECTORIES]\noutput_dir = " + filepath.Join(tmpDir, "out") +
+		"\n\n[PROMPT]\ntext = Общий\n\n[PROMPT.en]\ntext = English prompt\n\n[PROMPT.RU]\ntext = Русский промпт\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.LanguagePrompts["en"] != "English prompt" || config.LanguagePrompts["ru"] != "Русский промпт" {
+		t.Errorf("Некорректные языковые варианты промпта: %v", config.LanguagePrompts)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Маркер битой ссылки в обогащенном документе
+const BrokenLinkMarker = "<!-- rich:broken-link -->"
+
+// Битая ссылка в обогащенном документе
+type brokenLink struct {
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+	// Ссылка отсутствует в оригинале и добавлена моделью
+	Introduced bool `json:"introduced"`
+	// Ссылка, добавленная моделью, удалена из документа (остался текст ссылки)
+	Stripped bool `json:"stripped,omitempty"`
+}
+
+var (
+	// Ссылки на сноски [^1] и определения сносок [^1]: ...
+	footnoteRefRe = regexp.MustCompile(`\[\^([^\]\s]+)\](:?)`)
+	// Ссылка markdown с текстом и целью
+	markdownLinkTextRe = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	// Вики-ссылка с именем заметки и псевдонимом
+	wikiLinkAliasRe = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+)
+
+// Проверка ссылок обогащенных документов
+type linkChecker struct {
+	inputDir  string
+	outputDir string
+	external  bool
+	client    *http.Client
+	// Внешние ссылки, которых нет в оригинале, считаются выдуманными моделью
+	flagNewURLs bool
+
+	// Индекс документов входного дерева для вики-ссылок, строится при первой проверке
+	resolverOnce sync.Once
+	resolver     *linkResolver
+
+	mu sync.Mutex
+	// Результаты проверки внешних URL за запуск ("" - ссылка доступна)
+	cache map[string]string
+}
+
+// Создание проверки ссылок для входного и выходного деревьев
+func newLinkChecker(inputDir, outputDir string, external bool, timeout time.Duration) *linkChecker {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &linkChecker{
+		inputDir:  inputDir,
+		outputDir: outputDir,
+		external:  external,
+		client:    &http.Client{Timeout: timeout},
+		cache:     make(map[string]string),
+	}
+}
+
+// Извлечение целей ссылок markdown вне блоков кода
+func extractLinkTargets(text string) []string {
+	return extractOutsideFences(text, markdownLinkRe)
+}
+
+// Первые группы совпадений регулярного выражения вне блоков кода
+func extractOutsideFences(text string, re *regexp.Regexp) []string {
+	var targets []string
+	lines := strings.Split(text, "\n")
+	forEachOutsideFences(lines, func(i int) {
+		for _, m := range re.FindAllStringSubmatch(lines[i], -1) {
+			targets = append(targets, m[1])
+		}
+	})
+	return targets
+}
+
+// Проверка ссылок обогащенного документа, который будет записан в outputPath:
+// ссылки markdown, вики-ссылки и сноски
+func (c *linkChecker) Check(enriched, original, outputPath string) []brokenLink {
+	known := make(map[string]bool)
+	for _, target := range extractLinkTargets(original) {
+		known[target] = true
+	}
+	for _, name := range extractOutsideFences(original, wikiLinkRe) {
+		known[wikiTarget(name)] = true
+	}
+
+	var broken []brokenLink
+	seen := make(map[string]bool)
+	for _, target := range extractLinkTargets(enriched) {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		reason := c.checkTarget(enriched, outputPath, target)
+		if reason == "" && c.flagNewURLs && !known[target] && isExternalLink(target) {
+			reason = tr("внешняя ссылка отсутствует в оригинале")
+		}
+		if reason != "" {
+			broken = append(broken, brokenLink{Target: target, Reason: reason, Introduced: !known[target]})
+		}
+	}
+	for _, name := range extractOutsideFences(enriched, wikiLinkRe) {
+		target := wikiTarget(name)
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		if !c.wikiExists(name) {
+			broken = append(broken, brokenLink{Target: target, Reason: tr("заметка не найдена"), Introduced: !known[target]})
+		}
+	}
+	for _, ref := range undefinedFootnotes(enriched) {
+		target := "[^" + ref + "]"
+		broken = append(broken, brokenLink{Target: target, Reason: tr("сноска без определения"), Introduced: !strings.Contains(original, target)})
+	}
+	return broken
+}
+
+// Цель вики-ссылки в отчете
+func wikiTarget(name string) string {
+	return "[[" + strings.TrimSpace(name) + "]]"
+}
+
+// Внешняя ссылка http(s)
+func isExternalLink(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// Проверка, что вики-ссылка указывает на заметку входного дерева
+func (c *linkChecker) wikiExists(name string) bool {
+	c.resolverOnce.Do(func() {
+		resolver, err := newLinkResolver(c.inputDir)
+		if err != nil {
+			logf("Предупреждение: не удалось проиндексировать входную директорию для вики-ссылок: %v", err)
+			return
+		}
+		c.resolver = resolver
+	})
+	if c.resolver == nil {
+		// Без индекса вики-ссылки не проверяются
+		return true
+	}
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".md")
+	if strings.Contains(name, "/") {
+		_, err := os.Stat(filepath.Join(c.resolver.root, filepath.FromSlash(name)+".md"))
+		return err == nil
+	}
+	return len(c.resolver.byName[strings.ToLower(name)]) > 0
+}
+
+// Ссылки на сноски без определений
+func undefinedFootnotes(text string) []string {
+	defined := make(map[string]bool)
+	var refs []string
+	lines := strings.Split(text, "\n")
+	forEachOutsideFences(lines, func(i int) {
+		for _, m := range footnoteRefRe.FindAllStringSubmatchIndex(lines[i], -1) {
+			id := lines[i][m[2]:m[3]]
+			if m[5] > m[4] && strings.TrimSpace(lines[i][:m[0]]) == "" {
+				defined[id] = true
+				continue
+			}
+			refs = append(refs, id)
+		}
+	})
+	var undefined []string
+	for _, id := range refs {
+		if !defined[id] && !slices.Contains(undefined, id) {
+			undefined = append(undefined, id)
+		}
+	}
+	return undefined
+}
+
+// Проверка одной ссылки; возвращает причину, если ссылка не разрешается
+func (c *linkChecker) checkTarget(doc, outputPath, target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return tr("некорректная ссылка")
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if !c.external {
+			return ""
+		}
+		return c.checkExternal(target)
+	case "":
+	default:
+		// mailto:, tel: и другие схемы не проверяются
+		return ""
+	}
+
+	if u.Path == "" {
+		if u.Fragment != "" && !hasAnchor(doc, u.Fragment) {
+			return tr("якорь не найден")
+		}
+		return ""
+	}
+
+	// Абсолютные пути отсчитываются от корня выходного дерева
+	var outTarget string
+	if strings.HasPrefix(u.Path, "/") {
+		outTarget = filepath.Join(c.outputDir, filepath.FromSlash(u.Path))
+	} else {
+		outTarget = filepath.Join(filepath.Dir(outputPath), filepath.FromSlash(u.Path))
+	}
+	rel, err := filepath.Rel(c.outputDir, outTarget)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return tr("ссылка ведет за пределы выходного дерева")
+	}
+	if _, err := os.Stat(outTarget); err == nil {
+		return ""
+	}
+	// Файл может быть еще не обработан, но присутствовать во входном дереве
+	if _, err := os.Stat(filepath.Join(c.inputDir, rel)); err == nil {
+		return ""
+	}
+	return tr("файл не найден")
+}
+
+// Проверка наличия якоря среди заголовков и явных якорей документа
+func hasAnchor(doc, fragment string) bool {
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	fragment = strings.ToLower(fragment)
+	lines := strings.Split(doc, "\n")
+	for _, h := range collectHeadings(lines) {
+		if h.Slug == fragment {
+			return true
+		}
+	}
+	for _, line := range lines {
+		if m := anchorLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && strings.ToLower(m[1]) == fragment {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверка внешнего URL запросом HEAD (GET, если сервер не поддерживает HEAD)
+func (c *linkChecker) checkExternal(target string) string {
+	c.mu.Lock()
+	reason, ok := c.cache[target]
+	c.mu.Unlock()
+	if ok {
+		return reason
+	}
+
+	status, err := c.request(http.MethodHead, target)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusForbidden || status == http.StatusNotImplemented) {
+		status, err = c.request(http.MethodGet, target)
+	}
+	switch {
+	case err != nil:
+		reason = trf("ошибка запроса: %v", err)
+	case status >= 400:
+		reason = fmt.Sprintf("HTTP %d", status)
+	}
+
+	c.mu.Lock()
+	c.cache[target] = reason
+	c.mu.Unlock()
+	return reason
+}
+
+// Выполнение запроса к внешнему URL и получение кода ответа
+func (c *linkChecker) request(method, target string) (int, error) {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	setUserAgent(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Пометка битых ссылок маркером в обогащенном документе
+func annotateBrokenLinks(text string, broken []brokenLink) string {
+	if len(broken) == 0 {
+		return text
+	}
+	bad := make(map[string]bool, len(broken))
+	for _, b := range broken {
+		bad[b.Target] = true
+	}
+
+	lines := strings.Split(text, "\n")
+	forEachOutsideFences(lines, func(i int) {
+		line := lines[i]
+		var b strings.Builder
+		last := 0
+		for _, m := range markdownLinkRe.FindAllStringSubmatchIndex(line, -1) {
+			if !bad[line[m[2]:m[3]]] {
+				continue
+			}
+			b.WriteString(line[last:m[1]])
+			last = m[1]
+			if !strings.HasPrefix(strings.TrimLeft(line[m[1]:], " "), BrokenLinkMarker) {
+				b.WriteString(" " + BrokenLinkMarker)
+			}
+		}
+		b.WriteString(line[last:])
+		lines[i] = b.String()
+	})
+	return strings.Join(lines, "\n")
+}
+
+// Удаление добавленных моделью битых ссылок: от ссылки остается ее текст (для
+// вики-ссылки - псевдоним или имя заметки), ссылка на сноску удаляется
+func stripIntroducedLinks(text string, broken []brokenLink) string {
+	bad := make(map[string]bool)
+	for i := range broken {
+		if broken[i].Introduced {
+			bad[broken[i].Target] = true
+			broken[i].Stripped = true
+		}
+	}
+	if len(bad) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	forEachOutsideFences(lines, func(i int) {
+		lines[i] = markdownLinkTextRe.ReplaceAllStringFunc(lines[i], func(link string) string {
+			m := markdownLinkTextRe.FindStringSubmatch(link)
+			if !bad[m[2]] {
+				return link
+			}
+			return m[1]
+		})
+		lines[i] = wikiLinkAliasRe.ReplaceAllStringFunc(lines[i], func(link string) string {
+			m := wikiLinkAliasRe.FindStringSubmatch(link)
+			if !bad[wikiTarget(m[1])] {
+				return link
+			}
+			if alias := strings.TrimSpace(m[2]); alias != "" {
+				return alias
+			}
+			return strings.TrimSpace(m[1])
+		})
+		lines[i] = footnoteRefRe.ReplaceAllStringFunc(lines[i], func(ref string) string {
+			if strings.HasSuffix(ref, ":") || !bad[ref] {
+				return ref
+			}
+			return ""
+		})
+	})
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkCheckerLocalLinks(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "todo")
+	outputDir := filepath.Join(root, "done")
+	for _, dir := range []string{filepath.Join(inputDir, "docs"), filepath.Join(outputDir, "docs")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Ошибка создания директории: %v", err)
+		}
+	}
+	// Обработанный файл есть в выходном дереве, необработанный - только во входном
+	if err := os.WriteFile(filepath.Join(outputDir, "docs", "done.md"), []byte("# Done"), 0644); err != nil {
+		t.Fatalf("Ошибка записи файла: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "pending.md"), []byte("# Pending"), 0644); err != nil {
+		t.Fatalf("Ошибка записи файла: %v", err)
+	}
+
+	original := "# Заметка\n\n[старая](missing-old.md)\n"
+	enriched := "# Заметка\n\n## Раздел\n\n" +
+		"[готово](done.md) [ожидает](../pending.md#x) [раздел](#раздел) [нет раздела](#нет)\n" +
+		"[старая](missing-old.md) [новая](missing-new.md) [наружу](../../outside.md) [почта](mailto:a@b.c)\n" +
+		"```\n[в коде](code.md)\n```\n"
+
+	checker := newLinkChecker(inputDir, outputDir, false, time.Second)
+	broken := checker.Check(enriched, original, filepath.Join(outputDir, "docs", "note.md"))
+
+	got := make(map[string]brokenLink)
+	for _, b := range broken {
+		got[b.Target] = b
+	}
+	if len(got) != 4 {
+		t.Fatalf("Ожидалось 4 битые ссылки, получено %d: %+v", len(got), broken)
+	}
+	if b, ok := got["missing-old.md"]; !ok || b.Introduced {
+		t.Errorf("Ссылка из оригинала должна быть битой, но не добавленной моделью: %+v", b)
+	}
+	if b, ok := got["missing-new.md"]; !ok || !b.Introduced {
+		t.Errorf("Новая ссылка должна быть отмечена как добавленная моделью: %+v", b)
+	}
+	if _, ok := got["#нет"]; !ok {
+		t.Error("Несуществующий якорь не обнаружен")
+	}
+	if _, ok := got["../../outside.md"]; !ok {
+		t.Error("Ссылка за пределы выходного дерева не обнаружена")
+	}
+
+	annotated := annotateBrokenLinks(enriched, broken)
+	if !strings.Contains(annotated, "[новая](missing-new.md) "+BrokenLinkMarker) {
+		t.Errorf("Битая ссылка не помечена:\n%s", annotated)
+	}
+	if strings.Contains(annotated, "[готово](done.md) "+BrokenLinkMarker) {
+		t.Error("Рабочая ссылка помечена как битая")
+	}
+	if again := annotateBrokenLinks(annotated, broken); again != annotated {
+		t.Errorf("Повторная пометка не должна дублировать маркеры:\n%s", again)
+	}
+}
+
+func TestLinkCheckerExternalLinks(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	enriched := "[a](" + server.URL + "/ok) [b](" + server.URL + "/get-only) [c](" + server.URL + "/gone)"
+
+	// Без external внешние ссылки не проверяются
+	if broken := newLinkChecker(dir, dir, false, time.Second).Check(enriched, "", filepath.Join(dir, "x.md")); len(broken) != 0 {
+		t.Errorf("Внешние ссылки не должны проверяться: %+v", broken)
+	}
+	if requests != 0 {
+		t.Errorf("Выполнено %d запросов при выключенной проверке", requests)
+	}
+
+	checker := newLinkChecker(dir, dir, true, time.Second)
+	broken := checker.Check(enriched, "", filepath.Join(dir, "x.md"))
+	if len(broken) != 1 || broken[0].Target != server.URL+"/gone" || broken[0].Reason != "HTTP 404" {
+		t.Fatalf("Ожидалась одна битая ссылка /gone, получено %+v", broken)
+	}
+
+	// Повторная проверка использует кэш
+	before := requests
+	checker.Check(enriched, "", filepath.Join(dir, "y.md"))
+	if requests != before {
+		t.Errorf("Повторная проверка выполнила %d запросов вместо использования кэша", requests-before)
+	}
+}
+
+func TestLinkCheckerFabricatedReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Проект.md"), []byte("# Проект"), 0644); err != nil {
+		t.Fatalf("Ошибка записи файла: %v", err)
+	}
+	original := "# Заметка\n\nСм. [[Проект]] и [сайт](https://example.com).\n"
+	enriched := "# Заметка\n\nСм. [[Проект]], [[Выдуманная заметка|обзор]] и [сайт](https://example.com).\n" +
+		"Исследование [Smith 2021](https://journal.example.org/smith)[^1] подтверждает это[^2].\n\n[^2]: Определение.\n"
+
+	checker := newLinkChecker(dir, dir, false, time.Second)
+	checker.flagNewURLs = true
+	broken := checker.Check(enriched, original, filepath.Join(dir, "note.md"))
+	got := make(map[string]brokenLink)
+	for _, b := range broken {
+		got[b.Target] = b
+	}
+	for _, target := range []string{"[[Выдуманная заметка]]", "https://journal.example.org/smith", "[^1]"} {
+		if b, ok := got[target]; !ok || !b.Introduced {
+			t.Errorf("Выдуманная ссылка %s не обнаружена: %+v", target, broken)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("Ожидалось 3 выдуманные ссылки, получено %+v", broken)
+	}
+
+	stripped := stripIntroducedLinks(enriched, broken)
+	want := "# Заметка\n\nСм. [[Проект]], обзор и [сайт](https://example.com).\n" +
+		"Исследование Smith 2021 подтверждает это[^2].\n\n[^2]: Определение.\n"
+	if stripped != want {
+		t.Errorf("Ожидалось:\n%s\nполучено:\n%s", want, stripped)
+	}
+	if !broken[0].Stripped {
+		t.Error("Удаленная ссылка должна быть отмечена в отчете")
+	}
+}
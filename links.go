@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Ссылки markdown вида [текст](путь)
+	markdownLinkRe = regexp.MustCompile(`\[[^\]]*\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	// Вики-ссылки вида [[Заметка]], [[Заметка|псевдоним]], [[Заметка#раздел]]
+	wikiLinkRe = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+)
+
+// Поиск документов входного дерева, на которые ссылается файл
+type linkResolver struct {
+	root string
+	// Пути markdown-файлов по имени без расширения (в нижнем регистре) для вики-ссылок
+	byName map[string][]string
+}
+
+// Создание индекса markdown-файлов входной директории для разрешения ссылок
+func newLinkResolver(root string) (*linkResolver, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	r := &linkResolver{root: root, byName: make(map[string][]string)}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+			name := strings.ToLower(strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())))
+			r.byName[name] = append(r.byName[name], path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Поиск путей документов, на которые ссылается содержимое файла
+func (r *linkResolver) Resolve(filePath, content string) []string {
+	absFile, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{absFile: true}
+	var targets []string
+
+	add := func(path string) {
+		if seen[path] || !r.inRoot(path) {
+			return
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return
+		}
+		seen[path] = true
+		targets = append(targets, path)
+	}
+
+	for _, m := range markdownLinkRe.FindAllStringSubmatch(content, -1) {
+		link := m[1]
+		if strings.Contains(link, "://") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "#") {
+			continue
+		}
+		link, _, _ = strings.Cut(link, "#")
+		if unescaped, err := url.PathUnescape(link); err == nil {
+			link = unescaped
+		}
+		if !strings.HasSuffix(strings.ToLower(link), ".md") {
+			continue
+		}
+		if filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+			add(filepath.Join(r.root, filepath.FromSlash(link)))
+		} else {
+			add(filepath.Join(filepath.Dir(absFile), filepath.FromSlash(link)))
+		}
+	}
+
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimSpace(m[1])
+		name = strings.TrimSuffix(name, ".md")
+		// Вики-ссылка с путем указывает на файл относительно корня
+		if strings.Contains(name, "/") {
+			add(filepath.Join(r.root, filepath.FromSlash(name)+".md"))
+			continue
+		}
+		for _, path := range r.byName[strings.ToLower(name)] {
+			add(path)
+		}
+	}
+
+	return targets
+}
+
+// Проверка, что путь находится внутри входной директории
+func (r *linkResolver) inRoot(path string) bool {
+	rel, err := filepath.Rel(r.root, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Выдержки из связанных документов (первые excerptWords слов без frontmatter)
+func (r *linkResolver) Excerpts(targets []string, excerptWords, maxDocs int) []contextChunk {
+	var chunks []contextChunk
+	for _, path := range targets {
+		if maxDocs > 0 && len(chunks) >= maxDocs {
+			break
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		_, body, _ := parseFrontmatter(data)
+		words := strings.Fields(string(body))
+		if len(words) == 0 {
+			continue
+		}
+		excerpt := strings.Join(words[:min(len(words), excerptWords)], " ")
+		if len(words) > excerptWords {
+			excerpt += " ..."
+		}
+		rel, err := filepath.Rel(r.root, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		chunks = append(chunks, contextChunk{Source: rel, Text: excerpt})
+	}
+	return chunks
+}
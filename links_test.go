@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkResolver(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		filepath.Join("docs", "note.md"): "См. [архитектуру](../arch.md#слои), [[Глоссарий|термины]], [[guides/setup]], " +
+			"[сайт](https://example.com/x.md), [картинку](img.png) и [[Отсутствует]].",
+		"arch.md": "---\ntitle: Архитектура\n---\nСистема состоит из трех слоев и шины событий.",
+		filepath.Join("misc", "Глоссарий.md"): "Шлюз - компонент приема платежей.",
+		filepath.Join("guides", "setup.md"):   "Установка выполняется одной командой.",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать файл %s: %v", name, err)
+		}
+	}
+	// Файл вне входной директории не должен попадать в контекст
+	outside := filepath.Join(filepath.Dir(root), "outside.md")
+	_ = os.WriteFile(outside, []byte("секрет"), 0644)
+	defer func() { _ = os.Remove(outside) }()
+
+	resolver, err := newLinkResolver(root)
+	if err != nil {
+		t.Fatalf("newLinkResolver() вернул ошибку: %v", err)
+	}
+
+	notePath := filepath.Join(root, "docs", "note.md")
+	content := files[filepath.Join("docs", "note.md")] + " [вне](../../outside.md)"
+	targets := resolver.Resolve(notePath, content)
+	if len(targets) != 3 {
+		t.Fatalf("Ожидалось 3 связанных документа, получено %d: %v", len(targets), targets)
+	}
+
+	excerpts := resolver.Excerpts(targets, 3, 2)
+	if len(excerpts) != 2 {
+		t.Fatalf("Ожидалось не более 2 выдержек, получено %d", len(excerpts))
+	}
+	if excerpts[0].Source != "arch.md" || excerpts[0].Text != "Система состоит из ..." {
+		t.Errorf("Некорректная выдержка: %+v", excerpts[0])
+	}
+	if strings.Contains(excerpts[0].Text, "title") {
+		t.Error("Frontmatter не должен попадать в выдержку")
+	}
+}
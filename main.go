@@ -2,52 +2,310 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"gopkg.in/ini.v1"
 )
 
-// Максимальный размер файла для обработки (10 МБ)
+// Максимальный размер файла для обработки по умолчанию (10 МБ)
 const MaxFileSize = 10 * 1024 * 1024
 
 // Ограничение количества запросов в минуту
 const RequestsPerMinute = 10
 
+// Размещение результатов в выходной директории (output_layout)
+const (
+	OutputLayoutFlat   = "flat"
+	OutputLayoutPerRun = "per-run"
+)
+
+// Формат имени директории запуска при output_layout = per-run
+const runDirLayout = "2006-01-02T15-04"
+
 // Конфигурация для процесса обогащения
 type Config struct {
-	InputDir      string
-	OutputDir     string
+	InputDir  string
+	OutputDir string
+	// Размещение результатов в выходной директории: flat - прямо в ней, per-run -
+	// в поддиректории запуска вида 2024-06-01T10-00
+	OutputLayout string
+	// Очередь проверки: результаты пишутся в эту директорию и переносятся в OutputDir
+	// командой rich approve ("" - результаты пишутся сразу в OutputDir)
+	ReviewDir string
+	// Источник входных файлов основного корня вместо входной директории: архив,
+	// ref git, S3, stdin ("" - входная директория)
+	InputSource string
+	// Дополнительные корни входных файлов ([DIRECTORIES.<имя>]) и имя обрабатываемого
+	// корня в копии конфигурации ("" - основной)
+	Roots         []inputRoot
+	RootName      string
 	ExcludedFiles []string
 	ModelName     string
 	ModelAPIURL   string
 	APIKey        string
 	Prompt        string
-	Temperature   float64
-	MaxTokens     int
+	// Проверка промпта перед запуском (error, warn или off) и доля max_tokens,
+	// которую может занимать промпт
+	PromptLint     string
+	MaxPromptRatio float64
+	Temperature    float64
+	MaxTokens      int
+	// Ядерная выборка top_p (0 - не отправляется) и стоп-последовательности
+	TopP float64
+	Stop []string
+	// max_tokens = auto: размер ответа и частей документа подбираются по контекстному окну модели
+	AutoMaxTokens bool
+	// Переопределение сведений о модели (0 - из встроенной таблицы)
+	ContextWindow   int
+	MaxOutputTokens int
+	// Потоковый ответ (server-sent events); поддерживается для Anthropic
+	Stream bool
+	// Модель с рассуждениями: "true", "false" или "" - по встроенной таблице
+	ReasoningModel string
+	// Усилие рассуждений reasoning_effort ("" - не отправляется)
+	ReasoningEffort string
+	// Провайдер API по имени или auto - по адресу api_url
+	Provider string
+	// Запросов в минуту (0 - значение провайдера или RequestsPerMinute)
+	RequestsPerMinute int
+	// Запас запросов, которые можно отправить подряд без паузы (0 - равен лимиту в минуту)
+	Burst int
+	// Окна обслуживания провайдера, во время которых файлы не отправляются
+	Maintenance maintenanceSchedule
+	// Токенов в минуту: оценка запроса вместе с max_tokens (0 - без локального ограничения)
+	TokensPerMinute int
+	// Vertex AI: проект, регион и файл учетных данных ("" - Application Default Credentials)
+	VertexProject   string
+	VertexLocation  string
+	GoogleCredsFile string
+	// Цена за 1 млн входных и выходных токенов в долларах
+	InputPrice  float64
+	OutputPrice float64
+	// Ограничения на один запуск (0 - без ограничений)
+	MaxFiles int
+	MaxUSD   float64
+	// Результаты сохраняются, только если все файлы запуска обработаны без ошибок (--transactional)
+	Transactional bool
+	// Пропуск файлов, обработанных по файлу состояния до прерывания запуска (--resume)
+	Resume bool
+	// Порядок обработки файлов и ключ frontmatter для порядка по приоритету
+	Order       string
+	PriorityKey string
+	// Правила приоритета очереди ([PRIORITY] и -priority)
+	Priorities []priorityRule
+	// Минимальный размер файла в байтах и словах для отправки в API
+	MinBytes int
+	MinWords int
+	// Фильтры по времени изменения входных файлов (нулевое значение - без фильтра)
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// Максимальная глубина обхода (1 - только файлы в корне input_dir, 0 - без ограничений)
+	MaxDepth int
+	// Директорий, читаемых одновременно при обходе (1 - последовательный обход)
+	WalkWorkers int
+	// Размер очереди между запросами к API и записью файлов (0 - запись без отдельного этапа)
+	WriteQueue int
+	// Файлов, обрабатываемых одновременно (1 - по одному)
+	Workers int
+	// Реакция на ошибки файлов: продолжать, остановиться сразу или после N ошибок
+	OnError failurePolicy
+	// Системных ошибок подряд с начала запуска до остановки (0 - не останавливать)
+	SystemicAfter int
+	// Часть файлов для обработки на этой машине (-shard K/N)
+	Shard shardSpec
+	// Сбрасывать записанные файлы и их директории на диск (применяется при загрузке)
+	Fsync bool
+	// Общие шаблоны исключений входного дерева: встроенные и ignore_patterns
+	IgnorePatterns []ignorePattern
+	// Суточный лимит запросов к API и токенов
+	DailyCap DailyCapConfig
+	// Эксперимент с промптами ([EXPERIMENT])
+	Experiment ExperimentConfig
+	// Режим наблюдения ([WATCH])
+	Watch WatchConfig
+	// Действие с результатом входного файла, удаленного во время наблюдения, и
+	// директория корзины ("" - trash в каталоге состояния)
+	OnDelete string
+	TrashDir string
+	// Порог сходства почти одинаковых документов (0 - проверка выключена) и действие с ними
+	DedupThreshold float64
+	DedupAction    string
+	// Варианты промпта по языку документа из секций [PROMPT.<lang>]
+	LanguagePrompts map[string]string
+	// Путь к JSON отчету о запуске ("" - не сохранять)
+	ReportFile string
+	// Путь к манифесту запуска для внешних систем сборки ("" - не сохранять)
+	RunManifestFile string
+	// База данных SQLite с историей запусков ("" - не ведется) и программа sqlite3
+	DatabaseFile string
+	SQLiteBinary string
+	// Рассылка итогов запуска по почте
+	Email EmailConfig
+	// Подбор заголовков и имен выходных файлов ([TITLES])
+	Titles TitleConfig
+	// Ссылки на связанные заметки ([RELATED])
+	Related RelatedConfig
+	// Дневниковые заметки и обзоры недели ([DAILY_NOTES])
+	DailyNotes DailyNotesConfig
+	// Управление обработкой полем статуса frontmatter ([WORKFLOW])
+	Workflow WorkflowConfig
+	// Проверочный файл перед запуском ([CANARY])
+	Canary CanaryConfig
+	// Карточки для интервального повторения ([FLASHCARDS])
+	Flashcards FlashcardConfig
+	// Проверенные источники ([CITATIONS])
+	Citations CitationConfig
+	// Список изменений документов ([CHANGELOG])
+	Changelog ChangelogConfig
+	// Руководство по стилю и механические правила ([STYLE])
+	Style StyleConfig
+	// Типографская обработка результата по правилам его языка ([TYPOGRAPHY])
+	Typography TypographyConfig
+	// Проверка орфографии результата через hunspell ([SPELLCHECK])
+	Spellcheck SpellcheckConfig
+	// Конвертация документов других форматов через pandoc ([PANDOC])
+	Pandoc PandocConfig
+	// Текст документов PDF ([PDF])
+	PDF PDFConfig
+	// Сжатые заметки markdown ([GZIP])
+	Gzip GzipConfig
+	// Распознавание текста заметок из одних изображений ([OCR])
+	OCR OCRConfig
+	// Оповещения о квоте ([ALERTS])
+	Alerts AlertConfig
+	// Общий лимит запросов и распределение файлов между экземплярами
+	Redis RedisConfig
+	// Директория с документами проекта для добавления контекста в промпт
+	ContextDir        string
+	ContextTopK       int
+	ContextChunkWords int
+	// Добавление выдержек из документов, на которые ссылается файл
+	LinkedDocs         bool
+	LinkedExcerptWords int
+	LinkedMaxDocs      int
+	// Источник векторных представлений для контекста и поиска
+	Embeddings EmbeddingConfig
+	// Параметры HTTP соединений ([NETWORK])
+	Network networkConfig
+	// Остановка обработки при недоступном API и повтор в режиме службы
+	Offline offlineConfig
+	// Приемники результатов: выходная директория, git, S3, стандартный вывод ([OUTPUT])
+	Sinks sinksConfig
+	// Подключение к хранилищу S3 для приемника и источника ([S3])
+	S3 s3Config
+	// Примеры обогащения, передаваемые модели перед документом
+	Examples []fewShotExample
+	// Контекст между частями документа, который обогащается по частям: режим и бюджет в токенах
+	ChunkContext       string
+	ChunkContextTokens int
+	// Наибольший размер части документа в токенах (0 - по контекстному окну модели)
+	MaxChunkTokens int
+	// Заголовки разделов, которые обогащаются вместо всего документа (например "## Summary")
+	SectionHeadings []string
+	// Повторное обогащение только измененных разделов ранее обработанных файлов
+	Incremental bool
+	// Действие с результатом, измененным вручную после обогащения: new или overwrite
+	OnConflict string
+	// Действие с конфликтными копиями синхронизации: skip или merge
+	ConflictCopies string
+	// Действие с файлом, обогащение которого не удалось: skip, original или cached
+	OnFailure string
+	// Режим обработки: full, outline (только оглавление) или skeleton (только заготовки)
+	Mode string
+	// Пакетная обработка: файлы не больше BatchMaxBytes (0 - выключено) отправляются
+	// по BatchSize в одном запросе
+	BatchMaxBytes int
+	BatchSize     int
+	// Максимальный размер файла (0 - MaxFileSize) и стратегия для файлов больше него
+	MaxFileSize int
+	Oversize    string
+	// Размер обогащенного текста, больше которого результат делится на части (0 - не делится)
+	MaxOutputSize int
+	// Маршруты выбора промпта и модели по путям и тегам
+	Routes []Route
+	// Локальная постобработка: нормализация заголовков, якоря, оглавление
+	NormalizeHeadings bool
+	HeadingAnchors    bool
+	TOC               bool
+	TOCMaxLevel       int
+	// Правила обработки ответа модели: вступления, замены, уровень заголовков, длина
+	OutputRules outputRules
+	// Проверка результата против оригинала: сходство и границы длины
+	Guard outputGuard
+	// Локальная политика содержимого: что нельзя отправлять во внешние API
+	Policy contentPolicy
+	// Проверка ссылок обогащенных документов
+	CheckLinks          bool
+	CheckExternalLinks  bool
+	AnnotateBrokenLinks bool
+	// Добавленные моделью битые ссылки удаляются, новые внешние ссылки считаются выдуманными
+	StripIntroducedLinks bool
+	FlagNewURLs          bool
+	LinkCheckTimeout     time.Duration
+	// Подпись о раскрытии использования ИИ в конце обогащенного документа
+	Disclosure         bool
+	DisclosureTemplate string
+	// Оглавление и JSON манифест выходной директории после запуска ("" - не создаются)
+	IndexFile     string
+	IndexManifest string
+	// Файл контрольных сумм выходной директории после запуска ("" - не создается)
+	ChecksumFile string
+	// Каталог состояния с журналами запусков ("" - журнал не ведется)
+	StateDir string
+	// Оператор запуска для общей выходной директории ([STATE] operator, "" - не
+	// записывается): журнал, отчет, frontmatter результатов и коммиты приемника git
+	Operator string
+	// Ограничение обработки набором файлов по ключам pathKey путей в общем состоянии
+	// (rich reenrich, режим наблюдения); nil - обрабатываются все файлы
+	OnlyFiles map[string]bool
+	// Язык журнала, ошибок и вывода подкоманд: ru, en или auto (по окружению)
+	UILanguage string
+	// Интервал сообщений о долгом ожидании ответа API (0 - выключены)
+	Heartbeat time.Duration
+	// Файл, для которого выполняются запросы (путь в общем состоянии запуска)
+	CurrentFile string
+	// Сведения о файле для подстановок промпта, шаблонов и команд
+	File fileContext
 }
 
 // Загрузка конфигурации из INI файла
 func loadConfig(configPath string) (*Config, error) {
-	// Проверка наличия файла конфигурации
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("файл конфигурации не найден: %s", configPath)
+	// Проверка наличия файла конфигурации; без файла конфигурация может быть
+	// задана целиком переменными окружения RICH_<СЕКЦИЯ>_<КЛЮЧ>
+	overrides := envConfigOverrides(os.Environ())
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && len(overrides) == 0 {
+		return nil, errorf("файл конфигурации не найден: %s", configPath)
 	}
 
-	// Загрузка INI файла
-	cfg, err := ini.Load(configPath)
+	// Загрузка INI файла с включенными базовыми конфигурациями
+	cfg, _, err := loadConfigSources(configPath, overrides)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось загрузить файл конфигурации: %v", err)
+		return nil, err
 	}
+	return parseConfig(cfg, configPath)
+}
+
+// Разбор загруженной конфигурации (относительные пути файлов промптов и примеров
+// берутся от каталога configPath); значения по умолчанию записываются в отсутствующие
+// ключи cfg, так что после разбора cfg содержит итоговую конфигурацию целиком
+func parseConfig(cfg *ini.File, configPath string) (*Config, error) {
+	var err error
 
 	// Инициализация конфигурации с настройками по умолчанию
 	config := &Config{
@@ -55,16 +313,32 @@ func loadConfig(configPath string) (*Config, error) {
 		OutputDir:   "./done",
 		Temperature: 0.7,
 		MaxTokens:   1000,
+		Order:       OrderAlphabetical,
+		PriorityKey: "priority",
+		ReportFile:  "rich.report.json",
+		StateDir:    ".rich",
 	}
 
 	// Чтение секции директорий
 	if dirSection := cfg.Section("DIRECTORIES"); dirSection != nil {
-		config.InputDir = dirSection.Key("input_dir").MustString("./todo")
-		config.OutputDir = dirSection.Key("output_dir").MustString("./done")
+		config.InputDir = stripLongPathPrefix(dirSection.Key("input_dir").MustString("./todo"))
+		config.OutputDir = stripLongPathPrefix(dirSection.Key("output_dir").MustString("./done"))
+		config.InputSource = strings.TrimSpace(dirSection.Key("input_source").String())
+		config.ReviewDir = stripLongPathPrefix(strings.TrimSpace(dirSection.Key("review_dir").String()))
+		config.OutputLayout = strings.ToLower(strings.TrimSpace(dirSection.Key("output_layout").MustString(OutputLayoutFlat)))
+		if config.OutputLayout != OutputLayoutFlat && config.OutputLayout != OutputLayoutPerRun {
+			return nil, withCategory(ErrorConfig, errorf("неизвестное значение output_layout: %s (ожидается flat или per-run)", config.OutputLayout))
+		}
+		if _, _, err := parseSourceSpec(config.InputSource); err != nil {
+			return nil, err
+		}
 	}
 
 	// Чтение секции исключений
 	if exclSection := cfg.Section("EXCLUSIONS"); exclSection != nil {
+		if config.IgnorePatterns, err = loadIgnorePatterns(exclSection); err != nil {
+			return nil, err
+		}
 		excludedStr := exclSection.Key("excluded_files").String()
 		if excludedStr != "" {
 			excluded := strings.Split(excludedStr, ",")
@@ -81,7 +355,38 @@ func loadConfig(configPath string) (*Config, error) {
 	// Чтение конфигурации модели
 	if modelSection := cfg.Section("MODEL"); modelSection != nil {
 		config.ModelName = modelSection.Key("name").MustString("gpt-3.5-turbo")
+		apiURLSet := modelSection.Key("api_url").String() != ""
 		config.ModelAPIURL = modelSection.Key("api_url").MustString("https://api.openai.com/v1/chat/completions")
+		config.Provider = strings.ToLower(modelSection.Key("provider").MustString(providerAuto))
+		named, ok := providerByName(config.Provider)
+		if !ok && config.Provider != providerAuto {
+			return nil, errorf("неизвестный провайдер %q: поддерживаются %s", config.Provider, strings.Join(providerNames(), ", "))
+		}
+		// Без api_url адрес берется у провайдера: заданного явно или по имени модели
+		if !apiURLSet {
+			if !ok {
+				named = providerForModel(config.ModelName)
+			}
+			if named != nil {
+				config.ModelAPIURL = named.DefaultURL
+			}
+		}
+
+		// Vertex AI: адрес зависит от региона, проект по умолчанию - из учетных данных
+		config.GoogleCredsFile = modelSection.Key("credentials_file").String()
+		config.VertexLocation = modelSection.Key("location").MustString(defaultVertexLocation)
+		config.VertexProject = modelSection.Key("project").String()
+		if p := config.provider(); p != nil && p.Name == providerVertex {
+			if !apiURLSet {
+				config.ModelAPIURL = vertexBaseURL(config.VertexLocation)
+			}
+			if config.VertexProject == "" {
+				config.VertexProject = os.Getenv("GOOGLE_CLOUD_PROJECT")
+			}
+			if config.VertexProject == "" {
+				config.VertexProject = googleCredentialsProject(config.GoogleCredsFile)
+			}
+		}
 
 		// Получение API ключа из переменной окружения в зависимости от провайдера
 		envKey := modelSection.Key("api_key_env").String()
@@ -92,554 +397,3023 @@ func loadConfig(configPath string) (*Config, error) {
 		// Затем, если указана переменная окружения, пробуем ее использовать
 		if envKey != "" && os.Getenv(envKey) != "" {
 			config.APIKey = os.Getenv(envKey)
-		} else if config.APIKey == "" {
-			// Автоматическое определение переменной окружения в зависимости от URL API
-			apiURL := strings.ToLower(config.ModelAPIURL)
-			switch {
-			case strings.Contains(apiURL, "openai"):
-				config.APIKey = os.Getenv("OPENAI_API_KEY")
-			case strings.Contains(apiURL, "openrouter"):
-				config.APIKey = os.Getenv("OPENROUTER_API_KEY")
-			case strings.Contains(apiURL, "anthropic"):
-				config.APIKey = os.Getenv("ANTHROPIC_API_KEY")
-			}
+		} else if p := config.provider(); config.APIKey == "" && p != nil {
+			// Переменная окружения провайдера (OPENAI_API_KEY, MISTRAL_API_KEY, ...)
+			config.APIKey = os.Getenv(p.KeyEnv)
+		}
+		// Ссылка на хранилище ОС или зашифрованное значение
+		if config.APIKey, err = resolveSecret(config.APIKey); err != nil {
+			return nil, errorf("ошибка в параметре api_key: %v", err)
 		}
 
 		config.Temperature = modelSection.Key("temperature").MustFloat64(0.7)
-		config.MaxTokens = modelSection.Key("max_tokens").MustInt(1000)
+		config.TopP = modelSection.Key("top_p").MustFloat64(0)
+		if config.TopP < 0 || config.TopP > 1 {
+			return nil, errorf("некорректное значение top_p %g: ожидалось от 0 до 1", config.TopP)
+		}
+		config.Stop = parseStopSequences(modelSection.Key("stop").String())
+		if strings.EqualFold(modelSection.Key("max_tokens").String(), "auto") {
+			config.AutoMaxTokens = true
+		} else {
+			config.MaxTokens = modelSection.Key("max_tokens").MustInt(1000)
+		}
+		config.ContextWindow = modelSection.Key("context_window").MustInt(0)
+		config.MaxOutputTokens = modelSection.Key("max_output").MustInt(0)
+		if _, known := config.modelInfo(); config.AutoMaxTokens && !known {
+			warnf("Предупреждение: модель %s отсутствует в таблице, для max_tokens = auto используется %d (задайте context_window и max_output)", config.ModelName, fallbackMaxTokens)
+		}
+		config.Stream = modelSection.Key("stream").MustBool(false)
+		config.ChunkContext = strings.ToLower(modelSection.Key("chunk_context").MustString(ChunkContextPrevious))
+		if err := validateChunkContext(config.ChunkContext); err != nil {
+			return nil, err
+		}
+		config.ChunkContextTokens = modelSection.Key("chunk_context_tokens").MustInt(1000)
+		config.MaxChunkTokens = modelSection.Key("max_chunk_tokens").MustInt(0)
+		if config.MaxChunkTokens < 0 {
+			return nil, errorf("max_chunk_tokens не может быть отрицательным: %d", config.MaxChunkTokens)
+		}
+		switch reasoning := strings.ToLower(modelSection.Key("reasoning_model").MustString("auto")); reasoning {
+		case "auto":
+		case "true", "false":
+			config.ReasoningModel = reasoning
+		default:
+			return nil, errorf("некорректное значение reasoning_model %q: ожидалось auto, true или false", reasoning)
+		}
+		config.ReasoningEffort = strings.ToLower(modelSection.Key("reasoning_effort").String())
+		if config.ReasoningEffort != "" && !slices.Contains(reasoningEfforts, config.ReasoningEffort) {
+			return nil, errorf("некорректное значение reasoning_effort %q: ожидалось %s", config.ReasoningEffort, strings.Join(reasoningEfforts, ", "))
+		}
+		if config.reasoningModel() && modelSection.HasKey("temperature") {
+			warnf("Предупреждение: модель %s не поддерживает temperature, параметр не отправляется", config.ModelName)
+		}
+		config.RequestsPerMinute = modelSection.Key("requests_per_minute").MustInt(0)
+		config.Burst = modelSection.Key("burst").MustInt(0)
+		if config.Burst < 0 {
+			return nil, errorf("burst не может быть отрицательным: %d", config.Burst)
+		}
+		config.TokensPerMinute = modelSection.Key("tokens_per_minute").MustInt(0)
+		if config.Maintenance.Windows, err = parseMaintenanceWindows(modelSection.Key("maintenance_windows").String()); err != nil {
+			return nil, err
+		}
+		if tz := modelSection.Key("maintenance_timezone").String(); tz != "" {
+			if config.Maintenance.Location, err = time.LoadLocation(tz); err != nil {
+				return nil, errorf("некорректный maintenance_timezone %q: %v", tz, err)
+			}
+		}
+		if config.TokensPerMinute < 0 {
+			return nil, errorf("tokens_per_minute не может быть отрицательным: %d", config.TokensPerMinute)
+		}
+		config.InputPrice = modelSection.Key("input_price").MustFloat64(0)
+		config.OutputPrice = modelSection.Key("output_price").MustFloat64(0)
 	}
 
-	// Чтение секции промпта
-	if promptSection := cfg.Section("PROMPT"); promptSection != nil {
-		config.Prompt = promptSection.Key("text").String()
-	}
+	// Чтение секции параметров обработки
+	if procSection := cfg.Section("PROCESSING"); procSection != nil {
+		config.Order = procSection.Key("order").MustString(OrderAlphabetical)
+		config.PriorityKey = procSection.Key("priority_key").MustString("priority")
+		config.MinBytes = procSection.Key("min_bytes").MustInt(0)
+		config.MinWords = procSection.Key("min_words").MustInt(0)
+		config.MaxDepth = procSection.Key("max_depth").MustInt(0)
+		config.WalkWorkers = procSection.Key("walk_workers").MustInt(defaultWalkWorkers)
+		config.WriteQueue = procSection.Key("write_queue").MustInt(defaultWriteQueue)
+		if config.WriteQueue < 0 {
+			return nil, errorf("размер очереди записи не может быть отрицательным: %d", config.WriteQueue)
+		}
+		config.Workers = procSection.Key("workers").MustInt(1)
+		if config.Workers < 1 || config.Workers > maxWorkers {
+			return nil, errorf("workers должен быть от 1 до %d: %d", maxWorkers, config.Workers)
+		}
+		if config.OnError, err = parseFailurePolicy(procSection.Key("on_error").String()); err != nil {
+			return nil, err
+		}
+		config.SystemicAfter = procSection.Key("systemic_after").MustInt(defaultSystemicAfter)
+		if config.SystemicAfter < 0 {
+			return nil, errorf("systemic_after не может быть отрицательным: %d", config.SystemicAfter)
+		}
+		config.OnDelete = strings.ToLower(procSection.Key("on_delete").MustString(OnDeleteKeep))
+		if err := validateOnDelete(config.OnDelete); err != nil {
+			return nil, err
+		}
+		config.TrashDir = stripLongPathPrefix(strings.TrimSpace(procSection.Key("trash_dir").String()))
+		config.Fsync = procSection.Key("fsync").MustBool(false)
+		durableWrites.Store(config.Fsync)
+		config.DailyCap = DailyCapConfig{
+			MaxRequests: procSection.Key("daily_max_requests").MustInt(0),
+			MaxTokens:   procSection.Key("daily_max_tokens").MustInt(0),
+		}
+		if config.DailyCap.MaxRequests < 0 || config.DailyCap.MaxTokens < 0 {
+			return nil, errorf("daily_max_requests и daily_max_tokens не могут быть отрицательными: %d, %d", config.DailyCap.MaxRequests, config.DailyCap.MaxTokens)
+		}
+		config.Incremental = procSection.Key("incremental").MustBool(false)
+		config.OnConflict = strings.ToLower(procSection.Key("on_conflict").MustString(OnConflictNew))
+		if err := validateOnConflict(config.OnConflict); err != nil {
+			return nil, err
+		}
+		config.OnFailure = strings.ToLower(procSection.Key("on_failure").MustString(OnFailureSkip))
+		if err := validateOnFailure(config.OnFailure); err != nil {
+			return nil, err
+		}
+		config.ConflictCopies = strings.ToLower(procSection.Key("conflict_copies").MustString(ConflictCopiesSkip))
+		if err := validateConflictCopies(config.ConflictCopies); err != nil {
+			return nil, err
+		}
+		config.Mode = strings.ToLower(procSection.Key("mode").MustString(ModeFull))
+		if err := validateMode(config.Mode); err != nil {
+			return nil, err
+		}
+		config.BatchMaxBytes = procSection.Key("batch_max_bytes").MustInt(0)
+		config.BatchSize = procSection.Key("batch_size").MustInt(5)
+		config.MaxFileSize = procSection.Key("max_file_size").MustInt(MaxFileSize)
+		if config.MaxFileSize <= 0 {
+			return nil, errorf("max_file_size должен быть положительным: %d", config.MaxFileSize)
+		}
+		config.MaxOutputSize = procSection.Key("max_output_size").MustInt(0)
+		if config.MaxOutputSize != 0 && config.MaxOutputSize < minOutputPageSize {
+			return nil, errorf("max_output_size должен быть 0 или не меньше %d: %d", minOutputPageSize, config.MaxOutputSize)
+		}
+		config.Oversize = strings.ToLower(procSection.Key("oversize").MustString(OversizeFail))
+		if err := validateOversize(config.Oversize); err != nil {
+			return nil, err
+		}
+		config.DedupThreshold = procSection.Key("dedup_threshold").MustFloat64(0)
+		if config.DedupThreshold < 0 || config.DedupThreshold > 1 {
+			return nil, errorf("некорректное значение dedup_threshold %g: ожидалось от 0 до 1", config.DedupThreshold)
+		}
+		config.DedupAction = procSection.Key("dedup_action").MustString(DedupReport)
+		if config.DedupAction != DedupReport && config.DedupAction != DedupSkip {
+			return nil, errorf("некорректное значение dedup_action %q: ожидалось %s или %s", config.DedupAction, DedupReport, DedupSkip)
+		}
 
-	// Безопасное создание выходной директории
-	outputDir, err := filepath.Abs(config.OutputDir)
-	if err != nil {
-		return nil, fmt.Errorf("не удалось получить абсолютный путь выходной директории: %v", err)
+		now := time.Now()
+		if config.ModifiedAfter, err = parseTimeFilter(procSection.Key("modified_after").String(), now); err != nil {
+			return nil, errorf("ошибка в параметре modified_after: %v", err)
+		}
+		if config.ModifiedBefore, err = parseTimeFilter(procSection.Key("modified_before").String(), now); err != nil {
+			return nil, errorf("ошибка в параметре modified_before: %v", err)
+		}
+	}
+	if err := validateOrder(config.Order); err != nil {
+		return nil, err
 	}
 
-	// Проверка, что выходная директория находится в безопасном месте
-	if !isPathSafe(outputDir) {
-		return nil, fmt.Errorf("небезопасный путь выходной директории: %s", outputDir)
+	// Чтение секции контекста
+	if ctxSection := cfg.Section("CONTEXT"); ctxSection != nil {
+		config.ContextDir = ctxSection.Key("dir").String()
+		config.ContextTopK = ctxSection.Key("top_k").MustInt(3)
+		config.ContextChunkWords = ctxSection.Key("chunk_words").MustInt(200)
+		config.LinkedDocs = ctxSection.Key("linked_docs").MustBool(false)
+		config.LinkedExcerptWords = ctxSection.Key("linked_excerpt_words").MustInt(150)
+		config.LinkedMaxDocs = ctxSection.Key("linked_max_docs").MustInt(5)
 	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("не удалось создать выходную директорию: %v", err)
+	// Чтение секции векторных представлений
+	if config.Network, err = loadNetworkConfig(cfg.Section("NETWORK")); err != nil {
+		return nil, err
+	}
+	if config.Offline, err = loadOfflineConfig(cfg.Section("NETWORK")); err != nil {
+		return nil, err
+	}
+	if config.Sinks, err = loadSinksConfig(cfg.Section("OUTPUT")); err != nil {
+		return nil, err
+	}
+	if config.S3, err = loadS3Config(cfg.Section("S3")); err != nil {
+		return nil, err
 	}
 
-	return config, nil
-}
+	if embSection := cfg.Section("EMBEDDINGS"); embSection != nil {
+		ec := &config.Embeddings
+		ec.Provider = strings.ToLower(embSection.Key("provider").MustString(embeddingsLocal))
+		if ec.Provider != embeddingsLocal {
+			defaults, ok := embeddingDefaults[ec.Provider]
+			if !ok {
+				return nil, errorf("неизвестный источник векторов %q: поддерживаются local, openai, ollama, gemini", ec.Provider)
+			}
+			ec.Model = embSection.Key("model").MustString(defaults.Model)
+			ec.APIURL = embSection.Key("api_url").MustString(defaults.URL)
+			ec.BatchSize = embSection.Key("batch_size").MustInt(64)
+			ec.APIKey = embSection.Key("api_key").String()
+			if env := embSection.Key("api_key_env").MustString(defaults.KeyEnv); env != "" && os.Getenv(env) != "" {
+				ec.APIKey = os.Getenv(env)
+			}
+			if ec.APIKey, err = resolveSecret(ec.APIKey); err != nil {
+				return nil, errorf("ошибка в параметре api_key секции [EMBEDDINGS]: %v", err)
+			}
+		}
+	}
 
-// Проверка безопасности пути (защита от path traversal)
-func isPathSafe(path string) bool {
-	// Проверка на наличие подозрительных последовательностей в пути
-	suspicious := []string{"../", "..\\"}
-	for _, s := range suspicious {
-		if strings.Contains(path, s) {
-			return false
+	// Чтение секции точечного обогащения разделов
+	if secSection := cfg.Section("SECTIONS"); secSection != nil {
+		for _, h := range strings.Split(secSection.Key("headings").String(), ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				config.SectionHeadings = append(config.SectionHeadings, h)
+			}
 		}
 	}
-	return true
-}
 
-// Валидация содержимого файла
-func validateContent(content []byte) error {
-	// Проверка размера файла
-	if len(content) > MaxFileSize {
-		return fmt.Errorf("размер файла превышает максимально допустимый (%d байт)", MaxFileSize)
+	// Чтение секции постобработки
+	if postSection := cfg.Section("POSTPROCESS"); postSection != nil {
+		config.NormalizeHeadings = postSection.Key("normalize_headings").MustBool(false)
+		config.HeadingAnchors = postSection.Key("anchors").MustBool(false)
+		config.TOC = postSection.Key("toc").MustBool(false)
+		config.TOCMaxLevel = postSection.Key("toc_max_level").MustInt(3)
+	}
+	if config.OutputRules, err = loadOutputRules(cfg); err != nil {
+		return nil, err
 	}
 
-	// Здесь можно добавить дополнительные проверки содержимого
-	return nil
-}
+	// Чтение секции проверки результата
+	guardSection := cfg.Section("GUARD")
+	config.Guard = outputGuard{
+		MaxSimilarity:  guardSection.Key("max_similarity").MustFloat64(0.98),
+		MinRatio:       guardSection.Key("min_ratio").MustFloat64(0),
+		MaxRatio:       guardSection.Key("max_ratio").MustFloat64(0),
+		KeepCodeBlocks: guardSection.Key("keep_code_blocks").MustBool(true),
+		Repair:         guardSection.Key("repair").MustBool(true),
+		Action:         guardSection.Key("action").MustString(GuardFail),
+	}
+	if config.Guard.MaxSimilarity < 0 || config.Guard.MaxSimilarity > 1 {
+		return nil, errorf("некорректное значение max_similarity %g: ожидалось от 0 до 1", config.Guard.MaxSimilarity)
+	}
+	if err := validateGuardAction(config.Guard.Action); err != nil {
+		return nil, err
+	}
 
-// Ограничитель частоты запросов
-type RateLimiter struct {
-	tokens   chan struct{}
-	interval time.Duration
-}
+	// Чтение политики содержимого
+	if config.Policy, err = loadContentPolicy(cfg); err != nil {
+		return nil, err
+	}
 
-// Создание нового ограничителя частоты запросов
-func NewRateLimiter(requestsPerMinute int) *RateLimiter {
-	interval := time.Minute / time.Duration(requestsPerMinute)
-	limiter := &RateLimiter{
-		tokens:   make(chan struct{}, requestsPerMinute),
-		interval: interval,
+	// Чтение секции проверки ссылок
+	if linksSection := cfg.Section("LINKS"); linksSection != nil {
+		config.CheckLinks = linksSection.Key("check").MustBool(false)
+		config.CheckExternalLinks = linksSection.Key("external").MustBool(false)
+		config.AnnotateBrokenLinks = linksSection.Key("annotate").MustBool(false)
+		config.StripIntroducedLinks = linksSection.Key("strip_introduced").MustBool(false)
+		config.FlagNewURLs = linksSection.Key("flag_new_urls").MustBool(false)
+		config.LinkCheckTimeout = linksSection.Key("timeout").MustDuration(10 * time.Second)
 	}
 
-	// Инициализация токенов
-	for i := 0; i < requestsPerMinute; i++ {
-		limiter.tokens <- struct{}{}
+	// Чтение секции подписи о раскрытии использования ИИ
+	if discSection := cfg.Section("DISCLOSURE"); discSection != nil {
+		config.Disclosure = discSection.Key("enabled").MustBool(false)
+		config.DisclosureTemplate = discSection.Key("template").String()
 	}
 
-	// Запуск горутины для пополнения токенов
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
+	// Чтение секции состояния
+	if stateSection := cfg.Section("STATE"); stateSection != nil {
+		config.StateDir = stripLongPathPrefix(stateSection.Key("dir").MustString(config.StateDir))
+		config.Operator = resolveOperator(stateSection.Key("operator").String())
+	}
+	if config.OnDelete == OnDeleteTrash && config.TrashDir == "" && config.StateDir == "" {
+		return nil, withCategory(ErrorConfig, errorf("для on_delete = trash задайте trash_dir или каталог состояния"))
+	}
 
-		for range ticker.C {
-			select {
-			case limiter.tokens <- struct{}{}:
-				// Токен добавлен
-			default:
-				// Буфер полон
+	// Язык сообщений: явно заданный язык применяется сразу, "auto" оставляет
+	// выбранный по переменным окружения при запуске
+	if uiSection := cfg.Section("INTERFACE"); uiSection != nil {
+		config.UILanguage = uiSection.Key("language").MustString("auto")
+		config.Heartbeat = uiSection.Key("heartbeat").MustDuration(defaultHeartbeat)
+		if config.Heartbeat < 0 {
+			return nil, errorf("heartbeat в секции [INTERFACE] не может быть отрицательным: %s", config.Heartbeat)
+		}
+		if lang := strings.ToLower(strings.TrimSpace(config.UILanguage)); lang != "auto" {
+			if err := setUILanguage(lang); err != nil {
+				return nil, err
 			}
 		}
-	}()
-
-	return limiter
-}
-
-// Ожидание доступности токена
-func (r *RateLimiter) Wait() {
-	<-r.tokens
-}
-
-// Обогащение markdown содержимого с использованием AI API
-func enrichContent(config *Config, content string, rateLimiter *RateLimiter) (string, error) {
-	// Ожидание доступности токена (ограничение частоты запросов)
-	rateLimiter.Wait()
-
-	// Подготовка полного промпта с содержимым
-	fullPrompt := fmt.Sprintf("%s\n\n%s", config.Prompt, content)
-
-	// Подготовка запроса на основе типа API
-	var requestBody []byte
-	var err error
+	}
 
-	if strings.Contains(strings.ToLower(config.ModelAPIURL), "openai") || strings.Contains(strings.ToLower(config.ModelAPIURL), "openrouter") {
-		// Формат запроса OpenAI/OpenRouter
-		requestData := map[string]interface{}{
-			"model": config.ModelName,
-			"messages": []map[string]string{
-				{"role": "user", "content": fullPrompt},
-			},
-			"temperature": config.Temperature,
-			"max_tokens":  config.MaxTokens,
-		}
-		requestBody, err = json.Marshal(requestData)
-	} else if strings.Contains(strings.ToLower(config.ModelAPIURL), "anthropic") {
-		// Формат запроса Anthropic
-		requestData := map[string]interface{}{
-			"model": config.ModelName,
-			"messages": []map[string]string{
-				{"role": "user", "content": fullPrompt},
-			},
-			"temperature": config.Temperature,
-			"max_tokens":  config.MaxTokens,
-		}
-		requestBody, err = json.Marshal(requestData)
-	} else {
-		// Общий формат API
-		requestData := map[string]interface{}{
-			"model":       config.ModelName,
-			"prompt":      fullPrompt,
-			"temperature": config.Temperature,
-			"max_tokens":  config.MaxTokens,
+	// Чтение секции отчета
+	if indexSection := cfg.Section("INDEX"); indexSection != nil && indexSection.Key("enabled").MustBool(false) {
+		config.IndexFile = indexSection.Key("file").MustString(defaultIndexFile)
+		config.IndexManifest = indexSection.Key("manifest").MustString(defaultManifestFile)
+		if !isRelPathSafe(config.IndexFile) || !isRelPathSafe(config.IndexManifest) {
+			return nil, errorf("файлы оглавления должны задаваться путями внутри выходной директории: %s, %s", config.IndexFile, config.IndexManifest)
 		}
-		requestBody, err = json.Marshal(requestData)
 	}
-
-	if err != nil {
-		return content, fmt.Errorf("ошибка при подготовке JSON запроса: %v", err)
+	if sumSection := cfg.Section("CHECKSUMS"); sumSection != nil && sumSection.Key("enabled").MustBool(false) {
+		config.ChecksumFile = sumSection.Key("file").MustString(defaultChecksumFile)
+		if !isRelPathSafe(config.ChecksumFile) {
+			return nil, errorf("файл контрольных сумм должен задаваться путем внутри выходной директории: %s", config.ChecksumFile)
+		}
 	}
-
-	// Формирование URL в зависимости от API
-	apiURL := config.ModelAPIURL
-	if strings.Contains(strings.ToLower(config.ModelAPIURL), "anthropic") {
-		apiURL = "https://api.anthropic.com/v1/messages"
+	if reportSection := cfg.Section("REPORT"); reportSection != nil {
+		config.ReportFile = reportSection.Key("file").MustString(config.ReportFile)
+		config.RunManifestFile = strings.TrimSpace(reportSection.Key("manifest").String())
 	}
 
-	// Создание HTTP запроса
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return content, fmt.Errorf("ошибка при создании HTTP запроса: %v", err)
+	// Чтение секции базы данных запусков
+	if dbSection := cfg.Section("DATABASE"); dbSection != nil {
+		config.DatabaseFile = dbSection.Key("file").String()
+		config.SQLiteBinary = dbSection.Key("sqlite3").MustString("sqlite3")
 	}
 
-	// Установка заголовков
-	req.Header.Set("Content-Type", "application/json")
-	if config.APIKey != "" {
-		if strings.Contains(strings.ToLower(config.ModelAPIURL), "anthropic") {
-			req.Header.Set("x-api-key", config.APIKey)
-			req.Header.Set("anthropic-version", "2023-06-01")
-		} else if strings.Contains(strings.ToLower(config.ModelAPIURL), "openrouter") {
-			req.Header.Set("Authorization", "Bearer "+config.APIKey)
-			req.Header.Set("HTTP-Referer", "https://github.com/")
-			req.Header.Set("X-Title", "Markdown Enricher")
-		} else {
-			req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	// Чтение секции рассылки итогов по почте
+	if emailSection := cfg.Section("EMAIL"); emailSection != nil && emailSection.Key("host").String() != "" {
+		ec := &config.Email
+		ec.Host = emailSection.Key("host").String()
+		ec.Port = emailSection.Key("port").MustInt(587)
+		ec.Username = emailSection.Key("username").String()
+		ec.Password = emailSection.Key("password").String()
+		if env := emailSection.Key("password_env").MustString("RICH_SMTP_PASSWORD"); os.Getenv(env) != "" {
+			ec.Password = os.Getenv(env)
+		}
+		if ec.Password, err = resolveSecret(ec.Password); err != nil {
+			return nil, errorf("ошибка в параметре password секции [EMAIL]: %v", err)
+		}
+		ec.From = emailSection.Key("from").MustString(ec.Username)
+		for _, to := range strings.Split(emailSection.Key("to").String(), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				ec.To = append(ec.To, to)
+			}
+		}
+		if ec.From == "" || len(ec.To) == 0 {
+			return nil, errorf("в секции [EMAIL] должны быть заданы from (или username) и to")
 		}
+		ec.OnlyOnFailure = emailSection.Key("only_on_failure").MustBool(false)
+		ec.ReportURL = emailSection.Key("report_url").String()
 	}
 
-	// Настройка HTTP клиента с проверкой TLS сертификатов
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		},
+	// Чтение порогов оповещений о квоте
+	if config.Alerts, err = loadAlertConfig(cfg.Section("ALERTS")); err != nil {
+		return nil, err
 	}
-	client := &http.Client{
-		Timeout:   60 * time.Second,
-		Transport: transport,
+
+	// Чтение настроек подбора заголовков
+	if config.Titles, err = loadTitleConfig(cfg.Section("TITLES")); err != nil {
+		return nil, err
 	}
 
-	// Выполнение запроса
-	resp, err := client.Do(req)
-	if err != nil {
-		return content, fmt.Errorf("ошибка при выполнении HTTP запроса: %v", err)
+	// Чтение настроек связанных заметок
+	if config.Related, err = loadRelatedConfig(cfg.Section("RELATED")); err != nil {
+		return nil, err
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Printf("Ошибка закрытия тела ответа: %v", cerr)
-		}
-	}()
 
-	// Проверка статуса ответа
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return content, fmt.Errorf("API запрос вернул статус %d: %s", resp.StatusCode, string(body))
+	// Чтение настроек дневниковых заметок
+	if config.DailyNotes, err = loadDailyNotesConfig(cfg.Section("DAILY_NOTES")); err != nil {
+		return nil, err
 	}
 
-	// Чтение и парсинг ответа
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return content, fmt.Errorf("ошибка при чтении ответа API: %v", err)
+	// Чтение настроек статусов frontmatter
+	if config.Workflow, err = loadWorkflowConfig(cfg.Section("WORKFLOW")); err != nil {
+		return nil, err
 	}
 
-	// Логируем только статус ответа, а не полное содержимое
-	log.Printf("Получен ответ API: статус %d, размер %d байт", resp.StatusCode, len(body))
+	// Чтение настроек проверочного файла
+	config.Canary = loadCanaryConfig(cfg.Section("CANARY"))
 
-	var responseData map[string]interface{}
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return content, fmt.Errorf("ошибка при разборе JSON ответа: %v", err)
+	// Чтение настроек режима наблюдения
+	if config.Watch, err = loadWatchConfig(cfg.Section("WATCH")); err != nil {
+		return nil, err
 	}
 
-	// Извлечение содержимого в зависимости от типа API
-	var enrichedContent string
-	if strings.Contains(strings.ToLower(config.ModelAPIURL), "openai") ||
-		strings.Contains(strings.ToLower(config.ModelAPIURL), "openrouter") ||
-		strings.Contains(strings.ToLower(config.ModelAPIURL), "chat/completions") {
-		choices, ok := responseData["choices"].([]interface{})
-		if !ok || len(choices) == 0 {
-			return "", fmt.Errorf("некорректный формат ответа API: отсутствует поле choices или оно пустое")
-		}
+	// Чтение настроек карточек
+	if config.Flashcards, err = loadFlashcardConfig(cfg.Section("FLASHCARDS")); err != nil {
+		return nil, err
+	}
 
-		firstChoice, ok := choices[0].(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("некорректный формат элемента choices в ответе API")
-		}
+	// Чтение настроек источников
+	if config.Citations, err = loadCitationConfig(cfg.Section("CITATIONS")); err != nil {
+		return nil, err
+	}
 
-		message, ok := firstChoice["message"].(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("некорректный формат поля message в ответе API")
-		}
+	// Чтение настроек списка изменений
+	if config.Changelog, err = loadChangelogConfig(cfg.Section("CHANGELOG")); err != nil {
+		return nil, err
+	}
 
-		messageContent, ok := message["content"].(string)
-		if !ok {
-			return "", fmt.Errorf("некорректный формат поля content в ответе API")
+	// Чтение секции совместной работы экземпляров
+	if redisSection := cfg.Section("REDIS"); redisSection != nil && redisSection.Key("url").String() != "" {
+		rc := &config.Redis
+		rc.URL = redisSection.Key("url").String()
+		if env := redisSection.Key("password_env").MustString("RICH_REDIS_PASSWORD"); os.Getenv(env) != "" {
+			rc.Password = os.Getenv(env)
 		}
-
-		enrichedContent = messageContent
-	} else if strings.Contains(strings.ToLower(config.ModelAPIURL), "anthropic") {
-		contentArray, ok := responseData["content"].([]interface{})
-		if !ok || len(contentArray) == 0 {
-			return "", fmt.Errorf("некорректный формат ответа Anthropic API: отсутствует поле content или оно пустое")
+		rc.Namespace = redisSection.Key("namespace").MustString("rich")
+		rc.ShareWork = redisSection.Key("share_work").MustBool(true)
+		rc.ClaimTTL = redisSection.Key("claim_ttl").MustDuration(defaultClaimTTL)
+		if rc.ClaimTTL <= 0 {
+			return nil, errorf("claim_ttl в секции [REDIS] должен быть положительным: %s", rc.ClaimTTL)
 		}
+	}
 
-		firstContent, ok := contentArray[0].(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("некорректный формат элемента content в ответе Anthropic API")
+	// Чтение секции промпта
+	if promptSection := cfg.Section("PROMPT"); promptSection != nil {
+		config.Prompt = promptSection.Key("text").String()
+		config.PromptLint = strings.ToLower(promptSection.Key("lint").MustString(PromptLintError))
+		if err := validatePromptLint(config.PromptLint); err != nil {
+			return nil, err
 		}
-
-		text, ok := firstContent["text"].(string)
-		if !ok {
-			return "", fmt.Errorf("некорректный формат поля text в ответе Anthropic API")
+		config.MaxPromptRatio = promptSection.Key("max_prompt_ratio").MustFloat64(defaultMaxPromptRatio)
+		if config.MaxPromptRatio <= 0 {
+			return nil, errorf("max_prompt_ratio в секции [PROMPT] должен быть положительным: %g", config.MaxPromptRatio)
 		}
+	}
 
-		enrichedContent = text
-	} else {
-		text, ok := responseData["text"].(string)
-		if !ok {
-			return "", fmt.Errorf("некорректный формат ответа API")
-		}
-		enrichedContent = text
+	// Чтение маршрутов
+	if config.Routes, err = loadRoutes(cfg, filepath.Dir(configPath)); err != nil {
+		return nil, err
 	}
 
-	return strings.TrimSpace(enrichedContent), nil
-}
+	// Чтение правил приоритета очереди
+	if config.Priorities, err = loadPriorityRules(cfg); err != nil {
+		return nil, err
+	}
 
-// Добавление файла в список исключений
-func addToExcludedFiles(configPath string, relPath string) error {
-	// Нормализуем путь для кроссплатформенности
-	relPath = filepath.Clean(relPath)
+	// Чтение правил типографики
+	if config.Typography, err = loadTypographyConfig(cfg); err != nil {
+		return nil, err
+	}
 
-	cfg, err := ini.Load(configPath)
-	if err != nil {
-		return fmt.Errorf("не удалось загрузить файл конфигурации: %v", err)
+	// Чтение настроек проверки орфографии
+	if config.Spellcheck, err = loadSpellcheckConfig(cfg.Section("SPELLCHECK")); err != nil {
+		return nil, err
 	}
 
-	exclSection := cfg.Section("EXCLUSIONS")
-	currentExcluded := exclSection.Key("excluded_files").String()
+	// Чтение руководства по стилю
+	if config.Style, err = loadStyleConfig(cfg.Section("STYLE"), filepath.Dir(configPath)); err != nil {
+		return nil, err
+	}
 
-	// Проверяем, не добавлен ли уже файл
-	excludedFiles := strings.Split(currentExcluded, ",")
-	for _, ef := range excludedFiles {
-		// Нормализуем путь из конфига для сравнения
-		existingPath := filepath.Clean(strings.TrimSpace(ef))
-		if existingPath == relPath {
-			return nil // Файл уже в списке
-		}
+	// Чтение настроек конвертации через pandoc
+	if config.Pandoc, err = loadPandocConfig(cfg.Section("PANDOC")); err != nil {
+		return nil, err
 	}
 
-	// Добавляем новый файл
-	if currentExcluded == "" {
-		currentExcluded = relPath
-	} else {
-		currentExcluded += ", " + relPath
+	// Чтение настроек документов PDF
+	if config.PDF, err = loadPDFConfig(cfg.Section("PDF")); err != nil {
+		return nil, err
 	}
 
-	exclSection.Key("excluded_files").SetValue(currentExcluded)
+	// Чтение настроек сжатых заметок
+	config.Gzip = loadGzipConfig(cfg.Section("GZIP"))
+	config.Experiment = loadExperimentConfig(cfg.Section("EXPERIMENT"))
 
-	// Безопасная запись в файл конфигурации
-	tempFile := configPath + ".tmp"
-	if err := cfg.SaveTo(tempFile); err != nil {
-		return fmt.Errorf("не удалось сохранить временный файл конфигурации: %v", err)
+	// Чтение настроек распознавания текста на изображениях
+	if config.OCR, err = loadOCRConfig(cfg.Section("OCR")); err != nil {
+		return nil, err
 	}
 
-	if err := os.Rename(tempFile, configPath); err != nil {
-		return fmt.Errorf("не удалось переименовать временный файл конфигурации: %v", err)
+	// Чтение примеров обогащения ([EXAMPLE.<имя>])
+	if config.Examples, err = loadExamples(cfg, filepath.Dir(configPath)); err != nil {
+		return nil, err
+	}
+	if len(config.Examples) > 0 && !config.provider().api().Conversation() {
+		warnf("Предупреждение: API %s не поддерживает диалог из нескольких сообщений, примеры [EXAMPLE.*] не отправляются", config.ModelAPIURL)
+		config.Examples = nil
 	}
 
-	return nil
-}
+	// Чтение языковых вариантов промпта ([PROMPT.ru], [PROMPT.en], ...)
+	for _, section := range cfg.Sections() {
+		if lang, ok := strings.CutPrefix(section.Name(), "PROMPT."); ok && section.HasKey("text") {
+			if config.LanguagePrompts == nil {
+				config.LanguagePrompts = make(map[string]string)
+			}
+			config.LanguagePrompts[strings.ToLower(lang)] = section.Key("text").String()
+		}
+	}
 
-// Безопасная запись в файл
-func safeWriteFile(path string, data []byte, perm os.FileMode) error {
-	// Создание временного файла
-	dir := filepath.Dir(path)
-	tempFile, err := os.CreateTemp(dir, "temp_*.tmp")
+	// Безопасное создание выходной директории
+	outputDir, err := filepath.Abs(config.OutputDir)
 	if err != nil {
-		return fmt.Errorf("не удалось создать временный файл: %v", err)
+		return nil, errorf("не удалось получить абсолютный путь выходной директории: %v", err)
 	}
-	tempPath := tempFile.Name()
 
-	// Запись данных во временный файл
-	if _, err := tempFile.Write(data); err != nil {
-		if cerr := tempFile.Close(); cerr != nil {
-			log.Printf("Ошибка закрытия временного файла: %v", cerr)
-		}
-		if rerr := os.Remove(tempPath); rerr != nil {
-			log.Printf("Ошибка удаления временного файла: %v", rerr)
-		}
-		return fmt.Errorf("не удалось записать данные во временный файл: %v", err)
+	// Проверка, что выходная директория находится в безопасном месте
+	if !isPathSafe(outputDir) {
+		return nil, errorf("небезопасный путь выходной директории: %s", outputDir)
 	}
 
-	if err := tempFile.Close(); err != nil {
-		if rerr := os.Remove(tempPath); rerr != nil {
-			log.Printf("Ошибка удаления временного файла: %v", rerr)
-		}
-		return fmt.Errorf("не удалось закрыть временный файл: %v", err)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, errorf("не удалось создать выходную директорию: %v", err)
 	}
 
-	// Установка прав доступа
-	if err := os.Chmod(tempPath, perm); err != nil {
-		if rerr := os.Remove(tempPath); rerr != nil {
-			log.Printf("Ошибка удаления временного файла: %v", rerr)
+	// Директория очереди проверки
+	if config.ReviewDir != "" {
+		if err := config.checkReviewDir(outputDir); err != nil {
+			return nil, withCategory(ErrorConfig, err)
 		}
-		return fmt.Errorf("не удалось установить права доступа для временного файла: %v", err)
 	}
 
-	// Переименование временного файла в целевой
-	if err := os.Rename(tempPath, path); err != nil {
-		if rerr := os.Remove(tempPath); rerr != nil {
-			log.Printf("Ошибка удаления временного файла: %v", rerr)
-		}
-		return fmt.Errorf("не удалось переименовать временный файл: %v", err)
+	// Дополнительные корни входных файлов
+	if config.Roots, err = loadInputRoots(cfg, config.OutputDir); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return config, nil
 }
 
-// Обработка одного markdown файла
-func processFile(config *Config, inputPath, outputPath string, configPath string, rateLimiter *RateLimiter) error {
-	log.Printf("Обработка %s", inputPath)
-
-	// Проверка безопасности путей
-	if !isPathSafe(inputPath) || !isPathSafe(outputPath) {
-		return fmt.Errorf("обнаружен небезопасный путь: %s или %s", inputPath, outputPath)
+// Проверка безопасности пути (защита от path traversal): компоненты ".." запрещены
+// при любых разделителях; имена дисков, UNC пути и префикс \\?\ допустимы
+func isPathSafe(path string) bool {
+	for _, part := range strings.FieldsFunc(stripLongPathPrefix(path), isPathSeparator) {
+		if part == ".." {
+			return false
+		}
 	}
+	return true
+}
 
-	// Чтение оригинального содержимого
-	content, err := os.ReadFile(inputPath)
-	if err != nil {
-		return fmt.Errorf("ошибка при чтении файла: %v", err)
-	}
+// Валидация содержимого файла
+func validateContent(content []byte) error {
+	return validateContentSize(content, MaxFileSize)
+}
 
-	// Валидация содержимого файла
-	if err := validateContent(content); err != nil {
-		return fmt.Errorf("ошибка валидации содержимого файла: %v", err)
+// Валидация содержимого файла с ограничением размера
+func validateContentSize(content []byte, limit int) error {
+	// Проверка размера файла
+	if len(content) > limit {
+		return errorf("размер файла превышает максимально допустимый (%d байт)", limit)
 	}
 
-	// Обогащение содержимого
-	enrichedContent, err := enrichContent(config, string(content), rateLimiter)
-	if err != nil {
-		log.Printf("Предупреждение: ошибка при обогащении содержимого %s: %v", inputPath, err)
-		return err // Возвращаем ошибку и прекращаем обработку файла
-	}
+	// Здесь можно добавить дополнительные проверки содержимого
+	return nil
+}
 
-	// Подготовка директории для выходного файла
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("ошибка при создании выходной директории: %v", err)
+// Проверка, что файл пустой или меньше порогов min_bytes/min_words (без учета frontmatter)
+func isTooSmall(config *Config, content []byte) (string, bool) {
+	_, body, _ := parseFrontmatter(content)
+	body = bytes.TrimSpace(body)
+
+	if len(body) == 0 {
+		return tr("файл пуст"), true
+	}
+	if config.MinBytes > 0 && len(body) < config.MinBytes {
+		return trf("размер %d байт меньше минимального %d", len(body), config.MinBytes), true
+	}
+	if config.MinWords > 0 {
+		if words := len(strings.Fields(string(body))); words < config.MinWords {
+			return trf("%d слов меньше минимального количества %d", words, config.MinWords), true
+		}
 	}
+	return "", false
+}
 
-	// Экранирование тройных обратных кавычек в оригинальном содержимом
-	escapedContent := strings.ReplaceAll(string(content), "```", "\\`\\`\\`")
+// Обогащение markdown содержимого с использованием AI API
+func enrichContent(config *Config, content string, rateLimiter *RateLimiter) (string, error) {
+	enriched, _, err := enrichContentWithUsage(config, content, rateLimiter)
+	return enriched, err
+}
 
-	// Объединение обогащенного содержимого с оригинальным в указанном формате
-	finalContent := fmt.Sprintf("%s\n\n```old\n%s\n```", enrichedContent, escapedContent)
+// Ответ API с кодом, отличным от 200
+type apiStatusError struct {
+	StatusCode int
+	Body       string
+	// Сообщение об ошибке, разобранное из тела по формату провайдера ("" - не распознано)
+	Message string
+}
 
-	// Безопасная запись результата
-	if err := safeWriteFile(outputPath, []byte(finalContent), 0644); err != nil {
-		return fmt.Errorf("ошибка при записи выходного файла: %v", err)
+func (e *apiStatusError) Error() string {
+	if e.Message != "" {
+		return trf("API запрос вернул статус %d: %s", e.StatusCode, e.Message)
 	}
+	return trf("API запрос вернул статус %d: %s", e.StatusCode, e.Body)
+}
 
-	// Добавляем обработанный файл в список исключений только при успешном обогащении
-	relPath, errRel := filepath.Rel(config.InputDir, inputPath)
-	if errRel != nil || strings.Contains(relPath, "..") {
-		relPath = filepath.Base(inputPath)
-	}
-	if err := addToExcludedFiles(configPath, relPath); err != nil {
-		// Обрабатываем ошибку, но не прерываем выполнение
-		log.Printf("Предупреждение: не удалось добавить файл в список исключений: %v", err)
-		// Попытка повторить операцию
-		if retryErr := addToExcludedFiles(configPath, relPath); retryErr != nil {
-			log.Printf("Ошибка при повторной попытке добавить файл в список исключений: %v", retryErr)
+// Обогащение markdown содержимого с учетом израсходованных токенов; к ответу модели
+// применяются правила обработки ответа, отказ модели возвращается ошибкой refusalError
+func enrichContentWithUsage(config *Config, content string, rateLimiter *RateLimiter) (string, Usage, error) {
+	var total Usage
+	request := config
+	for attempt := 0; ; attempt++ {
+		enriched, usage, err := requestEnrichment(request, content, rateLimiter)
+		total = total.Add(usage)
+		if err != nil {
+			// Ошибки без категории возникают при разборе ответа и относятся к провайдеру
+			if errorCategory(err) == ErrorInternal {
+				err = withCategory(ErrorProvider, err)
+			}
+			return enriched, total, err
+		}
+		enriched = config.OutputRules.Apply(enriched)
+		if !config.OutputRules.isRefusal(enriched) {
+			return enriched, total, nil
 		}
+		if attempt >= config.OutputRules.RefusalRetries {
+			return content, total, &refusalError{Response: enriched}
+		}
+		// Повтор с уточненным промптом
+		warnf("Предупреждение: модель отказалась выполнить запрос, повтор с уточненным промптом")
+		retry := *config
+		retry.Prompt = config.Prompt + "\n\n" + refusalRetryInstruction
+		request = &retry
 	}
-
-	log.Printf("Сохранено обогащенное содержимое в %s", outputPath)
-	return nil
 }
 
-// Обработка директории для получения всех markdown файлов
-func processDirectory(config *Config, configPath string) error {
-	// Преобразование путей в абсолютные
-	inputDir, err := filepath.Abs(config.InputDir)
+// HTTP запрос к API модели в формате провайдера: тело JSON, адрес и заголовки
+// без авторизации; тело возвращается отдельно для предпросмотра
+func newModelRequest(config *Config, content string, image *imageAttachment, maxTokens int) (*http.Request, []byte, error) {
+	p := config.provider()
+	api := p.api()
+	// Параметры генерации в полях провайдера; неподдерживаемые не отправляются
+	params := p.requestParams(config, config.generationParams(maxTokens))
+	requestData, err := api.BuildRequest(config, p, content, image, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return fmt.Errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		return nil, nil, errorf("ошибка при подготовке JSON запроса: %v", err)
 	}
 
-	outputDir, err := filepath.Abs(config.OutputDir)
+	// Адрес запроса в формате API
+	apiURL, err := api.RequestURL(config, p)
 	if err != nil {
-		return fmt.Errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+		return nil, nil, err
 	}
 
-	// Проверка безопасности путей
-	if !isPathSafe(inputDir) || !isPathSafe(outputDir) {
-		return fmt.Errorf("обнаружен небезопасный путь директории: %s или %s", inputDir, outputDir)
+	// Запрос больше max_request_size не отправляется: провайдер отклонил бы его
+	// ответом 400 или 413 без указания причины
+	if limit := config.Network.MaxRequestBytes; limit > 0 && int64(len(requestBody)) > limit {
+		return nil, nil, withCategory(ErrorValidation, errorf("тело запроса (%d байт) больше max_request_size в секции [NETWORK] (%d байт): уменьшите документ или изображение либо увеличьте ограничение",
+			len(requestBody), limit))
 	}
 
-	// Множество исключенных файлов для быстрого поиска
-	excludedMap := make(map[string]bool)
-	for _, file := range config.ExcludedFiles {
-		cleaned := filepath.Clean(file)
-		excludedMap[cleaned] = true
+	// Большое тело запроса сжимается, если API принимает сжатые запросы
+	payload, compressed := requestBody, false
+	if len(requestBody) >= compressMinBytes && config.Network.compressRequests(p) {
+		if payload, err = gzipBody(requestBody); err != nil {
+			return nil, nil, errorf("ошибка при сжатии запроса: %v", err)
+		}
+		compressed = true
 	}
 
-	// Создание ограничителя частоты запросов
-	rateLimiter := NewRateLimiter(RequestsPerMinute)
+	// Создание HTTP запроса
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
 
-	// Счетчик обработанных файлов
-	fileCount := 0
+	// Установка заголовков; ответ в gzip или deflate распаковывается при чтении
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	setUserAgent(req)
+	return req, requestBody, nil
+}
+
+// Запрос обогащения к API модели
+func requestEnrichment(config *Config, content string, rateLimiter *RateLimiter) (string, Usage, error) {
+	return requestModel(config, content, nil, rateLimiter)
+}
+
+// Одна попытка запроса к API модели; image - изображение, отправляемое вместе
+// с текстом (nil - только текст)
+func requestModelOnce(config *Config, content string, image *imageAttachment, rateLimiter *RateLimiter) (_ string, usage Usage, _ error) {
+	// Время ожидания ограничителя и ответа API для хронологии обработки файла
+	queued := time.Now()
+	var sent time.Time
+	defer func() {
+		if !sent.IsZero() {
+			usage.Timing = requestTiming{Wait: sent.Sub(queued), API: time.Since(sent), Sent: sent, Received: time.Now()}
+		}
+	}()
+
+	// Ожидание доступности токена (ограничение частоты запросов)
+	rateLimiter.Wait()
+
+	// Подготовка полного промпта с содержимым
+	fullPrompt := fmt.Sprintf("%s\n\n%s", config.Prompt, content)
+
+	// Размер ответа с учетом контекстного окна модели (вместе с примерами)
+	maxTokens, err := requestMaxTokens(config, requestText(config, content))
+	if err != nil {
+		return content, Usage{}, withCategory(ErrorValidation, err)
+	}
+
+	// Лимит токенов в минуту (Groq, OpenAI): запрос ждет сброса, если не помещается в остаток
+	rateLimiter.WaitTokens(estimateTokens(requestText(config, content)))
+
+	// Локальный лимит tokens_per_minute: резервируется оценка запроса вместе с max_tokens,
+	// после ответа резерв заменяется фактическим расходом
+	reserved := rateLimiter.ReserveTokens(estimateTokens(requestText(config, content)) + maxTokens)
+	defer func() { rateLimiter.SettleTokens(reserved, usage) }()
+
+	// Запрос к API в формате провайдера
+	req, _, err := newModelRequest(config, content, image, maxTokens)
+	if err != nil {
+		return content, Usage{}, err
+	}
+	p := config.provider()
+	if err := setAuthHeaders(req, config); err != nil {
+		return content, Usage{}, withCategory(ErrorAuth, err)
+	}
+
+	// HTTP клиент с параметрами [NETWORK]: время ожидания, TLS, пул соединений.
+	// Потоковый ответ ограничивается не общим временем, а простоем между фрагментами
+	client := config.Network.client(0)
+	var idle *idleTimeout
+	if config.Stream {
+		var ctx context.Context
+		ctx, idle = newIdleTimeout(client.Timeout)
+		defer idle.Stop()
+		req = req.WithContext(ctx)
+		client.Timeout = 0
+	}
+
+	// Выполнение запроса; пока ответ не прочитан, выводятся сообщения об ожидании.
+	// Отправленный запрос учитывается в суточном лимите и при ошибке
+	sent = time.Now()
+	defer func() { rateLimiter.daily.Record(usage) }()
+	stopHeartbeat := startHeartbeat(config, sent)
+	defer stopHeartbeat()
+	resp, err := client.Do(req)
+	if idle != nil {
+		err = idle.Err(err)
+	}
+	if err != nil {
+		return content, Usage{}, errorf("ошибка при выполнении HTTP запроса: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			logErrorf("Ошибка закрытия тела ответа: %v", cerr)
+		}
+	}()
+
+	// Распаковка ответа, сжатого gzip или deflate
+	if err := decodeResponseBody(resp); err != nil {
+		return content, Usage{}, err
+	}
+
+	// Исчерпанный лимит провайдера приостанавливает следующие запросы до его сброса
+	if wait, ok := p.limitWait(resp.Header); ok {
+		infof("Лимит запросов провайдера исчерпан, пауза %v", wait)
+		rateLimiter.PauseFor(wait)
+	}
+	if remaining, reset, ok := tokenLimit(resp.Header); ok {
+		rateLimiter.ObserveTokens(remaining, reset)
+	}
+
+	// Проверка статуса ответа
+	if resp.StatusCode != http.StatusOK {
+		body, _ := readResponseBody(resp, config.Network.maxResponseBytes())
+		if wait, ok := p.errorBodyWait(body); ok && resp.StatusCode == http.StatusTooManyRequests {
+			infof("Лимит запросов провайдера исчерпан, пауза %v", wait)
+			rateLimiter.PauseFor(wait)
+		}
+		return content, Usage{}, &apiStatusError{StatusCode: resp.StatusCode, Body: string(body), Message: p.apiErrorMessage(body)}
+	}
+
+	// Потоковый ответ (server-sent events или NDJSON Ollama) читается по фрагментам
+	if contentType := resp.Header.Get("Content-Type"); strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, "application/x-ndjson") {
+		var body io.Reader = io.LimitReader(resp.Body, config.Network.maxResponseBytes())
+		if idle != nil {
+			body = idle.Reader(body)
+		}
+		text, usage, ok, err := p.api().ReadStream(body)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		logf("Получен потоковый ответ API: %d символов", len([]rune(text)))
+		text = strings.TrimSpace(text)
+		if !ok {
+			usage = estimateUsage(fullPrompt, text)
+		}
+		return text, usage, nil
+	}
+
+	// Чтение ответа не больше max_response_size и проверка, что это JSON
+	body, err := readResponseBody(resp, config.Network.maxResponseBytes())
+	if err != nil {
+		return content, Usage{}, err
+	}
+
+	// Логируем только статус ответа, а не полное содержимое
+	logf("Получен ответ API: статус %d, размер %d байт", resp.StatusCode, len(body))
+	if err := checkJSONResponse(resp, body); err != nil {
+		return content, Usage{}, err
+	}
+
+	var responseData map[string]interface{}
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		return content, Usage{}, errorf("ошибка при разборе JSON ответа: %v", err)
+	}
+
+	// Текст и токены ответа в формате API (оценка, если провайдер не вернул usage)
+	text, usage, ok, err := p.api().ParseResponse(config, responseData)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	text = strings.TrimSpace(text)
+	if !ok {
+		usage = estimateUsage(fullPrompt, text)
+	}
+	return text, usage, nil
+}
+
+// Установка заголовков авторизации в зависимости от API
+func setAuthHeaders(req *http.Request, config *Config) error {
+	p := config.provider()
+	if p != nil && p.accessToken != nil {
+		// Vertex AI: токен доступа по учетным данным вместо ключа
+		token, err := p.accessToken(config)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	if config.APIKey == "" {
+		return nil
+	}
+	p.setAuth(req, config.APIKey)
+	return nil
+}
+
+// Добавление файла в список исключений
+func addToExcludedFiles(configPath string, relPath string) error {
+	// Пути хранятся с прямыми слешами на всех платформах
+	relPath = normalizeRelPath(relPath)
+
+	// Конфигурация перечитывается и перезаписывается целиком, поэтому запись
+	// выполняется под блокировкой, иначе параллельные обновления теряются
+	return withFileLock(configPath, func() error {
+		// При конфигурации из переменных окружения файла может не быть: он создается
+		// и хранит только список обработанных файлов
+		cfg, err := loadConfigFile(configPath, true)
+		if err != nil {
+			return errorf("не удалось загрузить файл конфигурации: %v", err)
+		}
+
+		exclSection := cfg.Section("EXCLUSIONS")
+		currentExcluded := exclSection.Key("excluded_files").String()
+
+		// Проверяем, не добавлен ли уже файл
+		excludedFiles := strings.Split(currentExcluded, ",")
+		for _, ef := range excludedFiles {
+			// Сравнение без учета разделителей (и регистра в Windows)
+			if strings.TrimSpace(ef) != "" && samePath(ef, relPath) {
+				return nil // Файл уже в списке
+			}
+		}
+
+		// Добавляем новый файл
+		if currentExcluded == "" {
+			currentExcluded = relPath
+		} else {
+			currentExcluded += ", " + relPath
+		}
+
+		exclSection.Key("excluded_files").SetValue(currentExcluded)
+		return saveConfigFile(cfg, configPath)
+	})
+}
+
+// Чтение INI файла конфигурации (loose - отсутствующий файл считается пустым).
+// Комментарий в конце строки начинается с # или ; только после пробела, поэтому
+// значения вида vault:secret/data/rich#openai читаются целиком
+func loadConfigFile(configPath string, loose bool) (*ini.File, error) {
+	return ini.LoadSources(ini.LoadOptions{Loose: loose, SpaceBeforeInlineComment: true}, configPath)
+}
+
+// Безопасная запись файла конфигурации через временный файл
+func saveConfigFile(cfg *ini.File, configPath string) error {
+	tempFile := configPath + ".tmp"
+	if err := cfg.SaveTo(tempFile); err != nil {
+		return errorf("не удалось сохранить временный файл конфигурации: %v", err)
+	}
+	if durableWrites.Load() {
+		if err := syncFile(tempFile); err != nil {
+			return errorf("не удалось сохранить временный файл конфигурации: %v", err)
+		}
+	}
+
+	if err := moveFile(tempFile, configPath); err != nil {
+		return errorf("не удалось переименовать временный файл конфигурации: %v", err)
+	}
+
+	return nil
+}
+
+// Проверка наличия файла в списке исключенных на момент загрузки конфигурации
+func isExcluded(config *Config, relPath string) bool {
+	for _, ef := range config.ExcludedFiles {
+		if strings.TrimSpace(ef) != "" && samePath(ef, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Удаление файла из списка исключенных в конфигурации (при отмене запуска)
+func removeFromExcludedFiles(configPath string, relPath string) error {
+	return withFileLock(configPath, func() error {
+		cfg, err := loadConfigFile(configPath, false)
+		if err != nil {
+			return errorf("не удалось загрузить файл конфигурации: %v", err)
+		}
+
+		exclSection := cfg.Section("EXCLUSIONS")
+		var kept []string
+		found := false
+		for _, ef := range strings.Split(exclSection.Key("excluded_files").String(), ",") {
+			ef = strings.TrimSpace(ef)
+			if ef == "" {
+				continue
+			}
+			if samePath(ef, relPath) {
+				found = true
+				continue
+			}
+			kept = append(kept, ef)
+		}
+		if !found {
+			return nil
+		}
+		exclSection.Key("excluded_files").SetValue(strings.Join(kept, ", "))
+		return saveConfigFile(cfg, configPath)
+	})
+}
+
+// Сброс записанных файлов и их директорий на диск (fsync в секции [PROCESSING]):
+// после отключения питания на диске остается прежний или новый файл целиком, а не
+// обрезанный результат
+var durableWrites atomic.Bool
+
+// Безопасная запись в файл
+func safeWriteFile(path string, data []byte, perm os.FileMode) error {
+	// Создание временного файла
+	dir := filepath.Dir(path)
+	tempFile, err := os.CreateTemp(dir, "temp_*.tmp")
+	if err != nil {
+		return errorf("не удалось создать временный файл: %v", err)
+	}
+	tempPath := tempFile.Name()
+
+	// Запись данных во временный файл
+	if _, err := tempFile.Write(data); err != nil {
+		if cerr := tempFile.Close(); cerr != nil {
+			logErrorf("Ошибка закрытия временного файла: %v", cerr)
+		}
+		if rerr := os.Remove(tempPath); rerr != nil {
+			logErrorf("Ошибка удаления временного файла: %v", rerr)
+		}
+		return errorf("не удалось записать данные во временный файл: %v", err)
+	}
+	if durableWrites.Load() {
+		if err := tempFile.Sync(); err != nil {
+			if cerr := tempFile.Close(); cerr != nil {
+				logErrorf("Ошибка закрытия временного файла: %v", cerr)
+			}
+			if rerr := os.Remove(tempPath); rerr != nil {
+				logErrorf("Ошибка удаления временного файла: %v", rerr)
+			}
+			return errorf("не удалось сбросить временный файл на диск: %v", err)
+		}
+	}
+
+	if err := tempFile.Close(); err != nil {
+		if rerr := os.Remove(tempPath); rerr != nil {
+			logErrorf("Ошибка удаления временного файла: %v", rerr)
+		}
+		return errorf("не удалось закрыть временный файл: %v", err)
+	}
+
+	// Установка прав доступа
+	if err := os.Chmod(tempPath, perm); err != nil {
+		if rerr := os.Remove(tempPath); rerr != nil {
+			logErrorf("Ошибка удаления временного файла: %v", rerr)
+		}
+		return errorf("не удалось установить права доступа для временного файла: %v", err)
+	}
+
+	// Переименование временного файла в целевой (копированием, если цель на другой
+	// файловой системе)
+	if err := moveFile(tempPath, path); err != nil {
+		if rerr := os.Remove(tempPath); rerr != nil {
+			logErrorf("Ошибка удаления временного файла: %v", rerr)
+		}
+		return errorf("не удалось переименовать временный файл: %v", err)
+	}
+
+	return nil
+}
+
+// Результат обработки одного файла
+type fileResult struct {
+	// Итоговый статус обработки файла
+	Status string
+	// Израсходованные на обогащение токены
+	Usage Usage
+	// Определенный язык документа
+	Language string
+	// Имя выбранного маршрута ("" - общий промпт и модель)
+	Route string
+	// Версия промпта (хэш промпта после подстановок, без контекста) и модель
+	PromptHash string
+	Model      string
+	// Битые ссылки обогащенного документа
+	BrokenLinks []brokenLink
+	// Сведения для журнала запуска: резервная копия, хэши исходного файла и результата,
+	// добавление в исключения
+	Backup          string
+	InputHash       string
+	OutputHash      string
+	AddedToExcluded bool
+	// Метрики читаемости и структуры до и после обогащения
+	Metrics *qualityMetrics
+	// Основной документ, на который почти полностью похож этот файл
+	DuplicateOf string
+	// Сработавшие правила политики содержимого
+	PolicyMatches []string
+	// Время обработки файла
+	Duration time.Duration
+	// Хронология этапов обработки (время запросов к API - в Usage.Timing)
+	Timeline fileTimeline
+	// Заголовок и slug, предложенные моделью ([TITLES])
+	Title string
+	Slug  string
+	// Путь переименованного результата в общем состоянии ("" - имя не изменилось)
+	Output string
+	// Записанный выходной файл ("" - результат не записан в выходную директорию)
+	Written string
+	// Связанные заметки, на которые добавлены ссылки ([RELATED])
+	Related []string
+	// Количество карточек и путь записанного файла карточек ([FLASHCARDS])
+	Cards     int
+	CardsFile string
+	// Источники, предложенные моделью, и итоги их проверки ([CITATIONS])
+	Citations []citation
+	// Что изменилось в документе при обогащении ([CHANGELOG])
+	Changes []string
+	// Нарушения механических правил руководства по стилю ([STYLE])
+	StyleViolations []styleViolation
+	// Опечатки в обогащенном тексте ([SPELLCHECK])
+	Spelling []spellingIssue
+	// Изображения, текст которых распознан перед обогащением ([OCR])
+	OCR []string
+	// Файл с новым обогащением, если результат изменен вручную после прошлого обогащения
+	Conflict string
+	// Копия с отметкой об ошибке, записанная вместо результата (on_failure)
+	Fallback string
+	// Причина повторного запроса с исправлением ("" - ответ прошел проверку сразу)
+	Repair string
+	// Пути частей результата после первой, если результат разделен по max_output_size
+	Pages []string
+	// Эксперимент с промптами и вариант промпта ([EXPERIMENT], "" - файл не участвует)
+	Experiment string
+	Variant    string
+	// Конфликтные копии синхронизации, объединенные с заметкой (conflict_copies = merge)
+	ConflictCopies []string
+}
+
+// Статусы обработки файла
+const (
+	StatusEnriched         = "enriched"
+	StatusConflict         = "conflict"
+	StatusFailed           = "failed"
+	StatusSkippedTooSmall  = "skipped: too small"
+	StatusSkippedUnchanged = "skipped: unchanged"
+	StatusSkippedDuplicate = "skipped: duplicate"
+	StatusSkippedPolicy    = "skipped: policy"
+	StatusSkippedTooLarge  = "skipped: too large"
+	StatusSkippedComplete  = "skipped: complete"
+	StatusSkippedNoText    = "skipped: no text"
+	StatusSkippedStatus    = "skipped: status"
+	StatusSkippedInReview  = "skipped: in review"
+)
+
+// Проверка, что файл пропущен без обращения к API
+func isSkippedStatus(status string) bool {
+	return strings.HasPrefix(status, "skipped")
+}
+
+// Конфигурация обработки файла: переопределения маршрута (по пути и тегам frontmatter),
+// промпты директорий (.rich-prompt.md) и промпт для языка документа
+func resolveFileConfig(config *Config, relPath string, content []byte) (Config, *Route, string) {
+	fileConfig := *config
+	fm, _, _ := parseFrontmatter(content)
+	route := matchRoute(config.Routes, relPath, fm)
+	if route != nil {
+		route.Apply(&fileConfig)
+	}
+	if layers := dirPromptLayers(config.InputDir, relPath); len(layers) > 0 {
+		fileConfig.Prompt = applyDirPrompts(fileConfig.Prompt, layers)
+		variants := make(map[string]string, len(fileConfig.LanguagePrompts))
+		for l, prompt := range fileConfig.LanguagePrompts {
+			variants[l] = applyDirPrompts(prompt, layers)
+		}
+		fileConfig.LanguagePrompts = variants
+	}
+	fileConfig.CurrentFile = normalizeRelPath(rootKey(config.RootName, relPath))
+	lang := detectLanguage(string(content))
+	routeName := ""
+	if route != nil {
+		routeName = route.Name
+	}
+	fileConfig.File = newFileContext(relPath, content, lang, routeName)
+	fileConfig.Prompt = withStyleGuide(fileConfig.File.Expand(promptForLanguage(&fileConfig, lang)), config.Style.Guide)
+	return fileConfig, route, lang
+}
+
+// Обработка одного markdown файла: подготовка результата и запись выходного файла
+func processFile(config *Config, inputPath, outputPath string, configPath string, sess *session) (*fileResult, error) {
+	result, pending, err := prepareFile(config, inputPath, outputPath, sess)
+	if err != nil || pending == nil {
+		return result, err
+	}
+	return result, pending.Write(configPath, sess)
+}
+
+// Подготовленный к записи результат обработки файла
+type pendingWrite struct {
+	config     *Config
+	relPath    string
+	outputPath string
+	content    []byte
+	result     *fileResult
+	// Входной файл и хэш содержимого, по которому получен результат
+	inputPath string
+	inputHash string
+	// Файл карточек для записи рядом с результатом (nil - карточек нет)
+	cards []byte
+	// Части результата после первой (max_output_size)
+	pages [][]byte
+	// Сведения о файле для приемников
+	file fileContext
+}
+
+// Подготовка результата обработки файла без записи на диск: чтение, проверки и
+// запросы к API. Для пропущенных и необработанных файлов pendingWrite равен nil
+func prepareFile(config *Config, inputPath, outputPath string, sess *session) (*fileResult, *pendingWrite, error) {
+	result := &fileResult{Status: StatusFailed}
+	result.Timeline.Started = time.Now()
+
+	logf("Обработка %s", inputPath)
+
+	// Проверка безопасности путей
+	if !isPathSafe(inputPath) || !isPathSafe(outputPath) {
+		return result, nil, withCategory(ErrorValidation, errorf("обнаружен небезопасный путь: %s или %s", inputPath, outputPath))
+	}
+
+	// Чтение оригинального содержимого
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		return result, nil, errorf("ошибка при чтении файла: %w", err)
+	}
+	inputHash := contentHash(content)
+
+	// Сжатая заметка обогащается в распакованном виде
+	compressed := config.Gzip.Enabled && isGzipMarkdownPath(inputPath)
+	if compressed {
+		if content, err = gunzipBytes(content); err != nil {
+			return result, nil, withCategory(ErrorValidation, errorf("не удалось распаковать %s: %v", inputPath, err))
+		}
+		outputPath = config.Gzip.outputPath(outputPath)
+	}
+
+	// Документ другого формата обогащается в виде markdown
+	pandocFormat := config.Pandoc.formatFor(inputPath)
+	if pandocFormat != "" {
+		if content, err = config.Pandoc.toMarkdown(inputPath, pandocFormat); err != nil {
+			return result, nil, errorf("ошибка конвертации %s в markdown: %v", inputPath, err)
+		}
+		outputPath = config.Pandoc.outputPath(outputPath)
+		logf("Документ %s (%s) сконвертирован в markdown", inputPath, pandocFormat)
+	}
+
+	// Документ PDF обогащается по текстовому слою, результат сохраняется в markdown
+	// со ссылкой на исходный документ
+	sourcePDF := ""
+	if config.PDF.Enabled && isPDFPath(inputPath) {
+		text, pages, err := extractPDFText(content, config.PDF.MaxPages)
+		if err != nil {
+			return result, nil, withCategory(ErrorValidation, errorf("не удалось извлечь текст из %s: %v", inputPath, err))
+		}
+		if utf8.RuneCountInString(text) < config.PDF.MinTextChars {
+			logf("Пропуск файла %s (skipped: no text): в документе PDF нет текстового слоя (страниц: %d)", inputPath, pages)
+			result.Status = StatusSkippedNoText
+			return result, nil, nil
+		}
+		logf("Из документа %s извлечен текст: страниц %d", inputPath, pages)
+		content, sourcePDF = []byte(text), inputPath
+		outputPath = markdownOutputPath(outputPath)
+	}
+	convertBack := pandocFormat != "" && config.Pandoc.ConvertBack
+	result.Timeline.Read = time.Now()
+	result.Timeline.ReadMS = result.Timeline.Read.Sub(result.Timeline.Started).Milliseconds()
+
+	// Файл больше max_file_size: ошибка, пропуск, усечение или обработка по частям
+	original, oversized := content, len(content) > config.maxFileSize()
+	if oversized {
+		switch config.Oversize {
+		case OversizeSkip:
+			logf("Пропуск файла %s (skipped: too large): %d байт при ограничении %d", inputPath, len(content), config.maxFileSize())
+			result.Status = StatusSkippedTooLarge
+			return result, nil, nil
+		case OversizeTruncate:
+			content, _ = truncateContent(content, config.maxFileSize())
+			warnf("Предупреждение: файл %s больше %d байт, в модель отправляется начало файла (%d байт)", inputPath, config.maxFileSize(), len(content))
+		case OversizeChunk:
+			logf("Файл %s больше %d байт и обогащается по частям", inputPath, config.maxFileSize())
+		}
+	}
+
+	// Валидация содержимого файла
+	if config.Oversize != OversizeChunk {
+		if err := validateContentSize(content, config.maxFileSize()); err != nil {
+			return result, nil, withCategory(ErrorValidation, errorf("ошибка валидации содержимого файла: %v", err))
+		}
+	}
+
+	// Двоичный файл с расширением markdown не отправляется в модель
+	if reason, binary := sniffBinary(content); binary {
+		return result, nil, withCategory(ErrorValidation, errorf("файл %s не похож на текстовый: %s", inputPath, reason))
+	}
+
+	// Часть усеченного файла, не отправляемая в модель
+	tail := original[len(content):]
+
+	// Заметка только из изображений проверяется по размеру после распознавания текста
+	var images []string
+	if config.ocrConfigured() {
+		images = imageOnlyNote(content)
+	}
+
+	// Автор отметил файл как черновик или уже обогащенный в поле статуса frontmatter
+	if status, skipped := config.Workflow.Skipped(content); skipped {
+		logf("Пропуск файла %s (skipped: status): %s: %q", inputPath, config.Workflow.Field, status)
+		result.Status = StatusSkippedStatus
+		return result, nil, nil
+	}
+
+	// Пропуск пустых и слишком маленьких файлов, чтобы не тратить запросы впустую
+	if reason, small := isTooSmall(config, content); small && len(images) == 0 {
+		logf("Пропуск файла %s (skipped: too small): %s", inputPath, reason)
+		result.Status = StatusSkippedTooSmall
+		return result, nil, nil
+	}
+
+	// Путь файла относительно входной директории
+	relPath := filepath.Base(inputPath)
+	if inputDir, err := filepath.Abs(config.InputDir); err == nil {
+		if rel, err := filepath.Rel(inputDir, inputPath); err == nil && isRelPathSafe(rel) {
+			relPath = rel
+		}
+	}
+
+	// Локальная политика содержимого: запрещенные файлы не отправляются во внешние API
+	blocked := false
+	for _, m := range config.Policy.Check(content) {
+		result.PolicyMatches = append(result.PolicyMatches, m.Rule)
+		blocked = blocked || m.Action == PolicyBlock
+	}
+	if blocked {
+		logf("Пропуск файла %s (skipped: policy): %s", inputPath, strings.Join(result.PolicyMatches, ", "))
+		result.Status = StatusSkippedPolicy
+		return result, nil, nil
+	}
+	if len(result.PolicyMatches) > 0 {
+		warnf("Предупреждение: файл %s отмечен политикой содержимого: %s", relPath, strings.Join(result.PolicyMatches, ", "))
+	}
+
+	// Почти одинаковые документы: копия пропускается, если основной документ обрабатывается
+	if dup, ok := sess.duplicates[relPath]; ok {
+		result.DuplicateOf = normalizeRelPath(dup.Of)
+		if config.DedupAction == DedupSkip {
+			logf("Пропуск файла %s (skipped: duplicate): похож на %s", inputPath, dup.Of)
+			result.Status = StatusSkippedDuplicate
+			return result, nil, nil
+		}
+	}
+
+	// Выбор маршрута и промпта по языку документа
+	fileConfig, route, lang := resolveFileConfig(config, relPath, content)
+	if route != nil {
+		logf("Маршрут для %s: %s", relPath, route.Name)
+		result.Route = route.Name
+	}
+	// Текст изображений распознается до обогащения; язык определяется по распознанному тексту
+	note := string(content)
+	if len(images) > 0 {
+		if fileConfig.OCR.Engine == OCROff {
+			if reason, small := isTooSmall(config, content); small {
+				logf("Пропуск файла %s (skipped: too small): %s", inputPath, reason)
+				result.Status = StatusSkippedTooSmall
+				return result, nil, nil
+			}
+		} else {
+			recognized, usage, err := recognizeNoteImages(&fileConfig, inputPath, images, sess.limiter)
+			result.Usage = result.Usage.Add(usage)
+			if err != nil {
+				logf("Предупреждение: ошибка при распознавании текста %s: %v", inputPath, err)
+				return result, nil, err
+			}
+			for _, image := range images {
+				if recognized[image] != "" {
+					result.OCR = append(result.OCR, image)
+				}
+			}
+			if len(result.OCR) == 0 {
+				logf("Пропуск файла %s (skipped: no text): на изображениях не найден текст", inputPath)
+				result.Status = StatusSkippedNoText
+				return result, nil, nil
+			}
+			logf("Распознан текст изображений %s: %d", inputPath, len(result.OCR))
+			content = []byte(withRecognizedText(note, recognized, images))
+			for _, m := range config.Policy.Check(content) {
+				if m.Action == PolicyBlock {
+					logf("Пропуск файла %s (skipped: policy): %s", inputPath, m.Rule)
+					result.PolicyMatches = append(result.PolicyMatches, m.Rule)
+					result.Status = StatusSkippedPolicy
+					return result, nil, nil
+				}
+			}
+			fileConfig, _, lang = resolveFileConfig(config, relPath, content)
+		}
+	}
+	result.Language = lang
+	if lang != "" {
+		logf("Язык документа %s: %s", inputPath, lang)
+	}
+	// Вариант промпта в эксперименте
+	result.Variant = sess.experiment.Assign(&fileConfig, route)
+	if result.Variant != "" {
+		result.Experiment = config.Experiment.Name
+		logf("Вариант промпта для %s: %s", relPath, result.Variant)
+	}
+	// Промпт до добавления контекста - версия промпта для подписи и отслеживания устаревания
+	basePrompt := fileConfig.Prompt
+	result.PromptHash = promptHash(basePrompt)
+	result.Model = fileConfig.ModelName
+
+	// Добавление похожих фрагментов из директории контекста
+	if sess.contextIndex != nil {
+		chunks, err := sess.contextIndex.Search(string(content), config.ContextTopK)
+		if err != nil {
+			return result, nil, err
+		}
+		fileConfig.Prompt = withContextChunks(fileConfig.Prompt, chunks)
+	}
+
+	// Добавление выдержек из документов, на которые ссылается файл
+	if sess.links != nil {
+		targets := config.Policy.allowedPaths(sess.links.Resolve(inputPath, string(content)))
+		excerpts := sess.links.Excerpts(targets, config.LinkedExcerptWords, config.LinkedMaxDocs)
+		fileConfig.Prompt = withSourceChunks(fileConfig.Prompt, "Excerpts from documents referenced by this note (for awareness, do not copy verbatim):", excerpts)
+	}
+
+	// Дневниковая заметка: выдержки из обогащенных заметок предыдущих дней
+	if sess.dailyNotes != nil {
+		fm, _, _ := parseFrontmatter(content)
+		if date, ok := config.DailyNotes.noteDate(relPath, fm); ok {
+			chunks := sess.dailyNotes.Context(sess.titles, date)
+			fileConfig.Prompt = withSourceChunks(fileConfig.Prompt, "Enriched journal notes from the previous days (for continuity, do not copy verbatim):", chunks)
+			logf("Дневниковая заметка %s за %s: заметок предыдущих дней в контексте %d", relPath, date.Format("2006-01-02"), len(chunks))
+		}
+	}
+
+	// Результат, переименованный по заголовку при прошлой обработке, сохраняет имя
+	key := rootKey(config.RootName, relPath)
+	if config.Titles.Rename && sess.titles != nil {
+		if e, ok := sess.titles.Get(key); ok && !samePath(e.Output, key) {
+			outputPath = filepath.Join(filepath.Dir(outputPath), filepath.Base(e.Output))
+			result.Output = e.Output
+		}
+	}
+
+	// Маршрут с шаблоном output: результат сохраняется в структуре публикации, а не
+	// повторяет путь входного файла; имя по шаблону важнее имени по заголовку
+	if route != nil && route.Output != nil && !convertBack {
+		var modTime time.Time
+		if info, err := os.Stat(inputPath); err == nil {
+			modTime = info.ModTime()
+		}
+		rel, err := route.OutputPath(fileConfig.File, outputExt(outputPath), modTime)
+		if err != nil {
+			return result, nil, withCategory(ErrorConfig, err)
+		}
+		outputDir, err := filepath.Abs(config.OutputDir)
+		if err != nil {
+			return result, nil, errorf("ошибка выходной директории: %v", err)
+		}
+		rel = sess.routeOutputs.Reserve(config.RootName, key, rel)
+		outputPath = filepath.Join(outputDir, filepath.FromSlash(rel))
+		result.Output = rootKey(config.RootName, rel)
+		logf("Результат %s сохраняется по шаблону маршрута %s: %s", relPath, route.Name, rel)
+	}
+
+	// Новое обогащение, полученное другим оператором, ждет слияния с ручными правками:
+	// файл не запрашивается заново, чтобы не перезаписать чужой файл .new
+	if path, owner, ok := sess.foreignConflict(config, key, outputPath); ok {
+		warnf("Пропуск файла %s (skipped: in review): новое обогащение %s ждет проверки оператором %s", inputPath, path, owner)
+		result.Conflict = path
+		result.Status = StatusSkippedInReview
+		return result, nil, nil
+	}
+
+	// Режим обработки документа; в режимах outline и skeleton документ не обогащается целиком
+	mode := documentMode(config, string(content))
+	var placeholders []placeholderSection
+	if mode == ModeSkeleton {
+		if placeholders = findPlaceholderSections(string(content)); len(placeholders) == 0 {
+			logf("Пропуск файла %s (skipped: complete): нет незаполненных разделов", inputPath)
+			result.Status = StatusSkippedComplete
+			return result, nil, nil
+		}
+	}
+
+	// Версии из конфликтных копий синхронизации объединяются с заметкой при полном
+	// обогащении документа
+	if config.ConflictCopies == ConflictCopiesMerge {
+		if copies := readConflictCopies(config, inputPath); len(copies) > 0 {
+			if mode != ModeFull || len(findSections(config, string(content))) > 0 {
+				warnf("Предупреждение: конфликтные копии %s не объединяются: документ обогащается не целиком", relPath)
+			} else {
+				fileConfig.Prompt = withSourceChunks(fileConfig.Prompt, conflictMergeInstruction, copies)
+				for _, c := range copies {
+					result.ConflictCopies = append(result.ConflictCopies, normalizeRelPath(filepath.Join(filepath.Dir(relPath), c.Source)))
+				}
+				logf("Заметка %s объединяется с конфликтными копиями: %d", relPath, len(copies))
+			}
+		}
+	}
+
+	// Предыдущий результат для инкрементального обогащения измененных разделов
+	var prev *previousOutput
+	if config.Incremental && mode == ModeFull && len(result.ConflictCopies) == 0 {
+		if p, ok := readPreviousOutput(outputPath); ok {
+			if p.Original == note {
+				logf("Пропуск файла %s: содержимое не изменилось", inputPath)
+				result.Status = StatusSkippedUnchanged
+				return result, nil, nil
+			}
+			// Распознанный текст не входит в оригинал, а в разделенном на части
+			// результате первая часть содержит не весь документ, поэтому заметка
+			// обогащается заново
+			if len(result.OCR) == 0 && !hasPages(outputPath) {
+				prev = p
+			}
+		}
+	}
+
+	// Обогащенный документ и признак сохранения оригинала в блоке old
+	var enrichedDoc string
+	keepOriginal := true
+	incrementalDone := false
+	// Документ обогащен одним запросом: отклоненный проверкой ответ можно исправить
+	// повторным запросом
+	repairable := false
+	if prev != nil {
+		enriched, usage, ok, err := enrichIncrementally(&fileConfig, prev, string(content), sess.limiter)
+		result.Usage = usage
+		if err != nil {
+			logf("Предупреждение: ошибка при инкрементальном обогащении %s: %v", inputPath, err)
+			return result, nil, err
+		}
+		if ok {
+			// Подпись предыдущей обработки добавляется заново после постобработки
+			enrichedDoc = stripDisclosure(enriched)
+			incrementalDone = true
+		} else {
+			logf("Изменения в %s затрагивают большую часть документа, выполняется полное обогащение", inputPath)
+		}
+	}
+
+	sections := findSections(config, string(content))
+	switch {
+	case incrementalDone:
+		// Результат уже подготовлен инкрементальным обогащением
+	case mode == ModeOutline:
+		// Обновление только оглавления, остальной документ сохраняется без изменений
+		logf("Обновление оглавления %s", inputPath)
+		outlined, usage, err := refreshOutline(&fileConfig, string(content), sess.limiter)
+		result.Usage = usage
+		if err != nil {
+			logf("Предупреждение: ошибка при построении оглавления %s: %v", inputPath, err)
+			return result, nil, err
+		}
+		enrichedDoc = outlined
+		keepOriginal = false
+	case mode == ModeSkeleton:
+		// Заполнение только разделов-заготовок, завершенный текст не меняется
+		logf("Заполнение незаполненных разделов %s: %d", inputPath, len(placeholders))
+		filled, usage, err := fillPlaceholders(&fileConfig, string(content), placeholders, sess.limiter)
+		result.Usage = usage
+		if err != nil {
+			logf("Предупреждение: ошибка при заполнении разделов %s: %v", inputPath, err)
+			return result, nil, err
+		}
+		enrichedDoc = filled
+		keepOriginal = false
+	case len(sections) > 0:
+		// Обогащение только выделенных разделов, остальной документ сохраняется без изменений
+		logf("Обогащение разделов %s: %d", inputPath, len(sections))
+		replacements := make([]string, len(sections))
+		for i, sec := range sections {
+			enriched, usage, err := enrichContentWithUsage(&fileConfig, sec.Text(string(content)), sess.limiter)
+			result.Usage = result.Usage.Add(usage)
+			if err != nil {
+				logf("Предупреждение: ошибка при обогащении раздела %d файла %s: %v", i+1, inputPath, err)
+				return result, nil, err
+			}
+			replacements[i] = enriched
+		}
+		enrichedDoc = spliceSections(string(content), sections, replacements)
+		keepOriginal = false
+	default:
+		// Результат пакетного запроса, если файл был обработан в составе пакета; в пакет
+		// не входят конфликтные копии, поэтому при объединении с ними он не используется
+		if batched, ok := sess.batcher.Take(inputPath); ok && len(result.ConflictCopies) == 0 {
+			result.Usage = batched.Usage
+			enrichedDoc = batched.Content
+			repairable = true
+			break
+		}
+
+		// Документ, который не помещается в контекст модели, обогащается по частям
+		chunks := documentChunks(&fileConfig, string(content), oversized)
+		if len(chunks) > 1 {
+			logf("Документ %s не помещается в контекст модели, обогащение по частям: %d", inputPath, len(chunks))
+		}
+		enrichedChunks := make([]string, 0, len(chunks))
+		previous := newChunkContext(&fileConfig)
+		for i, chunk := range chunks {
+			// Обогащение содержимого с контекстом предыдущих частей
+			enrichedContent, usage, err := enrichContentWithUsage(previous.Apply(&fileConfig), chunk, sess.limiter)
+			result.Usage = result.Usage.Add(usage)
+			if err != nil {
+				if len(chunks) > 1 {
+					logf("Предупреждение: ошибка при обогащении части %d файла %s: %v", i+1, inputPath, err)
+				} else {
+					logf("Предупреждение: ошибка при обогащении содержимого %s: %v", inputPath, err)
+				}
+				return result, nil, err // Возвращаем ошибку и прекращаем обработку файла
+			}
+			enrichedChunks = append(enrichedChunks, enrichedContent)
+			previous.Add(chunk, enrichedContent)
+		}
+		enrichedDoc = joinChunks(enrichedChunks)
+		repairable = len(chunks) == 1
+	}
+
+	// Локальная постобработка обогащенного документа
+	enrichedDoc = postProcess(config, enrichedDoc)
+	result.Metrics = compareMetrics(string(content), enrichedDoc, lang)
+
+	// Проверка результата против оригинала; при обогащении отдельных разделов
+	// остальной документ совпадает с оригиналом, поэтому проверка не выполняется
+	doubtful := false
+	if keepOriginal {
+		issue, rejected := config.Guard.inspect(string(content), enrichedDoc, result.Metrics)
+		// Один повторный запрос с описанием того, что не так с ответом
+		if rejected && repairable && config.Guard.Repair {
+			logf("Результат обогащения %s не прошел проверку (%s), повторный запрос с исправлением", relPath, issue.Reason)
+			result.Repair = issue.Reason
+			repairConfig := fileConfig
+			repairConfig.Prompt = repairPrompt(fileConfig.Prompt, issue)
+			repaired, usage, err := enrichContentWithUsage(&repairConfig, string(content), sess.limiter)
+			result.Usage = result.Usage.Add(usage)
+			if err != nil {
+				logf("Предупреждение: ошибка при повторном обогащении %s: %v", inputPath, err)
+				return result, nil, err
+			}
+			enrichedDoc = postProcess(config, repaired)
+			result.Metrics = compareMetrics(string(content), enrichedDoc, lang)
+			if issue, rejected = config.Guard.inspect(string(content), enrichedDoc, result.Metrics); !rejected {
+				logf("Результат обогащения %s исправлен повторным запросом", relPath)
+			}
+		}
+		if rejected {
+			if config.Guard.Action == GuardWarn {
+				warnf("Предупреждение: результат обогащения %s вызывает сомнения: %s", relPath, issue.Reason)
+				doubtful = true
+			} else {
+				logf("Предупреждение: результат обогащения %s отклонен: %s", relPath, issue.Reason)
+				return result, nil, withCategory(ErrorValidation, errorf("результат отклонен проверкой: %s", issue.Reason))
+			}
+		}
+	}
+
+	// Язык результата для проверки орфографии и типографики
+	resultLang := detectLanguage(enrichedDoc)
+	if resultLang == "" {
+		resultLang = lang
+	}
+
+	// Проверка орфографии: опечатки, которых нет в оригинале, сообщаются, а при
+	// action = fix однозначные опечатки исправляются; ошибка проверки не прерывает обработку
+	if config.Spellcheck.Enabled {
+		checked, issues, err := config.Spellcheck.Check(enrichedDoc, string(content), resultLang)
+		if err != nil {
+			warnf("Предупреждение: не удалось проверить орфографию %s: %v", relPath, err)
+		} else {
+			enrichedDoc, result.Spelling = checked, issues
+			if len(issues) > 0 {
+				warnf("Предупреждение: опечатки в %s: %d", relPath, len(issues))
+				for _, s := range issues {
+					logf("Орфография %s:%d: %s", relPath, s.Line, s)
+				}
+			}
+		}
+	}
+
+	// Типографика по правилам языка результата: кавычки, тире, неразрывные пробелы
+	if rules, ok := config.Typography.rulesFor(resultLang); ok {
+		enrichedDoc = applyTypography(rules, enrichedDoc)
+	}
+
+	// Заголовок и имя файла, предложенные моделью; ошибка подбора не прерывает обработку
+	if config.Titles.Enabled && sess.titles != nil {
+		proposal, usage, err := proposeTitle(&fileConfig, enrichedDoc, sess.limiter)
+		result.Usage = result.Usage.Add(usage)
+		if err != nil {
+			warnf("Предупреждение: не удалось подобрать заголовок для %s: %v", relPath, err)
+		} else {
+			enrichedDoc = applyTitle(enrichedDoc, proposal.Title)
+			result.Title, result.Slug = proposal.Title, proposal.Slug
+			// Документы, сохраняемые в исходном формате, и сжатые заметки не переименовываются
+			if config.Titles.Rename && result.Output == "" && !convertBack && !compressed {
+				var output string
+				outputPath, output = titledOutputPath(config, sess.titles, key, relPath, outputPath, proposal.Slug, lang)
+				if !samePath(output, key) {
+					result.Output = output
+					logf("Результат %s сохраняется под именем %s", relPath, filepath.Base(outputPath))
+				}
+			}
+		}
+	}
+
+	// Проверка ссылок обогащенного документа
+	if sess.linkChecker != nil {
+		result.BrokenLinks = sess.linkChecker.Check(enrichedDoc, string(content), outputPath)
+		for _, b := range result.BrokenLinks {
+			origin := tr("из оригинала")
+			if b.Introduced {
+				origin = tr("добавлена моделью")
+			}
+			logf("Предупреждение: битая ссылка в %s: %s (%s, %s)", relPath, b.Target, b.Reason, origin)
+		}
+		if config.StripIntroducedLinks {
+			enrichedDoc = stripIntroducedLinks(enrichedDoc, result.BrokenLinks)
+		}
+		if config.AnnotateBrokenLinks {
+			enrichedDoc = annotateBrokenLinks(enrichedDoc, result.BrokenLinks)
+		}
+	}
+
+	// Механические правила руководства по стилю: нарушения только сообщаются
+	result.StyleViolations = config.Style.Check(enrichedDoc)
+	if len(result.StyleViolations) > 0 {
+		warnf("Предупреждение: нарушения руководства по стилю в %s: %d", relPath, len(result.StyleViolations))
+		for _, v := range result.StyleViolations {
+			logf("Стиль %s:%d (%s): %s", relPath, v.Line, v.Rule, v.Text)
+		}
+	}
+
+	// Карточки для интервального повторения; ошибка извлечения не прерывает обработку
+	var cards []byte
+	if config.Flashcards.Enabled {
+		extracted, usage, err := extractFlashcards(&fileConfig, enrichedDoc, sess.limiter)
+		result.Usage = result.Usage.Add(usage)
+		if err != nil {
+			warnf("Предупреждение: не удалось извлечь карточки из %s: %v", relPath, err)
+		} else if len(extracted) > 0 {
+			cards = renderFlashcards(config.Flashcards, relPath, extracted)
+			result.Cards = len(extracted)
+		}
+	}
+
+	// Источники, подтвержденные запросом к их адресам; ошибка подбора не прерывает обработку
+	if config.Citations.Enabled && sess.citations != nil {
+		citations, usage, err := suggestCitations(&fileConfig, enrichedDoc, sess.limiter)
+		result.Usage = result.Usage.Add(usage)
+		if err != nil {
+			warnf("Предупреждение: не удалось подобрать источники для %s: %v", relPath, err)
+		} else {
+			sess.citations.Verify(citations)
+			for _, c := range citations {
+				if !c.Verified {
+					logf("Источник %s для %s не подтвержден: %s", c.URL, relPath, c.Reason)
+				}
+			}
+			result.Citations = citations
+			if config.Citations.shown(citations) > 0 {
+				enrichedDoc = withCitations(enrichedDoc, renderCitations(config.Citations, citations))
+			} else {
+				enrichedDoc = stripCitations(enrichedDoc)
+			}
+		}
+	}
+
+	// Список изменений относительно оригинала; ошибка запроса не прерывает обработку
+	if config.Changelog.Enabled {
+		changes, usage, err := summarizeChanges(&fileConfig, stripChanges(string(content)), stripChanges(enrichedDoc), sess.limiter)
+		result.Usage = result.Usage.Add(usage)
+		if err != nil {
+			warnf("Предупреждение: не удалось составить список изменений %s: %v", relPath, err)
+		}
+		result.Changes = changes
+		if config.Changelog.inSection() {
+			if len(changes) > 0 {
+				enrichedDoc = withChanges(enrichedDoc, renderChanges(config.Changelog.Heading, changes))
+			} else {
+				enrichedDoc = stripChanges(enrichedDoc)
+			}
+		}
+	}
+
+	// Ссылки на самые похожие документы корпуса
+	if config.Related.Enabled {
+		links := findRelated(sess.related, relPath, config.Related.TopK, config.Related.MinSimilarity)
+		for i := range links {
+			links[i].RelPath = titledRelPath(config, sess.titles, links[i].RelPath)
+			result.Related = append(result.Related, normalizeRelPath(links[i].RelPath))
+		}
+		if len(links) > 0 {
+			outputRel := filepath.Join(filepath.Dir(relPath), filepath.Base(outputPath))
+			enrichedDoc = withRelated(enrichedDoc, renderRelated(config.Related, outputRel, links))
+		} else {
+			enrichedDoc = stripRelated(enrichedDoc)
+		}
+	}
+
+	// Ссылка на исходный документ PDF
+	if sourcePDF != "" {
+		enrichedDoc = withSourceLink(enrichedDoc, renderSourceLink(config.PDF.SourceLabel, outputPath, sourcePDF))
+	}
+
+	// Сведения о результате для подписи и приемников
+	fileConfig.File.EnrichedWords = documentWords(enrichedDoc, resultLang)
+
+	// Подпись о раскрытии использования ИИ
+	if config.Disclosure {
+		enrichedDoc = withDisclosure(enrichedDoc, renderDisclosure(config.DisclosureTemplate, disclosureInfo{
+			Model:    fileConfig.ModelName,
+			Date:     time.Now(),
+			Prompt:   basePrompt,
+			Route:    result.Route,
+			Language: lang,
+			RunID:    sess.runID,
+			File:     fileConfig.File,
+		}))
+	}
+
+	// Результат в исходном формате не содержит блока оригинала: оригинал остается
+	// во входной директории
+	if convertBack {
+		keepOriginal = false
+	}
+
+	// Статус обогащенного файла в frontmatter результата
+	enrichedDoc = config.Workflow.Apply(enrichedDoc)
+
+	// Оператор запуска для проверяющих в общей выходной директории
+	if config.Operator != "" {
+		enrichedDoc = setFrontmatterField(enrichedDoc, OperatorKey, config.Operator)
+	}
+
+	// Вариант промпта в эксперименте для проверяющих
+	if result.Variant != "" {
+		enrichedDoc = setFrontmatterField(enrichedDoc, VariantKey, result.Variant)
+	}
+
+	// Результат, объединенный из конфликтных копий или не прошедший проверку при
+	// action = warn, отмечается для проверки
+	switch {
+	case len(result.ConflictCopies) > 0:
+		enrichedDoc = setFrontmatterField(enrichedDoc, ReviewKey, ReviewConflictMerge)
+	case doubtful:
+		enrichedDoc = setFrontmatterField(enrichedDoc, ReviewKey, ReviewGuard)
+	}
+
+	// Усеченный файл: часть, не отправленная в модель, сохраняется без изменений
+	// (в блоке оригинала или, если оригинал не добавляется, после результата)
+	if len(tail) > 0 && !keepOriginal {
+		enrichedDoc = strings.TrimRight(enrichedDoc, "\n") + "\n\n" + string(tail)
+	}
+
+	finalContent := []byte(enrichedDoc)
+	if keepOriginal {
+		// Экранирование тройных обратных кавычек в оригинальном содержимом
+		escapedContent := strings.ReplaceAll(string(original), "```", "\\`\\`\\`")
+
+		// Объединение обогащенного содержимого с оригинальным в указанном формате
+		finalContent = []byte(fmt.Sprintf("%s\n\n```old\n%s\n```", enrichedDoc, escapedContent))
+	}
+	if convertBack {
+		if finalContent, err = config.Pandoc.fromMarkdown(finalContent, pandocFormat, inputPath); err != nil {
+			return result, nil, errorf("ошибка конвертации результата %s в %s: %v", relPath, pandocFormat, err)
+		}
+	}
+	if compressed && config.Gzip.CompressOutput {
+		if finalContent, err = gzipBytes(finalContent); err != nil {
+			return result, nil, errorf("ошибка сжатия результата %s: %v", relPath, err)
+		}
+	}
+
+	return result, &pendingWrite{config: config, relPath: relPath, outputPath: outputPath, content: finalContent, result: result,
+		inputPath: inputPath, inputHash: inputHash, cards: cards, file: fileConfig.File}, nil
+}
+
+// Запись подготовленного результата: резервная копия прежнего файла, безопасная
+// запись и добавление файла в список исключений
+func (w *pendingWrite) Write(configPath string, sess *session) error {
+	config, relPath, outputPath, result := w.config, w.relPath, w.outputPath, w.result
+	result.InputHash = w.inputHash
+
+	// Без приемника local результат только передается остальным приемникам
+	if !config.Sinks.WritesLocal() {
+		return w.publishOnly(configPath, sess)
+	}
+
+	// Результат, измененный вручную после прошлого обогащения, не перезаписывается:
+	// новое обогащение сохраняется рядом в файл .new для ручного слияния
+	finalPath := outputPath
+	if config.OnConflict != OnConflictOverwrite && sess.outputConflict(rootKey(config.RootName, relPath), finalPath) {
+		outputPath += ConflictSuffix
+		result.Conflict = outputPath
+		w.cards = nil
+	}
+
+	// Результат больше max_output_size записывается частями со ссылками между ними;
+	// в выходной файл записывается первая часть
+	if result.Conflict == "" && config.MaxOutputSize > 0 && isMarkdownPath(outputPath) {
+		w.content, w.pages = paginateOutput(w.content, outputPath, config.MaxOutputSize)
+		result.Pages = nil
+		for i := range w.pages {
+			result.Pages = append(result.Pages, pagePath(outputPath, i+2))
+		}
+		if len(w.pages) > 0 {
+			logf("Результат %s больше %d байт и записывается частями: %d", outputPath, config.MaxOutputSize, len(w.pages)+1)
+		}
+	}
+	intent := outputIntent{Key: rootKey(config.RootName, relPath), Input: w.inputPath, Output: outputPath,
+		InputHash: w.inputHash, OutputHash: contentHash(w.content), RunID: sess.runID}
+
+	// Подготовка директории для выходного файла
+	writeStarted := time.Now()
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return withCategory(ErrorIO, errorf("ошибка при создании выходной директории: %v", err))
+	}
+
+	// Резервная копия прежнего выходного файла для отмены запуска
+	if sess.journal != nil && result.Conflict == "" {
+		backup, err := sess.journal.Backup(outputPath, rootKey(config.RootName, relPath))
+		if err != nil {
+			return withCategory(ErrorIO, errorf("ошибка при резервном копировании выходного файла: %v", err))
+		}
+		result.Backup = backup
+	}
+
+	// Транзакционный запуск: результат и список исключений обновляются при фиксации
+	wasExcluded := isExcluded(config, rootKey(config.RootName, relPath))
+	if sess.txn != nil {
+		// Путь результата относительно выходной директории (с [TITLES] rename имя
+		// результата отличается от имени исходного файла)
+		outputRoot, err := filepath.Abs(config.OutputDir)
+		outputRel := relPath
+		if err == nil {
+			if rel, rerr := filepath.Rel(outputRoot, outputPath); rerr == nil && isRelPathSafe(rel) {
+				outputRel = rel
+			}
+			err = sess.txn.Stage(outputRoot, outputRel, intent, w.content)
+		}
+		if err == nil && w.cards != nil {
+			format := config.Flashcards.Format
+			if err = sess.txn.StageCompanion(outputRoot, cardsPath(outputRel, format), cardsPath(outputPath, format), w.cards); err == nil {
+				result.CardsFile = cardsPath(outputPath, format)
+			}
+		}
+		for i := 0; err == nil && i < len(w.pages); i++ {
+			err = sess.txn.StageCompanion(outputRoot, pagePath(outputRel, i+2), pagePath(outputPath, i+2), w.pages[i])
+		}
+		if err != nil {
+			return withCategory(ErrorIO, errorf("ошибка при записи выходного файла: %v", err))
+		}
+		if result.Conflict == "" && isMarkdownPath(outputPath) {
+			removeStalePages(outputPath, len(w.pages)+1)
+		}
+		w.publish(sess, outputPath, result.CardsFile)
+		result.OutputHash = contentHash(w.content)
+		result.AddedToExcluded = !wasExcluded
+		w.snapshot()
+		result.Timeline.Written = time.Now()
+		result.Timeline.WriteMS = result.Timeline.Written.Sub(writeStarted).Milliseconds()
+		result.Written = outputPath
+		logf("Обогащенное содержимое %s подготовлено к фиксации транзакции", outputPath)
+		result.Status = writtenStatus(finalPath, result)
+		return nil
+	}
+
+	// Намерение записи: если процесс завершится до обновления списка исключений,
+	// следующий запуск досохранит состояние без повторного запроса к API
+	var intentFile string
+	if config.StateDir != "" {
+		path, err := saveIntent(config.StateDir, intent)
+		if err != nil {
+			return withCategory(ErrorIO, err)
+		}
+		intentFile = path
+	}
+
+	// Безопасная запись результата
+	if err := safeWriteFile(outputPath, w.content, 0644); err != nil {
+		return withCategory(ErrorIO, errorf("ошибка при записи выходного файла: %v", err))
+	}
+	for i, page := range w.pages {
+		if err := safeWriteFile(pagePath(outputPath, i+2), page, 0644); err != nil {
+			return withCategory(ErrorIO, errorf("ошибка при записи части результата: %v", err))
+		}
+	}
+	if result.Conflict == "" && isMarkdownPath(outputPath) {
+		removeStalePages(outputPath, len(w.pages)+1)
+	}
+	result.OutputHash = intent.OutputHash
+	result.Written = outputPath
+	w.snapshot()
+
+	// Карточки рядом с результатом; ошибка записи карточек не отменяет результат
+	if w.cards != nil {
+		path := cardsPath(outputPath, config.Flashcards.Format)
+		if err := safeWriteFile(path, w.cards, 0644); err != nil {
+			warnf("Предупреждение: не удалось сохранить карточки %s: %v", path, err)
+		} else {
+			result.CardsFile = path
+		}
+	}
+	w.publish(sess, outputPath, result.CardsFile)
+
+	// Добавляем обработанный файл в список исключений только при успешном обогащении
+	if err := addToExcludedFiles(configPath, rootKey(config.RootName, relPath)); err != nil {
+		// Обрабатываем ошибку, но не прерываем выполнение
+		warnf("Предупреждение: не удалось добавить файл в список исключений: %v", err)
+		// Попытка повторить операцию; если и она не удалась, намерение записи остается
+		// и список исключений будет обновлен при следующем запуске
+		if retryErr := addToExcludedFiles(configPath, rootKey(config.RootName, relPath)); retryErr != nil {
+			logErrorf("Ошибка при повторной попытке добавить файл в список исключений: %v", retryErr)
+			intentFile = ""
+		} else {
+			result.AddedToExcluded = !wasExcluded
+		}
+	} else {
+		result.AddedToExcluded = !wasExcluded
+	}
+	if intentFile != "" {
+		removeIntent(intentFile)
+	}
+
+	result.Timeline.Written = time.Now()
+	result.Timeline.WriteMS = result.Timeline.Written.Sub(writeStarted).Milliseconds()
+
+	logf("Сохранено обогащенное содержимое в %s", outputPath)
+	result.Status = writtenStatus(finalPath, result)
+	return nil
+}
+
+// Передача записанного результата приемникам; результат, сохраненный рядом
+// с измененным вручную файлом, не передается, результат в очереди проверки
+// передается при одобрении (rich approve). Ошибка приемника не отменяет записи
+func (w *pendingWrite) publish(sess *session, outputPath, cardsFile string) {
+	if w.result.Conflict != "" || w.config.ReviewDir != "" {
+		return
+	}
+	written := append([]string{outputPath}, w.result.Pages...)
+	if cardsFile != "" {
+		written = append(written, cardsFile)
+	}
+	if err := sess.sinks.Publish(w.sinkOutput(outputPath, written)); err != nil {
+		warnf("Предупреждение: %v", err)
+	}
+}
+
+// Результат для приемников: путь относительно выходной директории корня
+func (w *pendingWrite) sinkOutput(outputPath string, written []string) sinkOutput {
+	out := sinkOutput{Key: rootKey(w.config.RootName, w.relPath), RelPath: normalizeRelPath(w.relPath), Content: w.content, Written: written, File: w.file}
+	if outputRoot, err := filepath.Abs(w.config.OutputDir); err == nil {
+		out.OutputDir = outputRoot
+		if abs, err := filepath.Abs(outputPath); err == nil {
+			if rel, err := filepath.Rel(outputRoot, abs); err == nil && isRelPathSafe(rel) {
+				out.RelPath = normalizeRelPath(rel)
+			}
+		}
+	}
+	return out
+}
+
+// Запись без выходной директории: результат передается приемникам, и только после
+// этого файл добавляется в список исключений
+func (w *pendingWrite) publishOnly(configPath string, sess *session) error {
+	config, result := w.config, w.result
+	key := rootKey(config.RootName, w.relPath)
+	writeStarted := time.Now()
+	if err := sess.sinks.Publish(w.sinkOutput(w.outputPath, nil)); err != nil {
+		return withCategory(ErrorIO, err)
+	}
+	result.OutputHash = contentHash(w.content)
+
+	wasExcluded := isExcluded(config, key)
+	if err := addToExcludedFiles(configPath, key); err != nil {
+		warnf("Предупреждение: не удалось добавить файл в список исключений: %v", err)
+	} else {
+		result.AddedToExcluded = !wasExcluded
+	}
+
+	result.Timeline.Written = time.Now()
+	result.Timeline.WriteMS = result.Timeline.Written.Sub(writeStarted).Milliseconds()
+	logf("Обогащенное содержимое %s передано приемникам результатов", key)
+	result.Status = StatusEnriched
+	return nil
+}
+
+// Копия записанного результата в каталоге состояния: прежнее обогащение для слияния
+// с ручными правками (rich merge). Новое обогащение при конфликте не сохраняется
+func (w *pendingWrite) snapshot() {
+	if w.config.StateDir == "" || w.result.Conflict != "" {
+		return
+	}
+	if err := saveOutputSnapshot(w.config.StateDir, w.content); err != nil {
+		warnf("Предупреждение: не удалось сохранить копию результата: %v", err)
+	}
+}
+
+// Итоговый статус записанного результата: конфликт, если новое обогащение сохранено
+// рядом с измененным вручную результатом
+func writtenStatus(outputPath string, result *fileResult) string {
+	if result.Conflict == "" {
+		return StatusEnriched
+	}
+	warnf("Предупреждение: %s изменен вручную после обогащения, новый результат сохранен в %s", outputPath, result.Conflict)
+	return StatusConflict
+}
+
+// Сбор markdown файлов входной директории с учетом глубины, .richignore, списка
+// исключенных файлов и фильтра по времени изменения
+func collectCandidates(config *Config, inputDir, outputDir string, excluded excludedIndex) ([]candidate, error) {
+	var candidates []candidate
+	err := walkCandidates(config, inputDir, outputDir, excluded, func(c candidate) error {
+		candidates = append(candidates, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// Обход входной директории с передачей каждого подходящего файла в yield по мере
+// обнаружения; ошибка yield прерывает обход
+func walkCandidates(config *Config, inputDir, outputDir string, excluded excludedIndex, yield func(candidate) error) error {
+	ignore := newIgnoreRules(config.IgnorePatterns...)
+	err := walkDirParallel(inputDir, config.WalkWorkers, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Обработка директорий: глубина, локальные исключения .richignore
+		if d.IsDir() {
+			relDir, err := filepath.Rel(inputDir, path)
+			if err != nil {
+				return errorf("ошибка при получении относительного пути: %v", err)
+			}
+			if relDir != "." && ignore.Match(relDir, true) {
+				logf("Пропуск исключенной директории: %s", relDir)
+				return filepath.SkipDir
+			}
+			if config.MaxDepth > 0 && pathDepth(relDir) >= config.MaxDepth {
+				return filepath.SkipDir
+			}
+			excludeSubtree, err := ignore.Load(path, relDir)
+			if err != nil {
+				return err
+			}
+			if excludeSubtree {
+				logf("Пропуск директории %s: найден пустой %s", relDir, IgnoreFileName)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Проверка расширения файла (markdown и форматы pandoc); промпты директорий
+		// и манифесты сборки не обрабатываются
+		if !config.isInputFile(d.Name()) || d.Name() == DirPromptFileName || d.Name() == CompileManifestFileName {
+			return nil
+		}
+
+		// Определение пути относительно входной директории
+		relPath, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return errorf("ошибка при получении относительного пути: %v", err)
+		}
+
+		// Проверка на path traversal
+		if !isRelPathSafe(relPath) {
+			return errorf("обнаружена попытка path traversal: %s", relPath)
+		}
+
+		// Проверка на исключенные файлы по относительному пути
+		relPath = filepath.Clean(relPath)
+		// Конфликтная копия не обрабатывается отдельно: при conflict_copies = merge она
+		// объединяется с основной заметкой
+		if _, ok := conflictCopyOriginal(d.Name()); ok {
+			logf("Пропуск конфликтной копии: %s", relPath)
+			return nil
+		}
+		// Часть результата, записанного во входную директорию
+		if samePath(inputDir, outputDir) && isResultPage(path) {
+			return nil
+		}
+		if ignore.Match(relPath, false) {
+			logf("Пропуск исключенного файла: %s", relPath)
+			return nil
+		}
+		if config.OnlyFiles != nil && !config.OnlyFiles[pathKey(rootKey(config.RootName, relPath))] {
+			return nil
+		}
+		if !config.Shard.Contains(rootKey(config.RootName, relPath)) {
+			return nil
+		}
+		if excluded.Contains(rootKey(config.RootName, relPath)) {
+			// Ранее обогащенный файл, изменившийся с прошлого запуска, обрабатывается
+			// инкрементально, а файл с новой конфликтной копией - объединяется с ней
+			resultPath := resultPathFor(config, filepath.Join(outputDir, relPath))
+			if (!config.Incremental || !needsIncrementalUpdate(path, resultPath)) && !config.conflictMergePending(path, resultPath) {
+				logf("Пропуск исключенного файла: %s", relPath)
+				return nil
+			}
+			logf("Файл изменился после обогащения: %s", relPath)
+		}
 
-	// Обход всех .md файлов в директории и поддиректориях
-	err = filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+		// Фильтр по времени изменения файла
+		info, err := d.Info()
 		if err != nil {
 			return err
 		}
-
-		// Пропуск директорий
-		if info.IsDir() {
+		if !modTimeInRange(info.ModTime(), config.ModifiedAfter, config.ModifiedBefore) {
 			return nil
 		}
 
-		// Проверка расширения файла
-		if !strings.HasSuffix(strings.ToLower(info.Name()), ".md") {
-			return nil
+		return yield(candidate{Path: path, RelPath: relPath, Info: info})
+	})
+
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return errorf("ошибка при обходе директории: %v", err)
+	}
+	return err
+}
+
+// Обработка директории для получения всех markdown файлов
+func processDirectory(config *Config, configPath string) error {
+	// Проверка промптов до первого запроса
+	if err := checkPrompts(config); err != nil {
+		return err
+	}
+
+	// Досохранение состояния файлов, записанных перед аварийным завершением прошлого запуска
+	recovered, err := recoverIntents(config, configPath)
+	if err != nil {
+		return err
+	}
+
+	// Файлы, отложенные прошлым запуском без соединения с API: пока API недоступен,
+	// запуск не начинается, иначе они обрабатываются первыми
+	queued, err := loadOfflineQueue(config.StateDir)
+	if err != nil {
+		return err
+	}
+	queuedKeys := make(map[string]bool, len(queued.Files))
+	if len(queued.Files) > 0 {
+		if err := apiReachable(config); err != nil {
+			return &offlineError{Queued: len(queued.Files), Err: err}
+		}
+		infof("Обработка файлов, отложенных без соединения с API с %s: %d", queued.Since.Local().Format(time.DateTime), len(queued.Files))
+		for _, key := range queued.Files {
+			queuedKeys[key] = true
 		}
+	}
+	offline := newOfflineTracker(config)
+	stoppedOffline := false
 
-		// Определение пути относительно входной директории
-		relPath, err := filepath.Rel(inputDir, path)
+	// Отсортированный список исключенных файлов для поиска без отдельного множества
+	excluded := newExcludedIndex(config.ExcludedFiles)
+
+	// Документы других форматов обрабатываются, только если установлен pandoc
+	config.Pandoc.checkBinary()
+	config.Spellcheck.checkBinary()
+
+	// Создание ограничителя частоты запросов
+	sess := newSession(config.newRateLimiter())
+	sess.experiment = newPromptExperiment(config)
+	if config.OutputLayout == OutputLayoutPerRun {
+		sess.runDir = time.Now().Format(runDirLayout)
+	}
+
+	// Журнал запуска с уникальным идентификатором
+	if config.StateDir != "" {
+		journal, err := startRun(config.StateDir, configPath, config.Operator)
 		if err != nil {
-			return fmt.Errorf("ошибка при получении относительного пути: %v", err)
+			return err
+		}
+		sess.journal = journal
+		sess.runID = journal.ID
+		prefix := log.Prefix()
+		log.SetPrefix(fmt.Sprintf("[%s] ", journal.ID))
+		defer log.SetPrefix(prefix)
+		infof("Начат запуск %s", journal.ID)
+
+		// Результаты прошлых запусков для обнаружения ручных правок выходных файлов
+		if sess.recorded, err = latestOutputs(config.StateDir); err != nil {
+			return err
 		}
 
-		// Проверка на path traversal
-		if strings.Contains(relPath, "..") {
-			return fmt.Errorf("обнаружена попытка path traversal: %s", relPath)
+		// Итоги файлов для продолжения прерванного запуска (--resume)
+		if sess.manifest, err = loadStateManifest(config.StateDir); err != nil {
+			return err
 		}
+		sess.manifest.deferred = config.Transactional
 
-		// Проверка на исключенные файлы по относительному пути
-		relPath = filepath.Clean(relPath)
-		if excludedMap[relPath] {
-			log.Printf("Пропуск исключенного файла: %s", relPath)
-			return nil
+		// Статистика по директориям сохраняется и при прерванном запуске: затраты уже понесены
+		sess.dirStats = newDirStats(config.StateDir, journal.ID)
+		defer func() {
+			if err := sess.dirStats.Save(); err != nil {
+				warnf("Предупреждение: %v", err)
+			}
+		}()
+	} else if config.Resume {
+		return withCategory(ErrorConfig, errorf("--resume требует каталога состояния: задайте dir в секции [STATE]"))
+	}
+
+	// Источник векторов для индекса контекста и поиска похожих документов
+	var embedder Embedder
+	if config.ContextDir != "" || config.DedupThreshold > 0 || config.Related.Enabled {
+		if embedder, err = newEmbedder(config); err != nil {
+			return err
+		}
+	}
+
+	// Построение индекса директории контекста
+	if config.ContextDir != "" {
+		index, err := buildContextIndex(config.ContextDir, config.ContextChunkWords, embedder)
+		if err != nil {
+			return err
+		}
+		sess.contextIndex = index
+	}
+
+	// Общий с другими экземплярами лимит запросов и распределение файлов через Redis
+	if config.Redis.URL != "" {
+		if sess.claims, err = setupRedisCoordination(config, sess.limiter, sess.runID); err != nil {
+			return err
 		}
+	}
+
+	// Проверка адресов источников
+	if config.Citations.Enabled {
+		sess.citations = newCitationVerifier(config)
+	}
+
+	// Приемники результатов помимо выходной директории (git, s3, stdout)
+	if config.Transactional && !config.Sinks.WritesLocal() {
+		return withCategory(ErrorConfig, errorf("--transactional работает только с приемником local в секции [OUTPUT]"))
+	}
+	if sess.sinks, err = newSinkSet(config, os.Stdout); err != nil {
+		return withCategory(ErrorConfig, err)
+	}
+	if config.Sinks.Has(SinkStdout) {
+		releaseStdout(console == nil || !console.color)
+	}
 
-		// Определение пути выходного файла
-		outputPath := filepath.Join(outputDir, relPath)
+	// Изменения документов для общего файла изменений
+	if config.Changelog.inFile() {
+		sess.changes = newRunChangelog()
+	}
 
-		// Обработка файла
-		if err := processFile(config, path, outputPath, configPath, rateLimiter); err != nil {
-			log.Printf("Ошибка при обработке %s: %v", path, err)
-			return nil // Продолжаем с другими файлами
+	// Соответствия заголовков и имен результатов
+	if config.Titles.Enabled {
+		if sess.titles, err = loadTitleMap(config.titlesFile()); err != nil {
+			return err
 		}
+	}
+
+	// Управление паузой через сигналы (SIGUSR1 - пауза, SIGUSR2 - продолжение)
+	gate := newPauseGate()
+	stopSignals := watchPauseSignals(gate)
+	defer stopSignals()
+
+	// Бюджет запуска (--max-files, --max-usd)
+	budget := newRunBudget(config)
+	if config.MaxUSD > 0 && config.InputPrice == 0 && config.OutputPrice == 0 {
+		warnf("Предупреждение: задан --max-usd, но цены input_price/output_price не указаны в секции [MODEL]")
+	}
+
+	// Оповещения о расходах за день и сериях ответов 429 во время запуска
+	alerts := newQuotaAlerts(config, sess.runID)
+
+	// Отчет и манифест запуска
+	report := newRunReport()
+	var runFiles *runManifestFiles
+	if config.RunManifestFile != "" {
+		runFiles = &runManifestFiles{}
+	}
+	report.RunID = sess.runID
+	report.Operator = config.Operator
+	report.Shard = config.Shard.String()
+	report.Recovered = recovered
+	if config.Shard.Count > 1 {
+		infof("Часть %s: обрабатываются только файлы этой части", config.Shard)
+	}
+	statusEvents.Emit(statusEvent{Event: EventRunStarted, RunID: sess.runID})
+
+	// Счетчики обработанных и пропущенных файлов и файлов с ошибками по категориям
+	fileCount := 0
+	skippedCount := 0
+	conflictCount := 0
+	failures := make(map[string]int)
+	// Остановка запуска по политике on_error и при системных ошибках с начала запуска
+	failTracker := newFailureTracker(config.OnError, config.SystemicAfter)
+
+	// Транзакционный запуск: результаты переносятся в выходные директории в конце
+	if config.Transactional {
+		sess.txn = newTransaction()
+		defer sess.txn.Rollback()
+	}
 
+	// Этап записи: итог файла учитывается в отчете и журнале после записи результата
+	pipeline := newOutputPipeline(config.WriteQueue, configPath, sess, func(item *pipelineItem) {
+		result, err := item.Result, item.Err
+		report.Add(config, item.Key, result, err)
+		runFiles.Record(config, item.Key, item.Path, result, err)
+		console.FileResult(item.Key, result, result.Usage.Cost(config), err)
+		statusEvents.Emit(fileFinishedEvent(config, item.Key, item.OutputPath, result, err))
+		if err == nil && result.Title != "" && sess.titles != nil {
+			output := result.Output
+			if output == "" {
+				output = item.Key
+			}
+			sess.titles.Set(item.Key, titleEntry{Title: result.Title, Slug: result.Slug, Output: output})
+		}
+		if err == nil {
+			sess.changes.Add(item.Key, item.OutputPath, result.Changes)
+		}
+		if sess.journal != nil {
+			if jerr := sess.journal.Record(newJournalEntry(item.Key, item.OutputPath, result, err)); jerr != nil {
+				warnf("Предупреждение: %v", jerr)
+			}
+		}
+		if sess.manifest != nil {
+			sess.manifest.Record(item.Key, result, err, sess.runID)
+		}
+		if sess.dirStats != nil {
+			sess.dirStats.Record(item.Key, result, err, result.Usage.Cost(config))
+		}
+		if err != nil {
+			failures[errorCategory(err)]++
+			logf("Ошибка при обработке %s: %v", item.Path, err)
+			// После ошибки файл может взять другой экземпляр
+			if sess.claims != nil {
+				if rerr := sess.claims.Release(item.Key); rerr != nil {
+					warnf("Предупреждение: %v", rerr)
+				}
+			}
+			return
+		}
+		if result.Status == StatusConflict {
+			conflictCount++
+			return
+		}
+		if result.Status != StatusEnriched {
+			skippedCount++
+			return
+		}
 		fileCount++
-		return nil
 	})
+	defer pipeline.Close()
+
+	// Параллельная обработка файлов (workers); итог каждого файла учитывается под
+	// блокировкой collect в порядке готовности
+	pool := newFilePool(config.Workers)
+	defer pool.Wait()
+	var collect sync.Mutex
+	var noConnection atomic.Bool
+	resumed := 0
+
+	// Корни входных файлов обрабатываются по очереди с общими журналом, бюджетом и отчетом
+	for _, rootConfig := range config.inputRoots() {
+		// Файлы источника (архив, ref git, S3, stdin) выкладываются во временный каталог,
+		// который обрабатывается как входная директория
+		if rootConfig.InputSource != "" {
+			dir, cleanup, err := fetchSource(rootConfig)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			sourceConfig := *rootConfig
+			sourceConfig.InputDir = dir
+			rootConfig = &sourceConfig
+		}
 
-	if err != nil {
-		return fmt.Errorf("ошибка при обходе директории: %v", err)
+		// Преобразование путей в абсолютные
+		inputDir, err := filepath.Abs(rootConfig.InputDir)
+		if err != nil {
+			return errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+
+		outputDir, err := filepath.Abs(rootConfig.OutputDir)
+		if err != nil {
+			return errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+		}
+
+		// Проверка безопасности путей
+		if !isPathSafe(inputDir) || !isPathSafe(outputDir) {
+			return errorf("обнаружен небезопасный путь директории: %s или %s", inputDir, outputDir)
+		}
+
+		// С очередью проверки результаты пишутся в review_dir до rich approve
+		if rootConfig.ReviewDir != "" {
+			if outputDir, err = filepath.Abs(rootConfig.ReviewDir); err != nil {
+				return errorf("ошибка при получении абсолютного пути директории проверки: %v", err)
+			}
+			reviewConfig := *rootConfig
+			reviewConfig.OutputDir = outputDir
+			rootConfig = &reviewConfig
+		}
+
+		// При output_layout = per-run результаты пишутся в директорию запуска
+		if sess.runDir != "" {
+			outputDir = filepath.Join(outputDir, sess.runDir)
+			runConfig := *rootConfig
+			runConfig.OutputDir = outputDir
+			rootConfig = &runConfig
+		}
+		if rootConfig.RootName != "" {
+			infof("Обработка корня %s: %s -> %s", rootConfig.RootName, inputDir, outputDir)
+		}
+
+		// Индекс документов для добавления выдержек из связанных файлов
+		if config.LinkedDocs {
+			links, err := newLinkResolver(inputDir)
+			if err != nil {
+				return errorf("ошибка при индексации связанных документов: %v", err)
+			}
+			sess.links = links
+		}
+
+		// Корпус документов для ссылок на связанные заметки
+		if config.Related.Enabled {
+			if sess.related, err = buildRelatedIndex(rootConfig, inputDir, embedder); err != nil {
+				return err
+			}
+		}
+
+		// Дневниковые заметки для контекста предыдущих дней
+		if config.DailyNotes.Enabled {
+			if sess.dailyNotes, err = buildDailyNoteIndex(rootConfig, inputDir, outputDir); err != nil {
+				return err
+			}
+		}
+
+		// Пакетная обработка маленьких файлов; при распределении файлов между экземплярами
+		// не используется, так как пакет собирается из еще не закрепленных файлов
+		sess.batcher = newBatcher(rootConfig, outputDir)
+		if sess.claims != nil && sess.batcher != nil {
+			logf("Пакетная обработка выключена: файлы распределяются между экземплярами")
+			sess.batcher = nil
+		}
+
+		// Проверка ссылок обогащенных документов
+		if config.CheckLinks {
+			sess.linkChecker = newLinkChecker(inputDir, outputDir, config.CheckExternalLinks, config.LinkCheckTimeout)
+			sess.linkChecker.flagNewURLs = config.FlagNewURLs
+		}
+
+		// Очередь файлов: при алфавитном порядке файлы обрабатываются по мере обхода
+		// директории, для остальных порядков, поиска похожих документов и файлов, отложенных
+		// без соединения с API (они переносятся в начало), нужен полный список
+		sess.duplicates = nil
+		var queue *candidateQueue
+		if config.streamCandidates() && len(queuedKeys) == 0 {
+			queue = newCandidateStream(rootConfig, inputDir, outputDir, excluded)
+		} else {
+			// Сбор всех .md файлов в директории и поддиректориях
+			candidates, err := collectCandidates(rootConfig, inputDir, outputDir, excluded)
+			if err != nil {
+				return err
+			}
+
+			// Упорядочивание файлов согласно выбранной стратегии
+			sortCandidates(candidates, config.Order, config.PriorityKey)
+			prioritizeCandidates(candidates, config.Priorities)
+			queuedFirst(candidates, rootConfig.RootName, queuedKeys)
+
+			// Поиск почти одинаковых документов до отправки в API
+			if config.DedupThreshold > 0 {
+				if sess.duplicates, err = findDuplicates(config.Policy.allowedCandidates(candidates), embedder, config.DedupThreshold); err != nil {
+					return err
+				}
+				if len(sess.duplicates) > 0 {
+					infof("Найдено похожих документов: %d", len(sess.duplicates))
+				}
+				if sess.batcher != nil && config.DedupAction == DedupSkip {
+					sess.batcher.skipped = sess.duplicates
+				}
+			}
+			queue = newCandidateList(candidates)
+		}
+
+		stopped := false
+		for {
+			c, window, ok := queue.Next(batchLookahead)
+			if !ok {
+				break
+			}
+
+			// Ожидание снятия паузы перед отправкой нового файла
+			gate.Wait()
+
+			// Окно обслуживания провайдера: файлы ждут в очереди до его окончания
+			config.Maintenance.Wait()
+
+			// Лимиты проверяются при свободном обработчике, чтобы учесть итоги всех
+			// завершенных файлов
+			pool.WaitIdle()
+			for {
+				// Остановка службы (SIGTERM, sc stop): текущий файл уже дообработан
+				if serviceStopping.Load() {
+					infof("Остановка обработки: служба останавливается")
+					stopped = true
+					break
+				}
+
+				// Проверка бюджета запуска
+				if reason, exhausted := budget.Exhausted(); exhausted {
+					infof("Остановка обработки: %s", reason)
+					stopped = true
+					break
+				}
+
+				// Суточный лимит запросов и токенов: оставшиеся файлы ждут следующего запуска
+				if reason, exhausted := sess.limiter.daily.Exhausted(); exhausted {
+					warnf("Остановка обработки: %s", reason)
+					stopped = true
+					break
+				}
+
+				// Политика on_error и системные ошибки с начала запуска останавливают запуск
+				if reason, failed := failTracker.Stopped(); failed {
+					logErrorf("Остановка обработки: %s", reason)
+					report.Stopped = reason
+					stopped = true
+					break
+				}
+
+				// Файлы в обработке занимают места в лимитах до учета их итога: если
+				// новый файл может превысить лимит, он ждет завершения одного из них
+				inFlight := pool.Running()
+				if inFlight == 0 || (budget.Admits(inFlight) && sess.limiter.daily.Admits(inFlight) && failTracker.Admits(inFlight)) {
+					break
+				}
+				pool.WaitBelow(inFlight)
+			}
+			if stopped {
+				break
+			}
+
+			// Путь файла в общем состоянии запуска
+			key := rootKey(rootConfig.RootName, c.RelPath)
+
+			// Файл, обработанный до прерывания запуска, с тем же содержимым
+			if config.Resume && sess.manifest.Done(key, c.Path) {
+				logf("Пропуск файла %s: обработан до прерывания запуска", key)
+				resumed++
+				continue
+			}
+
+			// Файл, закрепленный за другим экземпляром, пропускается
+			if sess.claims != nil {
+				claimed, err := sess.claims.Claim(key)
+				if err != nil {
+					warnf("Предупреждение: %v", err)
+					continue
+				}
+				if !claimed {
+					logf("Файл %s обрабатывается другим экземпляром", key)
+					continue
+				}
+			}
+
+			// Пакетный запрос для подряд идущих маленьких файлов
+			sess.batcher.Prefetch(window, budget.RemainingFiles(), sess.limiter)
+
+			// Определение пути выходного файла
+			outputPath := filepath.Join(outputDir, c.RelPath)
+
+			// Обработка файла; запись результата передается этапу записи, а затраты
+			// учитываются в бюджете сразу после запросов к API
+			statusEvents.Emit(statusEvent{Event: EventFileStarted, Path: normalizeRelPath(key)})
+			pool.Go(func() {
+				started := time.Now()
+				result, pending, err := prepareFile(rootConfig, c.Path, outputPath, sess)
+				if pending != nil {
+					outputPath = pending.outputPath
+				} else if err != nil {
+					sess.writeFallback(rootConfig, key, c.Path, outputDir, outputPath, result, err)
+				}
+				collect.Lock()
+				defer collect.Unlock()
+				if !isSkippedStatus(result.Status) {
+					// Лимит файлов расходует только файл, результат которого готов к записи
+					budget.Record(result.Usage.Cost(config), err == nil)
+					alerts.RecordCost(result.Usage.Cost(config))
+					alerts.RecordResult(err)
+					// Ошибка учитывается до этапа записи: следующий файл не начнется после
+					// срабатывания политики on_error
+					failTracker.Record(err)
+				}
+				// Ошибки соединения подряд: API недоступен, оставшиеся файлы откладываются
+				// до следующего запуска, а не завершаются ошибкой по одному. Статус читается
+				// до передачи результата этапу записи, который его меняет
+				if offline.Record(key, result.Status, err) {
+					noConnection.Store(true)
+				}
+				pipeline.Submit(&pipelineItem{Key: key, Path: c.Path, OutputPath: outputPath, Result: result,
+					Pending: pending, Err: err, Prepared: time.Since(started)})
+			})
+			if noConnection.Load() {
+				warnf("Предупреждение: нет соединения с API после %d ошибок подряд, обработка остановлена", config.Offline.After)
+				collect.Lock()
+				for {
+					c, _, ok := queue.Next(1)
+					if !ok {
+						break
+					}
+					offline.Defer(rootKey(rootConfig.RootName, c.RelPath))
+				}
+				collect.Unlock()
+				stoppedOffline = true
+				stopped = true
+				break
+			}
+		}
+		// Обработка корня заканчивается, когда готовы все его файлы: ресурсы сессии
+		// (индексы ссылок, дневник, пакеты) относятся к текущему корню
+		pool.Wait()
+		if err := queue.Close(); err != nil {
+			return err
+		}
+		if stopped {
+			break
+		}
+	}
+
+	stages := pipeline.Close()
+	if stages.QueueSize > 0 && stages.Files > 0 {
+		report.Pipeline = &stages
+		infof("Конвейер: запросы к API %.1f с (ожидание очереди записи %.1f с), запись %.1f с (простой %.1f с), очередь до %d из %d",
+			stages.APISeconds, stages.BlockedSeconds, stages.WriteSeconds, stages.IdleSeconds, stages.MaxQueued, stages.QueueSize)
+	}
+
+	// Фиксация или отмена транзакции
+	var txnErr error
+	if sess.txn != nil {
+		switch {
+		case len(failures) > 0:
+			txnErr = errorf("транзакция отменена, результаты не сохранены: %w", &filesFailedError{Categories: failures})
+		case serviceStopping.Load():
+			txnErr = errorf("транзакция отменена: обработка остановлена до окончания, результаты не сохранены")
+		default:
+			txnErr = sess.txn.Commit(configPath, config.StateDir)
+		}
+		if txnErr != nil {
+			sess.txn.Rollback()
+			report.Transaction = TransactionRolledBack
+		} else {
+			report.Transaction = TransactionCommitted
+			infof("Транзакция зафиксирована: сохранено результатов %d", sess.txn.Len())
+		}
 	}
 
-	log.Printf("Обработано файлов: %d", fileCount)
+	// Приемники получают результаты транзакции после фиксации и завершают запуск
+	sess.sinks.Finish(sess.runID, txnErr == nil)
+
+	// Итоги файлов сохраняются, если результаты запуска сохранены
+	if sess.manifest != nil && txnErr == nil {
+		if err := sess.manifest.Flush(); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+
+	if sess.titles != nil && txnErr == nil {
+		if err := sess.titles.Save(); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+	if sess.changes != nil && txnErr == nil {
+		if err := sess.changes.Save(config.changelogFile(), sess.runID, time.Now()); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+
+	failedCount := 0
+	for _, n := range failures {
+		failedCount += n
+	}
+	statusEvents.Emit(statusEvent{Event: EventRunFinished, RunID: sess.runID, Enriched: fileCount, Skipped: skippedCount,
+		Failed: failedCount, CostUSD: budget.Spent()})
+
+	infof("Обработано файлов: %d", fileCount)
+	if skippedCount > 0 {
+		infof("Пропущено файлов: %d", skippedCount)
+	}
+	if resumed > 0 {
+		infof("Пропущено файлов, обработанных до прерывания запуска: %d", resumed)
+	}
+	if conflictCount > 0 {
+		warnf("Результаты изменены вручную, новые версии сохранены рядом в файлы %s: %d", ConflictSuffix, conflictCount)
+	}
+	if spent := budget.Spent(); spent > 0 {
+		infof("Затраты за запуск: $%.4f", spent)
+	}
+	if stats := sess.limiter.Stats(); stats.Waits > 0 {
+		report.RateLimit = &stats
+		infof("Ожидание ограничителя частоты запросов: %d раз, всего %.1f с, максимум %.1f с",
+			stats.Waits, stats.WaitSeconds, stats.MaxWaitSeconds)
+	}
+	if err := report.Save(config.ReportFile); err != nil {
+		warnf("Предупреждение: %v", err)
+	}
+	if err := runFiles.Save(config, report); err != nil {
+		warnf("Предупреждение: %v", err)
+	}
+	if config.DatabaseFile != "" {
+		if err := recordRun(config, report); err != nil {
+			warnf("Предупреждение: запуск не записан в базу данных: %v", err)
+		}
+	}
+	if config.IndexFile != "" || config.ChecksumFile != "" {
+		for _, rootConfig := range config.inputRoots() {
+			outputDir, err := filepath.Abs(rootConfig.OutputDir)
+			if err == nil && sess.runDir != "" {
+				outputDir = filepath.Join(outputDir, sess.runDir)
+				runConfig := *rootConfig
+				runConfig.OutputDir = outputDir
+				rootConfig = &runConfig
+			}
+			if err == nil && config.IndexFile != "" {
+				err = updateIndex(rootConfig, outputDir)
+			}
+			// Контрольные суммы обновляются последними: они учитывают оглавление
+			if err == nil && config.ChecksumFile != "" {
+				err = updateChecksums(rootConfig, outputDir)
+			}
+			if err != nil {
+				warnf("Предупреждение: %v", err)
+			}
+		}
+	}
+	if err := sendRunDigest(config, report); err != nil {
+		warnf("Предупреждение: не удалось отправить итоги запуска по почте: %v", err)
+	}
+	if sess.journal != nil {
+		if err := sess.journal.Finish(); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+		infof("Запуск %s завершен (rich status --run %s, rich undo --run %s)", sess.runID, sess.runID, sess.runID)
+	}
+	// Очередь отложенных файлов сохраняется при остановке без соединения и
+	// сокращается по мере обработки отложенных файлов
+	var deferred offlineQueue
+	if stoppedOffline || len(queued.Files) > 0 {
+		deferred = offline.Queue(queued)
+		if !stoppedOffline && len(deferred.Files) == 0 {
+			infof("Отложенные файлы обработаны")
+		}
+		if config.StateDir != "" {
+			if err := saveOfflineQueue(config.StateDir, deferred); err != nil {
+				warnf("Предупреждение: %v", err)
+			}
+		}
+	}
+	if txnErr != nil {
+		return txnErr
+	}
+	if stoppedOffline {
+		return &offlineError{Queued: len(deferred.Files), Err: offline.lastErr}
+	}
+	if len(failures) > 0 {
+		return &filesFailedError{Categories: failures}
+	}
 	return nil
 }
 
+// Подробный журнал в файл rich.log и краткий (цветной, если поддерживается) вывод в консоль.
+// С toStdout подробный журнал пишется только в стандартный вывод (для контейнеров)
+func setupLogging(noColor, toStdout bool) (closeLog func(), err error) {
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	if toStdout {
+		log.SetOutput(os.Stdout)
+		console = nil
+		return func() {}, nil
+	}
+	logFile, err := os.OpenFile("rich.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(logFile)
+	console = newConsole(os.Stdout, useColor(noColor, os.Stdout))
+	return func() {
+		if cerr := logFile.Close(); cerr != nil {
+			logErrorf("Ошибка закрытия файла журнала: %v", cerr)
+		}
+	}, nil
+}
+
 func main() {
+	// Язык сообщений по переменным окружения (RICH_LANG, LANG); [INTERFACE] language
+	// в конфигурации имеет приоритет
+	if err := setUILanguage("auto"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	// Подкоманды: rich status, rich undo
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// Обработка аргументов командной строки
-	configPath := flag.String("config", "rich.cfg", "Путь к файлу конфигурации")
+	configPath := flag.String("config", envString("RICH_CONFIG", "rich.cfg"), tr("Путь к файлу конфигурации"))
+	maxFiles := flag.Int("max-files", 0, tr("Максимальное количество файлов за запуск (0 - без ограничений)"))
+	maxUSD := flag.Float64("max-usd", 0, tr("Максимальные затраты за запуск в долларах (0 - без ограничений)"))
+	order := flag.String("order", "", tr("Порядок обработки: alphabetical, newest, oldest, smallest, priority"))
+	priority := flag.String("priority", "", tr("Правила приоритета очереди через запятую: шаблон=приоритет (например, inbox/**=10)"))
+	modifiedAfter := flag.String("modified-after", "", tr("Обрабатывать только файлы, измененные после даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)"))
+	noColor := flag.Bool("no-color", false, tr("Выключить цветной вывод в консоль"))
+	modifiedBefore := flag.String("modified-before", "", tr("Обрабатывать только файлы, измененные до даты (YYYY-MM-DD, RFC3339 или срок: 36h, 7d)"))
+	healthAddr := flag.String("health-addr", os.Getenv("RICH_HEALTH_ADDR"), tr("Адрес HTTP сервера проверок состояния /healthz и /readyz (например, :8080)"))
+	logStdout := flag.Bool("log-stdout", envBool("RICH_LOG_STDOUT"), tr("Писать журнал в стандартный вывод вместо rich.log"))
+	pprofAddr := flag.String("pprof", "", tr("Адрес HTTP сервера профилирования /debug/pprof/ (например, localhost:6060)"))
+	runtimeStats := flag.Duration("runtime-stats", 0, tr("Интервал записи в журнал количества горутин и размера кучи (0 - выключено)"))
+	transactional := flag.Bool("transactional", false, tr("Сохранять результаты, только если все файлы запуска обработаны без ошибок"))
+	resume := flag.Bool("resume", false, tr("Продолжить прерванный запуск: пропустить файлы, уже обработанные по файлу состояния"))
+	workers := flag.Int("workers", 0, tr("Файлов, обрабатываемых одновременно (0 - workers из секции [PROCESSING])"))
+	onError := flag.String("on-error", "", tr("Реакция на ошибки файлов: continue, fail-fast или fail-after N (пусто - on_error из секции [PROCESSING])"))
+	canary := flag.String("canary", "", tr("Сначала обогатить этот файл и продолжить после проверки результата (переопределяет file секции [CANARY])"))
+	yes := flag.Bool("yes", false, tr("Продолжить запуск после проверочного файла без подтверждения"))
+	sample := flag.Int("sample", 0, tr("Обработать случайную выборку из N необработанных файлов в отдельную директорию (0 - все файлы)"))
+	seed := flag.Uint64("seed", 1, tr("Начальное значение случайной выборки -sample"))
+	sampleDir := flag.String("sample-dir", "", tr("Директория результатов выборки (по умолчанию sample-<время>)"))
+	dryRun := flag.Bool("dry-run", false, tr("Только показать файлы для обработки, запросы к API не отправляются"))
+	diff := flag.Bool("diff", false, tr("С -dry-run: обогатить заново файлы с результатами и показать отличия от текущих результатов"))
+	diffDir := flag.String("diff-dir", "", tr("С -dry-run -diff: сохранить сравнения в директорию"))
+	shard := flag.String("shard", "", tr("Обрабатывать только часть K из N файлов (например, 2/5) для обработки на нескольких машинах"))
+	watch := flag.Bool("watch", false, tr("Наблюдать за входными директориями и обрабатывать новые и измененные файлы по мере появления"))
+	watchDebounce := flag.Duration("watch-debounce", defaultWatchDebounce, tr("Время без изменений файла перед обработкой в режиме наблюдения (переопределяет debounce секции [WATCH])"))
+	watchInterval := flag.Duration("watch-interval", defaultWatchInterval, tr("Интервал проверки входных директорий в режиме наблюдения (переопределяет interval секции [WATCH])"))
+	statusStreamTarget := flag.String("status-stream", "", tr("Поток событий обработки файлов в формате NDJSON: stdout, stderr, fd:N или путь файла"))
 	flag.Parse()
 
 	// Настройка логирования
-	logFile, err := os.OpenFile("rich.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	closeLog, err := setupLogging(*noColor, *logStdout)
 	if err != nil {
-		log.Fatalf("Не удалось открыть файл журнала: %v", err)
+		fatalf("Не удалось открыть файл журнала: %v", err)
 	}
-	defer func() {
-		if cerr := logFile.Close(); cerr != nil {
-			log.Printf("Ошибка закрытия файла журнала: %v", cerr)
+	defer closeLog()
+
+	// Поток событий обработки для оркестраторов
+	if *statusStreamTarget != "" {
+		stream, err := openStatusStream(*statusStreamTarget)
+		if err != nil {
+			fatalf("Ошибка открытия потока состояния: %v", withCategory(ErrorConfig, err))
 		}
-	}()
+		defer stream.Close()
+		stream.claimStdout(*noColor)
+		statusEvents = stream
+	}
 
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.SetOutput(io.MultiWriter(os.Stdout, logFile))
+	// Проверки состояния для оркестратора контейнеров
+	health := &healthState{}
+	if *healthAddr != "" {
+		stopHealth, err := startHealthServer(*healthAddr, health)
+		if err != nil {
+			fatalf("Ошибка запуска проверок состояния: %v", err)
+		}
+		defer stopHealth()
+	}
+
+	// Диагностика потребления памяти
+	if *pprofAddr != "" {
+		stopPprof, err := startPprofServer(*pprofAddr)
+		if err != nil {
+			fatalf("Ошибка запуска профилирования: %v", err)
+		}
+		defer stopPprof()
+	}
+	if *runtimeStats > 0 {
+		defer startRuntimeStats(*runtimeStats)()
+	}
 
-	log.Printf("Запуск с конфигурацией из: %s", *configPath)
+	infof("Запуск с конфигурацией из: %s", *configPath)
 
 	// Загрузка конфигурации
 	config, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+		fatalf("Ошибка загрузки конфигурации: %v", withCategory(ErrorConfig, err))
+	}
+	config.MaxFiles = *maxFiles
+	config.MaxUSD = *maxUSD
+	config.Transactional = *transactional
+	config.Resume = *resume
+	if *workers != 0 {
+		if *workers < 1 || *workers > maxWorkers {
+			fatalf("Ошибка в параметре -workers: %v", withCategory(ErrorConfig, errorf("workers должен быть от 1 до %d: %d", maxWorkers, *workers)))
+		}
+		config.Workers = *workers
+	}
+	if *canary != "" {
+		config.Canary.File = *canary
+	}
+	if config.Shard, err = parseShard(*shard); err != nil {
+		fatalf("Ошибка в параметре -shard: %v", withCategory(ErrorConfig, err))
+	}
+	if *onError != "" {
+		policy, err := parseFailurePolicy(*onError)
+		if err != nil {
+			fatalf("Ошибка в параметре -on-error: %v", withCategory(ErrorConfig, err))
+		}
+		config.OnError = policy
+	}
+	if *order != "" {
+		if err := validateOrder(*order); err != nil {
+			fatalf("Ошибка в параметрах командной строки: %v", withCategory(ErrorConfig, err))
+		}
+		config.Order = *order
+	}
+	if *priority != "" {
+		rules, err := parsePriorityRules(*priority)
+		if err != nil {
+			fatalf("Ошибка в параметре -priority: %v", withCategory(ErrorConfig, err))
+		}
+		// Правила командной строки проверяются раньше правил [PRIORITY]
+		config.Priorities = append(rules, config.Priorities...)
+	}
+	now := time.Now()
+	if *modifiedAfter != "" {
+		if config.ModifiedAfter, err = parseTimeFilter(*modifiedAfter, now); err != nil {
+			fatalf("Ошибка в параметре -modified-after: %v", withCategory(ErrorConfig, err))
+		}
+	}
+	if *modifiedBefore != "" {
+		if config.ModifiedBefore, err = parseTimeFilter(*modifiedBefore, now); err != nil {
+			fatalf("Ошибка в параметре -modified-before: %v", withCategory(ErrorConfig, err))
+		}
 	}
 
 	// Обработка директории
+	health.Notify("READY=1")
+	if *sample < 0 {
+		fatalf("Ошибка в параметре -sample: %v", withCategory(ErrorConfig, errorf("размер выборки не может быть отрицательным: %d", *sample)))
+	}
+	if (*diff || *diffDir != "") && !*dryRun {
+		fatalf("Ошибка в параметре -diff: %v", withCategory(ErrorConfig, errorf("-diff и -diff-dir используются вместе с -dry-run")))
+	}
+	if *dryRun {
+		if *sample > 0 || *watch {
+			fatalf("Ошибка в параметре -dry-run: %v", withCategory(ErrorConfig, errorf("-dry-run нельзя совмещать с -sample и -watch")))
+		}
+		if err := runDryRun(config, dryRunOptions{Diff: *diff || *diffDir != "", DiffDir: *diffDir}, os.Stdout); err != nil {
+			fatalf("Ошибка пробного запуска: %v", err)
+		}
+		return
+	}
+	if *sample > 0 {
+		if err := runSample(config, sampleOptions{Size: *sample, Seed: *seed, Dir: *sampleDir}); err != nil {
+			fatalf("Ошибка обработки выборки: %v", err)
+		}
+		return
+	}
+	if *watch {
+		opts := watchOptions{Debounce: config.Watch.Debounce, Interval: config.Watch.Interval, MinInterval: config.Watch.MinInterval}
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "watch-debounce":
+				opts.Debounce = *watchDebounce
+			case "watch-interval":
+				opts.Interval = *watchInterval
+			}
+		})
+		if err := runWatchMode(config, *configPath, opts, health); err != nil {
+			fatalf("Ошибка режима наблюдения: %v", err)
+		}
+		return
+	}
+	if config.Canary.File != "" {
+		var in io.Reader
+		if stdinIsTerminal() {
+			in = os.Stdin
+		}
+		err := runCanary(config, *configPath, *yes, in, os.Stdout)
+		if errors.Is(err, errCanaryDeclined) {
+			return
+		}
+		if err != nil {
+			fatalf("Ошибка проверочного файла: %v", err)
+		}
+	}
 	if err := processDirectory(config, *configPath); err != nil {
-		log.Fatalf("Ошибка обработки директории: %v", err)
+		fatalf("Ошибка обработки директории: %v", err)
 	}
 
-	log.Println("Обработка завершена")
+	infof("Обработка завершена")
 }
@@ -157,6 +157,31 @@ func TestIsPathSafe(t *testing.T) {
 			path:     "./documents/file.txt",
 			expected: true,
 		},
+		{
+			name:     "Путь UNC",
+			path:     "\\\\server\\share\\docs",
+			expected: true,
+		},
+		{
+			name:     "Длинный путь Windows",
+			path:     "\\\\?\\C:\\very\\long\\path",
+			expected: true,
+		},
+		{
+			name:     "Длинный путь Windows с ..",
+			path:     "\\\\?\\C:\\docs\\..\\Windows",
+			expected: false,
+		},
+		{
+			name:     "Точки в имени файла",
+			path:     "/home/user/notes..md",
+			expected: true,
+		},
+		{
+			name:     "Путь, заканчивающийся на ..",
+			path:     "/home/user/..",
+			expected: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -191,30 +216,31 @@ func TestValidateContent(t *testing.T) {
 }
 
 func TestRateLimiter(t *testing.T) {
-	requestsPerMinute := 3
-	limiter := NewRateLimiter(requestsPerMinute)
+	// 600 запросов в минуту - новый токен каждые 100 мс
+	requestsPerMinute := 600
+	limiter := (&Config{RequestsPerMinute: requestsPerMinute, Burst: 3}).newRateLimiter()
 
 	start := time.Now()
 
-	// Используем все доступные токены
-	for i := 0; i < requestsPerMinute; i++ {
+	// Запас burst расходуется без ожидания
+	for i := 0; i < 3; i++ {
 		limiter.Wait()
 	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("запросы в пределах burst не должны ждать, ожидание %v", elapsed)
+	}
 
 	// Следующий запрос должен заблокироваться до получения нового токена
-	go func() {
-		time.Sleep(100 * time.Millisecond) // Немного подождем
-		// Отправляем токен в канал
-		limiter.tokens <- struct{}{}
-	}()
-
-	limiter.Wait() // Должен разблокироваться после получения токена
+	limiter.Wait()
 	elapsed := time.Since(start)
 
 	// Проверяем, что ожидание заняло некоторое время
 	if elapsed < 50*time.Millisecond {
 		t.Errorf("RateLimiter.Wait() не заблокировался должным образом")
 	}
+	if stats := limiter.Stats(); stats.Waits != 1 || stats.MaxWaitSeconds < 0.05 {
+		t.Errorf("некорректная статистика ожидания: %+v", stats)
+	}
 }
 
 func TestEnrichContent(t *testing.T) {
@@ -518,11 +544,11 @@ text = Test prompt`
 	}
 
 	// Создаем ограничитель частоты запросов для тестов
-	rateLimiter := NewRateLimiter(10)
+	sess := newSession(NewRateLimiter(10))
 
 	// Тест с безопасными путями
 	t.Run("SafePaths", func(t *testing.T) {
-		err := processFile(config, inputFilePath, outputFilePath, configPath, rateLimiter)
+		_, err := processFile(config, inputFilePath, outputFilePath, configPath, sess)
 		if err != nil {
 			t.Fatalf("processFile() вернул ошибку: %v", err)
 		}
@@ -555,7 +581,7 @@ text = Test prompt`
 		unsafeInputPath := "../../../dangerous.md"
 		unsafeOutputPath := "../../../dangerous_output.md"
 
-		err := processFile(config, unsafeInputPath, unsafeOutputPath, configPath, rateLimiter)
+		_, err := processFile(config, unsafeInputPath, unsafeOutputPath, configPath, sess)
 		if err == nil {
 			t.Error("Ожидалась ошибка при обработке файла с небезопасными путями, но ее не было")
 		} else if !strings.Contains(err.Error(), "небезопасный путь") {
@@ -574,7 +600,7 @@ text = Test prompt`
 
 		largeOutputPath := filepath.Join(outputDir, "large.md")
 
-		err := processFile(config, largeFilePath, largeOutputPath, configPath, rateLimiter)
+		_, err := processFile(config, largeFilePath, largeOutputPath, configPath, sess)
 		if err == nil {
 			t.Error("Ожидалась ошибка при обработке слишком большого файла, но ее не было")
 		} else if !strings.Contains(err.Error(), "размер файла превышает") {
@@ -751,3 +777,225 @@ text = Test prompt`
 		}
 	}
 }
+
+func TestProcessDirectoryMaxFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Обогащенный контент"}},
+			},
+			"usage": map[string]interface{}{"prompt_tokens": 100, "completion_tokens": 100},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		ModelName:   "gpt-3.5-turbo",
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		APIKey:      "test_key",
+		Prompt:      "Test prompt",
+		MaxFiles:    2,
+	}
+
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("Ожидалось 2 запроса к API при --max-files 2, получено %d", requests)
+	}
+}
+
+func TestIsTooSmall(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   *Config
+		content  string
+		expected bool
+	}{
+		{"Пустой файл", &Config{}, "", true},
+		{"Только пробелы", &Config{}, "  \n\t\n", true},
+		{"Только frontmatter", &Config{}, "---\ntitle: x\n---\n\n", true},
+		{"Без порогов", &Config{}, "слово", false},
+		{"Меньше min_bytes", &Config{MinBytes: 100}, "короткий текст", true},
+		{"Меньше min_words", &Config{MinWords: 5}, "три слова всего", true},
+		{"Достаточно слов", &Config{MinWords: 3}, "три слова всего", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, small := isTooSmall(tc.config, []byte(tc.content)); small != tc.expected {
+				t.Errorf("isTooSmall(%q) = %v, ожидалось %v", tc.content, small, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProcessFileSkipsTooSmall(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "empty.md")
+	outputPath := filepath.Join(tmpDir, "out", "empty.md")
+	if err := os.WriteFile(inputPath, []byte("\n\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Пустой файл не должен отправляться в API")
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: tmpDir, ModelAPIURL: server.URL + "/v1/chat/completions"}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(10)))
+	if err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	if result.Status != StatusSkippedTooSmall {
+		t.Errorf("Ожидался статус %q, получено %q", StatusSkippedTooSmall, result.Status)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("Выходной файл не должен создаваться для пропущенного файла")
+	}
+}
+
+func TestProcessDirectoryDepthAndIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+
+	files := map[string]string{
+		"root.md":                                  "# Корень",
+		filepath.Join("a", "level1.md"):            "# Уровень 1",
+		filepath.Join("a", "b", "level2.md"):       "# Уровень 2",
+		filepath.Join("skip", "secret.md"):         "# Скрыто",
+		filepath.Join("skip", IgnoreFileName):      "",
+		filepath.Join("a", IgnoreFileName):         "ignored.md",
+		filepath.Join("a", "ignored.md"):           "# Игнор",
+		filepath.Join("a", "b", "c", "level3.md"):  "# Уровень 3",
+		filepath.Join("a", "b", "c", "ignored.md"): "# Игнор",
+	}
+	for name, content := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Обогащенный контент"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxDepth:    3,
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	expected := map[string]bool{
+		"root.md":                                  true,
+		filepath.Join("a", "level1.md"):            true,
+		filepath.Join("a", "b", "level2.md"):       true,
+		filepath.Join("a", "b", "c", "level3.md"):  false, // глубже max_depth
+		filepath.Join("skip", "secret.md"):         false, // пустой .richignore
+		filepath.Join("a", "ignored.md"):           false, // шаблон из a/.richignore
+		filepath.Join("a", "b", "c", "ignored.md"): false,
+	}
+	for name, want := range expected {
+		_, err := os.Stat(filepath.Join(outputDir, name))
+		if got := err == nil; got != want {
+			t.Errorf("Файл %s: обработан=%v, ожидалось %v", name, got, want)
+		}
+	}
+}
+
+func TestOutputLayoutPerRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "done")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Заметка\n\nТекст заметки"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\noutput_layout = per-run\n" +
+		"[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	runs, err := os.ReadDir(outputDir)
+	if err != nil || len(runs) != 1 || !runs[0].IsDir() {
+		t.Fatalf("Ожидалась одна директория запуска: %v, %v", runs, err)
+	}
+	if _, err := time.Parse(runDirLayout, runs[0].Name()); err != nil {
+		t.Errorf("Неверное имя директории запуска %s: %v", runs[0].Name(), err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(outputDir, runs[0].Name()))
+	if len(entries) != 1 || entries[0].Name() != "a.md" {
+		t.Errorf("В директории запуска ожидался только a.md: %v", entries)
+	}
+
+	if err := os.WriteFile(configPath, []byte(cfg+"[DIRECTORIES]\noutput_layout = nested\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(configPath); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного output_layout")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Окно обслуживания провайдера: ежедневно или по дням недели с Start до End
+// (смещения от полуночи; End <= Start - окно переходит через полночь)
+type maintenanceWindow struct {
+	// Дни недели начала окна (пусто - каждый день)
+	Days       []time.Weekday
+	Start, End time.Duration
+}
+
+// Окна обслуживания и часовой пояс, в котором они заданы
+type maintenanceSchedule struct {
+	Windows  []maintenanceWindow
+	Location *time.Location
+}
+
+// Сокращения дней недели в maintenance_windows
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Разбор maintenance_windows: окна через запятую вида "02:00-04:00", "Sun 23:00-01:00"
+// или "Mon-Fri 12:00-13:00"
+func parseMaintenanceWindows(spec string) ([]maintenanceWindow, error) {
+	var windows []maintenanceWindow
+	for _, item := range strings.Split(spec, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		var w maintenanceWindow
+		if len(fields) == 2 {
+			days, err := parseWeekdays(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			w.Days = days
+			fields = fields[1:]
+		}
+		from, to, ok := strings.Cut(fields[0], "-")
+		if len(fields) != 1 || !ok {
+			return nil, errorf("некорректное окно обслуживания %q: ожидалось [дни] ЧЧ:ММ-ЧЧ:ММ", strings.TrimSpace(item))
+		}
+		var err error
+		if w.Start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.End, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// Разбор дня недели или диапазона дней (Mon-Fri, Sat-Sun)
+func parseWeekdays(spec string) ([]time.Weekday, error) {
+	from, to, isRange := strings.Cut(strings.ToLower(spec), "-")
+	first, ok1 := weekdayNames[from]
+	last, ok2 := weekdayNames[to]
+	if !isRange {
+		last, ok2 = first, ok1
+	}
+	if !ok1 || !ok2 {
+		return nil, errorf("некорректный день недели в окне обслуживания: %s", spec)
+	}
+	days := []time.Weekday{first}
+	for d := first; d != last; {
+		d = (d + 1) % 7
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// Разбор времени суток ЧЧ:ММ в смещение от полуночи
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, errorf("некорректное время в окне обслуживания: %s", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Окончание окна обслуживания, в которое попадает момент now
+func (s maintenanceSchedule) until(now time.Time) (time.Time, bool) {
+	if s.Location != nil {
+		now = now.In(s.Location)
+	}
+	var end time.Time
+	for _, w := range s.Windows {
+		length := w.End - w.Start
+		if length <= 0 {
+			length += 24 * time.Hour
+		}
+		// Окно могло начаться сегодня или вчера (переход через полночь)
+		for back := 0; back <= 1; back++ {
+			day := time.Date(now.Year(), now.Month(), now.Day()-back, 0, 0, 0, 0, now.Location())
+			if len(w.Days) > 0 && !slices.Contains(w.Days, day.Weekday()) {
+				continue
+			}
+			start := day.Add(w.Start)
+			if !now.Before(start) && now.Before(start.Add(length)) && start.Add(length).After(end) {
+				end = start.Add(length)
+			}
+		}
+	}
+	return end, !end.IsZero()
+}
+
+// Ожидание окончания окна обслуживания перед отправкой очередного файла; остановка
+// службы прерывает ожидание
+func (s maintenanceSchedule) Wait() {
+	for {
+		end, ok := s.until(time.Now())
+		if !ok {
+			return
+		}
+		infof("Окно обслуживания провайдера до %s: файлы ждут в очереди", end.Format("2006-01-02 15:04 MST"))
+		for time.Now().Before(end) {
+			if serviceStopping.Load() {
+				return
+			}
+			time.Sleep(min(time.Until(end), time.Second))
+		}
+		infof("Окно обслуживания завершено, отправка файлов возобновлена")
+	}
+}
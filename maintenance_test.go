@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	windows, err := parseMaintenanceWindows("02:00-04:00, Sun 23:00-01:00, Mon-Fri 12:00-12:30")
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindows() вернул ошибку: %v", err)
+	}
+	schedule := maintenanceSchedule{Windows: windows, Location: time.UTC}
+
+	// 2025-06-01 - воскресенье
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		now    time.Time
+		active bool
+		end    time.Time
+	}{
+		{at(1, 3, 0), true, at(1, 4, 0)},
+		{at(1, 4, 0), false, time.Time{}},
+		{at(1, 23, 30), true, at(2, 1, 0)},
+		{at(2, 0, 30), true, at(2, 1, 0)},
+		{at(3, 0, 30), false, time.Time{}},
+		{at(2, 12, 10), true, at(2, 12, 30)},
+		{at(7, 12, 10), false, time.Time{}},
+	}
+	for _, tc := range testCases {
+		end, active := schedule.until(tc.now)
+		if active != tc.active || !end.Equal(tc.end) {
+			t.Errorf("until(%s) = %s, %v; ожидалось %s, %v", tc.now.Format("Mon 15:04"), end, active, tc.end, tc.active)
+		}
+	}
+
+	for _, spec := range []string{"02:00", "Xyz 02:00-03:00", "25:00-26:00", "Mon 02:00-03:00 лишнее"} {
+		if _, err := parseMaintenanceWindows(spec); err == nil {
+			t.Errorf("parseMaintenanceWindows(%q) не вернул ошибку", spec)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Каталог копий записанных результатов в каталоге состояния: прежнее обогащение
+// для трехстороннего слияния с ручными правками (файлы по хэшу содержимого)
+const snapshotsDirName = "outputs"
+
+// Наибольшее число ячеек таблицы сравнения строк; при большем размере измененной
+// части документы сравниваются без поиска общих строк
+const mergeMaxDiffCells = 4 << 20
+
+// Строк сравнения версий, выводимых перед предложенным слиянием
+const mergeDiffLines = 40
+
+// Маркеры конфликтов слияния без модели (в стиле diff3)
+const (
+	mergeMarkerEdited   = "<<<<<<< edited"
+	mergeMarkerPrevious = "||||||| previous"
+	mergeMarkerSplit    = "======="
+	mergeMarkerNew      = ">>>>>>> new"
+)
+
+// Способы получения предложенной версии
+const (
+	MergeModel = "model"
+	MergeDiff3 = "diff3"
+)
+
+// Инструкция модели для слияния ручных правок с новым обогащением
+const mergeInstruction = `You are merging versions of an enriched markdown document. PREVIOUS is an earlier automatic enrichment of the source document; EDITED is PREVIOUS after a person edited it by hand; NEW is a fresh automatic enrichment of the current ORIGINAL source document; MERGED WITH CONFLICTS is a line-based three-way merge where unresolved places are marked with <<<<<<< edited, ||||||| previous, ======= and >>>>>>> new. Produce one merged document: keep every manual change from EDITED (corrections, additions, deletions, rewording) and bring in the changes from NEW that do not contradict those edits. Where EDITED and NEW disagree, prefer EDITED. Reply with the merged markdown document only, without explanations and without conflict markers.`
+
+// Сохранение копии записанного результата
+func saveOutputSnapshot(stateDir string, content []byte) error {
+	path := filepath.Join(stateDir, snapshotsDirName, contentHash(content))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return safeWriteFile(path, content, 0644)
+}
+
+// Копия результата по хэшу содержимого
+func loadOutputSnapshot(stateDir, hash string) ([]byte, bool) {
+	if hash == "" || strings.ContainsAny(hash, `/\.`) {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(stateDir, snapshotsDirName, hash))
+	if err != nil || contentHash(data) != hash {
+		return nil, false
+	}
+	return data, true
+}
+
+// Неразрешенный конфликт: последняя запись журналов о файле - конфликт, и файл
+// с новым обогащением на месте
+type pendingConflict struct {
+	RunID string
+	Entry journalEntry
+}
+
+// Неразрешенные конфликты по журналам всех запусков в порядке путей файлов
+func pendingConflicts(stateDir string) ([]pendingConflict, error) {
+	runs, err := listRuns(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]pendingConflict)
+	for _, j := range runs {
+		for _, e := range j.Entries {
+			if e.Undone || (e.Status != StatusEnriched && e.Status != StatusConflict) {
+				continue
+			}
+			e.Input = normalizeRelPath(e.Input)
+			latest[e.Input] = pendingConflict{RunID: j.ID, Entry: e}
+		}
+	}
+	var conflicts []pendingConflict
+	for _, c := range latest {
+		if c.Entry.Status != StatusConflict || c.Entry.Conflict == "" {
+			continue
+		}
+		if _, err := os.Stat(c.Entry.Conflict); err == nil {
+			conflicts = append(conflicts, c)
+		}
+	}
+	sort.Slice(conflicts, func(a, b int) bool { return conflicts[a].Entry.Input < conflicts[b].Entry.Input })
+	return conflicts, nil
+}
+
+// Поиск конфликта по пути исходного файла (относительно входной директории),
+// выходного файла или файла .new
+func findConflict(conflicts []pendingConflict, arg string) (pendingConflict, bool) {
+	abs, _ := filepath.Abs(arg)
+	for _, c := range conflicts {
+		if c.Entry.Input == normalizeRelPath(arg) || samePath(abs, c.Entry.Output) || samePath(abs, c.Entry.Conflict) {
+			return c, true
+		}
+	}
+	return pendingConflict{}, false
+}
+
+// Версии документа для слияния
+type mergeVersions struct {
+	// Исходный документ
+	Original string
+	// Прежнее обогащение ("" и HasPrevious = false, если копии нет)
+	Previous    string
+	HasPrevious bool
+	// Результат с ручными правками
+	Edited string
+	// Новое обогащение из файла .new
+	New string
+}
+
+// Чтение версий документа для конфликта
+func loadMergeVersions(config *Config, c pendingConflict) (mergeVersions, error) {
+	var v mergeVersions
+	rootConfig, rootRel := config.rootForKey(c.Entry.Input)
+	original, err := os.ReadFile(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rootRel)))
+	if err != nil {
+		return v, errorf("ошибка при чтении исходного файла: %v", err)
+	}
+	edited, err := os.ReadFile(c.Entry.Output)
+	if err != nil {
+		return v, errorf("ошибка при чтении выходного файла: %v", err)
+	}
+	next, err := os.ReadFile(c.Entry.Conflict)
+	if err != nil {
+		return v, errorf("ошибка при чтении файла с новым обогащением: %v", err)
+	}
+	v.Original, v.Edited, v.New = string(original), string(edited), string(next)
+
+	// Прежнее обогащение - последний результат, записанный до конфликта
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return v, err
+	}
+	if rec, ok := latest[c.Entry.Input]; ok {
+		if data, ok := loadOutputSnapshot(config.StateDir, rec.Entry.OutputHash); ok {
+			v.Previous, v.HasPrevious = string(data), true
+		}
+	}
+	return v, nil
+}
+
+// Строки текста без завершающего перевода строки
+func textLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Сопоставление строк: для каждой строки a - номер совпавшей строки b (наибольшая
+// общая подпоследовательность) или -1
+func lineMatches(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(ma), len(mb)
+	if n == 0 || m == 0 || (n+1)*(m+1) > mergeMaxDiffCells {
+		return matches
+	}
+
+	// Длины общих подпоследовательностей суффиксов
+	width := m + 1
+	lcs := make([]int32, (n+1)*width)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case ma[i] == mb[j]:
+			matches[prefix+i] = prefix + j
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// Изменения строк от a к b: удаленные строки с "- ", добавленные с "+ ", не больше
+// limit строк
+func formatLineDiff(a, b string, limit int) string {
+	from, to := textLines(a), textLines(b)
+	matches := lineMatches(from, to)
+	var lines []string
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		if i < len(from) && matches[i] == j {
+			i++
+			j++
+			continue
+		}
+		if i < len(from) && matches[i] < 0 {
+			lines = append(lines, "- "+from[i])
+			i++
+			continue
+		}
+		lines = append(lines, "+ "+to[j])
+		j++
+	}
+	if len(lines) == 0 {
+		return tr("  (без изменений)") + "\n"
+	}
+	more := 0
+	if len(lines) > limit {
+		more = len(lines) - limit
+		lines = lines[:limit]
+	}
+	text := "  " + strings.Join(lines, "\n  ") + "\n"
+	if more > 0 {
+		text += fmt.Sprintf(tr("  ... еще строк: %d\n"), more)
+	}
+	return text
+}
+
+// Трехстороннее слияние строк: изменения, сделанные только в одной из версий,
+// переносятся автоматически, пересекающиеся изменения отмечаются маркерами
+func diff3Merge(base, edited, next string) (string, int) {
+	b, a, n := textLines(base), textLines(edited), textLines(next)
+	ma, mn := lineMatches(b, a), lineMatches(b, n)
+	var merged []string
+	conflicts := 0
+	i, j, k := 0, 0, 0
+	for i < len(b) || j < len(a) || k < len(n) {
+		if i < len(b) && ma[i] == j && mn[i] == k {
+			merged = append(merged, b[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		// Следующая строка, оставшаяся в обеих версиях, завершает измененный участок
+		o := i
+		for o < len(b) && (ma[o] < 0 || mn[o] < 0) {
+			o++
+		}
+		aEnd, nEnd := len(a), len(n)
+		if o < len(b) {
+			aEnd, nEnd = ma[o], mn[o]
+		}
+		baseChunk, editedChunk, newChunk := b[i:o], a[j:aEnd], n[k:nEnd]
+		switch {
+		case equalLines(editedChunk, baseChunk):
+			merged = append(merged, newChunk...)
+		case equalLines(newChunk, baseChunk), equalLines(editedChunk, newChunk):
+			merged = append(merged, editedChunk...)
+		default:
+			conflicts++
+			merged = append(merged, mergeMarkerEdited)
+			merged = append(merged, editedChunk...)
+			merged = append(merged, mergeMarkerPrevious)
+			merged = append(merged, baseChunk...)
+			merged = append(merged, mergeMarkerSplit)
+			merged = append(merged, newChunk...)
+			merged = append(merged, mergeMarkerNew)
+		}
+		i, j, k = o, aEnd, nEnd
+	}
+	if len(merged) == 0 {
+		return "", conflicts
+	}
+	return strings.Join(merged, "\n") + "\n", conflicts
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Сообщение модели с версиями документа
+func mergeRequest(v mergeVersions, auto string) string {
+	previous := v.Previous
+	if !v.HasPrevious {
+		previous = "(not available)"
+	}
+	var b strings.Builder
+	for _, part := range []struct{ name, text string }{
+		{"ORIGINAL", v.Original}, {"PREVIOUS", previous}, {"EDITED", v.Edited}, {"NEW", v.New}, {"MERGED WITH CONFLICTS", auto},
+	} {
+		fmt.Fprintf(&b, "===== %s =====\n%s\n\n", part.name, strings.TrimSpace(part.text))
+	}
+	return b.String()
+}
+
+// Предложенная версия: трехстороннее слияние без конфликтов принимается как есть,
+// иначе слияние выполняет модель; если модель недоступна - слияние с маркерами конфликтов
+func proposeMerge(config *Config, v mergeVersions, useModel bool, limiter *RateLimiter) (text, method string, conflicts int, usage Usage, err error) {
+	base := v.Previous
+	if !v.HasPrevious {
+		// Без прежнего обогащения все различия правок и нового обогащения - конфликты
+		base = ""
+	}
+	auto, conflicts := diff3Merge(base, v.Edited, v.New)
+	if conflicts == 0 || !useModel {
+		return auto, MergeDiff3, conflicts, usage, nil
+	}
+
+	mergeConfig := *config
+	mergeConfig.Prompt = mergeInstruction
+	// Примеры относятся к обогащению; обертка ```markdown и вступление ответа удаляются
+	mergeConfig.Examples = nil
+	mergeConfig.OutputRules = outputRules{StripPreamble: true}
+	response, usage, err := enrichContentWithUsage(&mergeConfig, mergeRequest(v, auto), limiter)
+	if err != nil {
+		warnf("Предупреждение: слияние моделью не удалось, предложено слияние с маркерами конфликтов: %v", err)
+		return auto, MergeDiff3, conflicts, usage, nil
+	}
+	merged := strings.TrimSpace(response)
+	if merged == "" || strings.Contains(merged, mergeMarkerEdited) {
+		warnf("Предупреждение: модель не разрешила конфликты, предложено слияние с маркерами конфликтов")
+		return auto, MergeDiff3, conflicts, usage, nil
+	}
+	return merged + "\n", MergeModel, 0, usage, nil
+}
+
+// Применение слияния: результат заменяется объединенной версией, файл .new удаляется.
+// Слияние записывается отдельным запуском, поэтому его можно отменить через rich undo,
+// а объединенная версия становится прежним обогащением для следующих конфликтов
+func applyMerge(config *Config, configPath string, c pendingConflict, merged string) (string, error) {
+	journal, err := startRun(config.StateDir, configPath, config.Operator)
+	if err != nil {
+		return "", err
+	}
+	backup, err := journal.Backup(c.Entry.Output, c.Entry.Input)
+	if err != nil {
+		return "", errorf("ошибка при резервном копировании выходного файла: %v", err)
+	}
+	if err := safeWriteFile(c.Entry.Output, []byte(merged), 0644); err != nil {
+		return "", withCategory(ErrorIO, err)
+	}
+	if err := os.Remove(c.Entry.Conflict); err != nil && !os.IsNotExist(err) {
+		warnf("Предупреждение: не удалось удалить %s: %v", c.Entry.Conflict, err)
+	}
+	if err := saveOutputSnapshot(config.StateDir, []byte(merged)); err != nil {
+		warnf("Предупреждение: не удалось сохранить копию результата: %v", err)
+	}
+	entry := journalEntry{Input: c.Entry.Input, Output: c.Entry.Output, Status: StatusEnriched, InputHash: c.Entry.InputHash,
+		OutputHash: contentHash([]byte(merged)), PromptHash: c.Entry.PromptHash, Model: c.Entry.Model, Backup: backup,
+		Experiment: c.Entry.Experiment, Variant: c.Entry.Variant}
+	if err := journal.Record(entry); err != nil {
+		return journal.ID, err
+	}
+	return journal.ID, journal.Finish()
+}
+
+// Подтверждение применения слияния в стандартном вводе
+func confirmMerge(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, tr("Применить объединенную версию? [y/N]: "))
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes", "д", "да":
+		return true
+	}
+	return false
+}
+
+// rich merge [--yes] [--dry-run] [--no-model] [файл]: слияние ручных правок
+// результата с новым обогащением из файла .new; без файла - список конфликтов
+func runMergeCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	yes := fs.Bool("yes", false, tr("Применить объединенную версию без подтверждения"))
+	dryRun := fs.Bool("dry-run", false, tr("Только показать объединенную версию, файлы не изменяются"))
+	noModel := fs.Bool("no-model", false, tr("Слияние без модели: пересекающиеся правки отмечаются маркерами конфликтов"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	conflicts, err := pendingConflicts(config.StateDir)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		if len(conflicts) == 0 {
+			fmt.Fprintln(out, tr("Конфликтов с ручными правками нет"))
+			return nil
+		}
+		for _, c := range conflicts {
+			fmt.Fprintf(out, "%s  %s  (%s %s)\n", c.Entry.Input, c.Entry.Conflict, tr("запуск"), c.RunID)
+		}
+		return nil
+	}
+	if fs.NArg() > 1 {
+		return errorf("укажите один файл: rich merge <файл>")
+	}
+	c, ok := findConflict(conflicts, fs.Arg(0))
+	if !ok {
+		return errorf("для %s нет неразрешенного конфликта", fs.Arg(0))
+	}
+	v, err := loadMergeVersions(config, c)
+	if err != nil {
+		return err
+	}
+
+	rootConfig, rootRel := config.rootForKey(c.Entry.Input)
+	fmt.Fprintf(out, "%s: %s\n", tr("Исходный документ"), filepath.Join(rootConfig.InputDir, filepath.FromSlash(rootRel)))
+	if v.HasPrevious {
+		fmt.Fprintf(out, "%s: %s\n", tr("Прежнее обогащение"), tr("копия в каталоге состояния"))
+	} else {
+		fmt.Fprintf(out, "%s: %s\n", tr("Прежнее обогащение"), tr("копии нет, все различия считаются конфликтами"))
+	}
+	fmt.Fprintf(out, "%s: %s\n", tr("Ручные правки"), c.Entry.Output)
+	fmt.Fprintf(out, "%s: %s\n", tr("Новое обогащение"), c.Entry.Conflict)
+	if v.HasPrevious {
+		fmt.Fprintf(out, "\n%s\n%s", tr("Ручные правки относительно прежнего обогащения:"), formatLineDiff(v.Previous, v.Edited, mergeDiffLines))
+		fmt.Fprintf(out, "\n%s\n%s", tr("Новое обогащение относительно прежнего:"), formatLineDiff(v.Previous, v.New, mergeDiffLines))
+	} else {
+		fmt.Fprintf(out, "\n%s\n%s", tr("Новое обогащение относительно ручных правок:"), formatLineDiff(v.Edited, v.New, mergeDiffLines))
+	}
+
+	merged, method, unresolved, usage, err := proposeMerge(config, v, !*noModel, config.newRateLimiter())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n"+tr("Объединенная версия (%s, конфликтов: %d):")+"\n%s", method, unresolved, merged)
+	if cost := usage.Cost(config); cost > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), cost)
+	}
+	if *dryRun {
+		return nil
+	}
+	if !*yes && !confirmMerge(os.Stdin, out) {
+		fmt.Fprintln(out, tr("Слияние не применено"))
+		return nil
+	}
+	runID, err := applyMerge(config, *configPath, c, merged)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Объединенная версия записана в %s (rich undo --run %s)\n"), c.Entry.Output, runID)
+	if unresolved > 0 {
+		fmt.Fprintf(out, tr("Осталось конфликтов: %d, найдите маркеры %s в файле\n"), unresolved, mergeMarkerEdited)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDiff3Merge(t *testing.T) {
+	base := "# Заметка\n\nВступление.\n\nИтоги: нет.\n"
+	edited := "# Заметка\n\nВступление от редактора.\n\nИтоги: нет.\n"
+	next := "# Заметка\n\nВступление.\n\nИтоги: перейти на Postgres.\n\nТеги: db\n"
+	merged, conflicts := diff3Merge(base, edited, next)
+	if conflicts != 0 || merged != "# Заметка\n\nВступление от редактора.\n\nИтоги: перейти на Postgres.\n\nТеги: db\n" {
+		t.Errorf("diff3Merge() = %q, конфликтов %d", merged, conflicts)
+	}
+
+	// Одна и та же строка изменена в обеих версиях
+	next = "# Заметка\n\nНовое вступление.\n\nИтоги: нет.\n"
+	merged, conflicts = diff3Merge(base, edited, next)
+	want := "# Заметка\n\n" + mergeMarkerEdited + "\nВступление от редактора.\n" + mergeMarkerPrevious + "\nВступление.\n" +
+		mergeMarkerSplit + "\nНовое вступление.\n" + mergeMarkerNew + "\n\nИтоги: нет.\n"
+	if conflicts != 1 || merged != want {
+		t.Errorf("diff3Merge() с конфликтом = %q, конфликтов %d", merged, conflicts)
+	}
+}
+
+func TestMergeCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Заметка\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := "# Заметка\n\nВступление.\n\nИтоги: нет."
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		resp, _ := json.Marshal(map[string]interface{}{"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": answer}}}})
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + stateDir + "\n"
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: stateDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible}
+	run := func() {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(cfg+"[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+	run()
+	output := filepath.Join(outputDir, "a.md")
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), "Вступление.", "Вступление от редактора.", 1)
+	if err := os.WriteFile(output, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	answer = "# Заметка\n\nВступление.\n\nИтоги: перейти на Postgres."
+	run()
+
+	var out bytes.Buffer
+	if err := runMergeCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("rich merge вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "a.md  "+output+ConflictSuffix) {
+		t.Errorf("список конфликтов:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runMergeCommand([]string{"--config", configPath, "--dry-run", output}, &out); err != nil {
+		t.Fatalf("rich merge --dry-run вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "- Вступление.\n  + Вступление от редактора.") ||
+		!strings.Contains(out.String(), "(diff3, конфликтов: 0)") || requests.Load() != 2 {
+		t.Errorf("вывод rich merge --dry-run (запросов %d):\n%s", requests.Load(), out.String())
+	}
+	if data, _ := os.ReadFile(output); string(data) != edited {
+		t.Error("--dry-run изменил результат")
+	}
+
+	out.Reset()
+	if err := runMergeCommand([]string{"--config", configPath, "--yes", "a.md"}, &out); err != nil {
+		t.Fatalf("rich merge --yes вернул ошибку: %v", err)
+	}
+	data, _ = os.ReadFile(output)
+	if !strings.HasPrefix(string(data), "# Заметка\n\nВступление от редактора.\n\nИтоги: перейти на Postgres.") {
+		t.Errorf("объединенная версия:\n%s", data)
+	}
+	if _, err := os.Stat(output + ConflictSuffix); !os.IsNotExist(err) {
+		t.Error("файл .new не удален после слияния")
+	}
+	if conflicts, err := pendingConflicts(stateDir); err != nil || len(conflicts) != 0 {
+		t.Errorf("конфликты после слияния: %v, %v", conflicts, err)
+	}
+
+	// Объединенная версия - новая точка отсчета: повторное обогащение перезаписывает ее
+	run()
+	if _, err := os.Stat(output + ConflictSuffix); !os.IsNotExist(err) {
+		t.Error("объединенная версия считается ручной правкой")
+	}
+}
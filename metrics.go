@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Метрики читаемости и структуры текста
+type textMetrics struct {
+	Words     int `json:"words"`
+	Sentences int `json:"sentences"`
+	Headings  int `json:"headings"`
+	// Индекс удобочитаемости Флеша (для русского - адаптация Обороневой), больше - проще
+	Readability float64 `json:"readability"`
+	// Доля слов, находящихся в разделах под заголовками (0..1)
+	HeadingCoverage float64 `json:"heading_coverage"`
+}
+
+// Метрики документа до и после обогащения
+type qualityMetrics struct {
+	Original textMetrics `json:"original"`
+	Enriched textMetrics `json:"enriched"`
+	Delta    textMetrics `json:"delta"`
+}
+
+// Расчет метрик текста с учетом языка документа
+func computeTextMetrics(text, lang string) textMetrics {
+	var m textMetrics
+	syllables := 0
+	wordsUnderHeadings := 0
+	seenHeading := false
+	inFence := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if isHeadingLine(trimmed) {
+			m.Headings++
+			seenHeading = true
+			continue
+		}
+
+		words := strings.FieldsFunc(trimmed, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '\''
+		})
+		for _, w := range words {
+			if strings.IndexFunc(w, unicode.IsLetter) < 0 {
+				continue
+			}
+			m.Words++
+			syllables += countSyllables(w)
+			if seenHeading {
+				wordsUnderHeadings++
+			}
+		}
+		m.Sentences += strings.Count(trimmed, ".") + strings.Count(trimmed, "!") + strings.Count(trimmed, "?")
+	}
+
+	if m.Words == 0 {
+		return m
+	}
+	if m.Sentences == 0 {
+		m.Sentences = 1
+	}
+
+	asl := float64(m.Words) / float64(m.Sentences)
+	asw := float64(syllables) / float64(m.Words)
+	if lang == "ru" || lang == "uk" {
+		m.Readability = 206.835 - 1.3*asl - 60.1*asw
+	} else {
+		m.Readability = 206.835 - 1.015*asl - 84.6*asw
+	}
+	m.Readability = math.Round(m.Readability*10) / 10
+	m.HeadingCoverage = math.Round(float64(wordsUnderHeadings)/float64(m.Words)*100) / 100
+	return m
+}
+
+// Проверка, является ли строка markdown-заголовком
+func isHeadingLine(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level > 0 && level <= 6 && (len(line) == level || line[level] == ' ')
+}
+
+// Подсчет слогов по группам гласных
+func countSyllables(word string) int {
+	count := 0
+	prevVowel := false
+	for _, r := range strings.ToLower(word) {
+		vowel := strings.ContainsRune("aeiouyаеёиоуыэюяіїє", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// Расчет метрик до/после обогащения и их разницы
+func compareMetrics(original, enriched, lang string) *qualityMetrics {
+	q := &qualityMetrics{
+		Original: computeTextMetrics(original, lang),
+		Enriched: computeTextMetrics(enriched, lang),
+	}
+	q.Delta = textMetrics{
+		Words:           q.Enriched.Words - q.Original.Words,
+		Sentences:       q.Enriched.Sentences - q.Original.Sentences,
+		Headings:        q.Enriched.Headings - q.Original.Headings,
+		Readability:     math.Round((q.Enriched.Readability-q.Original.Readability)*10) / 10,
+		HeadingCoverage: math.Round((q.Enriched.HeadingCoverage-q.Original.HeadingCoverage)*100) / 100,
+	}
+	return q
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestComputeTextMetrics(t *testing.T) {
+	text := "Вступление без заголовка.\n\n# Заголовок\n\nПервое предложение. Второе предложение!\n\n```go\nfunc main() {}\n```\n\n## Раздел\n\nЕще текст?"
+	m := computeTextMetrics(text, "ru")
+
+	if m.Words != 9 {
+		t.Errorf("Ожидалось 9 слов, получено %d", m.Words)
+	}
+	if m.Sentences != 4 {
+		t.Errorf("Ожидалось 4 предложения, получено %d", m.Sentences)
+	}
+	if m.Headings != 2 {
+		t.Errorf("Ожидалось 2 заголовка, получено %d", m.Headings)
+	}
+	if m.HeadingCoverage != 0.67 {
+		t.Errorf("Ожидалось покрытие заголовками 0.67, получено %.2f", m.HeadingCoverage)
+	}
+	if m.Readability == 0 {
+		t.Error("Индекс читаемости не рассчитан")
+	}
+
+	if empty := computeTextMetrics("", "en"); empty != (textMetrics{}) {
+		t.Errorf("Для пустого текста ожидались нулевые метрики, получено %+v", empty)
+	}
+}
+
+func TestCompareMetrics(t *testing.T) {
+	q := compareMetrics("Short note.", "# Title\n\nShort note. Now with more words.", "en")
+	if q.Delta.Words != 4 {
+		t.Errorf("Ожидалось изменение количества слов +4, получено %d", q.Delta.Words)
+	}
+	if q.Delta.Headings != 1 {
+		t.Errorf("Ожидалось изменение количества заголовков +1, получено %d", q.Delta.Headings)
+	}
+	if q.Delta.HeadingCoverage != 1 {
+		t.Errorf("Ожидалось изменение покрытия заголовками +1, получено %.2f", q.Delta.HeadingCoverage)
+	}
+}
+
+func TestIsHeadingLine(t *testing.T) {
+	for line, expected := range map[string]bool{
+		"# Заголовок": true,
+		"###### H6":   true,
+		"####### H7":  false,
+		"#тег":        false,
+		"текст":       false,
+	} {
+		if got := isHeadingLine(line); got != expected {
+			t.Errorf("isHeadingLine(%q) = %v, ожидалось %v", line, got, expected)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// Формат API модели: тело запроса, адрес, заголовки авторизации и разбор ответа.
+// Провайдеры таблицы providers с одним форматом разделяют одну реализацию,
+// а отличия провайдеров (ключ, лимиты, ошибки) задаются полями provider
+type Provider interface {
+	// Имя формата (formatChat, formatAnthropic, formatGemini, formatOllama; "" - общий формат)
+	Format() string
+	// Тело запроса; image - изображение к последнему сообщению пользователя (nil - только текст)
+	BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error)
+	// Адрес запроса по api_url конфигурации
+	RequestURL(config *Config, p *provider) (string, error)
+	// Заголовки авторизации ключом API
+	SetAuth(req *http.Request, key string)
+	// Текст и токены ответа (false - ответ не содержит токенов)
+	ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error)
+	// Текст и токены потокового ответа (false - поток не содержал токенов)
+	ReadStream(r io.Reader) (string, Usage, bool, error)
+	// Имена полей параметров генерации для модели из конфигурации
+	ParamFields(config *Config) paramFields
+	// Формат принимает диалог из нескольких сообщений (примеры, контекст частей)
+	Conversation() bool
+}
+
+// Формат API провайдера; неизвестный API (nil) - общий формат
+func (p *provider) api() Provider {
+	if p == nil || p.API == nil {
+		return completionAPI{}
+	}
+	return p.API
+}
+
+// Авторизация Authorization: Bearer
+func setBearerAuth(req *http.Request, key string) {
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+// OpenAI Chat Completions: messages в запросе, choices в ответе
+type chatAPI struct{}
+
+func (chatAPI) Format() string { return formatChat }
+
+func (chatAPI) BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error) {
+	requestData := map[string]interface{}{
+		"model":    config.ModelName,
+		"messages": chatImageMessages(chatMessages(config, content, "assistant"), image),
+	}
+	maps.Copy(requestData, params)
+	if config.ReasoningEffort != "" {
+		requestData["reasoning_effort"] = config.ReasoningEffort
+	}
+	if config.Stream {
+		requestData["stream"] = true
+		if p != nil && p.StreamUsage {
+			requestData["stream_options"] = map[string]bool{"include_usage": true}
+		}
+	}
+	return requestData, nil
+}
+
+func (chatAPI) RequestURL(config *Config, p *provider) (string, error) {
+	return p.requestURL(config.ModelAPIURL), nil
+}
+
+func (chatAPI) SetAuth(req *http.Request, key string) { setBearerAuth(req, key) }
+
+func (chatAPI) ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error) {
+	text, err := chatResponseText(responseData)
+	if err != nil {
+		return "", Usage{}, false, err
+	}
+	usage, ok := parseUsage(responseData)
+	return text, usage, ok, nil
+}
+
+func (chatAPI) ReadStream(r io.Reader) (string, Usage, bool, error) { return readChatStream(r) }
+
+func (chatAPI) ParamFields(config *Config) paramFields {
+	if config.reasoningModel() {
+		// Модели с рассуждениями: лимит включает токены рассуждений,
+		// параметры выборки и стоп-последовательности не принимаются
+		return paramFields{MaxTokens: "max_completion_tokens"}
+	}
+	return paramFields{Temperature: "temperature", MaxTokens: "max_tokens", Stop: "stop", TopP: "top_p"}
+}
+
+func (chatAPI) Conversation() bool { return true }
+
+// Текст первого варианта ответа Chat Completions
+func chatResponseText(responseData map[string]interface{}) (string, error) {
+	choices, ok := responseData["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return "", errorf("некорректный формат ответа API: отсутствует поле choices или оно пустое")
+	}
+
+	firstChoice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return "", errorf("некорректный формат элемента choices в ответе API")
+	}
+
+	message, ok := firstChoice["message"].(map[string]interface{})
+	if !ok {
+		return "", errorf("некорректный формат поля message в ответе API")
+	}
+	return chatMessageText(message)
+}
+
+// Anthropic Messages API: messages в запросе, content из блоков в ответе
+type anthropicAPI struct{}
+
+func (anthropicAPI) Format() string { return formatAnthropic }
+
+func (anthropicAPI) BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error) {
+	requestData := map[string]interface{}{
+		"model":    config.ModelName,
+		"messages": anthropicImageMessages(chatMessages(config, content, "assistant"), image),
+	}
+	maps.Copy(requestData, params)
+	if config.Stream {
+		requestData["stream"] = true
+	}
+	return requestData, nil
+}
+
+// Адрес без пути /messages заменяется адресом провайдера по умолчанию
+func (anthropicAPI) RequestURL(config *Config, p *provider) (string, error) {
+	apiURL := p.requestURL(config.ModelAPIURL)
+	if p != nil && !strings.HasSuffix(strings.TrimRight(apiURL, "/"), "/messages") {
+		apiURL = p.DefaultURL
+	}
+	return apiURL, nil
+}
+
+func (anthropicAPI) SetAuth(req *http.Request, key string) {
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+}
+
+func (anthropicAPI) ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error) {
+	text, err := anthropicText(responseData)
+	if err != nil {
+		return "", Usage{}, false, err
+	}
+	usage, ok := parseUsage(responseData)
+	return text, usage, ok, nil
+}
+
+func (anthropicAPI) ReadStream(r io.Reader) (string, Usage, bool, error) {
+	return readAnthropicStream(r)
+}
+
+func (anthropicAPI) ParamFields(config *Config) paramFields {
+	return paramFields{Temperature: "temperature", MaxTokens: "max_tokens", Stop: "stop_sequences", TopP: "top_p"}
+}
+
+func (anthropicAPI) Conversation() bool { return true }
+
+// Gemini generateContent: contents в запросе, candidates в ответе
+type geminiAPI struct{}
+
+func (geminiAPI) Format() string { return formatGemini }
+
+func (geminiAPI) BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error) {
+	return withGeminiImage(geminiRequest(chatMessages(config, content, "model"), params), image), nil
+}
+
+func (geminiAPI) RequestURL(config *Config, p *provider) (string, error) {
+	apiURL, err := vertexURL(config)
+	if err != nil {
+		return "", withCategory(ErrorConfig, err)
+	}
+	return apiURL, nil
+}
+
+func (geminiAPI) SetAuth(req *http.Request, key string) {
+	req.Header.Set("x-goog-api-key", key)
+}
+
+func (geminiAPI) ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error) {
+	return geminiText(responseData)
+}
+
+func (geminiAPI) ReadStream(r io.Reader) (string, Usage, bool, error) { return readGeminiStream(r) }
+
+func (geminiAPI) ParamFields(config *Config) paramFields {
+	return paramFields{Temperature: "temperature", MaxTokens: "maxOutputTokens", Stop: "stopSequences", TopP: "topP"}
+}
+
+func (geminiAPI) Conversation() bool { return true }
+
+// Ollama /api/chat: messages и options в запросе, message в ответе, поток - NDJSON
+type ollamaAPI struct{}
+
+func (ollamaAPI) Format() string { return formatOllama }
+
+func (ollamaAPI) BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error) {
+	return ollamaRequest(config.ModelName, ollamaMessages(chatMessages(config, content, "assistant"), image), params, config.Stream), nil
+}
+
+func (ollamaAPI) RequestURL(config *Config, p *provider) (string, error) {
+	return p.requestURL(config.ModelAPIURL), nil
+}
+
+func (ollamaAPI) SetAuth(req *http.Request, key string) { setBearerAuth(req, key) }
+
+func (ollamaAPI) ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error) {
+	return ollamaText(responseData)
+}
+
+func (ollamaAPI) ReadStream(r io.Reader) (string, Usage, bool, error) { return readOllamaStream(r) }
+
+// Поля options запроса Ollama
+func (ollamaAPI) ParamFields(config *Config) paramFields {
+	return paramFields{Temperature: "temperature", MaxTokens: "num_predict", Stop: "stop", TopP: "top_p"}
+}
+
+func (ollamaAPI) Conversation() bool { return true }
+
+// Общий формат неизвестного API: промпт с содержимым в поле prompt, текст ответа
+// в поле text; сервер с адресом chat/completions отвечает в формате Chat Completions
+type completionAPI struct{}
+
+func (completionAPI) Format() string { return "" }
+
+func (completionAPI) BuildRequest(config *Config, p *provider, content string, image *imageAttachment, params map[string]interface{}) (map[string]interface{}, error) {
+	if image != nil {
+		return nil, withCategory(ErrorConfig, errorf("API %s не поддерживает изображения в запросе", config.ModelAPIURL))
+	}
+	requestData := map[string]interface{}{
+		"model":  config.ModelName,
+		"prompt": fmt.Sprintf("%s\n\n%s", config.Prompt, content),
+	}
+	maps.Copy(requestData, params)
+	return requestData, nil
+}
+
+func (completionAPI) RequestURL(config *Config, p *provider) (string, error) {
+	return config.ModelAPIURL, nil
+}
+
+func (completionAPI) SetAuth(req *http.Request, key string) { setBearerAuth(req, key) }
+
+func (completionAPI) ParseResponse(config *Config, responseData map[string]interface{}) (string, Usage, bool, error) {
+	var text string
+	if strings.Contains(strings.ToLower(config.ModelAPIURL), "chat/completions") {
+		var err error
+		if text, err = chatResponseText(responseData); err != nil {
+			return "", Usage{}, false, err
+		}
+	} else {
+		var ok bool
+		if text, ok = responseData["text"].(string); !ok {
+			return "", Usage{}, false, errorf("некорректный формат ответа API")
+		}
+	}
+	usage, ok := parseUsage(responseData)
+	return text, usage, ok, nil
+}
+
+func (completionAPI) ReadStream(r io.Reader) (string, Usage, bool, error) { return readChatStream(r) }
+
+func (completionAPI) ParamFields(config *Config) paramFields {
+	return paramFields{Temperature: "temperature", MaxTokens: "max_tokens", Stop: "stop", TopP: "top_p"}
+}
+
+func (completionAPI) Conversation() bool { return false }
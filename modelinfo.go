@@ -0,0 +1,383 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// Запас токенов контекстного окна на служебную разметку запроса и погрешность оценки
+	contextSafetyMargin = 256
+	// Минимальный размер ответа, при котором запрос имеет смысл отправлять
+	minResponseTokens = 256
+	// max_tokens в режиме auto для моделей, отсутствующих в таблице
+	fallbackMaxTokens = 4096
+)
+
+// Ограничения модели в токенах
+type modelInfo struct {
+	ContextWindow int
+	MaxOutput     int
+}
+
+// Известные модели по префиксу имени (без префикса провайдера OpenRouter)
+var knownModels = map[string]modelInfo{
+	"gpt-3.5-turbo":     {16385, 4096},
+	"gpt-4":             {8192, 4096},
+	"gpt-4-32k":         {32768, 4096},
+	"gpt-4-turbo":       {128000, 4096},
+	"gpt-4o":            {128000, 16384},
+	"gpt-4o-mini":       {128000, 16384},
+	"gpt-4.1":           {1047576, 32768},
+	"gpt-5":             {400000, 128000},
+	"o1":                {200000, 100000},
+	"o1-mini":           {128000, 65536},
+	"o3":                {200000, 100000},
+	"o4-mini":           {200000, 100000},
+	"claude-3-haiku":    {200000, 4096},
+	"claude-3-opus":     {200000, 4096},
+	"claude-3-5-haiku":  {200000, 8192},
+	"claude-3-5-sonnet": {200000, 8192},
+	"claude-3-7-sonnet": {200000, 64000},
+	"claude-sonnet-4":   {200000, 64000},
+	"claude-opus-4":     {200000, 32000},
+	"gemini-1.5-flash":  {1048576, 8192},
+	"gemini-1.5-pro":    {2097152, 8192},
+	"gemini-2.0":        {1048576, 8192},
+	"gemini-2.5":        {1048576, 65536},
+	"deepseek-chat":     {65536, 8192},
+	"deepseek-r1":       {65536, 8192},
+	"deepseek-reasoner": {65536, 32768},
+	"grok-2":            {131072, 32768},
+	"grok-3":            {131072, 32768},
+	"grok-4":            {256000, 32768},
+	"llama-3.1":         {131072, 8192},
+	"llama-3.3":         {131072, 8192},
+	"llama-4":           {131072, 8192},
+	"qwen2.5":           {32768, 8192},
+	"deepseek-v3":       {131072, 8192},
+	"mixtral-8x22b":     {65536, 8192},
+	"mixtral-8x7b":      {32768, 8192},
+	"gemma2":            {8192, 8192},
+	"mistral-large":     {131072, 8192},
+	"mistral-medium":    {131072, 8192},
+	"mistral-small":     {131072, 8192},
+	"ministral-3b":      {131072, 8192},
+	"ministral-8b":      {131072, 8192},
+	"open-mistral-nemo": {131072, 8192},
+	"codestral":         {262144, 8192},
+	"pixtral-large":     {131072, 8192},
+}
+
+// Поиск модели в таблице по самому длинному совпадающему префиксу имени;
+// префикс провайдера ("openai/") и суффикс варианта (":free") не учитываются
+func lookupModelInfo(name string) (modelInfo, bool) {
+	key, ok := modelKey(name)
+	if !ok {
+		return modelInfo{}, false
+	}
+	return knownModels[key], true
+}
+
+// Версии в именах моделей Fireworks: llama-v3p3-70b-instruct, qwen2p5-72b-instruct
+var (
+	fireworksVersionPattern = regexp.MustCompile(`(\d)p(\d)`)
+	fireworksLlamaPattern   = regexp.MustCompile(`^llama-v(\d)`)
+)
+
+// Ключ таблицы моделей для имени модели
+func modelKey(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	name = fireworksVersionPattern.ReplaceAllString(name, "$1.$2")
+	name = fireworksLlamaPattern.ReplaceAllString(name, "llama-$1")
+
+	best := ""
+	for prefix := range knownModels {
+		if len(prefix) > len(best) && (name == prefix || strings.HasPrefix(name, prefix+"-") || strings.HasPrefix(name, prefix+".")) {
+			best = prefix
+		}
+	}
+	return best, best != ""
+}
+
+// Модели с рассуждениями (ключи knownModels): принимают max_completion_tokens
+// вместо max_tokens, не принимают temperature и поддерживают reasoning_effort
+var reasoningModels = map[string]bool{
+	"o1":      true,
+	"o1-mini": true,
+	"o3":      true,
+	"o4-mini": true,
+	"gpt-5":   true,
+}
+
+// Значения reasoning_effort
+var reasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// Проверка, что модель из конфигурации - модель с рассуждениями: reasoning_model
+// из конфигурации или встроенная таблица
+func (c *Config) reasoningModel() bool {
+	switch c.ReasoningModel {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	key, ok := modelKey(c.ModelName)
+	return ok && reasoningModels[key]
+}
+
+// Месяц выпуска известных моделей (ключи совпадают с knownModels)
+var modelReleases = map[string]string{
+	"gpt-3.5-turbo":     "2023-03",
+	"gpt-4":             "2023-03",
+	"gpt-4-32k":         "2023-03",
+	"gpt-4-turbo":       "2023-11",
+	"gpt-4o":            "2024-05",
+	"gpt-4o-mini":       "2024-07",
+	"gpt-4.1":           "2025-04",
+	"gpt-5":             "2025-08",
+	"o1":                "2024-12",
+	"o1-mini":           "2024-09",
+	"o3":                "2025-04",
+	"o4-mini":           "2025-04",
+	"claude-3-haiku":    "2024-03",
+	"claude-3-opus":     "2024-03",
+	"claude-3-5-haiku":  "2024-10",
+	"claude-3-5-sonnet": "2024-06",
+	"claude-3-7-sonnet": "2025-02",
+	"claude-sonnet-4":   "2025-05",
+	"claude-opus-4":     "2025-05",
+	"gemini-1.5-flash":  "2024-05",
+	"gemini-1.5-pro":    "2024-02",
+	"gemini-2.0":        "2024-12",
+	"gemini-2.5":        "2025-03",
+	"deepseek-chat":     "2024-12",
+	"deepseek-r1":       "2025-01",
+	"deepseek-reasoner": "2025-01",
+	"grok-2":            "2024-08",
+	"grok-3":            "2025-02",
+	"grok-4":            "2025-07",
+	"llama-3.1":         "2024-07",
+	"llama-3.3":         "2024-12",
+	"llama-4":           "2025-04",
+	"qwen2.5":           "2024-09",
+	"deepseek-v3":       "2024-12",
+	"mixtral-8x22b":     "2024-04",
+	"mixtral-8x7b":      "2023-12",
+	"gemma2":            "2024-06",
+	"mistral-large":     "2024-02",
+	"mistral-medium":    "2025-05",
+	"mistral-small":     "2024-02",
+	"ministral-3b":      "2024-10",
+	"ministral-8b":      "2024-10",
+	"open-mistral-nemo": "2024-07",
+	"codestral":         "2024-05",
+	"pixtral-large":     "2024-11",
+}
+
+// Месяц выпуска модели в формате YYYY-MM
+func modelRelease(name string) (string, bool) {
+	key, ok := modelKey(name)
+	if !ok {
+		return "", false
+	}
+	release, ok := modelReleases[key]
+	return release, ok
+}
+
+// Проверка, что модель выпущена раньше эталонной; для неизвестных моделей known = false
+func modelOlderThan(name, reference string) (older, known bool) {
+	a, okA := modelRelease(name)
+	b, okB := modelRelease(reference)
+	if !okA || !okB {
+		return false, false
+	}
+	return a < b, true
+}
+
+// Ограничения модели из конфигурации (context_window, max_output) или из таблицы
+func (c *Config) modelInfo() (modelInfo, bool) {
+	info, known := lookupModelInfo(c.ModelName)
+	if c.ContextWindow > 0 {
+		info.ContextWindow = c.ContextWindow
+		known = true
+	}
+	if c.MaxOutputTokens > 0 {
+		info.MaxOutput = c.MaxOutputTokens
+	}
+	if known && info.MaxOutput <= 0 {
+		info.MaxOutput = fallbackMaxTokens
+	}
+	return info, known
+}
+
+// Значение max_tokens для запроса: в режиме auto - максимум ответа модели, иначе
+// значение из конфигурации; в обоих случаях не больше, чем остается в контекстном окне
+func requestMaxTokens(config *Config, fullPrompt string) (int, error) {
+	info, known := config.modelInfo()
+	if !known {
+		if config.AutoMaxTokens {
+			return fallbackMaxTokens, nil
+		}
+		return config.MaxTokens, nil
+	}
+
+	maxTokens := config.MaxTokens
+	if config.AutoMaxTokens || maxTokens > info.MaxOutput {
+		maxTokens = info.MaxOutput
+	}
+	promptTokens := estimateTokens(fullPrompt)
+	available := info.ContextWindow - promptTokens - contextSafetyMargin
+	if available < minResponseTokens {
+		return 0, errorf("запрос (~%d токенов) не помещается в контекстное окно модели %s (%d токенов): для ответа остается %d токенов из необходимых %d, сократите запрос примерно на %d токенов",
+			promptTokens, config.ModelName, info.ContextWindow, max(available, 0), minResponseTokens, minResponseTokens-available)
+	}
+	if available < maxTokens {
+		logf("max_tokens уменьшен с %d до %d: запрос ~%d токенов в окне %d токенов модели %s",
+			maxTokens, available, promptTokens, info.ContextWindow, config.ModelName)
+	}
+	return min(maxTokens, available), nil
+}
+
+// Разбиение документа на части, которые помещаются в контекст модели вместе с ответом
+// и не больше max_chunk_tokens. Документ делится по заголовкам, слишком большие
+// разделы - по абзацам. Без сведений о модели и max_chunk_tokens или если документ
+// помещается целиком, возвращается одна часть
+func splitForContext(config *Config, content string) []string {
+	info, known := config.modelInfo()
+	if !known && config.MaxChunkTokens <= 0 {
+		return []string{content}
+	}
+
+	limit := config.MaxChunkTokens
+	available, outputReserve := 0, 0
+	if known {
+		// Ответ должен вмещать обогащенную часть, которая обычно больше исходной
+		outputReserve = info.MaxOutput
+		if !config.AutoMaxTokens && config.MaxTokens > 0 {
+			outputReserve = min(config.MaxTokens, info.MaxOutput)
+		}
+		available = info.ContextWindow - estimateTokens(config.Prompt) - contextSafetyMargin
+		contextLimit := available - outputReserve
+		if config.AutoMaxTokens {
+			// В режиме auto части подбираются так, чтобы ответ мог быть вдвое больше части
+			contextLimit = min(contextLimit, outputReserve/2)
+		}
+		contextLimit = max(contextLimit, minResponseTokens)
+		if limit <= 0 || contextLimit < limit {
+			limit = contextLimit
+		}
+	}
+	if estimateTokens(content) <= limit {
+		return []string{content}
+	}
+	// Части, кроме первой, отправляются с контекстом предыдущих: он занимает место в окне
+	if known && config.ChunkContext != ChunkContextNone && config.ChunkContext != "" {
+		limit = min(limit, max(available-outputReserve-config.ChunkContextTokens, minResponseTokens))
+	}
+	return packPieces(splitPieces(content, limit), limit)
+}
+
+// Фрагменты документа не больше limit токенов: frontmatter целиком, разделы по
+// заголовкам, слишком большие разделы - по абзацам
+func splitPieces(content string, limit int) []string {
+	var pieces []string
+	body := content
+	if _, rest, ok := parseFrontmatter([]byte(content)); ok {
+		pieces = append(pieces, content[:len(content)-len(rest)])
+		body = string(rest)
+	}
+	for _, section := range splitByHeadings(body) {
+		if estimateTokens(section.Text) <= limit {
+			pieces = append(pieces, section.Text)
+			continue
+		}
+		pieces = append(pieces, splitOversized(section.Text, limit)...)
+	}
+	return pieces
+}
+
+// Разбиение слишком большого раздела по абзацам вне блоков кода, а абзацев и
+// блоков кода, которые больше limit, - по символам
+func splitOversized(text string, limit int) []string {
+	var pieces []string
+	for _, para := range splitParagraphs(text) {
+		if estimateTokens(para) <= limit {
+			pieces = append(pieces, para)
+			continue
+		}
+		runes := []rune(para)
+		step := limit * 4
+		for len(runes) > 0 {
+			n := min(step, len(runes))
+			pieces = append(pieces, string(runes[:n]))
+			runes = runes[n:]
+		}
+	}
+	return pieces
+}
+
+// Абзацы текста вместе с завершающими пустыми строками; пустые строки внутри
+// блоков кода абзацы не разделяют
+func splitParagraphs(text string) []string {
+	var paras []string
+	current := ""
+	inFence, blank := false, false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if blank && trimmed != "" && !inFence {
+			paras = append(paras, current)
+			current = ""
+		}
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		blank = trimmed == "" && !inFence
+		current += line
+	}
+	if current != "" {
+		paras = append(paras, current)
+	}
+	return paras
+}
+
+// Сборка документа из обогащенных частей: frontmatter, который модель добавила
+// к частям после первой, удаляется - у документа остается frontmatter первой части
+func joinChunks(chunks []string) string {
+	if len(chunks) == 1 {
+		return chunks[0]
+	}
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if i > 0 {
+			if _, body, ok := parseFrontmatter([]byte(strings.TrimSpace(chunk))); ok {
+				chunk = string(body)
+			}
+		}
+		parts[i] = strings.TrimSpace(chunk)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Объединение подряд идущих фрагментов в части не больше limit токенов
+func packPieces(pieces []string, limit int) []string {
+	var chunks []string
+	current := ""
+	for _, p := range pieces {
+		if current != "" && estimateTokens(current+p) > limit {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		current += p
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
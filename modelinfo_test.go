@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupModelInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  int
+		known   bool
+		maxResp int
+	}{
+		{"gpt-4o", 128000, true, 16384},
+		{"gpt-4o-mini-2024-07-18", 128000, true, 16384},
+		{"openai/gpt-4.1-nano", 1047576, true, 32768},
+		{"google/gemini-2.0-pro-exp-02-05:free", 1048576, true, 8192},
+		{"claude-3-7-sonnet-20250219", 200000, true, 64000},
+		{"gpt-4", 8192, true, 4096},
+		{"accounts/fireworks/models/llama-v3p3-70b-instruct", 131072, true, 8192},
+		{"accounts/fireworks/models/qwen2p5-72b-instruct", 32768, true, 8192},
+		{"meta-llama/Llama-3.3-70B-Instruct-Turbo", 131072, true, 8192},
+		{"gpt-40x", 0, false, 0},
+		{"unknown-model", 0, false, 0},
+	}
+	for _, tt := range tests {
+		info, known := lookupModelInfo(tt.name)
+		if known != tt.known || info.ContextWindow != tt.window || info.MaxOutput != tt.maxResp {
+			t.Errorf("lookupModelInfo(%q) = %+v, %v; ожидалось %d/%d, %v", tt.name, info, known, tt.window, tt.maxResp, tt.known)
+		}
+	}
+}
+
+func TestRequestMaxTokens(t *testing.T) {
+	// Явное значение ограничивается максимумом ответа модели
+	config := &Config{ModelName: "gpt-4", MaxTokens: 32000}
+	if got, err := requestMaxTokens(config, "короткий промпт"); err != nil || got != 4096 {
+		t.Errorf("Ожидалось 4096, получено %d (%v)", got, err)
+	}
+
+	// В режиме auto ответ ограничен остатком контекстного окна
+	config = &Config{ModelName: "gpt-4", AutoMaxTokens: true}
+	prompt := strings.Repeat("слово ", 4000) // ~6000 токенов
+	got, err := requestMaxTokens(config, prompt)
+	if err != nil {
+		t.Fatalf("requestMaxTokens() вернул ошибку: %v", err)
+	}
+	if expected := 8192 - estimateTokens(prompt) - contextSafetyMargin; got != expected {
+		t.Errorf("Ожидалось %d, получено %d", expected, got)
+	}
+
+	// Запрос, не помещающийся в окно, не отправляется
+	if _, err := requestMaxTokens(config, strings.Repeat("x", 40000)); err == nil {
+		t.Error("Ожидалась ошибка переполнения контекста")
+	} else if !strings.Contains(err.Error(), "сократите запрос примерно на") {
+		t.Errorf("Ошибка должна указывать, на сколько сократить запрос: %v", err)
+	}
+
+	// Неизвестная модель: значение из конфигурации или запасное значение для auto
+	if got, _ := requestMaxTokens(&Config{ModelName: "local", MaxTokens: 1000}, "x"); got != 1000 {
+		t.Errorf("Ожидалось 1000, получено %d", got)
+	}
+	if got, _ := requestMaxTokens(&Config{ModelName: "local", AutoMaxTokens: true}, "x"); got != fallbackMaxTokens {
+		t.Errorf("Ожидалось %d, получено %d", fallbackMaxTokens, got)
+	}
+
+	// Переопределение контекстного окна в конфигурации
+	config = &Config{ModelName: "local", AutoMaxTokens: true, ContextWindow: 2000, MaxOutputTokens: 5000}
+	if got, _ := requestMaxTokens(config, "x"); got != 2000-1-contextSafetyMargin {
+		t.Errorf("Ответ должен ограничиваться окном из конфигурации, получено %d", got)
+	}
+}
+
+func TestSplitForContext(t *testing.T) {
+	section := func(title string) string {
+		return "## " + title + "\n\n" + strings.Repeat("Текст раздела. ", 150) + "\n\n"
+	}
+	content := "# Документ\n\n" + section("Первый") + section("Второй") + section("Третий")
+
+	// Без сведений о модели документ не делится
+	if chunks := splitForContext(&Config{ModelName: "local", AutoMaxTokens: true}, content); len(chunks) != 1 {
+		t.Errorf("Ожидалась одна часть, получено %d", len(chunks))
+	}
+
+	// Окно 4000 токенов, ответ до 1400: части не больше 700 токенов
+	config := &Config{ModelName: "local", AutoMaxTokens: true, ContextWindow: 4000, MaxOutputTokens: 1400}
+	chunks := splitForContext(config, content)
+	if len(chunks) < 3 {
+		t.Fatalf("Ожидалось не меньше 3 частей, получено %d", len(chunks))
+	}
+	if strings.Join(chunks, "") != content {
+		t.Error("Части должны в сумме давать исходный документ")
+	}
+	for i, chunk := range chunks {
+		if estimateTokens(chunk) > 700 {
+			t.Errorf("Часть %d больше лимита: %d токенов", i+1, estimateTokens(chunk))
+		}
+	}
+	if !strings.HasPrefix(chunks[1], "## ") {
+		t.Errorf("Части должны начинаться с заголовков разделов: %q", chunks[1][:20])
+	}
+}
+
+func TestSplitForContextMaxChunkTokens(t *testing.T) {
+	code := "```go\n" + strings.Repeat("x := 1\n\ny := 2\n", 20) + "```\n\n"
+	content := "---\ntitle: Документ\ntags: [a]\n---\n# Документ\n\n" +
+		"## Первый\n\n" + strings.Repeat("Текст раздела. ", 60) + "\n\n" + code +
+		"## Второй\n\n" + strings.Repeat("Текст раздела. ", 60) + "\n"
+
+	// Модель неизвестна, но max_chunk_tokens задан
+	config := &Config{ModelName: "local", MaxChunkTokens: 300}
+	chunks := splitForContext(config, content)
+	if len(chunks) < 3 {
+		t.Fatalf("Ожидалось не меньше 3 частей, получено %d", len(chunks))
+	}
+	if strings.Join(chunks, "") != content {
+		t.Error("Части должны в сумме давать исходный документ")
+	}
+	if !strings.HasPrefix(chunks[0], "---\ntitle: Документ\ntags: [a]\n---\n") {
+		t.Errorf("frontmatter должен оставаться целиком в первой части: %q", chunks[0])
+	}
+	for i, chunk := range chunks {
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Errorf("Часть %d разрывает блок кода: %q", i+1, chunk)
+		}
+	}
+
+	// max_chunk_tokens меньше лимита по контекстному окну
+	config = &Config{ModelName: "local", AutoMaxTokens: true, ContextWindow: 100000, MaxOutputTokens: 16000, MaxChunkTokens: 300}
+	for i, chunk := range splitForContext(config, content) {
+		if estimateTokens(chunk) > 300 && !strings.Contains(chunk, "```") {
+			t.Errorf("Часть %d больше max_chunk_tokens: %d токенов", i+1, estimateTokens(chunk))
+		}
+	}
+}
+
+func TestJoinChunks(t *testing.T) {
+	got := joinChunks([]string{
+		"---\ntags: [a]\n---\n# Документ\n\nПервая часть\n",
+		"---\ntags: [b]\n---\n## Второй\n\nВторая часть\n\n",
+	})
+	want := "---\ntags: [a]\n---\n# Документ\n\nПервая часть\n\n## Второй\n\nВторая часть"
+	if got != want {
+		t.Errorf("joinChunks() = %q, ожидалось %q", got, want)
+	}
+	if got := joinChunks([]string{"Текст\n"}); got != "Текст\n" {
+		t.Errorf("Одна часть должна возвращаться без изменений: %q", got)
+	}
+}
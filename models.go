@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Модель из списка провайдера
+type modelListing struct {
+	ID string
+	// Другие имена той же модели (Mistral: mistral-large-latest)
+	Aliases       []string
+	ContextWindow int
+	MaxOutput     int
+	// Цены за 1 млн токенов, $ (если провайдер их сообщает)
+	InputPrice  float64
+	OutputPrice float64
+	HasPricing  bool
+}
+
+// Типы API списка моделей
+const (
+	modelsAPIOpenAI     = "openai"
+	modelsAPIOpenRouter = "openrouter"
+	modelsAPIAnthropic  = "anthropic"
+	modelsAPIOllama     = "ollama"
+	modelsAPITogether   = "together"
+	modelsAPIVertex     = "vertex"
+)
+
+// Адрес списка моделей и формат ответа по адресу API из конфигурации
+func modelsEndpoint(apiURL string) (string, string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return "", "", errorf("некорректный api_url: %q", apiURL)
+	}
+	lower := strings.ToLower(apiURL)
+	base := u.Scheme + "://" + u.Host
+	switch {
+	case strings.Contains(lower, "openrouter"):
+		return "https://openrouter.ai/api/v1/models", modelsAPIOpenRouter, nil
+	case strings.Contains(lower, "anthropic"):
+		return "https://api.anthropic.com/v1/models?limit=1000", modelsAPIAnthropic, nil
+	case strings.Contains(lower, "aiplatform.googleapis.com"):
+		return base + "/v1beta1/publishers/google/models", modelsAPIVertex, nil
+	case strings.Contains(lower, "together.xyz"), strings.Contains(lower, "together.ai"):
+		return base + "/v1/models", modelsAPITogether, nil
+	case strings.HasSuffix(u.Host, ":11434") || strings.HasPrefix(u.Path, "/api/generate") || strings.HasPrefix(u.Path, "/api/chat"):
+		return base + "/api/tags", modelsAPIOllama, nil
+	}
+	// OpenAI и совместимые API: <префикс до /v1>/v1/models
+	if i := strings.Index(u.Path, "/v1"); i >= 0 {
+		return base + u.Path[:i] + "/v1/models", modelsAPIOpenAI, nil
+	}
+	return base + "/v1/models", modelsAPIOpenAI, nil
+}
+
+// Запрос списка моделей у провайдера из конфигурации
+func fetchModels(config *Config) ([]modelListing, error) {
+	endpoint, kind, err := modelsEndpoint(config.ModelAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	setUserAgent(req)
+	if err := setAuthHeaders(req, config); err != nil {
+		return nil, err
+	}
+
+	client := config.Network.client(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errorf("ошибка при выполнении HTTP запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := readResponseBody(resp, config.Network.maxResponseBytes())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorf("запрос списка моделей вернул статус %d: %s", resp.StatusCode, string(body))
+	}
+	if err := checkJSONResponse(resp, body); err != nil {
+		return nil, err
+	}
+
+	models, err := parseModelList(kind, body)
+	if err != nil {
+		return nil, errorf("ошибка при разборе списка моделей: %v", err)
+	}
+
+	// Недостающие сведения о контексте - из встроенной таблицы
+	for i := range models {
+		if info, ok := lookupModelInfo(models[i].ID); ok {
+			if models[i].ContextWindow == 0 {
+				models[i].ContextWindow = info.ContextWindow
+			}
+			if models[i].MaxOutput == 0 {
+				models[i].MaxOutput = info.MaxOutput
+			}
+		}
+	}
+	sort.Slice(models, func(a, b int) bool { return models[a].ID < models[b].ID })
+	return models, nil
+}
+
+// Разбор ответа списка моделей в зависимости от формата API
+func parseModelList(kind string, body []byte) ([]modelListing, error) {
+	var models []modelListing
+	switch kind {
+	case modelsAPIOllama:
+		var data struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, m := range data.Models {
+			models = append(models, modelListing{ID: m.Name})
+		}
+	case modelsAPIOpenRouter:
+		var data struct {
+			Data []struct {
+				ID            string `json:"id"`
+				ContextLength int    `json:"context_length"`
+				Pricing       struct {
+					Prompt     string `json:"prompt"`
+					Completion string `json:"completion"`
+				} `json:"pricing"`
+				TopProvider struct {
+					MaxCompletionTokens int `json:"max_completion_tokens"`
+				} `json:"top_provider"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, m := range data.Data {
+			listing := modelListing{ID: m.ID, ContextWindow: m.ContextLength, MaxOutput: m.TopProvider.MaxCompletionTokens}
+			// Цены OpenRouter указаны за один токен
+			in, errIn := strconv.ParseFloat(m.Pricing.Prompt, 64)
+			out, errOut := strconv.ParseFloat(m.Pricing.Completion, 64)
+			if errIn == nil && errOut == nil {
+				listing.InputPrice, listing.OutputPrice, listing.HasPricing = in*1e6, out*1e6, true
+			}
+			models = append(models, listing)
+		}
+	case modelsAPIVertex:
+		// Vertex AI: модели издателя Google (publishers/google/models/<имя>)
+		var data struct {
+			PublisherModels []struct {
+				Name string `json:"name"`
+			} `json:"publisherModels"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, m := range data.PublisherModels {
+			models = append(models, modelListing{ID: m.Name[strings.LastIndex(m.Name, "/")+1:]})
+		}
+	case modelsAPITogether:
+		// Together: массив моделей с размером контекста и ценами за 1 млн токенов
+		var data []struct {
+			ID            string `json:"id"`
+			Type          string `json:"type"`
+			ContextLength int    `json:"context_length"`
+			Pricing       struct {
+				Input  float64 `json:"input"`
+				Output float64 `json:"output"`
+			} `json:"pricing"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, m := range data {
+			if m.Type != "" && m.Type != "chat" && m.Type != "language" {
+				continue
+			}
+			listing := modelListing{ID: m.ID, ContextWindow: m.ContextLength}
+			if m.Pricing.Input > 0 || m.Pricing.Output > 0 {
+				listing.InputPrice, listing.OutputPrice, listing.HasPricing = m.Pricing.Input, m.Pricing.Output, true
+			}
+			models = append(models, listing)
+		}
+	default:
+		// OpenAI, Anthropic и совместимые: {"data": [{"id": ...}]}; Mistral
+		// дополнительно сообщает размер контекста и псевдонимы модели
+		var data struct {
+			Data []struct {
+				ID               string   `json:"id"`
+				MaxContextLength int      `json:"max_context_length"`
+				Aliases          []string `json:"aliases"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, m := range data.Data {
+			models = append(models, modelListing{ID: m.ID, ContextWindow: m.MaxContextLength, Aliases: m.Aliases})
+		}
+	}
+	return models, nil
+}
+
+// rich models [--filter <подстрока>]: список моделей провайдера из конфигурации
+func runModelsCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	filter := fs.String("filter", "", tr("Показывать только модели, содержащие подстроку"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+
+	models, err := fetchModels(config)
+	if err != nil {
+		return err
+	}
+	printModels(out, models, *filter)
+
+	for _, m := range models {
+		if m.ID == config.ModelName || slices.Contains(m.Aliases, config.ModelName) {
+			return nil
+		}
+	}
+	fmt.Fprintf(out, tr("\nПредупреждение: модель %q из конфигурации не найдена в списке провайдера\n"), config.ModelName)
+	return nil
+}
+
+// Вывод таблицы моделей
+func printModels(out io.Writer, models []modelListing, filter string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, tr("МОДЕЛЬ\tКОНТЕКСТ\tОТВЕТ\tВХОД $/1M\tВЫХОД $/1M"))
+	for _, m := range models {
+		if filter != "" && !strings.Contains(strings.ToLower(m.ID), strings.ToLower(filter)) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.ID, formatTokens(m.ContextWindow), formatTokens(m.MaxOutput),
+			formatPrice(m.InputPrice, m.HasPricing), formatPrice(m.OutputPrice, m.HasPricing))
+	}
+	w.Flush()
+}
+
+// Форматирование числа токенов ("-" - неизвестно)
+func formatTokens(n int) string {
+	if n <= 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+// Форматирование цены ("-" - провайдер не сообщает цену)
+func formatPrice(price float64, known bool) string {
+	if !known {
+		return "-"
+	}
+	return strconv.FormatFloat(math.Round(price*1e4)/1e4, 'f', -1, 64)
+}
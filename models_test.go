@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModelsEndpoint(t *testing.T) {
+	tests := []struct {
+		apiURL   string
+		endpoint string
+		kind     string
+	}{
+		{"https://api.openai.com/v1/chat/completions", "https://api.openai.com/v1/models", modelsAPIOpenAI},
+		{"https://openrouter.ai/api/v1/chat/completions", "https://openrouter.ai/api/v1/models", modelsAPIOpenRouter},
+		{"https://api.anthropic.com", "https://api.anthropic.com/v1/models?limit=1000", modelsAPIAnthropic},
+		{"http://localhost:11434/api/generate", "http://localhost:11434/api/tags", modelsAPIOllama},
+		{"http://gpu-box/api/chat", "http://gpu-box/api/tags", modelsAPIOllama},
+		{"http://proxy.local/llm/v1/chat/completions", "http://proxy.local/llm/v1/models", modelsAPIOpenAI},
+		{"http://proxy.local/complete", "http://proxy.local/v1/models", modelsAPIOpenAI},
+		{"https://api.together.xyz/v1/chat/completions", "https://api.together.xyz/v1/models", modelsAPITogether},
+		{"https://api.fireworks.ai/inference/v1/chat/completions", "https://api.fireworks.ai/inference/v1/models", modelsAPIOpenAI},
+	}
+	for _, tt := range tests {
+		endpoint, kind, err := modelsEndpoint(tt.apiURL)
+		if err != nil || endpoint != tt.endpoint || kind != tt.kind {
+			t.Errorf("modelsEndpoint(%q) = %q, %q, %v; ожидалось %q, %q", tt.apiURL, endpoint, kind, err, tt.endpoint, tt.kind)
+		}
+	}
+	if _, _, err := modelsEndpoint("not a url"); err == nil {
+		t.Error("Ожидалась ошибка для некорректного адреса")
+	}
+}
+
+func TestParseModelList(t *testing.T) {
+	body := `{"data": [{"id": "openai/gpt-4o", "context_length": 128000,
+		"pricing": {"prompt": "0.0000025", "completion": "0.00001"},
+		"top_provider": {"max_completion_tokens": 16384}}]}`
+	models, err := parseModelList(modelsAPIOpenRouter, []byte(body))
+	if err != nil || len(models) != 1 {
+		t.Fatalf("Ошибка разбора списка OpenRouter: %v, %+v", err, models)
+	}
+	m := models[0]
+	if m.ID != "openai/gpt-4o" || m.ContextWindow != 128000 || m.MaxOutput != 16384 || !m.HasPricing {
+		t.Errorf("Некорректная модель: %+v", m)
+	}
+	if formatPrice(m.InputPrice, true) != "2.5" || formatPrice(m.OutputPrice, true) != "10" {
+		t.Errorf("Некорректные цены: %v, %v", m.InputPrice, m.OutputPrice)
+	}
+
+	models, err = parseModelList(modelsAPIOllama, []byte(`{"models": [{"name": "llama3.1:8b"}, {"name": "qwen2"}]}`))
+	if err != nil || len(models) != 2 || models[0].ID != "llama3.1:8b" {
+		t.Errorf("Ошибка разбора списка Ollama: %v, %+v", err, models)
+	}
+
+	body = `[{"id": "meta-llama/Llama-3.3-70B-Instruct-Turbo", "type": "chat", "context_length": 131072,
+		"pricing": {"input": 0.88, "output": 0.88}},
+		{"id": "BAAI/bge-large-en-v1.5", "type": "embedding", "context_length": 512}]`
+	models, err = parseModelList(modelsAPITogether, []byte(body))
+	if err != nil || len(models) != 1 || models[0].ContextWindow != 131072 || models[0].InputPrice != 0.88 {
+		t.Errorf("Ошибка разбора списка Together: %v, %+v", err, models)
+	}
+}
+
+func TestModelsCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer test_key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "gpt-4o-mini"}, {"id": "gpt-4o"}, {"id": "text-embedding-3-small"}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + filepath.Join(tmpDir, "in") + "\noutput_dir = " + filepath.Join(tmpDir, "out") +
+		"\n[MODEL]\nname = gpt-4o-2024\napi_url = " + server.URL + "/v1/chat/completions\napi_key = test_key\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runModelsCommand([]string{"-config", configPath, "-filter", "gpt"}, &out); err != nil {
+		t.Fatalf("models вернул ошибку: %v", err)
+	}
+	output := out.String()
+	if !strings.Contains(output, "gpt-4o-mini") || strings.Contains(output, "embedding") {
+		t.Errorf("Некорректный список моделей:\n%s", output)
+	}
+	// Размер контекста дополняется из встроенной таблицы
+	if !strings.Contains(output, "128000") {
+		t.Errorf("Нет размера контекста из таблицы:\n%s", output)
+	}
+	if !strings.Contains(output, `модель "gpt-4o-2024" из конфигурации не найдена`) {
+		t.Errorf("Нет предупреждения о неизвестной модели:\n%s", output)
+	}
+}
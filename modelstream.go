@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Максимальная длина строки потокового ответа (одно событие или строка NDJSON)
+const maxStreamLineBytes = 4 * 1024 * 1024
+
+// Построчное чтение потокового ответа с буфером под длинные события
+func newStreamScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineBytes)
+	return scanner
+}
+
+// Данные строки server-sent events; false - строка не содержит данных (event:,
+// комментарий, разделитель событий)
+func sseData(line string) (string, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+// Фрагмент потокового ответа Chat Completions
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string            `json:"content"`
+			ReasoningContent string            `json:"reasoning_content"`
+			ToolCalls        []json.RawMessage `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage json.RawMessage `json:"usage"`
+	Error json.RawMessage `json:"error"`
+}
+
+// Чтение потокового ответа Chat Completions (server-sent events): дельты первого
+// варианта собираются в сообщение, которое разбирается как обычный ответ; токены -
+// из последнего фрагмента (stream_options.include_usage)
+func readChatStream(r io.Reader) (string, Usage, bool, error) {
+	scanner := newStreamScanner(r)
+	var content, reasoning strings.Builder
+	var toolCalls []interface{}
+	var usage Usage
+	hasUsage, finished := false, false
+	for scanner.Scan() {
+		data, ok := sseData(scanner.Text())
+		if !ok || data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", Usage{}, false, errorf("ошибка при разборе фрагмента потока API: %v", err)
+		}
+		if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+			return "", Usage{}, false, errorf("ошибка в потоке API: %s", openAIErrorMessage([]byte(data)))
+		}
+		if len(chunk.Usage) > 0 {
+			var raw map[string]interface{}
+			if json.Unmarshal(chunk.Usage, &raw) == nil {
+				if u, ok := parseUsage(map[string]interface{}{"usage": raw}); ok {
+					usage, hasUsage = u, true
+				}
+			}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		content.WriteString(choice.Delta.Content)
+		reasoning.WriteString(choice.Delta.ReasoningContent)
+		for _, call := range choice.Delta.ToolCalls {
+			toolCalls = append(toolCalls, call)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			finished = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, false, errorf("ошибка при чтении потока API: %w", err)
+	}
+	if !finished {
+		return "", Usage{}, false, errorf("поток API прерван до завершения ответа")
+	}
+	message := map[string]interface{}{"content": content.String(), "reasoning_content": reasoning.String()}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+	text, err := chatMessageText(message)
+	if err != nil {
+		return "", Usage{}, false, err
+	}
+	return text, usage, hasUsage, nil
+}
+
+// Чтение потокового ответа Gemini (streamGenerateContent?alt=sse): части первого
+// кандидата из всех событий объединяются в один ответ generateContent, причина
+// завершения и токены берутся из последних событий
+func readGeminiStream(r io.Reader) (string, Usage, bool, error) {
+	scanner := newStreamScanner(r)
+	var parts []interface{}
+	merged := map[string]interface{}{}
+	candidate := map[string]interface{}{}
+	events := 0
+	for scanner.Scan() {
+		data, ok := sseData(scanner.Text())
+		if !ok || data == "" {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", Usage{}, false, errorf("ошибка при разборе события потока Gemini: %v", err)
+		}
+		if _, ok := event["error"]; ok {
+			return "", Usage{}, false, errorf("ошибка в потоке Gemini: %s", openAIErrorMessage([]byte(data)))
+		}
+		events++
+		for _, key := range []string{"usageMetadata", "promptFeedback"} {
+			if v, ok := event[key]; ok {
+				merged[key] = v
+			}
+		}
+		candidates, _ := event["candidates"].([]interface{})
+		if len(candidates) == 0 {
+			continue
+		}
+		first, _ := candidates[0].(map[string]interface{})
+		if reason, ok := first["finishReason"]; ok {
+			candidate["finishReason"] = reason
+		}
+		content, _ := first["content"].(map[string]interface{})
+		eventParts, _ := content["parts"].([]interface{})
+		parts = append(parts, eventParts...)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, false, errorf("ошибка при чтении потока Gemini: %w", err)
+	}
+	if events == 0 {
+		return "", Usage{}, false, errorf("поток Gemini не содержит событий")
+	}
+	if len(parts) > 0 || candidate["finishReason"] != nil {
+		candidate["content"] = map[string]interface{}{"parts": parts}
+		merged["candidates"] = []interface{}{candidate}
+	}
+	return geminiText(merged)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadChatStream(t *testing.T) {
+	stream := `data: {"choices": [{"delta": {"role": "assistant", "reasoning_content": "думаю"}, "finish_reason": null}]}
+
+data: {"choices": [{"delta": {"content": "Привет"}, "finish_reason": null}]}
+
+: keep-alive
+
+data: {"choices": [{"delta": {"content": ", мир"}, "finish_reason": "stop"}]}
+
+data: {"choices": [], "usage": {"prompt_tokens": 25, "completion_tokens": 15}}
+
+data: [DONE]
+`
+	text, usage, ok, err := readChatStream(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readChatStream() вернул ошибку: %v", err)
+	}
+	if text != "Привет, мир" || !ok || usage.PromptTokens != 25 || usage.CompletionTokens != 15 {
+		t.Errorf("Неожиданный результат: %q, %+v (ok=%v)", text, usage, ok)
+	}
+
+	truncated := strings.Split(stream, `data: {"choices": [{"delta": {"content": ", мир"}`)[0]
+	if _, _, _, err := readChatStream(strings.NewReader(truncated)); err == nil {
+		t.Error("Оборванный поток должен возвращать ошибку")
+	}
+	toolCall := `data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"name": "search"}}]}, "finish_reason": "tool_calls"}]}
+`
+	if _, _, _, err := readChatStream(strings.NewReader(toolCall)); err == nil || !strings.Contains(err.Error(), "вызов инструмента") {
+		t.Errorf("Ожидалась ошибка вызова инструмента, получено: %v", err)
+	}
+	failed := `data: {"error": {"message": "Overloaded"}}
+`
+	if _, _, _, err := readChatStream(strings.NewReader(failed)); err == nil || !strings.Contains(err.Error(), "Overloaded") {
+		t.Errorf("Ожидалась ошибка из потока, получено: %v", err)
+	}
+}
+
+func TestReadGeminiStream(t *testing.T) {
+	stream := `data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "план", "thought": true}, {"text": "Привет"}]}}]}
+
+data: {"candidates": [{"content": {"role": "model", "parts": [{"text": ", мир"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5}}
+`
+	text, usage, ok, err := readGeminiStream(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readGeminiStream() вернул ошибку: %v", err)
+	}
+	if text != "Привет, мир" || !ok || usage.PromptTokens != 12 || usage.CompletionTokens != 5 {
+		t.Errorf("Неожиданный результат: %q, %+v (ok=%v)", text, usage, ok)
+	}
+
+	blocked := `data: {"promptFeedback": {"blockReason": "SAFETY"}}
+`
+	if _, _, _, err := readGeminiStream(strings.NewReader(blocked)); err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("Ожидалась ошибка блокировки, получено: %v", err)
+	}
+	if _, _, _, err := readGeminiStream(strings.NewReader("")); err == nil {
+		t.Error("Пустой поток должен возвращать ошибку")
+	}
+}
+
+func TestReadOllamaStream(t *testing.T) {
+	stream := `{"message": {"role": "assistant", "content": "", "thinking": "план"}, "done": false}
+{"message": {"role": "assistant", "content": "Привет"}, "done": false}
+{"message": {"role": "assistant", "content": ", мир"}, "done": false}
+{"message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop", "prompt_eval_count": 12, "eval_count": 5}
+`
+	text, usage, ok, err := readOllamaStream(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("readOllamaStream() вернул ошибку: %v", err)
+	}
+	if text != "Привет, мир" || !ok || usage.PromptTokens != 12 || usage.CompletionTokens != 5 {
+		t.Errorf("Неожиданный результат: %q, %+v (ok=%v)", text, usage, ok)
+	}
+
+	truncated := strings.Split(stream, `{"message": {"role": "assistant", "content": ""}, "done": true`)[0]
+	if _, _, _, err := readOllamaStream(strings.NewReader(truncated)); err == nil {
+		t.Error("Поток без строки done должен возвращать ошибку")
+	}
+	if _, _, _, err := readOllamaStream(strings.NewReader(`{"error": "model not found"}`)); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Ожидалась ошибка из потока, получено: %v", err)
+	}
+}
+
+// Потоковые запросы: тело запроса и адрес по формату провайдера, ответ собирается из фрагментов
+func TestStreamRequest(t *testing.T) {
+	cases := []struct {
+		provider, path, contentType, body string
+		check                             func(t *testing.T, r *http.Request, request map[string]interface{})
+	}{
+		{
+			provider: "ollama", path: "/api/chat", contentType: "application/x-ndjson",
+			body: `{"message": {"content": "Ответ"}, "done": false}` + "\n" + `{"message": {"content": ""}, "done": true, "prompt_eval_count": 7, "eval_count": 2}` + "\n",
+			check: func(t *testing.T, r *http.Request, request map[string]interface{}) {
+				options, _ := request["options"].(map[string]interface{})
+				if request["stream"] != true || options["num_predict"] == nil {
+					t.Errorf("Запрос Ollama: %v", request)
+				}
+			},
+		},
+		{
+			provider: "openai-compatible", path: "/v1/chat/completions", contentType: "text/event-stream",
+			body: `data: {"choices": [{"delta": {"content": "Ответ"}, "finish_reason": "stop"}]}` + "\n\n" +
+				`data: {"choices": [], "usage": {"prompt_tokens": 7, "completion_tokens": 2}}` + "\n\ndata: [DONE]\n\n",
+			check: func(t *testing.T, r *http.Request, request map[string]interface{}) {
+				if options, _ := request["stream_options"].(map[string]interface{}); request["stream"] != true || options["include_usage"] != true {
+					t.Errorf("Запрос Chat Completions: %v", request)
+				}
+			},
+		},
+		{
+			provider: "gemini", path: "/models/gemini-2.0-flash:streamGenerateContent", contentType: "text/event-stream",
+			body: `data: {"candidates": [{"content": {"parts": [{"text": "Ответ"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 7, "candidatesTokenCount": 2}}` + "\n\n",
+			check: func(t *testing.T, r *http.Request, request map[string]interface{}) {
+				if r.URL.Query().Get("alt") != "sse" || r.Header.Get("x-goog-api-key") != "test-key" {
+					t.Errorf("Запрос Gemini: %s %v", r.URL, r.Header)
+				}
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.provider, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != c.path {
+					t.Errorf("Неожиданный адрес запроса: %s", r.URL)
+				}
+				var request map[string]interface{}
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &request); err != nil {
+					t.Errorf("Некорректный запрос: %v", err)
+				}
+				c.check(t, r, request)
+				w.Header().Set("Content-Type", c.contentType)
+				_, _ = w.Write([]byte(c.body))
+			}))
+			defer server.Close()
+
+			apiURL := server.URL
+			if c.provider == "openai-compatible" {
+				apiURL += "/v1"
+			}
+			config := &Config{ModelName: "gemini-2.0-flash", ModelAPIURL: apiURL, Provider: c.provider, APIKey: "test-key", MaxTokens: 100, Stream: true}
+			text, usage, err := requestModel(config, "текст", nil, NewRateLimiter(0))
+			if err != nil {
+				t.Fatalf("requestModel() вернул ошибку: %v", err)
+			}
+			if text != "Ответ" || usage.PromptTokens != 7 || usage.CompletionTokens != 2 {
+				t.Errorf("Неожиданный результат: %q, %+v", text, usage)
+			}
+		})
+	}
+}
+
+// Время ожидания потокового ответа отсчитывается от последнего фрагмента, а не от начала запроса
+func TestStreamIdleTimeout(t *testing.T) {
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher := w.(http.Flusher)
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, `{"message": {"content": "%d"}, "done": false}`+"\n", i)
+			flusher.Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+		if r.URL.Query().Get("stall") != "" {
+			select {
+			case <-stall:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		fmt.Fprintln(w, `{"message": {"content": ""}, "done": true}`)
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	config := &Config{ModelName: "llama3.2", ModelAPIURL: server.URL + "/api/chat", Provider: "ollama", MaxTokens: 100, Stream: true}
+	config.Network.Timeout = 200 * time.Millisecond
+	text, _, err := requestModel(config, "текст", nil, NewRateLimiter(0))
+	if err != nil || text != "01234" {
+		t.Fatalf("Поток дольше timeout с частыми фрагментами должен дочитываться: %q, %v", text, err)
+	}
+
+	config.ModelAPIURL = server.URL + "/api/chat?stall=1"
+	_, _, err = requestModel(config, "текст", nil, NewRateLimiter(0))
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() || errorCategory(err) != ErrorNetwork {
+		t.Errorf("Ожидалась ошибка простоя потока, получено: %v", err)
+	}
+}
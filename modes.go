@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Режимы обработки документа
+const (
+	// Обогащение всего документа (или выделенных разделов)
+	ModeFull = "full"
+	// Только оглавление документа, остальной текст не меняется
+	ModeOutline = "outline"
+	// Только незаполненные разделы (TODO, FIXME, TBD или пустые)
+	ModeSkeleton = "skeleton"
+)
+
+// Маркеры блока оглавления, блок заменяется при повторной обработке
+const (
+	OutlineStartMarker = "<!-- rich:outline -->"
+	OutlineEndMarker   = "<!-- rich:outline-end -->"
+)
+
+// Инструкции модели для режимов outline и skeleton
+const (
+	outlineInstruction  = `Write an outline of the markdown document below: a nested bullet list that follows the structure of the document, one item per section with a few words about its content, in the language of the document. Reply with the outline only.`
+	skeletonInstruction = `The markdown document below has an unfinished section "%s": it is empty or contains a placeholder such as TODO or FIXME. Write the body of this section only, in the language and style of the document and consistent with the other sections; follow the notes left in the placeholder, if any. Do not repeat the heading and do not change other sections. Reply with the section body only.`
+)
+
+// Режим документа, заданный маркером <!-- rich:mode outline -->
+var modeMarkerPattern = regexp.MustCompile(`<!--\s*rich:mode\s+(\w+)\s*-->`)
+
+// Строка-заготовка раздела: TODO, FIXME или TBD в начале строки (в том числе
+// в пункте списка или HTML комментарии)
+var placeholderPattern = regexp.MustCompile(`(?m)^\s*(?:[-*]\s+)?(?:<!--\s*)?(?:TODO|FIXME|TBD)\b`)
+
+// Проверка режима обработки
+func validateMode(mode string) error {
+	switch mode {
+	case ModeFull, ModeOutline, ModeSkeleton:
+		return nil
+	}
+	return errorf("неизвестный режим обработки %q: ожидалось full, outline или skeleton", mode)
+}
+
+// Режим обработки документа: маркер в документе имеет приоритет над конфигурацией
+func documentMode(config *Config, content string) string {
+	if m := modeMarkerPattern.FindStringSubmatch(content); m != nil {
+		if mode := strings.ToLower(m[1]); validateMode(mode) == nil {
+			return mode
+		}
+	}
+	if config.Mode == "" {
+		return ModeFull
+	}
+	return config.Mode
+}
+
+// Запрос оглавления документа и замена им прежнего блока оглавления
+func refreshOutline(config *Config, content string, limiter *RateLimiter) (string, Usage, error) {
+	outlineConfig := *config
+	outlineConfig.Prompt = outlineInstruction
+	// Примеры и правила ответа относятся к обогащению, а не к оглавлению
+	outlineConfig.Examples = nil
+	outlineConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&outlineConfig, stripOutline(content), limiter)
+	if err != nil {
+		return "", usage, err
+	}
+	outline := strings.TrimSpace(response)
+	if outline == "" {
+		return "", usage, errorf("модель вернула пустое оглавление")
+	}
+	return withOutline(content, outline), usage, nil
+}
+
+// Удаление блока оглавления вместе с маркерами
+func stripOutline(text string) string {
+	start := strings.Index(text, OutlineStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], OutlineEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(OutlineEndMarker)
+	return text[:start] + strings.TrimLeft(text[end:], "\r\n")
+}
+
+// Вставка оглавления: прежний блок заменяется на месте, новый добавляется после
+// заголовка первого уровня в начале документа (или после frontmatter и маркеров)
+func withOutline(text, outline string) string {
+	block := OutlineStartMarker + "\n" + outline + "\n" + OutlineEndMarker + "\n"
+	if start := strings.Index(text, OutlineStartMarker); start >= 0 {
+		if end := strings.Index(text[start:], OutlineEndMarker); end >= 0 {
+			end += start + len(OutlineEndMarker)
+			return text[:start] + strings.TrimSuffix(block, "\n") + text[end:]
+		}
+	}
+
+	_, body, _ := parseFrontmatter([]byte(text))
+	offset := len(text) - len(body)
+	// Пустые строки и HTML комментарии (маркеры) перед заголовком пропускаются
+	for pos := offset; pos < len(text); {
+		line, _, found := strings.Cut(text[pos:], "\n")
+		trimmed := strings.TrimSpace(line)
+		next := pos + len(line)
+		if found {
+			next++
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "<!--") && strings.HasSuffix(trimmed, "-->") {
+			pos = next
+			continue
+		}
+		if strings.HasPrefix(trimmed, "# ") {
+			if !found {
+				text += "\n"
+				next++
+			}
+			offset = next
+		}
+		break
+	}
+	after := strings.TrimLeft(text[offset:], "\r\n")
+	if after == "" {
+		return text[:offset] + "\n" + block
+	}
+	if offset == 0 {
+		return block + "\n" + after
+	}
+	return text[:offset] + "\n" + block + "\n" + after
+}
+
+// Незаполненный раздел документа
+type placeholderSection struct {
+	// Строка заголовка раздела
+	Heading string
+	// Тело раздела до следующего заголовка; пустое тело - позиция после заголовка
+	docSection
+}
+
+// Поиск незаполненных разделов: тело раздела (до следующего заголовка любого
+// уровня) пустое или содержит строку TODO, FIXME, TBD. Раздел с пустым телом и
+// вложенными разделами заготовкой не считается
+func findPlaceholderSections(content string) []placeholderSection {
+	type heading struct {
+		level     int
+		text      string
+		lineStart int
+		bodyStart int
+	}
+	var headings []heading
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		} else if !inFence && isHeadingLine(trimmed) {
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			headings = append(headings, heading{level: level, text: trimmed, lineStart: offset, bodyStart: offset + len(line)})
+		}
+		offset += len(line)
+	}
+
+	var sections []placeholderSection
+	for i, h := range headings {
+		end, nested := len(content), false
+		if i+1 < len(headings) {
+			end, nested = headings[i+1].lineStart, headings[i+1].level > h.level
+		}
+		body := content[h.bodyStart:end]
+		if sec, ok := trimSection(content, h.bodyStart, end); ok {
+			if placeholderPattern.MatchString(body) {
+				sections = append(sections, placeholderSection{Heading: h.text, docSection: sec})
+			}
+		} else if !nested {
+			sections = append(sections, placeholderSection{Heading: h.text, docSection: docSection{Start: h.bodyStart, End: h.bodyStart}})
+		}
+	}
+	return sections
+}
+
+// Заполнение разделов-заготовок; остальной документ сохраняется без изменений.
+// Каждый раздел запрашивается отдельно, модель получает документ целиком
+func fillPlaceholders(config *Config, content string, sections []placeholderSection, limiter *RateLimiter) (string, Usage, error) {
+	var total Usage
+	docSections := make([]docSection, len(sections))
+	replacements := make([]string, len(sections))
+	for i, sec := range sections {
+		skeletonConfig := *config
+		skeletonConfig.Prompt = strings.TrimSpace(config.Prompt + "\n\n" + fmt.Sprintf(skeletonInstruction, strings.TrimLeft(sec.Heading, "# ")))
+		skeletonConfig.Examples = nil
+		skeletonConfig.OutputRules = outputRules{}
+		response, usage, err := enrichContentWithUsage(&skeletonConfig, content, limiter)
+		total = total.Add(usage)
+		if err != nil {
+			return "", total, err
+		}
+		text := strings.TrimSpace(response)
+		// Повтор заголовка раздела в ответе не дублируется
+		if first, rest, _ := strings.Cut(text, "\n"); strings.EqualFold(strings.TrimSpace(first), sec.Heading) {
+			text = strings.TrimSpace(rest)
+		}
+		if text == "" {
+			return "", total, errorf("модель вернула пустой текст раздела %q", sec.Heading)
+		}
+		docSections[i] = sec.docSection
+		replacements[i] = text
+		if sec.Start == sec.End {
+			replacements[i] = emptySectionBody(content, sec.Start, text)
+		}
+	}
+	return spliceSections(content, docSections, replacements), total, nil
+}
+
+// Текст для пустого раздела с отступами от заголовка и следующего текста
+func emptySectionBody(content string, pos int, text string) string {
+	prefix := "\n"
+	if pos == 0 || content[pos-1] != '\n' {
+		prefix = "\n\n"
+	}
+	rest := content[pos:]
+	switch {
+	case strings.TrimSpace(rest) == "":
+		return prefix + text + "\n"
+	case strings.HasPrefix(strings.TrimLeft(rest, " \t\r"), "\n"):
+		return prefix + text + "\n"
+	default:
+		return prefix + text + "\n\n"
+	}
+}
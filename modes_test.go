@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentMode(t *testing.T) {
+	config := &Config{Mode: ModeSkeleton}
+	if got := documentMode(config, "# Заметка"); got != ModeSkeleton {
+		t.Errorf("режим из конфигурации: %q", got)
+	}
+	if got := documentMode(config, "<!-- rich:mode outline -->\n# Заметка"); got != ModeOutline {
+		t.Errorf("режим из маркера: %q", got)
+	}
+	if got := documentMode(&Config{}, "<!-- rich:mode unknown -->"); got != ModeFull {
+		t.Errorf("неизвестный режим в маркере: %q", got)
+	}
+	if err := validateMode("draft"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного режима")
+	}
+}
+
+func TestFindPlaceholderSections(t *testing.T) {
+	content := "# План\n\n## Введение\n\nГотовый текст.\n\n## Установка\n\nTODO: описать установку\n\n## Настройка\n\n## Детали\n\n### Порты\n\n- FIXME\n\n```\n## TODO в коде\n```\n\n## Итоги\n"
+	sections := findPlaceholderSections(content)
+	var headings []string
+	for _, s := range sections {
+		headings = append(headings, s.Heading)
+	}
+	want := "## Установка|## Настройка|### Порты|## Итоги"
+	if got := strings.Join(headings, "|"); got != want {
+		t.Errorf("незаполненные разделы: %s, ожидалось %s", got, want)
+	}
+	if got := sections[0].Text(content); got != "TODO: описать установку" {
+		t.Errorf("тело раздела-заготовки: %q", got)
+	}
+}
+
+func TestWithOutline(t *testing.T) {
+	doc := withOutline("---\ntitle: Заметка\n---\n# Заметка\n\nТекст.\n", "- Заметка")
+	want := "---\ntitle: Заметка\n---\n# Заметка\n\n" + OutlineStartMarker + "\n- Заметка\n" + OutlineEndMarker + "\n\nТекст.\n"
+	if doc != want {
+		t.Errorf("оглавление:\n%q\nожидалось\n%q", doc, want)
+	}
+	// Повторное построение заменяет блок на месте
+	doc = withOutline(doc, "- Новое")
+	if strings.Count(doc, OutlineStartMarker) != 1 || !strings.Contains(doc, "\n- Новое\n") || strings.Contains(doc, "- Заметка") {
+		t.Errorf("оглавление не заменено:\n%s", doc)
+	}
+	if got := stripOutline(doc); got != "---\ntitle: Заметка\n---\n# Заметка\n\nТекст.\n" {
+		t.Errorf("stripOutline() = %q", got)
+	}
+	if got := withOutline("Текст.", "- Текст"); got != OutlineStartMarker+"\n- Текст\n"+OutlineEndMarker+"\n\nТекст." {
+		t.Errorf("оглавление документа без заголовка: %q", got)
+	}
+}
+
+func TestOutlineAndSkeletonModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"plan.md":    "# План\n\n## Введение\n\nГотовый текст.\n\n## Установка\n\nTODO\n\n## Итоги",
+		"outline.md": "<!-- rich:mode outline -->\n# Обзор\n\nТекст обзора.\n",
+		"done.md":    "# Готово\n\nВсе разделы заполнены.\n",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		content := "Полностью переписанный документ"
+		switch {
+		case strings.Contains(string(body), `unfinished section \"Установка\"`):
+			content = "## Установка\\n\\nЗапустите установщик."
+		case strings.Contains(string(body), `unfinished section \"Итоги\"`):
+			content = "Все получилось."
+		case strings.Contains(string(body), "outline of the markdown document"):
+			content = "- Обзор"
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, Mode: ModeSkeleton,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "plan.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# План\n\n## Введение\n\nГотовый текст.\n\n## Установка\n\nЗапустите установщик.\n\n## Итоги\n\nВсе получилось.\n"
+	if string(data) != want {
+		t.Errorf("заполненные разделы:\n%q\nожидалось\n%q", data, want)
+	}
+	data, err = os.ReadFile(filepath.Join(outputDir, "outline.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# Обзор\n\n"+OutlineStartMarker+"\n- Обзор\n"+OutlineEndMarker+"\n\nТекст обзора.\n") {
+		t.Errorf("оглавление документа:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "done.md")); !os.IsNotExist(err) {
+		t.Errorf("заполненный документ не должен обрабатываться: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Перенос файла src на место dst с заменой. os.Rename атомарен только в пределах
+// одной файловой системы: если src на другом томе (каталог транзакции, корзина,
+// выходная директория маршрута на сетевом диске), содержимое копируется во временный
+// файл рядом с dst, сбрасывается на диск и переименовывается, так что читатель dst
+// по-прежнему видит старый или новый файл целиком. src после переноса удаляется.
+// При fsync переименование сбрасывается на диск вместе с директорией dst
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil && durableWrites.Load() {
+		return syncDir(filepath.Dir(dst))
+	}
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+	return copyReplace(src, dst)
+}
+
+// Сброс содержимого записанного файла на диск
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Перенос копированием между файловыми системами: копия во временном файле в
+// директории dst, fsync, переименование и fsync директории. Если dst - точка
+// монтирования файла (файл, подключенный в контейнер), заменить его нельзя, и
+// содержимое перезаписывается на месте со сбросом на диск
+func copyReplace(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "temp_*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		if !isCrossDeviceError(err) {
+			return err
+		}
+		if _, serr := in.Seek(0, io.SeekStart); serr != nil {
+			return serr
+		}
+		if err := overwriteFile(in, dst); err != nil {
+			return err
+		}
+	}
+	if err := syncDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// Перезапись содержимого файла на месте со сбросом на диск
+func overwriteFile(r io.Reader, dst string) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Перенос копированием (как между файловыми системами): dst заменяется целиком,
+// src удаляется, временных файлов не остается
+func TestCopyReplace(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	src := filepath.Join(srcDir, "temp_1.tmp")
+	dst := filepath.Join(dstDir, "note.md")
+	if err := os.WriteFile(src, []byte("# Новое содержимое"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("# Старое содержимое, длиннее нового"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyReplace(src, dst); err != nil {
+		t.Fatalf("copyReplace() вернул ошибку: %v", err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil || string(data) != "# Новое содержимое" {
+		t.Errorf("Неожиданное содержимое: %q, %v", data, err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(dst); info.Mode().Perm() != 0600 {
+			t.Errorf("Права доступа должны переноситься: %v", info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("Исходный файл должен быть удален: %v", err)
+	}
+	entries, _ := os.ReadDir(dstDir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "temp_") {
+			t.Errorf("Остался временный файл: %s", e.Name())
+		}
+	}
+
+	// Перенос в пределах файловой системы
+	if err := moveFile(dst, filepath.Join(srcDir, "moved.md")); err != nil {
+		t.Fatalf("moveFile() вернул ошибку: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(srcDir, "moved.md")); err != nil || string(data) != "# Новое содержимое" {
+		t.Errorf("Неожиданное содержимое после переноса: %q, %v", data, err)
+	}
+}
+
+func TestOverwriteFile(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "rich.cfg")
+	if err := os.WriteFile(dst, []byte("[DIRECTORIES]\ninput_dir = ./todo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := overwriteFile(strings.NewReader("[EXCLUSIONS]\n"), dst); err != nil {
+		t.Fatalf("overwriteFile() вернул ошибку: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "[EXCLUSIONS]\n" {
+		t.Errorf("Неожиданное содержимое: %q", data)
+	}
+}
+
+func TestDurableWrites(t *testing.T) {
+	t.Cleanup(func() { durableWrites.Store(false) })
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	if err := os.WriteFile(configPath, []byte("[DIRECTORIES]\ninput_dir = "+tmpDir+"\noutput_dir = "+filepath.Join(tmpDir, "out")+
+		"\n[PROCESSING]\nfsync = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Fsync || !durableWrites.Load() {
+		t.Fatal("fsync = true должен включать сброс записей на диск")
+	}
+	target := filepath.Join(tmpDir, "out", "note.md")
+	if err := safeWriteFile(target, []byte("# Результат"), 0644); err != nil {
+		t.Fatalf("safeWriteFile() вернул ошибку: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "# Результат" {
+		t.Errorf("Неожиданное содержимое: %q", data)
+	}
+	if err := addToExcludedFiles(configPath, "note.md"); err != nil {
+		t.Fatalf("addToExcludedFiles() вернул ошибку: %v", err)
+	}
+	if config, err = loadConfig(configPath); err != nil || !isExcluded(config, "note.md") {
+		t.Errorf("Файл не добавлен в список исключений: %v", err)
+	}
+}
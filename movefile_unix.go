@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Переименование невозможно: файлы на разных файловых системах (EXDEV) или цель -
+// точка монтирования файла (EBUSY)
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EBUSY)
+}
+
+// Сброс записи директории на диск: без него переименование файла может потеряться
+// при отключении питания
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+	return nil
+}
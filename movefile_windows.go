@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ERROR_NOT_SAME_DEVICE: MoveFileEx без MOVEFILE_COPY_ALLOWED не переносит файлы
+// между томами
+const errorNotSameDevice = syscall.Errno(17)
+
+// Переименование невозможно: файлы на разных томах
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
+
+// Windows не поддерживает сброс директории: записи директорий NTFS сбрасываются
+// вместе с журналом файловой системы
+func syncDir(dir string) error {
+	return nil
+}
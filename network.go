@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Значения [NETWORK] по умолчанию
+const (
+	defaultHTTPTimeout  = 60 * time.Second
+	defaultMaxIdleConns = 10
+	// Ответ API больше этого размера не читается: по неверному адресу сервер может
+	// вернуть большую страницу или файл вместо JSON
+	defaultMaxResponseBytes = 32 << 20
+)
+
+// Параметры HTTP соединений с API модели, векторных представлений и списка моделей
+type networkConfig struct {
+	// Время ожидания запроса к модели и API векторных представлений
+	Timeout time.Duration
+	// Минимальная версия TLS (tls.VersionTLS12 или tls.VersionTLS13)
+	TLSMinVersion uint16
+	// Отключение проверки сертификатов: только для тестовых стендов с самоподписанными сертификатами
+	InsecureSkipVerify bool
+	// Простаивающие соединения, которые сохраняются для повторного использования
+	MaxIdleConns int
+	// Максимальный размер тела ответа API в байтах
+	MaxResponseBytes int64
+	// Максимальный размер тела запроса к API модели в байтах (0 - без ограничения)
+	MaxRequestBytes int64
+	// Сжатие тел запросов к API модели: auto, on или off
+	CompressRequests string
+	// Повторы запросов к API модели при временных ошибках
+	Retry retryConfig
+}
+
+// Версии TLS, которые можно задать в tls_min_version
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Чтение секции [NETWORK]
+func loadNetworkConfig(section *ini.Section) (networkConfig, error) {
+	network := networkConfig{
+		Timeout:            section.Key("timeout").MustDuration(defaultHTTPTimeout),
+		InsecureSkipVerify: section.Key("insecure_skip_verify").MustBool(false),
+		MaxIdleConns:       section.Key("max_idle_conns").MustInt(defaultMaxIdleConns),
+		MaxResponseBytes:   section.Key("max_response_size").MustInt64(defaultMaxResponseBytes),
+		MaxRequestBytes:    section.Key("max_request_size").MustInt64(0),
+		CompressRequests:   strings.ToLower(section.Key("compress_requests").MustString(CompressAuto)),
+	}
+	if err := validateCompressRequests(network.CompressRequests); err != nil {
+		return network, err
+	}
+	var err error
+	if network.Retry, err = loadRetryConfig(section); err != nil {
+		return network, err
+	}
+	if network.Timeout <= 0 {
+		return network, errorf("timeout в секции [NETWORK] должен быть положительным: %s", network.Timeout)
+	}
+	if network.MaxResponseBytes <= 0 {
+		return network, errorf("max_response_size в секции [NETWORK] должен быть положительным: %d", network.MaxResponseBytes)
+	}
+	if network.MaxRequestBytes < 0 {
+		return network, errorf("max_request_size в секции [NETWORK] не может быть отрицательным: %d", network.MaxRequestBytes)
+	}
+	if network.MaxIdleConns < 0 {
+		return network, errorf("max_idle_conns в секции [NETWORK] не может быть отрицательным: %d", network.MaxIdleConns)
+	}
+	version := strings.TrimPrefix(strings.ToLower(section.Key("tls_min_version").MustString("1.2")), "tls")
+	var ok bool
+	if network.TLSMinVersion, ok = tlsVersions[version]; !ok {
+		return network, errorf("некорректное значение tls_min_version %q: ожидалось 1.2 или 1.3", version)
+	}
+	if network.InsecureSkipVerify {
+		warnf("Предупреждение: проверка TLS сертификатов выключена (insecure_skip_verify), используйте это только на тестовых стендах")
+	}
+	return network, nil
+}
+
+// Транспорты по параметрам: соединения с API переиспользуются между запросами
+var networkTransports sync.Map
+
+// HTTP транспорт с параметрами TLS и пула соединений
+func (n networkConfig) transport() *http.Transport {
+	if n.TLSMinVersion == 0 {
+		n.TLSMinVersion = tls.VersionTLS12
+	}
+	if n.MaxIdleConns == 0 {
+		n.MaxIdleConns = defaultMaxIdleConns
+	}
+	n.Timeout, n.MaxResponseBytes, n.MaxRequestBytes, n.CompressRequests, n.Retry = 0, 0, 0, "", retryConfig{}
+	if t, ok := networkTransports.Load(n); ok {
+		return t.(*http.Transport)
+	}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			MinVersion:         n.TLSMinVersion,
+			InsecureSkipVerify: n.InsecureSkipVerify,
+		},
+		MaxIdleConns:        n.MaxIdleConns,
+		MaxIdleConnsPerHost: n.MaxIdleConns,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	t, _ := networkTransports.LoadOrStore(n, transport)
+	return t.(*http.Transport)
+}
+
+// HTTP клиент с указанным временем ожидания (0 - timeout из [NETWORK])
+func (n networkConfig) client(timeout time.Duration) *http.Client {
+	if timeout == 0 {
+		timeout = n.Timeout
+	}
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &http.Client{Timeout: timeout, Transport: n.transport()}
+}
+
+// Максимальный размер ответа API (по умолчанию defaultMaxResponseBytes)
+func (n networkConfig) maxResponseBytes() int64 {
+	if n.MaxResponseBytes <= 0 {
+		return defaultMaxResponseBytes
+	}
+	return n.MaxResponseBytes
+}
+
+// Чтение распакованного тела ответа не больше limit байт; больший ответ не
+// дочитывается и возвращается ошибкой
+func readResponseBody(resp *http.Response, limit int64) ([]byte, error) {
+	if err := decodeResponseBody(resp); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return body, errorf("ошибка при чтении ответа API: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], withCategory(ErrorProvider, errorf("ответ API больше %d байт (Content-Type %s): проверьте адрес API или увеличьте max_response_size в секции [NETWORK]",
+			limit, resp.Header.Get("Content-Type")))
+	}
+	return body, nil
+}
+
+// Проверка, что ответ похож на JSON, до разбора: по неверному адресу сервер часто
+// возвращает страницу HTML со статусом 200. Ответ без Content-Type или с другим
+// типом принимается, если начинается с объекта или массива JSON
+func checkJSONResponse(resp *http.Response, body []byte) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	if trimmed := strings.TrimSpace(string(body[:min(len(body), 64)])); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return nil
+	}
+	if contentType == "" {
+		contentType = "-"
+	}
+	snippet := truncateRunes(strings.Join(strings.Fields(string(body[:min(len(body), 1024)])), " "), 200)
+	if mediaType == "text/html" || strings.HasPrefix(strings.ToLower(snippet), "<!doctype html") || strings.HasPrefix(strings.ToLower(snippet), "<html") {
+		return withCategory(ErrorProvider, errorf("API вернул страницу HTML вместо JSON (адрес API указывает не на API модели?): %s", snippet))
+	}
+	return withCategory(ErrorProvider, errorf("ответ API не в формате JSON (Content-Type %s): %s", contentType, snippet))
+}
+
+// Ошибка простоя потокового ответа: данные не приходили дольше timeout.
+// Реализует net.Error, поэтому относится к сетевым ошибкам и повторяется как они
+type idleTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *idleTimeoutError) Error() string {
+	return trf("нет данных от API дольше %v", e.timeout)
+}
+
+func (e *idleTimeoutError) Timeout() bool   { return true }
+func (e *idleTimeoutError) Temporary() bool { return true }
+
+// Таймаут простоя потокового запроса: вместо общего времени ожидания клиента
+// запрос отменяется, только если между фрагментами ответа прошло больше timeout,
+// поэтому длинный ответ не обрывается, пока модель продолжает генерацию
+type idleTimeout struct {
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// Контекст запроса, отменяемый по простою; Stop освобождает таймер
+func newIdleTimeout(timeout time.Duration) (context.Context, *idleTimeout) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &idleTimeout{timeout: timeout}
+	t.timer = time.AfterFunc(timeout, func() {
+		t.expired.Store(true)
+		cancel()
+	})
+	return ctx, t
+}
+
+func (t *idleTimeout) Stop() {
+	t.timer.Stop()
+}
+
+// Ошибка запроса или чтения с заменой отмены по простою на idleTimeoutError
+func (t *idleTimeout) Err(err error) error {
+	if err != nil && t.expired.Load() {
+		return &idleTimeoutError{timeout: t.timeout}
+	}
+	return err
+}
+
+// Тело ответа, каждый прочитанный фрагмент которого продлевает таймаут
+func (t *idleTimeout) Reader(r io.Reader) io.Reader {
+	return &idleReader{r: r, t: t}
+}
+
+type idleReader struct {
+	r io.Reader
+	t *idleTimeout
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 && !r.t.expired.Load() {
+		r.t.timer.Reset(r.t.timeout)
+	}
+	return n, r.t.Err(err)
+}
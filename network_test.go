@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadNetworkConfig(t *testing.T) {
+	cfg, err := ini.Load([]byte("[NETWORK]\ntimeout = 2m\ntls_min_version = 1.3\nmax_idle_conns = 4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := loadNetworkConfig(cfg.Section("NETWORK"))
+	if err != nil {
+		t.Fatalf("loadNetworkConfig() вернул ошибку: %v", err)
+	}
+	if network.Timeout != 2*time.Minute || network.TLSMinVersion != tls.VersionTLS13 || network.MaxIdleConns != 4 || network.InsecureSkipVerify {
+		t.Errorf("некорректные параметры: %+v", network)
+	}
+	if network.transport() != network.transport() {
+		t.Error("транспорт с одинаковыми параметрами должен переиспользоваться")
+	}
+	for _, bad := range []string{"timeout = 0s", "tls_min_version = 1.0", "max_idle_conns = -1", "max_response_size = 0", "max_request_size = -1"} {
+		cfg, _ := ini.Load([]byte("[NETWORK]\n" + bad + "\n"))
+		if _, err := loadNetworkConfig(cfg.Section("NETWORK")); err == nil {
+			t.Errorf("ожидалась ошибка для %q", bad)
+		}
+	}
+}
+
+func TestNetworkInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := (networkConfig{}).client(5 * time.Second).Get(server.URL); err == nil {
+		t.Error("самоподписанный сертификат не должен приниматься по умолчанию")
+	}
+	resp, err := (networkConfig{InsecureSkipVerify: true}).client(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("с insecure_skip_verify запрос должен выполняться: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestModelResponseGuard(t *testing.T) {
+	var body, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	config := &Config{ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Network: networkConfig{MaxResponseBytes: 1024}}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"страница HTML", "text/html; charset=utf-8", "<!DOCTYPE html><html><title>Login</title></html>", "страницу HTML"},
+		{"не JSON", "text/plain", "Service Unavailable", "не в формате JSON (Content-Type text/plain)"},
+		{"слишком большой ответ", "application/json", `{"choices": "` + strings.Repeat("x", 2048) + `"}`, "больше 1024 байт"},
+		{"JSON без Content-Type", "", `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType = tt.body, tt.contentType
+			text, _, err := requestModel(config, "note", nil, NewRateLimiter(0))
+			if tt.want == "" {
+				if err != nil || text != "ok" {
+					t.Errorf("requestModel() = %q, %v", text, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) || errorCategory(err) != ErrorProvider {
+				t.Errorf("requestModel() вернул ошибку %v (%s), ожидалось %q", err, errorCategory(err), tt.want)
+			}
+		})
+	}
+}
+
+func TestModelRequestSizeGuard(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+	config := &Config{ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		MaxTokens: 100, Network: networkConfig{MaxRequestBytes: 2048}}
+
+	// Запрос больше max_request_size не отправляется
+	_, _, err := requestModel(config, strings.Repeat("x", 4096), nil, NewRateLimiter(0))
+	if err == nil || !strings.Contains(err.Error(), "max_request_size") || errorCategory(err) != ErrorValidation {
+		t.Errorf("requestModel() вернул ошибку %v (%s), ожидалась ошибка max_request_size", err, errorCategory(err))
+	}
+	if requests != 0 {
+		t.Errorf("запрос больше ограничения не должен отправляться, отправлено %d", requests)
+	}
+
+	// Запрос в пределах ограничения отправляется
+	if text, _, err := requestModel(config, "note", nil, NewRateLimiter(0)); err != nil || text != "ok" {
+		t.Errorf("requestModel() = %q, %v", text, err)
+	}
+}
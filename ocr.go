@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Способы распознавания текста на изображениях
+const (
+	OCROff = "off"
+	// Изображение отправляется модели с поддержкой изображений
+	OCRVision = "vision"
+	// Локальная программа tesseract
+	OCRTesseract = "tesseract"
+)
+
+// Инструкция модели для распознавания текста на изображении
+const ocrInstruction = `Transcribe all text visible in the attached image (a photo of a whiteboard, a handwritten note or a printed page). Preserve the reading order, lists and headings as markdown. Do not describe the image, do not add anything that is not written in it. If there is no readable text, reply with an empty message.`
+
+// Типы изображений по расширениям
+var ocrMediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// Встроенные изображения: ![подпись](путь "заголовок"), ![[файл|размер]] и <img src="путь">
+var (
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	wikiImagePattern     = regexp.MustCompile(`!\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]`)
+	htmlImagePattern     = regexp.MustCompile(`(?i)<img\s[^>]*src=["']([^"']+)["'][^>]*>`)
+	htmlCommentPattern   = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// Распознавание текста из секции [OCR]
+type OCRConfig struct {
+	// off, vision или tesseract; маршрут может задать свой способ ключом ocr
+	Engine string
+	// Модель для распознавания (vision); "" - модель файла
+	Model string
+	// Программа tesseract и языки распознавания (tesseract -l)
+	Tesseract string
+	Languages string
+	// Максимальный размер изображения
+	MaxImageBytes int
+	// Время ожидания tesseract
+	Timeout time.Duration
+}
+
+// Чтение секции [OCR]
+func loadOCRConfig(section *ini.Section) (OCRConfig, error) {
+	ocr := OCRConfig{
+		Engine:        strings.ToLower(section.Key("engine").MustString(OCROff)),
+		Model:         section.Key("model").String(),
+		Tesseract:     section.Key("tesseract").MustString("tesseract"),
+		Languages:     section.Key("languages").MustString("rus+eng"),
+		MaxImageBytes: section.Key("max_image_bytes").MustInt(5 << 20),
+		Timeout:       section.Key("timeout").MustDuration(2 * time.Minute),
+	}
+	if err := validateOCREngine(ocr.Engine); err != nil {
+		return ocr, err
+	}
+	if ocr.MaxImageBytes <= 0 {
+		return ocr, errorf("max_image_bytes в секции [OCR] должен быть положительным: %d", ocr.MaxImageBytes)
+	}
+	return ocr, nil
+}
+
+// Проверка названия способа распознавания
+func validateOCREngine(engine string) error {
+	switch engine {
+	case OCROff, OCRVision, OCRTesseract:
+		return nil
+	}
+	return errorf("неизвестный способ распознавания %q: ожидалось off, vision или tesseract", engine)
+}
+
+// Распознавание включено в конфигурации или хотя бы в одном маршруте
+func (c *Config) ocrConfigured() bool {
+	if c.OCR.Engine != OCROff {
+		return true
+	}
+	for _, r := range c.Routes {
+		if r.OCR != "" && r.OCR != OCROff {
+			return true
+		}
+	}
+	return false
+}
+
+// Изображения заметки, которая состоит только из встроенных изображений (кроме
+// них допускаются frontmatter, заголовки и HTML комментарии); nil - в заметке есть текст
+func imageOnlyNote(content []byte) []string {
+	_, body, _ := parseFrontmatter(content)
+	text := htmlCommentPattern.ReplaceAllString(string(body), "")
+	var images []string
+	for _, re := range []*regexp.Regexp{markdownImagePattern, wikiImagePattern, htmlImagePattern} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if _, ok := ocrMediaTypes[strings.ToLower(filepath.Ext(m[1]))]; ok {
+				images = append(images, strings.TrimSpace(m[1]))
+			}
+		}
+		text = re.ReplaceAllString(text, "")
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !isHeadingLine(line) {
+			return nil
+		}
+	}
+	return images
+}
+
+// Путь изображения во входной директории: относительно заметки, затем от корня
+// входной директории; внешние адреса и пути за пределами директории не читаются
+func resolveNoteImage(inputDir, notePath, ref string) (string, bool) {
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "data:") {
+		return "", false
+	}
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	root, err := filepath.Abs(inputDir)
+	if err != nil {
+		return "", false
+	}
+	ref = filepath.FromSlash(ref)
+	for _, candidate := range []string{filepath.Join(filepath.Dir(notePath), ref), filepath.Join(root, ref)} {
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, abs); err != nil || !isRelPathSafe(rel) {
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// Изображение, отправляемое вместе с текстом запроса
+type imageAttachment struct {
+	MediaType string
+	Data      []byte
+}
+
+// Сообщения Chat Completions с изображением в последнем сообщении пользователя
+// (без изображения сообщения не меняются)
+func chatImageMessages(messages []map[string]string, image *imageAttachment) interface{} {
+	return messagesWithImage(messages, image, func(text, encoded string) interface{} {
+		return []map[string]interface{}{
+			{"type": "text", "text": text},
+			{"type": "image_url", "image_url": map[string]string{"url": "data:" + image.MediaType + ";base64," + encoded}},
+		}
+	})
+}
+
+// Сообщения Anthropic Messages API с изображением в последнем сообщении пользователя
+func anthropicImageMessages(messages []map[string]string, image *imageAttachment) interface{} {
+	return messagesWithImage(messages, image, func(text, encoded string) interface{} {
+		return []map[string]interface{}{
+			{"type": "image", "source": map[string]string{"type": "base64", "media_type": image.MediaType, "data": encoded}},
+			{"type": "text", "text": text},
+		}
+	})
+}
+
+// Сообщения, в которых содержимое последнего заменено частями с текстом
+// и изображением в base64
+func messagesWithImage(messages []map[string]string, image *imageAttachment, parts func(text, encoded string) interface{}) interface{} {
+	if image == nil {
+		return messages
+	}
+	encoded := base64.StdEncoding.EncodeToString(image.Data)
+	result := make([]map[string]interface{}, 0, len(messages))
+	for i, m := range messages {
+		var content interface{} = m["content"]
+		if i == len(messages)-1 {
+			content = parts(m["content"], encoded)
+		}
+		result = append(result, map[string]interface{}{"role": m["role"], "content": content})
+	}
+	return result
+}
+
+// Запрос generateContent с изображением в последнем сообщении
+func withGeminiImage(request map[string]interface{}, image *imageAttachment) map[string]interface{} {
+	if image == nil {
+		return request
+	}
+	contents, _ := request["contents"].([]map[string]interface{})
+	if len(contents) == 0 {
+		return request
+	}
+	last := contents[len(contents)-1]
+	var parts []interface{}
+	if textParts, ok := last["parts"].([]map[string]string); ok {
+		for _, p := range textParts {
+			parts = append(parts, p)
+		}
+	}
+	last["parts"] = append(parts, map[string]interface{}{
+		"inline_data": map[string]string{"mime_type": image.MediaType, "data": base64.StdEncoding.EncodeToString(image.Data)},
+	})
+	return request
+}
+
+// Распознавание текста изображений заметки; изображения вне входной директории
+// и недоступные файлы пропускаются с предупреждением
+func recognizeNoteImages(config *Config, notePath string, images []string, limiter *RateLimiter) (map[string]string, Usage, error) {
+	texts := make(map[string]string, len(images))
+	var total Usage
+	for _, image := range images {
+		if _, done := texts[image]; done {
+			continue
+		}
+		path, ok := resolveNoteImage(config.InputDir, notePath, image)
+		if !ok {
+			warnf("Предупреждение: изображение %s заметки %s не найдено во входной директории", image, notePath)
+			texts[image] = ""
+			continue
+		}
+		text, usage, err := recognizeImage(config, path, limiter)
+		total = total.Add(usage)
+		if err != nil {
+			return nil, total, err
+		}
+		texts[image] = text
+	}
+	return texts, total, nil
+}
+
+// Распознавание текста изображения выбранным способом
+func recognizeImage(config *Config, path string, limiter *RateLimiter) (string, Usage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", Usage{}, errorf("ошибка при чтении изображения: %v", err)
+	}
+	if len(data) > config.OCR.MaxImageBytes {
+		return "", Usage{}, errorf("изображение %s больше %d байт", filepath.Base(path), config.OCR.MaxImageBytes)
+	}
+	if config.OCR.Engine == OCRTesseract {
+		text, err := runTesseract(config.OCR, path)
+		return text, Usage{}, err
+	}
+
+	ocrConfig := *config
+	ocrConfig.Prompt = ocrInstruction
+	// Примеры и правила ответа относятся к обогащению, а не к распознаванию
+	ocrConfig.Examples = nil
+	ocrConfig.OutputRules = outputRules{}
+	if config.OCR.Model != "" {
+		ocrConfig.ModelName = config.OCR.Model
+	}
+	image := &imageAttachment{MediaType: ocrMediaTypes[strings.ToLower(filepath.Ext(path))], Data: data}
+	text, usage, err := requestModel(&ocrConfig, "", image, limiter)
+	if err != nil && errorCategory(err) == ErrorInternal {
+		err = withCategory(ErrorProvider, err)
+	}
+	return strings.TrimSpace(text), usage, err
+}
+
+// Распознавание программой tesseract (текст выводится в стандартный вывод)
+func runTesseract(ocr OCRConfig, path string) (string, error) {
+	if _, err := exec.LookPath(ocr.Tesseract); err != nil {
+		return "", withCategory(ErrorConfig, errorf("программа %s не найдена: установите tesseract или укажите путь ключом tesseract секции [OCR]", ocr.Tesseract))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ocr.Timeout)
+	defer cancel()
+	args := []string{path, "stdout"}
+	if ocr.Languages != "" {
+		args = append(args, "-l", ocr.Languages)
+	}
+	cmd := exec.CommandContext(ctx, ocr.Tesseract, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", errorf("tesseract не завершился за %v", ocr.Timeout)
+		}
+		return "", errorf("ошибка tesseract: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Заметка с распознанным текстом изображений для обогащения
+func withRecognizedText(note string, texts map[string]string, images []string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(note, "\n"))
+	for _, image := range images {
+		if text := texts[image]; text != "" {
+			fmt.Fprintf(&b, "\n\n<!-- text recognized in %s -->\n%s", image, text)
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
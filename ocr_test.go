@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestImageOnlyNote(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"изображение markdown", "---\ntags: [доска]\n---\n# Планерка\n\n![доска](img/board%201.jpg \"фото\")\n", []string{"img/board%201.jpg"}},
+		{"вики-ссылка и html", "<!-- снято 12.03 -->\n![[scan.PNG|600]]\n\n<img src=\"photo.webp\" width=\"300\">\n", []string{"scan.PNG", "photo.webp"}},
+		{"заметка с текстом", "![доска](board.jpg)\n\nОбсудили релиз.\n", nil},
+		{"не изображение", "![схема](diagram.svg)\n", nil},
+		{"без изображений", "# Заголовок\n", nil},
+	}
+	for _, tt := range tests {
+		if got := imageOnlyNote([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: imageOnlyNote() = %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveNoteImage(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(filepath.Join(inputDir, "notes", "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(inputDir, "notes", "img", "a b.png"), filepath.Join(inputDir, "shared.png"), filepath.Join(tmpDir, "secret.png")} {
+		if err := os.WriteFile(name, []byte("png"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	note := filepath.Join(inputDir, "notes", "board.md")
+
+	if path, ok := resolveNoteImage(inputDir, note, "img/a%20b.png"); !ok || filepath.Base(path) != "a b.png" {
+		t.Errorf("изображение рядом с заметкой: %q, %v", path, ok)
+	}
+	if path, ok := resolveNoteImage(inputDir, note, "shared.png"); !ok || filepath.Base(path) != "shared.png" {
+		t.Errorf("изображение от корня входной директории: %q, %v", path, ok)
+	}
+	for _, ref := range []string{"../../secret.png", "https://example.com/a.png", "missing.png"} {
+		if path, ok := resolveNoteImage(inputDir, note, ref); ok {
+			t.Errorf("изображение %s не должно читаться: %q", ref, path)
+		}
+	}
+}
+
+// Входная директория с заметкой-фотографией доски
+func writeImageNote(t *testing.T, inputDir, name string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(inputDir, "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "img", "board.png"), []byte("\x89PNG доска"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Доска\n\n![](img/board.png)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOCRVision(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeImageNote(t, inputDir, "board.md")
+
+	var ocrRequests, enrichRequests atomic.Int32
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("\x89PNG доска"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "image_url") {
+			ocrRequests.Add(1)
+			if !strings.Contains(string(body), dataURL) || !strings.Contains(string(body), `"model":"vision-model"`) {
+				t.Errorf("запрос распознавания без изображения или модели: %s", body)
+			}
+			_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Релиз 2.0: сроки и ответственные"}}]}`))
+			return
+		}
+		enrichRequests.Add(1)
+		if !strings.Contains(string(body), "Релиз 2.0: сроки и ответственные") {
+			t.Errorf("распознанный текст не отправлен на обогащение: %s", body)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Доска\n\nРелиз 2.0: план работ"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(tmpDir, "report.json")
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, ReportFile: reportPath,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		OCR: OCRConfig{Engine: OCRVision, Model: "vision-model", MaxImageBytes: 1 << 20}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if ocrRequests.Load() != 1 || enrichRequests.Load() != 1 {
+		t.Errorf("запросов распознавания %d, обогащения %d, ожидалось по одному", ocrRequests.Load(), enrichRequests.Load())
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "board.md"))
+	if err != nil {
+		t.Fatalf("результат не создан: %v", err)
+	}
+	if !strings.Contains(string(data), "Релиз 2.0: план работ") || !strings.Contains(string(data), "```old\n# Доска\n\n![](img/board.png)\n\n```") {
+		t.Errorf("результат заметки-изображения:\n%s", data)
+	}
+	report, _ := os.ReadFile(reportPath)
+	if !strings.Contains(string(report), `"ocr": [`) {
+		t.Errorf("в отчете нет распознанных изображений:\n%s", report)
+	}
+}
+
+func TestOCRTesseractRoute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("заменитель tesseract - сценарий sh")
+	}
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	writeImageNote(t, filepath.Join(inputDir, "boards"), "standup.md")
+	writeImageNote(t, filepath.Join(inputDir, "other"), "photo.md")
+
+	tesseract := filepath.Join(tmpDir, "tesseract")
+	script := "#!/bin/sh\n[ \"$2\" = stdout ] && [ \"$4\" = rus+eng ] || exit 1\necho 'Стендап: блокеры сборки'\n"
+	if err := os.WriteFile(tesseract, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "image_url") || !strings.Contains(string(body), "Стендап: блокеры сборки") {
+			t.Errorf("неожиданный запрос: %s", body)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Стендап\n\nБлокеры сборки"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg, err := ini.Load([]byte("[ROUTES]\nboards = boards/**\n[ROUTE.boards]\nocr = tesseract\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadRoutes(cfg, tmpDir)
+	if err != nil {
+		t.Fatalf("loadRoutes() вернул ошибку: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, Routes: routes, MinWords: 5,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		OCR: OCRConfig{Engine: OCROff, Tesseract: tesseract, Languages: "rus+eng", MaxImageBytes: 1 << 20, Timeout: time.Minute}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("запросов к модели: %d, ожидался 1", requests.Load())
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "boards", "standup.md")); err != nil {
+		t.Errorf("заметка маршрута с распознаванием не обогащена: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "other", "photo.md")); err == nil {
+		t.Error("заметка без распознавания должна пропускаться как слишком маленькая")
+	}
+
+	bad, _ := ini.Load([]byte("[ROUTES]\nboards = boards/**\n[ROUTE.boards]\nocr = scanner\n"))
+	if _, err := loadRoutes(bad, tmpDir); err == nil {
+		t.Error("ожидалась ошибка для неизвестного способа распознавания")
+	}
+}
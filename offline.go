@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Значения по умолчанию для работы без соединения с API
+const (
+	defaultOfflineAfter = 3
+	defaultOfflineRetry = time.Minute
+	// Время ожидания проверки доступности API
+	offlineProbeTimeout = 10 * time.Second
+)
+
+// Очередь файлов, отложенных без соединения с API, в каталоге состояния
+const offlineQueueFile = "offline-queue.json"
+
+// Параметры работы без соединения с API ([NETWORK])
+type offlineConfig struct {
+	// Подряд идущие ошибки соединения, после которых обработка останавливается (0 - выключено)
+	After int
+	// Пауза службы перед повторной попыткой после остановки
+	Retry time.Duration
+}
+
+// Чтение параметров offline_after и offline_retry секции [NETWORK]
+func loadOfflineConfig(section *ini.Section) (offlineConfig, error) {
+	offline := offlineConfig{
+		After: section.Key("offline_after").MustInt(defaultOfflineAfter),
+		Retry: section.Key("offline_retry").MustDuration(defaultOfflineRetry),
+	}
+	if offline.After < 0 {
+		return offline, errorf("offline_after в секции [NETWORK] не может быть отрицательным: %d", offline.After)
+	}
+	if offline.Retry <= 0 {
+		return offline, errorf("offline_retry в секции [NETWORK] должен быть положительным: %s", offline.Retry)
+	}
+	return offline, nil
+}
+
+// Сохраненная очередь отложенных файлов
+type offlineQueue struct {
+	Since time.Time `json:"since"`
+	// Последняя ошибка соединения
+	Reason string   `json:"reason"`
+	Files  []string `json:"files"`
+}
+
+// Путь очереди отложенных файлов
+func offlineQueuePath(stateDir string) string {
+	return filepath.Join(stateDir, offlineQueueFile)
+}
+
+// Чтение очереди; без каталога состояния или файла очереди возвращается пустая очередь
+func loadOfflineQueue(stateDir string) (offlineQueue, error) {
+	var q offlineQueue
+	if stateDir == "" {
+		return q, nil
+	}
+	data, err := os.ReadFile(offlineQueuePath(stateDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return q, errorf("не удалось прочитать очередь отложенных файлов: %v", err)
+	}
+	if err := json.Unmarshal(data, &q); err != nil {
+		return q, errorf("поврежден файл очереди отложенных файлов %s: %v", offlineQueuePath(stateDir), err)
+	}
+	return q, nil
+}
+
+// Сохранение очереди; пустая очередь удаляет файл
+func saveOfflineQueue(stateDir string, q offlineQueue) error {
+	path := offlineQueuePath(stateDir)
+	if len(q.Files) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errorf("не удалось удалить очередь отложенных файлов: %v", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return errorf("не удалось сохранить очередь отложенных файлов: %v", err)
+	}
+	if err := safeWriteFile(path, data, 0644); err != nil {
+		return errorf("не удалось сохранить очередь отложенных файлов: %v", err)
+	}
+	return nil
+}
+
+// Остановка обработки без соединения с API; категория ошибки определяется по
+// последней ошибке соединения
+type offlineError struct {
+	Queued int
+	Err    error
+}
+
+func (e *offlineError) Error() string {
+	return trf("нет соединения с API (%v): отложено файлов %d, они будут обработаны первыми при следующем запуске", e.Err, e.Queued)
+}
+
+func (e *offlineError) Unwrap() error {
+	return e.Err
+}
+
+// Проверка доступности API модели: любой ответ сервера, даже с ошибкой, означает,
+// что соединение есть
+func apiReachable(config *Config) error {
+	apiURL := config.ModelAPIURL
+	if p := config.provider(); apiURL == "" && p != nil {
+		apiURL = p.DefaultURL
+	}
+	req, err := http.NewRequest(http.MethodHead, apiURL, nil)
+	if err != nil {
+		return errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	setUserAgent(req)
+	resp, err := config.Network.client(min(config.Network.Timeout, offlineProbeTimeout)).Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Учет ошибок соединения за запуск: отложенные файлы и признак остановки
+type offlineTracker struct {
+	after int
+	// Подряд идущие ошибки соединения и последняя из них
+	failures int
+	lastErr  error
+	// Отложенные файлы в порядке обработки
+	deferred []string
+	seen     map[string]bool
+	// Файлы, обработанные в этом запуске
+	attempted map[string]bool
+}
+
+func newOfflineTracker(config *Config) *offlineTracker {
+	return &offlineTracker{after: config.Offline.After, seen: make(map[string]bool), attempted: make(map[string]bool)}
+}
+
+// Учет результата файла; true - ошибки соединения идут подряд offline_after раз
+// и обработку нужно остановить. Пропущенные файлы не обращаются к API и счетчик не меняют
+func (t *offlineTracker) Record(key, status string, err error) bool {
+	key = normalizeRelPath(key)
+	t.attempted[key] = true
+	if err != nil && errorCategory(err) == ErrorNetwork {
+		t.failures++
+		t.lastErr = err
+		t.Defer(key)
+		return t.after > 0 && t.failures >= t.after
+	}
+	if err != nil || !isSkippedStatus(status) {
+		t.failures = 0
+	}
+	return false
+}
+
+// Добавление файла в очередь отложенных
+func (t *offlineTracker) Defer(key string) {
+	key = normalizeRelPath(key)
+	if !t.seen[key] {
+		t.seen[key] = true
+		t.deferred = append(t.deferred, key)
+	}
+}
+
+// Очередь после остановки: отложенные в этом запуске файлы и файлы прежней
+// очереди, до которых обработка не дошла
+func (t *offlineTracker) Queue(prev offlineQueue) offlineQueue {
+	q := offlineQueue{Since: prev.Since, Files: t.deferred}
+	if q.Since.IsZero() {
+		q.Since = time.Now()
+	}
+	if t.lastErr != nil {
+		q.Reason = t.lastErr.Error()
+	}
+	for _, key := range prev.Files {
+		if !t.attempted[key] && !t.seen[key] {
+			t.seen[key] = true
+			q.Files = append(q.Files, key)
+		}
+	}
+	return q
+}
+
+// Файлы из очереди отложенных переносятся в начало списка, порядок остальных
+// не меняется
+func queuedFirst(candidates []candidate, root string, queued map[string]bool) {
+	if len(queued) == 0 {
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return queued[normalizeRelPath(rootKey(root, candidates[i].RelPath))] && !queued[normalizeRelPath(rootKey(root, candidates[j].RelPath))]
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOfflineQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md", "d.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Адрес закрытого сервера: соединение отклоняется
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	run := func(apiURL string) error {
+		t.Helper()
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + apiURL +
+			"/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + stateDir +
+			"\n[NETWORK]\noffline_after = 2\n[EXCLUSIONS]\nexcluded_files =\n"
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		return processDirectory(config, configPath)
+	}
+
+	// После двух ошибок соединения подряд остальные файлы откладываются
+	var oe *offlineError
+	if err := run(down.URL); !errors.As(err, &oe) || oe.Queued != 4 || exitCode(err) != exitCodes[ErrorNetwork] {
+		t.Fatalf("processDirectory() без соединения вернул %v", err)
+	}
+	queued, err := loadOfflineQueue(stateDir)
+	if err != nil || strings.Join(queued.Files, ",") != "a.md,b.md,c.md,d.md" {
+		t.Fatalf("очередь отложенных файлов: %+v, %v", queued, err)
+	}
+	runs, _ := listRuns(stateDir)
+
+	// Пока API недоступен, запуск не начинается
+	if err := run(down.URL); !errors.As(err, &oe) || oe.Queued != 4 {
+		t.Fatalf("повторный запуск без соединения вернул %v", err)
+	}
+	if again, _ := listRuns(stateDir); len(again) != len(runs) {
+		t.Errorf("запуск без соединения создал журнал: %d, ожидалось %d", len(again), len(runs))
+	}
+
+	// Новый файл обрабатывается после отложенных
+	if err := os.WriteFile(filepath.Join(inputDir, "0.md"), []byte("# Заметка 0.md"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var order []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			for _, name := range []string{"0.md", "a.md", "b.md", "c.md", "d.md"} {
+				if strings.Contains(string(body), "Заметка "+name) {
+					order = append(order, name)
+				}
+			}
+			mu.Unlock()
+		}
+		resp, _ := json.Marshal(map[string]interface{}{"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "Обогащено"}}}})
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+	if err := run(server.URL); err != nil {
+		t.Fatalf("processDirectory() после восстановления соединения: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "a.md,b.md,c.md,d.md,0.md" {
+		t.Errorf("порядок обработки: %s", got)
+	}
+	if _, err := os.Stat(offlineQueuePath(stateDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("очередь не удалена после обработки: %v", err)
+	}
+}
+
+func TestOfflineTracker(t *testing.T) {
+	tracker := newOfflineTracker(&Config{Offline: offlineConfig{After: 2}})
+	netErr := withCategory(ErrorNetwork, errors.New("connection refused"))
+	if tracker.Record("a.md", StatusFailed, netErr) {
+		t.Error("остановка после первой ошибки соединения")
+	}
+	// Пропущенный файл не прерывает серию ошибок, обработанный - прерывает
+	tracker.Record("small.md", StatusSkippedTooSmall, nil)
+	if !tracker.Record("b.md", StatusFailed, netErr) {
+		t.Error("нет остановки после двух ошибок соединения подряд")
+	}
+	tracker.Record("c.md", StatusEnriched, nil)
+	if tracker.Record("d.md", StatusFailed, netErr) {
+		t.Error("успешный файл не сбросил счетчик ошибок")
+	}
+
+	q := tracker.Queue(offlineQueue{Files: []string{"a.md", "old.md", "c.md"}})
+	if got := strings.Join(q.Files, ","); got != "a.md,b.md,d.md,old.md" {
+		t.Errorf("Queue() = %s", got)
+	}
+}
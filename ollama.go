@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Тело запроса /api/chat Ollama: параметры генерации передаются в options,
+// stream задается явно (по умолчанию Ollama отвечает потоком)
+func ollamaRequest(model string, messages []map[string]interface{}, params map[string]interface{}, stream bool) map[string]interface{} {
+	requestData := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   stream,
+	}
+	if len(params) > 0 {
+		requestData["options"] = params
+	}
+	return requestData
+}
+
+// Сообщения диалога для Ollama; изображение передается в поле images последнего
+// сообщения пользователя в base64
+func ollamaMessages(messages []map[string]string, image *imageAttachment) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(messages))
+	for i, m := range messages {
+		result[i] = map[string]interface{}{"role": m["role"], "content": m["content"]}
+	}
+	if image != nil {
+		for i := len(result) - 1; i >= 0; i-- {
+			if result[i]["role"] == "user" {
+				result[i]["images"] = []string{base64.StdEncoding.EncodeToString(image.Data)}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Текст и токены ответа /api/chat: рассуждения модели (thinking) в результат не
+// попадают, токены - из prompt_eval_count и eval_count
+func ollamaText(responseData map[string]interface{}) (string, Usage, bool, error) {
+	if msg, ok := responseData["error"].(string); ok {
+		return "", Usage{}, false, errorf("ошибка Ollama: %s", msg)
+	}
+	message, ok := responseData["message"].(map[string]interface{})
+	if !ok {
+		return "", Usage{}, false, errorf("некорректный формат ответа Ollama: отсутствует поле message")
+	}
+	chat := map[string]interface{}{"content": message["content"], "tool_calls": message["tool_calls"]}
+	if thinking, ok := message["thinking"].(string); ok {
+		chat["reasoning_content"] = thinking
+	}
+	text, err := chatMessageText(chat)
+	if err != nil {
+		return "", Usage{}, false, err
+	}
+	usage, ok := ollamaUsage(responseData)
+	return text, usage, ok, nil
+}
+
+// Токены ответа Ollama; нулевые счетчики считаются отсутствующими
+func ollamaUsage(responseData map[string]interface{}) (Usage, bool) {
+	prompt, _ := responseData["prompt_eval_count"].(float64)
+	completion, _ := responseData["eval_count"].(float64)
+	if prompt == 0 && completion == 0 {
+		return Usage{}, false
+	}
+	return Usage{PromptTokens: int(prompt), CompletionTokens: int(completion)}, true
+}
+
+// Строка потокового ответа Ollama (NDJSON)
+type ollamaStreamLine struct {
+	Message struct {
+		Content   string            `json:"content"`
+		Thinking  string            `json:"thinking"`
+		ToolCalls []json.RawMessage `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// Чтение потокового ответа Ollama: по объекту JSON на строку до строки с done,
+// в которой приходят счетчики токенов
+func readOllamaStream(r io.Reader) (string, Usage, bool, error) {
+	scanner := newStreamScanner(r)
+	var content, thinking strings.Builder
+	var toolCalls []interface{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var chunk ollamaStreamLine
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return "", Usage{}, false, errorf("ошибка при разборе строки потока Ollama: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", Usage{}, false, errorf("ошибка Ollama: %s", chunk.Error)
+		}
+		content.WriteString(chunk.Message.Content)
+		thinking.WriteString(chunk.Message.Thinking)
+		for _, call := range chunk.Message.ToolCalls {
+			toolCalls = append(toolCalls, call)
+		}
+		if !chunk.Done {
+			continue
+		}
+		message := map[string]interface{}{"content": content.String(), "reasoning_content": thinking.String()}
+		if len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+		text, err := chatMessageText(message)
+		if err != nil {
+			return "", Usage{}, false, err
+		}
+		usage := Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+		return text, usage, usage.PromptTokens > 0 || usage.CompletionTokens > 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, false, errorf("ошибка при чтении потока Ollama: %w", err)
+	}
+	return "", Usage{}, false, errorf("поток Ollama прерван до строки с done")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Поле frontmatter результата с именем оператора запуска, который его получил
+const OperatorKey = "rich_operator"
+
+// Значение operator секции [STATE]: оператор - пользователь системы
+const OperatorAuto = "auto"
+
+// Имя оператора из секции [STATE] (или RICH_STATE_OPERATOR): auto - пользователь
+// системы, пусто - оператор не записывается
+func resolveOperator(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, OperatorAuto) {
+		return defaultReviewer()
+	}
+	return value
+}
+
+// Оператор, получивший файл с новым обогащением на проверке ("" - файла нет,
+// он получен без оператора или не читается как markdown)
+func conflictOperator(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	fm, _, ok := parseFrontmatter(data)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(fm[OperatorKey])
+}
+
+// Файл .new с новым обогащением, полученным другим оператором и еще не слитым с
+// ручными правками: повторный запуск не перезаписывает его, иначе чужая проверка
+// молча начнется заново. Возвращает путь файла .new и его оператора
+func (s *session) foreignConflict(config *Config, key, outputPath string) (string, string, bool) {
+	if config.OnConflict == OnConflictOverwrite || !config.Sinks.WritesLocal() || !s.outputConflict(key, outputPath) {
+		return "", "", false
+	}
+	path := outputPath + ConflictSuffix
+	owner := conflictOperator(path)
+	if owner == "" || owner == config.Operator {
+		return "", "", false
+	}
+	return path, owner, true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveOperator(t *testing.T) {
+	t.Setenv("USER", "anna")
+	tests := map[string]string{"": "", " boris ": "boris", "auto": "anna", "AUTO": "anna"}
+	for value, want := range tests {
+		if got := resolveOperator(value); got != want {
+			t.Errorf("resolveOperator(%q) = %q, ожидалось %q", value, got, want)
+		}
+	}
+}
+
+func TestOperatorConflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Заметка"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, requests := "Первое обогащение", 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": map[string]string{
+			"role": "assistant", "content": answer}}}})
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100,
+		ReportFile: filepath.Join(tmpDir, "report.json")}
+	run := func(operator string) *runReport {
+		t.Helper()
+		config.Operator = operator
+		if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+		data, err := os.ReadFile(config.ReportFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := &runReport{}
+		if err := json.Unmarshal(data, report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	if report := run("anna"); report.Operator != "anna" {
+		t.Errorf("оператор в отчете: %q", report.Operator)
+	}
+	output := filepath.Join(outputDir, "a.md")
+	if data, _ := os.ReadFile(output); !strings.Contains(string(data), OperatorKey+": anna") {
+		t.Errorf("оператор в frontmatter результата:\n%s", data)
+	}
+	runs, err := listRuns(config.StateDir)
+	if err != nil || len(runs) != 1 || runs[0].Operator != "anna" {
+		t.Fatalf("оператор в журнале запуска: %v", err)
+	}
+
+	// Ручная правка: новое обогащение anna сохраняется в .new
+	if err := os.WriteFile(output, []byte("Правка редактора"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	answer = "Второе обогащение"
+	run("anna")
+	pending, err := os.ReadFile(output + ConflictSuffix)
+	if err != nil || !strings.Contains(string(pending), "Второе обогащение") {
+		t.Fatalf("файл .new: %q, %v", pending, err)
+	}
+
+	// Запуск другого оператора не запрашивает файл заново и не трогает чужой .new
+	answer = "Третье обогащение"
+	before := requests
+	report := run("boris")
+	if requests != before {
+		t.Errorf("файл на проверке у другого оператора отправлен в API")
+	}
+	if data, _ := os.ReadFile(output + ConflictSuffix); string(data) != string(pending) {
+		t.Errorf("файл .new другого оператора перезаписан:\n%s", data)
+	}
+	if len(report.Files) != 1 || report.Files[0].Status != StatusSkippedInReview || !samePath(report.Files[0].Conflict, output+ConflictSuffix) {
+		t.Errorf("файл на проверке в отчете: %+v", report.Files)
+	}
+	if conflicts, err := pendingConflicts(config.StateDir); err != nil || len(conflicts) != 1 {
+		t.Errorf("конфликт пропал из списка неразрешенных: %v, %v", conflicts, err)
+	}
+	if _, problems, err := verifyOutputs(config); err != nil || len(problems) != 1 || problems[0].Reason != VerifyConflict {
+		t.Errorf("verifyOutputs() = %v, %v", problems, err)
+	}
+
+	// Оператор конфликта обновляет свой файл .new
+	run("anna")
+	if data, _ := os.ReadFile(output + ConflictSuffix); !strings.Contains(string(data), "Третье обогащение") {
+		t.Errorf("файл .new не обновлен его оператором:\n%s", data)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Стратегии порядка обработки файлов
+const (
+	OrderAlphabetical = "alphabetical"
+	OrderNewest       = "newest"
+	OrderOldest       = "oldest"
+	OrderSmallest     = "smallest"
+	OrderPriority     = "priority"
+)
+
+// Размер начала файла, читаемого для поиска frontmatter при сортировке
+const frontmatterPeekSize = 64 * 1024
+
+// Файл-кандидат на обработку
+type candidate struct {
+	Path    string
+	RelPath string
+	Info    os.FileInfo
+}
+
+// Проверка корректности названия стратегии порядка обработки
+func validateOrder(order string) error {
+	switch order {
+	case OrderAlphabetical, OrderNewest, OrderOldest, OrderSmallest, OrderPriority:
+		return nil
+	}
+	return errorf("неизвестный порядок обработки: %s (допустимо: %s, %s, %s, %s, %s)",
+		order, OrderAlphabetical, OrderNewest, OrderOldest, OrderSmallest, OrderPriority)
+}
+
+// Сортировка кандидатов согласно выбранной стратегии
+func sortCandidates(candidates []candidate, order, priorityKey string) {
+	switch order {
+	case OrderNewest:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Info.ModTime().After(candidates[j].Info.ModTime())
+		})
+	case OrderOldest:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Info.ModTime().Before(candidates[j].Info.ModTime())
+		})
+	case OrderSmallest:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Info.Size() < candidates[j].Info.Size()
+		})
+	case OrderPriority:
+		priorities := make(map[string]float64, len(candidates))
+		for _, c := range candidates {
+			priorities[c.Path] = readPriority(c.Path, priorityKey)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return priorities[candidates[i].Path] > priorities[candidates[j].Path]
+		})
+	default:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].RelPath < candidates[j].RelPath
+		})
+	}
+}
+
+// Чтение приоритета файла из frontmatter (большее значение обрабатывается раньше, по умолчанию 0)
+func readPriority(path, key string) float64 {
+	value, ok := readFrontmatterField(path, key)
+	if !ok {
+		return 0
+	}
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// Значение поля frontmatter из начала файла
+func readFrontmatterField(path, key string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer func() { _ = file.Close() }()
+
+	head, err := io.ReadAll(io.LimitReader(file, frontmatterPeekSize))
+	if err != nil {
+		return "", false
+	}
+
+	fm, _, ok := parseFrontmatter(head)
+	if !ok {
+		return "", false
+	}
+	value, ok := fm[key]
+	return value, ok
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSortCandidates(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Now()
+
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"b.md", "---\npriority: 1\n---\nсредний файл", 2 * time.Hour},
+		{"a.md", "---\npriority: 10\n---\nсамый большой файл в наборе", 1 * time.Hour},
+		{"c.md", "мал", 3 * time.Hour},
+	}
+
+	var candidates []candidate
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", f.name, err)
+		}
+		mtime := now.Add(-f.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Не удалось изменить время файла %s: %v", f.name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Не удалось получить информацию о файле %s: %v", f.name, err)
+		}
+		candidates = append(candidates, candidate{Path: path, RelPath: f.name, Info: info})
+	}
+
+	testCases := []struct {
+		order    string
+		expected []string
+	}{
+		{OrderAlphabetical, []string{"a.md", "b.md", "c.md"}},
+		{OrderNewest, []string{"a.md", "b.md", "c.md"}},
+		{OrderOldest, []string{"c.md", "b.md", "a.md"}},
+		{OrderSmallest, []string{"c.md", "b.md", "a.md"}},
+		{OrderPriority, []string{"a.md", "b.md", "c.md"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.order, func(t *testing.T) {
+			sorted := append([]candidate(nil), candidates...)
+			sortCandidates(sorted, tc.order, "priority")
+			for i, name := range tc.expected {
+				if sorted[i].RelPath != name {
+					t.Errorf("Позиция %d: ожидалось %s, получено %s", i, name, sorted[i].RelPath)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	if err := validateOrder(OrderNewest); err != nil {
+		t.Errorf("validateOrder() вернул ошибку для допустимого порядка: %v", err)
+	}
+	if err := validateOrder("random"); err == nil {
+		t.Error("validateOrder() не вернул ошибку для неизвестного порядка")
+	}
+}
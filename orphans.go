@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Результаты и записи состояния, оставшиеся от удаленных входных файлов
+type orphanReport struct {
+	// Выходные файлы без исходного файла (абсолютные пути) и их пути в общем состоянии
+	Outputs []orphanOutput
+	// Записи файла состояния удаленных входных файлов
+	States []string
+	// Записи excluded_files удаленных входных файлов, добавленные при обработке
+	Excluded []string
+}
+
+// Выходной файл без исходного файла
+type orphanOutput struct {
+	Path string
+	Key  string
+}
+
+// Количество найденных записей
+func (r *orphanReport) Total() int {
+	return len(r.Outputs) + len(r.States) + len(r.Excluded)
+}
+
+// Проверка, что исходный файл по пути в общем состоянии существует
+func inputExists(config *Config, key string) bool {
+	rootConfig, rel := config.rootForKey(key)
+	_, err := os.Stat(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rel)))
+	return err == nil
+}
+
+// Проверка исходного файла результата без записи в журнале: файл с тем же путем,
+// сжатая заметка или файл с тем же именем и другим входным расширением (pandoc, PDF)
+func outputHasInput(config *Config, key string) bool {
+	if inputExists(config, key) || (config.Gzip.Enabled && inputExists(config, key+".gz")) {
+		return true
+	}
+	rootConfig, rel := config.rootForKey(key)
+	path := filepath.Join(rootConfig.InputDir, filepath.FromSlash(rel))
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.TrimSuffix(name, filepath.Ext(name)) == stem && config.isInputFile(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Поиск результатов без исходных файлов и записей состояния удаленных файлов.
+// Исходный файл результата берется из журнала запусков (учитывает маршруты и
+// смену расширения), для результатов без записи - по пути в выходной директории
+func findOrphans(config *Config) (*orphanReport, error) {
+	var latest map[string]outputRecord
+	var manifest *stateManifest
+	if config.StateDir != "" {
+		var err error
+		if latest, err = latestOutputs(config.StateDir); err != nil {
+			return nil, err
+		}
+		if manifest, err = loadStateManifest(config.StateDir); err != nil {
+			return nil, err
+		}
+	}
+	inputOf := make(map[string]string, len(latest))
+	for key, rec := range latest {
+		if rec.Entry.Output != "" {
+			inputOf[pathKey(rec.Entry.Output)] = key
+		}
+	}
+
+	trashDir, _ := filepath.Abs(config.trashDir())
+	report := &orphanReport{}
+	for _, rootConfig := range config.inputRoots() {
+		outputDir, err := filepath.Abs(rootConfig.OutputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+		}
+		inputDir, err := filepath.Abs(rootConfig.InputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+		if samePath(inputDir, outputDir) {
+			continue
+		}
+		if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+			continue
+		}
+		files, err := markdownFiles(outputDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range files {
+			path := filepath.Join(outputDir, filepath.FromSlash(rel))
+			// Оглавление, входная директория и корзина внутри выходной
+			if rel == config.IndexFile || strings.HasPrefix(path, inputDir+string(filepath.Separator)) ||
+				strings.HasPrefix(path, trashDir+string(filepath.Separator)) {
+				continue
+			}
+			// При output_layout = per-run путь результата начинается с директории запуска
+			if config.OutputLayout == OutputLayoutPerRun {
+				if dir, rest, ok := strings.Cut(rel, "/"); ok {
+					if _, err := time.Parse(runDirLayout, dir); err == nil {
+						rel = rest
+					}
+				}
+			}
+			// Часть разделенного результата проверяется по его первой части
+			lookup := path
+			if owner, ok := pageOwner(path); ok {
+				if _, err := os.Stat(owner); err == nil {
+					lookup = owner
+					rel, _ = pageOwner(rel)
+				}
+			}
+			if key, ok := inputOf[pathKey(lookup)]; ok {
+				if !inputExists(config, key) {
+					report.Outputs = append(report.Outputs, orphanOutput{Path: path, Key: key})
+				}
+				continue
+			}
+			if key := rootKey(rootConfig.RootName, rel); !outputHasInput(config, key) {
+				report.Outputs = append(report.Outputs, orphanOutput{Path: path, Key: key})
+			}
+		}
+	}
+
+	if manifest != nil {
+		for key := range manifest.Files {
+			if !inputExists(config, key) {
+				report.States = append(report.States, key)
+			}
+		}
+		sort.Strings(report.States)
+	}
+	// Записи, добавленные вручную (README.md, шаблоны), не трогаются: учитываются
+	// только файлы, которые есть в журнале запусков или файле состояния
+	for _, file := range config.ExcludedFiles {
+		file = strings.TrimSpace(file)
+		_, inJournal := latest[normalizeRelPath(file)]
+		inState := false
+		if manifest != nil {
+			_, inState = manifest.Files[normalizeRelPath(file)]
+		}
+		if file != "" && (inJournal || inState) && !inputExists(config, file) {
+			report.Excluded = append(report.Excluded, file)
+		}
+	}
+	return report, nil
+}
+
+// Очистка найденных записей: результаты переносятся в корзину, записи убираются
+// из файла состояния и excluded_files
+func pruneOrphans(config *Config, configPath string, report *orphanReport, now time.Time) error {
+	if len(report.Outputs) > 0 && config.TrashDir == "" && config.StateDir == "" {
+		return withCategory(ErrorConfig, errorf("для переноса результатов в корзину задайте trash_dir или каталог состояния"))
+	}
+	for _, o := range report.Outputs {
+		if _, err := moveToTrash(config, o.Path, o.Key, now); err != nil {
+			return errorf("не удалось перенести %s в корзину: %v", o.Path, err)
+		}
+	}
+	if len(report.States) > 0 {
+		manifest, err := loadStateManifest(config.StateDir)
+		if err != nil {
+			return err
+		}
+		for _, key := range report.States {
+			manifest.Forget(key)
+		}
+		if err := manifest.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, file := range report.Excluded {
+		if err := removeFromExcludedFiles(configPath, file); err != nil {
+			return errorf("не удалось убрать %s из списка исключений: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// rich orphans [--prune]: результаты без исходных файлов и записи состояния
+// удаленных файлов
+func runOrphansCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	prune := fs.Bool("prune", false, tr("Перенести результаты в корзину и убрать записи состояния"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if config.InputSource != "" {
+		return withCategory(ErrorConfig, errorf("rich orphans работает только с входной директорией input_dir, а не с input_source"))
+	}
+	report, err := findOrphans(config)
+	if err != nil {
+		return err
+	}
+	if report.Total() == 0 {
+		fmt.Fprintln(out, tr("Результатов и записей состояния без исходных файлов нет"))
+		return nil
+	}
+	if len(report.Outputs) > 0 {
+		fmt.Fprintf(out, tr("Результаты без исходных файлов: %d\n"), len(report.Outputs))
+		for _, o := range report.Outputs {
+			fmt.Fprintf(out, "  %s\n", o.Path)
+		}
+	}
+	if len(report.States) > 0 {
+		fmt.Fprintf(out, tr("Записи файла состояния удаленных файлов: %d\n"), len(report.States))
+		for _, key := range report.States {
+			fmt.Fprintf(out, "  %s\n", key)
+		}
+	}
+	if len(report.Excluded) > 0 {
+		fmt.Fprintf(out, tr("Записи excluded_files удаленных файлов: %d\n"), len(report.Excluded))
+		for _, file := range report.Excluded {
+			fmt.Fprintf(out, "  %s\n", file)
+		}
+	}
+	if !*prune {
+		fmt.Fprintln(out, tr("Для очистки запустите с --prune"))
+		return nil
+	}
+	if err := pruneOrphans(config, *configPath, report, time.Now()); err != nil {
+		return err
+	}
+	if len(report.Outputs) > 0 {
+		fmt.Fprintf(out, tr("Результаты перенесены в корзину: %s\n"), config.trashDir())
+	}
+	fmt.Fprintln(out, tr("Очистка завершена"))
+	return nil
+}
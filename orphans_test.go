@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrphans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	if err := os.MkdirAll(filepath.Join(inputDir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "notes/b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка\n\nТекст заметки"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[STATE]\ndir = " + stateDir + "\n[EXCLUSIONS]\nexcluded_files = README.md\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	// Удаленная заметка и результат, записанный без журнала
+	if err := os.Remove(filepath.Join(inputDir, "notes", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "stray.md"), []byte("# Лишний"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runOrphansCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("runOrphansCommand() вернул ошибку: %v", err)
+	}
+	for _, want := range []string{filepath.Join(outputDir, "notes", "b.md"), filepath.Join(outputDir, "stray.md"), "--prune"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("В выводе нет %s:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "README.md") || strings.Contains(out.String(), filepath.Join(outputDir, "a.md")) {
+		t.Errorf("Записи существующих и добавленных вручную файлов не должны выводиться:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "stray.md")); err != nil {
+		t.Errorf("Без --prune файлы не должны меняться: %v", err)
+	}
+
+	out.Reset()
+	if err := runOrphansCommand([]string{"--config", configPath, "--prune"}, &out); err != nil {
+		t.Fatalf("runOrphansCommand(--prune) вернул ошибку: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "notes", "b.md")); !os.IsNotExist(err) {
+		t.Errorf("Результат удаленной заметки должен быть перенесен в корзину: %v", err)
+	}
+	trashed, _ := filepath.Glob(filepath.Join(stateDir, "trash", "*", "stray.md"))
+	if len(trashed) != 1 {
+		t.Errorf("Лишний результат не найден в корзине: %v", trashed)
+	}
+	reloaded, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(reloaded.ExcludedFiles, ",") != "README.md,a.md" {
+		t.Errorf("Неожиданный список исключений после очистки: %v", reloaded.ExcludedFiles)
+	}
+	report, err := findOrphans(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total() != 0 {
+		t.Errorf("После очистки не должно оставаться записей: %+v", report)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Имена оглавления выходной директории по умолчанию
+const (
+	defaultIndexFile    = "INDEX.md"
+	defaultManifestFile = "index.json"
+)
+
+// Длина краткого описания документа в оглавлении (символов)
+const indexSummaryRunes = 200
+
+// Документ в оглавлении выходной директории
+type indexEntry struct {
+	// Путь относительно выходной директории (через /)
+	Path    string `json:"path"`
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+	// Дата из поля date frontmatter ("" - не указана)
+	Date string `json:"date,omitempty"`
+	// Время изменения и размер файла: неизмененные файлы повторно не разбираются
+	Modified time.Time `json:"modified"`
+	Size     int64     `json:"size"`
+}
+
+// JSON манифест оглавления
+type indexManifest struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Documents   []indexEntry `json:"documents"`
+}
+
+// Заголовок, краткое описание и дата обогащенного документа: из полей frontmatter
+// title, summary (description) и date, иначе - первый заголовок и первый абзац текста
+func describeDocument(relPath string, data []byte) (title, summary, date string) {
+	text := string(data)
+	if prev, ok := parseEnrichedOutput(text); ok {
+		text = prev.Enriched
+	}
+	fm, body, _ := parseFrontmatter([]byte(text))
+	title, summary, date = fm["title"], fm["summary"], fm["date"]
+	if summary == "" {
+		summary = fm["description"]
+	}
+	inFence := false
+	for _, para := range strings.Split(string(body), "\n\n") {
+		para = strings.TrimSpace(para)
+		// Блок кода может содержать пустые строки: пропускается до закрывающей ограды
+		if inFence || strings.HasPrefix(para, "```") {
+			if strings.Count(para, "```")%2 == 1 {
+				inFence = !inFence
+			}
+			continue
+		}
+		if para == "" {
+			continue
+		}
+		if isHeadingLine(para) {
+			if title == "" {
+				heading, _, _ := strings.Cut(para, "\n")
+				title = strings.TrimSpace(strings.TrimLeft(heading, "#"))
+			}
+			continue
+		}
+		if summary == "" && !strings.HasPrefix(para, "<") && !strings.HasPrefix(para, "|") {
+			summary = para
+		}
+		if title != "" && summary != "" {
+			break
+		}
+	}
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(relPath), path.Ext(relPath))
+	}
+	return title, snippet(summary, indexSummaryRunes), date
+}
+
+// Загрузка манифеста предыдущего запуска (пустой, если файла нет или он поврежден)
+func loadIndexManifest(manifestPath string) map[string]indexEntry {
+	entries := map[string]indexEntry{}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return entries
+	}
+	var manifest indexManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		logf("Предупреждение: манифест оглавления %s поврежден и будет построен заново: %v", manifestPath, err)
+		return entries
+	}
+	for _, e := range manifest.Documents {
+		entries[e.Path] = e
+	}
+	return entries
+}
+
+// Обновление оглавления INDEX.md и JSON манифеста выходной директории: разбираются
+// только новые и измененные с прошлого запуска документы, удаленные убираются.
+// Файлы не перезаписываются, если состав и описания документов не изменились
+func updateIndex(config *Config, outputDir string) error {
+	indexPath := filepath.Join(outputDir, config.IndexFile)
+	manifestPath := filepath.Join(outputDir, config.IndexManifest)
+	previous := loadIndexManifest(manifestPath)
+
+	var entries []indexEntry
+	parsed := 0
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != outputDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".md") || p == indexPath {
+			return nil
+		}
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil {
+			return err
+		}
+		if config.isServiceFile(rel) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if prev, ok := previous[rel]; ok && prev.Modified.Equal(info.ModTime()) && prev.Size == info.Size() {
+			entries = append(entries, prev)
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		title, summary, date := describeDocument(rel, data)
+		entries = append(entries, indexEntry{Path: rel, Title: title, Summary: summary, Date: date, Modified: info.ModTime(), Size: info.Size()})
+		parsed++
+		return nil
+	})
+	if err != nil {
+		return errorf("ошибка при построении оглавления: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	unchanged := parsed == 0 && len(entries) == len(previous)
+	if _, err := os.Stat(indexPath); unchanged && err == nil {
+		logf("Оглавление %s не изменилось", indexPath)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(indexManifest{GeneratedAt: time.Now(), Documents: entries}, "", "  ")
+	if err != nil {
+		return errorf("ошибка при подготовке манифеста оглавления: %v", err)
+	}
+	if err := safeWriteFile(manifestPath, data, 0644); err != nil {
+		return errorf("ошибка при записи манифеста оглавления: %v", err)
+	}
+	if err := safeWriteFile(indexPath, []byte(renderIndex(entries)), 0644); err != nil {
+		return errorf("ошибка при записи оглавления: %v", err)
+	}
+	infof("Оглавление обновлено: %s (документов %d, разобрано %d)", indexPath, len(entries), parsed)
+	return nil
+}
+
+// Markdown оглавления: документы сгруппированы по директориям
+func renderIndex(entries []indexEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, tr("# Оглавление\n\nДокументов: %d\n"), len(entries))
+
+	groups := map[string][]indexEntry{}
+	for _, e := range entries {
+		dir := path.Dir(e.Path)
+		groups[dir] = append(groups[dir], e)
+	}
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	// Документы корня выходной директории - первыми
+	slices.SortFunc(dirs, func(a, b string) int {
+		if (a == ".") != (b == ".") {
+			if a == "." {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	for _, dir := range dirs {
+		if dir != "." {
+			fmt.Fprintf(&b, "\n## %s\n", dir)
+		}
+		b.WriteString("\n")
+		for _, e := range groups[dir] {
+			fmt.Fprintf(&b, "- [%s](%s)", escapeLinkText(e.Title), escapeLinkPath(e.Path))
+			date := e.Date
+			if date == "" {
+				date = e.Modified.Format(time.DateOnly)
+			}
+			fmt.Fprintf(&b, " (%s)", date)
+			if e.Summary != "" {
+				b.WriteString(" - " + e.Summary)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Экранирование текста ссылки markdown
+func escapeLinkText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(text)
+}
+
+// Относительная ссылка на документ с экранированием сегментов пути
+func escapeLinkPath(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeDocument(t *testing.T) {
+	doc := "---\ntitle: Резервное копирование\ndate: 2024-05-01\n---\n```bash\npg_dump\n\nrestore\n```\n\n# Другой заголовок\n\nЕжедневные снимки базы данных.\n\n```old\nоригинал\n```"
+	title, summary, date := describeDocument("ops/backup.md", []byte(doc))
+	if title != "Резервное копирование" || summary != "Ежедневные снимки базы данных." || date != "2024-05-01" {
+		t.Errorf("describeDocument() = %q, %q, %q", title, summary, date)
+	}
+	title, summary, _ = describeDocument("notes/plain note.md", []byte("Текст без заголовка"))
+	if title != "plain note" || summary != "Текст без заголовка" {
+		t.Errorf("Без заголовка используется имя файла: %q, %q", title, summary)
+	}
+}
+
+func TestUpdateIndex(t *testing.T) {
+	outputDir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл: %v", err)
+		}
+	}
+	write("root.md", "# Корень\n\nОписание корня.")
+	write("guides/setup [v2].md", "# Установка [beta]\n\nШаги установки.")
+	write(".rich/runs/journal.md", "служебный файл")
+
+	config := &Config{IndexFile: defaultIndexFile, IndexManifest: defaultManifestFile}
+	if err := updateIndex(config, outputDir); err != nil {
+		t.Fatalf("updateIndex() вернул ошибку: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(outputDir, defaultIndexFile))
+	if err != nil {
+		t.Fatalf("Оглавление не создано: %v", err)
+	}
+	for _, want := range []string{"Документов: 2", "- [Корень](root.md)", "## guides", `- [Установка \[beta\]](guides/setup%20%5Bv2%5D.md)`, "Шаги установки."} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("В оглавлении нет %q:\n%s", want, index)
+		}
+	}
+	if strings.Index(string(index), "Корень") > strings.Index(string(index), "## guides") {
+		t.Error("Документы корня должны идти перед поддиректориями")
+	}
+
+	// Неизмененные документы не разбираются повторно: описание берется из манифеста
+	manifestPath := filepath.Join(outputDir, defaultManifestFile)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Манифест не создан: %v", err)
+	}
+	var manifest indexManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Documents) != 2 {
+		t.Fatalf("Некорректный манифест: %+v, %v", manifest, err)
+	}
+	manifest.Documents[0].Title = "Из манифеста" // guides/setup [v2].md
+	data, _ = json.Marshal(manifest)
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		t.Fatalf("Не удалось записать манифест: %v", err)
+	}
+	if err := os.Remove(filepath.Join(outputDir, defaultIndexFile)); err != nil {
+		t.Fatalf("Не удалось удалить оглавление: %v", err)
+	}
+	// Измененный документ разбирается заново
+	write("root.md", "# Новый корень\n\nОбновлено.")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(outputDir, "root.md"), future, future); err != nil {
+		t.Fatalf("Не удалось изменить время файла: %v", err)
+	}
+	if err := updateIndex(config, outputDir); err != nil {
+		t.Fatalf("updateIndex() вернул ошибку: %v", err)
+	}
+	index, _ = os.ReadFile(filepath.Join(outputDir, defaultIndexFile))
+	if !strings.Contains(string(index), "[Новый корень](root.md)") || !strings.Contains(string(index), "[Из манифеста]") {
+		t.Errorf("Оглавление должно обновляться по манифесту:\n%s", index)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/ini.v1"
+)
+
+// Служебное вступление модели перед результатом: короткая строка с двоеточием в конце,
+// например «Here is the enriched text:», «Sure! Here it is:», «Вот обогащенный текст:»
+var preambleRe = regexp.MustCompile(`^(?i)(?:here|below|sure|certainly|of course|okay|ok|вот|ниже|конечно|хорошо)[\s,!.][^\n]{0,120}:$`)
+
+// Замена по регулярному выражению из секции [REPLACE.<имя>]
+type outputReplacement struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Правила обработки ответа модели перед записью: удаление вступлений, замены,
+// уровень заголовков и ограничение длины
+type outputRules struct {
+	StripPreamble bool
+	Replacements  []outputReplacement
+	// Уровень заголовков верхнего уровня (0 - не изменяется)
+	HeadingLevel int
+	// Максимум слов в ответе (0 - без ограничения)
+	MaxWords int
+	// Отказы модели считаются ошибкой; после отказа запрос повторяется RefusalRetries
+	// раз с уточненным промптом. RefusalPattern - дополнительный признак отказа
+	DetectRefusals bool
+	RefusalRetries int
+	RefusalPattern *regexp.Regexp
+}
+
+// Чтение правил: ключи секции [POSTPROCESS] и секции [REPLACE.<имя>] в порядке
+// следования в конфигурации
+func loadOutputRules(cfg *ini.File) (outputRules, error) {
+	var rules outputRules
+	post := cfg.Section("POSTPROCESS")
+	rules.StripPreamble = post.Key("strip_preamble").MustBool(false)
+	rules.MaxWords = post.Key("max_words").MustInt(0)
+	// Уровень задается числом: символ # в значении INI начинает комментарий
+	rules.HeadingLevel = post.Key("heading_level").MustInt(0)
+	if rules.HeadingLevel < 0 || rules.HeadingLevel > 6 {
+		return rules, errorf("некорректное значение heading_level %d: ожидалось от 1 до 6 (0 - не изменять)", rules.HeadingLevel)
+	}
+	rules.DetectRefusals = post.Key("detect_refusals").MustBool(true)
+	rules.RefusalRetries = post.Key("refusal_retries").MustInt(1)
+	if pattern := post.Key("refusal_pattern").String(); pattern != "" {
+		var err error
+		if rules.RefusalPattern, err = regexp.Compile(pattern); err != nil {
+			return rules, errorf("некорректное регулярное выражение refusal_pattern: %v", err)
+		}
+	}
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "REPLACE.")
+		if !ok {
+			continue
+		}
+		if section.Key("pattern").String() == "" {
+			return rules, errorf("в замене %s не задан pattern", name)
+		}
+		pattern, err := regexp.Compile(section.Key("pattern").String())
+		if err != nil {
+			return rules, errorf("некорректное регулярное выражение в замене %s: %v", name, err)
+		}
+		rules.Replacements = append(rules.Replacements, outputReplacement{
+			Name: name, Pattern: pattern, Replacement: section.Key("replacement").String(),
+		})
+	}
+	return rules, nil
+}
+
+// Применение правил к ответу модели; заголовки в блоках кода не изменяются,
+// замены применяются ко всему тексту
+func (r outputRules) Apply(text string) string {
+	if r.StripPreamble {
+		text = stripPreamble(text)
+	}
+	for _, rep := range r.Replacements {
+		text = rep.Pattern.ReplaceAllString(text, rep.Replacement)
+	}
+	if r.HeadingLevel > 0 {
+		text = shiftHeadings(text, r.HeadingLevel)
+	}
+	if r.MaxWords > 0 {
+		text = trimWords(text, r.MaxWords)
+	}
+	return text
+}
+
+// Удаление вступительной строки перед результатом и обертки ```markdown
+func stripPreamble(text string) string {
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	first, rest, _ := strings.Cut(trimmed, "\n")
+	if !preambleRe.MatchString(strings.TrimSpace(first)) {
+		return text
+	}
+	rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	// Результат, целиком обернутый в блок кода markdown после вступления
+	if body, ok := strings.CutPrefix(rest, "```markdown\n"); ok {
+		if body, ok = strings.CutSuffix(strings.TrimRightFunc(body, unicode.IsSpace), "```"); ok {
+			rest = body
+		}
+	}
+	return rest
+}
+
+// Сдвиг заголовков: самый крупный заголовок получает уровень level, остальные
+// сдвигаются на ту же величину (не глубже шестого уровня)
+func shiftHeadings(text string, level int) string {
+	lines := strings.Split(text, "\n")
+	top := 0
+	forEachOutsideFences(lines, func(i int) {
+		if l := headingLevel(lines[i]); l > 0 && (top == 0 || l < top) {
+			top = l
+		}
+	})
+	if top == 0 || top == level {
+		return text
+	}
+	forEachOutsideFences(lines, func(i int) {
+		if l := headingLevel(lines[i]); l > 0 {
+			lines[i] = strings.Repeat("#", min(max(l-top+level, 1), 6)) + lines[i][l:]
+		}
+	})
+	return strings.Join(lines, "\n")
+}
+
+// Обрезка текста до maxWords слов с сохранением форматирования
+func trimWords(text string, maxWords int) string {
+	words := 0
+	inWord := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		if !inWord {
+			inWord = true
+			words++
+			if words > maxWords {
+				return strings.TrimRightFunc(text[:i], unicode.IsSpace) + "\n"
+			}
+		}
+	}
+	return text
+}
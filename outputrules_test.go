@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestStripPreamble(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"english", "Here is the enriched text:\n\n# Заметка\n", "# Заметка\n"},
+		{"sure", "Sure! Here's the enriched note:\n# Заметка", "# Заметка"},
+		{"russian", "Вот обогащенный текст:\n\n# Заметка", "# Заметка"},
+		{"code block", "Here is the result:\n```markdown\n# Заметка\n```\n", "# Заметка\n"},
+		{"no preamble", "# Заметка\n\nВот что важно:\n", "# Заметка\n\nВот что важно:\n"},
+		{"content line", "Here comes the sun, a song by the Beatles.\n", "Here comes the sun, a song by the Beatles.\n"},
+	}
+	for _, tt := range tests {
+		if got := stripPreamble(tt.input); got != tt.want {
+			t.Errorf("%s: ожидалось %q, получено %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestOutputRules(t *testing.T) {
+	cfg, err := ini.Load([]byte("[POSTPROCESS]\nstrip_preamble = true\nheading_level = 2\nmax_words = 6\n\n" +
+		"[REPLACE.ai]\npattern = (?i)as an ai language model,?\\s*\nreplacement =\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadOutputRules(cfg)
+	if err != nil {
+		t.Fatalf("loadOutputRules() вернул ошибку: %v", err)
+	}
+	got := rules.Apply("Here is the enriched text:\n# Заголовок\n\n## Раздел\n\nAs an AI language model, раз два три четыре")
+	want := "## Заголовок\n\n### Раздел\n\nраз два\n"
+	if got != want {
+		t.Errorf("ожидалось %q, получено %q", want, got)
+	}
+
+	for _, bad := range []string{"[POSTPROCESS]\nheading_level = 7\n", "[REPLACE.x]\npattern = (\n", "[REPLACE.x]\nreplacement = y\n"} {
+		cfg, _ := ini.Load([]byte(bad))
+		if _, err := loadOutputRules(cfg); err == nil {
+			t.Errorf("ожидалась ошибка для %q", bad)
+		}
+	}
+}
+
+func TestShiftHeadingsKeepsCode(t *testing.T) {
+	text := "### Раздел\n\n```\n# комментарий\n```\n#### Подраздел"
+	want := "# Раздел\n\n```\n# комментарий\n```\n## Подраздел"
+	if got := shiftHeadings(text, 1); got != want {
+		t.Errorf("ожидалось %q, получено %q", want, got)
+	}
+}
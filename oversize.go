@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Стратегии обработки файлов больше max_file_size
+const (
+	// Ошибка обработки файла (по умолчанию)
+	OversizeFail = "fail"
+	// Файл пропускается со статусом skipped: too large
+	OversizeSkip = "skip"
+	// В модель отправляется начало файла до max_file_size
+	OversizeTruncate = "truncate"
+	// Файл обогащается по частям не больше max_file_size
+	OversizeChunk = "chunk"
+)
+
+// Проверка стратегии обработки больших файлов
+func validateOversize(strategy string) error {
+	switch strategy {
+	case OversizeFail, OversizeSkip, OversizeTruncate, OversizeChunk:
+		return nil
+	}
+	return errorf("некорректное значение oversize %q: ожидалось %s, %s, %s или %s", strategy, OversizeFail, OversizeSkip, OversizeTruncate, OversizeChunk)
+}
+
+// Максимальный размер файла: max_file_size из конфигурации или MaxFileSize
+func (c *Config) maxFileSize() int {
+	if c.MaxFileSize > 0 {
+		return c.MaxFileSize
+	}
+	return MaxFileSize
+}
+
+// Начало содержимого не больше limit байт: по границе абзаца, если она есть во второй
+// половине, иначе по границе строки или символа; второе значение - отброшенный остаток
+func truncateContent(content []byte, limit int) ([]byte, []byte) {
+	if len(content) <= limit {
+		return content, nil
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	if i := bytes.LastIndex(content[:cut], []byte("\n\n")); i >= limit/2 {
+		cut = i + 2
+	} else if i := bytes.LastIndexByte(content[:cut], '\n'); i >= limit/2 {
+		cut = i + 1
+	}
+	return content[:cut], content[cut:]
+}
+
+// Дополнительное разбиение частей документа, которые больше limit байт
+func splitBySize(chunks []string, limit int) []string {
+	var result []string
+	for _, chunk := range chunks {
+		if len(chunk) <= limit {
+			result = append(result, chunk)
+			continue
+		}
+		head, _ := truncateContent([]byte(chunk), limit)
+		tokenLimit := estimateTokens(string(head))
+		result = append(result, packPieces(splitPieces(chunk, tokenLimit), tokenLimit)...)
+	}
+	return result
+}
+
+// Части документа для обогащения: по контексту модели и max_chunk_tokens, а для
+// файла больше max_file_size со стратегией chunk - еще и по размеру
+func documentChunks(config *Config, content string, oversized bool) []string {
+	chunks := splitForContext(config, content)
+	if oversized && config.Oversize == OversizeChunk {
+		chunks = splitBySize(chunks, config.maxFileSize())
+	}
+	return chunks
+}
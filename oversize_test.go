@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateContent(t *testing.T) {
+	content := []byte("Первый абзац заметки.\n\nВторой абзац заметки.\n\nТретий абзац.")
+	head, tail := truncateContent(content, 50)
+	if string(head) != "Первый абзац заметки.\n\n" || string(head)+string(tail) != string(content) {
+		t.Errorf("ожидалось усечение по границе абзаца, получено %q + %q", head, tail)
+	}
+	// Граница символа: кириллица не разрезается посередине
+	head, _ = truncateContent([]byte("Длинноеслово"), 5)
+	if len(head) != 4 {
+		t.Errorf("ожидалось 2 символа (4 байта), получено %q", head)
+	}
+	if head, tail := truncateContent(content, len(content)); len(tail) != 0 || len(head) != len(content) {
+		t.Error("файл в пределах ограничения не усекается")
+	}
+}
+
+func TestProcessFileOversize(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "big.md")
+	var sections []string
+	for i := 0; i < 6; i++ {
+		sections = append(sections, "## Раздел\n\n"+strings.Repeat("Текст раздела заметки. ", 8))
+	}
+	content := strings.Join(sections, "\n\n")
+	if err := os.WriteFile(inputPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[len(req.Messages)-1]["content"])
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "Обогащенная часть"}}},
+		})
+	}))
+	defer server.Close()
+
+	run := func(strategy string) (*fileResult, string, error) {
+		prompts = nil
+		outputPath := filepath.Join(tmpDir, strategy, "big.md")
+		config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions",
+			MaxTokens: 100, MaxFileSize: 600, Oversize: strategy}
+		result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+		data, _ := os.ReadFile(outputPath)
+		return result, string(data), err
+	}
+
+	if _, _, err := run(OversizeFail); err == nil || !strings.Contains(err.Error(), "размер файла превышает") {
+		t.Errorf("ожидалась ошибка размера, получено %v", err)
+	}
+	if result, _, err := run(OversizeSkip); err != nil || result.Status != StatusSkippedTooLarge || len(prompts) != 0 {
+		t.Errorf("ожидался пропуск без запросов, получено %v, %s, запросов %d", err, result.Status, len(prompts))
+	}
+	result, output, err := run(OversizeTruncate)
+	if err != nil || result.Status != StatusEnriched || len(prompts) != 1 || len(prompts[0]) > 600 {
+		t.Fatalf("ожидался один запрос с началом файла, получено %v, запросов %d", err, len(prompts))
+	}
+	if !strings.Contains(output, strings.ReplaceAll(content, "```", "\\`\\`\\`")) {
+		t.Error("оригинал усеченного файла должен сохраняться целиком")
+	}
+	if result, _, err := run(OversizeChunk); err != nil || result.Status != StatusEnriched || len(prompts) < 2 {
+		t.Errorf("ожидалось обогащение по частям, получено %v, запросов %d", err, len(prompts))
+	}
+	for _, p := range prompts {
+		if len(p) > 600 {
+			t.Errorf("часть больше max_file_size: %d байт", len(p))
+		}
+	}
+}
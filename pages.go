@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Наименьший размер части результата при max_output_size
+const minOutputPageSize = 1024
+
+// Имя части результата после первой: notes/a.md -> notes/a.part2.md
+var pageNamePattern = regexp.MustCompile(`^(.+)\.part([0-9]+)(\.[Mm][Dd])$`)
+
+// Путь части n (с 1) результата: первая часть записывается в сам выходной файл
+func pagePath(outputPath string, n int) string {
+	if n <= 1 {
+		return outputPath
+	}
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(outputPath, ext), n, ext)
+}
+
+// Выходной файл, частью которого является файл path (для частей после первой)
+func pageOwner(path string) (string, bool) {
+	m := pageNamePattern.FindStringSubmatch(path)
+	if m == nil {
+		return "", false
+	}
+	if n, err := strconv.Atoi(m[2]); err != nil || n < 2 {
+		return "", false
+	}
+	return m[1] + m[3], true
+}
+
+// Файл path - часть разделенного результата, первая часть которого существует
+func isResultPage(path string) bool {
+	owner, ok := pageOwner(path)
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(owner)
+	return err == nil
+}
+
+// Результат был разделен на части при прошлой записи
+func hasPages(outputPath string) bool {
+	_, err := os.Stat(pagePath(outputPath, 2))
+	return err == nil
+}
+
+// Разделение обогащенного документа на части не больше limit байт: по разделам,
+// слишком большие разделы - по абзацам, абзацы больше limit - по символам.
+// Frontmatter остается в первой части. Документ не больше limit - одна часть
+func splitPages(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+	var pieces []string
+	body := text
+	_, rest, hasFrontmatter := parseFrontmatter([]byte(text))
+	if hasFrontmatter {
+		pieces = append(pieces, text[:len(text)-len(rest)])
+		body = string(rest)
+	}
+	for _, section := range splitByHeadings(body) {
+		if len(section.Text) <= limit {
+			pieces = append(pieces, section.Text)
+			continue
+		}
+		for _, para := range splitParagraphs(section.Text) {
+			for len(para) > limit {
+				head, rest := truncateContent([]byte(para), limit)
+				pieces = append(pieces, string(head))
+				para = string(rest)
+			}
+			pieces = append(pieces, para)
+		}
+	}
+
+	var pages []string
+	current := ""
+	for i, piece := range pieces {
+		// Frontmatter не отделяется от начала документа
+		if current != "" && len(current)+len(piece) > limit && !(i == 1 && hasFrontmatter) {
+			pages = append(pages, current)
+			current = ""
+		}
+		current += piece
+	}
+	if strings.TrimSpace(current) != "" || len(pages) == 0 {
+		pages = append(pages, current)
+	}
+	for i := range pages {
+		pages[i] = strings.Trim(pages[i], "\n")
+	}
+	return pages
+}
+
+// Навигация по частям в конце части n из total: ссылки на соседние и все части
+func renderPageNav(outputPath string, n, total int) string {
+	link := func(i int) string {
+		return escapeLinkPath(filepath.Base(pagePath(outputPath, i)))
+	}
+	items := make([]string, 0, total+2)
+	if n > 1 {
+		items = append(items, fmt.Sprintf("[←](%s)", link(n-1)))
+	}
+	for i := 1; i <= total; i++ {
+		if i == n {
+			items = append(items, fmt.Sprintf("**%d**", i))
+		} else {
+			items = append(items, fmt.Sprintf("[%d](%s)", i, link(i)))
+		}
+	}
+	if n < total {
+		items = append(items, fmt.Sprintf("[→](%s)", link(n+1)))
+	}
+	return "---\n\n" + strings.Join(items, " · ") + "\n"
+}
+
+// Разделение выходного файла на части по max_output_size: содержимое первой части
+// (с блоком оригинала) и остальных частей. Ограничение относится к обогащенному
+// тексту; блок оригинала остается в первой части, чтобы инкрементальная обработка
+// и rich merge находили его в выходном файле
+func paginateOutput(content []byte, outputPath string, limit int) ([]byte, [][]byte) {
+	enriched, block := string(content), ""
+	if start := strings.LastIndex(enriched, "\n\n```old\n"); start >= 0 && strings.HasSuffix(enriched, "\n```") {
+		enriched, block = enriched[:start], enriched[start:]
+	}
+	if limit <= 0 || len(enriched) <= limit {
+		return content, nil
+	}
+	pages := splitPages(enriched, limit)
+	if len(pages) < 2 {
+		return content, nil
+	}
+	first := pages[0] + "\n\n" + renderPageNav(outputPath, 1, len(pages))
+	if block != "" {
+		first = strings.TrimRight(first, "\n") + block
+	}
+	rest := make([][]byte, 0, len(pages)-1)
+	for i, page := range pages[1:] {
+		rest = append(rest, []byte(page+"\n\n"+renderPageNav(outputPath, i+2, len(pages))))
+	}
+	return []byte(first), rest
+}
+
+// Удаление частей прежнего результата, оставшихся после записи total частей
+func removeStalePages(outputPath string, total int) {
+	for n := max(total, 1) + 1; ; n++ {
+		path := pagePath(outputPath, n)
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) {
+				warnf("Предупреждение: не удалось удалить прежнюю часть результата %s: %v", path, err)
+			}
+			return
+		}
+		logf("Удалена прежняя часть результата %s", path)
+	}
+}
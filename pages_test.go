@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageNames(t *testing.T) {
+	if got := pagePath("notes/a.md", 1); got != "notes/a.md" {
+		t.Errorf("pagePath(1) = %q", got)
+	}
+	if got := pagePath("notes/a.md", 3); got != "notes/a.part3.md" {
+		t.Errorf("pagePath(3) = %q", got)
+	}
+	for path, want := range map[string]string{"notes/a.part3.md": "notes/a.md", "a.part1.md": "", "a.md": "", "a.part.md": ""} {
+		owner, ok := pageOwner(path)
+		if owner != want || ok != (want != "") {
+			t.Errorf("pageOwner(%q) = %q, %v; ожидалось %q", path, owner, ok, want)
+		}
+	}
+}
+
+func TestPaginateOutput(t *testing.T) {
+	section := func(title string) string {
+		return "## " + title + "\n\n" + strings.Repeat("Текст раздела. ", 40) + "\n\n"
+	}
+	enriched := "---\ntitle: Большой документ\n---\n# Документ\n\n" + section("Первый") + section("Второй") + section("Третий")
+	content := []byte(enriched + "\n\n```old\n# Документ\n```")
+
+	first, rest := paginateOutput(content, "out/my note.md", 1200)
+	if len(rest) != 2 {
+		t.Fatalf("Ожидалось 3 части, получено %d", len(rest)+1)
+	}
+	prev, ok := parseEnrichedOutput(string(first))
+	if !ok || prev.Original != "# Документ" {
+		t.Fatalf("Первая часть должна содержать блок оригинала:\n%s", first)
+	}
+	if !strings.HasPrefix(prev.Enriched, "---\ntitle: Большой документ\n---\n# Документ") {
+		t.Errorf("Frontmatter должен оставаться в первой части:\n%s", prev.Enriched)
+	}
+	if !strings.Contains(prev.Enriched, "**1** · [2](my%20note.part2.md) · [3](my%20note.part3.md) · [→](my%20note.part2.md)") {
+		t.Errorf("Неожиданная навигация первой части:\n%s", prev.Enriched)
+	}
+	if !strings.HasPrefix(string(rest[0]), "## Второй") || !strings.Contains(string(rest[0]), "[←](my%20note.md) · [1](my%20note.md) · **2**") {
+		t.Errorf("Вторая часть должна начинаться с раздела и ссылаться на соседние части:\n%s", rest[0])
+	}
+	for i, page := range append([][]byte{[]byte(prev.Enriched)}, rest...) {
+		if len(page) > 1200+200 {
+			t.Errorf("Часть %d больше ограничения: %d байт", i+1, len(page))
+		}
+	}
+
+	if first, rest := paginateOutput(content, "out/a.md", 100000); rest != nil || string(first) != string(content) {
+		t.Error("Результат меньше ограничения не должен делиться")
+	}
+	// Раздел больше ограничения делится по абзацам и символам
+	long := "# Один раздел\n\n" + strings.Repeat("слово ", 1000)
+	pages := splitPages(long, 1024)
+	if len(pages) < 6 || strings.Join(strings.Fields(strings.Join(pages, " ")), " ") != strings.Join(strings.Fields(long), " ") {
+		t.Errorf("Неожиданное разбиение большого раздела: %d частей", len(pages))
+	}
+	for i, page := range pages {
+		if len(page) > 1024 {
+			t.Errorf("Часть %d больше ограничения: %d байт", i+1, len(page))
+		}
+	}
+}
+
+// Большой результат записывается частями; части, оставшиеся от прежнего
+// результата, удаляются при следующей записи
+func TestProcessFileWritesPages(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "doc.md")
+	outputPath := filepath.Join(tmpDir, "out", "doc.md")
+	if err := os.WriteFile(inputPath, []byte("# Документ\n\nКороткая заметка"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reply := "# Документ\\n\\n## Первый\\n\\n" + strings.Repeat("Текст. ", 100) + "\\n\\n## Второй\\n\\n" + strings.Repeat("Текст. ", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + reply + `"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens: 100, MaxOutputSize: 1500}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	part2 := filepath.Join(tmpDir, "out", "doc.part2.md")
+	if len(result.Pages) != 1 || result.Pages[0] != part2 {
+		t.Fatalf("Ожидалась одна дополнительная часть, получено %v", result.Pages)
+	}
+	data, err := os.ReadFile(part2)
+	if err != nil || !strings.HasPrefix(string(data), "## Второй") {
+		t.Errorf("Неожиданная вторая часть: %q (%v)", data, err)
+	}
+	main, _ := os.ReadFile(outputPath)
+	if !strings.Contains(string(main), "[2](doc.part2.md)") || !strings.Contains(string(main), "```old\n# Документ") {
+		t.Errorf("Первая часть должна ссылаться на вторую и содержать оригинал:\n%s", main)
+	}
+
+	reply = "# Документ\\n\\nКороткий результат"
+	result, err = processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pages) != 0 {
+		t.Errorf("Короткий результат не должен делиться: %v", result.Pages)
+	}
+	if _, err := os.Stat(part2); !os.IsNotExist(err) {
+		t.Error("Часть прежнего результата должна быть удалена")
+	}
+}
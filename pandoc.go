@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Форматы pandoc по расширениям файлов; для остальных расширений имя формата
+// совпадает с расширением
+var pandocFormats = map[string]string{
+	"tex":      "latex",
+	"latex":    "latex",
+	"htm":      "html",
+	"txt":      "plain",
+	"wiki":     "mediawiki",
+	"adoc":     "asciidoc",
+	"markdown": "markdown",
+}
+
+// Форматы, которые pandoc записывает только в файл
+var pandocBinaryFormats = map[string]bool{"docx": true, "odt": true, "epub": true, "pptx": true}
+
+// Форматы, для которых оформление берется из исходного документа (--reference-doc)
+var pandocReferenceFormats = map[string]bool{"docx": true, "odt": true, "pptx": true}
+
+// Конвертация документов других форматов через pandoc из секции [PANDOC]
+type PandocConfig struct {
+	Enabled bool
+	// Путь к программе pandoc
+	Binary string
+	// Расширения входных файлов без точки (docx, odt, tex)
+	Formats []string
+	// Результат сохраняется в исходном формате (false - в markdown рядом)
+	ConvertBack bool
+	// Время ожидания одной конвертации
+	Timeout time.Duration
+}
+
+// Чтение секции [PANDOC]
+func loadPandocConfig(section *ini.Section) (PandocConfig, error) {
+	pandoc := PandocConfig{
+		Enabled:     section.Key("enabled").MustBool(false),
+		Binary:      section.Key("binary").MustString("pandoc"),
+		ConvertBack: section.Key("convert_back").MustBool(true),
+		Timeout:     section.Key("timeout").MustDuration(2 * time.Minute),
+	}
+	for _, ext := range splitList(section.Key("formats").MustString("docx, odt, tex")) {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		if ext == "md" {
+			return pandoc, errorf("formats в секции [PANDOC] не должен содержать md: markdown обрабатывается без конвертации")
+		}
+		pandoc.Formats = append(pandoc.Formats, ext)
+	}
+	if pandoc.Timeout <= 0 {
+		return pandoc, errorf("timeout в секции [PANDOC] должен быть положительным: %v", pandoc.Timeout)
+	}
+	return pandoc, nil
+}
+
+// Формат pandoc входного файла ("" - файл не конвертируется)
+func (p PandocConfig) formatFor(path string) string {
+	if !p.Enabled {
+		return ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, f := range p.Formats {
+		if f == ext {
+			if format, ok := pandocFormats[ext]; ok {
+				return format
+			}
+			return ext
+		}
+	}
+	return ""
+}
+
+// Файл обрабатывается: markdown, формат, конвертируемый через pandoc, PDF или
+// сжатая заметка
+func (c *Config) isInputFile(name string) bool {
+	return isMarkdownPath(name) || c.Pandoc.formatFor(name) != "" || (c.PDF.Enabled && isPDFPath(name)) ||
+		(c.Gzip.Enabled && isGzipMarkdownPath(name))
+}
+
+// Файл markdown (по расширению)
+func isMarkdownPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".md")
+}
+
+// Путь результата документа другого формата: без обратной конвертации
+// результат сохраняется в markdown
+func (p PandocConfig) outputPath(outputPath string) string {
+	if p.ConvertBack {
+		return outputPath
+	}
+	return markdownOutputPath(outputPath)
+}
+
+// Проверка наличия pandoc; без него конвертация выключается
+func (p *PandocConfig) checkBinary() {
+	if !p.Enabled {
+		return
+	}
+	if _, err := exec.LookPath(p.Binary); err != nil {
+		warnf("Предупреждение: программа %s не найдена, документы %s не обрабатываются", p.Binary, strings.Join(p.Formats, ", "))
+		p.Enabled = false
+	}
+}
+
+// Запуск pandoc с ограничением времени
+func (p PandocConfig) run(stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Binary, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errorf("pandoc не завершился за %v", p.Timeout)
+		}
+		return nil, errorf("ошибка pandoc: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Конвертация документа в markdown
+func (p PandocConfig) toMarkdown(inputPath, format string) ([]byte, error) {
+	return p.run(nil, "--from="+format, "--to=gfm", "--wrap=none", inputPath)
+}
+
+// Конвертация обогащенного markdown обратно в формат исходного документа.
+// Оформление docx и odt берется из исходного документа
+func (p PandocConfig) fromMarkdown(markdown []byte, format, inputPath string) ([]byte, error) {
+	args := []string{"--from=gfm", "--to=" + format, "--standalone"}
+	if pandocReferenceFormats[format] {
+		args = append(args, "--reference-doc="+inputPath)
+	}
+	if !pandocBinaryFormats[format] {
+		return p.run(markdown, args...)
+	}
+
+	tmp, err := os.CreateTemp("", "rich-pandoc-*."+format)
+	if err != nil {
+		return nil, errorf("не удалось создать временный файл: %v", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+	if _, err := p.run(markdown, append(args, "--output="+tmpPath)...); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return nil, errorf("не удалось прочитать результат pandoc: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Заменитель pandoc: в markdown выводит заголовок и содержимое файла, обратно -
+// имя формата и markdown из стандартного ввода
+const fakePandocScript = `#!/bin/sh
+out=""; to=""; last=""
+for a in "$@"; do
+	case "$a" in
+		--to=*) to="${a#--to=}";;
+		--output=*) out="${a#--output=}";;
+	esac
+	last="$a"
+done
+if [ "$to" = "gfm" ]; then printf '# Документ\n\n'; cat "$last"; exit 0; fi
+if [ -n "$out" ]; then { printf '%s:' "$to"; cat; } > "$out"; else printf '%s:' "$to"; cat; fi
+`
+
+func TestLoadPandocConfig(t *testing.T) {
+	cfg := ini.Empty()
+	section := cfg.Section("PANDOC")
+	section.Key("enabled").SetValue("true")
+	section.Key("formats").SetValue(".DOCX, tex")
+	pandoc, err := loadPandocConfig(section)
+	if err != nil {
+		t.Fatalf("loadPandocConfig() вернул ошибку: %v", err)
+	}
+	config := &Config{Pandoc: pandoc}
+	for name, want := range map[string]bool{"a.md": true, "report.docx": true, "paper.TEX": true, "slides.odt": false} {
+		if got := config.isInputFile(name); got != want {
+			t.Errorf("isInputFile(%q) = %v, ожидалось %v", name, got, want)
+		}
+	}
+	if f := pandoc.formatFor("paper.tex"); f != "latex" {
+		t.Errorf("формат tex: %q", f)
+	}
+
+	section.Key("formats").SetValue("docx, md")
+	if _, err := loadPandocConfig(section); err == nil {
+		t.Error("ожидалась ошибка для md в formats")
+	}
+}
+
+func TestPandocBridge(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("заменитель pandoc - сценарий sh")
+	}
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(tmpDir, "pandoc")
+	if err := os.WriteFile(binary, []byte(fakePandocScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"report.docx": "Текст отчета из docx", "paper.tex": "Текст статьи"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, string(body))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Документ\n\nОбогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Pandoc: PandocConfig{Enabled: true, Binary: binary, Formats: []string{"docx", "tex"}, ConvertBack: true, Timeout: time.Minute}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if len(requests) != 2 || !strings.Contains(strings.Join(requests, "\n"), "Текст отчета из docx") {
+		t.Errorf("в модель должен отправляться markdown после конвертации: %q", requests)
+	}
+	docx, err := os.ReadFile(filepath.Join(outputDir, "report.docx"))
+	if err != nil {
+		t.Fatalf("результат в формате docx не создан: %v", err)
+	}
+	if !strings.HasPrefix(string(docx), "docx:") || !strings.Contains(string(docx), "Обогащенный текст") || strings.Contains(string(docx), "```old") {
+		t.Errorf("результат docx: %q", docx)
+	}
+	if tex, _ := os.ReadFile(filepath.Join(outputDir, "paper.tex")); !strings.HasPrefix(string(tex), "latex:") {
+		t.Errorf("результат tex: %q", tex)
+	}
+
+	// Без обратной конвертации результат сохраняется в markdown с оригиналом
+	config.OutputDir = filepath.Join(tmpDir, "markdown")
+	config.ExcludedFiles = nil
+	config.Pandoc.ConvertBack = false
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	md, err := os.ReadFile(filepath.Join(config.OutputDir, "report.md"))
+	if err != nil {
+		t.Fatalf("результат в markdown не создан: %v", err)
+	}
+	if !strings.Contains(string(md), "```old\n# Документ\n\nТекст отчета из docx\n```") {
+		t.Errorf("результат markdown: %q", md)
+	}
+}
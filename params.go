@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+)
+
+// Канонический набор параметров генерации; в запрос каждого провайдера
+// переводится в его имена полей
+type generationParams struct {
+	Temperature float64
+	MaxTokens   int
+	// Стоп-последовательности (пусто - не отправляются)
+	Stop []string
+	// Ядерная выборка (0 - не отправляется)
+	TopP float64
+}
+
+// Имена полей параметров в запросе ("" - параметр не поддерживается и не отправляется)
+type paramFields struct {
+	Temperature string
+	MaxTokens   string
+	Stop        string
+	TopP        string
+}
+
+// Параметры генерации из конфигурации
+func (c *Config) generationParams(maxTokens int) generationParams {
+	return generationParams{
+		Temperature: c.Temperature,
+		MaxTokens:   maxTokens,
+		Stop:        c.Stop,
+		TopP:        c.TopP,
+	}
+}
+
+// Имена полей параметров для провайдера и модели из конфигурации
+func (p *provider) paramFields(config *Config) paramFields {
+	fields := p.api().ParamFields(config)
+	if p != nil && p.adjustParams != nil {
+		fields = p.adjustParams(config, fields)
+	}
+	return fields
+}
+
+// Параметры генерации в полях запроса провайдера; неподдерживаемые
+// и незаданные параметры пропускаются
+func (p *provider) requestParams(config *Config, params generationParams) map[string]interface{} {
+	fields := p.paramFields(config)
+	result := map[string]interface{}{}
+	if fields.Temperature != "" {
+		result[fields.Temperature] = params.Temperature
+	}
+	if fields.MaxTokens != "" {
+		result[fields.MaxTokens] = params.MaxTokens
+	}
+	if fields.Stop != "" && len(params.Stop) > 0 {
+		result[fields.Stop] = params.Stop
+	}
+	if fields.TopP != "" && params.TopP > 0 {
+		result[fields.TopP] = params.TopP
+	}
+	return result
+}
+
+// Стоп-последовательности из конфигурации: через запятую, \n и \t - перевод строки и табуляция
+func parseStopSequences(value string) []string {
+	unescape := strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+	var stop []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			stop = append(stop, unescape.Replace(s))
+		}
+	}
+	return stop
+}
+
+// Anthropic: новые модели Claude не принимают temperature вместе с top_p,
+// явно заданный top_p имеет приоритет над temperature
+func anthropicParamFields(config *Config, fields paramFields) paramFields {
+	if config.TopP > 0 {
+		fields.Temperature = ""
+	}
+	return fields
+}
+
+// DeepSeek: deepseek-reasoner игнорирует параметры выборки
+func deepseekParamFields(config *Config, fields paramFields) paramFields {
+	if strings.Contains(strings.ToLower(config.ModelName), "reasoner") {
+		fields.Temperature = ""
+		fields.TopP = ""
+	}
+	return fields
+}
+
+// xAI: модели с рассуждениями (grok-3-mini, grok-4) не принимают стоп-последовательности
+func xaiParamFields(config *Config, fields paramFields) paramFields {
+	model := strings.ToLower(config.ModelName)
+	if strings.Contains(model, "grok-3-mini") || strings.Contains(model, "grok-4") {
+		fields.Stop = ""
+	}
+	return fields
+}
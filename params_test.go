@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequestParams(t *testing.T) {
+	params := generationParams{Temperature: 0.5, MaxTokens: 100, Stop: []string{"\n\n"}, TopP: 0.9}
+	tests := []struct {
+		provider string
+		model    string
+		want     map[string]interface{}
+	}{
+		{"openai", "gpt-4o", map[string]interface{}{"temperature": 0.5, "max_tokens": 100, "stop": []string{"\n\n"}, "top_p": 0.9}},
+		{"openai", "o3-mini", map[string]interface{}{"max_completion_tokens": 100}},
+		{"anthropic", "claude-sonnet-4", map[string]interface{}{"max_tokens": 100, "stop_sequences": []string{"\n\n"}, "top_p": 0.9}},
+		{"vertex", "gemini-2.0-flash", map[string]interface{}{"temperature": 0.5, "maxOutputTokens": 100, "stopSequences": []string{"\n\n"}, "topP": 0.9}},
+		{"deepseek", "deepseek-reasoner", map[string]interface{}{"max_tokens": 100, "stop": []string{"\n\n"}}},
+		{"xai", "grok-4", map[string]interface{}{"temperature": 0.5, "max_tokens": 100, "top_p": 0.9}},
+	}
+	for _, tt := range tests {
+		p, _ := providerByName(tt.provider)
+		config := &Config{ModelName: tt.model, TopP: params.TopP}
+		if got := p.requestParams(config, params); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("requestParams(%s, %s) = %v, ожидалось %v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+
+	// Незаданные stop и top_p не отправляются
+	var generic *provider
+	got := generic.requestParams(&Config{}, generationParams{Temperature: 0.7, MaxTokens: 10})
+	if want := map[string]interface{}{"temperature": 0.7, "max_tokens": 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("requestParams() = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestParseStopSequences(t *testing.T) {
+	got := parseStopSequences(` \n\n, ###,, END `)
+	if want := []string{"\n\n", "###", "END"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStopSequences() = %q, ожидалось %q", got, want)
+	}
+	if parseStopSequences("") != nil {
+		t.Error("Пустое значение не должно давать стоп-последовательностей")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path"
+	"runtime"
+	"strings"
+)
+
+// Префиксы путей Windows без ограничения длины (\\?\C:\... и \\?\UNC\server\share\...)
+const (
+	longPathPrefix    = `\\?\`
+	longUNCPathPrefix = `\\?\UNC\`
+)
+
+// Сравнение путей без учета регистра (файловые системы Windows регистронезависимы)
+var caseInsensitivePaths = runtime.GOOS == "windows"
+
+// Разделитель пути любой из платформ
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// Удаление префикса \\?\ длинного пути; для длинных абсолютных путей пакет os
+// добавляет его сам, а без префикса пути сравниваются и обрезаются единообразно
+func stripLongPathPrefix(p string) string {
+	if rest, ok := strings.CutPrefix(p, longUNCPathPrefix); ok {
+		return `\\` + rest
+	}
+	return strings.TrimPrefix(p, longPathPrefix)
+}
+
+// Проверка наличия имени диска (C:) или UNC префикса (\\server\share) в пути
+func hasVolume(p string) bool {
+	if len(p) >= 2 && p[1] == ':' && ('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z') {
+		return true
+	}
+	return strings.HasPrefix(p, `\\`) || strings.HasPrefix(p, "//")
+}
+
+// Нормализация относительного пути для хранения в конфигурации и каталоге
+// состояния: прямые слеши на всех платформах, без "./" и повторных разделителей
+func normalizeRelPath(p string) string {
+	p = strings.ReplaceAll(strings.TrimSpace(p), `\`, "/")
+	if p == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean(p), "./")
+}
+
+// Ключ пути для поиска в множествах с учетом регистронезависимости платформы
+func pathKey(p string) string {
+	p = normalizeRelPath(p)
+	if caseInsensitivePaths {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
+// Сравнение относительных путей независимо от разделителей (и регистра в Windows)
+func samePath(a, b string) bool {
+	return pathKey(a) == pathKey(b)
+}
+
+// Проверка, что относительный путь не выходит за пределы базовой директории:
+// не абсолютный, без имени диска или UNC префикса и без компонентов ".."
+func isRelPathSafe(p string) bool {
+	if p == "" || hasVolume(p) || isPathSeparator(rune(p[0])) {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(p, isPathSeparator) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestNormalizeRelPath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{`docs\guide\intro.md`, "docs/guide/intro.md"},
+		{"./docs//intro.md", "docs/intro.md"},
+		{` notes\a.md `, "notes/a.md"},
+		{"a.md", "a.md"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeRelPath(tt.path); got != tt.want {
+			t.Errorf("normalizeRelPath(%q) = %q, ожидалось %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	old := caseInsensitivePaths
+	defer func() { caseInsensitivePaths = old }()
+
+	caseInsensitivePaths = false
+	if !samePath(`docs\a.md`, "docs/a.md") {
+		t.Error("Пути с разными разделителями должны совпадать")
+	}
+	if samePath("Docs/A.md", "docs/a.md") {
+		t.Error("Без регистронезависимости пути в разном регистре не должны совпадать")
+	}
+
+	caseInsensitivePaths = true
+	if !samePath(`Docs\A.md`, "docs/a.md") {
+		t.Error("В Windows пути в разном регистре должны совпадать")
+	}
+}
+
+func TestIsRelPathSafe(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"docs/a.md", true},
+		{`docs\a.md`, true},
+		{"notes..md", true},
+		{"../a.md", false},
+		{`docs\..\..\a.md`, false},
+		{"/etc/passwd", false},
+		{`C:\Windows\a.md`, false},
+		{"C:a.md", false},
+		{`\\server\share\a.md`, false},
+		{`\\?\C:\a.md`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isRelPathSafe(tt.path); got != tt.want {
+			t.Errorf("isRelPathSafe(%q) = %v, ожидалось %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStripLongPathPrefix(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{`\\?\C:\docs`, `C:\docs`},
+		{`\\?\UNC\server\share\docs`, `\\server\share\docs`},
+		{`C:\docs`, `C:\docs`},
+		{"/home/docs", "/home/docs"},
+	}
+	for _, tt := range tests {
+		if got := stripLongPathPrefix(tt.path); got != tt.want {
+			t.Errorf("stripLongPathPrefix(%q) = %q, ожидалось %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExcludedFilesPathForms(t *testing.T) {
+	old := caseInsensitivePaths
+	caseInsensitivePaths = true
+	defer func() { caseInsensitivePaths = old }()
+
+	configPath := filepath.Join(t.TempDir(), "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = Docs\\Old.md\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	// Путь в другом регистре и с другими разделителями считается уже добавленным
+	if err := addToExcludedFiles(configPath, "docs/old.md"); err != nil {
+		t.Fatalf("addToExcludedFiles() вернул ошибку: %v", err)
+	}
+	if err := addToExcludedFiles(configPath, `guides\intro.md`); err != nil {
+		t.Fatalf("addToExcludedFiles() вернул ошибку: %v", err)
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Ошибка чтения конфигурации: %v", err)
+	}
+	if got := cfg.Section("EXCLUSIONS").Key("excluded_files").String(); got != `Docs\Old.md, guides/intro.md` {
+		t.Errorf("Неожиданный список исключений: %q", got)
+	}
+
+	config := &Config{ExcludedFiles: []string{`Docs\Old.md`, "guides/intro.md"}}
+	if !isExcluded(config, "docs/old.md") || !isExcluded(config, `GUIDES\intro.md`) {
+		t.Error("isExcluded() должен учитывать разные формы пути")
+	}
+
+	if err := removeFromExcludedFiles(configPath, "DOCS/OLD.MD"); err != nil {
+		t.Fatalf("removeFromExcludedFiles() вернул ошибку: %v", err)
+	}
+	cfg, err = ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Ошибка чтения конфигурации: %v", err)
+	}
+	if got := cfg.Section("EXCLUSIONS").Key("excluded_files").String(); got != "guides/intro.md" {
+		t.Errorf("Неожиданный список исключений после удаления: %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+)
+
+// Шлюз приостановки отправки новых файлов в обработку
+type pauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+// Создание нового шлюза приостановки
+func newPauseGate() *pauseGate {
+	g := &pauseGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Приостановка отправки новых файлов
+func (g *pauseGate) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.paused = true
+		infof("Обработка приостановлена: новые файлы не будут отправляться до возобновления")
+	}
+}
+
+// Возобновление отправки новых файлов
+func (g *pauseGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		infof("Обработка возобновлена")
+		g.cond.Broadcast()
+	}
+}
+
+// Проверка, приостановлена ли обработка
+func (g *pauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Ожидание снятия паузы перед отправкой очередного файла
+func (g *pauseGate) Wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.paused {
+		g.cond.Wait()
+	}
+}
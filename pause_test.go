@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := newPauseGate()
+
+	// Без паузы ожидание не блокируется
+	gate.Wait()
+
+	gate.Pause()
+	if !gate.Paused() {
+		t.Fatal("Ожидалось состояние паузы после Pause()")
+	}
+
+	released := make(chan struct{})
+	go func() {
+		gate.Wait()
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("Wait() не должен возвращаться во время паузы")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.Resume()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("Wait() не разблокировался после Resume()")
+	}
+
+	if gate.Paused() {
+		t.Error("Ожидалось снятие паузы после Resume()")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Маркеры ссылки на исходный документ PDF
+const (
+	SourceStartMarker = "<!-- rich:source -->"
+	SourceEndMarker   = "<!-- rich:source-end -->"
+)
+
+// Документы PDF из секции [PDF]
+type PDFConfig struct {
+	Enabled bool
+	// Максимум страниц, из которых извлекается текст (0 - все)
+	MaxPages int
+	// Минимум символов текстового слоя; документ с меньшим текстом считается сканом
+	MinTextChars int
+	// Подпись ссылки на исходный документ
+	SourceLabel string
+}
+
+// Чтение секции [PDF]
+func loadPDFConfig(section *ini.Section) (PDFConfig, error) {
+	pdf := PDFConfig{
+		Enabled:      section.Key("enabled").MustBool(false),
+		MaxPages:     section.Key("max_pages").MustInt(0),
+		MinTextChars: section.Key("min_text_chars").MustInt(20),
+		SourceLabel:  section.Key("source_label").MustString("Источник"),
+	}
+	if pdf.MaxPages < 0 {
+		return pdf, errorf("max_pages в секции [PDF] не может быть отрицательным: %d", pdf.MaxPages)
+	}
+	if pdf.MinTextChars < 0 {
+		return pdf, errorf("min_text_chars в секции [PDF] не может быть отрицательным: %d", pdf.MinTextChars)
+	}
+	return pdf, nil
+}
+
+// Файл PDF (по расширению)
+func isPDFPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
+
+// Путь результата в markdown для документа другого формата
+func markdownOutputPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".md"
+}
+
+// Ссылка на исходный документ относительно результата
+func renderSourceLink(label, outputPath, sourcePath string) string {
+	return label + ": " + relativeLink(outputPath, sourcePath)
+}
+
+// Ссылка markdown на файл относительно результата
+func relativeLink(outputPath, sourcePath string) string {
+	target := filepath.Base(sourcePath)
+	outputAbs, err1 := filepath.Abs(filepath.Dir(outputPath))
+	sourceAbs, err2 := filepath.Abs(sourcePath)
+	if err1 == nil && err2 == nil {
+		if rel, err := filepath.Rel(outputAbs, sourceAbs); err == nil {
+			target = rel
+		}
+	}
+	return fmt.Sprintf("[%s](%s)", escapeLinkText(filepath.Base(sourcePath)), escapeLinkPath(filepath.ToSlash(target)))
+}
+
+// Удаление ранее добавленной ссылки на исходный документ
+func stripSourceLink(text string) string {
+	start := strings.LastIndex(text, SourceStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], SourceEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(SourceEndMarker)
+	return strings.TrimRight(text[:start], "\n") + text[end:]
+}
+
+// Добавление ссылки на исходный документ в конец документа (с заменой предыдущей)
+func withSourceLink(text, link string) string {
+	text = strings.TrimRight(stripSourceLink(text), "\n")
+	return text + "\n\n" + SourceStartMarker + "\n" + strings.TrimSpace(link) + "\n" + SourceEndMarker + "\n"
+}
@@ -0,0 +1,880 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Извлечение текстового слоя PDF без внешних программ: разбор объектов (в том
+// числе потоков объектов PDF 1.5), распаковка FlateDecode, текстовые операторы
+// страниц и таблицы ToUnicode шрифтов. Изображения и сканы без распознанного
+// текста дают пустой результат
+
+// Максимальный размер распакованного потока
+const pdfMaxStreamBytes = 64 << 20
+
+// Заголовок объекта "12 0 obj"
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+// Значения PDF
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfRef     int
+	pdfDict    map[string]any
+	pdfStream  struct {
+		Dict pdfDict
+		Data []byte
+	}
+)
+
+// Лексический разбор PDF: значения, операторы и разделители
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// Вложенность массивов и словарей (ограничена для поврежденных файлов)
+	depth int
+}
+
+// Максимальная вложенность массивов и словарей
+const pdfMaxDepth = 64
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// Пропуск пробелов и комментариев
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// Следующая лексема: число (float64), имя, строка ([]byte) или ключевое слово
+// (операторы, true/false/null и разделители [ ] << >>); false - конец данных
+func (l *pdfLexer) token() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), true
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return pdfKeyword("<<"), true
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), true
+	case c == '<':
+		return l.hexString(), true
+	case c == '/':
+		return l.name(), true
+	case c == '[' || c == ']' || c == '{' || c == '}' || c == ')' || c == '>':
+		l.pos++
+		return pdfKeyword(c), true
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if n, ok := parsePDFNumber(word); ok {
+		return n, true
+	}
+	return pdfKeyword(word), true
+}
+
+// Число PDF (целое или вещественное, допускается ".5" и "-.5")
+func parsePDFNumber(word string) (float64, bool) {
+	if word == "" {
+		return 0, false
+	}
+	value, scale, sign, seenDigit, seenDot := 0.0, 1.0, 1.0, false, false
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case (c == '-' || c == '+') && i == 0:
+			if c == '-' {
+				sign = -1
+			}
+		case c == '.' && !seenDot:
+			seenDot = true
+		case c >= '0' && c <= '9':
+			seenDigit = true
+			if seenDot {
+				scale /= 10
+				value += float64(c-'0') * scale
+			} else {
+				value = value*10 + float64(c-'0')
+			}
+		default:
+			return 0, false
+		}
+	}
+	return sign * value, seenDigit
+}
+
+// Строка в круглых скобках с экранированием и вложенными скобками
+func (l *pdfLexer) literalString() []byte {
+	l.pos++
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// Строка в шестнадцатеричной записи <48656c6c6f>
+func (l *pdfLexer) hexString() []byte {
+	l.pos++
+	var b []byte
+	high, odd := byte(0), false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := hexDigit(c)
+		if !ok {
+			continue
+		}
+		if odd {
+			b = append(b, high<<4|v)
+		} else {
+			high = v
+		}
+		odd = !odd
+	}
+	if odd {
+		b = append(b, high<<4)
+	}
+	return b
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Имя /Name с экранированием #xx
+func (l *pdfLexer) name() pdfName {
+	l.pos++
+	var b []byte
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '#' && l.pos+1 < len(l.data) {
+			h, ok1 := hexDigit(l.data[l.pos])
+			lo, ok2 := hexDigit(l.data[l.pos+1])
+			if ok1 && ok2 {
+				c = h<<4 | lo
+				l.pos += 2
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfName(b)
+}
+
+// Значение: массив, словарь, ссылка "12 0 R" или лексема
+func (l *pdfLexer) value() (any, bool) {
+	tok, ok := l.token()
+	if !ok {
+		return nil, false
+	}
+	switch t := tok.(type) {
+	case pdfKeyword:
+		if (t == "[" || t == "<<") && l.depth >= pdfMaxDepth {
+			return tok, true
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+		switch t {
+		case "[":
+			var arr []any
+			for {
+				v, ok := l.value()
+				if !ok || v == pdfKeyword("]") {
+					return arr, true
+				}
+				arr = append(arr, v)
+			}
+		case "<<":
+			dict := pdfDict{}
+			for {
+				k, ok := l.value()
+				if !ok || k == pdfKeyword(">>") {
+					return dict, true
+				}
+				key, isName := k.(pdfName)
+				v, ok := l.value()
+				if !ok {
+					return dict, true
+				}
+				if isName {
+					dict[string(key)] = v
+				}
+			}
+		}
+	case float64:
+		if t == math.Trunc(t) && t >= 0 {
+			save := l.pos
+			if gen, ok := l.token(); ok {
+				if g, isNum := gen.(float64); isNum && g == math.Trunc(g) {
+					if r, ok := l.token(); ok && r == pdfKeyword("R") {
+						return pdfRef(t), true
+					}
+				}
+			}
+			l.pos = save
+		}
+	}
+	return tok, true
+}
+
+// Разобранный PDF документ
+type pdfDocument struct {
+	objects map[int]any
+}
+
+// Чтение объектов документа; структура xref не используется, поэтому читаются
+// и файлы с поврежденной таблицей ссылок
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errorf("файл не является документом PDF")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errorf("зашифрованные документы PDF не поддерживаются")
+	}
+	doc := &pdfDocument{objects: make(map[int]any)}
+	end := 0
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		// Совпадение внутри данных предыдущего потока
+		if m[0] < end {
+			continue
+		}
+		num, _ := parsePDFNumber(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		v, ok := l.value()
+		if !ok {
+			break
+		}
+		end = l.pos
+		if dict, isDict := v.(pdfDict); isDict {
+			l.skipSpace()
+			if bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+				stream, streamEnd := readPDFStream(data, l.pos+len("stream"), dict)
+				v, end = stream, streamEnd
+			}
+		}
+		doc.objects[int(num)] = v
+	}
+
+	// Объекты, упакованные в потоки объектов (PDF 1.5 и новее)
+	for _, v := range doc.objects {
+		if s, ok := v.(pdfStream); ok && s.Dict["Type"] == pdfName("ObjStm") {
+			doc.unpackObjectStream(s)
+		}
+	}
+	return doc, nil
+}
+
+// Данные потока после ключевого слова stream; возвращает поток и конец данных
+func readPDFStream(data []byte, pos int, dict pdfDict) (pdfStream, int) {
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+	// Длина задана прямо и подтверждается ключевым словом endstream
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && pos+int(n) <= len(data) {
+		end := pos + int(n)
+		rest := bytes.TrimLeft(data[end:min(end+32, len(data))], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return pdfStream{Dict: dict, Data: data[pos:end]}, end
+		}
+	}
+	end := bytes.Index(data[pos:], []byte("endstream"))
+	if end < 0 {
+		return pdfStream{Dict: dict, Data: data[pos:]}, len(data)
+	}
+	body := bytes.TrimRight(data[pos:pos+end], "\r\n")
+	return pdfStream{Dict: dict, Data: body}, pos + end
+}
+
+// Объекты из потока объектов; объекты вне потоков имеют приоритет
+func (doc *pdfDocument) unpackObjectStream(s pdfStream) {
+	data, err := decodePDFStream(s)
+	if err != nil {
+		return
+	}
+	n, _ := s.Dict["N"].(float64)
+	first, _ := s.Dict["First"].(float64)
+	if int(first) > len(data) {
+		return
+	}
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		numTok, ok1 := header.token()
+		offTok, ok2 := header.token()
+		num, isNum := numTok.(float64)
+		off, isOff := offTok.(float64)
+		if !ok1 || !ok2 || !isNum || !isOff || int(first+off) >= len(data) {
+			return
+		}
+		if _, exists := doc.objects[int(num)]; exists {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: int(first + off)}
+		if v, ok := l.value(); ok {
+			doc.objects[int(num)] = v
+		}
+	}
+}
+
+// Распаковка потока; поддерживается FlateDecode
+func decodePDFStream(s pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := s.Dict["Filter"].(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	data := s.Data
+	for _, f := range filters {
+		switch f {
+		case pdfName("FlateDecode"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Поврежденный конец потока не мешает прочитать начало
+			decoded, err := io.ReadAll(io.LimitReader(r, pdfMaxStreamBytes))
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, errorf("фильтр %v не поддерживается", f)
+		}
+	}
+	return data, nil
+}
+
+// Значение по ссылке (с ограничением глубины цепочки ссылок)
+func (doc *pdfDocument) resolve(v any) any {
+	for i := 0; i < 8; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = doc.objects[int(ref)]
+	}
+	return nil
+}
+
+// Словарь по значению или ссылке (словарь потока для потоков)
+func (doc *pdfDocument) dict(v any) pdfDict {
+	switch d := doc.resolve(v).(type) {
+	case pdfDict:
+		return d
+	case pdfStream:
+		return d.Dict
+	}
+	return nil
+}
+
+// Страница и унаследованные ресурсы
+type pdfPage struct {
+	Dict      pdfDict
+	Resources pdfDict
+}
+
+// Страницы в порядке дерева страниц; без каталога - в порядке номеров объектов
+func (doc *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(v any, resources pdfDict)
+	walk = func(v any, resources pdfDict) {
+		if ref, ok := v.(pdfRef); ok {
+			if visited[int(ref)] {
+				return
+			}
+			visited[int(ref)] = true
+		}
+		node := doc.dict(v)
+		if node == nil {
+			return
+		}
+		if r := doc.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{Dict: node, Resources: resources})
+			return
+		}
+		kids, _ := doc.resolve(node["Kids"]).([]any)
+		for _, kid := range kids {
+			walk(kid, resources)
+		}
+	}
+	nums := make([]int, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if d, ok := doc.objects[num].(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+			walk(d["Pages"], nil)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+	for _, num := range nums {
+		if d, ok := doc.objects[num].(pdfDict); ok && d["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{Dict: d, Resources: doc.dict(d["Resources"])})
+		}
+	}
+	return pages
+}
+
+// Содержимое страницы: один поток или массив потоков
+func (doc *pdfDocument) pageContent(page pdfPage) []byte {
+	var parts []any
+	switch c := doc.resolve(page.Dict["Contents"]).(type) {
+	case pdfStream:
+		parts = []any{c}
+	case []any:
+		parts = c
+	}
+	var b bytes.Buffer
+	for _, p := range parts {
+		s, ok := doc.resolve(p).(pdfStream)
+		if !ok {
+			continue
+		}
+		if data, err := decodePDFStream(s); err == nil {
+			b.Write(data)
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// Таблицы ToUnicode шрифтов страницы по именам ресурсов (nil - таблицы нет)
+func (doc *pdfDocument) pageFonts(page pdfPage) map[string]*pdfCMap {
+	fonts := make(map[string]*pdfCMap)
+	for name, ref := range doc.dict(page.Resources["Font"]) {
+		font := doc.dict(ref)
+		if s, ok := doc.resolve(font["ToUnicode"]).(pdfStream); ok {
+			if data, err := decodePDFStream(s); err == nil {
+				fonts[name] = parseCMap(data)
+			}
+		}
+		if _, ok := fonts[name]; !ok {
+			fonts[name] = nil
+		}
+	}
+	return fonts
+}
+
+// Диапазон bfrange таблицы ToUnicode
+type pdfCMapRange struct {
+	lo, hi uint32
+	n      int
+	// Начальный символ диапазона или список символов для каждого кода
+	base []rune
+	list []string
+}
+
+// Таблица ToUnicode: коды символов шрифта в текст
+type pdfCMap struct {
+	lengths []int
+	chars   map[string]string
+	ranges  []pdfCMapRange
+}
+
+// Разбор таблицы ToUnicode (beginbfchar, beginbfrange, begincodespacerange)
+func parseCMap(data []byte) *pdfCMap {
+	cm := &pdfCMap{chars: make(map[string]string)}
+	seen := make(map[int]bool)
+	addLength := func(n int) {
+		if n > 0 && n <= 4 && !seen[n] {
+			seen[n] = true
+			cm.lengths = append(cm.lengths, n)
+		}
+	}
+	l := &pdfLexer{data: data}
+	mode := ""
+	var operands []any
+	for {
+		v, ok := l.value()
+		if !ok {
+			break
+		}
+		kw, isKeyword := v.(pdfKeyword)
+		if !isKeyword {
+			operands = append(operands, v)
+			continue
+		}
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			mode = string(kw)
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].([]byte); ok {
+					addLength(len(lo))
+				}
+			}
+			mode = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].([]byte)
+				dst, ok2 := operands[i+1].([]byte)
+				if ok1 && ok2 {
+					cm.chars[string(src)] = decodeUTF16BE(dst)
+				}
+			}
+			mode = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].([]byte)
+				hi, ok2 := operands[i+1].([]byte)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) > 4 {
+					continue
+				}
+				r := pdfCMapRange{lo: codeValue(lo), hi: codeValue(hi), n: len(lo)}
+				switch dst := operands[i+2].(type) {
+				case []byte:
+					r.base = []rune(decodeUTF16BE(dst))
+				case []any:
+					for _, d := range dst {
+						s, _ := d.([]byte)
+						r.list = append(r.list, decodeUTF16BE(s))
+					}
+				}
+				cm.ranges = append(cm.ranges, r)
+			}
+			mode = ""
+		}
+		if mode == "" || kw == pdfKeyword(mode) {
+			operands = operands[:0]
+		}
+	}
+	// Без codespacerange длина кода берется из таблицы
+	if len(cm.lengths) == 0 {
+		for src := range cm.chars {
+			addLength(len(src))
+		}
+		for _, r := range cm.ranges {
+			addLength(r.n)
+		}
+	}
+	if len(cm.lengths) == 0 {
+		addLength(1)
+	}
+	sort.Ints(cm.lengths)
+	return cm
+}
+
+// Числовое значение кода символа
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// Текст в кодировке UTF-16BE (строки ToUnicode и строки с BOM FE FF)
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// Текст по кодам символов шрифта
+func (cm *pdfCMap) decode(b []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(b); {
+		matched := false
+		for _, n := range cm.lengths {
+			if i+n > len(b) {
+				continue
+			}
+			if s, ok := cm.lookup(b[i : i+n]); ok {
+				out.WriteString(s)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i += cm.lengths[0]
+		}
+	}
+	return out.String()
+}
+
+// Символы одного кода
+func (cm *pdfCMap) lookup(code []byte) (string, bool) {
+	if s, ok := cm.chars[string(code)]; ok {
+		return s, true
+	}
+	v := codeValue(code)
+	for _, r := range cm.ranges {
+		if r.n != len(code) || v < r.lo || v > r.hi {
+			continue
+		}
+		offset := v - r.lo
+		if r.list != nil {
+			if int(offset) < len(r.list) {
+				return r.list[offset], true
+			}
+			return "", false
+		}
+		if len(r.base) == 0 {
+			return "", false
+		}
+		runes := append([]rune(nil), r.base...)
+		runes[len(runes)-1] += rune(offset)
+		return string(runes), true
+	}
+	return "", false
+}
+
+// Символы Windows-1252 в диапазоне 0x80-0x9F (стандартная кодировка WinAnsi)
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x89: '‰', 0x8B: '‹',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x99: '™', 0x9B: '›',
+}
+
+// Текст строки шрифта без таблицы ToUnicode: UTF-16 с BOM или WinAnsi
+func decodePDFText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return decodeUTF16BE(b[2:])
+	}
+	var out strings.Builder
+	for _, c := range b {
+		switch {
+		case c >= 0x80 && c < 0xA0:
+			if r, ok := winAnsiHigh[c]; ok {
+				out.WriteRune(r)
+			}
+		default:
+			out.WriteRune(rune(c))
+		}
+	}
+	return out.String()
+}
+
+// Текст страницы по текстовым операторам потока содержимого
+func pageText(content []byte, fonts map[string]*pdfCMap) string {
+	var out bytes.Buffer
+	newline := func() {
+		if b := out.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+	var font *pdfCMap
+	show := func(v any) {
+		b, ok := v.([]byte)
+		if !ok {
+			return
+		}
+		if font != nil {
+			out.WriteString(font.decode(b))
+		} else {
+			out.WriteString(decodePDFText(b))
+		}
+	}
+	num := func(v any) float64 {
+		n, _ := v.(float64)
+		return n
+	}
+
+	l := &pdfLexer{data: content}
+	var operands []any
+	lineY, haveY := 0.0, false
+	for {
+		v, ok := l.value()
+		if !ok {
+			break
+		}
+		op, isOp := v.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			arr, _ := operands[len(operands)-1].([]any)
+			for _, item := range arr {
+				// Большой отступ между фрагментами - пробел между словами
+				if n, isNum := item.(float64); isNum && n < -200 {
+					out.WriteByte(' ')
+					continue
+				}
+				show(item)
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 && num(operands[len(operands)-1]) != 0 {
+				newline()
+				lineY += num(operands[len(operands)-1])
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				y := num(operands[len(operands)-1])
+				if haveY && math.Abs(y-lineY) > 1 {
+					newline()
+				} else if haveY {
+					out.WriteByte(' ')
+				}
+				lineY, haveY = y, true
+			}
+		case "BI":
+			// Встроенное изображение: данные до EI пропускаются
+			if i := bytes.Index(content[l.pos:], []byte("EI")); i >= 0 {
+				l.pos += i + 2
+			} else {
+				l.pos = len(content)
+			}
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// Текстовый слой документа PDF: страницы через пустую строку, строки страницы
+// без лишних пробелов. maxPages > 0 ограничивает количество страниц; возвращает
+// текст и количество страниц документа
+func extractPDFText(data []byte, maxPages int) (string, int, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return "", 0, err
+	}
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return "", 0, errorf("в документе PDF не найдены страницы")
+	}
+	var texts []string
+	for i, page := range pages {
+		if maxPages > 0 && i >= maxPages {
+			break
+		}
+		text := pageText(doc.pageContent(page), doc.pageFonts(page))
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			texts = append(texts, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(texts, "\n\n"), len(pages), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Документ PDF из тел объектов (номера объектов с 1); таблица xref не нужна
+func buildTestPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	for i, body := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+// Поток объекта PDF, при compress - сжатый FlateDecode
+func testPDFStream(dict string, data string, compress bool) string {
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		_, _ = w.Write([]byte(data))
+		_ = w.Close()
+		data = z.String()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func TestExtractPDFText(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+1 beginbfchar
+<0001> <041F>
+endbfchar
+1 beginbfrange
+<0010> <002F> <0430>
+endbfrange
+endcmap
+end end`
+	objStmHeader := "10 0 "
+	objStm := objStmHeader + "<< /Type /Font /Subtype /Type0 /BaseFont /Test /ToUnicode 8 0 R >>"
+
+	data := buildTestPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		// Страницы в дереве идут в обратном порядке номеров объектов
+		"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 10 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [6 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		testPDFStream("", "BT /F1 12 Tf 72 700 Td (Meeting \\(draft\\)) Tj 0 -14 Td (Budget \\226 Q3) Tj ET", false),
+		testPDFStream("", "BT /F2 12 Tf 1 0 0 1 72 650 Tm <000100200018001200150022> Tj 1 0 0 1 72 630 Tm /F1 12 Tf [(Hello) -300 (there)] TJ ET", true),
+		testPDFStream("", cmap, true),
+		testPDFStream(fmt.Sprintf("/Type /ObjStm /N 1 /First %d", len(objStmHeader)), objStm, true),
+	)
+	text, pages, err := extractPDFText(data, 0)
+	if err != nil {
+		t.Fatalf("extractPDFText() вернул ошибку: %v", err)
+	}
+	want := "Meeting (draft)\nBudget – Q3\n\nПривет\nHello there"
+	if pages != 2 || text != want {
+		t.Errorf("текст (страниц %d):\n%q\nожидалось\n%q", pages, text, want)
+	}
+	if text, _, _ := extractPDFText(data, 1); text != "Meeting (draft)\nBudget – Q3" {
+		t.Errorf("текст первой страницы: %q", text)
+	}
+
+	if _, _, err := extractPDFText([]byte("# не PDF"), 0); err == nil {
+		t.Error("ожидалась ошибка для файла не PDF")
+	}
+}
+
+func TestPDFInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	handout := buildTestPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		testPDFStream("", "BT /F1 12 Tf 72 700 Td (Agenda: release plan and budget review) Tj ET", true),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	// Скан: страница содержит только изображение
+	scan := buildTestPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		testPDFStream("", "q 612 0 0 792 0 0 cm /Im1 Do Q", false),
+	)
+	for name, data := range map[string][]byte{"handout.pdf": handout, "scan.pdf": scan} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# План релиза\n\nОбогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(tmpDir, "report.json")
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, ReportFile: reportPath,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		PDF: PDFConfig{Enabled: true, MinTextChars: 20, SourceLabel: "Источник"}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "handout.md"))
+	if err != nil {
+		t.Fatalf("результат handout.md не создан: %v", err)
+	}
+	doc := string(data)
+	link := SourceStartMarker + "\nИсточник: [handout.pdf](../input/handout.pdf)\n" + SourceEndMarker
+	if !strings.Contains(doc, link) || !strings.Contains(doc, "```old\nAgenda: release plan and budget review\n```") {
+		t.Errorf("результат документа PDF:\n%s", doc)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "scan.md")); err == nil {
+		t.Error("для скана без текстового слоя результат не должен создаваться")
+	}
+	report, _ := os.ReadFile(reportPath)
+	if !strings.Contains(string(report), StatusSkippedNoText) {
+		t.Errorf("в отчете нет статуса %q:\n%s", StatusSkippedNoText, report)
+	}
+}
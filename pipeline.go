@@ -0,0 +1,202 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Размер очереди записи по умолчанию: сколько подготовленных результатов может
+// ждать записи, пока запросы к API продолжаются
+const defaultWriteQueue = 16
+
+// Наибольшее число файлов, обрабатываемых одновременно (workers)
+const maxWorkers = 64
+
+// Файл, прошедший этап запросов к API
+type pipelineItem struct {
+	// Путь файла в общем состоянии запуска, входной и выходной пути
+	Key        string
+	Path       string
+	OutputPath string
+	Result     *fileResult
+	// Подготовленная запись (nil для пропущенных и необработанных файлов)
+	Pending *pendingWrite
+	// Ошибка подготовки, а после этапа записи - итоговая ошибка обработки файла
+	Err error
+	// Время этапа запросов к API для файла
+	Prepared time.Duration
+}
+
+// Метрики этапов конвейера обработки
+type pipelineStats struct {
+	// Размер очереди записи и наибольшее число ожидавших записи файлов
+	QueueSize int `json:"queue_size"`
+	MaxQueued int `json:"max_queued"`
+	// Файлы, прошедшие конвейер, и записанные выходные файлы
+	Files  int `json:"files"`
+	Writes int `json:"writes"`
+	// Время работы этапа запросов к API и его ожидания из-за заполненной очереди записи
+	// (медленный диск)
+	APISeconds     float64 `json:"api_seconds"`
+	BlockedSeconds float64 `json:"api_blocked_seconds"`
+	// Время работы этапа записи и его простоя в ожидании результатов (медленный API)
+	WriteSeconds float64 `json:"write_seconds"`
+	IdleSeconds  float64 `json:"writer_idle_seconds"`
+}
+
+// Конвейер обработки: этап запросов к API (вызывающая горутина) передает результаты
+// этапу записи через ограниченную очередь, так что медленный диск (сетевой том) не
+// задерживает запросы, а медленный API - запись. Итог каждого файла передается
+// функции done в порядке обработки из горутины записи
+type outputPipeline struct {
+	configPath string
+	sess       *session
+	done       func(*pipelineItem)
+	// Очередь записи (nil - запись в вызывающей горутине)
+	queue    chan *pipelineItem
+	finished chan struct{}
+	stats    pipelineStats
+}
+
+// Создание конвейера с очередью записи заданного размера (0 - без отдельного этапа записи)
+func newOutputPipeline(queueSize int, configPath string, sess *session, done func(*pipelineItem)) *outputPipeline {
+	p := &outputPipeline{configPath: configPath, sess: sess, done: done}
+	p.stats.QueueSize = queueSize
+	if queueSize > 0 {
+		p.queue = make(chan *pipelineItem, queueSize)
+		p.finished = make(chan struct{})
+		go p.run()
+	}
+	return p
+}
+
+// Передача файла этапу записи; при заполненной очереди ждет освобождения места
+func (p *outputPipeline) Submit(item *pipelineItem) {
+	p.stats.Files++
+	p.stats.APISeconds += item.Prepared.Seconds()
+	if p.queue == nil {
+		p.write(item)
+		return
+	}
+	select {
+	case p.queue <- item:
+	default:
+		blocked := time.Now()
+		p.queue <- item
+		p.stats.BlockedSeconds += time.Since(blocked).Seconds()
+	}
+	// Очередь читается горутиной записи, поэтому длина - оценка сверху
+	p.stats.MaxQueued = max(p.stats.MaxQueued, len(p.queue))
+}
+
+// Этап записи
+func (p *outputPipeline) run() {
+	defer close(p.finished)
+	for {
+		idle := time.Now()
+		item, ok := <-p.queue
+		if !ok {
+			return
+		}
+		p.stats.IdleSeconds += time.Since(idle).Seconds()
+		p.write(item)
+	}
+}
+
+// Запись подготовленного результата и передача итога файла
+func (p *outputPipeline) write(item *pipelineItem) {
+	if item.Err == nil && item.Pending != nil {
+		started := time.Now()
+		item.Err = item.Pending.Write(p.configPath, p.sess)
+		p.stats.Writes++
+		p.stats.WriteSeconds += time.Since(started).Seconds()
+		item.Result.Duration = item.Prepared + time.Since(started)
+	} else {
+		item.Result.Duration = item.Prepared
+	}
+	p.done(item)
+}
+
+// Ожидание записи всех переданных файлов; после Close конвейер не используется
+func (p *outputPipeline) Close() pipelineStats {
+	if p.queue != nil {
+		close(p.queue)
+		<-p.finished
+		p.queue = nil
+	}
+	return p.stats
+}
+
+// Обработчики файлов: одновременно готовится не больше size файлов, запросы к API
+// при этом проходят через общий ограничитель частоты. С одним обработчиком файл
+// обрабатывается в вызывающей горутине, как без пула
+type filePool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+	// Файлы в обработке; finished сообщает о завершении каждого из них
+	mu       sync.Mutex
+	finished *sync.Cond
+	running  int
+}
+
+func newFilePool(size int) *filePool {
+	p := &filePool{}
+	p.finished = sync.NewCond(&p.mu)
+	if size > 1 {
+		p.slots = make(chan struct{}, size)
+	}
+	return p
+}
+
+// Запуск обработки файла; если все обработчики заняты, ждет освобождения одного из них
+func (p *filePool) Go(fn func()) {
+	if p.slots == nil {
+		fn()
+		return
+	}
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	p.mu.Lock()
+	p.running++
+	p.mu.Unlock()
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.running--
+			p.finished.Broadcast()
+			p.mu.Unlock()
+			<-p.slots
+			p.wg.Done()
+		}()
+		fn()
+	}()
+}
+
+// Число файлов в обработке
+func (p *filePool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// Ожидание, пока в обработке останется меньше n файлов
+func (p *filePool) WaitBelow(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.running >= n {
+		p.finished.Wait()
+	}
+}
+
+// Ожидание свободного обработчика: следующий Go запустит файл сразу, поэтому
+// проверки перед его отправкой видят итоги всех завершенных файлов
+func (p *filePool) WaitIdle() {
+	if p.slots != nil {
+		p.WaitBelow(cap(p.slots))
+	}
+}
+
+// Ожидание обработки всех запущенных файлов
+func (p *filePool) Wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutputPipeline(t *testing.T) {
+	for _, queueSize := range []int{0, 2} {
+		tmpDir := t.TempDir()
+		configPath := filepath.Join(tmpDir, "test.cfg")
+		if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config := &Config{InputDir: tmpDir, OutputDir: tmpDir}
+
+		var done []string
+		p := newOutputPipeline(queueSize, configPath, newSession(NewRateLimiter(1000)), func(item *pipelineItem) {
+			status := item.Result.Status
+			if item.Err != nil {
+				status = item.Err.Error()
+			}
+			done = append(done, item.Key+" "+status)
+		})
+		var expected []string
+		for i := 0; i < 6; i++ {
+			key := fmt.Sprintf("n%d.md", i)
+			item := &pipelineItem{Key: key, OutputPath: filepath.Join(tmpDir, "out", key),
+				Result: &fileResult{Status: StatusFailed}, Prepared: time.Millisecond}
+			switch i {
+			case 2:
+				item.Result.Status = StatusSkippedTooSmall
+				expected = append(expected, key+" "+StatusSkippedTooSmall)
+			case 4:
+				item.Err = errors.New("ошибка API")
+				expected = append(expected, key+" ошибка API")
+			default:
+				item.Pending = &pendingWrite{config: config, relPath: key, outputPath: item.OutputPath,
+					content: []byte("# " + key), result: item.Result}
+				expected = append(expected, key+" "+StatusEnriched)
+			}
+			p.Submit(item)
+		}
+		stats := p.Close()
+
+		// Итоги передаются в порядке обработки, все записи выполнены к закрытию конвейера
+		if fmt.Sprint(done) != fmt.Sprint(expected) {
+			t.Errorf("queue=%d: итоги %v, ожидалось %v", queueSize, done, expected)
+		}
+		for _, key := range []string{"n0.md", "n5.md"} {
+			if data, err := os.ReadFile(filepath.Join(tmpDir, "out", key)); err != nil || string(data) != "# "+key {
+				t.Errorf("queue=%d: выходной файл %s: %q, %v", queueSize, key, data, err)
+			}
+		}
+		if stats.QueueSize != queueSize || stats.Files != 6 || stats.Writes != 4 || stats.APISeconds <= 0 {
+			t.Errorf("queue=%d: некорректные метрики конвейера: %+v", queueSize, stats)
+		}
+	}
+}
+
+// Файлы обрабатываются одновременно не больше чем workers, все результаты записываются
+func TestWorkersRun(t *testing.T) {
+	var active, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("n%02d.md", i)), []byte(fmt.Sprintf("# Заметка %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\nrequests_per_minute = 6000\n[PROCESSING]\nworkers = 3\n" +
+		"[STATE]\ndir = " + filepath.Join(tmpDir, "state") + "\n[EXCLUSIONS]\nexcluded_files =\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if got := peak.Load(); got < 2 || got > 3 {
+		t.Errorf("Одновременных запросов %d, ожидалось от 2 до 3", got)
+	}
+	for i := 0; i < 12; i++ {
+		data, err := os.ReadFile(filepath.Join(outputDir, fmt.Sprintf("n%02d.md", i)))
+		if err != nil || !strings.HasPrefix(string(data), "# Обогащено") {
+			t.Errorf("Результат n%02d.md: %q, %v", i, data, err)
+		}
+	}
+	config, err = loadConfig(configPath)
+	if err != nil || len(config.ExcludedFiles) != 12 {
+		t.Errorf("Все файлы должны попасть в список исключений: %v, %v", config.ExcludedFiles, err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(strings.Replace(cfg, "workers = 3", "workers = 0", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(configPath); err == nil || !strings.Contains(err.Error(), "workers") {
+		t.Errorf("workers = 0 должен быть ошибкой: %v", err)
+	}
+}
+
+// Лимиты запуска учитывают файлы в обработке: при нескольких обработчиках запросов
+// отправляется ровно столько, сколько допускает лимит
+func TestWorkersLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		fail   bool
+		config func(*Config)
+		want   int32
+	}{
+		{"max-files", false, func(c *Config) { c.MaxFiles = 2 }, 2},
+		{"fail-fast", true, func(c *Config) { c.OnError = failurePolicy{Mode: OnErrorFailFast} }, 1},
+		{"fail-after", true, func(c *Config) { c.OnError = failurePolicy{Mode: OnErrorFailAfter, After: 2} }, 2},
+		{"daily", false, func(c *Config) { c.DailyCap = DailyCapConfig{MaxRequests: 3} }, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				// Ответ с задержкой: файлы успевают отправиться одновременно
+				time.Sleep(30 * time.Millisecond)
+				if tt.fail {
+					http.Error(w, `{"error": "bad request"}`, http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			inputDir := filepath.Join(tmpDir, "input")
+			if err := os.MkdirAll(inputDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 8; i++ {
+				if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("note%d.md", i)), []byte(fmt.Sprintf("# Заметка %d", i)), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			configPath := filepath.Join(tmpDir, "test.cfg")
+			if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config := &Config{InputDir: inputDir, OutputDir: filepath.Join(tmpDir, "output"), StateDir: filepath.Join(tmpDir, ".rich"),
+				ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100, Workers: 4}
+			tt.config(config)
+
+			err := processDirectory(config, configPath)
+			var failed *filesFailedError
+			if tt.fail {
+				if !errors.As(err, &failed) || failed.Categories[ErrorProvider] != int(tt.want) {
+					t.Errorf("Ожидалось файлов с ошибками: %d, получено: %v", tt.want, err)
+				}
+			} else if err != nil {
+				t.Fatalf("processDirectory() вернул ошибку: %v", err)
+			}
+			if got := calls.Load(); got != tt.want {
+				t.Errorf("Запросов к API: %d, ожидалось %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gopkg.in/ini.v1"
+)
+
+// Действия политики содержимого
+const (
+	// Файл не отправляется во внешние API и пропускается
+	PolicyBlock = "block"
+	// Файл обрабатывается, совпадение выводится в журнал и отчет
+	PolicyFlag = "flag"
+)
+
+// Встроенные категории чувствительных данных
+var policyCategories = map[string]*regexp.Regexp{
+	// Ключи API, токены и закрытые ключи
+	"secrets": regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----|\bAKIA[0-9A-Z]{16}\b|\bsk-[A-Za-z0-9_-]{20,}|\bgh[pousr]_[A-Za-z0-9]{36}\b|\bxox[abpr]-[A-Za-z0-9-]{10,}|` +
+		`(?i)\b(?:api[_-]?key|secret|access[_-]?token|password|пароль)\s*[:=]\s*\S{8,}`),
+	// Номера банковских карт (проверяются по алгоритму Луна)
+	"cards":  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+	"emails": regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	"phones": regexp.MustCompile(`(?:^|[^\d])(\+?\d[\d ()-]{9,16}\d)`),
+}
+
+// Правило политики: совпадение с выражением или ключевым словом
+type policyRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Action  string
+	// Совпадение должно проходить проверку контрольной суммы (номера карт)
+	Luhn bool
+}
+
+// Локальная политика содержимого: правила проверяются до отправки файла во внешние
+// API (модель, пакетные запросы, векторные представления)
+type contentPolicy struct {
+	Rules []policyRule
+	// Поле frontmatter с классом данных и классы, которые не покидают сеть
+	ClassKey       string
+	BlockedClasses []string
+}
+
+// Совпадение файла с политикой
+type policyMatch struct {
+	Rule   string
+	Action string
+}
+
+// Чтение политики из секции [POLICY] и секций [POLICY.<имя>] с ключами pattern и action
+func loadContentPolicy(cfg *ini.File) (contentPolicy, error) {
+	var policy contentPolicy
+	section := cfg.Section("POLICY")
+	action := strings.ToLower(section.Key("action").MustString(PolicyBlock))
+	if err := validatePolicyAction(action); err != nil {
+		return policy, err
+	}
+	for _, name := range splitList(section.Key("categories").String()) {
+		re, ok := policyCategories[strings.ToLower(name)]
+		if !ok {
+			return policy, errorf("неизвестная категория политики %q: поддерживаются %s", name, strings.Join(policyCategoryNames(), ", "))
+		}
+		name = strings.ToLower(name)
+		policy.Rules = append(policy.Rules, policyRule{Name: name, Pattern: re, Action: action, Luhn: name == "cards"})
+	}
+	if keywords := splitList(section.Key("keywords").String()); len(keywords) > 0 {
+		quoted := make([]string, len(keywords))
+		for i, k := range keywords {
+			quoted[i] = regexp.QuoteMeta(k)
+		}
+		policy.Rules = append(policy.Rules, policyRule{Name: "keywords",
+			Pattern: regexp.MustCompile(`(?i)` + strings.Join(quoted, "|")), Action: action})
+	}
+	policy.ClassKey = section.Key("class_key").MustString("classification")
+	for _, class := range splitList(section.Key("blocked_classes").String()) {
+		policy.BlockedClasses = append(policy.BlockedClasses, strings.ToLower(class))
+	}
+
+	for _, s := range cfg.Sections() {
+		name, ok := strings.CutPrefix(s.Name(), "POLICY.")
+		if !ok {
+			continue
+		}
+		re, err := regexp.Compile(s.Key("pattern").String())
+		if err != nil || s.Key("pattern").String() == "" {
+			return policy, errorf("некорректное правило политики %s: задайте регулярное выражение pattern", name)
+		}
+		ruleAction := strings.ToLower(s.Key("action").MustString(action))
+		if err := validatePolicyAction(ruleAction); err != nil {
+			return policy, err
+		}
+		policy.Rules = append(policy.Rules, policyRule{Name: name, Pattern: re, Action: ruleAction})
+	}
+	return policy, nil
+}
+
+// Проверка действия политики
+func validatePolicyAction(action string) error {
+	if action != PolicyBlock && action != PolicyFlag {
+		return errorf("некорректное действие политики %q: ожидалось %s или %s", action, PolicyBlock, PolicyFlag)
+	}
+	return nil
+}
+
+// Имена встроенных категорий
+func policyCategoryNames() []string {
+	names := make([]string, 0, len(policyCategories))
+	for name := range policyCategories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Элементы списка через запятую
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Политика задана
+func (p contentPolicy) Enabled() bool {
+	return len(p.Rules) > 0 || len(p.BlockedClasses) > 0
+}
+
+// Совпадения содержимого с политикой
+func (p contentPolicy) Check(content []byte) []policyMatch {
+	var matches []policyMatch
+	if len(p.BlockedClasses) > 0 {
+		fm, _, _ := parseFrontmatter(content)
+		for _, class := range fm.List(p.ClassKey) {
+			if slices.Contains(p.BlockedClasses, strings.ToLower(class)) {
+				matches = append(matches, policyMatch{Rule: p.ClassKey + ": " + class, Action: PolicyBlock})
+				break
+			}
+		}
+	}
+	text := string(content)
+	for _, rule := range p.Rules {
+		if rule.matches(text) {
+			matches = append(matches, policyMatch{Rule: rule.Name, Action: rule.Action})
+		}
+	}
+	return matches
+}
+
+// Проверка, что содержимое запрещено отправлять во внешние API
+func (p contentPolicy) Blocks(content []byte) bool {
+	return slices.ContainsFunc(p.Check(content), func(m policyMatch) bool { return m.Action == PolicyBlock })
+}
+
+// Файлы, которые разрешено отправлять во внешние API; нечитаемые файлы остаются
+// и обрабатываются как обычно
+func (p contentPolicy) allowedPaths(paths []string) []string {
+	if !p.Enabled() {
+		return paths
+	}
+	return slices.DeleteFunc(slices.Clone(paths), func(path string) bool {
+		content, err := os.ReadFile(path)
+		return err == nil && p.Blocks(content)
+	})
+}
+
+// Кандидаты на обработку, которые разрешено отправлять во внешние API
+func (p contentPolicy) allowedCandidates(candidates []candidate) []candidate {
+	if !p.Enabled() {
+		return candidates
+	}
+	return slices.DeleteFunc(slices.Clone(candidates), func(c candidate) bool {
+		content, err := readMarkdownFile(c.Path)
+		return err == nil && p.Blocks(content)
+	})
+}
+
+// Совпадение правила; номера карт дополнительно проверяются контрольной суммой
+func (r policyRule) matches(text string) bool {
+	if !r.Luhn {
+		return r.Pattern.MatchString(text)
+	}
+	for _, m := range r.Pattern.FindAllString(text, -1) {
+		if luhnValid(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверка номера по алгоритму Луна
+func luhnValid(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		r := rune(number[i])
+		if !unicode.IsDigit(r) {
+			continue
+		}
+		d := int(r - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
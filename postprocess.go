@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Маркеры оглавления, между которыми оглавление обновляется при повторной обработке
+const (
+	TOCStartMarker = "<!-- rich:toc -->"
+	TOCEndMarker   = "<!-- rich:toc-end -->"
+)
+
+var (
+	anchorLineRe     = regexp.MustCompile(`^<a (?:id|name)="([^"]*)"></a>$`)
+	headingAnchorRe  = regexp.MustCompile(`\s*\{#[^}]*\}\s*$`)
+	closingHashesRe  = regexp.MustCompile(`\s+#+\s*$`)
+	inlineLinkTextRe = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// Заголовок документа
+type docHeading struct {
+	Line  int
+	Level int
+	Text  string
+	Slug  string
+}
+
+// Локальная постобработка обогащенного документа: нормализация уровней заголовков,
+// якоря и оглавление. Результат детерминирован и не требует обращения к модели
+func postProcess(config *Config, text string) string {
+	if !config.NormalizeHeadings && !config.HeadingAnchors && !config.TOC {
+		return text
+	}
+
+	// Frontmatter не изменяется
+	prefix := ""
+	if _, body, ok := parseFrontmatter([]byte(text)); ok {
+		prefix = text[:len(text)-len(body)]
+		text = string(body)
+	}
+
+	lines := strings.Split(text, "\n")
+	if config.NormalizeHeadings {
+		normalizeHeadingLevels(lines)
+	}
+	headings := collectHeadings(lines)
+	if config.HeadingAnchors {
+		lines = insertAnchors(lines, headings)
+		headings = collectHeadings(lines)
+	}
+	if config.TOC {
+		lines = insertTOC(lines, headings, config.TOCMaxLevel)
+	}
+	return prefix + strings.Join(lines, "\n")
+}
+
+// Уровень заголовка ATX или 0, если строка не заголовок
+func headingLevel(line string) int {
+	if !isHeadingLine(line) {
+		return 0
+	}
+	return len(line) - len(strings.TrimLeft(line, "#"))
+}
+
+// Обход строк вне блоков кода
+func forEachOutsideFences(lines []string, fn func(i int)) {
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			fn(i)
+		}
+	}
+}
+
+// Нормализация уровней: заголовок не может быть глубже предыдущего больше чем на один уровень
+func normalizeHeadingLevels(lines []string) {
+	prev := 0
+	forEachOutsideFences(lines, func(i int) {
+		level := headingLevel(lines[i])
+		if level == 0 {
+			return
+		}
+		if prev > 0 && level > prev+1 {
+			lines[i] = strings.Repeat("#", prev+1) + lines[i][level:]
+			level = prev + 1
+		}
+		prev = level
+	})
+}
+
+// Сбор заголовков документа с уникальными якорями в стиле GitHub
+func collectHeadings(lines []string) []docHeading {
+	var headings []docHeading
+	used := make(map[string]int)
+	forEachOutsideFences(lines, func(i int) {
+		level := headingLevel(lines[i])
+		if level == 0 {
+			return
+		}
+		text := strings.TrimSpace(lines[i][level:])
+		text = closingHashesRe.ReplaceAllString(headingAnchorRe.ReplaceAllString(text, ""), "")
+		if text == "" {
+			return
+		}
+		slug := slugify(text)
+		if n, ok := used[slug]; ok {
+			used[slug] = n + 1
+			slug = fmt.Sprintf("%s-%d", slug, n+1)
+		} else {
+			used[slug] = 0
+		}
+		headings = append(headings, docHeading{Line: i, Level: level, Text: text, Slug: slug})
+	})
+	return headings
+}
+
+// Якорь заголовка: строчные буквы и цифры, пробелы заменяются дефисами
+func slugify(text string) string {
+	text = inlineLinkTextRe.ReplaceAllString(text, "$1")
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// Вставка явных якорей перед заголовками, у которых их еще нет
+func insertAnchors(lines []string, headings []docHeading) []string {
+	result := make([]string, 0, len(lines)+len(headings))
+	next := 0
+	for i, line := range lines {
+		if next < len(headings) && headings[next].Line == i {
+			if i == 0 || !anchorLineRe.MatchString(lines[i-1]) {
+				result = append(result, fmt.Sprintf(`<a id="%s"></a>`, headings[next].Slug))
+			}
+			next++
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// Вставка или обновление оглавления между маркерами; без маркеров оглавление
+// размещается после первого заголовка верхнего уровня или в начале документа
+func insertTOC(lines []string, headings []docHeading, maxLevel int) []string {
+	if maxLevel <= 0 {
+		maxLevel = 3
+	}
+
+	start, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case TOCStartMarker:
+			if start < 0 {
+				start = i
+			}
+		case TOCEndMarker:
+			if start >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+
+	// Заголовок документа (единственный H1 в начале) в оглавление не включается
+	var entries []docHeading
+	minLevel := 0
+	for i, h := range headings {
+		if i == 0 && h.Level == 1 {
+			continue
+		}
+		if h.Level > maxLevel || (start >= 0 && end >= 0 && h.Line > start && h.Line < end) {
+			continue
+		}
+		entries = append(entries, h)
+		if minLevel == 0 || h.Level < minLevel {
+			minLevel = h.Level
+		}
+	}
+
+	block := []string{TOCStartMarker}
+	for _, h := range entries {
+		indent := strings.Repeat("  ", h.Level-minLevel)
+		block = append(block, fmt.Sprintf("%s- [%s](#%s)", indent, h.Text, h.Slug))
+	}
+	block = append(block, TOCEndMarker)
+
+	if start >= 0 && end >= 0 {
+		return append(append(append([]string{}, lines[:start]...), block...), lines[end+1:]...)
+	}
+	if len(entries) == 0 {
+		return lines
+	}
+
+	at := 0
+	if len(headings) > 0 && headings[0].Level == 1 {
+		at = headings[0].Line + 1
+	}
+	insert := append([]string{""}, block...)
+	if at == 0 {
+		insert = append(block, "")
+	}
+	return append(append(append([]string{}, lines[:at]...), insert...), lines[at:]...)
+}
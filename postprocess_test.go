@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHeadingLevels(t *testing.T) {
+	lines := strings.Split("# Заголовок\n#### Глубоко\n##### Еще глубже\n## Раздел\n```\n#### в коде\n```\n###### Прыжок", "\n")
+	normalizeHeadingLevels(lines)
+	expected := "# Заголовок\n## Глубоко\n### Еще глубже\n## Раздел\n```\n#### в коде\n```\n### Прыжок"
+	if got := strings.Join(lines, "\n"); got != expected {
+		t.Errorf("Некорректная нормализация:\n%s\nожидалось\n%s", got, expected)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!": "hello-world",
+		"Установка и настройка":  "установка-и-настройка",
+		"API [v2](http://x) doc": "api-v2-doc",
+		"snake_case - name":      "snake_case---name",
+	}
+	for text, want := range tests {
+		if got := slugify(text); got != want {
+			t.Errorf("slugify(%q) = %q, ожидалось %q", text, got, want)
+		}
+	}
+}
+
+func TestPostProcessTOCAndAnchors(t *testing.T) {
+	config := &Config{NormalizeHeadings: true, HeadingAnchors: true, TOC: true, TOCMaxLevel: 2}
+	text := "---\ntitle: x\n---\n# Документ\n\nВведение\n\n## Обзор\n\n#### Детали\n\n## Обзор\n"
+	result := postProcess(config, text)
+
+	expected := "---\ntitle: x\n---\n" +
+		"<a id=\"документ\"></a>\n# Документ\n\n" +
+		TOCStartMarker + "\n- [Обзор](#обзор)\n- [Обзор](#обзор-1)\n" + TOCEndMarker + "\n\n" +
+		"Введение\n\n" +
+		"<a id=\"обзор\"></a>\n## Обзор\n\n" +
+		"<a id=\"детали\"></a>\n### Детали\n\n" +
+		"<a id=\"обзор-1\"></a>\n## Обзор\n"
+	if result != expected {
+		t.Errorf("Некорректный результат постобработки:\n%s\nожидалось\n%s", result, expected)
+	}
+
+	// Повторная обработка не должна менять документ
+	if again := postProcess(config, result); again != result {
+		t.Errorf("Постобработка не идемпотентна:\n%s", again)
+	}
+}
+
+func TestPostProcessDisabled(t *testing.T) {
+	text := "# A\n#### B\n"
+	if got := postProcess(&Config{}, text); got != text {
+		t.Errorf("Без настроек документ не должен изменяться: %q", got)
+	}
+}
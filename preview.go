@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Значение, которое выводится вместо ключей и токенов в заголовках запроса
+const redactedValue = "***"
+
+// Запрос к модели, который будет отправлен при обработке файла
+type plannedRequest struct {
+	// Назначение запроса: обогащение, часть документа, оглавление, раздел
+	Purpose string
+	Config  *Config
+	Content string
+}
+
+// Файл и конфигурация его корня по пути из командной строки: путь относительно
+// текущей директории или входной директории одного из корней
+func previewInput(config *Config, path string) (*Config, string, string, error) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		for _, rootConfig := range config.inputRoots() {
+			candidates = append(candidates, filepath.Join(rootConfig.InputDir, path))
+		}
+	}
+	for _, candidate := range candidates {
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(abs); err != nil || info.IsDir() {
+			continue
+		}
+		for _, rootConfig := range config.inputRoots() {
+			inputDir, err := filepath.Abs(rootConfig.InputDir)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(inputDir, abs); err == nil && isRelPathSafe(rel) {
+				return rootConfig, abs, rel, nil
+			}
+		}
+		// Файл вне входных директорий обрабатывается с конфигурацией основного корня
+		return config, abs, filepath.Base(abs), nil
+	}
+	return nil, "", "", errorf("файл %s не найден", path)
+}
+
+// Запросы к модели для файла в порядке отправки, без обращения к API. Текст
+// изображений (OCR), фрагменты директории контекста и результаты предыдущих
+// частей документа известны только во время обработки и не входят в запросы
+func planFileRequests(config *Config, inputPath, relPath string) ([]plannedRequest, error) {
+	content, err := readMarkdownFile(inputPath)
+	if err != nil {
+		return nil, errorf("ошибка при чтении файла: %w", err)
+	}
+	if format := config.Pandoc.formatFor(inputPath); format != "" {
+		if content, err = config.Pandoc.toMarkdown(inputPath, format); err != nil {
+			return nil, errorf("ошибка конвертации %s в markdown: %v", inputPath, err)
+		}
+	} else if config.PDF.Enabled && isPDFPath(inputPath) {
+		text, _, err := extractPDFText(content, config.PDF.MaxPages)
+		if err != nil {
+			return nil, errorf("не удалось извлечь текст из %s: %v", inputPath, err)
+		}
+		content = []byte(text)
+	}
+	oversized := len(content) > config.maxFileSize()
+	if oversized && config.Oversize == OversizeTruncate {
+		content, _ = truncateContent(content, config.maxFileSize())
+	}
+
+	fileConfig, _, _ := resolveFileConfig(config, relPath, content)
+	text := string(content)
+	var planned []plannedRequest
+	switch mode := documentMode(config, text); {
+	case mode == ModeOutline:
+		outlineConfig := fileConfig
+		outlineConfig.Prompt = outlineInstruction
+		outlineConfig.Examples = nil
+		outlineConfig.OutputRules = outputRules{}
+		planned = append(planned, plannedRequest{Purpose: tr("оглавление"), Config: &outlineConfig, Content: stripOutline(text)})
+	case mode == ModeSkeleton:
+		for _, sec := range findPlaceholderSections(text) {
+			skeletonConfig := fileConfig
+			skeletonConfig.Prompt = strings.TrimSpace(fileConfig.Prompt + "\n\n" + fmt.Sprintf(skeletonInstruction, strings.TrimLeft(sec.Heading, "# ")))
+			skeletonConfig.Examples = nil
+			skeletonConfig.OutputRules = outputRules{}
+			planned = append(planned, plannedRequest{Purpose: trf("раздел %s", sec.Heading), Config: &skeletonConfig, Content: text})
+		}
+	default:
+		if sections := findSections(config, text); len(sections) > 0 {
+			for i, sec := range sections {
+				planned = append(planned, plannedRequest{Purpose: trf("раздел %d из %d", i+1, len(sections)), Config: &fileConfig, Content: sec.Text(text)})
+			}
+			break
+		}
+		chunks := documentChunks(&fileConfig, text, oversized)
+		for i, chunk := range chunks {
+			purpose := tr("обогащение")
+			if len(chunks) > 1 {
+				purpose = trf("часть %d из %d", i+1, len(chunks))
+			}
+			planned = append(planned, plannedRequest{Purpose: purpose, Config: &fileConfig, Content: chunk})
+		}
+	}
+	return planned, nil
+}
+
+// HTTP запрос с телом и заголовками, как при отправке; вместо ключа API
+// и токена доступа подставляется redactedValue
+func previewModelRequest(r plannedRequest) (*http.Request, []byte, error) {
+	maxTokens, err := requestMaxTokens(r.Config, requestText(r.Config, r.Content))
+	if err != nil {
+		return nil, nil, err
+	}
+	req, body, err := newModelRequest(r.Config, r.Content, nil, maxTokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := r.Config.provider()
+	switch {
+	case p != nil && p.accessToken != nil:
+		req.Header.Set("Authorization", "Bearer "+redactedValue)
+	case r.Config.APIKey != "":
+		p.setAuth(req, redactedValue)
+	}
+	return req, body, nil
+}
+
+// Вывод запроса: метод, адрес, заголовки по алфавиту и тело JSON с отступами
+func writePreviewRequest(out io.Writer, req *http.Request, body []byte) {
+	fmt.Fprintf(out, "%s %s\n", req.Method, req.URL)
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(out, "%s: %s\n", name, value)
+		}
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		indented.Reset()
+		indented.Write(body)
+	}
+	fmt.Fprintf(out, "\n%s\n", indented.String())
+}
+
+// rich preview [--summary] <файл...>: запросы к модели, которые будут отправлены
+// для файла, в точности как при обработке, но без отправки; ключи API скрываются.
+// С --summary выводятся только число запросов и оценка входных токенов файлов
+func runPreviewCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	summary := fs.Bool("summary", false, tr("Только число запросов и оценка входных токенов, без тел запросов"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || (fs.NArg() > 1 && !*summary) {
+		return errorf("укажите файл: rich preview <файл>")
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if *summary {
+		return writePreviewSummary(out, config, fs.Args())
+	}
+	rootConfig, inputPath, relPath, err := previewInput(config, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	planned, err := planFileRequests(rootConfig, inputPath, relPath)
+	if err != nil {
+		return err
+	}
+	if len(planned) == 0 {
+		fmt.Fprintf(out, tr("Для %s запросы к модели не отправляются\n"), relPath)
+		return nil
+	}
+
+	for i, r := range planned {
+		req, body, err := previewModelRequest(r)
+		if err != nil {
+			return errorf("запрос %d (%s): %v", i+1, r.Purpose, err)
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, tr("# Запрос %d из %d: %s\n"), i+1, len(planned), r.Purpose)
+		writePreviewRequest(out, req, body)
+	}
+	return nil
+}
+
+// Число запросов к модели и оценка входных токенов по файлам и всего: по ним
+// можно оценить затраты на документы, которые обогащаются по частям
+func writePreviewSummary(out io.Writer, config *Config, paths []string) error {
+	totalRequests, totalTokens := 0, 0
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, tr("ФАЙЛ\tЗАПРОСЫ\tТОКЕНЫ"))
+	for _, path := range paths {
+		rootConfig, inputPath, relPath, err := previewInput(config, path)
+		if err != nil {
+			return err
+		}
+		planned, err := planFileRequests(rootConfig, inputPath, relPath)
+		if err != nil {
+			return errorf("%s: %v", relPath, err)
+		}
+		tokens := 0
+		for _, r := range planned {
+			tokens += estimateTokens(requestText(r.Config, r.Content))
+		}
+		fmt.Fprintf(w, "%s\t%d\t~%d\n", relPath, len(planned), tokens)
+		totalRequests += len(planned)
+		totalTokens += tokens
+	}
+	if len(paths) > 1 {
+		fmt.Fprintf(w, tr("Всего\t%d\t~%d\n"), totalRequests, totalTokens)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"note.md":    "# Заметка о миграции\n\nПеренос базы на новый кластер.",
+		"outline.md": "<!-- rich:mode outline -->\n# План\n\n## Этапы\n\nТекст.",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") +
+		"\n[MODEL]\nprovider = openai\nname = gpt-4o-mini\napi_key = sk-secret-key\nmax_tokens = 2000\n" +
+		"[PROMPT]\ntext = Enrich the note in {{language_name}}.\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runPreviewCommand([]string{"--config", configPath, "note.md"}, &out); err != nil {
+		t.Fatalf("rich preview вернул ошибку: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"POST https://api.openai.com/v1/chat/completions",
+		"Authorization: Bearer ***",
+		`"model": "gpt-4o-mini"`,
+		`"max_tokens": 2000`,
+		"Enrich the note in Russian.",
+		"Перенос базы на новый кластер.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("в выводе rich preview нет %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "sk-secret-key") {
+		t.Errorf("вывод rich preview содержит ключ API:\n%s", text)
+	}
+
+	out.Reset()
+	if err := runPreviewCommand([]string{"--config", configPath, filepath.Join(inputDir, "outline.md")}, &out); err != nil {
+		t.Fatalf("rich preview вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "Write an outline") || strings.Contains(out.String(), "Enrich the note") {
+		t.Errorf("запрос оглавления:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runPreviewCommand([]string{"--config", configPath, "--summary", "note.md", "outline.md"}, &out); err != nil {
+		t.Fatalf("rich preview --summary вернул ошибку: %v", err)
+	}
+	if text := out.String(); !strings.Contains(text, "note.md") || !strings.Contains(text, "Всего") || strings.Contains(text, "POST") {
+		t.Errorf("вывод rich preview --summary:\n%s", text)
+	}
+
+	if err := runPreviewCommand([]string{"--config", configPath, "missing.md"}, &out); err == nil {
+		t.Error("ожидалась ошибка для несуществующего файла")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Поле frontmatter с приоритетом файла в очереди обработки
+const priorityFrontmatterKey = "rich_priority"
+
+// Правило приоритета: файлы по шаблону пути обрабатываются раньше (большее значение)
+// или позже (отрицательное значение) остальных
+type priorityRule struct {
+	Pattern  string
+	Priority float64
+	match    ignorePattern
+}
+
+// Разбор правила приоритета: шаблон пути в формате .richignore и число
+func newPriorityRule(pattern, value string) (priorityRule, error) {
+	pattern = strings.TrimSpace(pattern)
+	p, ok, err := parseIgnorePattern(pattern)
+	if err != nil || !ok || p.negate {
+		return priorityRule{}, errorf("некорректный шаблон приоритета: %s", pattern)
+	}
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return priorityRule{}, errorf("некорректный приоритет для %s: %s", pattern, value)
+	}
+	return priorityRule{Pattern: pattern, Priority: priority, match: p}, nil
+}
+
+// Чтение секции [PRIORITY]: шаблон пути = приоритет
+func loadPriorityRules(cfg *ini.File) ([]priorityRule, error) {
+	if !cfg.HasSection("PRIORITY") {
+		return nil, nil
+	}
+	var rules []priorityRule
+	for _, key := range cfg.Section("PRIORITY").Keys() {
+		rule, err := newPriorityRule(key.Name(), key.Value())
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Разбор параметра -priority: правила "шаблон=приоритет" через запятую
+func parsePriorityRules(spec string) ([]priorityRule, error) {
+	var rules []priorityRule
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, errorf("некорректное правило приоритета %q: ожидалось шаблон=приоритет", item)
+		}
+		rule, err := newPriorityRule(pattern, value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Приоритет файла в очереди: rich_priority из frontmatter, иначе первое подходящее
+// правило, иначе 0
+func queuePriority(c candidate, rules []priorityRule) float64 {
+	if value, ok := readFrontmatterField(c.Path, priorityFrontmatterKey); ok {
+		if priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return priority
+		}
+		warnf("Предупреждение: некорректное значение %s в %s: %s", priorityFrontmatterKey, c.RelPath, value)
+	}
+	slashPath := normalizeRelPath(c.RelPath)
+	for _, rule := range rules {
+		if rule.match.re.MatchString(slashPath) {
+			return rule.Priority
+		}
+	}
+	return 0
+}
+
+// Очередь по приоритету поверх выбранного порядка: файлы с большим приоритетом
+// отправляются первыми, внутри одного приоритета сохраняется порядок order
+func prioritizeCandidates(candidates []candidate, rules []priorityRule) {
+	priorities := make(map[string]float64, len(candidates))
+	for _, c := range candidates {
+		priorities[c.Path] = queuePriority(c, rules)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return priorities[candidates[i].Path] > priorities[candidates[j].Path]
+	})
+}
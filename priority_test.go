@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrioritizeCandidates(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"a.md":            "обычный файл",
+		"archive/b.md":    "архив",
+		"inbox/c.md":      "входящие",
+		"inbox/d.md":      "входящие",
+		"notes/urgent.md": "---\nrich_priority: 100\n---\nсрочно",
+	}
+	var candidates []candidate
+	for _, name := range []string{"a.md", "archive/b.md", "inbox/c.md", "inbox/d.md", "notes/urgent.md"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+		candidates = append(candidates, candidate{Path: path, RelPath: filepath.FromSlash(name)})
+	}
+
+	cliRules, err := parsePriorityRules("inbox/**=10, archive/**=-5")
+	if err != nil {
+		t.Fatalf("parsePriorityRules() вернул ошибку: %v", err)
+	}
+	prioritizeCandidates(candidates, cliRules)
+
+	expected := []string{"notes/urgent.md", "inbox/c.md", "inbox/d.md", "a.md", "archive/b.md"}
+	for i, name := range expected {
+		if got := filepath.ToSlash(candidates[i].RelPath); got != name {
+			t.Errorf("Позиция %d: ожидалось %s, получено %s", i, name, got)
+		}
+	}
+
+	for _, spec := range []string{"inbox/**", "inbox/**=высокий", "!inbox/**=1"} {
+		if _, err := parsePriorityRules(spec); err == nil {
+			t.Errorf("parsePriorityRules(%q) не вернул ошибку", spec)
+		}
+	}
+}
+
+func TestLoadPriorityRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	content := "[DIRECTORIES]\ninput_dir = " + tmpDir + "\noutput_dir = " + filepath.Join(tmpDir, "out") +
+		"\n\n[PRIORITY]\ninbox/** = 10\n*.draft.md = -1\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if len(config.Priorities) != 2 || config.Priorities[0].Pattern != "inbox/**" || config.Priorities[1].Priority != -1 {
+		t.Errorf("некорректные правила приоритета: %+v", config.Priorities)
+	}
+}
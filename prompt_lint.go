@@ -0,0 +1,174 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Действия при проблемах промпта, найденных перед запуском
+const (
+	// Серьезные проблемы останавливают запуск до первого запроса (по умолчанию)
+	PromptLintError = "error"
+	// Все проблемы выводятся предупреждениями, запуск продолжается
+	PromptLintWarn = "warn"
+	// Промпт не проверяется
+	PromptLintOff = "off"
+)
+
+// Доля max_tokens, которую промпт может занимать по умолчанию
+const defaultMaxPromptRatio = 1.0
+
+// Подстановка вида {{имя}} в тексте промпта
+var promptVariablePattern = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// Проблема промпта; Fatal - запуск с таким промптом дает заведомо негодный результат
+type promptIssue struct {
+	Message string
+	Fatal   bool
+}
+
+// Проверка действия при проблемах промпта
+func validatePromptLint(action string) error {
+	switch action {
+	case PromptLintError, PromptLintWarn, PromptLintOff:
+		return nil
+	}
+	return errorf("некорректное значение lint %q: ожидалось error, warn или off", action)
+}
+
+// Промпт, который проверяется: место в конфигурации, текст и параметры модели
+type lintedPrompt struct {
+	Where  string
+	Text   string
+	Config *Config
+}
+
+// Все промпты конфигурации: общий, языковые варианты и промпты маршрутов
+// (с моделью и max_tokens маршрута). Промпты директорий зависят от пути
+// файла и не проверяются
+func configPrompts(config *Config) []lintedPrompt {
+	prompts := []lintedPrompt{{Where: "[PROMPT]", Text: config.Prompt, Config: config}}
+	langs := make([]string, 0, len(config.LanguagePrompts))
+	for lang := range config.LanguagePrompts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		prompts = append(prompts, lintedPrompt{Where: "[PROMPT." + lang + "]", Text: config.LanguagePrompts[lang], Config: config})
+	}
+	for i := range config.Routes {
+		route := &config.Routes[i]
+		if route.Prompt == "" && route.ModelName == "" && route.MaxTokens == 0 {
+			continue
+		}
+		routeConfig := *config
+		route.Apply(&routeConfig)
+		prompts = append(prompts, lintedPrompt{Where: "[ROUTE." + route.Name + "]", Text: routeConfig.Prompt, Config: &routeConfig})
+	}
+	return prompts
+}
+
+// Проверка итоговых промптов перед запуском: неизвестные подстановки, размер
+// относительно max_tokens и контекстного окна модели, параметры, которые
+// не действуют в выбранном режиме обработки
+func lintPrompts(config *Config) []promptIssue {
+	var issues []promptIssue
+	if config.Mode == ModeOutline {
+		// Оглавление строится по встроенной инструкции, промпт в запрос не входит
+		if strings.TrimSpace(config.Prompt) != "" || len(config.LanguagePrompts) > 0 {
+			issues = append(issues, promptIssue{Message: tr("в режиме outline промпт [PROMPT] не используется: модель получает только инструкцию построения оглавления")})
+		}
+	}
+	if config.Mode != ModeFull && config.Mode != "" {
+		if config.Incremental {
+			issues = append(issues, promptIssue{Message: trf("в режиме %s параметр incremental не действует", config.Mode)})
+		}
+		if len(config.SectionHeadings) > 0 {
+			issues = append(issues, promptIssue{Message: trf("в режиме %s разделы [SECTIONS] не обогащаются", config.Mode)})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range configPrompts(config) {
+		for _, v := range promptVariablePattern.FindAllString(p.Text, -1) {
+			if !isPromptVariable(v) && !seen[p.Where+v] {
+				seen[p.Where+v] = true
+				issues = append(issues, promptIssue{
+					Message: trf("промпт %s содержит неизвестную подстановку %s: поддерживаются %s", p.Where, v, strings.Join(fileVariables, ", ")),
+					Fatal:   true,
+				})
+			}
+		}
+		if config.Mode == ModeOutline {
+			continue
+		}
+		if issue, ok := promptSizeIssue(p); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Проверка, что подстановка поддерживается (пробелы внутри скобок не допускаются,
+// такая подстановка не заменяется)
+func isPromptVariable(v string) bool {
+	m := fileVariablePattern.FindStringSubmatch(v)
+	if m == nil || m[0] != v {
+		return false
+	}
+	_, ok := fileContext{}.lookup(m[1])
+	return ok
+}
+
+// Размер промпта с руководством по стилю: промпт, который не помещается в окно
+// модели или больше max_prompt_ratio от max_tokens, оставляет слишком мало
+// места для документа и ответа
+func promptSizeIssue(p lintedPrompt) (promptIssue, bool) {
+	config := p.Config
+	tokens := estimateTokens(withStyleGuide(p.Text, config.Style.Guide))
+	if tokens == 0 {
+		return promptIssue{}, false
+	}
+	info, known := config.modelInfo()
+	if known && tokens+contextSafetyMargin+minResponseTokens > info.ContextWindow {
+		return promptIssue{
+			Message: trf("промпт %s (~%d токенов) не помещается в контекстное окно модели %s (%d токенов)", p.Where, tokens, config.ModelName, info.ContextWindow),
+			Fatal:   true,
+		}, true
+	}
+
+	maxTokens := config.MaxTokens
+	if config.AutoMaxTokens || (known && maxTokens > info.MaxOutput) {
+		maxTokens = info.MaxOutput
+	}
+	if maxTokens <= 0 || config.MaxPromptRatio <= 0 {
+		return promptIssue{}, false
+	}
+	if limit := int(float64(maxTokens) * config.MaxPromptRatio); tokens > limit {
+		return promptIssue{
+			Message: trf("промпт %s (~%d токенов) больше %g от max_tokens (%d): уменьшите промпт или увеличьте max_tokens или max_prompt_ratio", p.Where, tokens, config.MaxPromptRatio, maxTokens),
+			Fatal:   true,
+		}, true
+	}
+	return promptIssue{}, false
+}
+
+// Проверка промптов перед запуском: проблемы выводятся предупреждениями, а при
+// lint = error серьезные проблемы останавливают запуск
+func checkPrompts(config *Config) error {
+	if config.PromptLint == PromptLintOff {
+		return nil
+	}
+	fatal := 0
+	for _, issue := range lintPrompts(config) {
+		warnf("Предупреждение: %s", issue.Message)
+		if issue.Fatal {
+			fatal++
+		}
+	}
+	if fatal > 0 && config.PromptLint != PromptLintWarn {
+		return withCategory(ErrorConfig, errorf("проблем промпта: %d (проверка отключается параметром lint = warn или off в секции [PROMPT])", fatal))
+	}
+	return nil
+}
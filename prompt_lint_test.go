@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintPrompts(t *testing.T) {
+	base := Config{Mode: ModeFull, MaxTokens: 100, MaxPromptRatio: 1, Prompt: "Enrich the note. Answer in {{language_name}}."}
+	if issues := lintPrompts(&base); len(issues) != 0 {
+		t.Errorf("lintPrompts() для корректного промпта = %+v", issues)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+		fatal  bool
+	}{
+		{"неизвестная подстановка", func(c *Config) { c.Prompt += " Audience: {{audience}}." }, "{{audience}}", true},
+		{"подстановка языкового варианта", func(c *Config) { c.LanguagePrompts = map[string]string{"ru": "Ответь на {{lang}}"} }, "[PROMPT.ru]", true},
+		{"подстановка маршрута", func(c *Config) { c.Routes = []Route{{Name: "docs", Prompt: "{{ language }}"}} }, "[ROUTE.docs]", true},
+		{"больше max_tokens", func(c *Config) { c.Prompt = strings.Repeat("word ", 100) }, "max_tokens (100)", true},
+		{"max_tokens маршрута", func(c *Config) { c.Routes = []Route{{Name: "short", MaxTokens: 5}} }, "[ROUTE.short]", true},
+		{"контекстное окно", func(c *Config) {
+			c.ModelName, c.ContextWindow, c.MaxOutputTokens, c.MaxTokens = "local", 1000, 500, 500
+			c.MaxPromptRatio = 10
+			c.Prompt = strings.Repeat("word ", 500)
+		}, "контекстное окно", true},
+		{"промпт в режиме outline", func(c *Config) { c.Mode = ModeOutline }, "outline", false},
+		{"incremental в режиме skeleton", func(c *Config) { c.Mode, c.Incremental = ModeSkeleton, true }, "incremental", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.modify(&c)
+			issues := lintPrompts(&c)
+			if len(issues) != 1 || !strings.Contains(issues[0].Message, tt.want) || issues[0].Fatal != tt.fatal {
+				t.Errorf("lintPrompts() = %+v, ожидалась одна проблема с %q (fatal %v)", issues, tt.want, tt.fatal)
+			}
+		})
+	}
+}
+
+func TestCheckPrompts(t *testing.T) {
+	c := Config{Mode: ModeFull, Prompt: "Enrich for {{audience}}"}
+	if err := checkPrompts(&c); err == nil {
+		t.Error("ожидалась ошибка для неизвестной подстановки")
+	}
+	c.PromptLint = PromptLintWarn
+	if err := checkPrompts(&c); err != nil {
+		t.Errorf("с lint = warn запуск не должен останавливаться: %v", err)
+	}
+	c.PromptLint = PromptLintOff
+	c.Mode = ModeOutline
+	if err := checkPrompts(&c); err != nil {
+		t.Errorf("с lint = off промпт не проверяется: %v", err)
+	}
+}
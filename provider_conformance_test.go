@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Каталог ответов API для проверки провайдеров: testdata/providers/<формат>/*.json
+const providerFixturesDir = "testdata/providers"
+
+// Случаи, которые должны быть среди ответов каждого формата: обычный ответ,
+// пустой ответ, ответ из нескольких блоков, вызов инструмента и ошибка API
+var conformanceCases = []string{"text", "empty", "multi_block", "tool_call", "error"}
+
+// Ответ API из набора проверки провайдеров и ожидаемый результат его разбора
+type providerFixture struct {
+	Case string `json:"case"`
+	// Провайдеры, для которых проверяется ответ (пусто - все провайдеры формата)
+	Providers []string `json:"providers"`
+	// Код ответа (0 - 200)
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+	// Текст результата; без usage токены должны быть оценены
+	Text  string `json:"text"`
+	Usage *struct {
+		Prompt     int `json:"prompt"`
+		Completion int `json:"completion"`
+	} `json:"usage"`
+	// Подстрока ошибки разбора ответа
+	Error string `json:"error"`
+	// Сообщение из тела ответа с ошибкой, разобранное по формату провайдера
+	Message string `json:"message"`
+}
+
+// Ответы формата по именам файлов
+func loadProviderFixtures(t *testing.T, format string) map[string]providerFixture {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(providerFixturesDir, format, "*.json"))
+	if err != nil {
+		t.Fatalf("Не удалось найти ответы формата %s: %v", format, err)
+	}
+	fixtures := make(map[string]providerFixture)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Не удалось прочитать %s: %v", path, err)
+		}
+		var f providerFixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatalf("Некорректный ответ %s: %v", path, err)
+		}
+		fixtures[strings.TrimSuffix(filepath.Base(path), ".json")] = f
+	}
+	return fixtures
+}
+
+// Ответ проверяется для провайдера
+func (f providerFixture) appliesTo(p *provider) bool {
+	if len(f.Providers) == 0 {
+		return true
+	}
+	for _, name := range f.Providers {
+		if name == p.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Каждый провайдер должен разбирать все ответы своего формата: новый провайдер
+// проходит проверку без изменения теста, новый формат требует своего набора ответов
+func TestProviderConformance(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+
+	for _, p := range providers {
+		fixtures := loadProviderFixtures(t, p.api().Format())
+		names := make([]string, 0, len(fixtures))
+		covered := make(map[string]bool)
+		for name, f := range fixtures {
+			if f.appliesTo(p) {
+				names = append(names, name)
+				covered[f.Case] = true
+			}
+		}
+		sort.Strings(names)
+		for _, c := range conformanceCases {
+			if !covered[c] {
+				t.Errorf("Для провайдера %s нет ответов случая %s в %s", p.Name, c, filepath.Join(providerFixturesDir, p.api().Format()))
+			}
+		}
+
+		for _, name := range names {
+			f := fixtures[name]
+			t.Run(p.Name+"/"+name, func(t *testing.T) {
+				checkProviderFixture(t, p, f, key)
+			})
+		}
+	}
+}
+
+func checkProviderFixture(t *testing.T, p *provider, f providerFixture, key *rsa.PrivateKey) {
+	var tokens atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", tokenHandler(t, key, &tokens))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if f.Status != 0 {
+			w.WriteHeader(f.Status)
+		}
+		_, _ = w.Write(f.Body)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := &Config{ModelName: "test-model", Provider: p.Name, APIKey: "test-key", MaxTokens: 100}
+	switch p.api().Format() {
+	case formatAnthropic:
+		config.ModelAPIURL = server.URL + "/v1/messages"
+	case formatGemini:
+		config.ModelName = "gemini-2.0-flash-001"
+		config.ModelAPIURL = server.URL
+		config.VertexProject = "rich-test"
+		config.VertexLocation = "europe-west4"
+		config.GoogleCredsFile = writeServiceAccountKey(t, key, server.URL+"/token")
+	case formatOllama:
+		config.ModelAPIURL = server.URL + "/api/chat"
+	default:
+		config.ModelAPIURL = server.URL + "/v1/chat/completions"
+	}
+
+	text, usage, err := requestModel(config, "текст", nil, NewRateLimiter(0))
+	switch {
+	case f.Message != "" || f.Status != 0 && f.Status != http.StatusOK:
+		var statusErr *apiStatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("Ожидалась ошибка статуса, получено %q, %v", text, err)
+		}
+		if statusErr.StatusCode != f.Status || statusErr.Message != f.Message {
+			t.Errorf("Ошибка статуса %d %q, ожидалось %d %q", statusErr.StatusCode, statusErr.Message, f.Status, f.Message)
+		}
+	case f.Error != "":
+		if err == nil || !strings.Contains(err.Error(), f.Error) {
+			t.Errorf("Ожидалась ошибка с %q, получено %q, %v", f.Error, text, err)
+		}
+	default:
+		if err != nil {
+			t.Fatalf("requestModel() вернул ошибку: %v", err)
+		}
+		if text != f.Text {
+			t.Errorf("Текст %q, ожидалось %q", text, f.Text)
+		}
+		if f.Usage != nil && (usage.PromptTokens != f.Usage.Prompt || usage.CompletionTokens != f.Usage.Completion || usage.Estimated) {
+			t.Errorf("Токены %+v, ожидалось %+v", usage, *f.Usage)
+		}
+		if f.Usage == nil && !usage.Estimated {
+			t.Errorf("Без usage токены должны быть оценены: %+v", usage)
+		}
+	}
+}
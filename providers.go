@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Имена форматов запросов и ответов API (реализации Provider)
+const (
+	// OpenAI Chat Completions: messages в запросе, choices в ответе
+	formatChat = "chat"
+	// Anthropic Messages API: content из блоков в ответе
+	formatAnthropic = "anthropic"
+	// Gemini generateContent: contents в запросе, candidates в ответе
+	formatGemini = "gemini"
+	// Ollama /api/chat: messages и options в запросе, message в ответе, поток - NDJSON
+	formatOllama = "ollama"
+)
+
+// Значения provider: определение провайдера по адресу API и любой сервер
+// с API OpenAI Chat Completions (LM Studio, vLLM, llama.cpp server)
+const (
+	providerAuto             = "auto"
+	providerOpenAICompatible = "openai-compatible"
+	// Gemini в Vertex AI: адрес по проекту и региону, авторизация учетными данными Google
+	providerVertex = "vertex"
+)
+
+// Провайдер API: определение по адресу, ключ, формат запросов, разбор ошибок и лимитов
+type provider struct {
+	Name string
+	// Подстроки адреса API, по которым провайдер определяется при provider = auto
+	URLMarkers []string
+	// Адрес API по умолчанию; используется, если api_url не задан
+	DefaultURL string
+	// Префиксы имен моделей провайдера для выбора провайдера без api_url
+	ModelPrefixes []string
+	// Переменная окружения с ключом API
+	KeyEnv string
+	// Формат запросов и ответов
+	API Provider
+	// Путь запроса, который добавляется к api_url, если адрес задан без него
+	ChatPath string
+	// Запросов в минуту по умолчанию, если requests_per_minute не задан (0 - RequestsPerMinute)
+	RequestsPerMinute int
+	// API принимает тела запросов, сжатые gzip (Content-Encoding: gzip)
+	GzipRequests bool
+	// API возвращает токены потокового ответа по stream_options.include_usage
+	StreamUsage bool
+	// Заголовки авторизации (nil - заголовки формата API)
+	auth func(req *http.Request, key string)
+	// Токен доступа вместо статического ключа (Vertex AI)
+	accessToken func(config *Config) (string, error)
+	// Сообщение об ошибке из тела ответа (nil - формат OpenAI)
+	errorMessage func(body []byte) string
+	// Время до сброса исчерпанного лимита по заголовкам ответа (nil - заголовки OpenAI)
+	rateLimitWait func(h http.Header) (time.Duration, bool)
+	// Время ожидания из тела ответа 429 (nil - только по заголовкам)
+	errorWait func(body []byte) (time.Duration, bool)
+	// Поправки к полям параметров генерации для модели (nil - поля формата как есть)
+	adjustParams func(config *Config, fields paramFields) paramFields
+}
+
+// Поддерживаемые провайдеры; порядок важен для определения по адресу
+var providers = []*provider{
+	{
+		Name:       "openrouter",
+		URLMarkers: []string{"openrouter"},
+		DefaultURL: "https://openrouter.ai/api/v1/chat/completions",
+		KeyEnv:     "OPENROUTER_API_KEY",
+		API:        chatAPI{},
+		auth: func(req *http.Request, key string) {
+			req.Header.Set("Authorization", "Bearer "+key)
+			req.Header.Set("HTTP-Referer", "https://github.com/")
+			req.Header.Set("X-Title", "Markdown Enricher")
+		},
+	},
+	{
+		// Адрес Groq содержит /openai/, поэтому Groq определяется раньше OpenAI
+		Name:              "groq",
+		URLMarkers:        []string{"groq.com"},
+		DefaultURL:        "https://api.groq.com/openai/v1/chat/completions",
+		KeyEnv:            "GROQ_API_KEY",
+		API:               chatAPI{},
+		RequestsPerMinute: 30,
+		errorWait:         tryAgainWait,
+	},
+	{
+		Name:          "openai",
+		URLMarkers:    []string{"openai"},
+		DefaultURL:    "https://api.openai.com/v1/chat/completions",
+		ModelPrefixes: []string{"gpt-", "o1", "o3", "o4", "chatgpt-"},
+		KeyEnv:        "OPENAI_API_KEY",
+		API:           chatAPI{},
+		StreamUsage:   true,
+		errorWait:     tryAgainWait,
+	},
+	{
+		Name:          "anthropic",
+		URLMarkers:    []string{"anthropic"},
+		DefaultURL:    "https://api.anthropic.com/v1/messages",
+		ModelPrefixes: []string{"claude-"},
+		KeyEnv:        "ANTHROPIC_API_KEY",
+		API:           anthropicAPI{},
+		adjustParams:  anthropicParamFields,
+	},
+	{
+		Name:          "deepseek",
+		URLMarkers:    []string{"deepseek.com"},
+		DefaultURL:    "https://api.deepseek.com/chat/completions",
+		ModelPrefixes: []string{"deepseek-"},
+		KeyEnv:        "DEEPSEEK_API_KEY",
+		API:           chatAPI{},
+		adjustParams:  deepseekParamFields,
+	},
+	{
+		Name:          "xai",
+		URLMarkers:    []string{"api.x.ai"},
+		DefaultURL:    "https://api.x.ai/v1/chat/completions",
+		ModelPrefixes: []string{"grok-"},
+		KeyEnv:        "XAI_API_KEY",
+		API:           chatAPI{},
+		errorWait:     tryAgainWait,
+		adjustParams:  xaiParamFields,
+	},
+	{
+		// Модели с открытыми весами: meta-llama/Llama-3.3-70B-Instruct-Turbo
+		Name:       "together",
+		URLMarkers: []string{"together.xyz", "together.ai"},
+		DefaultURL: "https://api.together.xyz/v1/chat/completions",
+		KeyEnv:     "TOGETHER_API_KEY",
+		API:        chatAPI{},
+	},
+	{
+		// Модели с открытыми весами: accounts/fireworks/models/llama-v3p3-70b-instruct
+		Name:       "fireworks",
+		URLMarkers: []string{"fireworks.ai"},
+		DefaultURL: "https://api.fireworks.ai/inference/v1/chat/completions",
+		KeyEnv:     "FIREWORKS_API_KEY",
+		API:        chatAPI{},
+	},
+	{
+		// Адрес строится по региону и проекту (location, project), авторизация -
+		// по ключу сервисного аккаунта или Application Default Credentials
+		Name:         providerVertex,
+		URLMarkers:   []string{"aiplatform.googleapis.com"},
+		DefaultURL:   vertexBaseURL(defaultVertexLocation),
+		API:          geminiAPI{},
+		GzipRequests: true,
+		accessToken: func(config *Config) (string, error) {
+			return googleAccessToken(config.GoogleCredsFile)
+		},
+	},
+	{
+		// Gemini API (Google AI Studio) по ключу; адрес модели строится по имени модели
+		Name:          "gemini",
+		URLMarkers:    []string{"generativelanguage.googleapis.com"},
+		DefaultURL:    "https://generativelanguage.googleapis.com/v1beta",
+		ModelPrefixes: []string{"gemini-"},
+		KeyEnv:        "GEMINI_API_KEY",
+		API:           geminiAPI{},
+	},
+	{
+		// Собственный API Ollama (/api/chat); ключ не нужен, базовый адрес при явном
+		// provider = ollama дополняется путем /api/chat
+		Name:       "ollama",
+		URLMarkers: []string{"/api/chat"},
+		DefaultURL: "http://localhost:11434/api/chat",
+		API:        ollamaAPI{},
+		ChatPath:   "/api/chat",
+	},
+	{
+		// Задается только явно: адрес не угадывается, ключ необязателен
+		Name:        providerOpenAICompatible,
+		API:         chatAPI{},
+		ChatPath:    "/chat/completions",
+		StreamUsage: true,
+	},
+	{
+		Name:          "mistral",
+		URLMarkers:    []string{"mistral.ai"},
+		DefaultURL:    "https://api.mistral.ai/v1/chat/completions",
+		ModelPrefixes: []string{"mistral-", "ministral-", "open-mistral-", "codestral", "pixtral-"},
+		KeyEnv:        "MISTRAL_API_KEY",
+		API:           chatAPI{},
+		errorMessage:  mistralErrorMessage,
+		rateLimitWait: mistralRateLimitWait,
+	},
+}
+
+// Провайдер по имени
+func providerByName(name string) (*provider, bool) {
+	for _, p := range providers {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Определение провайдера по адресу API; nil - неизвестный API (общий формат)
+func detectProvider(apiURL string) *provider {
+	lower := strings.ToLower(apiURL)
+	for _, p := range providers {
+		for _, marker := range p.URLMarkers {
+			if strings.Contains(lower, marker) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Провайдер по имени модели (grok-2 - xAI, claude-... - Anthropic); имена с
+// префиксом провайдера ("openai/gpt-4o") относятся к агрегаторам и не определяются
+func providerForModel(name string) *provider {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.Contains(name, "/") {
+		return nil
+	}
+	for _, p := range providers {
+		for _, prefix := range p.ModelPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Имена провайдеров для сообщений об ошибках
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Провайдер конфигурации: заданный параметром provider или определенный по api_url
+func (c *Config) provider() *provider {
+	if c.Provider != "" && c.Provider != providerAuto {
+		if p, ok := providerByName(c.Provider); ok {
+			return p
+		}
+	}
+	return detectProvider(c.ModelAPIURL)
+}
+
+// Запросов в минуту: requests_per_minute из конфигурации, значение провайдера
+// или RequestsPerMinute
+func (c *Config) requestsPerMinute() int {
+	if c.RequestsPerMinute > 0 {
+		return c.RequestsPerMinute
+	}
+	if p := c.provider(); p != nil && p.RequestsPerMinute > 0 {
+		return p.RequestsPerMinute
+	}
+	return RequestsPerMinute
+}
+
+// Адрес запроса: api_url, дополненный путем провайдера, если задан базовый адрес
+// (http://localhost:1234/v1 - http://localhost:1234/v1/chat/completions)
+func (p *provider) requestURL(apiURL string) string {
+	if p == nil || p.ChatPath == "" {
+		return apiURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || strings.HasSuffix(strings.TrimRight(u.Path, "/"), p.ChatPath) {
+		return apiURL
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + p.ChatPath
+	return u.String()
+}
+
+// Установка заголовков авторизации провайдера
+func (p *provider) setAuth(req *http.Request, key string) {
+	if p != nil && p.auth != nil {
+		p.auth(req, key)
+		return
+	}
+	p.api().SetAuth(req, key)
+}
+
+// Сообщение об ошибке из тела ответа провайдера ("" - формат не распознан)
+func (p *provider) apiErrorMessage(body []byte) string {
+	if p != nil && p.errorMessage != nil {
+		return p.errorMessage(body)
+	}
+	return openAIErrorMessage(body)
+}
+
+// Ожидание до сброса лимита по заголовкам ответа: Retry-After или заголовки провайдера
+func (p *provider) limitWait(h http.Header) (time.Duration, bool) {
+	if secs, err := strconv.Atoi(strings.TrimSpace(h.Get("Retry-After"))); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if p != nil && p.rateLimitWait != nil {
+		return p.rateLimitWait(h)
+	}
+	return openAIRateLimitWait(h)
+}
+
+// Блок рассуждений, который модели семейства R1 у ряда провайдеров пишут в начало content
+var thinkBlockPattern = regexp.MustCompile(`(?s)^\s*<think>.*?</think>`)
+
+// Текст ответа в формате Chat Completions без рассуждений модели: рассуждения
+// DeepSeek (reasoning_content) и блок <think> в начале content в документ не попадают
+func chatMessageText(message map[string]interface{}) (string, error) {
+	reasoning, _ := message["reasoning_content"].(string)
+	content, ok := message["content"].(string)
+	if parts, isParts := message["content"].([]interface{}); isParts {
+		var thinking string
+		content, thinking, ok = chatContentParts(parts)
+		reasoning += thinking
+	}
+	if !ok && message["content"] != nil {
+		return "", errorf("некорректный формат поля content в ответе API")
+	}
+	if loc := thinkBlockPattern.FindStringIndex(content); loc != nil {
+		reasoning += content[:loc[1]]
+		content = content[loc[1]:]
+	} else if strings.HasPrefix(strings.TrimSpace(content), "<think>") {
+		// Ответ оборван внутри рассуждений (не хватило max_tokens)
+		reasoning += content
+		content = ""
+	}
+	if strings.TrimSpace(content) == "" {
+		if reasoning != "" {
+			return "", errorf("ответ содержит только рассуждения модели без результата: увеличьте max_tokens")
+		}
+		if calls, _ := message["tool_calls"].([]interface{}); len(calls) > 0 {
+			return "", errorf("модель вернула вызов инструмента вместо текста ответа")
+		}
+		if !ok {
+			return "", errorf("некорректный формат поля content в ответе API")
+		}
+	}
+	if reasoning != "" {
+		logf("Рассуждения модели (%d символов) не включены в результат", len([]rune(reasoning)))
+	}
+	return content, nil
+}
+
+// Содержимое content из частей (Mistral и часть OpenAI-совместимых серверов):
+// текстовые части объединяются по порядку, рассуждения (thinking) возвращаются
+// отдельно, части других типов пропускаются
+func chatContentParts(parts []interface{}) (text, reasoning string, ok bool) {
+	var b, r strings.Builder
+	for _, item := range parts {
+		part, isMap := item.(map[string]interface{})
+		if !isMap {
+			return "", "", false
+		}
+		switch partType, _ := part["type"].(string); partType {
+		case "", "text":
+			s, isText := part["text"].(string)
+			if !isText {
+				return "", "", false
+			}
+			b.WriteString(s)
+		case "thinking":
+			// Mistral: {"type": "thinking", "thinking": [{"type": "text", "text": ...}]}
+			if nested, isParts := part["thinking"].([]interface{}); isParts {
+				thought, _, _ := chatContentParts(nested)
+				r.WriteString(thought)
+			} else if s, isText := part["thinking"].(string); isText {
+				r.WriteString(s)
+			}
+		}
+	}
+	return b.String(), r.String(), true
+}
+
+// Время ожидания из тела ответа 429 при превышении лимита
+func (p *provider) errorBodyWait(body []byte) (time.Duration, bool) {
+	if p == nil || p.errorWait == nil {
+		return 0, false
+	}
+	return p.errorWait(body)
+}
+
+// Остаток лимита токенов в минуту и время до его сброса по заголовкам OpenAI
+// (x-ratelimit-remaining-tokens, x-ratelimit-reset-tokens); их же передает Groq
+func tokenLimit(h http.Header) (int, time.Duration, bool) {
+	remaining, err := strconv.Atoi(strings.TrimSpace(h.Get("x-ratelimit-remaining-tokens")))
+	if err != nil {
+		return 0, 0, false
+	}
+	reset, err := time.ParseDuration(strings.TrimSpace(h.Get("x-ratelimit-reset-tokens")))
+	if err != nil {
+		return 0, 0, false
+	}
+	return remaining, reset, true
+}
+
+// Время ожидания из сообщения о превышении лимита OpenAI и Groq:
+// "... on tokens per minute (TPM): Limit 6000, Used 5919, Requested 817. Please try again in 7.36s."
+var tryAgainPattern = regexp.MustCompile(`(?i)try again in ((?:\d+(?:\.\d+)?(?:h|ms|m|s))+)`)
+
+func tryAgainWait(body []byte) (time.Duration, bool) {
+	m := tryAgainPattern.FindSubmatch([]byte(openAIErrorMessage(body)))
+	if m == nil {
+		return 0, false
+	}
+	d, err := time.ParseDuration(string(m[1]))
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Ошибка в формате OpenAI и совместимых API: {"error": {"message": ...}} или {"error": "..."}
+func openAIErrorMessage(body []byte) string {
+	var data struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &data) != nil || len(data.Error) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(data.Error, &text) == nil {
+		return text
+	}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data.Error, &detail) == nil {
+		return detail.Message
+	}
+	return ""
+}
+
+// Ошибка Mistral: {"message": "..."} или при ошибке проверки запроса
+// {"message": {"detail": [{"loc": [...], "msg": "..."}]}}
+func mistralErrorMessage(body []byte) string {
+	var data struct {
+		Message json.RawMessage `json:"message"`
+	}
+	if json.Unmarshal(body, &data) != nil || len(data.Message) == 0 {
+		return openAIErrorMessage(body)
+	}
+	var text string
+	if json.Unmarshal(data.Message, &text) == nil {
+		return text
+	}
+	var validation struct {
+		Detail []struct {
+			Loc []interface{} `json:"loc"`
+			Msg string        `json:"msg"`
+		} `json:"detail"`
+	}
+	if json.Unmarshal(data.Message, &validation) != nil || len(validation.Detail) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, d := range validation.Detail {
+		var loc []string
+		for _, l := range d.Loc {
+			switch v := l.(type) {
+			case string:
+				loc = append(loc, v)
+			case float64:
+				loc = append(loc, strconv.Itoa(int(v)))
+			}
+		}
+		if len(loc) > 0 {
+			parts = append(parts, strings.Join(loc, ".")+": "+d.Msg)
+		} else {
+			parts = append(parts, d.Msg)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Лимиты OpenAI и совместимых API: x-ratelimit-remaining-{requests,tokens} и
+// время сброса x-ratelimit-reset-{requests,tokens} ("1s", "6m0s")
+func openAIRateLimitWait(h http.Header) (time.Duration, bool) {
+	var wait time.Duration
+	found := false
+	for _, kind := range []string{"requests", "tokens"} {
+		if strings.TrimSpace(h.Get("x-ratelimit-remaining-"+kind)) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(h.Get("x-ratelimit-reset-" + kind))); err == nil && d > 0 {
+			wait = max(wait, d)
+			found = true
+		}
+	}
+	return wait, found
+}
+
+// Лимиты Mistral: токены в минуту x-ratelimitbysize-remaining-minute и время
+// сброса ratelimitbysize-reset в секундах (без него - до конца минуты)
+func mistralRateLimitWait(h http.Header) (time.Duration, bool) {
+	remaining := strings.TrimSpace(h.Get("x-ratelimitbysize-remaining-minute"))
+	if remaining == "" {
+		remaining = strings.TrimSpace(h.Get("ratelimitbysize-remaining"))
+	}
+	if remaining != "0" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(h.Get("ratelimitbysize-reset"))); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	return time.Minute, true
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectProvider(t *testing.T) {
+	cases := map[string]string{
+		"https://api.openai.com/v1/chat/completions":             "openai",
+		"https://openrouter.ai/api/v1/chat/completions":          "openrouter",
+		"https://api.anthropic.com/v1/messages":                  "anthropic",
+		"https://api.mistral.ai/v1/chat/completions":             "mistral",
+		"https://api.groq.com/openai/v1/chat/completions":        "groq",
+		"https://api.deepseek.com/chat/completions":              "deepseek",
+		"https://api.x.ai/v1/chat/completions":                   "xai",
+		"https://api.together.xyz/v1/chat/completions":           "together",
+		"https://api.fireworks.ai/inference/v1/chat/completions": "fireworks",
+		"http://localhost:11434/api/generate":                    "",
+		"http://localhost:11434/api/chat":                        "ollama",
+		"https://generativelanguage.googleapis.com/v1beta":       "gemini",
+	}
+	for url, want := range cases {
+		got := ""
+		if p := detectProvider(url); p != nil {
+			got = p.Name
+		}
+		if got != want {
+			t.Errorf("detectProvider(%q) = %q, ожидалось %q", url, got, want)
+		}
+	}
+
+	// Явно заданный провайдер важнее адреса (например, для прокси)
+	config := &Config{ModelAPIURL: "https://gateway.example.com/v1/chat/completions", Provider: "mistral"}
+	if p := config.provider(); p == nil || p.Name != "mistral" {
+		t.Errorf("Ожидался провайдер mistral, получено %v", p)
+	}
+}
+
+func TestProviderAPI(t *testing.T) {
+	for _, p := range providers {
+		if p.API == nil {
+			t.Errorf("У провайдера %s не задан формат API", p.Name)
+		}
+	}
+
+	// Заголовки авторизации задает формат API, провайдер может их заменить
+	headers := map[string]string{"anthropic": "x-api-key", "gemini": "x-goog-api-key", "openai": "Authorization", "openrouter": "HTTP-Referer"}
+	for name, header := range headers {
+		p, _ := providerByName(name)
+		req := httptest.NewRequest("POST", "/", nil)
+		p.setAuth(req, "key")
+		if req.Header.Get(header) == "" {
+			t.Errorf("Провайдер %s не установил заголовок %s: %v", name, header, req.Header)
+		}
+	}
+
+	// Неизвестный API - общий формат без диалога и изображений
+	var unknown *provider
+	if api := unknown.api(); api.Format() != "" || api.Conversation() {
+		t.Errorf("Формат неизвестного API: %q", api.Format())
+	}
+	config := &Config{ModelAPIURL: "http://localhost:8000/generate", ModelName: "m", MaxTokens: 100}
+	if _, _, err := newModelRequest(config, "текст", &imageAttachment{MediaType: "image/png", Data: []byte{1}}, 100); err == nil {
+		t.Error("Ожидалась ошибка для изображения в общем формате")
+	}
+	req, body, err := newModelRequest(config, "текст", nil, 100)
+	if err != nil || req.URL.String() != config.ModelAPIURL || !strings.Contains(string(body), `"prompt"`) {
+		t.Errorf("Запрос в общем формате: %s, %v", body, err)
+	}
+}
+
+func TestLoadConfigProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	write := func(model string) {
+		content := "[DIRECTORIES]\noutput_dir = " + filepath.Join(tmpDir, "out") + "\n\n[MODEL]\n" + model
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+		}
+	}
+
+	t.Setenv("MISTRAL_API_KEY", "mistral-key")
+	write("name = mistral-large-latest\napi_url = https://api.mistral.ai/v1/chat/completions\n")
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.APIKey != "mistral-key" {
+		t.Errorf("Ключ должен браться из MISTRAL_API_KEY, получено %q", config.APIKey)
+	}
+	if info, ok := config.modelInfo(); !ok || info.ContextWindow != 131072 {
+		t.Errorf("Псевдоним mistral-large-latest должен находиться в таблице моделей: %+v", info)
+	}
+
+	write("api_url = https://gateway.example.com/v1\nprovider = Mistral\n")
+	if config, err = loadConfig(configPath); err != nil || config.Provider != "mistral" || config.APIKey != "mistral-key" {
+		t.Errorf("Провайдер из конфигурации: %+v, %v", config, err)
+	}
+
+	// Без api_url провайдер и адрес определяются по имени модели
+	t.Setenv("XAI_API_KEY", "xai-key")
+	write("name = grok-2-1212\n")
+	config, err = loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.ModelAPIURL != "https://api.x.ai/v1/chat/completions" || config.APIKey != "xai-key" {
+		t.Errorf("Модель grok-2 должна использовать xAI: %s, ключ %q", config.ModelAPIURL, config.APIKey)
+	}
+	if _, ok := config.modelInfo(); !ok {
+		t.Error("Модель grok-2-1212 должна находиться в таблице моделей")
+	}
+	write("name = openai/gpt-4o\n")
+	if config, err = loadConfig(configPath); err != nil || config.ModelAPIURL != "https://api.openai.com/v1/chat/completions" {
+		t.Errorf("Имя с префиксом провайдера не должно менять api_url: %+v, %v", config, err)
+	}
+
+	write("provider = unknown\n")
+	if _, err := loadConfig(configPath); err == nil {
+		t.Error("Неизвестный провайдер должен возвращать ошибку")
+	}
+	write("name = o3\nreasoning_effort = extreme\n")
+	if _, err := loadConfig(configPath); err == nil {
+		t.Error("Некорректный reasoning_effort должен возвращать ошибку")
+	}
+}
+
+func TestMistralErrors(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("x-ratelimitbysize-remaining-minute", "0")
+		w.Header().Set("ratelimitbysize-reset", "1")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"object": "error", "message": {"detail": [{"type": "less_than_equal", "loc": ["body", "temperature"], "msg": "Input should be less than or equal to 1.5"}]}, "type": "invalid_request_error"}`))
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "mistral-small-latest", ModelAPIURL: server.URL + "/v1/chat/completions", Provider: "mistral", APIKey: "k", Temperature: 2}
+	limiter := NewRateLimiter(RequestsPerMinute)
+	_, err := enrichContent(config, "текст", limiter)
+	if err == nil || !strings.Contains(err.Error(), "body.temperature: Input should be less than or equal to 1.5") {
+		t.Errorf("Ожидалось сообщение об ошибке Mistral, получено: %v", err)
+	}
+	if auth != "Bearer k" {
+		t.Errorf("Неожиданный заголовок Authorization: %q", auth)
+	}
+	if wait := time.Until(limiter.resumeAt); wait <= 0 || wait > time.Second {
+		t.Errorf("Исчерпанный лимит Mistral должен приостанавливать запросы на 1s, пауза %v", wait)
+	}
+
+	if got := mistralErrorMessage([]byte(`{"message": "Unauthorized", "request_id": "abc"}`)); got != "Unauthorized" {
+		t.Errorf("Неожиданное сообщение: %q", got)
+	}
+	if got := openAIErrorMessage([]byte(`{"error": {"message": "Invalid API key", "type": "invalid_request_error"}}`)); got != "Invalid API key" {
+		t.Errorf("Неожиданное сообщение: %q", got)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	h := http.Header{}
+	h.Set("x-ratelimit-remaining-requests", "5")
+	h.Set("x-ratelimit-reset-requests", "1s")
+	if _, ok := detectProvider("https://api.openai.com").limitWait(h); ok {
+		t.Error("Пауза не нужна, пока лимит не исчерпан")
+	}
+	h.Set("x-ratelimit-remaining-tokens", "0")
+	h.Set("x-ratelimit-reset-tokens", "6m0s")
+	if wait, ok := detectProvider("https://api.openai.com").limitWait(h); !ok || wait != 6*time.Minute {
+		t.Errorf("Ожидалась пауза 6m, получено %v (%v)", wait, ok)
+	}
+	h = http.Header{}
+	h.Set("Retry-After", "7")
+	if wait, ok := detectProvider("https://api.anthropic.com").limitWait(h); !ok || wait != 7*time.Second {
+		t.Errorf("Ожидалась пауза по Retry-After, получено %v (%v)", wait, ok)
+	}
+}
+
+func TestGroqRateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-remaining-tokens", "120")
+		w.Header().Set("x-ratelimit-reset-tokens", "50ms")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Rate limit reached for model ` + "`llama-3.3-70b-versatile`" + ` on tokens per minute (TPM): Limit 6000, Used 5919, Requested 817. Please try again in 7.36s. Visit https://console.groq.com/docs/rate-limits for more information.", "type": "tokens", "code": "rate_limit_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "llama-3.3-70b-versatile", ModelAPIURL: server.URL + "/openai/v1/chat/completions", Provider: "groq"}
+	if got := config.requestsPerMinute(); got != 30 {
+		t.Errorf("Groq по умолчанию: %d запросов в минуту, ожидалось 30", got)
+	}
+	config.RequestsPerMinute = 5
+	if got := config.requestsPerMinute(); got != 5 {
+		t.Errorf("requests_per_minute из конфигурации: %d, ожидалось 5", got)
+	}
+
+	limiter := NewRateLimiter(RequestsPerMinute)
+	_, err := enrichContent(config, "текст", limiter)
+	var statusErr *apiStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests || !strings.Contains(err.Error(), "tokens per minute") {
+		t.Fatalf("Ожидалась ошибка 429 с сообщением Groq, получено: %v", err)
+	}
+	if wait := time.Until(limiter.resumeAt); wait < 7*time.Second || wait > 7360*time.Millisecond {
+		t.Errorf("Ответ 429 должен приостанавливать запросы на 7.36s, пауза %v", wait)
+	}
+	if !limiter.tokensKnown || limiter.tokensRemaining != 120 {
+		t.Errorf("Остаток лимита токенов не учтен: %+v", limiter)
+	}
+
+	// Запрос больше остатка ждет сброса лимита токенов
+	start := time.Now()
+	limiter.WaitTokens(500)
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Запрос больше остатка должен ждать сброса лимита, ожидание %v", elapsed)
+	}
+
+	if d, ok := tryAgainWait([]byte(`{"error": {"message": "Please try again in 1m2.5s."}}`)); !ok || d != 62500*time.Millisecond {
+		t.Errorf("tryAgainWait() = %v, %v", d, ok)
+	}
+}
+
+func TestDeepSeekReasoning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "reasoning_content": "Сначала разберу структуру документа...", "content": "# Итог\n\nОбогащенный текст"}}], "usage": {"prompt_tokens": 10, "completion_tokens": 40}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "deepseek-reasoner", ModelAPIURL: server.URL + "/chat/completions", Provider: "deepseek"}
+	got, err := enrichContent(config, "текст", NewRateLimiter(RequestsPerMinute))
+	if err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	if got != "# Итог\n\nОбогащенный текст" {
+		t.Errorf("Рассуждения не должны попадать в результат: %q", got)
+	}
+
+	cases := []struct {
+		message map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{map[string]interface{}{"content": "<think>\nрассуждения\n</think>\n\nОтвет"}, "\n\nОтвет", false},
+		{map[string]interface{}{"content": "Ответ с <think> внутри"}, "Ответ с <think> внутри", false},
+		{map[string]interface{}{"content": "<think>оборвано на середине"}, "", true},
+		{map[string]interface{}{"content": nil, "reasoning_content": "только рассуждения"}, "", true},
+		{map[string]interface{}{"content": 42}, "", true},
+	}
+	for _, c := range cases {
+		got, err := chatMessageText(c.message)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("chatMessageText(%v) = %q, %v", c.message, got, err)
+		}
+	}
+}
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var path string
+	var request struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Некорректный запрос: %v", err)
+		}
+		// Сервер без поля usage (как часть локальных серверов)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	// Адрес без ключевых слов провайдеров и без пути запроса
+	config := &Config{ModelName: "qwen2.5-7b-instruct", ModelAPIURL: server.URL + "/v1", Provider: providerOpenAICompatible, MaxTokens: 100}
+	text, usage, err := enrichContentWithUsage(config, "текст", NewRateLimiter(RequestsPerMinute))
+	if err != nil {
+		t.Fatalf("enrichContentWithUsage() вернул ошибку: %v", err)
+	}
+	if path != "/v1/chat/completions" {
+		t.Errorf("Запрос должен идти на /v1/chat/completions, получено %q", path)
+	}
+	if request.Model != "qwen2.5-7b-instruct" || len(request.Messages) != 1 || !strings.Contains(request.Messages[0].Content, "текст") {
+		t.Errorf("Ожидался запрос в формате Chat Completions: %+v", request)
+	}
+	if text != "Обогащенный текст" || !usage.Estimated || usage.CompletionTokens == 0 {
+		t.Errorf("Без usage токены должны оцениваться: %q, %+v", text, usage)
+	}
+
+	// Полный адрес не дополняется
+	p, _ := providerByName(providerOpenAICompatible)
+	if got := p.requestURL("http://localhost:8080/v1/chat/completions"); got != "http://localhost:8080/v1/chat/completions" {
+		t.Errorf("requestURL() = %q", got)
+	}
+	if detectProvider("http://localhost:1234/v1") != nil {
+		t.Error("openai-compatible не должен определяться по адресу")
+	}
+}
+
+func TestReasoningModelRequest(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = nil
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Некорректный запрос: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "o4-mini", ModelAPIURL: server.URL, Provider: providerOpenAICompatible, Temperature: 0.7, MaxTokens: 100, ReasoningEffort: "low"}
+	if _, err := enrichContent(config, "текст", NewRateLimiter(RequestsPerMinute)); err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	if _, ok := request["temperature"]; ok {
+		t.Errorf("Модели с рассуждениями не принимают temperature: %v", request)
+	}
+	if _, ok := request["max_tokens"]; ok || request["max_completion_tokens"] == nil || request["reasoning_effort"] != "low" {
+		t.Errorf("Ожидались max_completion_tokens и reasoning_effort: %v", request)
+	}
+
+	// Явное reasoning_model=false возвращает обычные параметры
+	config.ReasoningModel = "false"
+	config.ReasoningEffort = ""
+	if _, err := enrichContent(config, "текст", NewRateLimiter(RequestsPerMinute)); err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	if request["temperature"] == nil || request["max_tokens"] == nil || request["reasoning_effort"] != nil {
+		t.Errorf("Ожидались temperature и max_tokens: %v", request)
+	}
+	if !(&Config{ModelName: "openai/gpt-5"}).reasoningModel() || (&Config{ModelName: "gpt-4o"}).reasoningModel() {
+		t.Error("Модели с рассуждениями должны определяться по встроенной таблице")
+	}
+}
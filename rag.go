@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Фрагмент документа из директории контекста
+type contextChunk struct {
+	Source string
+	Text   string
+	Vector []float32
+}
+
+// Индекс фрагментов контекста для поиска похожих на входной документ
+type contextIndex struct {
+	chunks   []contextChunk
+	embedder Embedder
+}
+
+// Построение индекса по всем .md/.txt файлам директории контекста (один раз за запуск)
+func buildContextIndex(dir string, chunkWords int, embedder Embedder) (*contextIndex, error) {
+	index := &contextIndex{embedder: embedder}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".md" && ext != ".txt") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errorf("ошибка при чтении файла контекста %s: %v", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		for _, text := range splitIntoChunks(string(data), chunkWords) {
+			index.chunks = append(index.chunks, contextChunk{Source: rel, Text: text})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при обходе директории контекста: %v", err)
+	}
+
+	if len(index.chunks) == 0 {
+		return index, nil
+	}
+
+	texts := make([]string, len(index.chunks))
+	for i, c := range index.chunks {
+		texts[i] = c.Text
+	}
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return nil, errorf("ошибка при построении векторов контекста: %v", err)
+	}
+	for i := range index.chunks {
+		index.chunks[i].Vector = vectors[i]
+	}
+
+	logf("Индекс контекста построен: %d фрагментов из %s", len(index.chunks), dir)
+	return index, nil
+}
+
+// Разбиение текста на фрагменты по абзацам размером не более chunkWords слов
+func splitIntoChunks(text string, chunkWords int) []string {
+	var chunks []string
+	var current []string
+	words := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.TrimSpace(strings.Join(current, "\n\n")))
+		}
+		current, words = nil, 0
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		n := len(strings.Fields(para))
+		// Новый раздел начинает новый фрагмент
+		if words > 0 && (words+n > chunkWords || isHeadingLine(para)) {
+			flush()
+		}
+		current = append(current, para)
+		words += n
+	}
+	flush()
+	return chunks
+}
+
+// Поиск k фрагментов, наиболее похожих на текст
+func (idx *contextIndex) Search(text string, k int) ([]contextChunk, error) {
+	if idx == nil || len(idx.chunks) == 0 || k <= 0 {
+		return nil, nil
+	}
+	vectors, err := idx.embedder.Embed([]string{text})
+	if err != nil {
+		return nil, errorf("ошибка при построении вектора документа: %v", err)
+	}
+
+	type scored struct {
+		chunk contextChunk
+		score float64
+	}
+	results := make([]scored, 0, len(idx.chunks))
+	for _, c := range idx.chunks {
+		if score := cosineSimilarity(vectors[0], c.Vector); score > 0 {
+			results = append(results, scored{c, score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+
+	if len(results) > k {
+		results = results[:k]
+	}
+	chunks := make([]contextChunk, len(results))
+	for i, r := range results {
+		chunks[i] = r.chunk
+	}
+	return chunks, nil
+}
+
+// Добавление найденных фрагментов контекста перед промптом
+func withContextChunks(prompt string, chunks []contextChunk) string {
+	return withSourceChunks(prompt, "Project context (use its terminology and facts, do not copy it verbatim):", chunks)
+}
+
+// Добавление фрагментов с указанием источника перед промптом
+func withSourceChunks(prompt, header string, chunks []contextChunk) string {
+	if len(chunks) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for _, c := range chunks {
+		fmt.Fprintf(&b, "\n--- %s ---\n%s\n", filepath.ToSlash(c.Source), c.Text)
+	}
+	b.WriteString("\n---\n\n")
+	b.WriteString(prompt)
+	return b.String()
+}
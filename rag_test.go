@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContextIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"glossary.md": "# Глоссарий\n\nШлюз оплаты принимает платежи клиентов и передает их в банк.\n\n# Доставка\n\nКурьерская служба доставляет заказы по городу.",
+		"team.txt":    "Команда поддержки отвечает на обращения клиентов круглосуточно.",
+		"image.png":   "не текст",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать файл контекста %s: %v", name, err)
+		}
+	}
+
+	index, err := buildContextIndex(dir, 200, localEmbedder{})
+	if err != nil {
+		t.Fatalf("buildContextIndex() вернул ошибку: %v", err)
+	}
+	if len(index.chunks) != 3 {
+		t.Fatalf("Ожидалось 3 фрагмента, получено %d", len(index.chunks))
+	}
+
+	chunks, err := index.Search("Как шлюз оплаты передает платежи в банк?", 1)
+	if err != nil {
+		t.Fatalf("Search() вернул ошибку: %v", err)
+	}
+	if len(chunks) != 1 || !strings.Contains(chunks[0].Text, "Шлюз оплаты") {
+		t.Fatalf("Ожидался фрагмент про шлюз оплаты, получено %+v", chunks)
+	}
+
+	prompt := withContextChunks("Исходный промпт", chunks)
+	if !strings.HasSuffix(prompt, "Исходный промпт") || !strings.Contains(prompt, "glossary.md") {
+		t.Errorf("Контекст должен добавляться перед промптом с указанием источника: %q", prompt)
+	}
+	if withContextChunks("Промпт", nil) != "Промпт" {
+		t.Error("Без фрагментов промпт не должен меняться")
+	}
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	text := "один два три\n\nчетыре пять\n\n# Раздел\n\nшесть"
+	chunks := splitIntoChunks(text, 4)
+	if len(chunks) != 3 {
+		t.Fatalf("Ожидалось 3 фрагмента, получено %d: %q", len(chunks), chunks)
+	}
+	if !strings.HasPrefix(chunks[2], "# Раздел") {
+		t.Errorf("Заголовок должен начинать новый фрагмент: %q", chunks[2])
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Ведро токенов: емкость burst, равномерное пополнение rate в секунду. Запрос
+// резервирует токены сразу, поэтому уровень может уйти в минус - это время
+// ожидания следующих запросов
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64
+	level    float64
+	last     time.Time
+}
+
+// Ведро на perMinute токенов в минуту с запасом burst (0 - perMinute), изначально полное
+func newTokenBucket(perMinute, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &tokenBucket{
+		capacity: float64(burst),
+		rate:     float64(perMinute) / time.Minute.Seconds(),
+		level:    float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Пополнение за время с прошлого обращения (вызывается под mu)
+func (b *tokenBucket) refill(now time.Time) {
+	b.level += now.Sub(b.last).Seconds() * b.rate
+	if b.level > b.capacity {
+		b.level = b.capacity
+	}
+	b.last = now
+}
+
+// Резервирование n токенов (не больше емкости ведра) и время ожидания до их пополнения
+func (b *tokenBucket) Reserve(n int) (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if float64(n) > b.capacity {
+		n = int(b.capacity)
+	}
+	b.level -= float64(n)
+	if b.level >= 0 {
+		return n, 0
+	}
+	return n, time.Duration(-b.level / b.rate * float64(time.Second))
+}
+
+// Возврат неизрасходованной части резерва или доплата перерасхода
+func (b *tokenBucket) Adjust(reserved, used int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	b.level += float64(reserved - used)
+	if b.level > b.capacity {
+		b.level = b.capacity
+	}
+}
+
+// Ожидание запросов в ограничителе частоты за запуск
+type rateLimitStats struct {
+	// Запросы, которые ждали ограничителя или паузы провайдера
+	Waits          int     `json:"waits"`
+	WaitSeconds    float64 `json:"wait_seconds"`
+	MaxWaitSeconds float64 `json:"max_wait_seconds"`
+}
+
+// Ограничитель частоты запросов
+type RateLimiter struct {
+	// Запросы в минуту с запасом burst
+	requests *tokenBucket
+	mu       sync.Mutex
+	// Момент, до которого запросы приостановлены по лимитам провайдера
+	resumeAt time.Time
+	// Остаток лимита токенов в минуту по последнему ответу и момент его сброса
+	tokensKnown     bool
+	tokensRemaining int
+	tokensResetAt   time.Time
+	// Общий с другими экземплярами лимит (nil - только локальный)
+	shared *sharedLimiter
+	// Лимит tokens_per_minute по оценке токенов запросов (nil - не задан)
+	tpm *tokenBucket
+	// Суточный лимит запросов и токенов (nil - не задан)
+	daily *dailyUsage
+	// Время ожидания запросов
+	stats rateLimitStats
+}
+
+// Создание нового ограничителя частоты запросов: запас равен лимиту в минуту
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	return &RateLimiter{requests: newTokenBucket(requestsPerMinute, requestsPerMinute)}
+}
+
+// Ограничитель частоты запросов по параметрам конфигурации: запросы в минуту с запасом
+// burst, токены в минуту и суточный лимит
+func (c *Config) newRateLimiter() *RateLimiter {
+	limiter := &RateLimiter{requests: newTokenBucket(c.requestsPerMinute(), c.Burst), daily: newDailyUsage(c)}
+	if c.TokensPerMinute > 0 {
+		limiter.tpm = newTokenBucket(c.TokensPerMinute, c.TokensPerMinute)
+	}
+	return limiter
+}
+
+// Учет ожидания запроса (короче миллисекунды не считается)
+func (r *RateLimiter) recordWait(d time.Duration) {
+	if d < time.Millisecond {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Waits++
+	r.stats.WaitSeconds += d.Seconds()
+	if d.Seconds() > r.stats.MaxWaitSeconds {
+		r.stats.MaxWaitSeconds = d.Seconds()
+	}
+}
+
+// Ожидание запросов за время работы ограничителя
+func (r *RateLimiter) Stats() rateLimitStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Ожидание доступности токена и окончания паузы по лимитам провайдера
+func (r *RateLimiter) Wait() {
+	start := time.Now()
+	if _, wait := r.requests.Reserve(1); wait > 0 {
+		time.Sleep(wait)
+	}
+	r.mu.Lock()
+	wait := time.Until(r.resumeAt)
+	r.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if r.shared != nil {
+		r.shared.Wait()
+	}
+	r.recordWait(time.Since(start))
+}
+
+// Учет остатка лимита токенов в минуту из заголовков ответа провайдера
+func (r *RateLimiter) ObserveTokens(remaining int, reset time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokensKnown = true
+	r.tokensRemaining = remaining
+	r.tokensResetAt = time.Now().Add(reset)
+}
+
+// Ожидание сброса лимита токенов в минуту, если в остатке меньше, чем нужно запросу
+func (r *RateLimiter) WaitTokens(need int) {
+	r.mu.Lock()
+	wait := time.Duration(0)
+	if r.tokensKnown && need > r.tokensRemaining {
+		wait = time.Until(r.tokensResetAt)
+		r.tokensKnown = false
+	}
+	r.mu.Unlock()
+	if wait > 0 {
+		logf("Остаток лимита токенов провайдера меньше запроса (~%d токенов), пауза %v", need, wait)
+		time.Sleep(wait)
+		r.recordWait(wait)
+	}
+}
+
+// Резервирование оценки токенов запроса в лимите tokens_per_minute с ожиданием,
+// если в лимите нет места; возвращает размер резерва для SettleTokens
+func (r *RateLimiter) ReserveTokens(estimate int) int {
+	if r.tpm == nil {
+		return 0
+	}
+	reserved, wait := r.tpm.Reserve(estimate)
+	if wait > 0 {
+		logf("Лимит токенов в минуту: запрос (~%d токенов) ждет %v", reserved, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+		r.recordWait(wait)
+	}
+	return reserved
+}
+
+// Учет фактического расхода токенов вместо резерва
+func (r *RateLimiter) SettleTokens(reserved int, usage Usage) {
+	if r.tpm == nil {
+		return
+	}
+	r.tpm.Adjust(reserved, usage.PromptTokens+usage.CompletionTokens)
+}
+
+// Приостановка запросов на время до сброса исчерпанного лимита провайдера
+func (r *RateLimiter) PauseFor(d time.Duration) {
+	r.mu.Lock()
+	if resume := time.Now().Add(d); resume.After(r.resumeAt) {
+		r.resumeAt = resume
+	}
+	r.mu.Unlock()
+	if r.shared != nil {
+		r.shared.PauseFor(d)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(6000, 0)
+
+	// Полное ведро пропускает запрос сразу
+	reserved, wait := bucket.Reserve(5000)
+	if reserved != 5000 || wait != 0 {
+		t.Errorf("Reserve(5000) = %d, %v, ожидалось 5000 без ожидания", reserved, wait)
+	}
+	// Следующий запрос ждет пополнения недостающих ~1000 токенов (100 в секунду)
+	if _, wait := bucket.Reserve(2000); wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("ожидание второго запроса %v, ожидалось около 10s", wait)
+	}
+	// Фактический расход меньше резерва возвращает токены
+	bucket.Adjust(2000, 500)
+	if _, wait := bucket.Reserve(400); wait != 0 {
+		t.Errorf("после возврата резерва запрос должен пройти сразу, ожидание %v", wait)
+	}
+	// Запрос больше лимита ограничивается емкостью ведра
+	if reserved, _ := newTokenBucket(1000, 0).Reserve(5000); reserved != 1000 {
+		t.Errorf("резерв запроса больше лимита: %d, ожидалось 1000", reserved)
+	}
+}
+
+func TestTokensPerMinuteLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "Обогащенный текст"}}},
+			"usage":   map[string]int{"prompt_tokens": 40, "completion_tokens": 10},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "test", ModelAPIURL: server.URL, Provider: providerOpenAICompatible, MaxTokens: 1000, TokensPerMinute: 60000}
+	limiter := config.newRateLimiter()
+	if limiter.tpm == nil {
+		t.Fatal("лимит tokens_per_minute не задан в ограничителе")
+	}
+	if _, err := enrichContent(config, "текст", limiter); err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	// После ответа резерв (оценка вместе с max_tokens) заменен фактическим расходом
+	limiter.tpm.mu.Lock()
+	spent := limiter.tpm.capacity - limiter.tpm.level
+	limiter.tpm.mu.Unlock()
+	if spent < 40 || spent > 60 {
+		t.Errorf("в лимите учтено %.0f токенов, ожидалось около 50", spent)
+	}
+
+	if limiter := (&Config{}).newRateLimiter(); limiter.tpm != nil {
+		t.Error("без tokens_per_minute локальный лимит токенов не нужен")
+	}
+}
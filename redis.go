@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ответ Redis с ошибкой (-ERR ...)
+type redisError string
+
+// Текст ошибки Redis
+func (e redisError) Error() string { return string(e) }
+
+// Минимальный клиент Redis (протокол RESP2) для общих лимитов и распределения файлов
+// между экземплярами; соединение одно и восстанавливается при ошибке
+type redisClient struct {
+	addr     string
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Клиент по адресу redis://[[пользователь]:пароль@]хост[:порт][/база]
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return nil, errorf("некорректный адрес Redis %q: ожидается redis://хост:порт/база", rawURL)
+	}
+	c := &redisClient{addr: u.Host, timeout: 10 * time.Second}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, errorf("некорректный номер базы Redis %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Подключение, авторизация и выбор базы
+func (c *redisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return errorf("не удалось подключиться к Redis %s: %v", c.addr, err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.roundTrip(args); err != nil {
+			c.close()
+			return errorf("ошибка авторизации в Redis: %v", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			c.close()
+			return errorf("ошибка выбора базы Redis: %v", err)
+		}
+	}
+	return nil
+}
+
+// Закрытие соединения
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+// Выполнение команды; ответ - string, int64, nil или []interface{}
+func (c *redisClient) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(args)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		// Сетевая ошибка: соединение пересоздается при следующей команде
+		c.close()
+		return nil, errorf("ошибка обмена с Redis: %v", err)
+	}
+	return reply, err
+}
+
+// Отправка команды и чтение ответа
+func (c *redisClient) roundTrip(args []string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.r)
+}
+
+// Чтение ответа RESP
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errorf("пустой ответ Redis")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				var re redisError
+				if !errors.As(err, &re) {
+					return nil, err
+				}
+			}
+		}
+		return items, nil
+	}
+	return nil, errorf("некорректный ответ Redis: %q", line)
+}
+
+// Целочисленный ответ команды
+func (c *redisClient) Int(args ...string) (int64, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errorf("неожиданный ответ Redis на %s: %v", args[0], reply)
+	}
+	return n, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Минимальный Redis в памяти: команды, которые использует rich
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	values   map[string]string
+	expires  map[string]time.Time
+	commands []string
+}
+
+// Запуск fakeRedis; возвращает адрес redis://
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось запустить тестовый Redis: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{password: password, values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, "redis://" + ln.Addr().String() + "/1"
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if cmd == "AUTH" {
+			if args[len(args)-1] != f.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			fmt.Fprint(conn, "+OK\r\n")
+			continue
+		}
+		if !authed {
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		fmt.Fprint(conn, f.exec(cmd, args[1:]))
+	}
+}
+
+func (f *fakeRedis) exec(cmd string, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, cmd)
+	for k, at := range f.expires {
+		if time.Now().After(at) {
+			delete(f.values, k)
+			delete(f.expires, k)
+		}
+	}
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.values[args[0]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "INCR":
+		n, _ := strconv.Atoi(f.values[args[0]])
+		n++
+		f.values[args[0]] = strconv.Itoa(n)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "PEXPIRE":
+		ms, _ := strconv.Atoi(args[1])
+		f.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "PTTL":
+		if _, ok := f.values[args[0]]; !ok {
+			return ":-2\r\n"
+		}
+		at, ok := f.expires[args[0]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(at).Milliseconds())
+	case "SET":
+		key, value := args[0], args[1]
+		var ttl time.Duration
+		nx := false
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			}
+		}
+		if _, exists := f.values[key]; nx && exists {
+			return "$-1\r\n"
+		}
+		f.values[key] = value
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "EVAL":
+		// Только скрипт снятия закрепления: GET KEYS[1] == ARGV[1] -> DEL
+		if args[0] != releaseClaimScript {
+			return "-ERR unknown script\r\n"
+		}
+		if f.values[args[2]] == args[3] {
+			delete(f.values, args[2])
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+func TestRedisClient(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+
+	client, err := newRedisClient(strings.Replace(addr, "redis://", "redis://:secret@", 1))
+	if err != nil {
+		t.Fatalf("newRedisClient() вернул ошибку: %v", err)
+	}
+	if reply, err := client.Do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("PING: %v, %v", reply, err)
+	}
+	if n, err := client.Int("INCR", "counter"); err != nil || n != 1 {
+		t.Errorf("INCR: %d, %v", n, err)
+	}
+	if reply, err := client.Do("GET", "missing"); err != nil || reply != nil {
+		t.Errorf("GET отсутствующего ключа: %v, %v", reply, err)
+	}
+	if _, err := client.Do("BOGUS"); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Ожидалась ошибка Redis, получено %v", err)
+	}
+
+	wrong, _ := newRedisClient(addr)
+	if _, err := wrong.Do("PING"); err == nil {
+		t.Error("Без пароля ожидалась ошибка")
+	}
+	if _, err := newRedisClient("http://localhost"); err == nil {
+		t.Error("Ожидалась ошибка для адреса не redis://")
+	}
+}
+
+func TestSharedLimiter(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	client, _ := newRedisClient(addr)
+	limiter := &sharedLimiter{client: client, prefix: "test", perMinute: 2, window: 300 * time.Millisecond}
+
+	// Третий запрос в окне ждет следующего окна
+	window := int64(limiter.window)
+	startSlot := time.Now().UnixNano() / window
+	for i := 0; i < 3; i++ {
+		limiter.Wait()
+	}
+	if slot := time.Now().UnixNano() / window; slot == startSlot {
+		t.Error("Три запроса прошли в одном окне при лимите 2")
+	}
+
+	// Пауза одного экземпляра действует на всех
+	other := &sharedLimiter{client: client, prefix: "test", perMinute: 100, window: time.Minute}
+	limiter.PauseFor(200 * time.Millisecond)
+	start := time.Now()
+	other.Wait()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Общая пауза не соблюдена: %v", elapsed)
+	}
+}
+
+func TestWorkClaims(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	client, _ := newRedisClient(addr)
+	a := &workClaims{client: client, prefix: "test", owner: "a", ttl: time.Minute}
+	b := &workClaims{client: client, prefix: "test", owner: "b", ttl: time.Minute}
+
+	if ok, err := a.Claim("docs/x.md"); err != nil || !ok {
+		t.Fatalf("Первое закрепление: %v, %v", ok, err)
+	}
+	if ok, _ := b.Claim("docs/x.md"); ok {
+		t.Error("Файл закреплен за двумя экземплярами")
+	}
+	// Чужое закрепление не снимается
+	if err := b.Release("docs/x.md"); err != nil {
+		t.Fatalf("Release() вернул ошибку: %v", err)
+	}
+	if ok, _ := b.Claim("docs/x.md"); ok {
+		t.Error("Закрепление снято чужим экземпляром")
+	}
+	if err := a.Release("docs/x.md"); err != nil {
+		t.Fatalf("Release() вернул ошибку: %v", err)
+	}
+	if ok, _ := b.Claim("docs/x.md"); !ok {
+		t.Error("После снятия закрепления файл должен быть доступен")
+	}
+}
+
+func TestProcessDirectorySharesWork(t *testing.T) {
+	fake, addr := startFakeRedis(t, "")
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка "+name), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл: %v", err)
+		}
+	}
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	// Два экземпляра с отдельными конфигурациями обрабатывают одну входную директорию
+	for i := 0; i < 2; i++ {
+		config := &Config{
+			InputDir:    inputDir,
+			OutputDir:   filepath.Join(tmpDir, fmt.Sprintf("output%d", i)),
+			ModelAPIURL: server.URL + "/v1/chat/completions",
+			Redis:       RedisConfig{URL: addr, Namespace: "test", ShareWork: true, ClaimTTL: time.Minute},
+		}
+		if err := processDirectory(config, filepath.Join(tmpDir, fmt.Sprintf("test%d.cfg", i))); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Каждый файл должен обрабатываться одним экземпляром, запросов: %d", requests)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if !strings.Contains(strings.Join(fake.commands, " "), "INCR") {
+		t.Errorf("Общий лимит запросов не использовался: %v", fake.commands)
+	}
+}
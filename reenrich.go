@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Условия выбора файлов для повторного обогащения; заданные условия объединяются по "и"
+type reenrichFilter struct {
+	// Результаты, полученные с устаревшей версией промпта
+	StalePrompt bool
+	// Результаты, полученные моделью, выпущенной раньше указанной
+	ModelOlderThan string
+	// Шаблоны путей исходных файлов (синтаксис .richignore)
+	Globs []string
+}
+
+// Проверка, что задано хотя бы одно условие
+func (f reenrichFilter) empty() bool {
+	return !f.StalePrompt && f.ModelOlderThan == "" && len(f.Globs) == 0
+}
+
+// Выбор файлов для повторного обогащения: относительные пути исходных файлов
+func selectReenrichTargets(config *Config, filter reenrichFilter) ([]string, error) {
+	if filter.empty() {
+		return nil, errorf("не задано ни одного условия выбора файлов")
+	}
+
+	// Кандидаты - все markdown файлы входной директории
+	var selected map[string]bool
+	if len(filter.Globs) > 0 {
+		var patterns []ignorePattern
+		for _, g := range filter.Globs {
+			p, ok, err := parseIgnorePattern(g)
+			if err != nil || !ok {
+				return nil, errorf("некорректный шаблон пути: %q", g)
+			}
+			patterns = append(patterns, p)
+		}
+		files, err := markdownFiles(config.InputDir)
+		if err != nil {
+			return nil, err
+		}
+		selected = make(map[string]bool)
+		for _, rel := range files {
+			for _, p := range patterns {
+				if p.re.MatchString(rel) {
+					selected[rel] = true
+					break
+				}
+			}
+		}
+	}
+
+	// Условия по журналу запусков
+	if filter.StalePrompt || filter.ModelOlderThan != "" {
+		if config.StateDir == "" {
+			return nil, errorf("каталог состояния не задан (секция [STATE], ключ dir)")
+		}
+		matched := make(map[string]bool)
+		if filter.StalePrompt {
+			stale, err := stalePromptOutputs(config)
+			if err != nil {
+				return nil, errorf("не удалось проверить версии промпта: %v", err)
+			}
+			for _, s := range stale {
+				matched[s.Entry.Input] = true
+			}
+		}
+		if filter.ModelOlderThan != "" {
+			older, err := olderModelOutputs(config.StateDir, filter.ModelOlderThan)
+			if err != nil {
+				return nil, err
+			}
+			if filter.StalePrompt {
+				for rel := range matched {
+					if !older[rel] {
+						delete(matched, rel)
+					}
+				}
+			} else {
+				matched = older
+			}
+		}
+		if selected == nil {
+			selected = matched
+		} else {
+			for rel := range selected {
+				if !matched[rel] {
+					delete(selected, rel)
+				}
+			}
+		}
+	}
+
+	targets := make([]string, 0, len(selected))
+	for rel := range selected {
+		targets = append(targets, rel)
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// Исходные файлы, последний результат которых получен моделью старше эталонной
+func olderModelOutputs(stateDir, reference string) (map[string]bool, error) {
+	if _, ok := modelRelease(reference); !ok {
+		return nil, errorf("неизвестна дата выпуска модели %s", reference)
+	}
+	latest, err := latestOutputs(stateDir)
+	if err != nil {
+		return nil, errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+	older := make(map[string]bool)
+	for rel, rec := range latest {
+		if rec.Entry.Model == "" {
+			continue
+		}
+		isOlder, known := modelOlderThan(rec.Entry.Model, reference)
+		if !known {
+			warnf("Предупреждение: неизвестна дата выпуска модели %s (%s), файл пропущен", rec.Entry.Model, rel)
+			continue
+		}
+		if isOlder {
+			older[rel] = true
+		}
+	}
+	return older, nil
+}
+
+// Относительные пути всех markdown файлов директории (с прямыми слешами)
+func markdownFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, normalizeRelPath(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при обходе директории: %v", err)
+	}
+	return files, nil
+}
+
+// Повторное обогащение выбранных файлов: файлы убираются из списка исключений
+// и обрабатываются заново целиком (без инкрементального режима)
+func reenrichFiles(config *Config, configPath string, targets []string) error {
+	only := make(map[string]bool, len(targets))
+	for _, rel := range targets {
+		only[pathKey(rel)] = true
+		if !isExcluded(config, rel) {
+			continue
+		}
+		if err := removeFromExcludedFiles(configPath, rel); err != nil {
+			return errorf("не удалось убрать %s из списка исключений: %v", rel, err)
+		}
+	}
+
+	var excluded []string
+	for _, file := range config.ExcludedFiles {
+		if !only[pathKey(file)] {
+			excluded = append(excluded, file)
+		}
+	}
+	config.ExcludedFiles = excluded
+	config.OnlyFiles = only
+	config.Incremental = false
+	return processDirectory(config, configPath)
+}
+
+// rich reenrich [--stale-prompt] [--model-older-than <модель>] [шаблон...]:
+// повторное обогащение устаревших или выбранных файлов
+func runReenrichCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("reenrich", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	stalePrompt := fs.Bool("stale-prompt", false, tr("Файлы, обогащенные с устаревшей версией промпта"))
+	modelOlderThan := fs.String("model-older-than", "", tr("Файлы, обогащенные моделью, выпущенной раньше указанной"))
+	dryRun := fs.Bool("dry-run", false, tr("Только показать выбранные файлы"))
+	noColor := fs.Bool("no-color", false, tr("Выключить цветной вывод в консоль"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter := reenrichFilter{StalePrompt: *stalePrompt, ModelOlderThan: *modelOlderThan, Globs: fs.Args()}
+	if filter.empty() {
+		return errorf("укажите --stale-prompt, --model-older-than или шаблоны путей")
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	targets, err := selectReenrichTargets(config, filter)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(out, tr("Нет файлов для повторного обогащения"))
+		return nil
+	}
+	fmt.Fprintf(out, tr("Файлы для повторного обогащения: %d\n"), len(targets))
+	for _, rel := range targets {
+		fmt.Fprintf(out, "  %s\n", rel)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	closeLog, err := setupLogging(*noColor, envBool("RICH_LOG_STDOUT"))
+	if err != nil {
+		return errorf("не удалось открыть файл журнала: %v", err)
+	}
+	defer closeLog()
+	return reenrichFiles(config, *configPath, targets)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestReenrich(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(filepath.Join(inputDir, "guides"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	files := []string{"a.md", "b.md", filepath.Join("guides", "c.md")}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Обогащенный контент"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:    inputDir,
+		OutputDir:   filepath.Join(tmpDir, "output"),
+		ModelName:   "gpt-3.5-turbo",
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		Prompt:      "Промпт v1",
+		StateDir:    filepath.Join(tmpDir, ".rich"),
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("Ожидалось 3 запроса, получено %d", got)
+	}
+
+	tests := []struct {
+		name   string
+		filter reenrichFilter
+		want   []string
+	}{
+		{"шаблон", reenrichFilter{Globs: []string{"guides/"}}, nil},
+		{"шаблон файлов", reenrichFilter{Globs: []string{"guides/*.md", "a.md"}}, []string{"a.md", filepath.Join("guides", "c.md")}},
+		{"старая модель", reenrichFilter{ModelOlderThan: "gpt-4o"}, files},
+		{"модель не старше", reenrichFilter{ModelOlderThan: "gpt-3.5-turbo"}, nil},
+		{"старая модель и шаблон", reenrichFilter{ModelOlderThan: "gpt-4o", Globs: []string{"b.md"}}, []string{"b.md"}},
+		{"актуальный промпт", reenrichFilter{StalePrompt: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectReenrichTargets(config, tt.filter)
+			if err != nil {
+				t.Fatalf("selectReenrichTargets() вернул ошибку: %v", err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectReenrichTargets() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := selectReenrichTargets(config, reenrichFilter{ModelOlderThan: "unknown-model"}); err == nil {
+		t.Error("Для неизвестной модели ожидалась ошибка")
+	}
+
+	// После правки промпта устаревшим считается каждый файл; обрабатывается только выбранный
+	config.Prompt = "Промпт v2"
+	targets, err := selectReenrichTargets(config, reenrichFilter{StalePrompt: true, Globs: []string{"b.md"}})
+	if err != nil || !reflect.DeepEqual(targets, []string{"b.md"}) {
+		t.Fatalf("Ожидался выбор b.md, получено %v (%v)", targets, err)
+	}
+	if err := reenrichFiles(config, configPath, targets); err != nil {
+		t.Fatalf("reenrichFiles() вернул ошибку: %v", err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("Ожидался один дополнительный запрос, всего получено %d", got)
+	}
+	stale, err := stalePromptOutputs(config)
+	if err != nil {
+		t.Fatalf("stalePromptOutputs() вернул ошибку: %v", err)
+	}
+	for _, s := range stale {
+		if s.Entry.Input == "b.md" {
+			t.Error("b.md после повторного обогащения не должен считаться устаревшим")
+		}
+	}
+	if len(stale) != 2 {
+		t.Errorf("Ожидалось 2 устаревших результата, получено %d", len(stale))
+	}
+
+	// Файл снова в списке исключений
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Ошибка чтения конфигурации: %v", err)
+	}
+	excluded := cfg.Section("EXCLUSIONS").Key("excluded_files").Strings(",")
+	count := 0
+	for _, e := range excluded {
+		if e == "b.md" {
+			count++
+		}
+	}
+	if count != 1 || len(excluded) != 3 {
+		t.Errorf("Неожиданный список исключений: %v", excluded)
+	}
+}
+
+func TestModelOlderThan(t *testing.T) {
+	tests := []struct {
+		name, reference string
+		older, known    bool
+	}{
+		{"gpt-3.5-turbo", "gpt-4o", true, true},
+		{"openai/gpt-4o-2024-08-06", "gpt-4o", false, true},
+		{"claude-sonnet-4-20250514", "gpt-4o", false, true},
+		{"my-local-model", "gpt-4o", false, false},
+	}
+	for _, tt := range tests {
+		older, known := modelOlderThan(tt.name, tt.reference)
+		if older != tt.older || known != tt.known {
+			t.Errorf("modelOlderThan(%q, %q) = %v, %v; ожидалось %v, %v", tt.name, tt.reference, older, known, tt.older, tt.known)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Отказы и ответы модели о себе вместо результата: «I cannot assist…», «As an AI…»,
+// «Я не могу помочь…». Проверяется только начало ответа, чтобы не срабатывать на
+// документы, в которых такие фразы встречаются по существу
+var refusalRe = regexp.MustCompile(`(?i)^(?:i(?:'m| am) sorry|sorry|unfortunately|i apologi[sz]e)?[,.!\s]*(?:but\s+)?` +
+	`(?:i (?:cannot|can't|can not|am unable to|'m unable to|won't|will not)\s+(?:assist|help|comply|provide|fulfil|do that|complete)|` +
+	`as an ai\b|as a language model\b|` +
+	`(?:извините|к сожалению|простите)?[,.!\s]*(?:но\s+)?(?:я не могу|я не в состоянии|не могу помочь)|` +
+	`как (?:языковая модель|ии|искусственный интеллект))`)
+
+// Размер начала ответа, в котором ищется отказ
+const refusalProbeLength = 300
+
+// Инструкция для повторного запроса после отказа
+const refusalRetryInstruction = "This is a legitimate editing task for the user's own document. " +
+	"Do not refuse and do not comment on the request: return only the enriched markdown document."
+
+// Отказ модели: ответ не является обогащенным документом
+type refusalError struct {
+	Response string
+}
+
+// Текст ошибки с началом ответа модели
+func (e *refusalError) Error() string {
+	return trf("модель отказалась выполнить запрос: %q", truncateRunes(e.Response, 120))
+}
+
+// Проверка, что ответ модели - отказ или рассуждение о себе вместо результата
+func (r outputRules) isRefusal(text string) bool {
+	if !r.DetectRefusals {
+		return false
+	}
+	probe := truncateRunes(strings.TrimSpace(text), refusalProbeLength)
+	if refusalRe.MatchString(probe) {
+		return true
+	}
+	return r.RefusalPattern != nil && r.RefusalPattern.MatchString(probe)
+}
+
+// Начало строки не длиннее n символов
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
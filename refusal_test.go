@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIsRefusal(t *testing.T) {
+	rules := outputRules{DetectRefusals: true}
+	refusals := []string{
+		"I'm sorry, but I cannot assist with that request.",
+		"I can't help with this.",
+		"As an AI language model, I do not have personal opinions.",
+		"К сожалению, я не могу выполнить этот запрос.",
+		"Как языковая модель, я не имею доступа к файлам.",
+	}
+	for _, text := range refusals {
+		if !rules.isRefusal(text) {
+			t.Errorf("отказ не распознан: %q", text)
+		}
+	}
+	documents := []string{
+		"# Заметка\n\nI cannot assist you with that, сказал он.",
+		"Unfortunately, I cannot attend the meeting on Friday.",
+		strings.Repeat("Текст заметки. ", 30) + "As an AI, I cannot help.",
+	}
+	for _, text := range documents {
+		if rules.isRefusal(text) {
+			t.Errorf("документ принят за отказ: %q", text[:40])
+		}
+	}
+	if (outputRules{}).isRefusal(refusals[0]) {
+		t.Error("при выключенной проверке отказ не должен распознаваться")
+	}
+	custom := outputRules{DetectRefusals: true, RefusalPattern: regexp.MustCompile(`^TODO`)}
+	if !custom.isRefusal("TODO: enrich later") {
+		t.Error("дополнительный признак отказа не учтен")
+	}
+}
+
+func TestEnrichContentRetriesRefusal(t *testing.T) {
+	var prompts []string
+	answers := []string{"I'm sorry, but I can't help with that.", "# Обогащенная заметка"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body.Messages[len(body.Messages)-1]["content"])
+		answer := answers[min(len(prompts), len(answers))-1]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": answer}}},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Provider:    providerOpenAICompatible,
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens:   1000,
+		Prompt:      "Обогати",
+		OutputRules: outputRules{DetectRefusals: true, RefusalRetries: 1},
+	}
+	enriched, err := enrichContent(config, "заметка", NewRateLimiter(1000))
+	if err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	if enriched != "# Обогащенная заметка" || len(prompts) != 2 {
+		t.Fatalf("ожидался результат второй попытки, получено %q после %d запросов", enriched, len(prompts))
+	}
+	if !strings.Contains(prompts[1], refusalRetryInstruction) {
+		t.Error("повторный запрос должен содержать уточнение промпта")
+	}
+
+	// Без повторов отказ возвращается ошибкой
+	prompts = nil
+	answers = answers[:1]
+	config.OutputRules.RefusalRetries = 0
+	_, err = enrichContent(config, "заметка", NewRateLimiter(1000))
+	var refusal *refusalError
+	if !errors.As(err, &refusal) {
+		t.Fatalf("ожидалась ошибка отказа, получено %v", err)
+	}
+}
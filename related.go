@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Маркеры блока связанных заметок, блок заменяется при повторной обработке
+const (
+	RelatedStartMarker = "<!-- rich:related -->"
+	RelatedEndMarker   = "<!-- rich:related-end -->"
+)
+
+// Форматы ссылок на связанные заметки
+const (
+	RelatedMarkdown = "markdown"
+	// Вики-ссылки [[заметка|заголовок]] (Obsidian, Logseq)
+	RelatedWiki = "wiki"
+)
+
+// Связанные заметки из секции [RELATED]
+type RelatedConfig struct {
+	Enabled bool
+	// Количество ссылок на самые похожие документы
+	TopK int
+	// Минимальное косинусное сходство документа со ссылкой
+	MinSimilarity float64
+	// Заголовок раздела и формат ссылок
+	Heading string
+	Format  string
+}
+
+// Чтение секции [RELATED]
+func loadRelatedConfig(section *ini.Section) (RelatedConfig, error) {
+	related := RelatedConfig{
+		Enabled:       section.Key("enabled").MustBool(false),
+		TopK:          section.Key("top_k").MustInt(5),
+		MinSimilarity: section.Key("min_similarity").MustFloat64(0.3),
+		Heading:       section.Key("heading").MustString("Связанные заметки"),
+		Format:        strings.ToLower(section.Key("format").MustString(RelatedMarkdown)),
+	}
+	if related.TopK <= 0 {
+		return related, errorf("top_k в секции [RELATED] должен быть положительным: %d", related.TopK)
+	}
+	if related.MinSimilarity < 0 || related.MinSimilarity > 1 {
+		return related, errorf("некорректное значение min_similarity %g: ожидалось от 0 до 1", related.MinSimilarity)
+	}
+	if related.Format != RelatedMarkdown && related.Format != RelatedWiki {
+		return related, errorf("неизвестный формат ссылок %q в секции [RELATED]: ожидалось markdown или wiki", related.Format)
+	}
+	return related, nil
+}
+
+// Документ корпуса для поиска связанных заметок
+type relatedDoc struct {
+	// Путь относительно входной директории
+	RelPath string
+	Title   string
+	Vector  []float32
+}
+
+// Связанная заметка для ссылки
+type relatedLink struct {
+	RelPath    string
+	Title      string
+	Similarity float64
+}
+
+// Векторы всех документов входной директории (один раз за запуск корня). В корпус
+// входят и ранее обработанные документы, кроме запрещенных политикой содержимого
+func buildRelatedIndex(config *Config, inputDir string, embedder Embedder) ([]relatedDoc, error) {
+	corpusConfig := *config
+	corpusConfig.OnlyFiles = nil
+	corpusConfig.ModifiedAfter, corpusConfig.ModifiedBefore = time.Time{}, time.Time{}
+	candidates, err := collectCandidates(&corpusConfig, inputDir, "", newExcludedIndex(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []relatedDoc
+	var texts []string
+	for _, c := range config.Policy.allowedCandidates(candidates) {
+		// Документы других форматов в корпус не входят
+		if !isMarkdownPath(c.Path) {
+			continue
+		}
+		data, err := os.ReadFile(c.Path)
+		if err != nil {
+			return nil, errorf("ошибка при чтении файла: %v", err)
+		}
+		_, body, _ := parseFrontmatter(data)
+		text := strings.TrimSpace(string(body))
+		if text == "" {
+			continue
+		}
+		title, _, _ := describeDocument(normalizeRelPath(c.RelPath), data)
+		docs = append(docs, relatedDoc{RelPath: c.RelPath, Title: title})
+		texts = append(texts, text)
+	}
+	if len(docs) < 2 {
+		return nil, nil
+	}
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return nil, errorf("ошибка при построении векторов документов: %v", err)
+	}
+	for i := range docs {
+		docs[i].Vector = vectors[i]
+	}
+	logf("Корпус связанных заметок: %d документов", len(docs))
+	return docs, nil
+}
+
+// Самые похожие на документ заметки корпуса по убыванию сходства
+func findRelated(docs []relatedDoc, relPath string, k int, minSimilarity float64) []relatedLink {
+	self := -1
+	for i, d := range docs {
+		if samePath(d.RelPath, relPath) {
+			self = i
+			break
+		}
+	}
+	if self < 0 {
+		return nil
+	}
+	var links []relatedLink
+	for i, d := range docs {
+		if i == self {
+			continue
+		}
+		if sim := cosineSimilarity(docs[self].Vector, d.Vector); sim >= minSimilarity && sim > 0 {
+			links = append(links, relatedLink{RelPath: d.RelPath, Title: d.Title, Similarity: sim})
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].Similarity > links[j].Similarity })
+	if len(links) > k {
+		links = links[:k]
+	}
+	return links
+}
+
+// Раздел со ссылками на связанные заметки; ссылки относительны выходному файлу
+// (fromRel - путь результата относительно выходной директории)
+func renderRelated(rc RelatedConfig, fromRel string, links []relatedLink) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", rc.Heading)
+	fromDir := path.Dir(normalizeRelPath(fromRel))
+	for _, l := range links {
+		target := normalizeRelPath(l.RelPath)
+		if rc.Format == RelatedWiki {
+			name := strings.TrimSuffix(path.Base(target), path.Ext(target))
+			if l.Title == "" || l.Title == name {
+				fmt.Fprintf(&b, "- [[%s]]\n", name)
+			} else {
+				fmt.Fprintf(&b, "- [[%s|%s]]\n", name, strings.NewReplacer("[", "", "]", "", "|", "-").Replace(l.Title))
+			}
+			continue
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(target))
+		if err != nil {
+			rel = target
+		}
+		fmt.Fprintf(&b, "- [%s](%s)\n", escapeLinkText(l.Title), escapeLinkPath(filepath.ToSlash(rel)))
+	}
+	return b.String()
+}
+
+// Удаление ранее добавленного раздела связанных заметок
+func stripRelated(text string) string {
+	start := strings.LastIndex(text, RelatedStartMarker)
+	if start < 0 {
+		return text
+	}
+	end := strings.Index(text[start:], RelatedEndMarker)
+	if end < 0 {
+		return text
+	}
+	end += start + len(RelatedEndMarker)
+	return strings.TrimRight(text[:start], "\n") + text[end:]
+}
+
+// Добавление раздела связанных заметок в конец документа (с заменой предыдущего)
+func withRelated(text, section string) string {
+	text = strings.TrimRight(stripRelated(text), "\n")
+	return text + "\n\n" + RelatedStartMarker + "\n" + strings.TrimSpace(section) + "\n" + RelatedEndMarker + "\n"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderRelated(t *testing.T) {
+	links := []relatedLink{
+		{RelPath: "guides/setup.md", Title: "Настройка [кратко]"},
+		{RelPath: "notes/faq.md", Title: "faq"},
+	}
+	md := renderRelated(RelatedConfig{Heading: "Связанные заметки", Format: RelatedMarkdown}, "notes/a.md", links)
+	want := "## Связанные заметки\n\n- [Настройка \\[кратко\\]](../guides/setup.md)\n- [faq](faq.md)\n"
+	if md != want {
+		t.Errorf("markdown ссылки:\n%q\nожидалось\n%q", md, want)
+	}
+	wiki := renderRelated(RelatedConfig{Heading: "См. также", Format: RelatedWiki}, "notes/a.md", links)
+	if !strings.Contains(wiki, "- [[setup|Настройка кратко]]\n") || !strings.Contains(wiki, "- [[faq]]\n") {
+		t.Errorf("вики-ссылки:\n%s", wiki)
+	}
+
+	// Повторное добавление заменяет прежний раздел
+	doc := withRelated(withRelated("# Заметка\n", md), wiki)
+	if strings.Count(doc, RelatedStartMarker) != 1 || strings.Contains(doc, "../guides") {
+		t.Errorf("раздел не заменен:\n%s", doc)
+	}
+}
+
+func TestRelatedNotes(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"kafka.md":       "# Kafka\n\nНастройка брокера Kafka: партиции, реплики и потребители.",
+		"sub/brokers.md": "# Брокеры\n\nБрокер Kafka хранит партиции, реплики читают потребители.",
+		"recipes.md":     "# Пироги\n\nТесто, начинка из яблок, духовка на двести градусов.",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir,
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+		Related: RelatedConfig{Enabled: true, TopK: 1, MinSimilarity: 0.2, Heading: "Связанные заметки", Format: RelatedMarkdown}}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "kafka.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "- [Брокеры](sub/brokers.md)") {
+		t.Errorf("нет ссылки на похожую заметку:\n%s", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(outputDir, "sub", "brokers.md"))
+	if !strings.Contains(string(data), "- [Kafka](../kafka.md)") || strings.Contains(string(data), "recipes.md") {
+		t.Errorf("ссылки связанных заметок sub/brokers.md:\n%s", data)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Запись отчета о результате обработки одного файла
+type reportEntry struct {
+	Path             string           `json:"path"`
+	Status           string           `json:"status"`
+	Language         string           `json:"language,omitempty"`
+	Route            string           `json:"route,omitempty"`
+	Model            string           `json:"model,omitempty"`
+	PromptHash       string           `json:"prompt_hash,omitempty"`
+	PromptTokens     int              `json:"prompt_tokens,omitempty"`
+	CompletionTokens int              `json:"completion_tokens,omitempty"`
+	TokensEstimated  bool             `json:"tokens_estimated,omitempty"`
+	CostUSD          float64          `json:"cost_usd,omitempty"`
+	Error            string           `json:"error,omitempty"`
+	ErrorCategory    string           `json:"error_category,omitempty"`
+	Metrics          *qualityMetrics  `json:"metrics,omitempty"`
+	BrokenLinks      []brokenLink     `json:"broken_links,omitempty"`
+	DuplicateOf      string           `json:"duplicate_of,omitempty"`
+	DurationMS       int64            `json:"duration_ms,omitempty"`
+	Policy           []string         `json:"policy,omitempty"`
+	Timeline         *fileTimeline    `json:"timeline,omitempty"`
+	Title            string           `json:"title,omitempty"`
+	Output           string           `json:"output,omitempty"`
+	Related          []string         `json:"related,omitempty"`
+	Cards            int              `json:"cards,omitempty"`
+	Citations        []citation       `json:"citations,omitempty"`
+	Changes          []string         `json:"changes,omitempty"`
+	StyleViolations  []styleViolation `json:"style_violations,omitempty"`
+	Spelling         []spellingIssue  `json:"spelling,omitempty"`
+	OCR              []string         `json:"ocr,omitempty"`
+	Conflict         string           `json:"conflict,omitempty"`
+	Fallback         string           `json:"fallback,omitempty"`
+	ConflictCopies   []string         `json:"conflict_copies,omitempty"`
+	Variant          string           `json:"variant,omitempty"`
+	Repair           string           `json:"repair,omitempty"`
+	Pages            []string         `json:"pages,omitempty"`
+}
+
+// Итоги запуска
+type reportTotals struct {
+	Files    int `json:"files"`
+	Enriched int `json:"enriched"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	// Результаты, измененные вручную: новое обогащение сохранено в файл .new
+	Conflicts        int     `json:"conflicts,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	BrokenLinks      int     `json:"broken_links"`
+	// Файлы с ошибками по категориям
+	Errors map[string]int `json:"errors,omitempty"`
+	// Средние изменения метрик по обогащенным файлам
+	AvgWordsDelta       float64 `json:"avg_words_delta"`
+	AvgHeadingsDelta    float64 `json:"avg_headings_delta"`
+	AvgReadabilityDelta float64 `json:"avg_readability_delta"`
+	// Перцентили задержек этапов обработки: read, wait, api, write, total
+	Latency map[string]latencyPercentiles `json:"latency,omitempty"`
+}
+
+// Отчет о запуске обработки
+type runReport struct {
+	mu    sync.Mutex
+	RunID string `json:"run_id,omitempty"`
+	// Оператор запуска ([STATE] operator)
+	Operator string `json:"operator,omitempty"`
+	// Часть файлов запуска с -shard K/N ("" - все файлы)
+	Shard      string        `json:"shard,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Files      []reportEntry `json:"files"`
+	Totals     reportTotals  `json:"totals"`
+	// Ожидание ограничителя частоты запросов (nil - запросы не ждали)
+	RateLimit *rateLimitStats `json:"rate_limit,omitempty"`
+	// Метрики этапов запросов к API и записи файлов
+	Pipeline *pipelineStats `json:"pipeline,omitempty"`
+	// Файлы, состояние которых досохранено после аварийного завершения прошлого запуска
+	Recovered []string `json:"recovered,omitempty"`
+	// Итог транзакционного запуска ("" - запуск без --transactional)
+	Transaction string `json:"transaction,omitempty"`
+	// Причина остановки запуска по политике on_error ("" - запуск не остановлен)
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// Итоги транзакционного запуска
+const (
+	TransactionCommitted  = "committed"
+	TransactionRolledBack = "rolled back"
+)
+
+// Создание нового отчета о запуске
+func newRunReport() *runReport {
+	return &runReport{StartedAt: time.Now()}
+}
+
+// Добавление результата обработки файла в отчет
+func (r *runReport) Add(config *Config, relPath string, result *fileResult, err error) {
+	entry := reportEntry{
+		Path:             normalizeRelPath(relPath),
+		Status:           result.Status,
+		Language:         result.Language,
+		Route:            result.Route,
+		Model:            result.Model,
+		PromptHash:       result.PromptHash,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		TokensEstimated:  result.Usage.Estimated,
+		CostUSD:          result.Usage.Cost(config),
+		Metrics:          result.Metrics,
+		BrokenLinks:      result.BrokenLinks,
+		DuplicateOf:      result.DuplicateOf,
+		DurationMS:       result.Duration.Milliseconds(),
+		Policy:           result.PolicyMatches,
+		Title:            result.Title,
+		Output:           result.Output,
+		Related:          result.Related,
+		Cards:            result.Cards,
+		Citations:        result.Citations,
+		Changes:          result.Changes,
+		StyleViolations:  result.StyleViolations,
+		Spelling:         result.Spelling,
+		OCR:              result.OCR,
+		Conflict:         result.Conflict,
+		Fallback:         result.Fallback,
+		ConflictCopies:   result.ConflictCopies,
+		Variant:          result.Variant,
+		Repair:           result.Repair,
+		Pages:            result.Pages,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.ErrorCategory = errorCategory(err)
+	}
+	if !result.Timeline.Started.IsZero() {
+		timeline := result.Timeline.withRequests(result.Usage.Timing)
+		entry.Timeline = &timeline
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, entry)
+}
+
+// Подсчет итогов отчета
+func (r *runReport) finish() {
+	r.FinishedAt = time.Now()
+	totals := reportTotals{Files: len(r.Files)}
+
+	var wordsDelta, headingsDelta, readabilityDelta float64
+	for _, e := range r.Files {
+		switch e.Status {
+		case StatusEnriched:
+			totals.Enriched++
+		case StatusConflict:
+			totals.Conflicts++
+		case StatusFailed:
+			totals.Failed++
+			if e.ErrorCategory != "" {
+				if totals.Errors == nil {
+					totals.Errors = make(map[string]int)
+				}
+				totals.Errors[e.ErrorCategory]++
+			}
+		default:
+			totals.Skipped++
+		}
+		totals.PromptTokens += e.PromptTokens
+		totals.CompletionTokens += e.CompletionTokens
+		totals.CostUSD += e.CostUSD
+		totals.BrokenLinks += len(e.BrokenLinks)
+		if e.Metrics != nil {
+			wordsDelta += float64(e.Metrics.Delta.Words)
+			headingsDelta += float64(e.Metrics.Delta.Headings)
+			readabilityDelta += e.Metrics.Delta.Readability
+		}
+	}
+
+	if totals.Enriched > 0 {
+		n := float64(totals.Enriched)
+		totals.AvgWordsDelta = wordsDelta / n
+		totals.AvgHeadingsDelta = headingsDelta / n
+		totals.AvgReadabilityDelta = readabilityDelta / n
+	}
+	totals.Latency = latencyBreakdown(r.Files)
+	r.Totals = totals
+}
+
+// Сохранение отчета в JSON файл и вывод итогов в журнал
+func (r *runReport) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finish()
+
+	if r.Totals.Enriched > 0 {
+		infof("Изменение метрик в среднем на файл: слова %+.1f, заголовки %+.1f, читаемость %+.1f",
+			r.Totals.AvgWordsDelta, r.Totals.AvgHeadingsDelta, r.Totals.AvgReadabilityDelta)
+	}
+	if len(r.Totals.Latency) > 0 {
+		var phases []string
+		for _, phase := range timelinePhases {
+			if l, ok := r.Totals.Latency[phase]; ok {
+				phases = append(phases, fmt.Sprintf("%s %d/%d/%d", phase, l.P50, l.P90, l.P99))
+			}
+		}
+		infof("Задержки этапов (p50/p90/p99, мс): %s", strings.Join(phases, ", "))
+	}
+	if r.Totals.BrokenLinks > 0 {
+		infof("Найдено битых ссылок в обогащенных документах: %d", r.Totals.BrokenLinks)
+	}
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errorf("ошибка при подготовке отчета: %v", err)
+	}
+	if err := safeWriteFile(path, data, 0644); err != nil {
+		return errorf("ошибка при записи отчета: %v", err)
+	}
+	infof("Отчет о запуске сохранен в %s", path)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunReport(t *testing.T) {
+	config := &Config{InputPrice: 1, OutputPrice: 1}
+	report := newRunReport()
+
+	report.Add(config, "a.md", &fileResult{
+		Status:  StatusEnriched,
+		Usage:   Usage{PromptTokens: 1000000, CompletionTokens: 1000000},
+		Metrics: compareMetrics("one two", "# T\n\none two three four", "en"),
+	}, nil)
+	report.Add(config, "b.md", &fileResult{Status: StatusSkippedTooSmall}, nil)
+	report.Add(config, "c.md", &fileResult{Status: StatusFailed}, errors.New("ошибка API"))
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := report.Save(path); err != nil {
+		t.Fatalf("Save() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать отчет: %v", err)
+	}
+	var saved runReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Отчет не является корректным JSON: %v", err)
+	}
+
+	totals := saved.Totals
+	if totals.Files != 3 || totals.Enriched != 1 || totals.Skipped != 1 || totals.Failed != 1 {
+		t.Errorf("Некорректные итоги: %+v", totals)
+	}
+	if totals.CostUSD != 2 {
+		t.Errorf("Ожидалась стоимость 2, получено %f", totals.CostUSD)
+	}
+	if totals.AvgWordsDelta != 2 || totals.AvgHeadingsDelta != 1 {
+		t.Errorf("Некорректные средние изменения метрик: %+v", totals)
+	}
+	if saved.Files[2].Error != "ошибка API" {
+		t.Errorf("Ожидалась ошибка в записи отчета, получено %q", saved.Files[2].Error)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Файл состояния обработки в каталоге состояния: хэш и итог каждого файла по
+// последней обработке; по нему --resume продолжает прерванный запуск
+const stateManifestFile = "rich.state.json"
+
+// Интервал сохранения файла состояния во время запуска: при аварийном завершении
+// теряются итоги не более чем за этот интервал
+const stateManifestSaveInterval = time.Second
+
+// Итог последней обработки файла
+type manifestEntry struct {
+	// Хэш исходного файла, по которому получен результат
+	Hash   string `json:"hash,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Обработки подряд, завершившиеся ошибкой
+	Failures  int       `json:"failures,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Файл состояния: итоги файлов по путям в общем состоянии запуска
+type stateManifest struct {
+	mu   sync.Mutex
+	path string
+	// Итоги сохраняются только вызовом Flush (транзакционный запуск: итоги
+	// отмененной транзакции не сохраняются)
+	deferred bool
+	dirty    bool
+	savedAt  time.Time
+
+	Files map[string]manifestEntry `json:"files"`
+}
+
+// Чтение файла состояния; без файла возвращается пустое состояние
+func loadStateManifest(stateDir string) (*stateManifest, error) {
+	m := &stateManifest{path: filepath.Join(stateDir, stateManifestFile), Files: make(map[string]manifestEntry)}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errorf("не удалось прочитать файл состояния: %v", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, errorf("поврежден файл состояния %s: %v", m.path, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]manifestEntry)
+	}
+	return m, nil
+}
+
+// Файл уже обработан: результат получен по текущему содержимому файла
+func (m *stateManifest) Done(key, path string) bool {
+	m.mu.Lock()
+	entry, ok := m.Files[normalizeRelPath(key)]
+	m.mu.Unlock()
+	if !ok || entry.Hash == "" || (entry.Status != StatusEnriched && entry.Status != StatusConflict) {
+		return false
+	}
+	content, err := os.ReadFile(path)
+	return err == nil && contentHash(content) == entry.Hash
+}
+
+// Запись итога файла; файл сохраняется не чаще stateManifestSaveInterval
+func (m *stateManifest) Record(key string, result *fileResult, err error, runID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = normalizeRelPath(key)
+	entry := manifestEntry{Hash: result.InputHash, Status: result.Status, RunID: runID, UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+		entry.Failures = m.Files[key].Failures + 1
+	}
+	m.Files[key] = entry
+	m.dirty = true
+	if !m.deferred && time.Since(m.savedAt) >= stateManifestSaveInterval {
+		if serr := m.save(); serr != nil {
+			warnf("Предупреждение: %v", serr)
+		}
+	}
+}
+
+// Удаление итога файла (входной файл удален); сохраняется вызовом Flush
+func (m *stateManifest) Forget(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = normalizeRelPath(key)
+	if _, ok := m.Files[key]; ok {
+		delete(m.Files, key)
+		m.dirty = true
+	}
+}
+
+// Сохранение несохраненных итогов
+func (m *stateManifest) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty {
+		return nil
+	}
+	return m.save()
+}
+
+func (m *stateManifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errorf("не удалось сохранить файл состояния: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return errorf("не удалось сохранить файл состояния: %v", err)
+	}
+	if err := safeWriteFile(m.path, data, 0644); err != nil {
+		return errorf("не удалось сохранить файл состояния: %v", err)
+	}
+	m.dirty = false
+	m.savedAt = time.Now()
+	return nil
+}
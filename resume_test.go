@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStateManifest(t *testing.T) {
+	stateDir := t.TempDir()
+	input := filepath.Join(t.TempDir(), "a.md")
+	if err := os.WriteFile(input, []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadStateManifest(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Record("a.md", &fileResult{Status: StatusEnriched, InputHash: contentHash([]byte("# A"))}, nil, "run-1")
+	m.Record("b.md", &fileResult{Status: StatusFailed}, errorf("ошибка API"), "run-1")
+	m.Record("b.md", &fileResult{Status: StatusFailed}, errorf("ошибка API"), "run-1")
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = loadStateManifest(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Done("a.md", input) {
+		t.Error("Обогащенный файл с тем же содержимым должен считаться обработанным")
+	}
+	if entry := m.Files["b.md"]; m.Done("b.md", input) || entry.Failures != 2 || entry.Error == "" {
+		t.Errorf("Файл с ошибкой: %+v", entry)
+	}
+	if err := os.WriteFile(input, []byte("# A изменен"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if m.Done("a.md", input) {
+		t.Error("Измененный файл должен обрабатываться заново")
+	}
+
+	if err := os.WriteFile(filepath.Join(stateDir, stateManifestFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStateManifest(stateDir); err == nil {
+		t.Error("Поврежденный файл состояния должен возвращать ошибку")
+	}
+}
+
+// Запуск, прерванный остановкой службы, продолжается с --resume без повторных запросов
+// к API для обработанных файлов, даже если список исключений не сохранился
+func TestResumeRun(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + filepath.Join(tmpDir, "state") + "\n[EXCLUSIONS]\nexcluded_files =\n"
+	run := func(resume bool, maxFiles int) {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		config.Resume = resume
+		config.MaxFiles = maxFiles
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() вернул ошибку: %v", err)
+		}
+	}
+
+	// Первый запуск останавливается бюджетом после двух файлов
+	run(false, 2)
+	if calls.Load() != 2 {
+		t.Fatalf("Запросов первого запуска: %d", calls.Load())
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "state", stateManifestFile))
+	if err != nil || !strings.Contains(string(data), `"a.md"`) || !strings.Contains(string(data), StatusEnriched) {
+		t.Fatalf("Файл состояния: %s, %v", data, err)
+	}
+
+	// Конфигурация перезаписывается без списка исключений: продолжение - по файлу состояния
+	run(true, 0)
+	if calls.Load() != 3 {
+		t.Errorf("С --resume должен обрабатываться только оставшийся файл, запросов всего %d", calls.Load())
+	}
+	run(false, 0)
+	if calls.Load() != 6 {
+		t.Errorf("Без --resume файлы не из списка исключений обрабатываются заново, запросов всего %d", calls.Load())
+	}
+}
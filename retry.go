@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Повторы запросов по умолчанию
+const (
+	defaultRetries       = 3
+	defaultRetryDelay    = 2 * time.Second
+	defaultRetryMaxDelay = time.Minute
+)
+
+// Статусы временных ошибок провайдера, после которых запрос повторяется
+// (529 - перегрузка Anthropic)
+var retryableStatuses = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooEarly:            true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	529:                            true,
+}
+
+// Повторы запросов к API модели при временных ошибках ([NETWORK])
+type retryConfig struct {
+	// Повторы после первой попытки (0 - без повторов)
+	Retries int
+	// Пауза перед первым повтором и наибольшая пауза: пауза удваивается с каждым повтором
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// Чтение параметров retries, retry_delay и retry_max_delay секции [NETWORK]
+func loadRetryConfig(section *ini.Section) (retryConfig, error) {
+	retry := retryConfig{
+		Retries:  section.Key("retries").MustInt(defaultRetries),
+		Delay:    section.Key("retry_delay").MustDuration(defaultRetryDelay),
+		MaxDelay: section.Key("retry_max_delay").MustDuration(defaultRetryMaxDelay),
+	}
+	if retry.Retries < 0 {
+		return retry, errorf("retries в секции [NETWORK] не может быть отрицательным: %d", retry.Retries)
+	}
+	if retry.Delay <= 0 || retry.MaxDelay < retry.Delay {
+		return retry, errorf("retry_delay в секции [NETWORK] должен быть положительным и не больше retry_max_delay: %s, %s", retry.Delay, retry.MaxDelay)
+	}
+	return retry, nil
+}
+
+// Ошибка временная, и запрос стоит повторить
+func retryableError(err error) bool {
+	var se *apiStatusError
+	return errors.As(err, &se) && retryableStatuses[se.StatusCode]
+}
+
+// Пауза перед повтором attempt (1 - первый повтор): экспоненциальный рост до MaxDelay
+// со случайным разбросом ±20%, чтобы параллельные обработчики не повторяли запросы
+// одновременно
+func (r retryConfig) wait(attempt int) time.Duration {
+	delay := r.Delay
+	for i := 1; i < attempt && delay < r.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.MaxDelay)
+	return time.Duration(float64(delay) * (0.8 + 0.4*rand.Float64()))
+}
+
+// Запрос к API модели с повторами при временных ошибках провайдера (429, 5xx).
+// Пауза провайдера из Retry-After и заголовков лимитов учитывается ограничителем
+// частоты запросов, пауза повтора добавляется к ней
+func requestModel(config *Config, content string, image *imageAttachment, rateLimiter *RateLimiter) (string, Usage, error) {
+	retry := config.Network.Retry
+	for attempt := 1; ; attempt++ {
+		text, usage, err := requestModelOnce(config, content, image, rateLimiter)
+		if err == nil || attempt > retry.Retries || !retryableError(err) {
+			return text, usage, err
+		}
+		wait := retry.wait(attempt)
+		warnf("Временная ошибка API, повтор %d из %d через %v: %v", attempt, retry.Retries, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadRetryConfig(t *testing.T) {
+	load := func(text string) (retryConfig, error) {
+		t.Helper()
+		cfg, err := ini.Load([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loadRetryConfig(cfg.Section("NETWORK"))
+	}
+	retry, err := load("")
+	if err != nil || retry.Retries != defaultRetries || retry.Delay != defaultRetryDelay || retry.MaxDelay != defaultRetryMaxDelay {
+		t.Errorf("Повторы по умолчанию: %+v, %v", retry, err)
+	}
+	for _, text := range []string{
+		"[NETWORK]\nretries = -1\n",
+		"[NETWORK]\nretry_delay = 0s\n",
+		"[NETWORK]\nretry_delay = 2m\nretry_max_delay = 1m\n",
+	} {
+		if _, err := load(text); err == nil {
+			t.Errorf("Конфигурация %q должна возвращать ошибку", text)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	retry := retryConfig{Retries: 5, Delay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := retry.wait(attempt); got < want*8/10 || got > want*12/10 {
+			t.Errorf("Пауза перед повтором %d: %v, ожидалось около %v", attempt, got, want)
+		}
+	}
+}
+
+func TestRequestModelRetry(t *testing.T) {
+	var calls atomic.Int32
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		switch {
+		case r.URL.Path == "/bad/v1/chat/completions":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "bad request"}}`))
+		case r.URL.Path == "/down/v1/chat/completions":
+			w.WriteHeader(http.StatusBadGateway)
+		case n <= len(statuses):
+			w.WriteHeader(statuses[n-1])
+		default:
+			_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Ответ"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "test-model", ModelAPIURL: server.URL + "/v1/chat/completions", Provider: "openai-compatible", MaxTokens: 100}
+	config.Network.Retry = retryConfig{Retries: 3, Delay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	text, _, err := requestModel(config, "текст", nil, NewRateLimiter(0))
+	if err != nil || text != "Ответ" || calls.Load() != 3 {
+		t.Errorf("После двух временных ошибок ожидался ответ с третьей попытки: %q, %v, запросов %d", text, err, calls.Load())
+	}
+
+	// Ошибки запроса не повторяются
+	calls.Store(10)
+	config.ModelAPIURL = server.URL + "/bad/v1/chat/completions"
+	if _, _, err := requestModel(config, "текст", nil, NewRateLimiter(0)); err == nil || calls.Load() != 11 {
+		t.Errorf("Ответ 400 не должен повторяться: %v, запросов %d", err, calls.Load()-10)
+	}
+
+	// После исчерпания повторов возвращается последняя ошибка
+	calls.Store(0)
+	config.ModelAPIURL = server.URL + "/down/v1/chat/completions"
+	_, _, err = requestModel(config, "текст", nil, NewRateLimiter(0))
+	var statusErr *apiStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway || calls.Load() != 4 {
+		t.Errorf("Ожидалась ошибка 502 после 4 попыток: %v, запросов %d", err, calls.Load())
+	}
+}
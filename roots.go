@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Дополнительный корень входных файлов со своей выходной директорией
+// (секция [DIRECTORIES.<имя>]); состояние запуска, список исключений и отчет общие
+type inputRoot struct {
+	Name      string
+	InputDir  string
+	OutputDir string
+}
+
+// Чтение дополнительных корней; выходная директория по умолчанию - <output_dir>/<имя>
+func loadInputRoots(cfg *ini.File, outputDir string) ([]inputRoot, error) {
+	var roots []inputRoot
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "DIRECTORIES.")
+		if !ok {
+			continue
+		}
+		if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return nil, errorf("некорректное имя корня [DIRECTORIES.%s]: имя не должно содержать разделителей пути", name)
+		}
+		// Ключи читаются только из самой секции: дочерняя секция ini наследует
+		// ключи [DIRECTORIES], и корень получил бы основные директории
+		root := inputRoot{Name: name, OutputDir: filepath.Join(outputDir, name)}
+		if slices.Contains(section.KeyStrings(), "input_dir") {
+			root.InputDir = stripLongPathPrefix(section.Key("input_dir").String())
+		}
+		if slices.Contains(section.KeyStrings(), "output_dir") {
+			root.OutputDir = stripLongPathPrefix(section.Key("output_dir").String())
+		}
+		if root.InputDir == "" {
+			return nil, errorf("для корня [DIRECTORIES.%s] не задан input_dir", name)
+		}
+		abs, err := filepath.Abs(root.OutputDir)
+		if err != nil || !isPathSafe(abs) {
+			return nil, errorf("небезопасный путь выходной директории: %s", root.OutputDir)
+		}
+		if err := os.MkdirAll(abs, 0755); err != nil {
+			return nil, errorf("не удалось создать выходную директорию: %v", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// Конфигурации обработки корней: основной (input_dir или input_source, output_dir)
+// и дополнительные
+func (c *Config) inputRoots() []*Config {
+	configs := []*Config{c}
+	for _, root := range c.Roots {
+		rootConfig := *c
+		rootConfig.InputDir, rootConfig.OutputDir, rootConfig.RootName = root.InputDir, root.OutputDir, root.Name
+		rootConfig.InputSource = ""
+		if c.ReviewDir != "" {
+			rootConfig.ReviewDir = filepath.Join(c.ReviewDir, root.Name)
+		}
+		configs = append(configs, &rootConfig)
+	}
+	return configs
+}
+
+// Путь файла в общем состоянии (исключения, журнал, отчет): у дополнительных корней
+// путь относительно корня дополняется именем корня
+func rootKey(root, relPath string) string {
+	if root == "" {
+		return relPath
+	}
+	return root + "/" + normalizeRelPath(relPath)
+}
+
+// Конфигурация корня и путь файла относительно него по пути из общего состояния
+func (c *Config) rootForKey(key string) (*Config, string) {
+	for _, rootConfig := range c.inputRoots()[1:] {
+		if rel, ok := strings.CutPrefix(normalizeRelPath(key), rootConfig.RootName+"/"); ok {
+			return rootConfig, rel
+		}
+	}
+	return c, key
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessDirectoryMultipleRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + filepath.Join(tmpDir, "notes") + "\noutput_dir = " + filepath.Join(tmpDir, "out") + "\n\n" +
+		"[DIRECTORIES.wiki]\ninput_dir = " + filepath.Join(tmpDir, "wiki") + "\n\n" +
+		"[DIRECTORIES.docs]\ninput_dir = " + filepath.Join(tmpDir, "docs") + "\noutput_dir = " + filepath.Join(tmpDir, "docs-out") + "\n\n" +
+		"[EXCLUSIONS]\nexcluded_files = wiki/skip.md\n\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"notes/README.md", "wiki/README.md", "wiki/skip.md", "docs/README.md"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# Заметка\n\nТекст заметки "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if len(config.Roots) != 2 || config.Roots[0].OutputDir != filepath.Join(tmpDir, "out", "wiki") {
+		t.Fatalf("некорректные корни: %+v", config.Roots)
+	}
+	config.ModelAPIURL = server.URL + "/v1/chat/completions"
+	config.ReportFile = filepath.Join(tmpDir, "report.json")
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	for _, out := range []string{"out/README.md", "out/wiki/README.md", "docs-out/README.md"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(out))); err != nil {
+			t.Errorf("нет выходного файла %s: %v", out, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "out", "wiki", "skip.md")); !os.IsNotExist(err) {
+		t.Error("исключенный файл корня wiki не должен обрабатываться")
+	}
+
+	// Одноименные файлы корней различаются в списке исключений
+	reloaded, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded := strings.Join(reloaded.ExcludedFiles, ",")
+	for _, key := range []string{"README.md", "wiki/README.md", "docs/README.md"} {
+		if !isExcluded(reloaded, key) {
+			t.Errorf("%s не добавлен в список исключений: %s", key, excluded)
+		}
+	}
+	if root, rel := reloaded.rootForKey("docs/README.md"); root.RootName != "docs" || rel != "README.md" {
+		t.Errorf("ожидался корень docs и README.md, получено %q и %s", root.RootName, rel)
+	}
+}
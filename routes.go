@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Маршрут: условия выбора файлов и переопределения промпта/модели для них
+type Route struct {
+	Name  string
+	Paths []ignorePattern
+	Tags  []string
+
+	Prompt      string
+	ModelName   string
+	ModelAPIURL string
+	APIKey      string
+	Temperature *float64
+	MaxTokens   int
+	// Способ распознавания текста на изображениях (off, vision, tesseract)
+	OCR string
+	// Шаблон пути результата относительно выходной директории (nil - путь повторяет входной)
+	Output *template.Template
+}
+
+// Чтение маршрутов: [ROUTES] задает условия (имя = шаблоны путей, tag:тег),
+// секции [ROUTE.<имя>] - переопределения промпта и модели
+func loadRoutes(cfg *ini.File, configDir string) ([]Route, error) {
+	if !cfg.HasSection("ROUTES") {
+		return nil, nil
+	}
+
+	var routes []Route
+	for _, key := range cfg.Section("ROUTES").Keys() {
+		route := Route{Name: key.Name()}
+		for _, cond := range strings.Split(key.String(), ",") {
+			cond = strings.TrimSpace(cond)
+			if cond == "" {
+				continue
+			}
+			if tag, ok := strings.CutPrefix(cond, "tag:"); ok {
+				route.Tags = append(route.Tags, strings.ToLower(strings.TrimSpace(tag)))
+				continue
+			}
+			p, ok, err := parseIgnorePattern(cond)
+			if err != nil || !ok || p.negate {
+				return nil, errorf("некорректное условие маршрута %s: %s", route.Name, cond)
+			}
+			route.Paths = append(route.Paths, p)
+		}
+		if len(route.Paths) == 0 && len(route.Tags) == 0 {
+			return nil, errorf("маршрут %s не содержит условий", route.Name)
+		}
+
+		if !cfg.HasSection("ROUTE." + route.Name) {
+			return nil, errorf("для маршрута %s не найдена секция [ROUTE.%s]", route.Name, route.Name)
+		}
+		section := cfg.Section("ROUTE." + route.Name)
+		route.Prompt = section.Key("prompt").String()
+		if promptFile := section.Key("prompt_file").String(); promptFile != "" && route.Prompt == "" {
+			if !filepath.IsAbs(promptFile) {
+				promptFile = filepath.Join(configDir, promptFile)
+			}
+			data, err := os.ReadFile(promptFile)
+			if err != nil {
+				return nil, errorf("не удалось прочитать промпт маршрута %s: %v", route.Name, err)
+			}
+			route.Prompt = string(data)
+		}
+		route.ModelName = section.Key("name").String()
+		route.ModelAPIURL = section.Key("api_url").String()
+		route.APIKey = section.Key("api_key").String()
+		if envKey := section.Key("api_key_env").String(); envKey != "" && os.Getenv(envKey) != "" {
+			route.APIKey = os.Getenv(envKey)
+		}
+		apiKey, err := resolveSecret(route.APIKey)
+		if err != nil {
+			return nil, errorf("некорректный api_key маршрута %s: %v", route.Name, err)
+		}
+		route.APIKey = apiKey
+		if section.HasKey("temperature") {
+			t, err := section.Key("temperature").Float64()
+			if err != nil {
+				return nil, errorf("некорректная temperature маршрута %s: %v", route.Name, err)
+			}
+			route.Temperature = &t
+		}
+		route.MaxTokens = section.Key("max_tokens").MustInt(0)
+		route.OCR = strings.ToLower(section.Key("ocr").String())
+		if route.OCR != "" {
+			if err := validateOCREngine(route.OCR); err != nil {
+				return nil, errorf("маршрут %s: %v", route.Name, err)
+			}
+		}
+		if output := strings.TrimSpace(section.Key("output").String()); output != "" {
+			tmpl, err := template.New(route.Name).Option("missingkey=zero").Parse(output)
+			if err != nil {
+				return nil, errorf("некорректный шаблон output маршрута %s: %v", route.Name, err)
+			}
+			route.Output = tmpl
+		}
+
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// Проверка соответствия файла условиям маршрута (по пути или по тегам frontmatter)
+func (r *Route) Matches(relPath string, tags []string) bool {
+	slashPath := filepath.ToSlash(filepath.Clean(relPath))
+	for _, p := range r.Paths {
+		if p.re.MatchString(slashPath) {
+			return true
+		}
+	}
+	for _, want := range r.Tags {
+		for _, tag := range tags {
+			if strings.EqualFold(strings.TrimPrefix(tag, "#"), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Выбор первого подходящего маршрута для файла
+func matchRoute(routes []Route, relPath string, fm Frontmatter) *Route {
+	tags := fm.List("tags")
+	for i := range routes {
+		if routes[i].Matches(relPath, tags) {
+			return &routes[i]
+		}
+	}
+	return nil
+}
+
+// Применение переопределений маршрута к конфигурации файла
+func (r *Route) Apply(config *Config) {
+	if r.Prompt != "" {
+		config.Prompt = r.Prompt
+		// Языковые варианты общего промпта не применяются к промпту маршрута
+		config.LanguagePrompts = nil
+	}
+	if r.ModelName != "" {
+		config.ModelName = r.ModelName
+		// Переопределения контекстного окна относятся к общей модели
+		config.ContextWindow = 0
+		config.MaxOutputTokens = 0
+	}
+	if r.ModelAPIURL != "" {
+		config.ModelAPIURL = r.ModelAPIURL
+	}
+	if r.APIKey != "" {
+		config.APIKey = r.APIKey
+	}
+	if r.Temperature != nil {
+		config.Temperature = *r.Temperature
+	}
+	if r.MaxTokens > 0 {
+		config.MaxTokens = r.MaxTokens
+		config.AutoMaxTokens = false
+	}
+	if r.OCR != "" {
+		config.OCR.Engine = r.OCR
+	}
+}
+
+// Поля шаблона output маршрута
+type routeOutputData struct {
+	// Дата документа: год, месяц и день с ведущими нулями и дата целиком (ГГГГ-ММ-ДД)
+	Year, Month, Day, Date string
+	// Slug по полю slug или title frontmatter, иначе по имени файла
+	Slug string
+	// Имя входного файла без расширения, его директория (с прямыми слешами) и
+	// расширение результата
+	Name, Dir, Ext string
+	Title          string
+	Frontmatter    Frontmatter
+	// Сведения о файле, общие с подстановками промпта и подписи
+	File fileContext
+}
+
+// Дата в имени файла вида 2024-03-05-заметка.md
+var fileNameDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+// Дата документа: поле date frontmatter, затем дата в имени файла, затем время изменения
+func documentDate(relPath string, fm Frontmatter, modTime time.Time) time.Time {
+	if date, ok := noteDate(relPath, fm); ok {
+		return date
+	}
+	return modTime
+}
+
+// Явно указанная дата заметки: поле date frontmatter или дата в имени файла
+func noteDate(relPath string, fm Frontmatter) (time.Time, bool) {
+	if value := strings.TrimSpace(fm["date"]); value != "" {
+		for _, layout := range timeFilterLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	if m := fileNameDate.FindString(filepath.Base(relPath)); m != "" {
+		if t, err := time.ParseInLocation("2006-01-02", m, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Путь результата по шаблону output маршрута относительно выходной директории
+// (с прямыми слешами). Без расширения в шаблоне добавляется ext - расширение результата
+func (r *Route) OutputPath(file fileContext, ext string, modTime time.Time) (string, error) {
+	relPath, fm := filepath.FromSlash(file.Path), file.Frontmatter
+	name := strings.TrimSuffix(filepath.Base(relPath), filepath.Ext(relPath))
+	date := documentDate(relPath, fm, modTime)
+	data := routeOutputData{
+		Year:        date.Format("2006"),
+		Month:       date.Format("01"),
+		Day:         date.Format("02"),
+		Date:        date.Format("2006-01-02"),
+		Slug:        fileSlug(fm["slug"]),
+		Name:        name,
+		Dir:         filepath.ToSlash(filepath.Dir(relPath)),
+		Ext:         ext,
+		Title:       fm["title"],
+		Frontmatter: fm,
+		File:        file,
+	}
+	if data.Slug == "" {
+		data.Slug = fileSlug(data.Title)
+	}
+	if data.Slug == "" {
+		data.Slug = fileSlug(name)
+	}
+	if data.Title == "" {
+		data.Title = name
+	}
+
+	var b strings.Builder
+	if err := r.Output.Execute(&b, data); err != nil {
+		return "", errorf("ошибка шаблона output маршрута %s: %v", r.Name, err)
+	}
+	rel := path.Clean(filepath.ToSlash(strings.TrimSpace(b.String())))
+	if rel == "." || strings.HasSuffix(b.String(), "/") || path.IsAbs(rel) || !isRelPathSafe(filepath.FromSlash(rel)) {
+		return "", errorf("шаблон output маршрута %s дал недопустимый путь %q для %s", r.Name, b.String(), relPath)
+	}
+	if path.Ext(rel) == "" {
+		rel += ext
+	}
+	return rel, nil
+}
+
+// Выходные пути маршрутов, занятые за запуск: путь, совпавший с результатом другого
+// документа, получает номер
+type routeOutputs struct {
+	mu     sync.Mutex
+	owners map[string]string
+}
+
+// Закрепление пути rel в выходной директории корня root за документом key;
+// возвращает свободный путь
+func (o *routeOutputs) Reserve(root, key, rel string) string {
+	if o == nil {
+		return rel
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.owners == nil {
+		o.owners = make(map[string]string)
+	}
+	ext := path.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	candidate := rel
+	for i := 2; ; i++ {
+		owned := pathKey(rootKey(root, candidate))
+		if owner, ok := o.owners[owned]; !ok || owner == key {
+			o.owners[owned] = key
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadRoutesAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "editorial.txt"), []byte("Редакторский промпт"), 0644); err != nil {
+		t.Fatalf("Ошибка записи промпта: %v", err)
+	}
+	cfg, err := ini.Load([]byte(`
+[ROUTES]
+technical = docs/api/**, tag:api
+editorial = blog/**, *.post.md
+
+[ROUTE.technical]
+prompt = Технический промпт
+name = tech-model
+temperature = 0.1
+
+[ROUTE.editorial]
+prompt_file = editorial.txt
+`))
+	if err != nil {
+		t.Fatalf("Ошибка разбора INI: %v", err)
+	}
+	routes, err := loadRoutes(cfg, dir)
+	if err != nil {
+		t.Fatalf("Ошибка чтения маршрутов: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("Ожидалось 2 маршрута, получено %d", len(routes))
+	}
+
+	tests := []struct {
+		path string
+		fm   Frontmatter
+		want string
+	}{
+		{"docs/api/v1/users.md", nil, "technical"},
+		{"docs/guide.md", Frontmatter{"tags": "[howto, API]"}, "technical"},
+		{"blog/2024/news.md", nil, "editorial"},
+		{"notes/release.post.md", nil, "editorial"},
+		{"docs/guide.md", nil, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if route := matchRoute(routes, tt.path, tt.fm); route != nil {
+			got = route.Name
+		}
+		if got != tt.want {
+			t.Errorf("Маршрут для %s: %q, ожидалось %q", tt.path, got, tt.want)
+		}
+	}
+
+	config := &Config{Prompt: "Общий", ModelName: "base", Temperature: 0.7, LanguagePrompts: map[string]string{"ru": "Русский"}}
+	routes[0].Apply(config)
+	if config.Prompt != "Технический промпт" || config.ModelName != "tech-model" || config.Temperature != 0.1 {
+		t.Errorf("Некорректное применение маршрута: %+v", config)
+	}
+	if config.LanguagePrompts != nil {
+		t.Error("Языковые варианты общего промпта должны отключаться промптом маршрута")
+	}
+	if routes[1].Prompt != "Редакторский промпт" {
+		t.Errorf("Промпт маршрута не прочитан из файла: %q", routes[1].Prompt)
+	}
+}
+
+func TestLoadRoutesErrors(t *testing.T) {
+	cases := []string{
+		"[ROUTES]\ntechnical = docs/**\n",
+		"[ROUTES]\ntechnical = ,\n[ROUTE.technical]\nprompt = x\n",
+		"[ROUTES]\ntechnical = docs/**\n[ROUTE.technical]\ntemperature = abc\n",
+		"[ROUTES]\ntechnical = docs/**\n[ROUTE.technical]\noutput = {{.Year}/{{.Slug}}.md\n",
+	}
+	for _, data := range cases {
+		cfg, err := ini.Load([]byte(data))
+		if err != nil {
+			t.Fatalf("Ошибка разбора INI: %v", err)
+		}
+		if _, err := loadRoutes(cfg, "."); err == nil {
+			t.Errorf("Ожидалась ошибка для конфигурации:\n%s", data)
+		}
+	}
+}
+
+func TestRouteOutputPath(t *testing.T) {
+	cfg, err := ini.Load([]byte("[ROUTES]\nblog = blog/**\nescape = x/**\n" +
+		"[ROUTE.blog]\noutput = {{.Year}}/{{.Month}}/{{.Slug}}\n[ROUTE.escape]\noutput = ../{{.Name}}.md\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadRoutes(cfg, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2023, 7, 9, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		path string
+		fm   Frontmatter
+		want string
+	}{
+		{"blog/draft.md", Frontmatter{"date": "2024-03-05", "title": "Привет, мир!"}, "2024/03/привет-мир.md"},
+		{"blog/2022-11-30 Итоги.md", Frontmatter{"slug": "Year End"}, "2022/11/year-end.md"},
+		{"blog/Note.md", nil, "2023/07/note.md"},
+	}
+	for _, tt := range tests {
+		got, err := routes[0].OutputPath(fileContext{Path: tt.path, Frontmatter: tt.fm}, ".md", modTime)
+		if err != nil || got != tt.want {
+			t.Errorf("OutputPath(%s) = %q, %v; ожидалось %q", tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := routes[1].OutputPath(fileContext{Path: "x/a.md"}, ".md", modTime); err == nil {
+		t.Error("Путь вне выходной директории должен быть ошибкой")
+	}
+
+	var outputs routeOutputs
+	if got := outputs.Reserve("", "a.md", "2024/post.md"); got != "2024/post.md" {
+		t.Errorf("Reserve() = %q", got)
+	}
+	if got := outputs.Reserve("", "b.md", "2024/post.md"); got != "2024/post-2.md" {
+		t.Errorf("Совпавший путь другого документа: %q", got)
+	}
+	if got := outputs.Reserve("", "a.md", "2024/post.md"); got != "2024/post.md" {
+		t.Errorf("Повторное закрепление пути за тем же документом: %q", got)
+	}
+}
+
+func TestRouteOutputRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "drafts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	post := "---\ntitle: Первый пост\ndate: 2024-05-01\n---\n# Черновик"
+	if err := os.WriteFile(filepath.Join(inputDir, "drafts", "post.md"), []byte(post), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[EXCLUSIONS]\nexcluded_files =\n" +
+		"[ROUTES]\nblog = drafts/**\n[ROUTE.blog]\noutput = posts/{{.Year}}/{{.Slug}}.md\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "posts", "2024", "первый-пост.md"))
+	if err != nil || !strings.Contains(string(data), "# Обогащено") {
+		t.Errorf("Результат по шаблону маршрута: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "drafts", "post.md")); !os.IsNotExist(err) {
+		t.Errorf("Результат не должен повторять путь входного файла: %v", err)
+	}
+}
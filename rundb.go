@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Схема базы данных запусков
+const runDBSchema = `CREATE TABLE IF NOT EXISTS runs (
+	id TEXT PRIMARY KEY,
+	started_at TEXT NOT NULL,
+	finished_at TEXT NOT NULL,
+	files INTEGER NOT NULL,
+	enriched INTEGER NOT NULL,
+	skipped INTEGER NOT NULL,
+	failed INTEGER NOT NULL,
+	prompt_tokens INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	cost_usd REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	run_id TEXT NOT NULL REFERENCES runs(id),
+	path TEXT NOT NULL,
+	dir TEXT NOT NULL,
+	status TEXT NOT NULL,
+	model TEXT,
+	prompt_hash TEXT,
+	prompt_tokens INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	cost_usd REAL NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	error TEXT
+);
+CREATE INDEX IF NOT EXISTS files_path ON files(path);
+CREATE INDEX IF NOT EXISTS files_run ON files(run_id);
+CREATE TABLE IF NOT EXISTS labels (
+	path TEXT PRIMARY KEY,
+	label TEXT NOT NULL,
+	note TEXT,
+	reviewer TEXT,
+	run_id TEXT NOT NULL,
+	labeled_at TEXT NOT NULL
+);
+`
+
+// Встроенные запросы rich db query; %d - ограничение числа строк
+var runDBQueries = map[string]string{
+	"runs": `SELECT id, started_at, enriched, skipped, failed, prompt_tokens + completion_tokens AS tokens, ROUND(cost_usd, 4) AS cost_usd
+FROM runs ORDER BY started_at DESC LIMIT %d`,
+	"failures": `SELECT path, COUNT(*) AS failures, MAX(run_id) AS last_run, error
+FROM files WHERE status = 'failed' GROUP BY path ORDER BY failures DESC, last_run DESC LIMIT %d`,
+	"cost-by-dir": `SELECT dir, COUNT(*) AS files, SUM(prompt_tokens + completion_tokens) AS tokens, ROUND(SUM(cost_usd), 4) AS cost_usd
+FROM files GROUP BY dir ORDER BY SUM(cost_usd) DESC LIMIT %d`,
+	"slowest": `SELECT path, run_id, duration_ms, status, model
+FROM files WHERE duration_ms > 0 ORDER BY duration_ms DESC LIMIT %d`,
+	"labels": `SELECT label, COUNT(*) AS files, MAX(labeled_at) AS last_labeled
+FROM labels GROUP BY label ORDER BY files DESC LIMIT %d`,
+}
+
+// Строковый литерал SQL
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Выполнение SQL через программу sqlite3: драйвера SQLite нет в стандартной
+// библиотеке Go, а sqlite3 есть в большинстве систем
+func execSQLite(config *Config, script string, args ...string) ([]byte, error) {
+	binary := config.SQLiteBinary
+	if binary == "" {
+		binary = "sqlite3"
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, errorf("программа %s не найдена: установите SQLite или укажите путь ключом sqlite3 секции [DATABASE]", binary)
+	}
+	cmd := exec.Command(binary, append(append([]string{"-bail"}, args...), config.DatabaseFile)...)
+	cmd.Stdin = strings.NewReader(script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errorf("ошибка SQLite: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Запись запуска и результатов по файлам в базу данных; повторная запись запуска
+// с тем же идентификатором заменяет прежнюю
+func recordRun(config *Config, report *runReport) error {
+	id := report.RunID
+	if id == "" {
+		id = report.StartedAt.Format("20060102-150405")
+	}
+	t := report.Totals
+
+	var b strings.Builder
+	b.WriteString(runDBSchema)
+	b.WriteString("BEGIN;\n")
+	fmt.Fprintf(&b, "DELETE FROM files WHERE run_id = %s;\n", sqlQuote(id))
+	fmt.Fprintf(&b, "INSERT OR REPLACE INTO runs VALUES (%s, %s, %s, %d, %d, %d, %d, %d, %d, %g);\n",
+		sqlQuote(id), sqlQuote(report.StartedAt.Format(time.RFC3339)), sqlQuote(report.FinishedAt.Format(time.RFC3339)),
+		t.Files, t.Enriched, t.Skipped, t.Failed, t.PromptTokens, t.CompletionTokens, t.CostUSD)
+	for _, e := range report.Files {
+		fmt.Fprintf(&b, "INSERT INTO files VALUES (%s, %s, %s, %s, %s, %s, %d, %d, %g, %d, %s);\n",
+			sqlQuote(id), sqlQuote(e.Path), sqlQuote(path.Dir(e.Path)), sqlQuote(e.Status), sqlQuote(e.Model),
+			sqlQuote(e.PromptHash), e.PromptTokens, e.CompletionTokens, e.CostUSD, e.DurationMS, sqlQuote(e.Error))
+	}
+	b.WriteString("COMMIT;\n")
+
+	if _, err := execSQLite(config, b.String()); err != nil {
+		return err
+	}
+	logf("Запуск %s записан в базу данных %s", id, config.DatabaseFile)
+	return nil
+}
+
+// rich db query <запрос|SQL> [--limit N] [--json]: встроенные запросы к базе
+// данных запусков или произвольный SQL
+func runDBCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "query" {
+		return errorf("укажите действие: rich db query <запрос>")
+	}
+	fs := flag.NewFlagSet("db query", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	limit := fs.Int("limit", 20, tr("Максимальное количество строк встроенного запроса"))
+	asJSON := fs.Bool("json", false, tr("Вывести результат в JSON"))
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(runDBQueries))
+	for name := range runDBQueries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if query == "" {
+		return errorf("укажите запрос: %s или SQL", strings.Join(names, ", "))
+	}
+	if builtin, ok := runDBQueries[query]; ok {
+		query = fmt.Sprintf(builtin, *limit)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if config.DatabaseFile == "" {
+		return errorf("база данных запусков не задана (секция [DATABASE], ключ file)")
+	}
+
+	mode := []string{"-header", "-column"}
+	if *asJSON {
+		mode = []string{"-json"}
+	}
+	output, err := execSQLite(config, runDBSchema+strings.TrimSuffix(query, ";")+";\n", mode...)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		fmt.Fprintln(out, tr("Нет данных"))
+		return nil
+	}
+	_, err = out.Write(output)
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRunAndQuery(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 не установлен")
+	}
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "rich.db")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[DATABASE]\nfile = "+dbPath+"\n"), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+
+	started := time.Date(2024, 6, 17, 10, 30, 0, 0, time.UTC)
+	for i, id := range []string{"run-1", "run-2"} {
+		report := &runReport{RunID: id, StartedAt: started.Add(time.Duration(i) * time.Hour)}
+		report.Files = []reportEntry{
+			{Path: "guides/a.md", Status: StatusEnriched, PromptTokens: 100, CompletionTokens: 50, CostUSD: 0.01, DurationMS: 1200},
+			{Path: "notes/it's.md", Status: StatusFailed, Error: "API вернул 500", DurationMS: 300},
+		}
+		report.finish()
+		if err := recordRun(config, report); err != nil {
+			t.Fatalf("recordRun() вернул ошибку: %v", err)
+		}
+	}
+	// Повторная запись запуска не дублирует файлы
+	report := &runReport{RunID: "run-2", StartedAt: started, Files: []reportEntry{{Path: "guides/a.md", Status: StatusEnriched, DurationMS: 5000}}}
+	report.finish()
+	if err := recordRun(config, report); err != nil {
+		t.Fatalf("recordRun() вернул ошибку: %v", err)
+	}
+
+	query := func(args ...string) []map[string]interface{} {
+		t.Helper()
+		var out bytes.Buffer
+		if err := runDBCommand(append([]string{"query", "-config", configPath, "-json"}, args...), &out); err != nil {
+			t.Fatalf("db query %v вернул ошибку: %v", args, err)
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+			t.Fatalf("Некорректный JSON %q: %v", out.String(), err)
+		}
+		return rows
+	}
+
+	if rows := query("runs"); len(rows) != 2 {
+		t.Errorf("Ожидалось 2 запуска, получено %v", rows)
+	}
+	failures := query("failures")
+	if len(failures) != 1 || failures[0]["path"] != "notes/it's.md" || failures[0]["failures"] != 1.0 {
+		t.Errorf("Некорректный список ошибок: %v", failures)
+	}
+	slowest := query("-limit", "1", "slowest")
+	if len(slowest) != 1 || slowest[0]["duration_ms"] != 5000.0 {
+		t.Errorf("Некорректный список медленных файлов: %v", slowest)
+	}
+	byDir := query("cost-by-dir")
+	if len(byDir) != 2 || byDir[0]["dir"] != "guides" {
+		t.Errorf("Некорректные затраты по директориям: %v", byDir)
+	}
+	if rows := query("SELECT COUNT(*) AS n FROM files"); rows[0]["n"] != 3.0 {
+		t.Errorf("Ожидалось 3 записи о файлах, получено %v", rows)
+	}
+
+	var out bytes.Buffer
+	if err := runDBCommand([]string{"query", "-config", configPath, "SELECT * FROM files WHERE 0"}, &out); err != nil {
+		t.Fatalf("db query вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "Нет данных") {
+		t.Errorf("Ожидалось сообщение об отсутствии данных, получено %q", out.String())
+	}
+}
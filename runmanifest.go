@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Версия схемы манифеста запуска; меняется при несовместимых изменениях полей
+const runManifestSchema = "rich.run/v1"
+
+// Итог запуска в манифесте
+const (
+	RunSucceeded  = "succeeded"
+	RunFailed     = "failed"
+	RunStopped    = "stopped"
+	RunRolledBack = "rolled_back"
+)
+
+// Манифест запуска для внешних систем сборки: входные и выходные файлы с хэшами,
+// модель, версия промпта и длительности ([REPORT] manifest)
+type runManifest struct {
+	Schema     string    `json:"schema"`
+	RunID      string    `json:"run_id"`
+	Operator   string    `json:"operator,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMS int64     `json:"duration_ms"`
+	// Модель и версия общего промпта (хэш как в подписи и отчете)
+	Model      string `json:"model"`
+	PromptHash string `json:"prompt_hash"`
+	InputDir   string `json:"input_dir"`
+	OutputDir  string `json:"output_dir"`
+	// Файлы в порядке завершения обработки
+	Files  []manifestFile `json:"files"`
+	Totals manifestTotals `json:"totals"`
+}
+
+// Файл запуска в манифесте
+type manifestFile struct {
+	// Ключ файла в состоянии (путь относительно входной директории, с корнем) и путь
+	// к входному файлу
+	Input       string `json:"input"`
+	InputPath   string `json:"input_path"`
+	InputSHA256 string `json:"input_sha256,omitempty"`
+	// Записанный результат и его хэш (пусто, если результат не записан)
+	Output       string `json:"output,omitempty"`
+	OutputSHA256 string `json:"output_sha256,omitempty"`
+	// Другие записанные файлы: части результата и карточки
+	Extra            []string `json:"extra,omitempty"`
+	Status           string   `json:"status"`
+	Model            string   `json:"model,omitempty"`
+	PromptHash       string   `json:"prompt_hash,omitempty"`
+	Route            string   `json:"route,omitempty"`
+	DurationMS       int64    `json:"duration_ms"`
+	APIMS            int64    `json:"api_ms,omitempty"`
+	PromptTokens     int      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int      `json:"completion_tokens,omitempty"`
+	CostUSD          float64  `json:"cost_usd,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	ErrorCategory    string   `json:"error_category,omitempty"`
+}
+
+// Итоги запуска в манифесте
+type manifestTotals struct {
+	Files            int     `json:"files"`
+	Enriched         int     `json:"enriched"`
+	Skipped          int     `json:"skipped"`
+	Failed           int     `json:"failed"`
+	Conflicts        int     `json:"conflicts"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Сбор файлов запуска для манифеста
+type runManifestFiles struct {
+	mu    sync.Mutex
+	files []manifestFile
+}
+
+// Добавление итога файла
+func (m *runManifestFiles) Record(config *Config, key, inputPath string, result *fileResult, err error) {
+	if m == nil {
+		return
+	}
+	file := manifestFile{
+		Input:            normalizeRelPath(key),
+		InputPath:        absPath(inputPath),
+		InputSHA256:      result.InputHash,
+		Status:           result.Status,
+		Model:            result.Model,
+		PromptHash:       result.PromptHash,
+		Route:            result.Route,
+		DurationMS:       result.Duration.Milliseconds(),
+		APIMS:            result.Usage.Timing.API.Milliseconds(),
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		CostUSD:          result.Usage.Cost(config),
+	}
+	if result.Written != "" {
+		file.Output, file.OutputSHA256 = absPath(result.Written), result.OutputHash
+		for _, page := range result.Pages {
+			file.Extra = append(file.Extra, absPath(page))
+		}
+		if result.CardsFile != "" {
+			file.Extra = append(file.Extra, absPath(result.CardsFile))
+		}
+	}
+	if err != nil {
+		file.Error, file.ErrorCategory = err.Error(), errorCategory(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files = append(m.files, file)
+}
+
+// Абсолютный путь для манифеста (при ошибке - путь как есть)
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// Итог запуска по отчету: отмена транзакции, остановка, ошибки файлов
+func runStatus(report *runReport) string {
+	switch {
+	case report.Transaction == TransactionRolledBack:
+		return RunRolledBack
+	case report.Stopped != "":
+		return RunStopped
+	case report.Totals.Failed > 0:
+		return RunFailed
+	}
+	return RunSucceeded
+}
+
+// Путь манифеста: {{run_id}} заменяется идентификатором запуска
+func runManifestPath(pattern, runID string) string {
+	return strings.ReplaceAll(pattern, "{{run_id}}", runID)
+}
+
+// Сохранение манифеста после отчета о запуске (итоги отчета уже подсчитаны)
+func (m *runManifestFiles) Save(config *Config, report *runReport) error {
+	if m == nil || config.RunManifestFile == "" {
+		return nil
+	}
+	m.mu.Lock()
+	files := append([]manifestFile{}, m.files...)
+	m.mu.Unlock()
+	report.mu.Lock()
+	manifest := runManifest{
+		Schema:     runManifestSchema,
+		RunID:      report.RunID,
+		Operator:   report.Operator,
+		Status:     runStatus(report),
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		DurationMS: report.FinishedAt.Sub(report.StartedAt).Milliseconds(),
+		Model:      config.ModelName,
+		PromptHash: promptHash(config.Prompt),
+		InputDir:   absPath(config.InputDir),
+		OutputDir:  absPath(config.OutputDir),
+		Files:      files,
+		Totals: manifestTotals{
+			Files:            report.Totals.Files,
+			Enriched:         report.Totals.Enriched,
+			Skipped:          report.Totals.Skipped,
+			Failed:           report.Totals.Failed,
+			Conflicts:        report.Totals.Conflicts,
+			PromptTokens:     report.Totals.PromptTokens,
+			CompletionTokens: report.Totals.CompletionTokens,
+			CostUSD:          report.Totals.CostUSD,
+		},
+	}
+	report.mu.Unlock()
+	if manifest.Files == nil {
+		manifest.Files = []manifestFile{}
+	}
+
+	path := runManifestPath(config.RunManifestFile, report.RunID)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errorf("ошибка при подготовке манифеста запуска: %v", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errorf("ошибка при записи манифеста запуска: %v", err)
+		}
+	}
+	if err := safeWriteFile(path, data, 0644); err != nil {
+		return errorf("ошибка при записи манифеста запуска: %v", err)
+	}
+	logf("Манифест запуска сохранен в %s", path)
+	return nil
+}
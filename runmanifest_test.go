@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	note := "# Заметка\n\nТекст заметки, который нужно дополнить."
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte(note), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "empty.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{{"message": map[string]string{
+			"role": "assistant", "content": "# Заметка\n\nДополненный текст заметки."}}}})
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: filepath.Join(tmpDir, ".rich"),
+		ModelName: "test-model", ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible, MaxTokens: 100,
+		Prompt: "Дополни заметку", RunManifestFile: filepath.Join(tmpDir, "runs", "{{run_id}}", "run.json")}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(tmpDir, "runs", "*", "run.json"))
+	if len(paths) != 1 {
+		t.Fatalf("ожидался один манифест запуска, найдено %v", paths)
+	}
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest runManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("некорректный манифест: %v\n%s", err, data)
+	}
+	if manifest.Schema != runManifestSchema || manifest.Status != RunSucceeded || manifest.RunID == "" ||
+		filepath.Base(filepath.Dir(paths[0])) != manifest.RunID || manifest.Model != "test-model" ||
+		manifest.PromptHash != promptHash("Дополни заметку") || manifest.Totals.Enriched != 1 || manifest.Totals.Skipped != 1 {
+		t.Errorf("манифест запуска: %+v", manifest)
+	}
+
+	files := make(map[string]manifestFile)
+	for _, f := range manifest.Files {
+		files[f.Input] = f
+	}
+	a := files["a.md"]
+	output, err := os.ReadFile(filepath.Join(outputDir, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != StatusEnriched || a.InputSHA256 != contentHash([]byte(note)) || a.OutputSHA256 != contentHash(output) ||
+		a.Output != filepath.Join(outputDir, "a.md") || a.InputPath != filepath.Join(inputDir, "a.md") || a.Model != "test-model" {
+		t.Errorf("файл a.md в манифесте: %+v", a)
+	}
+	if empty := files["empty.md"]; empty.Status != StatusSkippedTooSmall || empty.Output != "" {
+		t.Errorf("пропущенный файл в манифесте: %+v", empty)
+	}
+}
+
+func TestRunStatus(t *testing.T) {
+	tests := []struct {
+		report *runReport
+		want   string
+	}{
+		{&runReport{}, RunSucceeded},
+		{&runReport{Totals: reportTotals{Failed: 1}}, RunFailed},
+		{&runReport{Stopped: "on_error", Totals: reportTotals{Failed: 3}}, RunStopped},
+		{&runReport{Transaction: TransactionRolledBack, Totals: reportTotals{Failed: 1}}, RunRolledBack},
+	}
+	for _, tt := range tests {
+		if got := runStatus(tt.report); got != tt.want {
+			t.Errorf("runStatus(%+v) = %q, ожидалось %q", tt.report.Totals, got, tt.want)
+		}
+	}
+}
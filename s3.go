@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Регион S3 по умолчанию
+const defaultS3Region = "us-east-1"
+
+// Подключение к хранилищу S3 и совместимым с ним (MinIO, Yandex Object Storage)
+// из секции [S3]; общее для приемника s3 и источника s3://
+type s3Config struct {
+	Region   string
+	Endpoint string
+	// Ключи доступа (по умолчанию AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY) и
+	// временный токен сессии (AWS_SESSION_TOKEN)
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// Чтение секции [S3]; адрес по умолчанию строится по региону AWS
+func loadS3Config(section *ini.Section) (s3Config, error) {
+	c := s3Config{
+		Region:       section.Key("region").MustString(defaultS3Region),
+		Endpoint:     strings.TrimRight(section.Key("endpoint").String(), "/"),
+		AccessKey:    section.Key("access_key").MustString(os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey:    section.Key("secret_key").MustString(os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	if u, err := url.Parse(c.Endpoint); err != nil || u.Host == "" {
+		return c, errorf("некорректный адрес endpoint %q в секции [S3]", c.Endpoint)
+	}
+	var err error
+	if c.SecretKey, err = resolveSecret(c.SecretKey); err != nil {
+		return c, errorf("ошибка в параметре secret_key секции [S3]: %v", err)
+	}
+	return c, nil
+}
+
+// Проверка ключей доступа для приемника или источника S3
+func (c s3Config) check() error {
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return errorf("для S3 задайте access_key и secret_key в секции [S3] или переменные AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY")
+	}
+	return nil
+}
+
+// Адрес объекта или бакета в стиле пути (<endpoint>/<бакет>/<ключ>): его
+// поддерживают и AWS, и совместимые хранилища
+func (c s3Config) objectURL(bucket, key string) *url.URL {
+	u, _ := url.Parse(c.Endpoint)
+	base, rawBase := strings.TrimRight(u.Path, "/"), strings.TrimRight(u.EscapedPath(), "/")
+	u.Path, u.RawPath = base+"/"+bucket+"/", rawBase+"/"+awsEscape(bucket)+"/"
+	if key != "" {
+		u.Path += path.Clean(key)
+		u.RawPath += awsEscapePath(key)
+	}
+	return u
+}
+
+// Запрос к хранилищу с подписью AWS Signature Version 4; ответ с кодом, отличным
+// от 200, возвращается ошибкой
+func (c s3Config) do(client *http.Client, method string, u *url.URL, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	signAWSRequest(req, body, c.AccessKey, c.SecretKey, c.Region, "s3", time.Now().UTC())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, errorf("хранилище вернуло статус %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+// Загрузка объекта
+func (c s3Config) put(client *http.Client, bucket, key string, content []byte) error {
+	resp, err := c.do(client, http.MethodPut, c.objectURL(bucket, key), content, contentTypeFor(key))
+	if err != nil {
+		return errorf("ошибка загрузки %s: %w", key, err)
+	}
+	return resp.Body.Close()
+}
+
+// Содержимое объекта не больше limit байт
+func (c s3Config) get(client *http.Client, bucket, key string, limit int64) ([]byte, error) {
+	resp, err := c.do(client, http.MethodGet, c.objectURL(bucket, key), nil, "")
+	if err != nil {
+		return nil, errorf("ошибка чтения %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, errorf("ошибка чтения %s: %w", key, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errorf("объект %s больше %d байт", key, limit)
+	}
+	return data, nil
+}
+
+// Ключи объектов бакета с префиксом (ListObjectsV2 с продолжением по страницам)
+func (c s3Config) list(client *http.Client, bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		u := c.objectURL(bucket, "")
+		query := url.Values{"list-type": {"2"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalS3Query(query)
+		resp, err := c.do(client, http.MethodGet, u, nil, "")
+		if err != nil {
+			return nil, errorf("ошибка получения списка объектов %s: %w", bucket, err)
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errorf("некорректный список объектов %s: %v", bucket, err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, obj.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Параметры запроса в каноническом виде подписи: по алфавиту, с кодированием AWS
+// (подпись использует строку запроса как есть)
+func canonicalS3Query(query url.Values) string {
+	encoded := strings.ReplaceAll(query.Encode(), "+", "%20")
+	return strings.ReplaceAll(encoded, "%7E", "~")
+}
+
+// Тип содержимого объекта по расширению
+func contentTypeFor(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".md", ".markdown":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".csv":
+		return "text/csv; charset=utf-8"
+	case ".html", ".htm":
+		return "text/html; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Кодирование по правилам AWS: без изменений остаются только буквы, цифры и -._~
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Кодирование ключа объекта по частям пути: слеши сохраняются
+func awsEscapePath(key string) string {
+	parts := strings.Split(path.Clean(key), "/")
+	for i, p := range parts {
+		parts[i] = awsEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Параметры запуска на выборке (-sample)
+type sampleOptions struct {
+	// Файлов в выборке
+	Size int
+	// Начальное значение случайной выборки: одинаковый seed дает одинаковую выборку
+	Seed uint64
+	// Директория результатов выборки ("" - sample-<время>)
+	Dir string
+}
+
+// Необработанные файлы всех корней с путями в общем состоянии запуска
+func pendingFiles(config *Config) ([]candidate, error) {
+	var pending []candidate
+	for _, rootConfig := range config.inputRoots() {
+		inputDir, err := filepath.Abs(rootConfig.InputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+		cands, err := collectCandidates(rootConfig, inputDir, rootConfig.OutputDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cands {
+			key := rootKey(rootConfig.RootName, normalizeRelPath(c.RelPath))
+			if !isExcluded(config, key) {
+				pending = append(pending, candidate{Path: c.Path, RelPath: key})
+			}
+		}
+	}
+	return pending, nil
+}
+
+// Случайная выборка необработанных файлов всех корней: ключи в общем состоянии запуска
+func samplePendingFiles(config *Config, size int, seed uint64) ([]string, error) {
+	pending, err := pendingFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, size)
+	for _, c := range sampleCandidates(pending, size, seed) {
+		keys = append(keys, c.RelPath)
+	}
+	return keys, nil
+}
+
+// Конфигурация запуска на выборке и пробного запуска со сравнением: результаты
+// пишутся в dir (дополнительные корни - в поддиректории по имени корня), отчет -
+// в dir/report.json. Каталог состояния, база данных, индекс, письма, общие
+// блокировки и внешние приемники не используются, чтобы пробный запуск не влиял
+// на основной
+func sampleConfig(config *Config, dir string, keys []string) *Config {
+	sc := *config
+	sc.OutputDir = dir
+	sc.Roots = make([]inputRoot, len(config.Roots))
+	for i, root := range config.Roots {
+		root.OutputDir = filepath.Join(dir, root.Name)
+		sc.Roots[i] = root
+	}
+	sc.OnlyFiles = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		sc.OnlyFiles[pathKey(key)] = true
+	}
+	sc.StateDir = ""
+	sc.DatabaseFile = ""
+	sc.IndexFile = ""
+	sc.ReportFile = filepath.Join(dir, "report.json")
+	sc.Email = EmailConfig{}
+	sc.Redis = RedisConfig{}
+	sc.Sinks = sinksConfig{Sinks: []string{SinkLocal}}
+	sc.Transactional = false
+	sc.Resume = false
+	sc.Incremental = false
+	sc.Canary = CanaryConfig{}
+	sc.OutputLayout = OutputLayoutFlat
+	return &sc
+}
+
+// Обработка случайной выборки необработанных файлов в отдельную директорию для
+// проверки промпта перед полным запуском. Выходная директория и список исключений
+// основной конфигурации не изменяются: исключения выборки пишутся во временный файл
+func runSample(config *Config, opts sampleOptions) error {
+	if config.InputSource != "" {
+		return withCategory(ErrorConfig, errorf("-sample работает только с входной директорией input_dir, а не с input_source"))
+	}
+	keys, err := samplePendingFiles(config, opts.Size, opts.Seed)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		infof("Необработанных файлов для выборки нет")
+		return nil
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "sample-" + time.Now().Format("20060102-150405")
+	}
+	scratch, err := os.MkdirTemp("", "rich-sample-")
+	if err != nil {
+		return withCategory(ErrorIO, errorf("не удалось создать временную директорию: %v", err))
+	}
+	defer os.RemoveAll(scratch)
+
+	infof("Выборка (seed %d): %d файлов, результаты в %s", opts.Seed, len(keys), dir)
+	for _, key := range keys {
+		logf("Файл выборки: %s", key)
+	}
+	return processDirectory(sampleConfig(config, dir, keys), filepath.Join(scratch, "rich.cfg"))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRunSample(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(inputDir, fmt.Sprintf("note%02d.md", i))
+		if err := os.WriteFile(name, []byte(fmt.Sprintf("# Заметка %d\n\nТекст", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[EXCLUSIONS]\nexcluded_files = note00.md, note01.md\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Выборка воспроизводима и не содержит обработанных файлов
+	first, err := samplePendingFiles(config, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := samplePendingFiles(config, 3, 42)
+	if len(first) != 3 || !slices.Equal(first, second) {
+		t.Fatalf("Выборка с одинаковым seed должна совпадать: %v и %v", first, second)
+	}
+	if slices.Contains(first, "note00.md") || slices.Contains(first, "note01.md") {
+		t.Errorf("Выборка не должна содержать обработанные файлы: %v", first)
+	}
+
+	sampleDir := filepath.Join(tmpDir, "sample")
+	if err := runSample(config, sampleOptions{Size: 3, Seed: 42, Dir: sampleDir}); err != nil {
+		t.Fatalf("runSample() вернул ошибку: %v", err)
+	}
+	for _, key := range first {
+		if _, err := os.Stat(filepath.Join(sampleDir, key)); err != nil {
+			t.Errorf("Нет результата файла выборки %s: %v", key, err)
+		}
+	}
+	entries, _ := os.ReadDir(sampleDir)
+	if len(entries) != 4 {
+		t.Errorf("В директории выборки ожидались 3 результата и отчет, найдено %d", len(entries))
+	}
+	if outputs, _ := os.ReadDir(outputDir); len(outputs) > 0 {
+		t.Errorf("Выходная директория не должна изменяться при запуске на выборке: %d файлов", len(outputs))
+	}
+	reloaded, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reloaded.ExcludedFiles, config.ExcludedFiles) {
+		t.Errorf("Список исключений не должен изменяться: %v", reloaded.ExcludedFiles)
+	}
+}
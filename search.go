@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Максимальная длина фрагмента в результатах поиска (символов)
+const searchSnippetRunes = 240
+
+// Документ, найденный поиском по обогащенным документам
+type searchHit struct {
+	// Путь относительно выходной директории
+	Path  string
+	Score float64
+	// Наиболее близкий к запросу фрагмент документа
+	Snippet string
+}
+
+// Текст обогащенного документа для поиска: без блока с оригиналом и frontmatter
+func searchableText(data []byte) string {
+	text := string(data)
+	if prev, ok := parseEnrichedOutput(text); ok {
+		text = prev.Enriched
+	}
+	_, body, _ := parseFrontmatter([]byte(text))
+	return string(body)
+}
+
+// Фрагменты обогащенных документов выходной директории
+func outputChunks(config *Config) ([]contextChunk, error) {
+	outputDir := config.OutputDir
+	chunkWords := config.ContextChunkWords
+	if chunkWords <= 0 {
+		chunkWords = 200
+	}
+
+	var chunks []contextChunk
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Служебные директории (.rich, .git) не индексируются
+		if d.IsDir() && path != outputDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errorf("ошибка при чтении файла: %v", err)
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		// Оглавление и файл изменений повторяют описания документов и не участвуют в поиске
+		if config.isServiceFile(rel) {
+			return nil
+		}
+		for _, text := range splitIntoChunks(searchableText(data), chunkWords) {
+			chunks = append(chunks, contextChunk{Source: normalizeRelPath(rel), Text: text})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при обходе выходной директории: %v", err)
+	}
+	return chunks, nil
+}
+
+// Фрагмент со сходством с запросом
+type scoredChunk struct {
+	contextChunk
+	Score float64
+}
+
+// Фрагменты, похожие на запрос, по убыванию сходства
+func rankChunks(embedder Embedder, query string, chunks []contextChunk) ([]scoredChunk, error) {
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, 0, len(chunks)+1)
+	texts = append(texts, query)
+	for _, c := range chunks {
+		texts = append(texts, c.Text)
+	}
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return nil, errorf("ошибка при построении векторов документов: %v", err)
+	}
+
+	var ranked []scoredChunk
+	for i, c := range chunks {
+		if score := cosineSimilarity(vectors[0], vectors[i+1]); score > 0 {
+			ranked = append(ranked, scoredChunk{contextChunk: c, Score: score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	return ranked, nil
+}
+
+// Поиск документов выходной директории, наиболее близких к запросу по смыслу.
+// Документы разбиваются на фрагменты, оценка документа - сходство лучшего фрагмента
+func searchCorpus(config *Config, embedder Embedder, query string, top int) ([]searchHit, error) {
+	chunks, err := outputChunks(config)
+	if err != nil {
+		return nil, err
+	}
+	ranked, err := rankChunks(embedder, query, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	best := map[string]searchHit{}
+	for _, c := range ranked {
+		if _, ok := best[c.Source]; !ok {
+			best[c.Source] = searchHit{Path: c.Source, Score: c.Score, Snippet: c.Text}
+		}
+	}
+	hits := make([]searchHit, 0, len(best))
+	for _, hit := range best {
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+	if top > 0 && len(hits) > top {
+		hits = hits[:top]
+	}
+	for i := range hits {
+		hits[i].Snippet = snippet(hits[i].Snippet, searchSnippetRunes)
+	}
+	return hits, nil
+}
+
+// Фрагмент в одну строку не длиннее limit символов
+func snippet(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:limit])) + "…"
+}
+
+// rich search [--top N] "запрос": поиск по смыслу среди обогащенных документов
+func runSearchCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	top := fs.Int("top", 5, tr("Количество найденных документов"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if query == "" {
+		return errorf("не задан поисковый запрос: rich search \"запрос\"")
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	embedder, err := newEmbedder(config)
+	if err != nil {
+		return err
+	}
+
+	hits, err := searchCorpus(config, embedder, query, *top)
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		fmt.Fprintln(out, tr("Ничего не найдено"))
+		return nil
+	}
+	for i, hit := range hits {
+		fmt.Fprintf(out, "%d. %s (%.2f)\n   %s\n", i+1, hit.Path, hit.Score, hit.Snippet)
+	}
+	return nil
+}
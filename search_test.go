@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchCorpus(t *testing.T) {
+	outputDir := t.TempDir()
+	files := map[string]string{
+		"ops/backup.md":   "---\ntitle: Бэкапы\n---\n# Резервное копирование\n\nЕжедневные снимки базы данных хранятся тридцать дней.\n\n```old\nоригинал про погоду\n```",
+		"food/pancake.md": "# Блины\n\nМука, молоко, яйца и щепотка соли.",
+		".rich/runs/x.md": "Снимки базы данных из каталога состояния",
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл: %v", err)
+		}
+	}
+
+	config := &Config{OutputDir: outputDir}
+	hits, err := searchCorpus(config, localEmbedder{}, "снимки базы данных", 5)
+	if err != nil {
+		t.Fatalf("searchCorpus() вернул ошибку: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Path != "ops/backup.md" {
+		t.Fatalf("Ожидался один документ ops/backup.md, получено %+v", hits)
+	}
+	if !strings.Contains(hits[0].Snippet, "Ежедневные снимки") || strings.Contains(hits[0].Snippet, "title") {
+		t.Errorf("Фрагмент должен браться из обогащенного текста без frontmatter: %q", hits[0].Snippet)
+	}
+
+	// Блок с оригиналом в поиске не участвует
+	if hits, _ := searchCorpus(config, localEmbedder{}, "оригинал про погоду", 5); len(hits) != 0 {
+		t.Errorf("Оригинал документа не должен находиться поиском: %+v", hits)
+	}
+}
+
+func TestRunSearchCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "done")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "note.md"), []byte("Настройка репликации PostgreSQL"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	if err := os.WriteFile(configPath, []byte("[DIRECTORIES]\noutput_dir = "+outputDir+"\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runSearchCommand([]string{"-config", configPath, "репликации", "postgresql"}, &out); err != nil {
+		t.Fatalf("runSearchCommand() вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "1. note.md (") || !strings.Contains(out.String(), "Настройка репликации") {
+		t.Errorf("Неожиданный вывод: %q", out.String())
+	}
+	if err := runSearchCommand([]string{"-config", configPath}, &out); err == nil {
+		t.Error("Пустой запрос должен возвращать ошибку")
+	}
+}
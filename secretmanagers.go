@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Ссылки на внешние хранилища секретов
+const (
+	// vault:<путь>#<поле> - HashiCorp Vault (KV v1 и v2), адрес и токен из VAULT_ADDR и VAULT_TOKEN
+	vaultPrefix = "vault:"
+	// aws-sm:<имя или ARN>#<поле> - AWS Secrets Manager, учетные данные из переменных AWS_*
+	awsSecretPrefix = "aws-sm:"
+	// gcp-sm:<проект>/<секрет>[/<версия>]#<поле> - Google Secret Manager, учетные данные Google по умолчанию
+	gcpSecretPrefix = "gcp-sm:"
+)
+
+// Время жизни секрета из внешнего хранилища, если хранилище его не сообщает:
+// по истечении секрет запрашивается заново, так что ротация ключей подхватывается
+// службой без перезапуска
+const remoteSecretTTL = 15 * time.Minute
+
+// Адрес Google Secret Manager
+var gcpSecretManagerURL = "https://secretmanager.googleapis.com"
+
+// Ссылка на внешнее хранилище секретов
+func isRemoteSecretRef(value string) bool {
+	return strings.HasPrefix(value, vaultPrefix) || strings.HasPrefix(value, awsSecretPrefix) || strings.HasPrefix(value, gcpSecretPrefix)
+}
+
+// Получение секрета из внешнего хранилища и времени, на которое его можно кэшировать
+func fetchRemoteSecret(value string) (string, time.Duration, error) {
+	if ref, ok := strings.CutPrefix(value, vaultPrefix); ok {
+		return fetchVaultSecret(ref)
+	}
+	if ref, ok := strings.CutPrefix(value, awsSecretPrefix); ok {
+		secret, err := fetchAWSSecret(ref)
+		return secret, remoteSecretTTL, err
+	}
+	secret, err := fetchGCPSecret(strings.TrimPrefix(value, gcpSecretPrefix))
+	return secret, remoteSecretTTL, err
+}
+
+// Путь секрета и поле после #
+func splitSecretField(ref string) (path, field string) {
+	path, field, _ = strings.Cut(ref, "#")
+	return path, field
+}
+
+// Строковое поле секрета; без имени поля секрет должен содержать ровно одно поле
+func secretMapField(values map[string]interface{}, field, ref string) (string, error) {
+	if field == "" {
+		if len(values) != 1 {
+			return "", errorf("секрет %s содержит несколько полей: укажите поле после #", ref)
+		}
+		for name := range values {
+			field = name
+		}
+	}
+	value, ok := values[field].(string)
+	if !ok {
+		return "", errorf("в секрете %s нет строкового поля %s", ref, field)
+	}
+	return value, nil
+}
+
+// Значение секрета, хранящегося строкой: с полем строка разбирается как объект JSON
+func secretStringField(value, field, ref string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return "", errorf("секрет %s не является объектом JSON, поле %s недоступно", ref, field)
+	}
+	return secretMapField(values, field, ref)
+}
+
+// Выполнение запроса к хранилищу секретов
+func secretStoreRequest(req *http.Request, store string) ([]byte, error) {
+	setUserAgent(req)
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errorf("ошибка запроса к %s: %v", store, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorf("ошибка запроса к %s: %v", store, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorf("%s вернул статус %d: %s", store, resp.StatusCode, truncateRunes(strings.TrimSpace(string(body)), 300))
+	}
+	return body, nil
+}
+
+// Токен Vault: VAULT_TOKEN или файл ~/.vault-token, который сохраняет vault login
+func vaultToken() string {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// Секрет из Vault; срок кэширования - lease_duration ответа (у KV v2 его нет)
+func fetchVaultSecret(ref string) (string, time.Duration, error) {
+	path, field := splitSecretField(ref)
+	addr, token := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"), vaultToken()
+	if addr == "" || token == "" {
+		return "", 0, errorf("для ссылки vault: задайте VAULT_ADDR и VAULT_TOKEN (или выполните vault login)")
+	}
+	req, err := http.NewRequest("GET", addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", 0, errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	body, err := secretStoreRequest(req, "Vault")
+	if err != nil {
+		return "", 0, err
+	}
+	var resp struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", 0, errorf("некорректный ответ %s: %v", "Vault", err)
+	}
+	// KV v2 вкладывает значения в data.data рядом с data.metadata
+	values := resp.Data
+	if inner, ok := values["data"].(map[string]interface{}); ok {
+		if _, ok := values["metadata"]; ok {
+			values = inner
+		}
+	}
+	value, err := secretMapField(values, field, path)
+	if err != nil {
+		return "", 0, err
+	}
+	ttl := remoteSecretTTL
+	if resp.LeaseDuration > 0 {
+		ttl = time.Duration(resp.LeaseDuration) * time.Second
+	}
+	return value, ttl, nil
+}
+
+// Регион AWS: из ARN секрета, AWS_REGION или AWS_DEFAULT_REGION
+func awsRegion(secretID string) string {
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// Секрет из AWS Secrets Manager (GetSecretValue с подписью Signature Version 4)
+func fetchAWSSecret(ref string) (string, error) {
+	id, field := splitSecretField(ref)
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", errorf("для ссылки aws-sm: задайте AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY")
+	}
+	region := awsRegion(id)
+	if region == "" {
+		return "", errorf("для ссылки aws-sm: задайте регион в AWS_REGION или укажите ARN секрета")
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	payload, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequest("POST", strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, payload, accessKey, secretKey, region, "secretsmanager", time.Now().UTC())
+	body, err := secretStoreRequest(req, "AWS Secrets Manager")
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary []byte `json:"SecretBinary"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", errorf("некорректный ответ %s: %v", "AWS Secrets Manager", err)
+	}
+	if resp.SecretString == "" {
+		resp.SecretString = string(resp.SecretBinary)
+	}
+	return secretStringField(resp.SecretString, field, id)
+}
+
+// Подпись запроса AWS Signature Version 4: подписываются Host и все заголовки запроса
+func signAWSRequest(req *http.Request, payload []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate, date := now.Format("20060102T150405Z"), now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Полное имя версии секрета Google: projects/<проект>/secrets/<секрет>/versions/<версия>;
+// сокращение <проект>/<секрет>[/<версия>] дополняется, версия по умолчанию - latest
+func gcpSecretName(path string) (string, error) {
+	if strings.HasPrefix(path, "projects/") {
+		if !strings.Contains(path, "/versions/") {
+			path += "/versions/latest"
+		}
+		return path, nil
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return "", errorf("некорректная ссылка gcp-sm:%s: ожидалось <проект>/<секрет>[/<версия>]", path)
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	return "projects/" + parts[0] + "/secrets/" + parts[1] + "/versions/" + version, nil
+}
+
+// Секрет из Google Secret Manager
+func fetchGCPSecret(ref string) (string, error) {
+	path, field := splitSecretField(ref)
+	name, err := gcpSecretName(path)
+	if err != nil {
+		return "", err
+	}
+	token, err := googleAccessToken("")
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", gcpSecretManagerURL+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", errorf("ошибка при создании HTTP запроса: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	body, err := secretStoreRequest(req, "Google Secret Manager")
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", errorf("некорректный ответ %s: %v", "Google Secret Manager", err)
+	}
+	return secretStringField(string(resp.Payload.Data), field, path)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVaultSecret(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "s.test" || r.URL.Path != "/v1/secret/data/rich" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"lease_duration": 0, "data": {"data": {"openai": "sk-vault", "other": "x"}, "metadata": {"version": 3}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.test")
+
+	// Значение в конфигурации: # внутри ссылки не считается комментарием
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "test.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + dir + "   # входные файлы\n\n[MODEL]\napi_key = vault:secret/data/rich#openai\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.APIKey != "sk-vault" || config.InputDir != dir {
+		t.Errorf("ожидался ключ sk-vault и каталог %s, получено %q и %q", dir, config.APIKey, config.InputDir)
+	}
+	if _, err := resolveSecret("vault:secret/data/rich#openai"); err != nil || requests != 1 {
+		t.Errorf("секрет должен браться из кэша: запросов %d (%v)", requests, err)
+	}
+
+	// По истечении срока секрет запрашивается заново
+	secretCache.mu.Lock()
+	secretCache.entries["vault:secret/data/rich#openai"] = cachedSecret{Value: "old", Expires: time.Now().Add(-time.Second)}
+	secretCache.mu.Unlock()
+	if key, err := resolveSecret("vault:secret/data/rich#openai"); err != nil || key != "sk-vault" || requests != 2 {
+		t.Errorf("истекший секрет должен обновляться: %q, запросов %d (%v)", key, requests, err)
+	}
+
+	if _, err := resolveSecret("vault:secret/data/rich"); err == nil {
+		t.Error("без поля для секрета с несколькими полями ожидалась ошибка")
+	}
+	if _, err := resolveSecret("vault:secret/data/other#openai"); err == nil {
+		t.Error("ожидалась ошибка для запрещенного пути")
+	}
+}
+
+func TestAWSSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		var req map[string]string
+		_ = json.Unmarshal(body, &req)
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"openai": "sk-aws-` + req["SecretId"] + `"}`})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+
+	if key, err := resolveSecret("aws-sm:rich/keys#openai"); err != nil || key != "sk-aws-rich/keys" {
+		t.Errorf("ожидалось sk-aws-rich/keys, получено %q (%v)", key, err)
+	}
+	if region := awsRegion("arn:aws:secretsmanager:us-east-2:123456789012:secret:rich"); region != "us-east-2" {
+		t.Errorf("регион должен браться из ARN, получено %q", region)
+	}
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// Пример get-vanilla из набора тестов Signature Version 4
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signAWSRequest(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("некорректная подпись:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestGCPSecretName(t *testing.T) {
+	tests := map[string]string{
+		"proj/openai":                     "projects/proj/secrets/openai/versions/latest",
+		"proj/openai/3":                   "projects/proj/secrets/openai/versions/3",
+		"projects/proj/secrets/openai":    "projects/proj/secrets/openai/versions/latest",
+		"projects/p/secrets/s/versions/2": "projects/p/secrets/s/versions/2",
+	}
+	for ref, want := range tests {
+		if got, err := gcpSecretName(ref); err != nil || got != want {
+			t.Errorf("%s: ожидалось %s, получено %s (%v)", ref, want, got, err)
+		}
+	}
+	if _, err := gcpSecretName("openai"); err == nil {
+		t.Error("ожидалась ошибка для ссылки без проекта")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ссылки на секреты в значениях конфигурации
+const (
+	// keychain:<служба>/<учетная запись> - хранилище ОС (Keychain, Credential Manager, Secret Service)
+	keychainPrefix = "keychain:"
+	// enc:v1:<base64> - значение, зашифрованное парольной фразой (AES-256-GCM)
+	encryptedPrefix = "enc:v1:"
+)
+
+// Переменная окружения с парольной фразой для зашифрованных значений
+const passphraseEnv = "RICH_PASSPHRASE"
+
+// Параметры шифрования: соль, nonce GCM и число итераций PBKDF2
+const (
+	secretSaltSize   = 16
+	secretIterations = 600000
+)
+
+// Разрешенный секрет; нулевой момент истечения - секрет хранится до конца работы процесса
+type cachedSecret struct {
+	Value   string
+	Expires time.Time
+}
+
+// Разрешенные секреты: конфигурация перечитывается службой, обращение к хранилищу
+// ОС может запрашивать подтверждение, а внешние хранилища не должны опрашиваться
+// на каждый запуск. Секреты внешних хранилищ запрашиваются заново по истечении срока
+var secretCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedSecret
+}{entries: map[string]cachedSecret{}}
+
+// Значение секрета: ссылка на хранилище ОС или внешнее хранилище и зашифрованное
+// значение разрешаются, остальные значения возвращаются как есть
+func resolveSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, keychainPrefix) && !strings.HasPrefix(value, encryptedPrefix) && !isRemoteSecretRef(value) {
+		return value, nil
+	}
+	secretCache.mu.Lock()
+	defer secretCache.mu.Unlock()
+	if cached, ok := secretCache.entries[value]; ok && (cached.Expires.IsZero() || time.Now().Before(cached.Expires)) {
+		return cached.Value, nil
+	}
+	var entry cachedSecret
+	var err error
+	if ref, ok := strings.CutPrefix(value, keychainPrefix); ok {
+		service, account := parseKeychainRef(ref)
+		entry.Value, err = keychainGet(service, account)
+		if err != nil {
+			return "", errorf("не удалось получить секрет %s из хранилища ОС: %v", ref, err)
+		}
+	} else if strings.HasPrefix(value, encryptedPrefix) {
+		passphrase := os.Getenv(passphraseEnv)
+		if passphrase == "" {
+			return "", errorf("для зашифрованного значения задайте парольную фразу в переменной %s", passphraseEnv)
+		}
+		if entry.Value, err = decryptSecret(value, passphrase); err != nil {
+			return "", err
+		}
+	} else {
+		var ttl time.Duration
+		if entry.Value, ttl, err = fetchRemoteSecret(value); err != nil {
+			return "", errorf("не удалось получить секрет %s: %v", value, err)
+		}
+		entry.Expires = time.Now().Add(ttl)
+		logf("Получен секрет %s, действует до %s", value, entry.Expires.Format(time.RFC3339))
+	}
+	secretCache.entries[value] = entry
+	return entry.Value, nil
+}
+
+// Служба и учетная запись ссылки keychain:rich/openai; без службы используется rich
+func parseKeychainRef(ref string) (service, account string) {
+	if service, account, ok := strings.Cut(ref, "/"); ok {
+		return service, account
+	}
+	return "rich", ref
+}
+
+// Ключ шифрования из парольной фразы
+func secretKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, secretIterations, 32)
+}
+
+// Шифрование значения парольной фразой: enc:v1:base64(соль | nonce | шифртекст)
+func encryptSecret(plain, passphrase string) (string, error) {
+	salt := make([]byte, secretSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := secretKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := append(append(salt, nonce...), gcm.Seal(nil, nonce, []byte(plain), nil)...)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Расшифровка значения enc:v1:...
+func decryptSecret(value, passphrase string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(data) < secretSaltSize {
+		return "", errorf("некорректное зашифрованное значение")
+	}
+	key, err := secretKey(passphrase, data[:secretSaltSize])
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	data = data[secretSaltSize:]
+	if len(data) < gcm.NonceSize() {
+		return "", errorf("некорректное зашифрованное значение")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errorf("не удалось расшифровать значение: неверная парольная фраза или поврежденные данные")
+	}
+	return string(plain), nil
+}
+
+// Чтение секрета из первой строки стандартного ввода
+func readSecretInput(in io.Reader) (string, error) {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		return "", errorf("секрет не задан: передайте его в стандартный ввод")
+	}
+	return secret, nil
+}
+
+// rich secret set <служба>/<учетная запись> | rich secret encrypt: сохранение ключа
+// в хранилище ОС или шифрование парольной фразой; секрет читается из стандартного ввода
+func runSecretCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errorf("укажите действие: rich secret set <служба>/<учетная запись> | rich secret encrypt")
+	}
+	switch args[0] {
+	case "set":
+		if len(args) != 2 {
+			return errorf("укажите секрет: rich secret set <служба>/<учетная запись>")
+		}
+		secret, err := readSecretInput(os.Stdin)
+		if err != nil {
+			return err
+		}
+		service, account := parseKeychainRef(strings.TrimPrefix(args[1], keychainPrefix))
+		if err := keychainSet(service, account, secret); err != nil {
+			return errorf("не удалось сохранить секрет в хранилище ОС: %v", err)
+		}
+		fmt.Fprintf(out, tr("Секрет сохранен, укажите в конфигурации: api_key = %s\n"), keychainPrefix+service+"/"+account)
+		return nil
+	case "encrypt":
+		passphrase := os.Getenv(passphraseEnv)
+		if passphrase == "" {
+			return errorf("для зашифрованного значения задайте парольную фразу в переменной %s", passphraseEnv)
+		}
+		secret, err := readSecretInput(os.Stdin)
+		if err != nil {
+			return err
+		}
+		encrypted, err := encryptSecret(secret, passphrase)
+		if err != nil {
+			return errorf("не удалось зашифровать секрет: %v", err)
+		}
+		fmt.Fprintln(out, encrypted)
+		return nil
+	}
+	return errorf("неизвестное действие %q: rich secret set|encrypt", args[0])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	encrypted, err := encryptSecret("sk-test", "фраза")
+	if err != nil {
+		t.Fatalf("encryptSecret() вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "sk-test") {
+		t.Fatalf("некорректное зашифрованное значение: %s", encrypted)
+	}
+	if plain, err := decryptSecret(encrypted, "фраза"); err != nil || plain != "sk-test" {
+		t.Errorf("ожидалось sk-test, получено %q (%v)", plain, err)
+	}
+	if _, err := decryptSecret(encrypted, "другая"); err == nil {
+		t.Error("ожидалась ошибка для неверной парольной фразы")
+	}
+	if _, err := decryptSecret(encryptedPrefix+"AAAA", "фраза"); err == nil {
+		t.Error("ожидалась ошибка для поврежденного значения")
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	if got, err := resolveSecret("plain-key"); err != nil || got != "plain-key" {
+		t.Errorf("обычное значение должно возвращаться как есть: %q (%v)", got, err)
+	}
+	if service, account := parseKeychainRef("team/openai"); service != "team" || account != "openai" {
+		t.Errorf("ожидалось team/openai, получено %s/%s", service, account)
+	}
+	if service, account := parseKeychainRef("openai"); service != "rich" || account != "openai" {
+		t.Errorf("ожидалось rich/openai, получено %s/%s", service, account)
+	}
+
+	encrypted, err := encryptSecret("sk-config", "фраза")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(passphraseEnv, "")
+	if _, err := resolveSecret(encrypted); err == nil {
+		t.Error("без парольной фразы ожидалась ошибка")
+	}
+
+	t.Setenv(passphraseEnv, "фраза")
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "test.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + dir + "\n\n[MODEL]\napi_key = " + encrypted + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig() вернул ошибку: %v", err)
+	}
+	if config.APIKey != "sk-config" {
+		t.Errorf("ключ не расшифрован: %q", config.APIKey)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Результат обогащения, раздел которого обновляется: выходной файл и его входной файл
+type sectionTarget struct {
+	// Абсолютный путь выходного файла
+	Output string
+	// Ключ входного файла (с именем корня) и запись журнала о результате, если есть
+	Key    string
+	Record *outputRecord
+}
+
+// Поиск входного файла по выходному: по журналу запусков (учитывает маршруты и
+// переименование по заголовку), иначе - по пути в выходной директории корня
+func findSectionTarget(config *Config, path string) (sectionTarget, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return sectionTarget{}, err
+	}
+	if config.StateDir != "" {
+		latest, err := latestOutputs(config.StateDir)
+		if err != nil {
+			return sectionTarget{}, err
+		}
+		for key, rec := range latest {
+			if samePath(rec.Entry.Output, abs) {
+				return sectionTarget{Output: rec.Entry.Output, Key: key, Record: &rec}, nil
+			}
+		}
+	}
+	for _, root := range config.inputRoots() {
+		outputDir, err := filepath.Abs(root.OutputDir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(outputDir, abs); err == nil && isRelPathSafe(rel) {
+			return sectionTarget{Output: abs, Key: rootKey(root.RootName, normalizeRelPath(rel))}, nil
+		}
+	}
+	return sectionTarget{}, errorf("%s не является результатом обогащения: файла нет в журнале запусков и выходных директориях", path)
+}
+
+// Единственный раздел документа под заголовком
+func findNamedSection(content, heading string) (docSection, error) {
+	sections := findHeadingSections(content, []string{heading})
+	switch len(sections) {
+	case 0:
+		return docSection{}, errorf("раздел %q не найден или пуст", heading)
+	case 1:
+		return sections[0], nil
+	}
+	return docSection{}, errorf("заголовок %q встречается в документе %d раз", heading, len(sections))
+}
+
+// Обновленный раздел результата
+type sectionUpdate struct {
+	// Новое содержимое выходного файла
+	Content []byte
+	// Новый текст раздела
+	Section string
+	// Раздел взят из входного файла (false - обогащен заново текущий текст результата)
+	FromInput bool
+	Usage     Usage
+}
+
+// Повторное обогащение одного раздела результата. Если раздел есть во входном файле,
+// обогащается его текущий текст, а раздел оригинала в блоке old обновляется; иначе
+// заново обогащается раздел самого результата. Остальной документ не меняется
+func reenrichSection(config *Config, target sectionTarget, heading string, limiter *RateLimiter) (sectionUpdate, error) {
+	var update sectionUpdate
+	data, err := readMarkdownFile(target.Output)
+	if err != nil {
+		return update, errorf("ошибка при чтении выходного файла: %v", err)
+	}
+	enriched, original, hasOriginal := string(data), "", false
+	if prev, ok := parseEnrichedOutput(enriched); ok {
+		enriched, original, hasOriginal = prev.Enriched, prev.Original, true
+	}
+	sec, err := findNamedSection(enriched, heading)
+	if err != nil {
+		return update, errorf("%s: %v", target.Output, err)
+	}
+
+	rootConfig, rel := config.rootForKey(target.Key)
+	source := sec.Text(enriched)
+	input, err := readMarkdownFile(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rel)))
+	if err == nil {
+		if inputSec, err := findNamedSection(string(input), heading); err == nil {
+			source, update.FromInput = inputSec.Text(string(input)), true
+		}
+	}
+	if !update.FromInput {
+		logf("Раздел %q не найден во входном файле %s, обогащается текст результата", heading, target.Key)
+	}
+
+	fileConfig, _, _ := resolveFileConfig(rootConfig, filepath.FromSlash(rel), input)
+	text, usage, err := enrichContentWithUsage(&fileConfig, source, limiter)
+	update.Usage = usage
+	if err != nil {
+		return update, err
+	}
+	update.Section = strings.TrimSpace(text)
+	enriched = spliceSections(enriched, []docSection{sec}, []string{update.Section})
+
+	if !hasOriginal {
+		update.Content = []byte(enriched)
+		return update, nil
+	}
+	if update.FromInput {
+		if origSec, err := findNamedSection(original, heading); err == nil {
+			original = spliceSections(original, []docSection{origSec}, []string{source})
+		}
+	}
+	escaped := strings.ReplaceAll(original, "```", "\\`\\`\\`")
+	update.Content = []byte(fmt.Sprintf("%s\n\n```old\n%s\n```", enriched, escaped))
+	return update, nil
+}
+
+// Запись обновленного результата. С каталогом состояния обновление записывается
+// отдельным запуском (резервная копия, rich undo), а новый хэш результата не
+// считается ручной правкой при следующей обработке
+func applySectionUpdate(config *Config, configPath string, target sectionTarget, content []byte) (string, error) {
+	if isGzipMarkdownPath(target.Output) {
+		var err error
+		if content, err = gzipBytes(content); err != nil {
+			return "", err
+		}
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(target.Output); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if config.StateDir == "" {
+		return "", withCategory(ErrorIO, safeWriteFile(target.Output, content, mode))
+	}
+	journal, err := startRun(config.StateDir, configPath, config.Operator)
+	if err != nil {
+		return "", err
+	}
+	backup, err := journal.Backup(target.Output, target.Key)
+	if err != nil {
+		return "", errorf("ошибка при резервном копировании выходного файла: %v", err)
+	}
+	if err := safeWriteFile(target.Output, content, mode); err != nil {
+		return "", withCategory(ErrorIO, err)
+	}
+	if err := saveOutputSnapshot(config.StateDir, content); err != nil {
+		warnf("Предупреждение: не удалось сохранить копию результата: %v", err)
+	}
+	entry := journalEntry{Input: target.Key, Output: target.Output, Status: StatusEnriched, OutputHash: contentHash(content), Backup: backup}
+	if target.Record != nil {
+		entry.InputHash, entry.PromptHash, entry.Model = target.Record.Entry.InputHash, target.Record.Entry.PromptHash, target.Record.Entry.Model
+		entry.Experiment, entry.Variant = target.Record.Entry.Experiment, target.Record.Entry.Variant
+	}
+	if err := journal.Record(entry); err != nil {
+		return journal.ID, err
+	}
+	return journal.ID, journal.Finish()
+}
+
+// rich section [--dry-run] <выходной файл> <заголовок>: повторное обогащение одного
+// раздела обогащенного документа с подстановкой нового раздела на место прежнего
+func runSectionCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("section", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	dryRun := fs.Bool("dry-run", false, tr("Только показать новый раздел, файлы не изменяются"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errorf("укажите результат и заголовок раздела: rich section <файл> \"## Заголовок\"")
+	}
+	heading := strings.TrimSpace(fs.Arg(1))
+	if !isHeadingLine(heading) {
+		return errorf("%q не является заголовком markdown (например \"## Установка\")", heading)
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	target, err := findSectionTarget(config, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	update, err := reenrichSection(config, target, heading, config.newRateLimiter())
+	if err != nil {
+		return err
+	}
+	source := tr("текущий текст результата")
+	if update.FromInput {
+		source = tr("входной файл")
+	}
+	fmt.Fprintf(out, tr("Раздел %s (источник: %s):")+"\n\n%s\n\n", heading, source, update.Section)
+	if cost := update.Usage.Cost(config); cost > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), cost)
+	}
+	if *dryRun {
+		return nil
+	}
+	runID, err := applySectionUpdate(config, *configPath, target, update.Content)
+	if err != nil {
+		return err
+	}
+	if runID != "" {
+		fmt.Fprintf(out, tr("Раздел обновлен в %s (rich undo --run %s)\n"), target.Output, runID)
+	} else {
+		fmt.Fprintf(out, tr("Раздел обновлен в %s\n"), target.Output)
+	}
+	return nil
+}
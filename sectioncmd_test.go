@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSectionCommand(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		content := "Обогащенная установка: make install"
+		if strings.Contains(string(body), "Использование") {
+			content = "# Руководство\\n\\nВведение (обогащено)\\n\\n## Установка\\n\\nmake (обогащено)\\n\\n## Использование\\n\\nзапуск (обогащено)"
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "done")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	inputPath := filepath.Join(inputDir, "guide.md")
+	if err := os.WriteFile(inputPath, []byte("# Руководство\n\nВведение\n\n## Установка\n\nmake\n\n## Использование\n\nзапуск\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+		"[STATE]\ndir = " + filepath.Join(tmpDir, "state") + "\n[EXCLUSIONS]\nexcluded_files =\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	outputPath := filepath.Join(outputDir, "guide.md")
+	before, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Небольшая правка одного раздела входного файла
+	if err := os.WriteFile(inputPath, []byte("# Руководство\n\nВведение\n\n## Установка\n\nmake install\n\n## Использование\n\nзапуск\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSectionCommand([]string{"-config", configPath, outputPath, "## Неизвестный"}, io.Discard); err == nil {
+		t.Error("Ожидалась ошибка для отсутствующего раздела")
+	}
+	var out bytes.Buffer
+	if err := runSectionCommand([]string{"-config", configPath, "-dry-run", outputPath, "## Установка"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(outputPath); !bytes.Equal(data, before) {
+		t.Error("-dry-run не должен изменять результат")
+	}
+	if !strings.Contains(out.String(), "Обогащенная установка") {
+		t.Errorf("-dry-run должен показывать новый раздел:\n%s", out.String())
+	}
+
+	mu.Lock()
+	bodies = nil
+	mu.Unlock()
+	out.Reset()
+	if err := runSectionCommand([]string{"-config", configPath, outputPath, "## Установка"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "make install") || strings.Contains(bodies[0], "запуск") {
+		t.Errorf("В модель должен отправляться только раздел входного файла: %v", bodies)
+	}
+	mu.Unlock()
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, ok := parseEnrichedOutput(string(data))
+	if !ok {
+		t.Fatalf("Результат должен сохранить блок оригинала:\n%s", data)
+	}
+	want := "# Руководство\n\nВведение (обогащено)\n\n## Установка\n\nОбогащенная установка: make install\n\n## Использование\n\nзапуск (обогащено)"
+	if prev.Enriched != want {
+		t.Errorf("Неожиданный результат:\n%s\nожидалось:\n%s", prev.Enriched, want)
+	}
+	if needsIncrementalUpdate(inputPath, outputPath) {
+		t.Error("Раздел оригинала в блоке old должен обновиться")
+	}
+
+	// Обновление записано запуском: новый хэш результата не считается ручной правкой
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := latest["guide.md"]; rec.Entry.OutputHash != contentHash(data) || rec.Entry.Backup == "" {
+		t.Errorf("Неожиданная запись журнала: %+v", rec.Entry)
+	}
+	if !strings.Contains(out.String(), "rich undo --run") {
+		t.Errorf("Ожидалась подсказка отмены:\n%s", out.String())
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+)
+
+// Маркеры разделов для точечного обогащения
+const (
+	SectionStartMarker = "<!-- rich:start -->"
+	SectionEndMarker   = "<!-- rich:end -->"
+)
+
+// Раздел документа, отправляемый на обогащение: [Start, End) - байтовые границы текста раздела
+type docSection struct {
+	Start int
+	End   int
+}
+
+// Текст раздела
+func (s docSection) Text(content string) string {
+	return content[s.Start:s.End]
+}
+
+// Поиск разделов для обогащения: сначала по маркерам, затем по заголовкам из конфигурации
+func findSections(config *Config, content string) []docSection {
+	if sections := findMarkedSections(content); len(sections) > 0 {
+		return sections
+	}
+	return findHeadingSections(content, config.SectionHeadings)
+}
+
+// Поиск разделов между маркерами <!-- rich:start --> и <!-- rich:end -->
+func findMarkedSections(content string) []docSection {
+	var sections []docSection
+	offset := 0
+	for {
+		start := strings.Index(content[offset:], SectionStartMarker)
+		if start < 0 {
+			break
+		}
+		start += offset + len(SectionStartMarker)
+		end := strings.Index(content[start:], SectionEndMarker)
+		if end < 0 {
+			break
+		}
+		end += start
+		if sec, ok := trimSection(content, start, end); ok {
+			sections = append(sections, sec)
+		}
+		offset = end + len(SectionEndMarker)
+	}
+	return sections
+}
+
+// Поиск разделов под заголовками из конфигурации (например "## Summary"):
+// раздел продолжается до следующего заголовка того же или более высокого уровня.
+// Выбранный заголовок внутри другого выбранного раздела отдельным разделом не
+// считается: он обогащается вместе с внешним разделом
+func findHeadingSections(content string, selectors []string) []docSection {
+	if len(selectors) == 0 {
+		return nil
+	}
+
+	type heading struct {
+		level      int
+		text       string
+		lineStart  int
+		bodyStart  int
+		inSelector bool
+	}
+
+	var headings []heading
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		} else if !inFence && isHeadingLine(trimmed) {
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			h := heading{level: level, text: trimmed, lineStart: offset, bodyStart: offset + len(line)}
+			for _, sel := range selectors {
+				if strings.EqualFold(strings.TrimSpace(sel), trimmed) {
+					h.inSelector = true
+				}
+			}
+			headings = append(headings, h)
+		}
+		offset += len(line)
+	}
+
+	var sections []docSection
+	covered := 0
+	for i, h := range headings {
+		if !h.inSelector || h.lineStart < covered {
+			continue
+		}
+		end := len(content)
+		for _, next := range headings[i+1:] {
+			if next.level <= h.level {
+				end = next.lineStart
+				break
+			}
+		}
+		covered = end
+		if sec, ok := trimSection(content, h.bodyStart, end); ok {
+			sections = append(sections, sec)
+		}
+	}
+	return sections
+}
+
+// Исключение пробельных символов по краям раздела, чтобы они сохранились без изменений
+func trimSection(content string, start, end int) (docSection, bool) {
+	text := content[start:end]
+	trimmedLeft := strings.TrimLeft(text, " \t\r\n")
+	start += len(text) - len(trimmedLeft)
+	end = start + len(strings.TrimRight(trimmedLeft, " \t\r\n"))
+	if start >= end {
+		return docSection{}, false
+	}
+	return docSection{Start: start, End: end}, true
+}
+
+// Замена текста разделов обогащенными версиями с сохранением остального документа без изменений
+func spliceSections(content string, sections []docSection, replacements []string) string {
+	var b strings.Builder
+	prev := 0
+	for i, sec := range sections {
+		b.WriteString(content[prev:sec.Start])
+		b.WriteString(replacements[i])
+		prev = sec.End
+	}
+	b.WriteString(content[prev:])
+	return b.String()
+}
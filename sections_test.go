@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindMarkedSections(t *testing.T) {
+	content := "Начало\n<!-- rich:start -->\n  Первый раздел\n<!-- rich:end -->\nСередина\n<!-- rich:start --><!-- rich:end -->\n<!-- rich:start -->Второй<!-- rich:end -->"
+	sections := findMarkedSections(content)
+	if len(sections) != 2 {
+		t.Fatalf("Ожидалось 2 непустых раздела, получено %d", len(sections))
+	}
+	if got := sections[0].Text(content); got != "Первый раздел" {
+		t.Errorf("Некорректный текст первого раздела: %q", got)
+	}
+	if got := sections[1].Text(content); got != "Второй" {
+		t.Errorf("Некорректный текст второго раздела: %q", got)
+	}
+
+	result := spliceSections(content, sections, []string{"ОДИН", "ДВА"})
+	expected := "Начало\n<!-- rich:start -->\n  ОДИН\n<!-- rich:end -->\nСередина\n<!-- rich:start --><!-- rich:end -->\n<!-- rich:start -->ДВА<!-- rich:end -->"
+	if result != expected {
+		t.Errorf("Некорректная замена разделов:\n%q\nожидалось\n%q", result, expected)
+	}
+}
+
+func TestFindHeadingSections(t *testing.T) {
+	content := "# Заголовок\n\nВведение\n\n## Summary\n\nКраткое содержание\n\n### Детали\n\nПодробности\n\n## Другое\n\n```\n## Summary\n```\n"
+	sections := findHeadingSections(content, []string{"## summary"})
+	if len(sections) != 1 {
+		t.Fatalf("Ожидался 1 раздел, получено %d", len(sections))
+	}
+	if got := sections[0].Text(content); got != "Краткое содержание\n\n### Детали\n\nПодробности" {
+		t.Errorf("Раздел должен включать подразделы до следующего заголовка того же уровня: %q", got)
+	}
+
+	if findHeadingSections(content, nil) != nil {
+		t.Error("Без селекторов разделы не должны находиться")
+	}
+
+	// Вложенный выбранный заголовок обогащается в составе внешнего раздела
+	nested := "# Док\n\n## A\n\nТекст A\n\n### B\n\nТекст B\n\n## C\n\n### B\n\nЕще B\n"
+	sections = findHeadingSections(nested, []string{"## A", "### B"})
+	if len(sections) != 2 || sections[0].Text(nested) != "Текст A\n\n### B\n\nТекст B" || sections[1].Text(nested) != "Еще B" {
+		t.Fatalf("Неожиданные разделы с вложенными селекторами: %+v", sections)
+	}
+	result := spliceSections(nested, sections, []string{"A2", "B2"})
+	if result != "# Док\n\n## A\n\nA2\n\n## C\n\n### B\n\nB2\n" {
+		t.Errorf("Некорректная замена вложенных разделов:\n%s", result)
+	}
+}
+
+func TestProcessFileSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "doc.md")
+	outputPath := filepath.Join(tmpDir, "out", "doc.md")
+	original := "# Документ\r\n\r\nТекст, который   нельзя менять.\n<!-- rich:start -->\nКороткий раздел\n<!-- rich:end -->\nХвост\n"
+	if err := os.WriteFile(inputPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.cfg"), []byte("[EXCLUSIONS]\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	var sentContent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sentContent = string(body)
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Расширенный раздел"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{InputDir: tmpDir, ModelAPIURL: server.URL + "/v1/chat/completions", Prompt: "P"}
+	if _, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(10))); err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+
+	if strings.Contains(sentContent, "нельзя менять") {
+		t.Error("В API должен отправляться только размеченный раздел")
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать выходной файл: %v", err)
+	}
+	expected := strings.Replace(original, "Короткий раздел", "Расширенный раздел", 1)
+	if string(data) != expected {
+		t.Errorf("Документ вне разделов должен сохраняться побайтно:\n%q\nожидалось\n%q", data, expected)
+	}
+}
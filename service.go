@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Имя службы по умолчанию (юнит systemd, служба Windows)
+const defaultServiceName = "rich"
+
+// Параметры работы в режиме службы
+type serviceOptions struct {
+	Name       string
+	ConfigPath string
+	// Пауза между запусками обработки входной директории
+	Interval time.Duration
+	PIDFile  string
+	// Рабочая директория: относительно нее разрешаются пути конфигурации и rich.log
+	WorkDir string
+	// Адрес HTTP сервера проверок состояния ("" - выключен)
+	HealthAddr string
+	// Журнал в стандартный вывод вместо rich.log
+	LogStdout bool
+	// Адрес сервера профилирования ("" - выключен) и интервал записи состояния
+	// среды выполнения в журнал (0 - выключена)
+	PprofAddr    string
+	RuntimeStats time.Duration
+	// Поток событий обработки файлов ("" - выключен)
+	StatusStream string
+	// Состояние для проверок /healthz и /readyz
+	health *healthState
+}
+
+// Запрошена остановка службы: новые файлы в обработку не отправляются
+var serviceStopping atomic.Bool
+
+// Управление работающей службой: перечитывание конфигурации и остановка
+type serviceControl struct {
+	reload chan struct{}
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// Создание канала управления службой
+func newServiceControl() *serviceControl {
+	return &serviceControl{reload: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// Запрос на перечитывание конфигурации; повторные запросы до обработки объединяются
+func (c *serviceControl) Reload() {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Запрос на остановку: текущий файл дообрабатывается, новые не отправляются
+func (c *serviceControl) Stop() {
+	c.once.Do(func() {
+		serviceStopping.Store(true)
+		close(c.stop)
+	})
+}
+
+// Цикл службы: обработка входной директории с паузой opts.Interval до остановки.
+// Состояние сообщается через notify в формате sd_notify (READY=1, RELOADING=1,
+// STOPPING=1, STATUS=...)
+func serviceLoop(opts serviceOptions, ctl *serviceControl, notify func(state string)) error {
+	config, err := loadConfig(opts.ConfigPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if opts.health != nil {
+		sdNotify := notify
+		notify = func(state string) {
+			opts.health.Notify(state)
+			sdNotify(state)
+		}
+	}
+	infof("Служба %s запущена: обработка каждые %s", opts.Name, opts.Interval)
+	notify("READY=1")
+
+	for {
+		// Без соединения с API следующий запуск выполняется через offline_retry, если
+		// это раньше обычного интервала
+		wait := opts.Interval
+		var oe *offlineError
+		if err := processDirectory(config, opts.ConfigPath); errors.As(err, &oe) {
+			wait = min(wait, config.Offline.Retry)
+			warnf("Предупреждение: %v; повтор через %s", err, wait)
+		} else if err != nil {
+			logErrorf("Ошибка обработки директории: %v", err)
+		}
+		serviceWeeklyRollup(config, time.Now())
+		notify("STATUS=" + trf("Следующий запуск в %s", time.Now().Add(wait).Format(time.TimeOnly)))
+
+		select {
+		case <-time.After(wait):
+		case <-ctl.reload:
+			notify("RELOADING=1")
+			if reloaded, err := loadConfig(opts.ConfigPath); err != nil {
+				warnf("Предупреждение: конфигурация не перечитана, используется прежняя: %v", err)
+			} else {
+				config = reloaded
+				infof("Конфигурация перечитана из %s", opts.ConfigPath)
+			}
+			notify("READY=1")
+		case <-ctl.stop:
+		}
+
+		if serviceStopping.Load() {
+			notify("STOPPING=1")
+			infof("Служба %s остановлена", opts.Name)
+			return nil
+		}
+	}
+}
+
+// Запись PID файла; файл живого процесса не перезаписывается
+func writePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid != os.Getpid() && processAlive(pid) {
+			return errorf("процесс %d из PID файла %s еще работает", pid, path)
+		}
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return errorf("не удалось записать PID файл: %v", err)
+	}
+	return nil
+}
+
+// Удаление PID файла при остановке
+func removePIDFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		warnf("Предупреждение: не удалось удалить PID файл: %v", err)
+	}
+}
+
+// Юнит systemd для запуска службы: Type=notify, перечитывание конфигурации
+// через systemctl reload (SIGHUP)
+func systemdUnit(exe string, opts serviceOptions) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=rich markdown enrichment\n")
+	b.WriteString("After=network-online.target\nWants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=notify\n")
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", opts.WorkDir)
+	fmt.Fprintf(&b, "ExecStart=%s service run --config %s --interval %s\n", exe, opts.ConfigPath, opts.Interval)
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\n")
+	// Текущий файл дообрабатывается после SIGTERM
+	b.WriteString("TimeoutStopSec=300\n\n")
+	b.WriteString("[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// Разбор параметров службы; пути приводятся к абсолютным, так как менеджер служб
+// запускает процесс в своей рабочей директории
+func parseServiceOptions(name string, args []string) (serviceOptions, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", envString("RICH_CONFIG", "rich.cfg"), tr("Путь к файлу конфигурации"))
+	interval := fs.Duration("interval", 15*time.Minute, tr("Пауза между запусками обработки"))
+	pidFile := fs.String("pid-file", "", tr("Путь к PID файлу"))
+	workDir := fs.String("workdir", "", tr("Рабочая директория службы (по умолчанию - текущая)"))
+	serviceName := fs.String("name", defaultServiceName, tr("Имя службы"))
+	healthAddr := fs.String("health-addr", os.Getenv("RICH_HEALTH_ADDR"), tr("Адрес HTTP сервера проверок состояния /healthz и /readyz (например, :8080)"))
+	logStdout := fs.Bool("log-stdout", envBool("RICH_LOG_STDOUT"), tr("Писать журнал в стандартный вывод вместо rich.log"))
+	pprofAddr := fs.String("pprof", "", tr("Адрес HTTP сервера профилирования /debug/pprof/ (например, localhost:6060)"))
+	runtimeStats := fs.Duration("runtime-stats", 0, tr("Интервал записи в журнал количества горутин и размера кучи (0 - выключено)"))
+	statusStream := fs.String("status-stream", "", tr("Поток событий обработки файлов в формате NDJSON: stdout, stderr, fd:N или путь файла"))
+	if err := fs.Parse(args); err != nil {
+		return serviceOptions{}, err
+	}
+	if *interval <= 0 {
+		return serviceOptions{}, errorf("пауза между запусками должна быть положительной: %s", *interval)
+	}
+	opts := serviceOptions{Name: *serviceName, Interval: *interval, PIDFile: *pidFile, WorkDir: *workDir,
+		HealthAddr: *healthAddr, LogStdout: *logStdout, PprofAddr: *pprofAddr, RuntimeStats: *runtimeStats,
+		StatusStream: *statusStream}
+	if opts.WorkDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return opts, errorf("не удалось определить рабочую директорию: %v", err)
+		}
+		opts.WorkDir = wd
+	}
+	var err error
+	if opts.WorkDir, err = filepath.Abs(opts.WorkDir); err != nil {
+		return opts, errorf("не удалось определить рабочую директорию: %v", err)
+	}
+	opts.ConfigPath = *configPath
+	if !filepath.IsAbs(opts.ConfigPath) {
+		opts.ConfigPath = filepath.Join(opts.WorkDir, opts.ConfigPath)
+	}
+	if opts.PIDFile != "" && !filepath.IsAbs(opts.PIDFile) {
+		opts.PIDFile = filepath.Join(opts.WorkDir, opts.PIDFile)
+	}
+	return opts, nil
+}
+
+// Работа в режиме службы до остановки
+func runServiceMode(opts serviceOptions) error {
+	if err := os.Chdir(opts.WorkDir); err != nil {
+		return errorf("не удалось перейти в рабочую директорию: %v", err)
+	}
+	closeLog, err := setupLogging(true, opts.LogStdout)
+	if err != nil {
+		return errorf("не удалось открыть файл журнала: %v", err)
+	}
+	defer closeLog()
+
+	if opts.HealthAddr != "" {
+		opts.health = &healthState{}
+		stopHealth, err := startHealthServer(opts.HealthAddr, opts.health)
+		if err != nil {
+			return err
+		}
+		defer stopHealth()
+	}
+
+	if opts.PprofAddr != "" {
+		stopPprof, err := startPprofServer(opts.PprofAddr)
+		if err != nil {
+			return err
+		}
+		defer stopPprof()
+	}
+	if opts.RuntimeStats > 0 {
+		defer startRuntimeStats(opts.RuntimeStats)()
+	}
+
+	if opts.StatusStream != "" {
+		stream, err := openStatusStream(opts.StatusStream)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		stream.claimStdout(true)
+		statusEvents = stream
+	}
+
+	if opts.PIDFile != "" {
+		if err := writePIDFile(opts.PIDFile); err != nil {
+			return err
+		}
+		defer removePIDFile(opts.PIDFile)
+	}
+	return runService(opts)
+}
+
+// rich service run|install|uninstall: работа по расписанию под управлением systemd
+// или диспетчера служб Windows
+func runServiceCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errorf("укажите действие: rich service run|install|uninstall")
+	}
+	action := args[0]
+	opts, err := parseServiceOptions("service "+action, args[1:])
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "run":
+		return runServiceMode(opts)
+	case "install":
+		return installService(opts, out)
+	case "uninstall":
+		return uninstallService(opts, out)
+	}
+	return errorf("неизвестное действие %q: rich service run|install|uninstall", action)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceLoop(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(tmpDir, "output") +
+		"\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") +
+		"\n[REPORT]\nfile = " + filepath.Join(tmpDir, "rich.report.json") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+	t.Cleanup(func() { serviceStopping.Store(false) })
+
+	states := make(chan string, 20)
+	ctl := newServiceControl()
+	done := make(chan error, 1)
+	opts := serviceOptions{Name: "rich", ConfigPath: configPath, Interval: time.Hour}
+	go func() { done <- serviceLoop(opts, ctl, func(state string) { states <- state }) }()
+
+	expect := func(prefix string) {
+		t.Helper()
+		select {
+		case state := <-states:
+			if !strings.HasPrefix(state, prefix) {
+				t.Fatalf("Ожидалось состояние %s, получено %q", prefix, state)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Не дождались состояния %s", prefix)
+		}
+	}
+	expect("READY=1")
+	expect("STATUS=")
+
+	ctl.Reload()
+	expect("RELOADING=1")
+	expect("READY=1")
+	expect("STATUS=")
+
+	ctl.Stop()
+	expect("STOPPING=1")
+	if err := <-done; err != nil {
+		t.Errorf("serviceLoop() вернул ошибку: %v", err)
+	}
+}
+
+func TestProcessDirectoryStopsWhenServiceStopping(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать входную директорию: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Заметка"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	serviceStopping.Store(true)
+	t.Cleanup(func() { serviceStopping.Store(false) })
+	config := &Config{InputDir: inputDir, OutputDir: filepath.Join(tmpDir, "output"), ModelAPIURL: server.URL + "/v1/chat/completions"}
+	if err := processDirectory(config, filepath.Join(tmpDir, "test.cfg")); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("При остановке службы новые файлы не должны отправляться, запросов: %d", requests)
+	}
+}
+
+func TestWritePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rich.pid")
+
+	// Файл завершившегося процесса перезаписывается
+	if err := os.WriteFile(path, []byte("999999999\n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать PID файл: %v", err)
+	}
+	if err := writePIDFile(path); err != nil {
+		t.Fatalf("writePIDFile() вернул ошибку: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("В PID файле %q, ожидался %d", data, os.Getpid())
+	}
+
+	removePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("PID файл не удален: %v", err)
+	}
+}
+
+func TestParseServiceOptions(t *testing.T) {
+	workDir := t.TempDir()
+	opts, err := parseServiceOptions("service run", []string{"-workdir", workDir, "-interval", "30m", "-pid-file", "rich.pid"})
+	if err != nil {
+		t.Fatalf("parseServiceOptions() вернул ошибку: %v", err)
+	}
+	if opts.ConfigPath != filepath.Join(workDir, "rich.cfg") || opts.PIDFile != filepath.Join(workDir, "rich.pid") {
+		t.Errorf("Пути должны быть абсолютными относительно рабочей директории: %+v", opts)
+	}
+	if opts.Interval != 30*time.Minute || opts.Name != defaultServiceName {
+		t.Errorf("Некорректные параметры службы: %+v", opts)
+	}
+	if _, err := parseServiceOptions("service run", []string{"-interval", "0s"}); err == nil {
+		t.Error("Ожидалась ошибка для нулевой паузы между запусками")
+	}
+
+	unit := systemdUnit("/usr/local/bin/rich", opts)
+	for _, want := range []string{"Type=notify", "WorkingDirectory=" + workDir, "service run --config " + opts.ConfigPath + " --interval 30m0s", "ExecReload=/bin/kill -HUP $MAINPID"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("В юните systemd нет %q:\n%s", want, unit)
+		}
+	}
+}
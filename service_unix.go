@@ -0,0 +1,108 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Уведомление systemd о состоянии службы (протокол sd_notify); без NOTIFY_SOCKET
+// (запуск не из systemd или Type=simple) ничего не делает
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Абстрактный сокет Linux задается с префиксом @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return errorf("не удалось подключиться к сокету systemd: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return errorf("не удалось уведомить systemd: %v", err)
+	}
+	return nil
+}
+
+// Интервал сторожевого таймера systemd (WatchdogSec); 0 - таймер не включен
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Проверка, что процесс с указанным PID существует
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Работа службы: SIGHUP перечитывает конфигурацию, SIGTERM и SIGINT останавливают
+// службу после текущего файла
+func runService(opts serviceOptions) error {
+	ctl := newServiceControl()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigs)
+	go func() {
+		for sig := range sigs {
+			logf("Получен сигнал %v", sig)
+			if sig == syscall.SIGHUP {
+				ctl.Reload()
+			} else {
+				ctl.Stop()
+			}
+		}
+	}()
+
+	if interval := sdWatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				if err := sdNotify("WATCHDOG=1"); err != nil {
+					logf("Предупреждение: %v", err)
+				}
+			}
+		}()
+	}
+
+	return serviceLoop(opts, ctl, func(state string) {
+		if err := sdNotify(state); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	})
+}
+
+// Вывод юнита systemd; установка выполняется администратором
+func installService(opts serviceOptions, out io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return errorf("не удалось определить путь к программе: %v", err)
+	}
+	fmt.Fprint(out, systemdUnit(exe, opts))
+	fmt.Fprintf(out, tr("\n# Сохраните юнит в /etc/systemd/system/%s.service и выполните:\n#   systemctl daemon-reload && systemctl enable --now %s\n"), opts.Name, opts.Name)
+	return nil
+}
+
+// Подсказка по удалению юнита systemd
+func uninstallService(opts serviceOptions, out io.Writer) error {
+	fmt.Fprintf(out, tr("Выполните: systemctl disable --now %s && rm /etc/systemd/system/%s.service && systemctl daemon-reload\n"), opts.Name, opts.Name)
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	// Без NOTIFY_SOCKET уведомление не отправляется
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify() без сокета вернул ошибку: %v", err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram сокеты недоступны: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify() вернул ошибку: %v", err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Уведомление не получено: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("Получено уведомление %q, ожидалось READY=1", got)
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := sdWatchdogInterval(); got != 30*time.Second {
+		t.Errorf("sdWatchdogInterval() = %v, ожидалось 30s", got)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := sdWatchdogInterval(); got != 0 {
+		t.Errorf("Сторожевой таймер другого процесса должен игнорироваться, получено %v", got)
+	}
+}
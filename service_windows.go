@@ -0,0 +1,207 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Функции диспетчера служб Windows (advapi32)
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+// Константы SERVICE_* из winsvc.h
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+	serviceControlParamChange = 6
+
+	serviceAcceptStop        = 1
+	serviceAcceptShutdown    = 4
+	serviceAcceptParamChange = 8
+
+	errorServiceSpecificError = 1066
+)
+
+// SERVICE_STATUS
+type windowsServiceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// Состояние службы, запущенной диспетчером: функции обратного вызова не принимают
+// контекст, поэтому оно хранится в переменной пакета
+var winService struct {
+	mu     sync.Mutex
+	opts   serviceOptions
+	ctl    *serviceControl
+	handle uintptr
+	status windowsServiceStatus
+	err    error
+}
+
+// Сообщение диспетчеру служб о новом состоянии
+func setServiceState(state uint32, exitErr error) {
+	winService.mu.Lock()
+	defer winService.mu.Unlock()
+	s := &winService.status
+	s.ServiceType = serviceWin32OwnProcess
+	s.CurrentState = state
+	s.ControlsAccepted = 0
+	if state == serviceRunning {
+		s.ControlsAccepted = serviceAcceptStop | serviceAcceptShutdown | serviceAcceptParamChange
+	}
+	s.Win32ExitCode, s.ServiceSpecificExitCode = 0, 0
+	if exitErr != nil {
+		s.Win32ExitCode, s.ServiceSpecificExitCode = errorServiceSpecificError, 1
+	}
+	s.CheckPoint = 0
+	if state == serviceStartPending || state == serviceStopPending {
+		s.CheckPoint = 1
+		s.WaitHint = 300000
+	}
+	if r, _, err := procSetServiceStatus.Call(winService.handle, uintptr(unsafe.Pointer(s))); r == 0 {
+		logf("Предупреждение: не удалось сообщить состояние службы: %v", err)
+	}
+}
+
+// Обработчик команд диспетчера: остановка, завершение работы системы и
+// перечитывание конфигурации (sc control <имя> paramchange)
+func serviceCtrlHandler(ctl, eventType, eventData, context uintptr) uintptr {
+	switch ctl {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceState(serviceStopPending, nil)
+		winService.ctl.Stop()
+	case serviceControlParamChange:
+		winService.ctl.Reload()
+	case serviceControlInterrogate:
+		winService.mu.Lock()
+		state := winService.status.CurrentState
+		winService.mu.Unlock()
+		setServiceState(state, nil)
+	}
+	return 0
+}
+
+// Точка входа службы, вызываемая диспетчером в отдельном потоке
+func serviceMain(argc, argv uintptr) uintptr {
+	name, _ := syscall.UTF16PtrFromString(winService.opts.Name)
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(name)), syscall.NewCallback(serviceCtrlHandler), 0)
+	if handle == 0 {
+		winService.err = errorf("не удалось зарегистрировать обработчик службы: %v", err)
+		return 0
+	}
+	winService.handle = handle
+	setServiceState(serviceStartPending, nil)
+
+	winService.err = serviceLoop(winService.opts, winService.ctl, func(state string) {
+		switch state {
+		case "READY=1":
+			setServiceState(serviceRunning, nil)
+		case "STOPPING=1":
+			setServiceState(serviceStopPending, nil)
+		}
+	})
+	setServiceState(serviceStopped, winService.err)
+	return 0
+}
+
+// Проверка, что процесс с указанным PID существует
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// Работа под управлением диспетчера служб Windows; вызов блокируется до остановки службы
+func runService(opts serviceOptions) error {
+	winService.opts = opts
+	winService.ctl = newServiceControl()
+	name, err := syscall.UTF16PtrFromString(opts.Name)
+	if err != nil {
+		return errorf("некорректное имя службы: %v", err)
+	}
+	table := []serviceTableEntry{{name: name, proc: syscall.NewCallback(serviceMain)}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return errorf("не удалось подключиться к диспетчеру служб Windows (rich service run запускается диспетчером, см. rich service install): %v", err)
+	}
+	return winService.err
+}
+
+// Выполнение sc.exe с выводом результата
+func runSC(out io.Writer, args ...string) error {
+	output, err := exec.Command("sc.exe", args...).CombinedOutput()
+	fmt.Fprint(out, string(output))
+	if err != nil {
+		return errorf("sc.exe %s: %v", args[0], err)
+	}
+	return nil
+}
+
+// Регистрация службы Windows с автоматическим запуском (требуются права администратора)
+func installService(opts serviceOptions, out io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return errorf("не удалось определить путь к программе: %v", err)
+	}
+	args := []string{exe, "service", "run", "--name", opts.Name, "--config", opts.ConfigPath,
+		"--interval", opts.Interval.String(), "--workdir", opts.WorkDir}
+	if opts.PIDFile != "" {
+		args = append(args, "--pid-file", opts.PIDFile)
+	}
+	for i, a := range args {
+		args[i] = syscall.EscapeArg(a)
+	}
+	if err := runSC(out, "create", opts.Name, "binPath=", strings.Join(args, " "), "start=", "auto", "DisplayName=", "rich"); err != nil {
+		return err
+	}
+	if err := runSC(out, "description", opts.Name, "rich markdown enrichment"); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Служба %s зарегистрирована: sc start %s - запуск, sc control %s paramchange - перечитать конфигурацию\n"), opts.Name, opts.Name, opts.Name)
+	return nil
+}
+
+// Остановка и удаление службы Windows
+func uninstallService(opts serviceOptions, out io.Writer) error {
+	// Служба может быть уже остановлена: ошибка остановки не мешает удалению
+	_ = runSC(out, "stop", opts.Name)
+	if err := runSC(out, "delete", opts.Name); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Служба %s удалена\n"), opts.Name)
+	return nil
+}
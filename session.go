@@ -0,0 +1,54 @@
+package main
+
+// Общие ресурсы одного запуска обработки, разделяемые между файлами
+type session struct {
+	// Ограничитель частоты запросов к API
+	limiter *RateLimiter
+	// Индекс фрагментов из директории контекста (nil, если context_dir не задан)
+	contextIndex *contextIndex
+	// Разрешение ссылок на связанные документы (nil, если linked_docs выключен)
+	links *linkResolver
+	// Проверка ссылок обогащенных документов (nil, если проверка выключена)
+	linkChecker *linkChecker
+	// Идентификатор и журнал запуска (nil, если каталог состояния не задан)
+	runID   string
+	journal *runJournal
+	// Поддиректория результатов запуска при output_layout = per-run ("" - без нее)
+	runDir string
+	// Пакетная обработка маленьких файлов (nil, если выключена)
+	batcher *batcher
+	// Почти одинаковые документы по относительным путям копий (nil, если проверка выключена)
+	duplicates map[string]duplicate
+	// Закрепление файлов за экземпляром через Redis (nil, если файлы не распределяются)
+	claims *workClaims
+	// Транзакция запуска с --transactional (nil - результаты записываются сразу)
+	txn *transaction
+	// Приемники результатов помимо выходной директории
+	sinks *sinkSet
+	// Выходные пути, занятые по шаблонам output маршрутов
+	routeOutputs *routeOutputs
+	// Соответствия заголовков и имен результатов (nil, если [TITLES] выключен)
+	titles *titleMap
+	// Дневниковые заметки корня (nil, если [DAILY_NOTES] выключен)
+	dailyNotes *dailyNoteIndex
+	// Векторы документов корпуса для ссылок на связанные заметки (nil, если [RELATED] выключен)
+	related []relatedDoc
+	// Проверка адресов источников (nil, если [CITATIONS] выключен)
+	citations *citationVerifier
+	// Изменения документов для общего файла изменений (nil, если файл не ведется)
+	changes *runChangelog
+	// Последние результаты по журналам прошлых запусков для обнаружения ручных правок
+	// (nil, если каталог состояния не задан)
+	recorded map[string]outputRecord
+	// Итоги файлов для продолжения прерванного запуска (nil, если каталог состояния не задан)
+	manifest *stateManifest
+	// Статистика запуска по директориям (nil, если каталог состояния не задан)
+	dirStats *dirStats
+	// Назначение вариантов промпта (nil, если [EXPERIMENT] выключен)
+	experiment *promptExperiment
+}
+
+// Создание сессии обработки с заданным ограничителем частоты запросов
+func newSession(limiter *RateLimiter) *session {
+	return &session{limiter: limiter, routeOutputs: &routeOutputs{}}
+}
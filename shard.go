@@ -0,0 +1,49 @@
+package main
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Часть файлов для обработки на одной из нескольких машин (-shard K/N)
+type shardSpec struct {
+	// Номер части от 1 до Count
+	Index int
+	// Число частей (0 - файлы не делятся)
+	Count int
+}
+
+// Разбор -shard в формате K/N ("" - без деления)
+func parseShard(value string) (shardSpec, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return shardSpec{}, nil
+	}
+	k, n, ok := strings.Cut(value, "/")
+	index, kerr := strconv.Atoi(strings.TrimSpace(k))
+	count, nerr := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || kerr != nil || nerr != nil || count < 1 || index < 1 || index > count {
+		return shardSpec{}, errorf("некорректная часть %q: ожидается K/N, где 1 <= K <= N", value)
+	}
+	return shardSpec{Index: index, Count: count}, nil
+}
+
+// Файл относится к этой части. Часть определяется хэшем пути в общем состоянии с
+// прямыми слешами, поэтому совпадает на всех машинах и платформах и не зависит от
+// того, какие файлы уже обработаны
+func (s shardSpec) Contains(key string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(normalizeRelPath(key)))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+func (s shardSpec) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    shardSpec
+		wantErr bool
+	}{
+		{"", shardSpec{}, false},
+		{"2/5", shardSpec{Index: 2, Count: 5}, false},
+		{" 1 / 1 ", shardSpec{Index: 1, Count: 1}, false},
+		{"0/5", shardSpec{}, true},
+		{"6/5", shardSpec{}, true},
+		{"2", shardSpec{}, true},
+		{"a/b", shardSpec{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseShard(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseShard(%q) ошибка = %v, ожидалась ошибка: %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseShard(%q) = %+v, ожидалось %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// Каждый файл попадает ровно в одну часть, независимо от разделителей пути
+func TestShardContains(t *testing.T) {
+	counts := make([]int, 5)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("notes/%03d.md", i)
+		parts := 0
+		for k := 1; k <= 5; k++ {
+			s := shardSpec{Index: k, Count: 5}
+			if s.Contains(key) {
+				parts++
+				counts[k-1]++
+				if !s.Contains(strings.ReplaceAll(key, "/", `\`)) {
+					t.Errorf("Часть файла %s не должна зависеть от разделителей", key)
+				}
+			}
+		}
+		if parts != 1 {
+			t.Fatalf("Файл %s попал в %d частей", key, parts)
+		}
+	}
+	for k, n := range counts {
+		if n < 100 {
+			t.Errorf("Часть %d/5 слишком мала: %d файлов из 1000", k+1, n)
+		}
+	}
+	if !(shardSpec{}).Contains("a.md") {
+		t.Error("Без деления файл должен обрабатываться")
+	}
+}
+
+// Две машины обрабатывают непересекающиеся части, состояние объединяется
+func TestShardRunAndMerge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var all []string
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("note%02d.md", i)
+		all = append(all, name)
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка\n\nТекст заметки"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var configs []string
+	for k := 1; k <= 2; k++ {
+		work := filepath.Join(tmpDir, fmt.Sprintf("machine%d", k))
+		if err := os.MkdirAll(work, 0755); err != nil {
+			t.Fatal(err)
+		}
+		configPath := filepath.Join(work, "rich.cfg")
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(work, "output") +
+			"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n" +
+			"[STATE]\ndir = " + filepath.Join(work, ".rich") + "\n"
+		if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.ReportFile = ""
+		config.Shard = shardSpec{Index: k, Count: 2}
+		if err := processDirectory(config, configPath); err != nil {
+			t.Fatalf("processDirectory() части %d вернул ошибку: %v", k, err)
+		}
+		configs = append(configs, configPath)
+	}
+
+	first, _ := loadConfig(configs[0])
+	second, _ := loadConfig(configs[1])
+	for _, file := range first.ExcludedFiles {
+		if isExcluded(second, file) {
+			t.Errorf("Файл %s обработан обеими машинами", file)
+		}
+	}
+	if len(first.ExcludedFiles)+len(second.ExcludedFiles) != len(all) {
+		t.Fatalf("Части должны покрывать все файлы: %v и %v", first.ExcludedFiles, second.ExcludedFiles)
+	}
+
+	// Слияние состояния второй машины с первой
+	src, err := loadForeignState(configs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := mergeState(first, configs[0], []*foreignState{src}, PreferNewer)
+	if err != nil {
+		t.Fatalf("mergeState() вернул ошибку: %v", err)
+	}
+	if summary.Excluded != len(second.ExcludedFiles) || summary.States != len(second.ExcludedFiles) || summary.Runs != 1 {
+		t.Errorf("Неожиданные итоги слияния: %+v", summary)
+	}
+	merged, _ := loadConfig(configs[0])
+	got := append([]string(nil), merged.ExcludedFiles...)
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(all, ",") {
+		t.Errorf("После слияния в excluded_files должны быть все файлы: %v", got)
+	}
+	manifest, _ := loadStateManifest(first.StateDir)
+	if len(manifest.Files) != len(all) {
+		t.Errorf("После слияния в файле состояния должны быть все файлы: %d", len(manifest.Files))
+	}
+	runs, _ := listRuns(first.StateDir)
+	if len(runs) != 2 {
+		t.Errorf("После слияния ожидались журналы двух запусков: %d", len(runs))
+	}
+	// Повторное слияние ничего не меняет
+	if summary, err = mergeState(merged, configs[0], []*foreignState{src}, PreferNewer); err != nil || summary != (mergeSummary{}) {
+		t.Errorf("Повторное слияние не должно ничего менять: %+v, %v", summary, err)
+	}
+}
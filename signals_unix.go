@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Подписка на сигналы управления паузой: SIGUSR1 приостанавливает, SIGUSR2 возобновляет
+func watchPauseSignals(gate *pauseGate) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				logf("Получен сигнал %v", sig)
+				if sig == syscall.SIGUSR1 {
+					gate.Pause()
+				} else {
+					gate.Resume()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
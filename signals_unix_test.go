@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchPauseSignals(t *testing.T) {
+	gate := newPauseGate()
+	stop := watchPauseSignals(gate)
+	defer stop()
+
+	waitFor := func(want bool) {
+		deadline := time.Now().Add(time.Second)
+		for gate.Paused() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Ожидалось состояние паузы %v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Не удалось отправить SIGUSR1: %v", err)
+	}
+	waitFor(true)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatalf("Не удалось отправить SIGUSR2: %v", err)
+	}
+	waitFor(false)
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// На Windows сигналы SIGUSR1/SIGUSR2 недоступны, поэтому управление паузой не поддерживается
+func watchPauseSignals(gate *pauseGate) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Приемники результатов (sinks секции [OUTPUT])
+const (
+	// Выходная директория корня (по умолчанию): резервные копии, конфликты с ручными
+	// правками и транзакции работают только с ней
+	SinkLocal = "local"
+	// Стандартный вывод; журнал и консоль переносятся в stderr
+	SinkStdout = "stdout"
+	// Коммит записанных результатов в репозиторий выходной директории в конце запуска
+	SinkGit = "git"
+	// Объектное хранилище S3 и совместимые с ним (MinIO, Yandex Object Storage)
+	SinkS3 = "s3"
+	// Команда, которая запускается для каждого результата
+	SinkCommand = "command"
+)
+
+// Сообщение коммита приемника git по умолчанию
+const defaultGitSinkMessage = "rich: обогащение документов"
+
+// Параметры приемников результатов ([OUTPUT])
+type sinksConfig struct {
+	// Приемники в порядке публикации
+	Sinks []string
+	// Сообщение коммита и отправка коммита в удаленный репозиторий (git push)
+	GitMessage string
+	GitPush    bool
+	// Бакет и префикс ключей приемника s3; подключение - в секции [S3]
+	S3Bucket string
+	S3Prefix string
+	// Команда приемника command и время ожидания ее завершения
+	Command        string
+	CommandTimeout time.Duration
+}
+
+// Чтение секции [OUTPUT]
+func loadSinksConfig(section *ini.Section) (sinksConfig, error) {
+	sc := sinksConfig{
+		GitMessage:     section.Key("git_message").MustString(defaultGitSinkMessage),
+		GitPush:        section.Key("git_push").MustBool(false),
+		S3Bucket:       strings.TrimSpace(section.Key("s3_bucket").String()),
+		S3Prefix:       strings.Trim(section.Key("s3_prefix").String(), "/ "),
+		Command:        strings.TrimSpace(section.Key("command").String()),
+		CommandTimeout: section.Key("command_timeout").MustDuration(time.Minute),
+	}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(section.Key("sinks").MustString(SinkLocal), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		switch name {
+		case SinkLocal, SinkStdout, SinkGit, SinkS3, SinkCommand:
+		default:
+			return sc, errorf("неизвестный приемник результатов %q в секции [OUTPUT]: поддерживаются local, stdout, git, s3, command", name)
+		}
+		seen[name] = true
+		sc.Sinks = append(sc.Sinks, name)
+	}
+	if len(sc.Sinks) == 0 {
+		return sc, errorf("в секции [OUTPUT] не задано ни одного приемника результатов")
+	}
+	if seen[SinkGit] && !seen[SinkLocal] {
+		return sc, errorf("приемник git коммитит файлы выходной директории и требует приемника local")
+	}
+	if seen[SinkS3] && sc.S3Bucket == "" {
+		return sc, errorf("для приемника s3 задайте s3_bucket в секции [OUTPUT]")
+	}
+	if seen[SinkCommand] && sc.Command == "" {
+		return sc, errorf("для приемника command задайте command в секции [OUTPUT]")
+	}
+	if sc.CommandTimeout <= 0 {
+		return sc, errorf("command_timeout в секции [OUTPUT] должен быть положительным: %v", sc.CommandTimeout)
+	}
+	return sc, nil
+}
+
+// Приемник выбран в конфигурации
+func (sc sinksConfig) Has(name string) bool {
+	for _, s := range sc.Sinks {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Результаты записываются в выходную директорию; без конфигурации [OUTPUT]
+// (конфигурация собрана в коде) - тоже
+func (sc sinksConfig) WritesLocal() bool {
+	return len(sc.Sinks) == 0 || sc.Has(SinkLocal)
+}
+
+// Записанный результат, который передается приемникам
+type sinkOutput struct {
+	// Ключ исходного файла в списке исключений
+	Key string
+	// Путь результата относительно выходной директории с прямыми слешами
+	RelPath string
+	// Абсолютный путь выходной директории корня
+	OutputDir string
+	Content   []byte
+	// Файлы, записанные в выходную директорию (результат и карточки); пусто без local
+	Written []string
+	// Сведения об исходном файле
+	File fileContext
+}
+
+// Приемник обогащенных результатов помимо выходной директории: Publish вызывается
+// для каждого сохраненного результата, Finish - один раз после записи всех
+// результатов запуска (для транзакционного запуска - после фиксации)
+type OutputSink interface {
+	Name() string
+	Publish(out sinkOutput) error
+	Finish(runID string) error
+}
+
+// Приемники запуска; результаты транзакционного запуска копятся до фиксации
+type sinkSet struct {
+	sinks []OutputSink
+	mu    sync.Mutex
+	// Результаты, ожидающие фиксации транзакции (deferred = true)
+	deferred bool
+	pending  []sinkOutput
+}
+
+// Приемники по конфигурации; local выполняется самой записью результата
+func newSinkSet(config *Config, stdout io.Writer) (*sinkSet, error) {
+	set := &sinkSet{deferred: config.Transactional}
+	for _, name := range config.Sinks.Sinks {
+		switch name {
+		case SinkStdout:
+			set.sinks = append(set.sinks, &stdoutSink{out: stdout})
+		case SinkGit:
+			sink, err := newGitSink(config.Sinks, config.Operator)
+			if err != nil {
+				return nil, err
+			}
+			set.sinks = append(set.sinks, sink)
+		case SinkS3:
+			if err := config.S3.check(); err != nil {
+				return nil, err
+			}
+			set.sinks = append(set.sinks, &s3Sink{s3: config.S3, bucket: config.Sinks.S3Bucket, prefix: config.Sinks.S3Prefix,
+				client: config.Network.client(0)})
+		case SinkCommand:
+			set.sinks = append(set.sinks, &commandSink{command: config.Sinks.Command, timeout: config.Sinks.CommandTimeout})
+		}
+	}
+	return set, nil
+}
+
+// Передача результата приемникам; ошибки всех приемников объединяются
+func (s *sinkSet) Publish(out sinkOutput) error {
+	if s == nil || len(s.sinks) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deferred {
+		s.pending = append(s.pending, out)
+		return nil
+	}
+	return s.publish(out)
+}
+
+func (s *sinkSet) publish(out sinkOutput) error {
+	var failed []string
+	for _, sink := range s.sinks {
+		if err := sink.Publish(out); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return errorf("не удалось передать %s приемникам результатов: %s", out.RelPath, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Завершение запуска: результаты, отложенные до фиксации транзакции, передаются
+// приемникам (committed = false - транзакция отменена и они отбрасываются),
+// затем приемники завершают запуск. Ошибки выводятся предупреждениями: результаты
+// уже сохранены
+func (s *sinkSet) Finish(runID string, committed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	pending := s.pending
+	s.pending, s.deferred = nil, false
+	s.mu.Unlock()
+	if !committed {
+		return
+	}
+	for _, out := range pending {
+		if err := s.publish(out); err != nil {
+			warnf("Предупреждение: %v", err)
+		}
+	}
+	for _, sink := range s.sinks {
+		if err := sink.Finish(runID); err != nil {
+			warnf("Предупреждение: приемник результатов %s: %v", sink.Name(), err)
+		}
+	}
+}
+
+// Приемник stdout: результаты подряд, перед каждым - строка с путем, как у head
+type stdoutSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (s *stdoutSink) Name() string { return SinkStdout }
+
+func (s *stdoutSink) Publish(out sinkOutput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content := strings.TrimRight(string(out.Content), "\n")
+	_, err := fmt.Fprintf(s.out, "==> %s <==\n%s\n\n", out.RelPath, content)
+	return err
+}
+
+func (s *stdoutSink) Finish(string) error { return nil }
+
+// Приемник git: файлы, записанные за запуск, коммитятся одним коммитом в каждом
+// репозитории выходных директорий; чужие изменения в индексе в коммит не попадают
+type gitSink struct {
+	binary  string
+	message string
+	push    bool
+	// Оператор запуска для строки Operator: коммита ("" - строка не добавляется)
+	operator string
+	mu       sync.Mutex
+	// Записанные файлы по выходным директориям
+	files map[string][]string
+}
+
+func newGitSink(sc sinksConfig, operator string) (*gitSink, error) {
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errorf("приемник git: программа git не найдена: %v", err)
+	}
+	return &gitSink{binary: binary, message: sc.GitMessage, push: sc.GitPush, operator: operator, files: make(map[string][]string)}, nil
+}
+
+func (g *gitSink) Name() string { return SinkGit }
+
+func (g *gitSink) Publish(out sinkOutput) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, file := range out.Written {
+		if rel, err := filepath.Rel(out.OutputDir, file); err == nil && isRelPathSafe(rel) {
+			g.files[out.OutputDir] = append(g.files[out.OutputDir], filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// Коммит в каждой выходной директории; ошибка одной директории не мешает остальным
+func (g *gitSink) Finish(runID string) error {
+	g.mu.Lock()
+	files := g.files
+	g.files = make(map[string][]string)
+	g.mu.Unlock()
+
+	dirs := make([]string, 0, len(files))
+	for dir := range files {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var failed []string
+	for _, dir := range dirs {
+		if err := g.commit(dir, files[dir], runID); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", dir, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (g *gitSink) commit(dir string, files []string, runID string) error {
+	sort.Strings(files)
+	if _, err := g.run(dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return errorf("выходная директория не находится в репозитории git: %v", err)
+	}
+	if _, err := g.run(dir, append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	// Результаты, совпавшие с уже закоммиченными, коммит не создают
+	if _, err := g.run(dir, append([]string{"diff", "--cached", "--quiet", "--"}, files...)...); err == nil {
+		logf("Приемник git: изменений в %s нет, коммит не создан", dir)
+		return nil
+	}
+
+	message := g.message + "\n\n"
+	for _, file := range files {
+		message += "- " + file + "\n"
+	}
+	if runID != "" {
+		message += "\nRun: " + runID + "\n"
+	}
+	if g.operator != "" {
+		if runID == "" {
+			message += "\n"
+		}
+		message += "Operator: " + g.operator + "\n"
+	}
+	if _, err := g.run(dir, append([]string{"commit", "--quiet", "-m", message, "--"}, files...)...); err != nil {
+		return err
+	}
+	infof("Приемник git: закоммичено файлов %d в %s", len(files), dir)
+	if g.push {
+		if _, err := g.run(dir, "push", "--quiet"); err != nil {
+			return err
+		}
+		infof("Приемник git: коммит отправлен в удаленный репозиторий")
+	}
+	return nil
+}
+
+func (g *gitSink) run(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command(g.binary, append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, errorf("git %s: %v: %s", args[0], err, msg)
+		}
+		return out, errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+// Приемник s3: каждый результат загружается объектом <s3_prefix>/<путь результата>
+type s3Sink struct {
+	s3     s3Config
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+func (s *s3Sink) Name() string { return SinkS3 }
+
+func (s *s3Sink) Publish(out sinkOutput) error {
+	key := out.RelPath
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	if err := s.s3.put(s.client, s.bucket, key, out.Content); err != nil {
+		return err
+	}
+	logf("Приемник s3: загружен объект %s", key)
+	return nil
+}
+
+func (s *s3Sink) Finish(string) error { return nil }
+
+// Приемник command: команда запускается для каждого результата, содержимое
+// передается в стандартный ввод, путь результата и сведения о файле - в переменных
+// окружения RICH_OUTPUT_PATH, RICH_OUTPUT_FILE и RICH_FILE_*
+type commandSink struct {
+	command string
+	timeout time.Duration
+}
+
+func (c *commandSink) Name() string { return SinkCommand }
+
+func (c *commandSink) Publish(out sinkOutput) error {
+	args := strings.Fields(c.command)
+	if len(args) == 0 {
+		return nil
+	}
+	file := ""
+	if len(out.Written) > 0 {
+		file = out.Written[0]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(append(os.Environ(), "RICH_OUTPUT_PATH="+out.RelPath, "RICH_OUTPUT_FILE="+file), out.File.Env()...)
+	cmd.Stdin = bytes.NewReader(out.Content)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return errorf("команда %s не завершилась за %v", args[0], c.timeout)
+		}
+		return errorf("команда %s завершилась с ошибкой: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	logf("Приемник command: результат %s передан команде %s", out.RelPath, args[0])
+	return nil
+}
+
+func (c *commandSink) Finish(string) error { return nil }
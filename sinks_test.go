@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadSinksConfig(t *testing.T) {
+	load := func(text string) (sinksConfig, error) {
+		t.Helper()
+		cfg, err := ini.Load([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loadSinksConfig(cfg.Section("OUTPUT"))
+	}
+
+	sc, err := load("")
+	if err != nil || !sc.WritesLocal() || len(sc.Sinks) != 1 {
+		t.Fatalf("По умолчанию результаты пишутся только в выходную директорию: %+v, %v", sc, err)
+	}
+	sc, err = load("[OUTPUT]\nsinks = local, Git, local\n")
+	if err != nil || strings.Join(sc.Sinks, ",") != "local,git" {
+		t.Errorf("sinks = %v, %v", sc.Sinks, err)
+	}
+	sc, err = load("[OUTPUT]\nsinks = s3\ns3_bucket = notes\ns3_prefix = /kb/\n")
+	if err != nil || sc.WritesLocal() || sc.S3Bucket != "notes" || sc.S3Prefix != "kb" {
+		t.Errorf("Параметры s3: %+v, %v", sc, err)
+	}
+
+	for _, text := range []string{
+		"[OUTPUT]\nsinks = confluence\n",
+		"[OUTPUT]\nsinks = ,\n",
+		"[OUTPUT]\nsinks = git\n",
+		"[OUTPUT]\nsinks = s3\n",
+		"[OUTPUT]\nsinks = command\n",
+		"[OUTPUT]\ncommand_timeout = 0s\n",
+	} {
+		if _, err := load(text); err == nil {
+			t.Errorf("Конфигурация %q должна возвращать ошибку", text)
+		}
+	}
+}
+
+func TestSinkSetTransactional(t *testing.T) {
+	var out bytes.Buffer
+	set := &sinkSet{sinks: []OutputSink{&stdoutSink{out: &out}}, deferred: true}
+	if err := set.Publish(sinkOutput{RelPath: "a.md", Content: []byte("# A\n")}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("До фиксации транзакции результаты не передаются: %q", out.String())
+	}
+	set.Finish("run-1", true)
+	if out.String() != "==> a.md <==\n# A\n\n" {
+		t.Errorf("Вывод приемника stdout: %q", out.String())
+	}
+
+	out.Reset()
+	set = &sinkSet{sinks: []OutputSink{&stdoutSink{out: &out}}, deferred: true}
+	_ = set.Publish(sinkOutput{RelPath: "b.md", Content: []byte("# B")})
+	set.Finish("run-2", false)
+	if out.Len() != 0 {
+		t.Errorf("Результаты отмененной транзакции не должны передаваться: %q", out.String())
+	}
+}
+
+func TestS3SinkOnlyRun(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	})
+	mux.HandleFunc("/notes/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		auth := r.Header.Get("Authorization")
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-central-1/s3/aws4_request") ||
+			r.Header.Get("Content-Type") != "text/markdown; charset=utf-8" {
+			t.Errorf("Неожиданный запрос к хранилищу: %s %s %v", r.Method, r.URL, r.Header)
+		}
+		mu.Lock()
+		objects[r.URL.EscapedPath()] = string(body)
+		mu.Unlock()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "sub dir", "a b.md"), []byte("# Заметка"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[OUTPUT]\nsinks = s3\ns3_bucket = notes\ns3_prefix = kb\n" +
+		"[S3]\nregion = eu-central-1\nendpoint = " + server.URL + "\naccess_key = AKID\nsecret_key = secret\n[EXCLUSIONS]\nexcluded_files =\n" +
+		"[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if got := objects["/notes/kb/sub%20dir/a%20b.md"]; !strings.HasPrefix(got, "# Обогащено") {
+		t.Errorf("Объект в хранилище: %v", objects)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "sub dir", "a b.md")); !os.IsNotExist(err) {
+		t.Errorf("Без приемника local результат не записывается в выходную директорию: %v", err)
+	}
+	config, err = loadConfig(configPath)
+	if err != nil || !isExcluded(config, "sub dir/a b.md") {
+		t.Errorf("Переданный приемникам файл должен попасть в список исключений: %v", err)
+	}
+}
+
+func TestGitSink(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "rich")
+	t.Setenv("GIT_AUTHOR_EMAIL", "rich@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "rich")
+	t.Setenv("GIT_COMMITTER_EMAIL", "rich@example.com")
+
+	dir := t.TempDir()
+	sink, err := newGitSink(sinksConfig{GitMessage: defaultGitSinkMessage}, "anna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.run(dir, "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	// Чужое изменение в индексе не должно попасть в коммит результатов
+	for name, content := range map[string]string{"notes/a.md": "# A", "other.txt": "чужой файл"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sink.run(dir, "add", "other.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Publish(sinkOutput{RelPath: "notes/a.md", OutputDir: dir, Written: []string{filepath.Join(dir, "notes", "a.md")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Finish("20260101-000000"); err != nil {
+		t.Fatalf("Finish() вернул ошибку: %v", err)
+	}
+	files, err := sink.run(dir, "show", "--name-only", "--format=%B", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files), "- notes/a.md") || !strings.Contains(string(files), "Run: 20260101-000000\nOperator: anna") || strings.Contains(string(files), "\nother.txt") {
+		t.Errorf("Коммит результатов:\n%s", files)
+	}
+
+	// Без изменений коммит не создается
+	_ = sink.Publish(sinkOutput{RelPath: "notes/a.md", OutputDir: dir, Written: []string{filepath.Join(dir, "notes", "a.md")}})
+	if err := sink.Finish(""); err != nil {
+		t.Fatalf("Finish() без изменений вернул ошибку: %v", err)
+	}
+	count, _ := sink.run(dir, "rev-list", "--count", "HEAD")
+	if strings.TrimSpace(string(count)) != "1" {
+		t.Errorf("Коммитов: %s", count)
+	}
+
+	// Выходная директория вне репозитория - ошибка
+	outside := t.TempDir()
+	_ = sink.Publish(sinkOutput{RelPath: "a.md", OutputDir: outside, Written: []string{filepath.Join(outside, "a.md")}})
+	if err := sink.Finish(""); err == nil {
+		t.Error("Для директории вне репозитория ожидалась ошибка")
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Документ статического сайта проверки результатов
+type siteDocument struct {
+	// Путь относительно выходной директории (через /)
+	Path     string
+	Title    string
+	Original string
+	Enriched string
+	// Запись отчета о запуске (нулевая, если файла нет в отчете)
+	Entry reportEntry
+	// Ссылка на страницу документа относительно index.html ("" - страницы нет)
+	Page string
+	// Путь исходного файла для rich label ("" - результата нет в журналах запусков)
+	Key string
+	// Действующая оценка проверяющего
+	Review outputLabel
+}
+
+// Подписи страниц сайта на языке интерфейса
+type siteLabels struct {
+	Title, Run, Started, Finished, Files, Enriched, Skipped, Failed, Tokens, Cost string
+	AvgWords, AvgHeadings, AvgReadability                                         string
+	Document, Status, Original, Result, Back, Error, NoOriginal, NoReport         string
+	Review, NoReview, ReviewHint                                                  string
+}
+
+// Подписи сайта для текущего языка интерфейса
+func newSiteLabels() siteLabels {
+	return siteLabels{
+		Title:          tr("Результаты обогащения"),
+		Run:            tr("Запуск"),
+		Started:        tr("Начат"),
+		Finished:       tr("Завершен"),
+		Files:          tr("Файлов"),
+		Enriched:       tr("Обогащено"),
+		Skipped:        tr("Пропущено"),
+		Failed:         tr("Ошибок"),
+		Tokens:         tr("Токены"),
+		Cost:           tr("Стоимость, $"),
+		AvgWords:       tr("Слова Δ на файл"),
+		AvgHeadings:    tr("Заголовки Δ на файл"),
+		AvgReadability: tr("Читаемость Δ на файл"),
+		Document:       tr("Документ"),
+		Status:         tr("Статус"),
+		Original:       tr("Оригинал"),
+		Result:         tr("Результат"),
+		Back:           tr("К списку файлов"),
+		Error:          tr("Ошибка"),
+		NoOriginal:     tr("Оригинал не сохранен в выходном файле"),
+		NoReport:       tr("Отчет о запуске не найден: статистика недоступна"),
+		Review:         tr("Оценка"),
+		NoReview:       tr("нет оценки"),
+		ReviewHint:     tr("Чтобы оценить результат, выполните одну из команд:"),
+	}
+}
+
+const siteStyle = `body{font-family:system-ui,sans-serif;margin:2rem;color:#222}
+table{border-collapse:collapse;width:100%}th,td{border-bottom:1px solid #ddd;padding:.3rem .6rem;text-align:left}
+td.num{text-align:right}.failed,.rejected{color:#b00}.skipped{color:#888}.accepted{color:#080}
+.stats{display:flex;flex-wrap:wrap;gap:1.5rem;margin-bottom:1.5rem}.stats div{min-width:8rem}.stats b{display:block;font-size:1.3rem}
+.sides{display:grid;grid-template-columns:1fr 1fr;gap:1rem}
+pre{white-space:pre-wrap;word-wrap:break-word;background:#f6f8fa;padding:1rem;border-radius:4px}`
+
+var siteIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.L.Title}}</title><style>` + siteStyle + `</style></head>
+<body>
+<h1>{{.L.Title}}</h1>
+{{with .Report}}<div class="stats">
+{{if .RunID}}<div>{{$.L.Run}}<b>{{.RunID}}</b></div>{{end}}
+<div>{{$.L.Started}}<b>{{.StartedAt.Format "2006-01-02 15:04"}}</b></div>
+<div>{{$.L.Finished}}<b>{{.FinishedAt.Format "2006-01-02 15:04"}}</b></div>
+<div>{{$.L.Files}}<b>{{.Totals.Files}}</b></div>
+<div>{{$.L.Enriched}}<b>{{.Totals.Enriched}}</b></div>
+<div>{{$.L.Skipped}}<b>{{.Totals.Skipped}}</b></div>
+<div>{{$.L.Failed}}<b>{{.Totals.Failed}}</b></div>
+<div>{{$.L.Tokens}}<b>{{.Totals.PromptTokens}} + {{.Totals.CompletionTokens}}</b></div>
+<div>{{$.L.Cost}}<b>{{printf "%.4f" .Totals.CostUSD}}</b></div>
+<div>{{$.L.AvgWords}}<b>{{printf "%+.1f" .Totals.AvgWordsDelta}}</b></div>
+<div>{{$.L.AvgHeadings}}<b>{{printf "%+.1f" .Totals.AvgHeadingsDelta}}</b></div>
+<div>{{$.L.AvgReadability}}<b>{{printf "%+.1f" .Totals.AvgReadabilityDelta}}</b></div>
+</div>{{else}}<p>{{.L.NoReport}}</p>{{end}}
+<table>
+<tr><th>{{.L.Document}}</th><th>{{.L.Status}}</th><th>{{.L.Review}}</th><th>{{.L.Tokens}}</th><th>{{.L.Cost}}</th></tr>
+{{range .Documents}}<tr>
+<td>{{if .Page}}<a href="{{.Page}}">{{.Path}}</a>{{else}}{{.Path}}{{end}}</td>
+<td class="{{.StatusClass}}">{{.Entry.Status}}{{with .Entry.Error}}: {{.}}{{end}}</td>
+<td class="{{.Review.Label}}">{{.Review.Label}}</td>
+<td class="num">{{with .Tokens}}{{.}}{{end}}</td>
+<td class="num">{{with .Entry.CostUSD}}{{printf "%.4f" .}}{{end}}</td>
+</tr>{{end}}
+</table>
+</body></html>
+`))
+
+var siteDocumentTemplate = template.Must(template.New("document").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Doc.Title}}</title><style>` + siteStyle + `</style></head>
+<body>
+<p><a href="{{.Back}}">← {{.L.Back}}</a></p>
+<h1>{{.Doc.Title}}</h1>
+<p>{{.Doc.Path}}{{with .Doc.Entry.Status}} · {{.}}{{end}}{{with .Doc.Entry.Model}} · {{.}}{{end}}</p>
+{{with .Doc.Entry.Error}}<p class="failed">{{$.L.Error}}: {{.}}</p>{{end}}
+{{with .Doc.Key}}<p>{{$.L.Review}}: {{with $.Doc.Review.Label}}<b class="{{.}}">{{.}}</b>{{with $.Doc.Review.Reviewer}} · {{.}}{{end}}{{with $.Doc.Review.Note}} · {{.}}{{end}}{{else}}{{$.L.NoReview}}{{end}}</p>
+<p>{{$.L.ReviewHint}}</p>
+<pre>rich label accept {{.}}
+rich label reject --note "..." {{.}}</pre>{{end}}
+<div class="sides">
+<div><h2>{{.L.Original}}</h2>{{if .Doc.Original}}<pre>{{.Doc.Original}}</pre>{{else}}<p>{{.L.NoOriginal}}</p>{{end}}</div>
+<div><h2>{{.L.Result}}</h2><pre>{{.Doc.Enriched}}</pre></div>
+</div>
+</body></html>
+`))
+
+// Класс строки таблицы по статусу файла
+func (d siteDocument) StatusClass() string {
+	switch {
+	case d.Entry.Status == StatusFailed:
+		return "failed"
+	case isSkippedStatus(d.Entry.Status):
+		return "skipped"
+	}
+	return ""
+}
+
+// Всего токенов документа
+func (d siteDocument) Tokens() int {
+	return d.Entry.PromptTokens + d.Entry.CompletionTokens
+}
+
+// Загрузка отчета о запуске (nil, если отчета нет)
+func loadRunReport(reportPath string) (*runReport, error) {
+	if reportPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(reportPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorf("ошибка при чтении отчета: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, errorf("некорректный отчет %s: %v", reportPath, err)
+	}
+	return &report, nil
+}
+
+// Документы выходной директории с записями отчета и оценками проверяющих;
+// файлы отчета без выходного файла (ошибки, пропуски) попадают в список без страницы
+func collectSiteDocuments(config *Config, report *runReport) ([]siteDocument, error) {
+	entries := map[string]reportEntry{}
+	if report != nil {
+		for _, e := range report.Files {
+			entries[e.Path] = e
+		}
+	}
+	keys, labels, err := siteReviewKeys(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []siteDocument
+	err = filepath.WalkDir(config.OutputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != config.OutputDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(config.OutputDir, p)
+		if err != nil {
+			return err
+		}
+		if config.isServiceFile(rel) {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return errorf("ошибка при чтении файла: %v", err)
+		}
+		rel = normalizeRelPath(rel)
+		doc := siteDocument{Path: rel, Enriched: string(data), Entry: entries[rel]}
+		if prev, ok := parseEnrichedOutput(string(data)); ok {
+			doc.Enriched, doc.Original = prev.Enriched, prev.Original
+		}
+		doc.Title, _, _ = describeDocument(rel, data)
+		doc.Page = "files/" + escapeLinkPath(strings.TrimSuffix(rel, path.Ext(rel))+".html")
+		doc.Key = keys[rel]
+		doc.Review = labels[doc.Key]
+		delete(entries, rel)
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, errorf("ошибка при обходе выходной директории: %v", err)
+	}
+	for rel, e := range entries {
+		docs = append(docs, siteDocument{Path: rel, Title: rel, Entry: e})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+	return docs, nil
+}
+
+// Пути исходных файлов по путям результатов в выходной директории и действующие
+// оценки; без каталога состояния оценки недоступны
+func siteReviewKeys(config *Config) (map[string]string, map[string]outputLabel, error) {
+	keys := make(map[string]string)
+	if config.StateDir == "" {
+		return keys, nil, nil
+	}
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return nil, nil, errorf("не удалось прочитать журналы запусков: %v", err)
+	}
+	for key, rec := range latest {
+		if rel, err := filepath.Rel(config.OutputDir, rec.Entry.Output); err == nil && !strings.HasPrefix(rel, "..") {
+			keys[normalizeRelPath(rel)] = key
+		}
+	}
+	labels, err := currentLabels(config.StateDir, latest)
+	return keys, labels, err
+}
+
+// Генерация статического сайта: index.html со списком файлов и статистикой запуска
+// и страница с оригиналом и результатом для каждого документа
+func exportSite(config *Config, report *runReport, siteDir string) (int, error) {
+	docs, err := collectSiteDocuments(config, report)
+	if err != nil {
+		return 0, err
+	}
+	labels := newSiteLabels()
+
+	write := func(rel string, tmpl *template.Template, data interface{}) error {
+		target := filepath.Join(siteDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return errorf("не удалось создать директорию сайта: %v", err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return errorf("ошибка при формировании страницы %s: %v", rel, err)
+		}
+		if err := safeWriteFile(target, []byte(b.String()), 0644); err != nil {
+			return errorf("ошибка при записи страницы %s: %v", rel, err)
+		}
+		return nil
+	}
+
+	pages := 0
+	for _, doc := range docs {
+		if doc.Page == "" {
+			continue
+		}
+		rel := "files/" + strings.TrimSuffix(doc.Path, path.Ext(doc.Path)) + ".html"
+		back := strings.Repeat("../", strings.Count(rel, "/")) + "index.html"
+		if err := write(rel, siteDocumentTemplate, map[string]interface{}{"L": labels, "Doc": doc, "Back": back}); err != nil {
+			return pages, err
+		}
+		pages++
+	}
+	if err := write("index.html", siteIndexTemplate, map[string]interface{}{"L": labels, "Report": report, "Documents": docs}); err != nil {
+		return pages, err
+	}
+	return pages, nil
+}
+
+// rich export site [--out dir]: статический HTML сайт для проверки результатов в браузере
+func runExportSiteCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export site", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	siteDir := fs.String("out", "site", tr("Директория для сайта"))
+	reportPath := fs.String("report", "", tr("Путь к JSON отчету о запуске (по умолчанию - из конфигурации)"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	if *reportPath == "" {
+		*reportPath = config.ReportFile
+	}
+	report, err := loadRunReport(*reportPath)
+	if err != nil {
+		return err
+	}
+
+	pages, err := exportSite(config, report, *siteDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Сайт сохранен в %s: документов %d (откройте index.html)\n"), *siteDir, pages)
+	return nil
+}
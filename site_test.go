@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExportSite(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "done")
+	if err := os.MkdirAll(filepath.Join(outputDir, "guides"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+	enriched := "# Установка\n\nШаги <script>alert(1)</script>\n\n```old\nисходный текст\n```"
+	if err := os.WriteFile(filepath.Join(outputDir, "guides", "setup.md"), []byte(enriched), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл: %v", err)
+	}
+
+	report := &runReport{RunID: "20250101-120000-abcdef", Files: []reportEntry{
+		{Path: "guides/setup.md", Status: StatusEnriched, PromptTokens: 100, CompletionTokens: 50, CostUSD: 0.002},
+		{Path: "broken.md", Status: StatusFailed, Error: "API запрос вернул статус 500"},
+	}}
+	report.finish()
+	reportPath := filepath.Join(tmpDir, "rich.report.json")
+	data, _ := json.Marshal(report)
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		t.Fatalf("Не удалось записать отчет: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	config := "[DIRECTORIES]\noutput_dir = " + outputDir + "\n\n[REPORT]\nfile = " + reportPath + "\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+
+	siteDir := filepath.Join(tmpDir, "site")
+	var out bytes.Buffer
+	if err := runExportCommand([]string{"site", "-config", configPath, "-out", siteDir}, &out); err != nil {
+		t.Fatalf("runExportCommand() вернул ошибку: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(siteDir, "index.html"))
+	if err != nil {
+		t.Fatalf("index.html не создан: %v", err)
+	}
+	for _, want := range []string{"20250101-120000-abcdef", `href="files/guides/setup.html"`, "broken.md", "статус 500", "0.0020"} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("В index.html нет %q", want)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(siteDir, "files", "guides", "setup.html"))
+	if err != nil {
+		t.Fatalf("Страница документа не создана: %v", err)
+	}
+	if !strings.Contains(string(page), "исходный текст") || !strings.Contains(string(page), `href="../../index.html"`) {
+		t.Errorf("Страница должна содержать оригинал и ссылку на список:\n%s", page)
+	}
+	if strings.Contains(string(page), "<script>") {
+		t.Error("Содержимое документов должно экранироваться")
+	}
+
+	if err := runExportCommand([]string{"pdf"}, &out); err == nil {
+		t.Error("Неизвестный формат экспорта должен возвращать ошибку")
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Источники входных файлов (input_source секции [DIRECTORIES])
+const (
+	// archive:<путь> - архив zip, tar или tar.gz
+	SourceArchive = "archive"
+	// git:<репозиторий>#<ref> - файлы ветки, тега или коммита без изменения рабочей копии
+	SourceGit = "git"
+	// s3://<бакет>/<префикс> - объекты хранилища S3 (подключение в секции [S3])
+	SourceS3 = "s3"
+	// stdin - один документ из стандартного ввода
+	SourceStdin = "stdin"
+)
+
+// Общий размер файлов, которые источник может выложить за запуск
+const maxSourceBytes = 1 << 30
+
+// Источник входных файлов помимо входной директории: файлы источника выкладываются
+// во временный каталог, который обходится как обычная входная директория, поэтому
+// .richignore, список исключений, фильтры и состояние одинаковы для всех источников
+type Source interface {
+	Name() string
+	// Выкладка файлов источника в каталог dir с сохранением относительных путей
+	Fetch(dir string) error
+}
+
+// Вид и параметр источника по значению input_source ("" - входная директория)
+func parseSourceSpec(spec string) (kind, arg string, err error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return "", "", nil
+	case spec == SourceStdin || spec == "-":
+		return SourceStdin, "", nil
+	case strings.HasPrefix(spec, "s3://"):
+		arg = strings.TrimPrefix(spec, "s3://")
+		if bucket, _, _ := strings.Cut(arg, "/"); bucket == "" {
+			return "", "", errorf("в источнике %q не указан бакет: ожидалось s3://<бакет>/<префикс>", spec)
+		}
+		return SourceS3, arg, nil
+	}
+	if kind, arg, ok := strings.Cut(spec, ":"); ok && (kind == SourceArchive || kind == SourceGit) && strings.TrimSpace(arg) != "" {
+		return kind, strings.TrimSpace(arg), nil
+	}
+	return "", "", errorf("некорректный источник входных файлов %q: ожидалось archive:<путь>, git:<репозиторий>#<ref>, s3://<бакет>/<префикс> или stdin", spec)
+}
+
+// Источник по конфигурации корня; nil - файлы берутся из входной директории
+func newSource(config *Config, stdin io.Reader) (Source, error) {
+	kind, arg, err := parseSourceSpec(config.InputSource)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case SourceArchive:
+		return &archiveSource{path: arg}, nil
+	case SourceGit:
+		repo, ref, _ := strings.Cut(arg, "#")
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return &gitSource{repo: repo, ref: ref}, nil
+	case SourceS3:
+		if err := config.S3.check(); err != nil {
+			return nil, err
+		}
+		bucket, prefix, _ := strings.Cut(arg, "/")
+		return &s3Source{s3: config.S3, bucket: bucket, prefix: strings.Trim(prefix, "/"), client: config.Network.client(0)}, nil
+	case SourceStdin:
+		return &stdinSource{in: stdin}, nil
+	}
+	return nil, nil
+}
+
+// Выкладка источника корня во временный каталог; cleanup удаляет каталог
+func fetchSource(config *Config) (dir string, cleanup func(), err error) {
+	src, err := newSource(config, os.Stdin)
+	if err != nil {
+		return "", nil, withCategory(ErrorConfig, err)
+	}
+	dir, err = os.MkdirTemp("", "rich-source-*")
+	if err != nil {
+		return "", nil, withCategory(ErrorIO, errorf("не удалось создать каталог для файлов источника: %v", err))
+	}
+	cleanup = func() {
+		if err := os.RemoveAll(dir); err != nil {
+			warnf("Предупреждение: не удалось удалить каталог файлов источника %s: %v", dir, err)
+		}
+	}
+	if err := src.Fetch(dir); err != nil {
+		cleanup()
+		return "", nil, errorf("источник %s: %w", src.Name(), err)
+	}
+	infof("Файлы источника %s выложены в %s", config.InputSource, dir)
+	return dir, cleanup, nil
+}
+
+// Запись файла источника по относительному пути с прямыми слешами; пути вне
+// каталога (../, абсолютные) считаются попыткой path traversal
+type sourceWriter struct {
+	dir   string
+	total int64
+}
+
+func (w *sourceWriter) Write(name string, r io.Reader) error {
+	rel := filepath.FromSlash(path.Clean(strings.TrimPrefix(name, "./")))
+	if !isRelPathSafe(rel) || filepath.IsAbs(rel) {
+		return errorf("обнаружена попытка path traversal: %s", name)
+	}
+	target := filepath.Join(w.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxSourceBytes-w.total+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if w.total += n; w.total > maxSourceBytes {
+		return errorf("файлы источника больше %d байт", int64(maxSourceBytes))
+	}
+	return nil
+}
+
+// Источник archive: архив zip, tar, tar.gz (tgz)
+type archiveSource struct {
+	path string
+}
+
+func (a *archiveSource) Name() string { return SourceArchive }
+
+func (a *archiveSource) Fetch(dir string) error {
+	lower := strings.ToLower(a.path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return extractZip(a.path, &sourceWriter{dir: dir})
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		f, err := os.Open(a.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if !strings.HasSuffix(lower, ".tar") {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return errorf("некорректный архив %s: %v", a.path, err)
+			}
+			defer zr.Close()
+			r = zr
+		}
+		return extractTar(r, &sourceWriter{dir: dir})
+	}
+	return errorf("неподдерживаемый формат архива %s: поддерживаются zip, tar, tar.gz", a.path)
+}
+
+// Обычные файлы архива zip; каталоги и ссылки пропускаются
+func extractZip(archivePath string, w *sourceWriter) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return errorf("некорректный архив %s: %v", archivePath, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errorf("ошибка чтения %s из архива: %v", f.Name, err)
+		}
+		err = w.Write(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Обычные файлы архива tar; каталоги и ссылки пропускаются
+func extractTar(r io.Reader, w *sourceWriter) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errorf("некорректный архив tar: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := w.Write(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Источник git: файлы ref выгружаются git archive, рабочая копия и индекс
+// репозитория не меняются
+type gitSource struct {
+	repo string
+	ref  string
+}
+
+func (g *gitSource) Name() string { return SourceGit }
+
+func (g *gitSource) Fetch(dir string) error {
+	binary, err := exec.LookPath("git")
+	if err != nil {
+		return errorf("программа git не найдена: %v", err)
+	}
+	cmd := exec.Command(binary, "-C", g.repo, "archive", "--format=tar", g.ref)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	extractErr := extractTar(out, &sourceWriter{dir: dir})
+	// Остаток вывода читается, чтобы git завершился и при ошибке распаковки
+	_, _ = io.Copy(io.Discard, out)
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errorf("git archive %s: %v: %s", g.ref, err, msg)
+		}
+		return errorf("git archive %s: %v", g.ref, err)
+	}
+	return extractErr
+}
+
+// Источник s3: объекты бакета с префиксом; путь файла - ключ без префикса
+type s3Source struct {
+	s3     s3Config
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+func (s *s3Source) Name() string { return SourceS3 }
+
+func (s *s3Source) Fetch(dir string) error {
+	listPrefix := s.prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	keys, err := s.s3.list(s.client, s.bucket, listPrefix)
+	if err != nil {
+		return err
+	}
+	w := &sourceWriter{dir: dir}
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, listPrefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		data, err := s.s3.get(s.client, s.bucket, key, maxSourceBytes-w.total)
+		if err != nil {
+			return err
+		}
+		if err := w.Write(rel, bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	logf("Источник s3: получено объектов %d из %s", len(keys), s.bucket)
+	return nil
+}
+
+// Источник stdin: документ из стандартного ввода сохраняется как stdin-<хеш>.md,
+// поэтому повторно переданный тот же документ пропускается по списку исключений,
+// а измененный обрабатывается заново
+type stdinSource struct {
+	in io.Reader
+}
+
+func (s *stdinSource) Name() string { return SourceStdin }
+
+func (s *stdinSource) Fetch(dir string) error {
+	data, err := io.ReadAll(io.LimitReader(s.in, maxSourceBytes+1))
+	if err != nil {
+		return errorf("ошибка чтения стандартного ввода: %v", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errorf("стандартный ввод пуст")
+	}
+	w := &sourceWriter{dir: dir}
+	return w.Write("stdin-"+contentHash(data)[:12]+".md", bytes.NewReader(data))
+}
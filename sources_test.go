@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSourceSpec(t *testing.T) {
+	cases := []struct {
+		spec, kind, arg string
+		wantErr         bool
+	}{
+		{"", "", "", false},
+		{"stdin", SourceStdin, "", false},
+		{"-", SourceStdin, "", false},
+		{"archive:notes.zip", SourceArchive, "notes.zip", false},
+		{"git:../kb#release", SourceGit, "../kb#release", false},
+		{"s3://notes/kb/2024", SourceS3, "notes/kb/2024", false},
+		{"s3:///kb", "", "", true},
+		{"archive:", "", "", true},
+		{"ftp://host/dir", "", "", true},
+	}
+	for _, c := range cases {
+		kind, arg, err := parseSourceSpec(c.spec)
+		if (err != nil) != c.wantErr || kind != c.kind || arg != c.arg {
+			t.Errorf("parseSourceSpec(%q) = %q, %q, %v", c.spec, kind, arg, err)
+		}
+	}
+}
+
+// Файлы выложенного источника: относительный путь -> содержимое
+func sourceFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestArchiveSource(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, content := range map[string]string{"a.md": "# A", "sub/b.md": "# B"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_, _ = zw.Create("sub/")
+	_ = zw.Close()
+	zipPath := filepath.Join(tmpDir, "notes.zip")
+	if err := os.WriteFile(zipPath, zipBuf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarGz := func(name string, entries map[string]string) string {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for entry, content := range entries {
+			_ = tw.WriteHeader(&tar.Header{Name: entry, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			_, _ = tw.Write([]byte(content))
+		}
+		_ = tw.WriteHeader(&tar.Header{Name: "link.md", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink})
+		_ = tw.Close()
+		_ = gz.Close()
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, archive := range []string{zipPath, tarGz("notes.tar.gz", map[string]string{"./a.md": "# A", "sub/b.md": "# B"})} {
+		dir := t.TempDir()
+		if err := (&archiveSource{path: archive}).Fetch(dir); err != nil {
+			t.Fatalf("Fetch(%s) вернул ошибку: %v", archive, err)
+		}
+		if got := sourceFiles(t, dir); fmt.Sprint(got) != fmt.Sprint(map[string]string{"a.md": "# A", "sub/b.md": "# B"}) {
+			t.Errorf("Файлы архива %s: %v", archive, got)
+		}
+	}
+
+	evil := tarGz("evil.tgz", map[string]string{"../escape.md": "x"})
+	if err := (&archiveSource{path: evil}).Fetch(t.TempDir()); err == nil || !strings.Contains(err.Error(), "path traversal") {
+		t.Errorf("Путь вне каталога в архиве должен быть ошибкой: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "escape.md")); !os.IsNotExist(err) {
+		t.Error("Файл архива записан вне каталога источника")
+	}
+	if err := (&archiveSource{path: filepath.Join(tmpDir, "notes.rar")}).Fetch(t.TempDir()); err == nil {
+		t.Error("Неподдерживаемый формат архива должен быть ошибкой")
+	}
+}
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "rich")
+	t.Setenv("GIT_AUTHOR_EMAIL", "rich@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "rich")
+	t.Setenv("GIT_COMMITTER_EMAIL", "rich@example.com")
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "--quiet")
+	if err := os.MkdirAll(filepath.Join(repo, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "notes", "a.md"), []byte("# Версия 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	// Незакоммиченные изменения рабочей копии в источник не попадают
+	if err := os.WriteFile(filepath.Join(repo, "notes", "a.md"), []byte("# Черновик"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := (&gitSource{repo: repo, ref: "v1"}).Fetch(dir); err != nil {
+		t.Fatalf("Fetch() вернул ошибку: %v", err)
+	}
+	if got := sourceFiles(t, dir); len(got) != 1 || got["notes/a.md"] != "# Версия 1" {
+		t.Errorf("Файлы ref v1: %v", got)
+	}
+	if err := (&gitSource{repo: repo, ref: "missing"}).Fetch(t.TempDir()); err == nil {
+		t.Error("Несуществующий ref должен быть ошибкой")
+	}
+}
+
+func TestS3Source(t *testing.T) {
+	objects := map[string]string{"kb/a.md": "# A", "kb/sub/b c.md": "# B", "kb/sub/": ""}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/notes/" && r.URL.Query().Get("list-type") == "2" {
+			if r.URL.Query().Get("prefix") != "kb/" {
+				t.Errorf("Неожиданный префикс: %s", r.URL.RawQuery)
+			}
+			// Список из двух страниц
+			if r.URL.Query().Get("continuation-token") == "" {
+				fmt.Fprint(w, `<ListBucketResult><Contents><Key>kb/a.md</Key></Contents><Contents><Key>kb/sub/</Key></Contents>`+
+					`<IsTruncated>true</IsTruncated><NextContinuationToken>page 2</NextContinuationToken></ListBucketResult>`)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult><Contents><Key>kb/sub/b c.md</Key></Contents><IsTruncated>false</IsTruncated></ListBucketResult>`)
+			return
+		}
+		content, ok := objects[strings.TrimPrefix(r.URL.Path, "/notes/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	config := &Config{InputSource: "s3://notes/kb/", S3: s3Config{Region: "eu-central-1", Endpoint: server.URL, AccessKey: "AKID", SecretKey: "secret"}}
+	src, err := newSource(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := src.Fetch(dir); err != nil {
+		t.Fatalf("Fetch() вернул ошибку: %v", err)
+	}
+	if got := sourceFiles(t, dir); len(got) != 2 || got["a.md"] != "# A" || got["sub/b c.md"] != "# B" {
+		t.Errorf("Файлы из S3: %v", got)
+	}
+
+	config.S3.SecretKey = ""
+	if _, err := newSource(config, nil); err == nil {
+		t.Error("Без ключей доступа источник S3 должен возвращать ошибку")
+	}
+}
+
+func TestStdinSourceRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "output")
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_source = stdin\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[EXCLUSIONS]\nexcluded_files =\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdin := filepath.Join(tmpDir, "stdin.md")
+	if err := os.WriteFile(stdin, []byte("# Документ из конвейера"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	oldStdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = oldStdin }()
+
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	name := "stdin-" + contentHash([]byte("# Документ из конвейера"))[:12] + ".md"
+	data, err := os.ReadFile(filepath.Join(outputDir, name))
+	if err != nil || !strings.HasPrefix(string(data), "# Обогащено") {
+		t.Errorf("Результат документа из stdin: %q, %v", data, err)
+	}
+	config, err = loadConfig(configPath)
+	if err != nil || !isExcluded(config, name) {
+		t.Errorf("Документ из stdin должен попасть в список исключений: %v", err)
+	}
+}
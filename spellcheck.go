@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Действия с опечатками в обогащенном тексте
+const (
+	// Опечатки только перечисляются в журнале и отчете
+	SpellcheckReport = "report"
+	// Опечатки с единственным вариантом исправления исправляются
+	SpellcheckFix = "fix"
+)
+
+// Словари hunspell по умолчанию для языков документов
+const defaultSpellDictionaries = "ru: ru_RU, uk: uk_UA, en: en_US, de: de_DE, fr: fr_FR, es: es_ES, it: it_IT, pt: pt_PT"
+
+// Проверка орфографии обогащенного текста через hunspell из секции [SPELLCHECK]
+type SpellcheckConfig struct {
+	Enabled bool
+	// Путь к программе hunspell
+	Binary string
+	// report или fix
+	Action string
+	// Словари по языкам документов: ru -> ru_RU
+	Dictionaries map[string]string
+	// Слова, которые не считаются опечатками (без учета регистра)
+	IgnoreWords []string
+	// Время ожидания проверки одного документа
+	Timeout time.Duration
+}
+
+// Чтение секции [SPELLCHECK]
+func loadSpellcheckConfig(section *ini.Section) (SpellcheckConfig, error) {
+	sc := SpellcheckConfig{
+		Enabled:      section.Key("enabled").MustBool(false),
+		Binary:       section.Key("binary").MustString("hunspell"),
+		Action:       strings.ToLower(section.Key("action").MustString(SpellcheckReport)),
+		Dictionaries: make(map[string]string),
+		IgnoreWords:  splitList(section.Key("ignore_words").String()),
+		Timeout:      section.Key("timeout").MustDuration(30 * time.Second),
+	}
+	if sc.Action != SpellcheckReport && sc.Action != SpellcheckFix {
+		return sc, errorf("некорректное значение action %q в секции [SPELLCHECK]: ожидалось %s или %s", sc.Action, SpellcheckReport, SpellcheckFix)
+	}
+	for _, item := range splitList(section.Key("dictionaries").MustString(defaultSpellDictionaries)) {
+		lang, dict, ok := strings.Cut(item, ":")
+		lang, dict = strings.ToLower(strings.TrimSpace(lang)), strings.TrimSpace(dict)
+		if !ok || lang == "" || dict == "" {
+			return sc, errorf("некорректный словарь %q в секции [SPELLCHECK]: ожидалось язык: словарь, например ru: ru_RU", item)
+		}
+		sc.Dictionaries[lang] = dict
+	}
+	if sc.Timeout <= 0 {
+		return sc, errorf("timeout в секции [SPELLCHECK] должен быть положительным: %v", sc.Timeout)
+	}
+	return sc, nil
+}
+
+// Проверка наличия hunspell; без него проверка орфографии выключается
+func (sc *SpellcheckConfig) checkBinary() {
+	if !sc.Enabled {
+		return
+	}
+	if _, err := exec.LookPath(sc.Binary); err != nil {
+		warnf("Предупреждение: программа %s не найдена, проверка орфографии выключена", sc.Binary)
+		sc.Enabled = false
+	}
+}
+
+// Опечатка в обогащенном тексте
+type spellingIssue struct {
+	Word string `json:"word"`
+	// Номер строки обогащенного документа (с 1)
+	Line        int      `json:"line"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Слово исправлено на единственный вариант (action = fix)
+	Fixed bool `json:"fixed,omitempty"`
+}
+
+// Строки документа для проверки: frontmatter, блоки кода, код в тексте, адреса,
+// HTML и вики-ссылки заменяются пробелами той же длины, поэтому позиции слов в
+// проверяемом тексте совпадают с позициями в документе
+func spellcheckLines(doc string) []string {
+	lines := strings.Split(doc, "\n")
+	masked := make([]string, len(lines))
+	_, body, _ := parseFrontmatter([]byte(doc))
+	first := strings.Count(doc[:len(doc)-len(body)], "\n")
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if i < first || inFence || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ") && !isListLine(trimmed) {
+			continue
+		}
+		masked[i] = typographyProtected.ReplaceAllStringFunc(line, func(s string) string {
+			return strings.Repeat(" ", len(s))
+		})
+	}
+	return masked
+}
+
+// Слово документа: буквы, апострофы и дефисы внутри слова
+var spellWordPattern = regexp.MustCompile(`\p{L}+(?:['’-]\p{L}+)*`)
+
+// Проверка орфографии обогащенного документа на языке lang. Сообщаются только слова,
+// которых нет в оригинале: имена и термины автора заметки не считаются опечатками.
+// При action = fix слова с единственным вариантом исправления заменяются в документе
+func (sc SpellcheckConfig) Check(doc, original, lang string) (string, []spellingIssue, error) {
+	dict, ok := sc.Dictionaries[lang]
+	if !sc.Enabled || !ok {
+		return doc, nil, nil
+	}
+	known := make(map[string]bool)
+	for _, w := range spellWordPattern.FindAllString(original, -1) {
+		known[strings.ToLower(w)] = true
+	}
+	for _, w := range sc.IgnoreWords {
+		known[strings.ToLower(w)] = true
+	}
+
+	masked := spellcheckLines(doc)
+	results, err := sc.run(dict, masked)
+	if err != nil {
+		return doc, nil, err
+	}
+
+	lines := strings.Split(doc, "\n")
+	var issues []spellingIssue
+	for i, misspelled := range results {
+		reported := make(map[string]bool)
+		for _, m := range misspelled {
+			if known[strings.ToLower(m.Word)] || reported[m.Word] {
+				continue
+			}
+			reported[m.Word] = true
+			issue := spellingIssue{Word: m.Word, Line: i + 1, Suggestions: m.Suggestions}
+			if sc.Action == SpellcheckFix && len(m.Suggestions) == 1 {
+				lines[i], issue.Fixed = replaceWord(lines[i], masked[i], m.Word, m.Suggestions[0])
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return strings.Join(lines, "\n"), issues, nil
+}
+
+// Замена слова целиком в строке документа по позициям в проверенном тексте
+func replaceWord(line, masked, word, replacement string) (string, bool) {
+	locs := spellWordPattern.FindAllStringIndex(masked, -1)
+	replaced := false
+	for i := len(locs) - 1; i >= 0; i-- {
+		start, end := locs[i][0], locs[i][1]
+		if masked[start:end] == word {
+			line = line[:start] + replacement + line[end:]
+			replaced = true
+		}
+	}
+	return line, replaced
+}
+
+// Слово, не найденное в словаре, и варианты исправления
+type misspelling struct {
+	Word        string
+	Suggestions []string
+}
+
+// Запуск hunspell в режиме ispell (-a): по группе результатов на каждую строку
+func (sc SpellcheckConfig) run(dict string, lines []string) ([][]misspelling, error) {
+	var input bytes.Buffer
+	for _, line := range lines {
+		// Префикс ^ - строка проверяется как текст, а не как команда
+		input.WriteString("^" + line + "\n")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sc.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, sc.Binary, "-a", "-i", "utf-8", "-d", dict)
+	cmd.Stdin = &input
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errorf("%s не завершился за %v", sc.Binary, sc.Timeout)
+		}
+		return nil, errorf("ошибка %s: %v: %s", sc.Binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseIspellOutput(stdout.String(), len(lines)), nil
+}
+
+// Разбор вывода режима ispell: строка версии, затем для каждой входной строки
+// результаты по словам (& - есть варианты, # - нет вариантов) и пустая строка
+func parseIspellOutput(output string, lines int) [][]misspelling {
+	results := make([][]misspelling, lines)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "@(#)"):
+		case text == "":
+			line++
+		case line >= lines:
+		case strings.HasPrefix(text, "& "):
+			head, suggestions, _ := strings.Cut(text[2:], ": ")
+			fields := strings.Fields(head)
+			if len(fields) == 0 {
+				continue
+			}
+			var list []string
+			for _, s := range strings.Split(suggestions, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			results[line] = append(results[line], misspelling{Word: fields[0], Suggestions: list})
+		case strings.HasPrefix(text, "# "):
+			if fields := strings.Fields(text[2:]); len(fields) > 0 {
+				results[line] = append(results[line], misspelling{Word: fields[0]})
+			}
+		}
+	}
+	return results
+}
+
+// Краткое описание опечатки для журнала: слово и варианты исправления
+func (s spellingIssue) String() string {
+	switch {
+	case s.Fixed:
+		return trf("%s -> %s (исправлено)", s.Word, s.Suggestions[0])
+	case len(s.Suggestions) > 0:
+		return s.Word + " -> " + strings.Join(s.Suggestions, ", ")
+	}
+	return s.Word
+}
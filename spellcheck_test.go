@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Заменитель hunspell в режиме ispell: знает три опечатки и записывает аргументы
+const fakeHunspellScript = `#!/bin/sh
+echo "$@" > "$0.args"
+echo "@(#) International Ispell Version 3.2.06 (but really Hunspell 1.7.0)"
+while IFS= read -r line; do
+	for w in $line; do
+		case "$w" in
+			*опечтка*) echo "& опечтка 1 0: опечатка";;
+			*граматика*) echo "& граматика 2 0: грамматика, грамотика";;
+			*Фуубар*) echo "# Фуубар 0";;
+			*) echo "*";;
+		esac
+	done
+	echo
+done
+`
+
+func TestParseIspellOutput(t *testing.T) {
+	output := "@(#) Hunspell\n*\n& teh 2 0: the, tech\n\n\n# Zork 5\n*\n\n"
+	got := parseIspellOutput(output, 3)
+	if len(got[0]) != 1 || got[0][0].Word != "teh" || strings.Join(got[0][0].Suggestions, "|") != "the|tech" {
+		t.Errorf("Строка 1: %+v", got[0])
+	}
+	if len(got[1]) != 0 || len(got[2]) != 1 || got[2][0].Word != "Zork" || got[2][0].Suggestions != nil {
+		t.Errorf("Строки 2-3: %+v", got[1:])
+	}
+}
+
+func TestLoadSpellcheckConfig(t *testing.T) {
+	cfg, _ := ini.Load([]byte("[SPELLCHECK]\nenabled = true\naction = fix\ndictionaries = ru: ru_RU, en: en_GB\n"))
+	sc, err := loadSpellcheckConfig(cfg.Section("SPELLCHECK"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Action != SpellcheckFix || sc.Dictionaries["en"] != "en_GB" || len(sc.Dictionaries) != 2 {
+		t.Errorf("Неожиданные настройки: %+v", sc)
+	}
+	for _, bad := range []string{"action = ask", "dictionaries = ru_RU", "timeout = 0s"} {
+		cfg, _ := ini.Load([]byte("[SPELLCHECK]\n" + bad + "\n"))
+		if _, err := loadSpellcheckConfig(cfg.Section("SPELLCHECK")); err == nil {
+			t.Errorf("%s: ожидалась ошибка", bad)
+		}
+	}
+}
+
+func TestSpellcheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("заменитель hunspell - сценарий sh")
+	}
+	binary := filepath.Join(t.TempDir(), "hunspell")
+	if err := os.WriteFile(binary, []byte(fakeHunspellScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sc := SpellcheckConfig{Enabled: true, Binary: binary, Action: SpellcheckFix, Dictionaries: map[string]string{"ru": "ru_RU"}, Timeout: 10 * time.Second}
+	doc := "---\ntitle: опечтка\n---\n# Заметка\n\nЭта опечтка и граматика, опечтка `опечтка` и Фуубар.\n\n```\nопечтка\n```"
+	fixed, issues, err := sc.Check(doc, "Заметка про Фуубар", "ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ntitle: опечтка\n---\n# Заметка\n\nЭта опечатка и граматика, опечатка `опечтка` и Фуубар.\n\n```\nопечтка\n```"
+	if fixed != want {
+		t.Errorf("Неожиданный результат:\n%s\nожидалось:\n%s", fixed, want)
+	}
+	// Слово из оригинала не считается опечаткой, повтор в строке сообщается один раз
+	if len(issues) != 2 || issues[0].Word != "опечтка" || !issues[0].Fixed || issues[0].Line != 6 ||
+		issues[1].Word != "граматика" || issues[1].Fixed || len(issues[1].Suggestions) != 2 {
+		t.Errorf("Неожиданные опечатки: %+v", issues)
+	}
+	if args, _ := os.ReadFile(binary + ".args"); !strings.Contains(string(args), "-d ru_RU") {
+		t.Errorf("Неожиданные аргументы hunspell: %s", args)
+	}
+
+	// Язык без словаря не проверяется
+	if out, issues, err := sc.Check(doc, "", "ja"); err != nil || out != doc || issues != nil {
+		t.Errorf("Документ без словаря не должен проверяться: %v, %v", issues, err)
+	}
+}
+
+// Опечатки результата попадают в отчет, однозначные исправляются перед записью
+func TestProcessFileSpellcheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("заменитель hunspell - сценарий sh")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant",
+				"content": "# Заметка\n\nВ этой заметке была опечтка, а теперь добавлено пояснение."}}},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	binary := filepath.Join(tmpDir, "hunspell")
+	if err := os.WriteFile(binary, []byte(fakeHunspellScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	inputPath := filepath.Join(tmpDir, "note.md")
+	outputPath := filepath.Join(tmpDir, "out", "note.md")
+	if err := os.WriteFile(inputPath, []byte("# Заметка\n\nВ этой заметке было что-то важное."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions", MaxTokens: 100,
+		Spellcheck: SpellcheckConfig{Enabled: true, Binary: binary, Action: SpellcheckFix, Dictionaries: map[string]string{"ru": "ru_RU"}, Timeout: 10 * time.Second}}
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	if len(result.Spelling) != 1 || !result.Spelling[0].Fixed {
+		t.Errorf("Ожидалась одна исправленная опечатка: %+v", result.Spelling)
+	}
+	if data, _ := os.ReadFile(outputPath); !strings.Contains(string(data), "была опечатка") {
+		t.Errorf("Опечатка не исправлена:\n%s", data)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Имя файла оглавления заметок разделенного документа
+const splitIndexFileName = "index.md"
+
+// Длина строки документа, отправляемой модели для разметки; остаток строки
+// не влияет на границы заметок
+const splitLineRunes = 200
+
+// Инструкция модели для разделения документа на заметки
+const splitInstruction = `The markdown document below is too long to be one note. Each line is prefixed with its number. Split it into logically separate notes: every note covers one self-contained topic and starts where that topic starts, usually at a heading. Do not split lists, tables or code blocks. Write note titles in the language of the document; slugs use lowercase latin letters, digits and hyphens (transliterate if needed). Reply with a single JSON object {"title": "title of the whole document", "notes": [{"title": "...", "slug": "...", "start": <first line number>}]} with notes in document order, and nothing else.`
+
+// Заметка, выделенная моделью: заголовок, slug и первая строка (с 1)
+type splitNote struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Start int    `json:"start"`
+	// Текст заметки из исходного документа
+	Text string `json:"-"`
+}
+
+// Разметка документа, предложенная моделью
+type splitPlan struct {
+	Title string      `json:"title"`
+	Notes []splitNote `json:"notes"`
+}
+
+// Текст документа с номерами строк; длинные строки сокращаются
+func numberedLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if utf8.RuneCountInString(line) > splitLineRunes {
+			line = string([]rune(line)[:splitLineRunes]) + "…"
+		}
+		fmt.Fprintf(&b, "%d: %s\n", i+1, line)
+	}
+	return b.String()
+}
+
+// Разбор ответа модели и нарезка документа по границам заметок. Первая заметка
+// начинается с первой строки; границы вне документа и не по порядку отбрасываются
+func parseSplitPlan(response string, lines []string) (splitPlan, error) {
+	var plan splitPlan
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return plan, errorf("в ответе модели нет разметки документа: %q", snippet(response, 200))
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &plan); err != nil {
+		return plan, errorf("некорректный ответ модели с разметкой документа: %v", err)
+	}
+	plan.Title = strings.Join(strings.Fields(plan.Title), " ")
+
+	var notes []splitNote
+	last := 0
+	for _, n := range plan.Notes {
+		n.Title = strings.Join(strings.Fields(n.Title), " ")
+		if n.Title == "" || n.Start <= last || n.Start > len(lines) {
+			continue
+		}
+		last = n.Start
+		if len(notes) == 0 {
+			n.Start = 1
+		}
+		notes = append(notes, n)
+	}
+	if len(notes) < 2 {
+		return plan, errorf("модель не разделила документ: заметок %d", len(notes))
+	}
+
+	// Уникальные slug в пределах документа
+	used := map[string]int{strings.TrimSuffix(splitIndexFileName, ".md"): 1}
+	for i := range notes {
+		slug := fileSlug(notes[i].Slug)
+		if slug == "" {
+			slug = fileSlug(notes[i].Title)
+		}
+		if slug == "" {
+			slug = "note"
+		}
+		used[slug]++
+		if used[slug] > 1 {
+			slug = fmt.Sprintf("%s-%d", slug, used[slug])
+		}
+		notes[i].Slug = slug
+
+		stop := len(lines)
+		if i+1 < len(notes) {
+			stop = notes[i+1].Start - 1
+		}
+		notes[i].Text = strings.TrimSpace(strings.Join(lines[notes[i].Start-1:stop], ""))
+	}
+	plan.Notes = notes
+	if plan.Title == "" {
+		plan.Title = notes[0].Title
+	}
+	return plan, nil
+}
+
+// Запрос разметки документа у модели
+func planSplit(config *Config, body string, limiter *RateLimiter) (splitPlan, Usage, error) {
+	lines := strings.SplitAfter(body, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	splitConfig := *config
+	splitConfig.Prompt = splitInstruction
+	// Примеры и правила ответа относятся к обогащению, а не к разметке
+	splitConfig.Examples = nil
+	splitConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&splitConfig, numberedLines(lines), limiter)
+	if err != nil {
+		return splitPlan{}, usage, err
+	}
+	plan, err := parseSplitPlan(response, lines)
+	return plan, usage, err
+}
+
+// Заметка с заголовком и ссылками на оглавление и исходный документ
+func renderSplitNote(n splitNote, docTitle, notePath, sourcePath string) string {
+	text := n.Text
+	// Заметка, которая начинается не с заголовка первого уровня, получает заголовок из разметки
+	if first, _, _ := strings.Cut(text, "\n"); !strings.HasPrefix(strings.TrimSpace(first), "# ") {
+		text = "# " + n.Title + "\n\n" + text
+	}
+	text = fmt.Sprintf("%s\n\n%s: [%s](%s)\n", text, tr("Оглавление"), escapeLinkText(docTitle), splitIndexFileName)
+	return withSourceLink(text, renderSourceLink(tr("Источник"), notePath, sourcePath))
+}
+
+// Оглавление заметок: frontmatter исходного документа, заголовок и список ссылок
+func renderSplitIndex(plan splitPlan, frontmatter string) string {
+	var b strings.Builder
+	b.WriteString(frontmatter)
+	fmt.Fprintf(&b, "# %s\n\n", plan.Title)
+	for i, n := range plan.Notes {
+		fmt.Fprintf(&b, "%d. [%s](%s)\n", i+1, escapeLinkText(n.Title), escapeLinkPath(n.Slug+".md"))
+	}
+	return b.String()
+}
+
+// Разделение документа: заметки и оглавление записываются в директорию результата
+// с именем документа (notes/big.md -> <выходная директория>/notes/big/)
+func splitDocument(config *Config, inputPath string, dryRun bool, limiter *RateLimiter) (string, splitPlan, Usage, error) {
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return "", splitPlan{}, Usage{}, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+	relPath, err := filepath.Rel(inputDir, inputPath)
+	if err != nil || !isRelPathSafe(relPath) {
+		return "", splitPlan{}, Usage{}, errorf("файл %s вне входной директории", inputPath)
+	}
+	outDir := filepath.Join(config.OutputDir, strings.TrimSuffix(relPath, filepath.Ext(relPath)))
+
+	content, err := os.ReadFile(inputPath)
+	if err != nil {
+		return outDir, splitPlan{}, Usage{}, errorf("ошибка при чтении файла: %w", err)
+	}
+	if blockedByPolicy(config.Policy.Check(content)) {
+		return outDir, splitPlan{}, Usage{}, withCategory(ErrorValidation, errorf("файл %s запрещен политикой содержимого", relPath))
+	}
+	_, body, _ := parseFrontmatter(content)
+	frontmatter := string(content[:len(content)-len(body)])
+
+	plan, usage, err := planSplit(config, string(body), limiter)
+	if err != nil || dryRun {
+		return outDir, plan, usage, err
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return outDir, plan, usage, withCategory(ErrorIO, errorf("ошибка при создании директории: %v", err))
+	}
+	for _, n := range plan.Notes {
+		notePath := filepath.Join(outDir, n.Slug+".md")
+		if err := safeWriteFile(notePath, []byte(renderSplitNote(n, plan.Title, notePath, inputPath)), 0644); err != nil {
+			return outDir, plan, usage, withCategory(ErrorIO, err)
+		}
+	}
+	if err := safeWriteFile(filepath.Join(outDir, splitIndexFileName), []byte(renderSplitIndex(plan, frontmatter)), 0644); err != nil {
+		return outDir, plan, usage, withCategory(ErrorIO, err)
+	}
+	return outDir, plan, usage, nil
+}
+
+// rich split [--dry-run] [файл...]: разделение длинных документов на отдельные
+// заметки; без файлов - документы входной директории больше max_file_size
+func runSplitCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	dryRun := fs.Bool("dry-run", false, tr("Только показать предложенные заметки, файлы не записываются"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		if !filepath.IsAbs(arg) {
+			if _, err := os.Stat(arg); err != nil {
+				arg = filepath.Join(inputDir, arg)
+			}
+		}
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return errorf("ошибка при получении абсолютного пути: %v", err)
+		}
+		if !isMarkdownPath(abs) {
+			return errorf("разделяются только markdown документы: %s", arg)
+		}
+		files = append(files, abs)
+	}
+	if len(files) == 0 {
+		cands, err := collectCandidates(config, inputDir, config.OutputDir, nil)
+		if err != nil {
+			return err
+		}
+		for _, c := range cands {
+			if isMarkdownPath(c.Path) && c.Info.Size() > int64(config.maxFileSize()) {
+				files = append(files, c.Path)
+			}
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(out, tr("Во входной директории нет документов больше %d байт\n"), config.maxFileSize())
+			return nil
+		}
+	}
+
+	limiter := config.newRateLimiter()
+	failed := 0
+	var spent float64
+	for _, file := range files {
+		outDir, plan, usage, err := splitDocument(config, file, *dryRun, limiter)
+		spent += usage.Cost(config)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, tr("%s: ошибка разделения: %v\n"), file, err)
+			continue
+		}
+		fmt.Fprintf(out, tr("%s -> %s: заметок %d\n"), file, outDir, len(plan.Notes))
+		for _, n := range plan.Notes {
+			fmt.Fprintf(out, "  %s.md  %s (%s %d)\n", n.Slug, n.Title, tr("строка"), n.Start)
+		}
+	}
+	if spent > 0 {
+		fmt.Fprintf(out, tr("Затраты за запуск: $%.4f\n"), spent)
+	}
+	if failed > 0 {
+		return errorf("не удалось разделить документов: %d", failed)
+	}
+	return nil
+}
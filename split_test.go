@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSplitPlan(t *testing.T) {
+	lines := strings.SplitAfter("Вступление\n## Установка\napt install\n## Index\nсписок\n## Установка\nеще раз\n", "\n")
+	lines = lines[:len(lines)-1]
+	response := "```json\n" + `{"title": "Руководство", "notes": [
+		{"title": "Введение", "slug": "intro", "start": 2},
+		{"title": "Установка", "slug": "setup", "start": 2},
+		{"title": "Оглавление", "slug": "index", "start": 4},
+		{"title": "Без строки", "slug": "x", "start": 40},
+		{"title": "Установка снова", "slug": "setup", "start": 6}
+	]}` + "\n```"
+	plan, err := parseSplitPlan(response, lines)
+	if err != nil {
+		t.Fatalf("parseSplitPlan() вернул ошибку: %v", err)
+	}
+	var got []string
+	for _, n := range plan.Notes {
+		got = append(got, n.Slug+"@"+strings.ReplaceAll(n.Text, "\n", "|"))
+	}
+	// Первая заметка начинается с первой строки, границы не по порядку отброшены, slug index занят оглавлением
+	want := []string{"intro@Вступление|## Установка|apt install", "index-2@## Index|список", "setup@## Установка|еще раз"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("заметки:\n%q\nожидалось\n%q", got, want)
+	}
+
+	if _, err := parseSplitPlan(`{"notes": [{"title": "Все", "start": 1}]}`, lines); err == nil {
+		t.Error("ожидалась ошибка для разметки из одной заметки")
+	}
+	if _, err := parseSplitPlan("не могу", lines); err == nil {
+		t.Error("ожидалась ошибка для ответа без JSON")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(filepath.Join(inputDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	doc := "---\ntags: [wiki]\n---\n# Платформа\n\nОбзор платформы.\n\n## Сборка\n\nmake build\n\n## Деплой\n\nkubectl apply\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "docs", "platform.md"), []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "small.md"), []byte("# Коротко\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `5: ## Сборка`) || strings.Contains(string(body), "tags: [wiki]") {
+			t.Errorf("документ отправлен без номеров строк или с frontmatter: %s", body)
+		}
+		plan := `{"title": "Платформа", "notes": [{"title": "Обзор", "slug": "overview", "start": 1}, {"title": "Сборка и деплой", "slug": "build-deploy", "start": 5}]}`
+		resp, _ := json.Marshal(map[string]interface{}{"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": plan}}}})
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[MODEL]\napi_url = " + server.URL + "/v1/chat/completions\nprovider = openai-compatible\n[PROCESSING]\nmax_file_size = 60\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runSplitCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("rich split вернул ошибку: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "overview.md  Обзор") || strings.Contains(out.String(), "small.md") {
+		t.Errorf("вывод rich split:\n%s", out.String())
+	}
+
+	notesDir := filepath.Join(outputDir, "docs", "platform")
+	index, err := os.ReadFile(filepath.Join(notesDir, splitIndexFileName))
+	if err != nil {
+		t.Fatalf("оглавление не создано: %v", err)
+	}
+	if string(index) != "---\ntags: [wiki]\n---\n# Платформа\n\n1. [Обзор](overview.md)\n2. [Сборка и деплой](build-deploy.md)\n" {
+		t.Errorf("оглавление:\n%s", index)
+	}
+	note, err := os.ReadFile(filepath.Join(notesDir, "build-deploy.md"))
+	if err != nil {
+		t.Fatalf("заметка не создана: %v", err)
+	}
+	want := "# Сборка и деплой\n\n## Сборка\n\nmake build\n\n## Деплой\n\nkubectl apply\n\nОглавление: [Платформа](index.md)\n\n" +
+		SourceStartMarker + "\nИсточник: [platform.md](../../../input/docs/platform.md)\n" + SourceEndMarker + "\n"
+	if string(note) != want {
+		t.Errorf("заметка:\n%s\nожидалось\n%s", note, want)
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Поддиректория каталога состояния с журналами запусков
+const runsDirName = "runs"
+
+// Файлы журнала запуска: заголовок запуска и записи о файлах, которые дописываются
+// по одной строке JSON
+const (
+	journalFileName = "journal.json"
+	entriesFileName = "entries.jsonl"
+)
+
+// Индекс последних результатов по журналам завершенных запусков в каталоге состояния
+const latestIndexFileName = "latest.json"
+
+// Запись журнала запуска об одном файле
+type journalEntry struct {
+	// Путь исходного файла относительно входной директории
+	Input string `json:"input"`
+	// Абсолютный путь выходного файла
+	Output string `json:"output,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Хэш исходного файла, по которому получен результат
+	InputHash string `json:"input_hash,omitempty"`
+	// Хэш записанного выходного файла для обнаружения последующих изменений (при
+	// конфликте - хэш файла с новым обогащением)
+	OutputHash string `json:"output_hash,omitempty"`
+	// Файл с новым обогащением результата, измененного вручную (статус conflict)
+	Conflict string `json:"conflict,omitempty"`
+	// Версия промпта и модель, которыми получен результат
+	PromptHash string `json:"prompt_hash,omitempty"`
+	Model      string `json:"model,omitempty"`
+	// Копия выходного файла до запуска (путь относительно директории запуска)
+	Backup string `json:"backup,omitempty"`
+	// Файл добавлен в excluded_files этим запуском
+	AddedToExcluded bool `json:"added_to_excluded,omitempty"`
+	// Абсолютный путь записанного файла карточек (удаляется при отмене)
+	Cards string `json:"cards,omitempty"`
+	// Изменения файла отменены командой undo
+	Undone bool `json:"undone,omitempty"`
+	// Эксперимент с промптами и вариант промпта результата
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	// Файл в директории проверки, из которого результат перенесен командой approve
+	// (отмена возвращает результат туда)
+	Approved string `json:"approved,omitempty"`
+	// Выходной файл - копия с отметкой об ошибке, записанная после неудачного
+	// обогащения (on_failure)
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// Журнал одного запуска: все артефакты запуска сгруппированы по его идентификатору.
+// Заголовок хранится в journal.json, записи о файлах - в entries.jsonl; журналы
+// прежних версий хранят записи в journal.json и читаются как есть
+type runJournal struct {
+	mu  sync.Mutex
+	dir string
+
+	ID         string `json:"id"`
+	ConfigPath string `json:"config_path"`
+	// Оператор запуска ([STATE] operator, "" - не задан)
+	Operator   string         `json:"operator,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	UndoneAt   *time.Time     `json:"undone_at,omitempty"`
+	Entries    []journalEntry `json:"entries,omitempty"`
+}
+
+// Уникальный идентификатор запуска: время запуска и случайный суффикс
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return now.Format("20060102-150405.000")
+	}
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Начало нового запуска оператора operator с журналом в каталоге состояния
+func startRun(stateDir, configPath, operator string) (*runJournal, error) {
+	now := time.Now()
+	id := newRunID(now)
+	dir := filepath.Join(stateDir, runsDirName, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errorf("не удалось создать директорию запуска: %v", err)
+	}
+	j := &runJournal{dir: dir, ID: id, ConfigPath: configPath, Operator: operator, StartedAt: now}
+	if err := j.Save(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Загрузка журнала запуска по идентификатору
+func loadRun(stateDir, id string) (*runJournal, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, errorf("некорректный идентификатор запуска: %q", id)
+	}
+	dir := filepath.Join(stateDir, runsDirName, id)
+	data, err := os.ReadFile(filepath.Join(dir, journalFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errorf("запуск %s не найден", id)
+		}
+		return nil, errorf("не удалось прочитать журнал запуска %s: %v", id, err)
+	}
+	j := &runJournal{dir: dir}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, errorf("некорректный журнал запуска %s: %v", id, err)
+	}
+	entries, err := readJournalEntries(filepath.Join(dir, entriesFileName))
+	if err != nil {
+		return nil, errorf("некорректный журнал запуска %s: %v", id, err)
+	}
+	j.Entries = append(j.Entries, entries...)
+	return j, nil
+}
+
+// Чтение записей о файлах из entries.jsonl; незавершенная последняя строка
+// (аварийное завершение во время записи) пропускается
+func readJournalEntries(path string) ([]journalEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []journalEntry
+	for len(data) > 0 {
+		line, rest, complete := bytes.Cut(data, []byte("\n"))
+		data = rest
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			if !complete {
+				break
+			}
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Список запусков в порядке от старых к новым
+func listRuns(stateDir string) ([]*runJournal, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(stateDir, runsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []*runJournal
+	for _, e := range dirEntries {
+		if !e.IsDir() {
+			continue
+		}
+		j, err := loadRun(stateDir, e.Name())
+		if err != nil {
+			continue
+		}
+		runs = append(runs, j)
+	}
+	sort.Slice(runs, func(a, b int) bool { return runs[a].StartedAt.Before(runs[b].StartedAt) })
+	return runs, nil
+}
+
+// Сохранение журнала запуска целиком: заголовок и все записи о файлах
+// (отмена запуска, импорт журналов)
+func (j *runJournal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var b bytes.Buffer
+	for _, e := range j.Entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return errorf("ошибка при подготовке журнала запуска: %v", err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := safeWriteFile(filepath.Join(j.dir, entriesFileName), b.Bytes(), 0644); err != nil {
+		return errorf("ошибка при записи журнала запуска: %v", err)
+	}
+	return j.saveHeader()
+}
+
+// Сохранение заголовка журнала без записей о файлах
+func (j *runJournal) saveHeader() error {
+	entries := j.Entries
+	j.Entries = nil
+	data, err := json.MarshalIndent(j, "", "  ")
+	j.Entries = entries
+	if err != nil {
+		return errorf("ошибка при подготовке журнала запуска: %v", err)
+	}
+	if err := safeWriteFile(filepath.Join(j.dir, journalFileName), data, 0644); err != nil {
+		return errorf("ошибка при записи журнала запуска: %v", err)
+	}
+	return nil
+}
+
+// Копирование существующего выходного файла перед перезаписью; "" - файла не было
+func (j *runJournal) Backup(outputPath, relPath string) (string, error) {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	backup := path.Join("backup", normalizeRelPath(relPath))
+	target := filepath.Join(j.dir, filepath.FromSlash(backup))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := safeWriteFile(target, data, 0644); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// Запись журнала по результату обработки файла
+func newJournalEntry(relPath, outputPath string, result *fileResult, err error) journalEntry {
+	entry := journalEntry{
+		Input:           normalizeRelPath(relPath),
+		Status:          result.Status,
+		InputHash:       result.InputHash,
+		OutputHash:      result.OutputHash,
+		PromptHash:      result.PromptHash,
+		Model:           result.Model,
+		Backup:          result.Backup,
+		AddedToExcluded: result.AddedToExcluded,
+		Cards:           result.CardsFile,
+		Conflict:        result.Conflict,
+	}
+	if result.OutputHash != "" {
+		entry.Output = outputPath
+	}
+	if result.Fallback != "" {
+		entry.Output, entry.Fallback = result.Fallback, true
+	}
+	if result.Variant != "" {
+		entry.Experiment, entry.Variant = result.Experiment, result.Variant
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// Добавление записи о файле: строка дописывается в entries.jsonl сразу, чтобы
+// пережить аварийное завершение, без перезаписи прежних записей
+func (j *runJournal) Record(entry journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errorf("ошибка при подготовке журнала запуска: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, entriesFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errorf("ошибка при записи журнала запуска: %v", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil && durableWrites.Load() {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errorf("ошибка при записи журнала запуска: %v", err)
+	}
+	j.Entries = append(j.Entries, entry)
+	return nil
+}
+
+// Завершение запуска
+func (j *runJournal) Finish() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.FinishedAt = &now
+	return j.saveHeader()
+}
+
+// Подсчет файлов журнала по итоговым статусам
+func (j *runJournal) Counts() (enriched, skipped, failed int) {
+	for _, e := range j.Entries {
+		switch {
+		case e.Status == StatusEnriched:
+			enriched++
+		case e.Status == StatusFailed:
+			failed++
+		default:
+			skipped++
+		}
+	}
+	return enriched, skipped, failed
+}
+
+// Хэш содержимого выходного файла
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Отмена запуска: восстановление прежних выходных файлов (или удаление созданных)
+// и удаление из excluded_files файлов, добавленных этим запуском. Файлы, измененные
+// после запуска, пропускаются, если не указан force
+func undoRun(j *runJournal, configPath string, force bool) (restored int, skipped []string, err error) {
+	if j.UndoneAt != nil {
+		return 0, nil, errorf("запуск %s уже отменен %s", j.ID, j.UndoneAt.Format(time.RFC3339))
+	}
+
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := &j.Entries[i]
+		// При конфликте запуск записал только файл .new рядом с результатом
+		target := e.Output
+		if e.Status == StatusConflict {
+			target = e.Conflict
+		}
+		// Неудачное обогащение отменяется, если запуск записал копию с отметкой
+		changed := e.Status == StatusEnriched || e.Status == StatusConflict || (e.Status == StatusFailed && e.Fallback)
+		if !changed || target == "" || e.Undone {
+			continue
+		}
+
+		current, readErr := os.ReadFile(target)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return restored, skipped, errorf("не удалось прочитать %s: %v", target, readErr)
+		}
+		if !force && (readErr != nil || contentHash(current) != e.OutputHash) {
+			skipped = append(skipped, e.Input)
+			continue
+		}
+
+		// Одобренный результат возвращается в очередь проверки
+		if e.Approved != "" && readErr == nil {
+			if err := os.MkdirAll(filepath.Dir(e.Approved), 0755); err != nil {
+				return restored, skipped, errorf("не удалось вернуть %s в очередь проверки: %v", target, err)
+			}
+			if err := safeWriteFile(e.Approved, current, 0644); err != nil {
+				return restored, skipped, err
+			}
+		}
+
+		if e.Backup != "" {
+			if !isRelPathSafe(e.Backup) {
+				return restored, skipped, errorf("недопустимый путь резервной копии в журнале: %s", e.Backup)
+			}
+			data, err := os.ReadFile(filepath.Join(j.dir, filepath.FromSlash(e.Backup)))
+			if err != nil {
+				return restored, skipped, errorf("не удалось прочитать резервную копию %s: %v", e.Backup, err)
+			}
+			if err := safeWriteFile(target, data, 0644); err != nil {
+				return restored, skipped, err
+			}
+		} else if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return restored, skipped, errorf("не удалось удалить %s: %v", target, err)
+		}
+
+		if e.Cards != "" {
+			if err := os.Remove(e.Cards); err != nil && !os.IsNotExist(err) {
+				return restored, skipped, errorf("не удалось удалить %s: %v", e.Cards, err)
+			}
+		}
+
+		if e.AddedToExcluded {
+			if err := removeFromExcludedFiles(configPath, e.Input); err != nil {
+				return restored, skipped, err
+			}
+		}
+		e.Undone = true
+		restored++
+	}
+
+	// Запуск считается отмененным, когда отменены изменения всех его файлов
+	if len(skipped) == 0 {
+		now := time.Now()
+		j.UndoneAt = &now
+	}
+	if err := j.Save(); err != nil {
+		return restored, skipped, err
+	}
+	// Отмененные результаты уже учтены в индексе: он строится заново при следующем чтении
+	return restored, skipped, invalidateLatestIndex(filepath.Dir(filepath.Dir(j.dir)))
+}
+
+// Последний действующий результат обработки файла по журналам всех запусков
+type outputRecord struct {
+	RunID string       `json:"run_id"`
+	At    time.Time    `json:"at"`
+	Entry journalEntry `json:"entry"`
+}
+
+// Индекс последних результатов: одна запись на файл вместо журналов всех запусков
+type latestIndex struct {
+	// Завершенные запуски, записи которых учтены в индексе
+	Runs    []string                `json:"runs"`
+	Outputs map[string]outputRecord `json:"outputs"`
+}
+
+// Последние неотмененные результаты по относительным путям исходных файлов.
+// Читается индекс latest.json и журналы только тех запусков, которых в нем еще нет;
+// завершенные запуски добавляются в индекс. Отмена запуска удаляет индекс
+func latestOutputs(stateDir string) (map[string]outputRecord, error) {
+	index := latestIndex{Outputs: make(map[string]outputRecord)}
+	if data, err := os.ReadFile(filepath.Join(stateDir, latestIndexFileName)); err == nil {
+		var stored latestIndex
+		if json.Unmarshal(data, &stored) == nil && stored.Outputs != nil {
+			index = stored
+		}
+	}
+	dirEntries, err := os.ReadDir(filepath.Join(stateDir, runsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index.Outputs, nil
+		}
+		return nil, err
+	}
+	indexed := make(map[string]bool, len(index.Runs))
+	for _, id := range index.Runs {
+		indexed[id] = true
+	}
+	var pending []*runJournal
+	for _, e := range dirEntries {
+		if !e.IsDir() || indexed[e.Name()] {
+			continue
+		}
+		if j, err := loadRun(stateDir, e.Name()); err == nil {
+			pending = append(pending, j)
+		}
+	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a].StartedAt.Before(pending[b].StartedAt) })
+
+	added := false
+	for _, j := range pending {
+		for _, e := range j.Entries {
+			if e.Status != StatusEnriched || e.Undone {
+				continue
+			}
+			e.Input = normalizeRelPath(e.Input)
+			// Запуск, не попавший в индекс раньше (прерванный), не заменяет более поздние результаты
+			if prev, ok := index.Outputs[e.Input]; ok && prev.RunID != j.ID && prev.At.After(j.StartedAt) {
+				continue
+			}
+			index.Outputs[e.Input] = outputRecord{RunID: j.ID, At: j.StartedAt, Entry: e}
+		}
+		// Незавершенный запуск может еще дописывать записи: он читается заново
+		if j.FinishedAt != nil {
+			index.Runs = append(index.Runs, j.ID)
+			added = true
+		}
+	}
+	if added {
+		data, err := json.Marshal(index)
+		if err != nil {
+			return nil, errorf("ошибка при подготовке индекса результатов: %v", err)
+		}
+		if err := safeWriteFile(filepath.Join(stateDir, latestIndexFileName), data, 0644); err != nil {
+			warnf("Предупреждение: не удалось записать индекс результатов: %v", err)
+		}
+	}
+	return index.Outputs, nil
+}
+
+// Удаление индекса последних результатов после изменения журналов
+func invalidateLatestIndex(stateDir string) error {
+	if err := os.Remove(filepath.Join(stateDir, latestIndexFileName)); err != nil && !os.IsNotExist(err) {
+		return errorf("не удалось удалить индекс результатов: %v", err)
+	}
+	return nil
+}
+
+// Результат, полученный с промптом, отличающимся от текущего
+type staleOutput struct {
+	outputRecord
+	CurrentPromptHash string
+}
+
+// Поиск результатов, полученных с устаревшей версией промпта. Текущая версия
+// вычисляется для каждого файла с учетом маршрутов и языка документа
+func stalePromptOutputs(config *Config) ([]staleOutput, error) {
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	var stale []staleOutput
+	for rel, rec := range latest {
+		if rec.Entry.PromptHash == "" {
+			continue
+		}
+		rootConfig, rootRel := config.rootForKey(rel)
+		content, err := os.ReadFile(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rootRel)))
+		if err != nil {
+			continue
+		}
+		fileConfig, _, _ := resolveFileConfig(rootConfig, rootRel, content)
+		if current := promptHash(fileConfig.Prompt); current != rec.Entry.PromptHash {
+			stale = append(stale, staleOutput{outputRecord: rec, CurrentPromptHash: current})
+		}
+	}
+	sort.Slice(stale, func(a, b int) bool { return stale[a].Entry.Input < stale[b].Entry.Input })
+	return stale, nil
+}
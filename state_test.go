@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestRunJournalAndUndo(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+
+	for _, dir := range []string{inputDir, outputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Не удалось создать директорию: %v", err)
+		}
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+	// Результат предыдущей обработки b.md должен восстановиться при отмене
+	if err := os.WriteFile(filepath.Join(outputDir, "b.md"), []byte("старый результат"), 0644); err != nil {
+		t.Fatalf("Не удалось создать выходной файл: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Обогащенный контент"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		ModelName:   "gpt-3.5-turbo",
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		APIKey:      "test_key",
+		Prompt:      "Test prompt",
+		StateDir:    stateDir,
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	runs, err := listRuns(stateDir)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("Ожидался один запуск, получено %d (%v)", len(runs), err)
+	}
+	j := runs[0]
+	if j.FinishedAt == nil {
+		t.Error("Запуск не отмечен как завершенный")
+	}
+	if enriched, _, _ := j.Counts(); enriched != 2 {
+		t.Fatalf("Ожидалось 2 обогащенных файла в журнале, получено %d", enriched)
+	}
+	for _, e := range j.Entries {
+		if !e.AddedToExcluded || e.OutputHash == "" {
+			t.Errorf("Неполная запись журнала: %+v", e)
+		}
+		if (e.Input == "b.md") != (e.Backup != "") {
+			t.Errorf("Резервная копия должна быть только у b.md: %+v", e)
+		}
+	}
+
+	// Подкоманда status выводит файлы запуска
+	statusConfig := filepath.Join(tmpDir, "status.cfg")
+	cfgData := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[STATE]\ndir = " + stateDir + "\n"
+	if err := os.WriteFile(statusConfig, []byte(cfgData), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+	var out bytes.Buffer
+	if err := runStatusCommand([]string{"-config", statusConfig, "-run", j.ID}, &out); err != nil {
+		t.Fatalf("status вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), j.ID) || !strings.Contains(out.String(), "b.md") {
+		t.Errorf("Некорректный вывод status:\n%s", out.String())
+	}
+
+	// Измененный после запуска файл не отменяется без force
+	modified := filepath.Join(outputDir, "a.md")
+	if err := os.WriteFile(modified, []byte("правка пользователя"), 0644); err != nil {
+		t.Fatalf("Ошибка записи файла: %v", err)
+	}
+	restored, skipped, err := undoRun(j, configPath, false)
+	if err != nil {
+		t.Fatalf("undoRun() вернул ошибку: %v", err)
+	}
+	if restored != 1 || len(skipped) != 1 || skipped[0] != "a.md" {
+		t.Errorf("Ожидалась отмена b.md и пропуск a.md, получено %d, %v", restored, skipped)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "b.md")); string(data) != "старый результат" {
+		t.Errorf("Прежний результат b.md не восстановлен: %q", data)
+	}
+	if j.UndoneAt != nil {
+		t.Error("Запуск с пропущенными файлами не должен считаться отмененным")
+	}
+
+	// Повторная отмена с force завершает отмену
+	j, err = loadRun(stateDir, j.ID)
+	if err != nil {
+		t.Fatalf("loadRun() вернул ошибку: %v", err)
+	}
+	if restored, skipped, err = undoRun(j, configPath, true); err != nil || restored != 1 || len(skipped) != 0 {
+		t.Fatalf("Ожидалась отмена a.md, получено %d, %v, %v", restored, skipped, err)
+	}
+	if _, err := os.Stat(modified); !os.IsNotExist(err) {
+		t.Error("Созданный запуском файл a.md не удален")
+	}
+	if j.UndoneAt == nil {
+		t.Error("Запуск не отмечен как отмененный")
+	}
+	if _, _, err := undoRun(j, configPath, false); err == nil {
+		t.Error("Повторная отмена отмененного запуска должна возвращать ошибку")
+	}
+	// Индекс последних результатов не возвращает отмененные файлы
+	if latest, err := latestOutputs(stateDir); err != nil || len(latest) != 0 {
+		t.Errorf("После отмены не должно оставаться результатов, получено %v (%v)", latest, err)
+	}
+
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		t.Fatalf("Ошибка чтения конфигурации: %v", err)
+	}
+	if excluded := cfg.Section("EXCLUSIONS").Key("excluded_files").String(); excluded != "" {
+		t.Errorf("Файлы запуска не удалены из excluded_files: %q", excluded)
+	}
+
+	if _, err := loadRun(stateDir, "../x"); err == nil {
+		t.Error("Некорректный идентификатор запуска должен отклоняться")
+	}
+}
+
+func TestJournalEntriesAndLatestIndex(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), ".rich")
+
+	first, err := startRun(stateDir, "test.cfg", "")
+	if err != nil {
+		t.Fatalf("startRun() вернул ошибку: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := first.Record(journalEntry{Input: name, Status: StatusEnriched, OutputHash: "first"}); err != nil {
+			t.Fatalf("Record() вернул ошибку: %v", err)
+		}
+	}
+	if err := first.Finish(); err != nil {
+		t.Fatalf("Finish() вернул ошибку: %v", err)
+	}
+
+	// Записи дописываются в entries.jsonl, заголовок их не содержит
+	if data, _ := os.ReadFile(filepath.Join(first.dir, entriesFileName)); strings.Count(string(data), "\n") != 2 {
+		t.Errorf("Ожидалось 2 строки в %s, получено:\n%s", entriesFileName, data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(first.dir, journalFileName)); strings.Contains(string(data), `"entries"`) {
+		t.Errorf("Заголовок журнала не должен содержать записи:\n%s", data)
+	}
+
+	// Незавершенная последняя строка после аварийного завершения пропускается
+	file, err := os.OpenFile(filepath.Join(first.dir, entriesFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Ошибка открытия журнала: %v", err)
+	}
+	file.WriteString(`{"input":"c.md","sta`)
+	file.Close()
+	loaded, err := loadRun(stateDir, first.ID)
+	if err != nil || len(loaded.Entries) != 2 || loaded.FinishedAt == nil {
+		t.Fatalf("Ожидался завершенный запуск с 2 записями, получено %+v (%v)", loaded, err)
+	}
+
+	latest, err := latestOutputs(stateDir)
+	if err != nil || len(latest) != 2 {
+		t.Fatalf("Ожидалось 2 результата, получено %v (%v)", latest, err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, latestIndexFileName)); err != nil {
+		t.Fatalf("Индекс результатов не записан: %v", err)
+	}
+
+	// Новый запуск дополняет индекс и заменяет прежний результат a.md
+	second, err := startRun(stateDir, "test.cfg", "")
+	if err != nil {
+		t.Fatalf("startRun() вернул ошибку: %v", err)
+	}
+	if err := second.Record(journalEntry{Input: "a.md", Status: StatusEnriched, OutputHash: "second"}); err != nil {
+		t.Fatalf("Record() вернул ошибку: %v", err)
+	}
+	if err := second.Finish(); err != nil {
+		t.Fatalf("Finish() вернул ошибку: %v", err)
+	}
+	// Журналы запусков из индекса повторно не читаются
+	if err := os.Remove(filepath.Join(first.dir, entriesFileName)); err != nil {
+		t.Fatalf("Ошибка удаления журнала: %v", err)
+	}
+	latest, err = latestOutputs(stateDir)
+	if err != nil || len(latest) != 2 {
+		t.Fatalf("Ожидалось 2 результата, получено %v (%v)", latest, err)
+	}
+	if latest["a.md"].RunID != second.ID || latest["a.md"].Entry.OutputHash != "second" {
+		t.Errorf("Результат a.md должен быть из второго запуска: %+v", latest["a.md"])
+	}
+	if latest["b.md"].RunID != first.ID {
+		t.Errorf("Результат b.md должен остаться из первого запуска: %+v", latest["b.md"])
+	}
+
+	// После удаления индекса результаты строятся заново по журналам
+	if err := invalidateLatestIndex(stateDir); err != nil {
+		t.Fatalf("invalidateLatestIndex() вернул ошибку: %v", err)
+	}
+	if latest, err = latestOutputs(stateDir); err != nil || len(latest) != 1 || latest["a.md"].RunID != second.ID {
+		t.Errorf("Ожидался только результат a.md второго запуска, получено %v (%v)", latest, err)
+	}
+}
+
+func TestStalePromptOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files = \n"), 0644); err != nil {
+		t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл %s: %v", name, err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "Обогащенный контент"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		InputDir:    inputDir,
+		OutputDir:   filepath.Join(tmpDir, "output"),
+		ModelName:   "gpt-3.5-turbo",
+		ModelAPIURL: server.URL + "/v1/chat/completions",
+		Prompt:      "Промпт v1",
+		StateDir:    filepath.Join(tmpDir, ".rich"),
+		Routes:      []Route{{Name: "b", Paths: []ignorePattern{mustIgnorePattern(t, "b.md")}, Prompt: "Промпт маршрута"}},
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if stale, err := stalePromptOutputs(config); err != nil || len(stale) != 0 {
+		t.Fatalf("С текущим промптом устаревших результатов быть не должно: %v, %+v", err, stale)
+	}
+
+	// Изменение общего промпта делает устаревшим только файл без маршрута
+	config.Prompt = "Промпт v2"
+	stale, err := stalePromptOutputs(config)
+	if err != nil {
+		t.Fatalf("stalePromptOutputs() вернул ошибку: %v", err)
+	}
+	if len(stale) != 1 || stale[0].Entry.Input != "a.md" || stale[0].CurrentPromptHash != promptHash("Промпт v2") {
+		t.Errorf("Ожидался устаревший результат a.md, получено %+v", stale)
+	}
+	if stale[0].Entry.Model != "gpt-3.5-turbo" {
+		t.Errorf("В журнале не сохранена модель: %+v", stale[0].Entry)
+	}
+}
+
+func mustIgnorePattern(t *testing.T, pattern string) ignorePattern {
+	t.Helper()
+	p, ok, err := parseIgnorePattern(pattern)
+	if err != nil || !ok {
+		t.Fatalf("Некорректный шаблон: %s", pattern)
+	}
+	return p
+}
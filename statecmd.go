@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Правила выбора записи файла состояния, если файл есть в обоих состояниях (--prefer)
+const (
+	// Остается запись более поздней обработки; при равном времени - локальная
+	PreferNewer = "newer"
+	// Остается локальная запись
+	PreferOurs = "ours"
+	// Остается запись объединяемого состояния
+	PreferTheirs = "theirs"
+)
+
+// Версия формата выгрузки состояния
+const stateBundleVersion = 1
+
+// Выгрузка состояния обработки (rich state export): список исключений, записи
+// файла состояния и журналы запусков без резервных копий
+type stateBundle struct {
+	Version    int                      `json:"version"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Excluded   []string                 `json:"excluded_files"`
+	Files      map[string]manifestEntry `json:"files"`
+	Runs       []*runJournal            `json:"runs"`
+}
+
+// Состояние обработки другой машины: список исключений, записи файла состояния и
+// журналы запусков
+type foreignState struct {
+	// Файл конфигурации или выгрузки, из которого прочитано состояние
+	Source   string
+	Excluded []string
+	Files    map[string]manifestEntry
+	// Каталог состояния, журналы которого копируются целиком ("" - не задан)
+	StateDir string
+	// Журналы запусков из выгрузки
+	Runs []*runJournal
+}
+
+// Чтение состояния из файла конфигурации другой машины: относительный путь каталога
+// состояния отсчитывается от директории файла конфигурации (рабочая директория
+// машины копируется целиком)
+func loadForeignState(configPath string) (*foreignState, error) {
+	cfg, err := loadConfigFile(configPath, false)
+	if err != nil {
+		return nil, errorf("не удалось загрузить файл конфигурации %s: %v", configPath, err)
+	}
+	st := &foreignState{Source: configPath, StateDir: ".rich"}
+	for _, file := range strings.Split(cfg.Section("EXCLUSIONS").Key("excluded_files").String(), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			st.Excluded = append(st.Excluded, file)
+		}
+	}
+	if section := cfg.Section("STATE"); section.HasKey("dir") {
+		st.StateDir = stripLongPathPrefix(strings.TrimSpace(section.Key("dir").String()))
+	}
+	if st.StateDir != "" && !filepath.IsAbs(st.StateDir) {
+		st.StateDir = filepath.Join(filepath.Dir(configPath), st.StateDir)
+	}
+	if st.StateDir != "" {
+		manifest, err := loadStateManifest(st.StateDir)
+		if err != nil {
+			return nil, err
+		}
+		st.Files = manifest.Files
+	}
+	return st, nil
+}
+
+// Выгрузка состояния обработки
+func exportState(config *Config) (*stateBundle, error) {
+	bundle := &stateBundle{Version: stateBundleVersion, ExportedAt: time.Now().UTC(), Excluded: config.ExcludedFiles, Files: map[string]manifestEntry{}}
+	if config.StateDir == "" {
+		return bundle, nil
+	}
+	manifest, err := loadStateManifest(config.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Files = manifest.Files
+	if bundle.Runs, err = listRuns(config.StateDir); err != nil {
+		return nil, errorf("не удалось прочитать журналы запусков %s: %v", config.StateDir, err)
+	}
+	return bundle, nil
+}
+
+// Чтение выгрузки состояния
+func loadStateBundle(path string) (*foreignState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorf("не удалось прочитать выгрузку состояния: %v", err)
+	}
+	var bundle stateBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, errorf("некорректная выгрузка состояния %s: %v", path, err)
+	}
+	if bundle.Version != stateBundleVersion {
+		return nil, errorf("неподдерживаемая версия выгрузки состояния %s: %d", path, bundle.Version)
+	}
+	return &foreignState{Source: path, Excluded: bundle.Excluded, Files: bundle.Files, Runs: bundle.Runs}, nil
+}
+
+// Проверка правила выбора записи файла состояния
+func validatePrefer(prefer string) error {
+	if prefer != PreferNewer && prefer != PreferOurs && prefer != PreferTheirs {
+		return errorf("некорректное значение --prefer %q: ожидалось %s, %s или %s", prefer, PreferNewer, PreferOurs, PreferTheirs)
+	}
+	return nil
+}
+
+// Выбор записи объединяемого состояния вместо локальной
+func preferIncoming(prefer string, cur, incoming manifestEntry) bool {
+	switch prefer {
+	case PreferOurs:
+		return false
+	case PreferTheirs:
+		return cur != incoming
+	}
+	return incoming.UpdatedAt.After(cur.UpdatedAt)
+}
+
+// Итоги слияния состояния
+type mergeSummary struct {
+	// Файлов, добавленных в excluded_files
+	Excluded int
+	// Записей файла состояния, добавленных или замененных по правилу prefer
+	States int
+	// Журналов запусков, скопированных в каталог состояния
+	Runs int
+}
+
+// Слияние состояния других машин (частей -shard, выгрузок) с локальным: списки
+// исключений объединяются, запись файла состояния о файле, который есть в обоих
+// состояниях, выбирается по prefer, журналы запусков копируются (идентификаторы
+// запусков уникальны)
+func mergeState(config *Config, configPath string, sources []*foreignState, prefer string) (mergeSummary, error) {
+	var summary mergeSummary
+	var excluded []string
+	for _, src := range sources {
+		excluded = append(excluded, src.Excluded...)
+	}
+	added, err := addManyToExcludedFiles(configPath, excluded)
+	if err != nil {
+		return summary, err
+	}
+	summary.Excluded = added
+	if config.StateDir == "" {
+		return summary, nil
+	}
+
+	manifest, err := loadStateManifest(config.StateDir)
+	if err != nil {
+		return summary, err
+	}
+	for _, src := range sources {
+		if src.StateDir != "" && samePath(src.StateDir, config.StateDir) {
+			continue
+		}
+		for key, entry := range src.Files {
+			if cur, ok := manifest.Files[key]; ok && !preferIncoming(prefer, cur, entry) {
+				continue
+			}
+			manifest.Files[key] = entry
+			manifest.dirty = true
+			summary.States++
+		}
+		var copied int
+		if src.StateDir != "" {
+			copied, err = copyRuns(src.StateDir, config.StateDir)
+		} else {
+			copied, err = importRuns(src.Runs, config.StateDir)
+		}
+		summary.Runs += copied
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, manifest.Flush()
+}
+
+// Копирование журналов запусков, которых нет в каталоге состояния dst
+func copyRuns(src, dst string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(src, runsDirName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errorf("не удалось прочитать журналы запусков %s: %v", src, err)
+	}
+	copied := 0
+	for _, e := range entries {
+		target := filepath.Join(dst, runsDirName, e.Name())
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := copyTree(filepath.Join(src, runsDirName, e.Name()), target); err != nil {
+			return copied, errorf("не удалось скопировать журнал запуска %s: %v", e.Name(), err)
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// Запись журналов запусков выгрузки, которых нет в каталоге состояния dst
+func importRuns(runs []*runJournal, dst string) (int, error) {
+	imported := 0
+	for _, j := range runs {
+		if j.ID == "" || strings.ContainsAny(j.ID, `/\`) || strings.Contains(j.ID, "..") {
+			return imported, errorf("некорректный идентификатор запуска: %q", j.ID)
+		}
+		j.dir = filepath.Join(dst, runsDirName, j.ID)
+		if _, err := os.Stat(j.dir); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(j.dir, 0755); err != nil {
+			return imported, errorf("не удалось создать директорию запуска: %v", err)
+		}
+		if err := j.Save(); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// Перевод списка исключений в файл состояния (rich state migrate): для файлов из
+// excluded_files без записи в файле состояния записывается хэш текущего входного
+// файла, как после обогащения. Возвращает число записанных файлов и файлы, входных
+// файлов которых нет
+func migrateExcluded(config *Config, now time.Time) (int, []string, error) {
+	if config.StateDir == "" {
+		return 0, nil, withCategory(ErrorConfig, errorf("для rich state migrate задайте каталог состояния [STATE] dir"))
+	}
+	manifest, err := loadStateManifest(config.StateDir)
+	if err != nil {
+		return 0, nil, err
+	}
+	migrated := 0
+	var missing []string
+	for _, file := range config.ExcludedFiles {
+		key := normalizeRelPath(file)
+		if key == "" {
+			continue
+		}
+		if _, ok := manifest.Files[key]; ok {
+			continue
+		}
+		rootConfig, rel := config.rootForKey(key)
+		data, err := os.ReadFile(filepath.Join(rootConfig.InputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			missing = append(missing, file)
+			continue
+		}
+		manifest.Files[key] = manifestEntry{Hash: contentHash(data), Status: StatusEnriched, UpdatedAt: now.UTC()}
+		manifest.dirty = true
+		migrated++
+	}
+	return migrated, missing, manifest.Flush()
+}
+
+// Копирование директории с поддиректориями
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return safeWriteFile(target, data, 0644)
+	})
+}
+
+// Добавление нескольких файлов в список исключений за одну запись конфигурации;
+// возвращает число добавленных файлов
+func addManyToExcludedFiles(configPath string, files []string) (int, error) {
+	if len(files) == 0 {
+		return 0, nil
+	}
+	added := 0
+	err := withFileLock(configPath, func() error {
+		cfg, err := loadConfigFile(configPath, true)
+		if err != nil {
+			return errorf("не удалось загрузить файл конфигурации: %v", err)
+		}
+		key := cfg.Section("EXCLUSIONS").Key("excluded_files")
+		var list []string
+		seen := make(map[string]bool)
+		for _, file := range strings.Split(key.String(), ",") {
+			if file = strings.TrimSpace(file); file != "" {
+				list = append(list, file)
+				seen[pathKey(file)] = true
+			}
+		}
+		for _, file := range files {
+			file = normalizeRelPath(file)
+			if file == "" || seen[pathKey(file)] {
+				continue
+			}
+			list = append(list, file)
+			seen[pathKey(file)] = true
+			added++
+		}
+		if added == 0 {
+			return nil
+		}
+		key.SetValue(strings.Join(list, ", "))
+		return saveConfigFile(cfg, configPath)
+	})
+	return added, err
+}
+
+// rich state <команда>: перенос и слияние состояния обработки
+func runStateCommand(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runStateExportCommand(args[1:], out)
+		case "import":
+			return runStateImportCommand(args[1:], out)
+		case "merge":
+			return runStateMergeCommand(args[1:], out)
+		case "migrate":
+			return runStateMigrateCommand(args[1:], out)
+		}
+	}
+	return errorf("укажите команду: rich state export, import, merge или migrate")
+}
+
+// rich state export [--out <файл>]: выгрузка состояния в JSON (по умолчанию в
+// стандартный вывод)
+func runStateExportCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("state export", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	outPath := fs.String("out", "", tr("Файл выгрузки (по умолчанию стандартный вывод)"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	bundle, err := exportState(config)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return errorf("не удалось подготовить выгрузку состояния: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		_, err = out.Write(data)
+		return err
+	}
+	if err := safeWriteFile(*outPath, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Состояние выгружено в %s: %d файлов в excluded_files, %d записей файла состояния, %d журналов запусков\n"),
+		*outPath, len(bundle.Excluded), len(bundle.Files), len(bundle.Runs))
+	return nil
+}
+
+// Разбор общих параметров import и merge: конфигурация, правило выбора записей
+// и источники
+func parseStateMergeArgs(name string, args []string) (configPath, prefer string, sources []string, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	cp := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	pr := fs.String("prefer", PreferNewer, tr("Запись о файле, который есть в обоих состояниях: newer, ours или theirs"))
+	if err := fs.Parse(args); err != nil {
+		return "", "", nil, err
+	}
+	if err := validatePrefer(*pr); err != nil {
+		return "", "", nil, err
+	}
+	return *cp, *pr, fs.Args(), nil
+}
+
+// rich state import [--prefer newer|ours|theirs] <выгрузка>...: слияние выгрузок
+// состояния с локальным
+func runStateImportCommand(args []string, out io.Writer) error {
+	configPath, prefer, paths, err := parseStateMergeArgs("state import", args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errorf("укажите файлы выгрузки состояния")
+	}
+	var sources []*foreignState
+	for _, path := range paths {
+		src, err := loadStateBundle(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	return runMergeSources(configPath, prefer, sources, out)
+}
+
+// rich state merge [--prefer newer|ours|theirs] <конфигурация>...: слияние
+// состояния частей -shard
+func runStateMergeCommand(args []string, out io.Writer) error {
+	configPath, prefer, paths, err := parseStateMergeArgs("state merge", args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errorf("укажите файлы конфигурации машин, состояние которых нужно объединить")
+	}
+	var sources []*foreignState
+	for _, path := range paths {
+		src, err := loadForeignState(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	return runMergeSources(configPath, prefer, sources, out)
+}
+
+// Слияние источников с состоянием локальной конфигурации и вывод итогов
+func runMergeSources(configPath, prefer string, sources []*foreignState, out io.Writer) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	summary, err := mergeState(config, configPath, sources, prefer)
+	fmt.Fprintf(out, tr("Добавлено в excluded_files: %d, записей файла состояния: %d, журналов запусков: %d\n"), summary.Excluded, summary.States, summary.Runs)
+	return err
+}
+
+// rich state migrate: перевод списка исключений в файл состояния
+func runStateMigrateCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("state migrate", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	migrated, missing, err := migrateExcluded(config, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("Записано в файл состояния: %d\n"), migrated)
+	for _, file := range missing {
+		fmt.Fprintf(out, tr("  пропущен %s: входного файла нет\n"), file)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreferIncoming(t *testing.T) {
+	old := manifestEntry{Hash: "a", Status: StatusEnriched, UpdatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	newer := manifestEntry{Hash: "b", Status: StatusFailed, UpdatedAt: old.UpdatedAt.Add(time.Hour)}
+	tests := []struct {
+		prefer        string
+		cur, incoming manifestEntry
+		want          bool
+	}{
+		{PreferNewer, old, newer, true},
+		{PreferNewer, newer, old, false},
+		{PreferNewer, old, old, false},
+		{PreferOurs, old, newer, false},
+		{PreferTheirs, newer, old, true},
+		{PreferTheirs, old, old, false},
+	}
+	for _, tt := range tests {
+		if got := preferIncoming(tt.prefer, tt.cur, tt.incoming); got != tt.want {
+			t.Errorf("preferIncoming(%s, %s, %s) = %v, ожидалось %v", tt.prefer, tt.cur.Hash, tt.incoming.Hash, got, tt.want)
+		}
+	}
+	if err := validatePrefer("latest"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного правила")
+	}
+}
+
+// Выгрузка состояния одной машины и загрузка на другой; перевод списка исключений
+// в файл состояния
+func TestStateExportImportMigrate(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig := func(name, excluded string) string {
+		t.Helper()
+		work := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(work, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(work, "rich.cfg")
+		cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + filepath.Join(work, "output") +
+			"\n[STATE]\ndir = " + filepath.Join(work, ".rich") + "\n[EXCLUSIONS]\nexcluded_files = " + excluded + "\n"
+		if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Старая машина: только excluded_files, файла состояния нет
+	oldConfig := writeConfig("old", "a.md, b.md, gone.md")
+	var out bytes.Buffer
+	if err := runStateMigrateCommand([]string{"--config", oldConfig}, &out); err != nil {
+		t.Fatalf("rich state migrate вернул ошибку: %v", err)
+	}
+	if !strings.Contains(out.String(), "gone.md") {
+		t.Errorf("Файл без входного файла должен быть указан:\n%s", out.String())
+	}
+	config, _ := loadConfig(oldConfig)
+	manifest, _ := loadStateManifest(config.StateDir)
+	if len(manifest.Files) != 2 || manifest.Files["a.md"].Hash != contentHash([]byte("# a.md")) {
+		t.Errorf("Неожиданные записи файла состояния: %+v", manifest.Files)
+	}
+
+	bundlePath := filepath.Join(tmpDir, "state.json")
+	out.Reset()
+	if err := runStateExportCommand([]string{"--config", oldConfig, "--out", bundlePath}, &out); err != nil {
+		t.Fatalf("rich state export вернул ошибку: %v", err)
+	}
+
+	// Новая машина получает состояние старой
+	newConfig := writeConfig("new", "")
+	out.Reset()
+	if err := runStateImportCommand([]string{"--config", newConfig, bundlePath}, &out); err != nil {
+		t.Fatalf("rich state import вернул ошибку: %v", err)
+	}
+	imported, _ := loadConfig(newConfig)
+	if strings.Join(imported.ExcludedFiles, ",") != "a.md,b.md,gone.md" {
+		t.Errorf("Неожиданный список исключений: %v", imported.ExcludedFiles)
+	}
+	manifest, _ = loadStateManifest(imported.StateDir)
+	if len(manifest.Files) != 2 {
+		t.Errorf("Записи файла состояния не перенесены: %+v", manifest.Files)
+	}
+	if err := runStateImportCommand([]string{"--config", newConfig, "--prefer", "latest", bundlePath}, &out); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного --prefer")
+	}
+}
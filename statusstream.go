@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// События потока состояния
+const (
+	EventRunStarted   = "run_started"
+	EventFileStarted  = "file_started"
+	EventFileFinished = "file_finished"
+	// Запрос к API для файла все еще ждет ответа: время ожидания в duration_ms
+	EventFileWaiting = "file_waiting"
+	EventRunFinished = "run_finished"
+	// Оповещение о квоте ([ALERTS]): вид оповещения в status
+	EventAlert = "alert"
+)
+
+// Событие потока состояния: одна строка JSON (NDJSON)
+type statusEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	RunID string    `json:"run_id,omitempty"`
+	// Файл: путь в общем состоянии запуска, статус, ошибка и ее категория
+	Path          string `json:"path,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Error         string `json:"error,omitempty"`
+	ErrorCategory string `json:"error_category,omitempty"`
+	// Абсолютный путь записанного результата (при конфликте - файла с новым обогащением)
+	Output string `json:"output,omitempty"`
+	// Текст оповещения
+	Message string `json:"message,omitempty"`
+	// Израсходованные токены, стоимость и длительность обработки файла
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	CostUSD          float64 `json:"cost_usd,omitempty"`
+	DurationMS       int64   `json:"duration_ms,omitempty"`
+	// Итоги запуска
+	Enriched int `json:"enriched,omitempty"`
+	Skipped  int `json:"skipped,omitempty"`
+	Failed   int `json:"failed,omitempty"`
+}
+
+// Поток состояния обработки для оркестраторов: события файлов по мере обработки
+type statusStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	out io.WriteCloser
+	// Поток пишется в стандартный вывод
+	stdout bool
+	// Получатель событий внутри процесса (счетчики режима наблюдения); nil - нет
+	observe func(statusEvent)
+}
+
+// Поток состояния процесса; nil - события не выводятся
+var statusEvents *statusStream
+
+// Открытие потока состояния: "-" или stdout - стандартный вывод, stderr, fd:N -
+// открытый дескриптор (например, переданный оркестратором), иначе путь файла
+// (события дописываются в конец)
+func openStatusStream(target string) (*statusStream, error) {
+	var out io.WriteCloser
+	switch {
+	case target == "-" || target == "stdout":
+		out = nopWriteCloser{os.Stdout}
+	case target == "stderr":
+		out = nopWriteCloser{os.Stderr}
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 3 {
+			return nil, errorf("некорректный дескриптор потока состояния: %s (ожидается fd:N, N >= 3)", target)
+		}
+		file := os.NewFile(uintptr(fd), target)
+		if file == nil {
+			return nil, errorf("некорректный дескриптор потока состояния: %s (ожидается fd:N, N >= 3)", target)
+		}
+		out = file
+	default:
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, errorf("не удалось открыть поток состояния: %v", err)
+		}
+		out = file
+	}
+	return &statusStream{enc: json.NewEncoder(out), out: out, stdout: target == "-" || target == "stdout"}, nil
+}
+
+// Стандартный вывод занят потоком состояния: журнал и консоль переносятся в stderr
+func (s *statusStream) claimStdout(noColor bool) {
+	if s.stdout {
+		releaseStdout(noColor)
+	}
+}
+
+// Запись события; ошибки записи не прерывают обработку
+func (s *statusStream) Emit(ev statusEvent) {
+	if s == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.observe != nil {
+		s.observe(ev)
+	}
+	if s.enc == nil {
+		return
+	}
+	if err := s.enc.Encode(ev); err != nil {
+		logf("Предупреждение: не удалось записать событие в поток состояния: %v", err)
+	}
+}
+
+// Передача событий получателю внутри процесса; без потока состояния события
+// только передаются получателю
+func observeStatusEvents(fn func(statusEvent)) {
+	if statusEvents == nil {
+		statusEvents = &statusStream{}
+	}
+	statusEvents.observe = fn
+}
+
+// Закрытие потока состояния
+func (s *statusStream) Close() error {
+	if s == nil || s.out == nil {
+		return nil
+	}
+	return s.out.Close()
+}
+
+// Событие окончания обработки файла
+func fileFinishedEvent(config *Config, key, outputPath string, result *fileResult, err error) statusEvent {
+	ev := statusEvent{Event: EventFileFinished, Path: normalizeRelPath(key), Status: result.Status,
+		PromptTokens: result.Usage.PromptTokens, CompletionTokens: result.Usage.CompletionTokens,
+		CostUSD: result.Usage.Cost(config), DurationMS: result.Duration.Milliseconds()}
+	switch {
+	case result.Conflict != "":
+		ev.Output = result.Conflict
+	case result.OutputHash != "":
+		ev.Output = outputPath
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		ev.ErrorCategory = errorCategory(err)
+	}
+	return ev
+}
+
+// Запись без закрытия (стандартные потоки процесса)
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
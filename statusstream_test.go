@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusStream(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"a.md": "# Заметка", "b.md": ""} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}], "usage": {"prompt_tokens": 10, "completion_tokens": 5}}`))
+	}))
+	defer server.Close()
+
+	streamPath := filepath.Join(tmpDir, "events.ndjson")
+	stream, err := openStatusStream(streamPath)
+	if err != nil {
+		t.Fatalf("openStatusStream() вернул ошибку: %v", err)
+	}
+	statusEvents = stream
+	t.Cleanup(func() { statusEvents = nil })
+
+	// Без отдельного этапа записи события файлов идут строго по очереди
+	config := &Config{InputDir: inputDir, OutputDir: filepath.Join(tmpDir, "output"),
+		ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible}
+	if err := processDirectory(config, filepath.Join(tmpDir, "test.cfg")); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(streamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var events []statusEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ev statusEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("строка потока состояния не является JSON: %q", scanner.Text())
+		}
+		events = append(events, ev)
+	}
+
+	// Начало запуска, начало и окончание каждого файла, итоги запуска
+	expected := []struct{ event, path, status string }{
+		{EventRunStarted, "", ""},
+		{EventFileStarted, "a.md", ""},
+		{EventFileFinished, "a.md", StatusEnriched},
+		{EventFileStarted, "b.md", ""},
+		{EventFileFinished, "b.md", StatusSkippedTooSmall},
+		{EventRunFinished, "", ""},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("получено событий %d, ожидалось %d: %+v", len(events), len(expected), events)
+	}
+	for i, e := range expected {
+		if events[i].Event != e.event || events[i].Path != e.path || events[i].Status != e.status || events[i].Time.IsZero() {
+			t.Errorf("событие %d: %+v, ожидалось %+v", i, events[i], e)
+		}
+	}
+	if events[2].PromptTokens != 10 || events[2].CompletionTokens != 5 {
+		t.Errorf("токены файла в событии: %+v", events[2])
+	}
+	if last := events[len(events)-1]; last.Enriched != 1 || last.Skipped != 1 || last.Failed != 0 {
+		t.Errorf("итоги запуска в событии: %+v", last)
+	}
+
+	for _, target := range []string{"fd:1", "fd:x"} {
+		if _, err := openStatusStream(target); err == nil {
+			t.Errorf("ожидалась ошибка для %s", target)
+		}
+	}
+}
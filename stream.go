@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Размер очереди файлов между обходом директории и обработкой: обход опережает
+// обработку не больше чем на столько файлов
+const candidateQueueSize = 1024
+
+// Обход прерван, потому что обработка остановлена раньше его окончания
+var errStopWalk = errors.New("обход директории остановлен")
+
+// Список исключенных файлов для поиска при обходе: отсортированные ключи путей без
+// отдельного множества (список уже хранится в конфигурации)
+type excludedIndex []string
+
+// Построение индекса исключенных файлов
+func newExcludedIndex(files []string) excludedIndex {
+	index := make(excludedIndex, 0, len(files))
+	for _, file := range files {
+		if strings.TrimSpace(file) != "" {
+			index = append(index, pathKey(file))
+		}
+	}
+	sort.Strings(index)
+	return slices.Compact(index)
+}
+
+// Проверка, исключен ли файл
+func (x excludedIndex) Contains(relPath string) bool {
+	_, found := slices.BinarySearch(x, pathKey(relPath))
+	return found
+}
+
+// Потоковая обработка возможна, если порядку не нужен полный список файлов:
+// алфавитный порядок (порядок обхода) без правил приоритета и поиска похожих документов
+func (c *Config) streamCandidates() bool {
+	return c.Order == OrderAlphabetical && len(c.Priorities) == 0 && c.DedupThreshold <= 0
+}
+
+// Очередь файлов на обработку: готовый список или поток от обхода директории
+// с окном просмотра вперед для пакетной обработки
+type candidateQueue struct {
+	buf    []candidate
+	stream <-chan candidate
+	// Обход закончен и все найденные файлы перенесены в buf
+	drained bool
+	stop    chan struct{}
+	walked  chan error
+}
+
+// Очередь из готового списка файлов
+func newCandidateList(candidates []candidate) *candidateQueue {
+	return &candidateQueue{buf: candidates, drained: true}
+}
+
+// Очередь с обходом директории в отдельной горутине: в памяти находится не больше
+// candidateQueueSize найденных, но еще не обработанных файлов
+func newCandidateStream(config *Config, inputDir, outputDir string, excluded excludedIndex) *candidateQueue {
+	stream := make(chan candidate, candidateQueueSize)
+	q := &candidateQueue{stream: stream, stop: make(chan struct{}), walked: make(chan error, 1)}
+	go func() {
+		defer close(stream)
+		q.walked <- walkCandidates(config, inputDir, outputDir, excluded, func(c candidate) error {
+			select {
+			case stream <- c:
+				return nil
+			case <-q.stop:
+				return errStopWalk
+			}
+		})
+	}()
+	return q
+}
+
+// Следующий файл и окно из него и не более lookahead-1 следующих файлов
+func (q *candidateQueue) Next(lookahead int) (candidate, []candidate, bool) {
+	for !q.drained && len(q.buf) < lookahead {
+		c, ok := <-q.stream
+		if !ok {
+			q.drained = true
+			break
+		}
+		q.buf = append(q.buf, c)
+	}
+	if len(q.buf) == 0 {
+		return candidate{}, nil, false
+	}
+	window := slices.Clone(q.buf[:min(lookahead, len(q.buf))])
+	q.buf = q.buf[1:]
+	return window[0], window, true
+}
+
+// Завершение очереди: остановка обхода, если обработка закончилась раньше, и ошибка обхода
+func (q *candidateQueue) Close() error {
+	if q.walked == nil {
+		return nil
+	}
+	close(q.stop)
+	for range q.stream {
+	}
+	if err := <-q.walked; err != nil && !errors.Is(err, errStopWalk) {
+		return err
+	}
+	return nil
+}
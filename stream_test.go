@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCandidateStream(t *testing.T) {
+	inputDir := t.TempDir()
+	total := candidateQueueSize + 200
+	for i := 0; i < total; i++ {
+		dir := filepath.Join(inputDir, fmt.Sprintf("d%02d", i%10))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("n%04d.md", i)), []byte("текст"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	excluded := newExcludedIndex([]string{"d00/n0000.md", " ", `d01\n0001.md`, "d00/n0000.md"})
+	if len(excluded) != 2 || !excluded.Contains("d01/n0001.md") || excluded.Contains("d02/n0002.md") {
+		t.Fatalf("некорректный индекс исключений: %v", excluded)
+	}
+
+	config := &Config{Order: OrderAlphabetical}
+	if !config.streamCandidates() {
+		t.Fatal("алфавитный порядок без приоритетов должен обрабатываться потоком")
+	}
+	list, err := collectCandidates(config, inputDir, t.TempDir(), excluded)
+	if err != nil {
+		t.Fatalf("collectCandidates() вернул ошибку: %v", err)
+	}
+
+	// Поток выдает те же файлы в том же порядке, окно начинается с текущего файла
+	queue := newCandidateStream(config, inputDir, t.TempDir(), excluded)
+	for i := 0; ; i++ {
+		c, window, ok := queue.Next(batchLookahead)
+		if !ok {
+			if i != len(list) || i != total-2 {
+				t.Errorf("получено файлов %d, ожидалось %d", i, len(list))
+			}
+			break
+		}
+		if c.RelPath != list[i].RelPath || window[0].RelPath != c.RelPath || len(window) != min(batchLookahead, len(list)-i) {
+			t.Fatalf("файл %d: %s (окно %d), ожидалось %s", i, c.RelPath, len(window), list[i].RelPath)
+		}
+	}
+	if err := queue.Close(); err != nil {
+		t.Errorf("Close() вернул ошибку: %v", err)
+	}
+
+	// Остановка обработки раньше окончания обхода не блокирует обход
+	queue = newCandidateStream(config, inputDir, t.TempDir(), excluded)
+	if _, _, ok := queue.Next(1); !ok {
+		t.Fatal("поток не выдал ни одного файла")
+	}
+	if err := queue.Close(); err != nil {
+		t.Errorf("Close() после остановки вернул ошибку: %v", err)
+	}
+
+	// Ошибка обхода возвращается при закрытии очереди
+	queue = newCandidateStream(config, filepath.Join(inputDir, "нет"), t.TempDir(), nil)
+	if _, _, ok := queue.Next(1); ok {
+		t.Error("поток несуществующей директории не должен выдавать файлы")
+	}
+	if err := queue.Close(); err == nil {
+		t.Error("ожидалась ошибка обхода несуществующей директории")
+	}
+}
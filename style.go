@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/ini.v1"
+)
+
+// Проверка запятой перед последним элементом перечисления (Oxford comma)
+const (
+	OxfordCommaOff     = "off"
+	OxfordCommaRequire = "require"
+	OxfordCommaForbid  = "forbid"
+)
+
+// Правила механической проверки стиля
+const (
+	StyleRuleSentenceCase = "sentence_case"
+	StyleRuleOxfordComma  = "oxford_comma"
+	StyleRuleBannedWord   = "banned_word"
+)
+
+// Перечисления из трех однословных элементов: "red, green and blue" и "red, green, and blue".
+// Проверяются только английские союзы and и or
+var (
+	missingOxfordComma = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])[\p{L}\p{N}-]+, [\p{L}\p{N}-]+ (?:and|or) [\p{L}\p{N}]`)
+	extraOxfordComma   = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])[\p{L}\p{N}-]+, [\p{L}\p{N}-]+, (?:and|or) [\p{L}\p{N}]`)
+)
+
+// Код в обратных кавычках и адреса ссылок не проверяются
+var (
+	inlineCodePattern = regexp.MustCompile("`[^`]*`")
+	linkTargetPattern = regexp.MustCompile(`\]\([^)]*\)`)
+)
+
+// Руководство по стилю из секции [STYLE]: текст руководства добавляется к промпту,
+// механические правила проверяются в обогащенном документе
+type StyleConfig struct {
+	// Текст руководства (тон, голос, правила оформления)
+	Guide string
+	// Заголовки с заглавной буквой только в первом слове
+	SentenceCaseHeadings bool
+	// off, require или forbid
+	OxfordComma string
+	// Запрещенные слова и выражения (без учета регистра)
+	BannedWords []string
+	// Имена собственные, которые пишутся с заглавной буквы в любом месте заголовка
+	ProperNouns []string
+}
+
+// Чтение секции [STYLE]; путь guide_file задается относительно файла конфигурации
+func loadStyleConfig(section *ini.Section, configDir string) (StyleConfig, error) {
+	style := StyleConfig{
+		SentenceCaseHeadings: section.Key("sentence_case_headings").MustBool(false),
+		OxfordComma:          strings.ToLower(section.Key("oxford_comma").MustString(OxfordCommaOff)),
+		BannedWords:          splitList(section.Key("banned_words").String()),
+		ProperNouns:          splitList(section.Key("proper_nouns").String()),
+	}
+	switch style.OxfordComma {
+	case OxfordCommaOff, OxfordCommaRequire, OxfordCommaForbid:
+	default:
+		return style, errorf("неизвестное значение oxford_comma %q в секции [STYLE]: ожидалось off, require или forbid", style.OxfordComma)
+	}
+	if file := section.Key("guide_file").String(); file != "" {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(configDir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return style, errorf("не удалось прочитать руководство по стилю: %v", err)
+		}
+		style.Guide = strings.TrimSpace(string(data))
+	}
+	return style, nil
+}
+
+// Включена ли хотя бы одна механическая проверка
+func (s StyleConfig) checksEnabled() bool {
+	return s.SentenceCaseHeadings || s.OxfordComma == OxfordCommaRequire || s.OxfordComma == OxfordCommaForbid || len(s.BannedWords) > 0
+}
+
+// Добавление руководства по стилю после промпта
+func withStyleGuide(prompt, guide string) string {
+	if guide == "" {
+		return prompt
+	}
+	return strings.TrimRight(prompt, "\n") + "\n\nStyle guide (follow its tone, voice and formatting rules):\n\n" + guide
+}
+
+// Нарушение механического правила стиля
+type styleViolation struct {
+	Rule string `json:"rule"`
+	// Номер строки обогащенного документа (с 1)
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// Проверка механических правил в документе; frontmatter и блоки кода пропускаются
+func (s StyleConfig) Check(doc string) []styleViolation {
+	if !s.checksEnabled() {
+		return nil
+	}
+	var banned []*regexp.Regexp
+	for _, w := range s.BannedWords {
+		banned = append(banned, regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(`+regexp.QuoteMeta(w)+`)(?:$|[^\p{L}\p{N}])`))
+	}
+
+	_, body, _ := parseFrontmatter([]byte(doc))
+	first := strings.Count(doc[:len(doc)-len(body)], "\n") + 1
+	var violations []styleViolation
+	inFence := false
+	for i, line := range strings.Split(string(body), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || trimmed == "" || strings.HasPrefix(trimmed, "<!--") {
+			continue
+		}
+		n := first + i
+		text := linkTargetPattern.ReplaceAllString(inlineCodePattern.ReplaceAllString(trimmed, ""), "]")
+
+		if isHeadingLine(trimmed) {
+			if s.SentenceCaseHeadings && !isSentenceCase(headingText(text), s.ProperNouns) {
+				violations = append(violations, styleViolation{Rule: StyleRuleSentenceCase, Line: n, Text: trimmed})
+			}
+		} else {
+			switch {
+			case s.OxfordComma == OxfordCommaRequire && missingOxfordComma.MatchString(text):
+				violations = append(violations, styleViolation{Rule: StyleRuleOxfordComma, Line: n, Text: snippet(trimmed, searchSnippetRunes)})
+			case s.OxfordComma == OxfordCommaForbid && extraOxfordComma.MatchString(text):
+				violations = append(violations, styleViolation{Rule: StyleRuleOxfordComma, Line: n, Text: snippet(trimmed, searchSnippetRunes)})
+			}
+		}
+		for _, re := range banned {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				violations = append(violations, styleViolation{Rule: StyleRuleBannedWord, Line: n, Text: m[1]})
+			}
+		}
+	}
+	return violations
+}
+
+// Текст заголовка без символов # и разметки ссылок
+func headingText(line string) string {
+	text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+	text = strings.TrimSpace(strings.TrimRight(text, "#"))
+	return strings.NewReplacer("[", "", "]", "", "*", "", "_", " ").Replace(text)
+}
+
+// Заголовок в sentence case: первое слово с заглавной буквы, остальные слова, кроме
+// аббревиатур, слов со смешанным регистром (GitHub), имен собственных и слов после
+// двоеточия или точки, со строчной
+func isSentenceCase(text string, properNouns []string) bool {
+	words := strings.Fields(text)
+	first := true
+	for i, word := range words {
+		w := strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		runes := []rune(w)
+		if len(runes) == 0 {
+			continue
+		}
+		if !unicode.IsLetter(runes[0]) || isProperNoun(w, properNouns) {
+			first = false
+			continue
+		}
+		upperRest := false
+		for _, r := range runes[1:] {
+			upperRest = upperRest || unicode.IsUpper(r)
+		}
+		if upperRest {
+			// Аббревиатура или слово со смешанным регистром
+			first = false
+			continue
+		}
+		if first {
+			if unicode.IsLower(runes[0]) {
+				return false
+			}
+			first = false
+			continue
+		}
+		if unicode.IsUpper(runes[0]) && len(runes) > 1 && i > 0 && !strings.HasSuffix(words[i-1], ":") && !strings.HasSuffix(words[i-1], ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// Слово из списка имен собственных
+func isProperNoun(word string, properNouns []string) bool {
+	for _, p := range properNouns {
+		if strings.EqualFold(word, p) {
+			return true
+		}
+	}
+	return false
+}
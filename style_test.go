@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestIsSentenceCase(t *testing.T) {
+	nouns := []string{"Kafka"}
+	for text, want := range map[string]bool{
+		"Настройка брокера":            true,
+		"Настройка Брокера":            false,
+		"Getting started with Kafka":   true,
+		"Getting Started":              false,
+		"Using the API and GitHub":     true,
+		"lowercase start":              false,
+		"2024 roadmap":                 true,
+		"Part 1: Introduction to jobs": true,
+	} {
+		if got := isSentenceCase(text, nouns); got != want {
+			t.Errorf("isSentenceCase(%q) = %v, ожидалось %v", text, got, want)
+		}
+	}
+}
+
+func TestStyleCheck(t *testing.T) {
+	style := StyleConfig{SentenceCaseHeadings: true, OxfordComma: OxfordCommaRequire, BannedWords: []string{"simply", "в общем-то"}}
+	doc := "---\ntitle: Test\n---\n# Getting Started\n\nSimply install red, green and blue packages.\n\n```\nsimply ignored, in and code\n```\n\nЭто, в общем-то, всё. Use `simply` in code.\n\nPick red, green, and blue.\n"
+	got := style.Check(doc)
+	want := []styleViolation{
+		{Rule: StyleRuleSentenceCase, Line: 4, Text: "# Getting Started"},
+		{Rule: StyleRuleOxfordComma, Line: 6, Text: "Simply install red, green and blue packages."},
+		{Rule: StyleRuleBannedWord, Line: 6, Text: "Simply"},
+		{Rule: StyleRuleBannedWord, Line: 12, Text: "в общем-то"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("нарушения:\n%+v\nожидалось\n%+v", got, want)
+	}
+
+	style = StyleConfig{OxfordComma: OxfordCommaForbid}
+	if got := style.Check("Pick red, green, and blue.\n\nPick red, green and blue.\n"); len(got) != 1 || got[0].Line != 1 {
+		t.Errorf("лишняя запятая перед and: %+v", got)
+	}
+}
+
+func TestLoadStyleGuide(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "style.md"), []byte("Пишите коротко.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ini.Empty()
+	section := cfg.Section("STYLE")
+	section.Key("guide_file").SetValue("style.md")
+	section.Key("banned_words").SetValue("simply, just")
+	style, err := loadStyleConfig(section, dir)
+	if err != nil {
+		t.Fatalf("loadStyleConfig() вернул ошибку: %v", err)
+	}
+	if style.Guide != "Пишите коротко." || !reflect.DeepEqual(style.BannedWords, []string{"simply", "just"}) {
+		t.Errorf("настройки стиля: %+v", style)
+	}
+	prompt := withStyleGuide("Обогати документ.", style.Guide)
+	if !strings.HasPrefix(prompt, "Обогати документ.\n\n") || !strings.HasSuffix(prompt, "\n\nПишите коротко.") {
+		t.Errorf("промпт с руководством: %q", prompt)
+	}
+
+	section.Key("oxford_comma").SetValue("sometimes")
+	if _, err := loadStyleConfig(section, dir); err == nil {
+		t.Error("ожидалась ошибка для неизвестного значения oxford_comma")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Вариант промпта для сравнения
+type sweepPrompt struct {
+	Name string `json:"name"`
+	// Текст промпта ("" - промпт из конфигурации с учетом маршрутов и языка)
+	Text string `json:"-"`
+	Path string `json:"path,omitempty"`
+}
+
+// Результат обогащения файла одним вариантом
+type sweepResult struct {
+	File        string          `json:"file"`
+	Prompt      string          `json:"prompt"`
+	Temperature float64         `json:"temperature"`
+	Output      string          `json:"output,omitempty"`
+	Usage       Usage           `json:"usage"`
+	CostUSD     float64         `json:"cost_usd,omitempty"`
+	Metrics     *qualityMetrics `json:"metrics,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Итоги сравнения
+type sweepSummary struct {
+	CreatedAt    time.Time     `json:"created_at"`
+	Model        string        `json:"model"`
+	Prompts      []sweepPrompt `json:"prompts"`
+	Temperatures []float64     `json:"temperatures"`
+	Files        []string      `json:"files"`
+	Results      []sweepResult `json:"results"`
+}
+
+// Разбор списка температур через запятую
+func parseTemperatures(value string) ([]float64, error) {
+	var temps []float64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, err := strconv.ParseFloat(part, 64)
+		if err != nil || t < 0 || t > 2 {
+			return nil, errorf("некорректная температура: %q", part)
+		}
+		temps = append(temps, t)
+	}
+	return temps, nil
+}
+
+// Чтение вариантов промпта из файлов; имя варианта - имя файла без расширения
+func loadSweepPrompts(value string) ([]sweepPrompt, error) {
+	var prompts []sweepPrompt
+	seen := make(map[string]bool)
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errorf("не удалось прочитать промпт %s: %v", path, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		for base, n := name, 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		seen[name] = true
+		prompts = append(prompts, sweepPrompt{Name: name, Text: string(data), Path: path})
+	}
+	return prompts, nil
+}
+
+// Случайная (воспроизводимая при одинаковом seed) выборка файлов
+func sampleCandidates(cands []candidate, n int, seed uint64) []candidate {
+	if n <= 0 || n >= len(cands) {
+		return cands
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+	perm := r.Perm(len(cands))
+	sample := make([]candidate, 0, n)
+	for _, i := range perm[:n] {
+		sample = append(sample, cands[i])
+	}
+	sort.Slice(sample, func(a, b int) bool { return sample[a].RelPath < sample[b].RelPath })
+	return sample
+}
+
+// Имя поддиректории варианта в наборе результатов
+func sweepVariantDir(prompt string, temperature float64) string {
+	return filepath.Join(prompt, "t"+strconv.FormatFloat(temperature, 'f', -1, 64))
+}
+
+// Обогащение выборки файлов всеми сочетаниями промптов и температур с сохранением
+// результатов в outDir. Выходная директория и список исключенных файлов не изменяются
+func runSweep(config *Config, files []candidate, prompts []sweepPrompt, temps []float64, outDir string, limiter *RateLimiter) (*sweepSummary, error) {
+	summary := &sweepSummary{CreatedAt: time.Now(), Model: config.ModelName, Prompts: prompts, Temperatures: temps}
+
+	for _, c := range files {
+		content, err := readMarkdownFile(c.Path)
+		if err != nil {
+			return nil, errorf("ошибка при чтении файла: %v", err)
+		}
+		if err := validateContentSize(content, config.maxFileSize()); err != nil {
+			logf("Пропуск %s: %v", c.RelPath, err)
+			continue
+		}
+		summary.Files = append(summary.Files, c.RelPath)
+		// Распакованная сжатая заметка сохраняется в markdown
+		name := c.RelPath
+		if isGzipMarkdownPath(name) {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		if err := writeSweepFile(filepath.Join(outDir, "original", name), content); err != nil {
+			return nil, err
+		}
+
+		fileConfig, _, lang := resolveFileConfig(config, c.RelPath, content)
+		for _, p := range prompts {
+			variant := fileConfig
+			if p.Text != "" {
+				variant.Prompt = p.Text
+				variant.LanguagePrompts = nil
+				variant.Prompt = variant.File.Expand(promptForLanguage(&variant, lang))
+			}
+			for _, t := range temps {
+				variant.Temperature = t
+				logf("Сравнение: %s, промпт %s, температура %g", c.RelPath, p.Name, t)
+
+				result := sweepResult{File: c.RelPath, Prompt: p.Name, Temperature: t}
+				enriched, usage, err := enrichContentWithUsage(&variant, string(content), limiter)
+				result.Usage = usage
+				result.CostUSD = usage.Cost(config)
+				if err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Output = filepath.ToSlash(filepath.Join(sweepVariantDir(p.Name, t), name))
+					result.Metrics = compareMetrics(string(content), enriched, lang)
+					if err := writeSweepFile(filepath.Join(outDir, result.Output), []byte(enriched)); err != nil {
+						return nil, err
+					}
+				}
+				summary.Results = append(summary.Results, result)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return nil, errorf("ошибка при подготовке итогов: %v", err)
+	}
+	if err := writeSweepFile(filepath.Join(outDir, "summary.json"), data); err != nil {
+		return nil, err
+	}
+	if err := writeSweepFile(filepath.Join(outDir, "index.md"), []byte(renderSweepIndex(summary))); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Запись файла набора результатов
+func writeSweepFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errorf("ошибка при создании директории: %v", err)
+	}
+	return safeWriteFile(path, data, 0644)
+}
+
+// Сводная таблица сравнения в markdown: средние по вариантам и результаты по файлам
+func renderSweepIndex(s *sweepSummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, tr("# Сравнение промптов и температур\n\nМодель: %s, файлов: %d, создано: %s\n\n"),
+		s.Model, len(s.Files), s.CreatedAt.Format(time.DateTime))
+
+	b.WriteString(tr("## Средние по вариантам\n\n"))
+	b.WriteString(tr("| Промпт | Температура | Слова Δ | Заголовки Δ | Читаемость Δ | Токены | Стоимость, $ | Ошибки |\n"))
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, p := range s.Prompts {
+		for _, t := range s.Temperatures {
+			var words, headings, readability, cost float64
+			tokens, n, errors := 0, 0, 0
+			for _, r := range s.Results {
+				if r.Prompt != p.Name || r.Temperature != t {
+					continue
+				}
+				if r.Error != "" {
+					errors++
+					continue
+				}
+				n++
+				words += float64(r.Metrics.Delta.Words)
+				headings += float64(r.Metrics.Delta.Headings)
+				readability += r.Metrics.Delta.Readability
+				tokens += r.Usage.PromptTokens + r.Usage.CompletionTokens
+				cost += r.CostUSD
+			}
+			if n > 0 {
+				words, headings, readability = words/float64(n), headings/float64(n), readability/float64(n)
+			}
+			fmt.Fprintf(&b, "| %s | %g | %+.1f | %+.1f | %+.1f | %d | %.4f | %d |\n",
+				p.Name, t, words, headings, readability, tokens, cost, errors)
+		}
+	}
+
+	for _, file := range s.Files {
+		fmt.Fprintf(&b, tr("\n## %s\n\n[Оригинал](%s)\n\n"), file, filepath.ToSlash(filepath.Join("original", file)))
+		b.WriteString(tr("| Промпт | Температура | Слова Δ | Заголовки Δ | Читаемость Δ | Результат |\n"))
+		b.WriteString("|---|---|---|---|---|---|\n")
+		for _, r := range s.Results {
+			if r.File != file {
+				continue
+			}
+			if r.Error != "" {
+				fmt.Fprintf(&b, tr("| %s | %g | - | - | - | ошибка: %s |\n"), r.Prompt, r.Temperature, strings.ReplaceAll(r.Error, "|", "\\|"))
+				continue
+			}
+			fmt.Fprintf(&b, tr("| %s | %g | %+d | %+d | %+.1f | [открыть](%s) |\n"), r.Prompt, r.Temperature,
+				r.Metrics.Delta.Words, r.Metrics.Delta.Headings, r.Metrics.Delta.Readability, r.Output)
+		}
+	}
+	return b.String()
+}
+
+// rich sweep --temperatures 0,0.4,0.8 --prompts a.tmpl,b.tmpl --sample 5
+func runSweepCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	tempsFlag := fs.String("temperatures", "", tr("Температуры через запятую (по умолчанию - из конфигурации)"))
+	promptsFlag := fs.String("prompts", "", tr("Файлы промптов через запятую (по умолчанию - промпт из конфигурации)"))
+	sample := fs.Int("sample", 5, tr("Количество файлов в выборке (0 - все)"))
+	seed := fs.Uint64("seed", 1, tr("Начальное значение для случайной выборки"))
+	outDir := fs.String("out", "", tr("Директория набора результатов (по умолчанию sweep-<время>)"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	temps, err := parseTemperatures(*tempsFlag)
+	if err != nil {
+		return err
+	}
+	if len(temps) == 0 {
+		temps = []float64{config.Temperature}
+	}
+	prompts, err := loadSweepPrompts(*promptsFlag)
+	if err != nil {
+		return err
+	}
+	if len(prompts) == 0 {
+		prompts = []sweepPrompt{{Name: "config"}}
+	}
+	if *outDir == "" {
+		*outDir = "sweep-" + time.Now().Format("20060102-150405")
+	}
+
+	// Выборка из всех файлов входной директории, включая ранее обработанные
+	inputDir, err := filepath.Abs(config.InputDir)
+	if err != nil {
+		return errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+	}
+	cands, err := collectCandidates(config, inputDir, config.OutputDir, nil)
+	if err != nil {
+		return err
+	}
+	files := sampleCandidates(cands, *sample, *seed)
+	if len(files) == 0 {
+		return errorf("во входной директории нет файлов для сравнения")
+	}
+
+	fmt.Fprintf(out, tr("Файлов: %d, вариантов: %d, запросов: %d\n"), len(files), len(prompts)*len(temps), len(files)*len(prompts)*len(temps))
+	summary, err := runSweep(config, files, prompts, temps, *outDir, config.newRateLimiter())
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range summary.Results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	fmt.Fprintf(out, tr("Результаты сохранены в %s (index.md, summary.json), ошибок: %d\n"), *outDir, failed)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSampleCandidates(t *testing.T) {
+	var cands []candidate
+	for i := 0; i < 10; i++ {
+		cands = append(cands, candidate{RelPath: fmt.Sprintf("f%d.md", i)})
+	}
+	a := sampleCandidates(cands, 3, 42)
+	b := sampleCandidates(cands, 3, 42)
+	if len(a) != 3 || fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("Выборка должна быть воспроизводимой: %v / %v", a, b)
+	}
+	if len(sampleCandidates(cands, 0, 1)) != 10 || len(sampleCandidates(cands, 20, 1)) != 10 {
+		t.Error("При sample 0 или больше числа файлов выбираются все файлы")
+	}
+}
+
+func TestParseTemperatures(t *testing.T) {
+	temps, err := parseTemperatures("0, 0.4,0.8")
+	if err != nil || fmt.Sprint(temps) != "[0 0.4 0.8]" {
+		t.Errorf("Некорректный разбор: %v, %v", temps, err)
+	}
+	if _, err := parseTemperatures("0,abc"); err == nil {
+		t.Error("Ожидалась ошибка для некорректной температуры")
+	}
+	if _, err := parseTemperatures("3"); err == nil {
+		t.Error("Ожидалась ошибка для температуры вне диапазона")
+	}
+}
+
+func TestSweepCommand(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var request struct {
+			Prompt      string  `json:"prompt"`
+			Temperature float64 `json:"temperature"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Некорректный запрос: %v", err)
+			return
+		}
+		answer := fmt.Sprintf("# Результат\n\n%s при %g", strings.Fields(request.Prompt)[0], request.Temperature)
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": answer}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "in")
+	outputDir := filepath.Join(tmpDir, "out")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Ошибка создания директории: %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# "+name+"\n\nТекст заметки."), 0644); err != nil {
+			t.Fatalf("Ошибка записи файла: %v", err)
+		}
+	}
+	for name, text := range map[string]string{"short.tmpl": "Кратко", "long.tmpl": "Подробно"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(text), 0644); err != nil {
+			t.Fatalf("Ошибка записи промпта: %v", err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir +
+		"\n[EXCLUSIONS]\nexcluded_files = a.md\n[MODEL]\nname = test-model\napi_url = " + server.URL + "/v1/chat/completions\napi_key = k\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatalf("Ошибка записи конфигурации: %v", err)
+	}
+
+	bundle := filepath.Join(tmpDir, "bundle")
+	var out bytes.Buffer
+	args := []string{"-config", configPath, "-temperatures", "0,0.8",
+		"-prompts", filepath.Join(tmpDir, "short.tmpl") + "," + filepath.Join(tmpDir, "long.tmpl"),
+		"-sample", "2", "-out", bundle}
+	if err := runSweepCommand(args, &out); err != nil {
+		t.Fatalf("sweep вернул ошибку: %v", err)
+	}
+	if requests != 8 {
+		t.Errorf("Ожидалось 8 запросов (2 файла x 2 промпта x 2 температуры), получено %d", requests)
+	}
+
+	data, err := os.ReadFile(filepath.Join(bundle, "summary.json"))
+	if err != nil {
+		t.Fatalf("Не найден summary.json: %v", err)
+	}
+	var summary sweepSummary
+	if err := json.Unmarshal(data, &summary); err != nil || len(summary.Results) != 8 || len(summary.Files) != 2 {
+		t.Fatalf("Некорректные итоги: %v, %+v", err, summary)
+	}
+	r := summary.Results[0]
+	output, err := os.ReadFile(filepath.Join(bundle, r.Output))
+	if err != nil {
+		t.Fatalf("Не найден результат варианта: %v", err)
+	}
+	if !strings.Contains(string(output), "Кратко при 0") || r.Prompt != "short" {
+		t.Errorf("Результат не соответствует варианту %s/%g: %s", r.Prompt, r.Temperature, output)
+	}
+
+	index, err := os.ReadFile(filepath.Join(bundle, "index.md"))
+	if err != nil || !strings.Contains(string(index), "| long | 0.8 |") {
+		t.Errorf("Некорректная сводная таблица: %v\n%s", err, index)
+	}
+	// Выходная директория не изменяется
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("sweep не должен записывать в выходную директорию: %d файлов", len(entries))
+	}
+}
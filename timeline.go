@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Хронология обработки файла: моменты чтения, отправки первого запроса, получения
+// последнего ответа и записи результата, длительность этапов в миллисекундах
+type fileTimeline struct {
+	Started  time.Time `json:"started"`
+	Read     time.Time `json:"read,omitzero"`
+	Sent     time.Time `json:"request_sent,omitzero"`
+	Received time.Time `json:"response_received,omitzero"`
+	Written  time.Time `json:"written,omitzero"`
+	// Чтение файла, ожидание ограничителя частоты, ожидание ответов API, запись результата
+	ReadMS  int64 `json:"read_ms"`
+	WaitMS  int64 `json:"wait_ms"`
+	APIMS   int64 `json:"api_ms"`
+	WriteMS int64 `json:"write_ms"`
+}
+
+// Этапы обработки файла для сравнения задержек
+var timelinePhases = []string{"read", "wait", "api", "write", "total"}
+
+// Дополнение хронологии временем запросов к API
+func (t fileTimeline) withRequests(timing requestTiming) fileTimeline {
+	t.Sent, t.Received = timing.Sent, timing.Received
+	t.WaitMS, t.APIMS = timing.Wait.Milliseconds(), timing.API.Milliseconds()
+	return t
+}
+
+// Перцентили длительности этапа по файлам, мс
+type latencyPercentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+// Перцентиль по ближайшему рангу в отсортированных значениях
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// Перцентили задержек этапов по файлам, для которых этап выполнялся: чтение и общее
+// время - для всех файлов, ожидание и API - для файлов с запросами, запись - для записанных
+func latencyBreakdown(files []reportEntry) map[string]latencyPercentiles {
+	samples := map[string][]int64{}
+	for _, e := range files {
+		if e.Timeline == nil {
+			continue
+		}
+		samples["read"] = append(samples["read"], e.Timeline.ReadMS)
+		if !e.Timeline.Sent.IsZero() {
+			samples["wait"] = append(samples["wait"], e.Timeline.WaitMS)
+			samples["api"] = append(samples["api"], e.Timeline.APIMS)
+		}
+		if !e.Timeline.Written.IsZero() {
+			samples["write"] = append(samples["write"], e.Timeline.WriteMS)
+		}
+		samples["total"] = append(samples["total"], e.DurationMS)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	breakdown := map[string]latencyPercentiles{}
+	for phase, values := range samples {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		breakdown[phase] = latencyPercentiles{
+			P50: percentile(values, 50),
+			P90: percentile(values, 90),
+			P99: percentile(values, 99),
+			Max: values[len(values)-1],
+		}
+	}
+	return breakdown
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProcessFileTimeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Итог\n\nОбогащенный текст"}}], "usage": {"prompt_tokens": 10, "completion_tokens": 5}}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "note.md")
+	outputPath := filepath.Join(tmpDir, "out", "note.md")
+	if err := os.WriteFile(inputPath, []byte("# Заметка\n\nТекст заметки."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: tmpDir, OutputDir: filepath.Join(tmpDir, "out"), ModelName: "test",
+		ModelAPIURL: server.URL, Provider: providerOpenAICompatible, Prompt: "Обогати"}
+
+	result, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000)))
+	if err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	timeline := result.Timeline.withRequests(result.Usage.Timing)
+	steps := []time.Time{timeline.Started, timeline.Read, timeline.Sent, timeline.Received, timeline.Written}
+	for i := 1; i < len(steps); i++ {
+		if steps[i].IsZero() || steps[i].Before(steps[i-1]) {
+			t.Fatalf("некорректная хронология обработки: %+v", timeline)
+		}
+	}
+	if timeline.APIMS < 20 {
+		t.Errorf("время ответа API %d мс, ожидалось не меньше 20", timeline.APIMS)
+	}
+
+	// Перцентили по файлам: файлы без запросов не учитываются в ожидании и API
+	report := newRunReport()
+	report.Add(config, "note.md", result, nil)
+	for i := int64(1); i <= 9; i++ {
+		report.Add(config, "skip.md", &fileResult{Status: StatusSkippedTooSmall,
+			Timeline: fileTimeline{Started: time.Now(), ReadMS: i}}, nil)
+	}
+	report.finish()
+	latency := report.Totals.Latency
+	if latency["api"].P50 != timeline.APIMS || latency["read"].P90 != 8 || latency["read"].Max != 9 {
+		t.Errorf("некорректные перцентили задержек: %+v", latency)
+	}
+	if _, ok := latency["write"]; !ok {
+		t.Errorf("нет задержки записи: %+v", latency)
+	}
+}
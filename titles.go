@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gopkg.in/ini.v1"
+)
+
+// Шаблон имени переименованного файла по умолчанию и файл соответствий в каталоге состояния
+const (
+	defaultSlugTemplate = "{{slug}}"
+	titlesFileName      = "titles.json"
+)
+
+// Максимальная длина slug в символах
+const maxSlugLength = 60
+
+// Инструкция модели для подбора заголовка и имени файла
+const titleInstruction = `Propose a concise, descriptive title for the markdown document below and a short slug for its file name. Write the title in the language of the document. The slug uses lowercase latin letters, digits and hyphens (transliterate if needed). Reply with a single JSON object {"title": "...", "slug": "..."} and nothing else.`
+
+// Подбор заголовков и имен файлов из секции [TITLES]
+type TitleConfig struct {
+	// Запрашивать у модели заголовок для обогащенного документа
+	Enabled bool
+	// Переименовывать выходной файл по предложенному slug
+	Rename bool
+	// Шаблон имени файла без расширения: {{slug}}, {{name}}, {{date}}, {{lang}}
+	SlugTemplate string
+	// Файл соответствий исходных файлов и новых имен ("" - titles.json в каталоге состояния)
+	MappingFile string
+}
+
+// Чтение секции [TITLES]
+func loadTitleConfig(section *ini.Section) (TitleConfig, error) {
+	titles := TitleConfig{
+		Enabled:      section.Key("enabled").MustBool(false),
+		Rename:       section.Key("rename").MustBool(false),
+		SlugTemplate: section.Key("slug_template").MustString(defaultSlugTemplate),
+		MappingFile:  stripLongPathPrefix(section.Key("mapping_file").String()),
+	}
+	if !strings.Contains(titles.SlugTemplate, "{{slug}}") {
+		return titles, errorf("slug_template в секции [TITLES] должен содержать {{slug}}: %q", titles.SlugTemplate)
+	}
+	if strings.ContainsAny(titles.SlugTemplate, `/\`) {
+		return titles, errorf("slug_template в секции [TITLES] не может содержать разделители пути: %q", titles.SlugTemplate)
+	}
+	return titles, nil
+}
+
+// Заголовок и slug, предложенные моделью
+type titleProposal struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+// Запрос заголовка и slug для обогащенного документа
+func proposeTitle(config *Config, doc string, limiter *RateLimiter) (titleProposal, Usage, error) {
+	titleConfig := *config
+	titleConfig.Prompt = titleInstruction
+	// Примеры и правила ответа относятся к обогащению, а не к подбору заголовка
+	titleConfig.Examples = nil
+	titleConfig.OutputRules = outputRules{}
+	response, usage, err := enrichContentWithUsage(&titleConfig, doc, limiter)
+	if err != nil {
+		return titleProposal{}, usage, err
+	}
+	proposal, err := parseTitleProposal(response)
+	return proposal, usage, err
+}
+
+// Разбор ответа модели: JSON объект, возможно окруженный текстом или блоком кода
+func parseTitleProposal(response string) (titleProposal, error) {
+	var proposal titleProposal
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return proposal, errorf("в ответе модели нет заголовка: %q", response)
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &proposal); err != nil {
+		return proposal, errorf("некорректный ответ модели с заголовком: %v", err)
+	}
+	proposal.Title = strings.Join(strings.Fields(proposal.Title), " ")
+	if proposal.Title == "" {
+		return proposal, errorf("в ответе модели нет заголовка: %q", response)
+	}
+	proposal.Slug = fileSlug(proposal.Slug)
+	if proposal.Slug == "" {
+		proposal.Slug = fileSlug(proposal.Title)
+	}
+	return proposal, nil
+}
+
+// Slug имени файла: строчные буквы и цифры, остальные символы заменяются одним дефисом
+func fileSlug(s string) string {
+	var b strings.Builder
+	dash := false
+	n := 0
+	for _, r := range strings.ToLower(s) {
+		if n >= maxSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				n++
+			}
+			b.WriteRune(r)
+			n++
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// Имя выходного файла по шаблону с расширением исходного файла
+func renderSlugName(template, slug, relPath, lang string, date time.Time) string {
+	ext := filepath.Ext(relPath)
+	name := strings.TrimSuffix(filepath.Base(relPath), ext)
+	return strings.NewReplacer(
+		"{{slug}}", slug,
+		"{{name}}", name,
+		"{{date}}", date.Format("2006-01-02"),
+		"{{lang}}", lang,
+	).Replace(template) + ext
+}
+
+// Выходной путь переименованного результата: имя по шаблону в той же директории.
+// Если имя занято результатом или исходным файлом другого документа, к нему
+// добавляется номер. Возвращает путь файла и путь в общем состоянии запуска
+func titledOutputPath(config *Config, titles *titleMap, key, relPath, outputPath, slug, lang string) (string, string) {
+	name := renderSlugName(config.Titles.SlugTemplate, slug, relPath, lang, time.Now())
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		candidate := filepath.Join(filepath.Dir(relPath), name)
+		if !samePath(candidate, relPath) {
+			if _, err := os.Stat(filepath.Join(config.InputDir, candidate)); err == nil {
+				continue
+			}
+		}
+		state := rootKey(config.RootName, normalizeRelPath(candidate))
+		if titles.Reserve(key, state) {
+			return filepath.Join(filepath.Dir(outputPath), name), state
+		}
+	}
+}
+
+// Путь результата документа относительно выходной директории корня с учетом
+// переименования по заголовку
+func titledRelPath(config *Config, titles *titleMap, relPath string) string {
+	if titles == nil || !config.Titles.Rename {
+		return relPath
+	}
+	if e, ok := titles.Get(rootKey(config.RootName, relPath)); ok && e.Output != "" {
+		return filepath.Join(filepath.Dir(relPath), filepath.Base(e.Output))
+	}
+	return relPath
+}
+
+// Замена заголовка документа: поле title во frontmatter и заголовок первого уровня.
+// Если нет ни того, ни другого, в начало документа добавляется заголовок первого уровня
+func applyTitle(doc, title string) string {
+	head, body := "", doc
+	titleKey := false
+	if _, rest, ok := parseFrontmatter([]byte(doc)); ok {
+		body = string(rest)
+		lines := strings.SplitAfter(doc[:len(doc)-len(body)], "\n")
+		for i := 1; i < len(lines); i++ {
+			if strings.HasPrefix(lines[i], "title:") {
+				lines[i] = "title: " + strconv.Quote(title) + lineEnding(lines[i])
+				titleKey = true
+				break
+			}
+		}
+		head = strings.Join(lines, "")
+	}
+
+	lines := strings.SplitAfter(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			lines[i] = "# " + title + lineEnding(line)
+			return head + strings.Join(lines, "")
+		}
+	}
+	if titleKey {
+		return head + body
+	}
+	return head + "# " + title + "\n\n" + strings.TrimLeft(body, "\r\n")
+}
+
+// Окончание строки (\n, \r\n или пустое для последней строки)
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// Заголовок и имя выходного файла, записанные для исходного файла
+type titleEntry struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug,omitempty"`
+	// Путь выходного файла в общем состоянии запуска (относительно выходной директории корня)
+	Output    string    `json:"output"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Соответствие исходных файлов и переименованных результатов. Файл, переименованный
+// однажды, сохраняет имя при следующих запусках, чтобы ссылки на него не ломались
+type titleMap struct {
+	mu   sync.Mutex
+	path string
+	// Записи по путям файлов в общем состоянии запуска
+	entries map[string]titleEntry
+	// Владельцы выходных путей (pathKey) - для разрешения совпадений имен
+	owners  map[string]string
+	changed map[string]bool
+}
+
+// Загрузка соответствий; отсутствующий файл - пустое соответствие
+func loadTitleMap(path string) (*titleMap, error) {
+	m := &titleMap{path: path, entries: make(map[string]titleEntry), owners: make(map[string]string), changed: make(map[string]bool)}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, errorf("не удалось прочитать соответствия заголовков: %v", err)
+	}
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		return nil, errorf("некорректный файл соответствий заголовков %s: %v", path, err)
+	}
+	for key, e := range m.entries {
+		m.owners[pathKey(e.Output)] = key
+	}
+	return m, nil
+}
+
+// Запись для исходного файла
+func (m *titleMap) Get(key string) (titleEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[normalizeRelPath(key)]
+	return e, ok
+}
+
+// Закрепление выходного пути за исходным файлом; false - путь занят другим файлом
+func (m *titleMap) Reserve(key, output string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owner := pathKey(output)
+	if k, ok := m.owners[owner]; ok && k != normalizeRelPath(key) {
+		return false
+	}
+	m.owners[owner] = normalizeRelPath(key)
+	return true
+}
+
+// Запись заголовка и выходного пути исходного файла
+func (m *titleMap) Set(key string, e titleEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key = normalizeRelPath(key)
+	e.Output = normalizeRelPath(e.Output)
+	e.UpdatedAt = time.Now().UTC()
+	m.entries[key] = e
+	m.changed[key] = true
+}
+
+// Сохранение измененных записей; записи других экземпляров, сохраненные за время
+// запуска, не теряются
+func (m *titleMap) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.path == "" || len(m.changed) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return errorf("не удалось сохранить соответствия заголовков: %v", err)
+	}
+	return withFileLock(m.path, func() error {
+		entries := make(map[string]titleEntry)
+		if data, err := os.ReadFile(m.path); err == nil {
+			_ = json.Unmarshal(data, &entries)
+		}
+		for key := range m.changed {
+			entries[key] = m.entries[key]
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return errorf("не удалось сохранить соответствия заголовков: %v", err)
+		}
+		if err := safeWriteFile(m.path, data, 0644); err != nil {
+			return errorf("не удалось сохранить соответствия заголовков: %v", err)
+		}
+		m.changed = make(map[string]bool)
+		return nil
+	})
+}
+
+// Путь файла соответствий из настроек
+func (c *Config) titlesFile() string {
+	if c.Titles.MappingFile != "" || c.StateDir == "" {
+		return c.Titles.MappingFile
+	}
+	return filepath.Join(c.StateDir, titlesFileName)
+}
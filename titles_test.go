@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyTitle(t *testing.T) {
+	tests := []struct {
+		name, doc, want string
+	}{
+		{"заголовок первого уровня", "Вступление\n\n# Старый\n\nТекст\n", "Вступление\n\n# Новый: итоги\n\nТекст\n"},
+		{"frontmatter и заголовок", "---\ntitle: Старый\ntags: [a]\n---\n# Старый\nТекст", "---\ntitle: \"Новый: итоги\"\ntags: [a]\n---\n# Новый: итоги\nТекст"},
+		{"только frontmatter", "---\ntitle: Старый\n---\nТекст", "---\ntitle: \"Новый: итоги\"\n---\nТекст"},
+		{"без заголовка", "```\n# не заголовок\n```\n", "# Новый: итоги\n\n```\n# не заголовок\n```\n"},
+		{"перевод строки CRLF", "# Старый\r\nТекст", "# Новый: итоги\r\nТекст"},
+	}
+	for _, tt := range tests {
+		if got := applyTitle(tt.doc, "Новый: итоги"); got != tt.want {
+			t.Errorf("%s: получено %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTitleProposal(t *testing.T) {
+	proposal, err := parseTitleProposal("```json\n{\"title\": \"  Планы  на квартал \", \"slug\": \"Plans_for Q3!\"}\n```")
+	if err != nil {
+		t.Fatalf("parseTitleProposal() вернул ошибку: %v", err)
+	}
+	if proposal.Title != "Планы на квартал" || proposal.Slug != "plans-for-q3" {
+		t.Errorf("разобрано %+v", proposal)
+	}
+	if proposal, _ = parseTitleProposal(`{"title": "Планы на квартал"}`); proposal.Slug != "планы-на-квартал" {
+		t.Errorf("slug по заголовку: %q", proposal.Slug)
+	}
+	if _, err := parseTitleProposal("Не знаю"); err == nil {
+		t.Error("ожидалась ошибка для ответа без JSON")
+	}
+}
+
+func TestTitlesRenameOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	stateDir := filepath.Join(tmpDir, ".rich")
+	for _, dir := range []string{inputDir, outputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Черновик\n\nЗаметка "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Модель предлагает одинаковый заголовок для обоих файлов
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		content := "# Черновик\\n\\nОбогащенный текст"
+		if strings.Contains(string(body), "Propose a concise") {
+			content = `{\"title\": \"Итоги встречи\", \"slug\": \"meeting-notes\"}`
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + content + `"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	writeConfig := func() {
+		if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig()
+	newConfig := func() *Config {
+		return &Config{InputDir: inputDir, OutputDir: outputDir, StateDir: stateDir, WriteQueue: 2,
+			ModelAPIURL: server.URL + "/v1/chat/completions", Provider: providerOpenAICompatible,
+			Titles: TitleConfig{Enabled: true, Rename: true, SlugTemplate: defaultSlugTemplate}}
+	}
+	if err := processDirectory(newConfig(), configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	for _, name := range []string{"meeting-notes.md", "meeting-notes-2.md"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("нет переименованного результата %s: %v", name, err)
+		}
+		if !strings.HasPrefix(string(data), "# Итоги встречи\n") {
+			t.Errorf("заголовок %s не заменен:\n%s", name, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.md")); err == nil {
+		t.Error("результат сохранен и под исходным именем")
+	}
+
+	var mapping map[string]titleEntry
+	data, err := os.ReadFile(filepath.Join(stateDir, titlesFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != 2 || mapping["a.md"].Title != "Итоги встречи" || mapping["a.md"].Output == mapping["b.md"].Output {
+		t.Fatalf("соответствия заголовков: %+v", mapping)
+	}
+
+	// Повторная обработка сохраняет прежнее имя результата
+	renamed := mapping["b.md"].Output
+	writeConfig()
+	if err := processDirectory(newConfig(), configPath); err != nil {
+		t.Fatalf("повторный processDirectory() вернул ошибку: %v", err)
+	}
+	entries, _ := os.ReadDir(outputDir)
+	if len(entries) != 2 {
+		t.Errorf("после повторной обработки в выходной директории %d файлов", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, renamed)); err != nil {
+		t.Errorf("результат b.md не сохранил имя %s: %v", renamed, err)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Префикс каталогов подготовленных результатов транзакционного запуска внутри
+// выходной директории (переименование в пределах одной файловой системы атомарно)
+const stagingDirPrefix = ".rich-staging-"
+
+// Транзакционный запуск (--transactional): результаты пишутся во временный каталог
+// и переносятся в выходную директорию вместе с обновлением списка исключений только
+// если все файлы запуска обработаны без ошибок
+type transaction struct {
+	// Каталоги подготовленных результатов по абсолютным выходным директориям корней
+	dirs map[string]string
+	// Подготовленные результаты в порядке записи
+	staged []stagedOutput
+}
+
+// Подготовленный результат транзакции
+type stagedOutput struct {
+	Staged string
+	Target string
+	// Намерение записи результата (ключ файла в списке исключений и хэши); у
+	// сопутствующих файлов (карточки) ключа нет
+	Intent outputIntent
+	// Прежний выходной файл, перенесенный на время переноса результата ("" - его не было)
+	previous string
+}
+
+// Создание транзакции запуска
+func newTransaction() *transaction {
+	return &transaction{dirs: make(map[string]string)}
+}
+
+// Запись результата во временный каталог выходной директории outputRoot вместо intent.Output
+func (t *transaction) Stage(outputRoot, relPath string, intent outputIntent, content []byte) error {
+	dir, ok := t.dirs[outputRoot]
+	if !ok {
+		if err := os.MkdirAll(outputRoot, 0755); err != nil {
+			return errorf("ошибка при создании выходной директории: %v", err)
+		}
+		var err error
+		if dir, err = os.MkdirTemp(outputRoot, stagingDirPrefix+"*"); err != nil {
+			return errorf("не удалось создать каталог транзакции: %v", err)
+		}
+		t.dirs[outputRoot] = dir
+	}
+	staged := filepath.Join(dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(staged), 0755); err != nil {
+		return errorf("не удалось создать каталог транзакции: %v", err)
+	}
+	if err := safeWriteFile(staged, content, 0644); err != nil {
+		return err
+	}
+	t.staged = append(t.staged, stagedOutput{Staged: staged, Target: intent.Output, Intent: intent})
+	return nil
+}
+
+// Запись сопутствующего файла результата (например, карточек) вместо target; файл
+// переносится вместе с результатами, но не добавляется в список исключений
+func (t *transaction) StageCompanion(outputRoot, relPath, target string, content []byte) error {
+	return t.Stage(outputRoot, relPath, outputIntent{Output: target}, content)
+}
+
+// Количество подготовленных результатов без сопутствующих файлов
+func (t *transaction) Len() int {
+	n := 0
+	for _, s := range t.staged {
+		if s.Intent.Key != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Перенос всех результатов в выходные директории и обновление списка исключений.
+// Если перенос не удался, уже перенесенные результаты возвращаются обратно и прежние
+// выходные файлы восстанавливаются. Намерения записи в каталоге состояния stateDir
+// позволяют досохранить список исключений, если процесс завершится после переноса
+func (t *transaction) Commit(configPath, stateDir string) error {
+	intents := make([]string, len(t.staged))
+	removeIntents := func() {
+		for _, p := range intents {
+			if p != "" {
+				removeIntent(p)
+			}
+		}
+	}
+	if stateDir != "" {
+		for i, s := range t.staged {
+			if s.Intent.Key == "" {
+				continue
+			}
+			path, err := saveIntent(stateDir, s.Intent)
+			if err != nil {
+				removeIntents()
+				return err
+			}
+			intents[i] = path
+		}
+	}
+	for i := range t.staged {
+		s := &t.staged[i]
+		err := os.MkdirAll(filepath.Dir(s.Target), 0755)
+		if err == nil {
+			if _, serr := os.Lstat(s.Target); serr == nil {
+				s.previous = s.Staged + ".previous"
+				err = moveFile(s.Target, s.previous)
+			}
+		}
+		if err == nil {
+			err = moveFile(s.Staged, s.Target)
+		}
+		if err != nil {
+			t.restore(i)
+			t.cleanup()
+			removeIntents()
+			return errorf("не удалось перенести результат %s: %v", s.Target, err)
+		}
+	}
+	for i, s := range t.staged {
+		if s.Intent.Key == "" {
+			continue
+		}
+		if err := addToExcludedFiles(configPath, s.Intent.Key); err != nil {
+			warnf("Предупреждение: не удалось добавить файл в список исключений: %v", err)
+			continue
+		}
+		if intents[i] != "" {
+			removeIntent(intents[i])
+		}
+	}
+	t.cleanup()
+	return nil
+}
+
+// Отмена транзакции: подготовленные результаты удаляются
+func (t *transaction) Rollback() {
+	t.cleanup()
+}
+
+// Возврат перенесенных результатов до n-го (включительно, если он перенесен частично)
+func (t *transaction) restore(n int) {
+	for i := n; i >= 0; i-- {
+		s := t.staged[i]
+		if i < n {
+			if err := moveFile(s.Target, s.Staged); err != nil {
+				logErrorf("Ошибка отмены переноса %s: %v", s.Target, err)
+			}
+		}
+		if s.previous != "" {
+			if _, err := os.Lstat(s.Target); err == nil {
+				// Результат n-го файла не перенесен, прежний файл уже на месте
+				continue
+			}
+			if err := moveFile(s.previous, s.Target); err != nil {
+				logErrorf("Ошибка восстановления прежнего файла %s: %v", s.Target, err)
+			}
+		}
+	}
+}
+
+// Удаление временных каталогов транзакции
+func (t *transaction) cleanup() {
+	for _, dir := range t.dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			logErrorf("Ошибка удаления каталога транзакции: %v", err)
+		}
+	}
+	t.dirs = make(map[string]string)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransactionalRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	for name, text := range map[string]string{"a.md": "# Первая заметка", "sub/b.md": "# Вторая заметка", "c.md": "# Сбой"} {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "a.md"), []byte("прежний результат"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "Сбой") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Обогащенный текст"}}]}`))
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "test.cfg")
+	if err := os.WriteFile(configPath, []byte("[EXCLUSIONS]\nexcluded_files =\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(tmpDir, "report.json")
+	config := &Config{InputDir: inputDir, OutputDir: outputDir, ModelAPIURL: server.URL + "/v1/chat/completions",
+		Provider: providerOpenAICompatible, Transactional: true, WriteQueue: 2, ReportFile: reportPath}
+
+	readReport := func() *runReport {
+		t.Helper()
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report runReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		return &report
+	}
+	assertNoStaging := func() {
+		t.Helper()
+		entries, _ := os.ReadDir(outputDir)
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), stagingDirPrefix) {
+				t.Errorf("каталог транзакции не удален: %s", e.Name())
+			}
+		}
+	}
+
+	// Ошибка одного файла: результаты не сохраняются, список исключений не меняется
+	if err := processDirectory(config, configPath); err == nil {
+		t.Fatal("ожидалась ошибка отмены транзакции")
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "a.md")); string(data) != "прежний результат" {
+		t.Errorf("прежний результат изменен при отмене транзакции: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "sub", "b.md")); !os.IsNotExist(err) {
+		t.Errorf("результат сохранен при отмене транзакции: %v", err)
+	}
+	if cfg, _ := os.ReadFile(configPath); strings.Contains(string(cfg), ".md") {
+		t.Errorf("список исключений изменен при отмене транзакции:\n%s", cfg)
+	}
+	if report := readReport(); report.Transaction != TransactionRolledBack {
+		t.Errorf("итог транзакции в отчете %q, ожидался %q", report.Transaction, TransactionRolledBack)
+	}
+	assertNoStaging()
+
+	// Без ошибок результаты переносятся вместе с обновлением списка исключений
+	if err := os.Remove(filepath.Join(inputDir, "c.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+	for _, name := range []string{"a.md", "sub/b.md"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil || !strings.HasPrefix(string(data), "Обогащенный текст") {
+			t.Errorf("результат %s не сохранен: %q, %v", name, data, err)
+		}
+	}
+	if cfg, _ := os.ReadFile(configPath); !strings.Contains(string(cfg), "a.md") || !strings.Contains(string(cfg), "sub/b.md") {
+		t.Errorf("список исключений не обновлен:\n%s", cfg)
+	}
+	if report := readReport(); report.Transaction != TransactionCommitted {
+		t.Errorf("итог транзакции в отчете %q, ожидался %q", report.Transaction, TransactionCommitted)
+	}
+	assertNoStaging()
+}
+
+func TestTransactionCommitRestoresOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputDir := filepath.Join(tmpDir, "output")
+	txn := newTransaction()
+	for _, name := range []string{"a.md", "sub/b.md"} {
+		if err := txn.Stage(outputDir, name, outputIntent{Key: name, Output: filepath.Join(outputDir, name)}, []byte("новый "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "a.md"), []byte("прежний"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Файл на месте каталога второго результата не дает его перенести
+	if err := os.WriteFile(filepath.Join(outputDir, "sub"), []byte("файл"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(filepath.Join(tmpDir, "test.cfg"), ""); err == nil {
+		t.Fatal("ожидалась ошибка переноса результата")
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "a.md")); string(data) != "прежний" {
+		t.Errorf("прежний файл не восстановлен: %q", data)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 2 {
+		t.Errorf("после отмены переноса в выходной директории лишние файлы: %v", entries)
+	}
+}
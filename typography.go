@@ -0,0 +1,269 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/ini.v1"
+)
+
+// Неразрывный пробел и узкий неразрывный пробел (перед знаками препинания во французском)
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+// Типографские правила языка
+type typographyRules struct {
+	// Кавычки: внешние открывающая и закрывающая, вложенные открывающая и закрывающая
+	// ("" - кавычки не заменяются)
+	Quotes string
+	// Тире вместо дефиса, окруженного пробелами: символ тире и неразрывный пробел перед ним
+	Dashes   bool
+	Dash     string
+	DashNbsp bool
+	// Неразрывный пробел между числом и единицей измерения или валютой
+	UnitSpaces bool
+	// Узкий неразрывный пробел перед ; : ! ? и внутри «» (французская типографика)
+	FrenchSpacing bool
+}
+
+// Правила языков по умолчанию
+var defaultTypography = map[string]typographyRules{
+	"ru": {Quotes: "«»„“", Dash: "—", DashNbsp: true, Dashes: true, UnitSpaces: true},
+	"uk": {Quotes: "«»„“", Dash: "—", DashNbsp: true, Dashes: true, UnitSpaces: true},
+	"en": {Quotes: "“”‘’", Dash: "—", Dashes: true, UnitSpaces: true},
+	"de": {Quotes: "„“‚‘", Dash: "–", Dashes: true, UnitSpaces: true},
+	"fr": {Quotes: "«»“”", Dash: "—", DashNbsp: true, Dashes: true, UnitSpaces: true, FrenchSpacing: true},
+	"es": {Quotes: "«»“”", Dash: "—", Dashes: true, UnitSpaces: true},
+	"it": {Quotes: "«»“”", Dash: "—", Dashes: true, UnitSpaces: true},
+	"pt": {Quotes: "«»“”", Dash: "—", Dashes: true, UnitSpaces: true},
+}
+
+// Типографская обработка обогащенного текста из секций [TYPOGRAPHY] и [TYPOGRAPHY.<язык>]
+type TypographyConfig struct {
+	Enabled bool
+	// Правила по языкам результата с учетом настроек
+	Languages map[string]typographyRules
+}
+
+// Чтение секции [TYPOGRAPHY] и секций языков: настройки [TYPOGRAPHY] действуют для
+// всех языков, секция языка переопределяет их и может добавить язык без правил по
+// умолчанию (тогда кавычки задаются ключом quotes)
+func loadTypographyConfig(cfg *ini.File) (TypographyConfig, error) {
+	base := cfg.Section("TYPOGRAPHY")
+	tc := TypographyConfig{Enabled: base.Key("enabled").MustBool(false), Languages: make(map[string]typographyRules)}
+	langs := make(map[string]*ini.Section)
+	for lang := range defaultTypography {
+		langs[lang] = nil
+	}
+	for _, section := range cfg.Sections() {
+		if lang, ok := strings.CutPrefix(section.Name(), "TYPOGRAPHY."); ok && lang != "" {
+			langs[strings.ToLower(lang)] = section
+		}
+	}
+	for lang, section := range langs {
+		rules, ok := defaultTypography[lang]
+		if !ok {
+			rules = typographyRules{Dash: "—", Dashes: true, UnitSpaces: true}
+		}
+		var err error
+		if rules, err = applyTypographyKeys(rules, base, "TYPOGRAPHY"); err != nil {
+			return tc, err
+		}
+		if section != nil {
+			if hasOwnKey(section, "enabled") && !section.Key("enabled").MustBool(true) {
+				continue
+			}
+			if rules, err = applyTypographyKeys(rules, section, section.Name()); err != nil {
+				return tc, err
+			}
+		}
+		tc.Languages[lang] = rules
+	}
+	return tc, nil
+}
+
+// Настройки правил из секции: quotes (true, false или четыре символа кавычек),
+// dashes, unit_spaces и french_spacing
+func applyTypographyKeys(rules typographyRules, section *ini.Section, name string) (typographyRules, error) {
+	if hasOwnKey(section, "quotes") {
+		switch value := strings.TrimSpace(section.Key("quotes").String()); strings.ToLower(value) {
+		case "true", "on", "yes":
+		case "false", "off", "no":
+			rules.Quotes = ""
+		default:
+			if utf8.RuneCountInString(value) != 4 {
+				return rules, errorf("некорректное значение quotes %q в секции [%s]: ожидалось true, false или четыре символа кавычек, например «»„“", value, name)
+			}
+			rules.Quotes = value
+		}
+	}
+	for key, value := range map[string]*bool{"dashes": &rules.Dashes, "unit_spaces": &rules.UnitSpaces, "french_spacing": &rules.FrenchSpacing} {
+		if hasOwnKey(section, key) {
+			*value = section.Key(key).MustBool(*value)
+		}
+	}
+	return rules, nil
+}
+
+// Ключ задан в самой секции: секция [TYPOGRAPHY.<язык>] наследует ключи [TYPOGRAPHY],
+// а MustBool записывает значение по умолчанию в секцию
+func hasOwnKey(section *ini.Section, name string) bool {
+	return slices.Contains(section.KeyStrings(), name)
+}
+
+// Правила для языка результата; false, если обработка выключена или язык не настроен
+func (t TypographyConfig) rulesFor(lang string) (typographyRules, bool) {
+	if !t.Enabled || lang == "" {
+		return typographyRules{}, false
+	}
+	rules, ok := t.Languages[lang]
+	return rules, ok
+}
+
+var (
+	// Фрагменты строки, которые не изменяются: код, адреса ссылок, HTML, вики-ссылки,
+	// адреса и якоря заголовков
+	typographyProtected = regexp.MustCompile("`[^`]*`|\\]\\([^)]*\\)|<[^>]*>|\\[\\[[^\\]]*\\]\\]|https?://[^\\s)>\\]]+|\\{#[^}]*\\}")
+	// Дефис или тире, окруженные пробелами
+	spacedDashPattern = regexp.MustCompile(`([^\s|])[ \x{00a0}]+(?:--?|—|–)[ ]+`)
+	// Число и единица измерения или валюта через обычный пробел
+	unitSpacePattern = regexp.MustCompile(`(\d) ((?:кг|г|мг|т|км|м|см|мм|мкм|л|мл|с|мс|мин|ч|сут|руб|₽|коп|тыс|млн|млрд|трлн|Кб|Мб|Гб|Тб|КБ|МБ|ГБ|ТБ|Вт|кВт|В|А|kg|g|mg|t|km|m|cm|mm|µm|l|L|ml|mL|s|ms|min|h|Hz|kHz|MHz|GHz|B|KB|MB|GB|TB|KiB|MiB|GiB|TiB|W|kW|MW|V|mV|A|mA|px|pt|dpi|%|‰|°C|°F|°|€|\$|£|¥)\.?)([^\p{L}\p{N}]|$)`)
+	// Пробел перед двойными знаками препинания во французском
+	frenchPunctPattern = regexp.MustCompile(`([^\s])[ \x{00a0}]([;:!?])`)
+	// Тематический разрыв (- - -), который не является текстом с тире
+	thematicBreakPattern = regexp.MustCompile(`^[-*_ ]+$`)
+)
+
+// Типографская обработка документа по правилам языка: кавычки, тире, неразрывные
+// пробелы перед единицами измерения. Frontmatter, блоки кода, код в тексте и адреса
+// не изменяются; повторная обработка результата не меняет
+func applyTypography(rules typographyRules, text string) string {
+	prefix := ""
+	if _, body, ok := parseFrontmatter([]byte(text)); ok {
+		prefix = text[:len(text)-len(body)]
+		text = string(body)
+	}
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || trimmed == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ") && !isListLine(trimmed) {
+			continue
+		}
+		lines[i] = typographLine(rules, line, thematicBreakPattern.MatchString(trimmed))
+	}
+	return prefix + strings.Join(lines, "\n")
+}
+
+// Строка элемента списка
+func isListLine(trimmed string) bool {
+	if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") || strings.HasPrefix(trimmed, "+ ") {
+		return true
+	}
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	return digits > 0 && (strings.HasPrefix(trimmed[digits:], ". ") || strings.HasPrefix(trimmed[digits:], ") "))
+}
+
+// Обработка строки вне защищенных фрагментов
+func typographLine(rules typographyRules, line string, thematicBreak bool) string {
+	var b strings.Builder
+	quotes := &quoteState{rules: rules}
+	prev := rune(0)
+	last := 0
+	emit := func(segment string) {
+		if !thematicBreak {
+			if rules.Dashes {
+				sep := " "
+				if rules.DashNbsp {
+					sep = nbsp
+				}
+				segment = spacedDashPattern.ReplaceAllString(segment, "${1}"+sep+rules.Dash+" ")
+			}
+			if rules.UnitSpaces {
+				segment = unitSpacePattern.ReplaceAllString(segment, "${1}"+nbsp+"${2}${3}")
+			}
+		}
+		segment = quotes.Replace(segment, prev)
+		if rules.FrenchSpacing {
+			segment = frenchPunctPattern.ReplaceAllString(segment, "${1}"+narrowNbsp+"${2}")
+			segment = strings.NewReplacer("« ", "«"+narrowNbsp, " »", narrowNbsp+"»", "«"+nbsp, "«"+narrowNbsp, nbsp+"»", narrowNbsp+"»").Replace(segment)
+			segment = frenchGuillemets(segment)
+		}
+		b.WriteString(segment)
+	}
+	for _, loc := range typographyProtected.FindAllStringIndex(line, -1) {
+		emit(line[last:loc[0]])
+		b.WriteString(line[loc[0]:loc[1]])
+		last = loc[1]
+		prev, _ = utf8.DecodeLastRuneInString(line[:last])
+	}
+	emit(line[last:])
+	return b.String()
+}
+
+// Узкий неразрывный пробел внутри «» без пробела
+func frenchGuillemets(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r == '»' && i > 0 {
+			if p, _ := utf8.DecodeLastRuneInString(s[:i]); p != ' ' && !unicode.IsSpace(p) {
+				b.WriteString(narrowNbsp)
+			}
+		}
+		b.WriteRune(r)
+		if r == '«' {
+			if n, _ := utf8.DecodeRuneInString(s[i+len("«"):]); n != utf8.RuneError && n != ' ' && !unicode.IsSpace(n) {
+				b.WriteString(narrowNbsp)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Замена кавычек в строке: открывающая или закрывающая определяется по соседним
+// символам, уровень вложенности - по ранее открытым кавычкам строки
+type quoteState struct {
+	rules typographyRules
+	depth int
+}
+
+// Замена кавычек во фрагменте; prev - символ перед фрагментом (0 - начало строки)
+func (q *quoteState) Replace(segment string, prev rune) string {
+	marks := []rune(q.rules.Quotes)
+	runes := []rune(segment)
+	for i, r := range runes {
+		before := prev
+		if i > 0 {
+			before = runes[i-1]
+		}
+		switch {
+		case r == '\'' || r == '’':
+			// Апостроф внутри слова
+			if i+1 < len(runes) && unicode.IsLetter(before) && unicode.IsLetter(runes[i+1]) {
+				runes[i] = '’'
+			}
+		case len(marks) == 4 && (r == '"' || r == '“' || r == '”' || r == '«' || r == '»' || r == '„'):
+			opening := r == '«' || r == '„'
+			if r == '"' || r == '“' {
+				opening = before == 0 || unicode.IsSpace(before) || strings.ContainsRune("([{—–-/«„“", before)
+			}
+			if opening {
+				runes[i] = marks[min(q.depth, 1)*2]
+				q.depth++
+			} else {
+				q.depth = max(q.depth-1, 0)
+				runes[i] = marks[min(q.depth, 1)*2+1]
+			}
+		}
+	}
+	return string(runes)
+}
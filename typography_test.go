@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestApplyTypography(t *testing.T) {
+	testCases := []struct {
+		lang string
+		in   string
+		want string
+	}{
+		{"ru", `Он сказал: "Это "важно" - запомни".`, "Он сказал: «Это „важно“~— запомни»."},
+		{"ru", "Вес 5 кг, дистанция 10 км и 2024 г. - рекорд", "Вес 5~кг, дистанция 10~км и 2024~г.~— рекорд"},
+		{"ru", "Уже «правильно»~— без изменений", "Уже «правильно»~— без изменений"},
+		{"en", `She said "it's done" -- finally, 5 MB left.`, "She said “it’s done” — finally, 5~MB left."},
+		{"de", `Er sagte "Hallo" - und ging.`, "Er sagte „Hallo“ – und ging."},
+		{"fr", `Il a dit "bonjour" : c'est tout !`, "Il a dit «^bonjour^»^: c’est tout^!"},
+		// Код, адреса ссылок, HTML и вики-ссылки не изменяются
+		{"ru", "Команда `rm -rf \"x\"` и [ссылка](http://a.b/c - d) и [[Заметка - черновик]] <a title=\"x\">", "Команда `rm -rf \"x\"` и [ссылка](http://a.b/c - d) и [[Заметка - черновик]] <a title=\"x\">"},
+		// Разрыв и списки
+		{"ru", "- - -\n- пункт - первый\n\n    код \"x\" - y", "- - -\n- пункт~— первый\n\n    код \"x\" - y"},
+	}
+	// ~ - неразрывный пробел, ^ - узкий неразрывный пробел
+	spaces := strings.NewReplacer("~", nbsp, "^", narrowNbsp)
+	for _, tc := range testCases {
+		tc.in, tc.want = spaces.Replace(tc.in), spaces.Replace(tc.want)
+		tcfg, err := loadTypographyConfig(ini.Empty())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules, ok := tcfg.Languages[tc.lang]
+		if !ok {
+			t.Fatalf("Нет правил для языка %s", tc.lang)
+		}
+		got := applyTypography(rules, tc.in)
+		if got != tc.want {
+			t.Errorf("%s: applyTypography(%q) =\n%q\nожидалось\n%q", tc.lang, tc.in, got, tc.want)
+		}
+		if again := applyTypography(rules, got); again != got {
+			t.Errorf("%s: повторная обработка изменила результат:\n%q\n%q", tc.lang, got, again)
+		}
+	}
+
+	doc := "---\ntitle: \"Заголовок\"\n---\n```\nx - \"y\"\n```\nТекст - \"тут\""
+	if got := applyTypography(defaultTypography["ru"], doc); got != spaces.Replace("---\ntitle: \"Заголовок\"\n---\n```\nx - \"y\"\n```\nТекст~— «тут»") {
+		t.Errorf("Frontmatter и блоки кода не должны изменяться:\n%q", got)
+	}
+}
+
+func TestLoadTypographyConfig(t *testing.T) {
+	cfg, err := ini.Load([]byte("[TYPOGRAPHY]\nenabled = true\nunit_spaces = false\n[TYPOGRAPHY.en]\nquotes = false\n[TYPOGRAPHY.de]\nenabled = false\n[TYPOGRAPHY.pl]\nquotes = „”‚’\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := loadTypographyConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ru, ok := tc.rulesFor("ru"); !ok || ru.UnitSpaces || ru.Quotes != "«»„“" {
+		t.Errorf("Неожиданные правила ru: %+v", ru)
+	}
+	if en, ok := tc.rulesFor("en"); !ok || en.Quotes != "" || !en.Dashes {
+		t.Errorf("Неожиданные правила en: %+v", en)
+	}
+	if _, ok := tc.rulesFor("de"); ok {
+		t.Error("Типографика de должна быть выключена")
+	}
+	if pl, ok := tc.rulesFor("pl"); !ok || pl.Quotes != "„”‚’" {
+		t.Errorf("Неожиданные правила pl: %+v", pl)
+	}
+	if _, ok := tc.rulesFor(""); ok {
+		t.Error("Для неопределенного языка правила не применяются")
+	}
+
+	cfg, _ = ini.Load([]byte("[TYPOGRAPHY.ru]\nquotes = <>\n"))
+	if _, err := loadTypographyConfig(cfg); err == nil {
+		t.Error("Ожидалась ошибка для некорректных кавычек")
+	}
+}
+
+// Типографика применяется к обогащенному тексту по языку результата, блок оригинала
+// не изменяется
+func TestProcessFileTypography(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant",
+				"content": "# Заметка\n\nКоманда сказала \"готово\" - это значит, что релиз весит 5 МБ и выходит завтра."}}},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "note.md")
+	outputPath := filepath.Join(tmpDir, "out", "note.md")
+	original := "# Заметка\n\nКоманда сказала \"готово\" - релиз завтра."
+	if err := os.WriteFile(inputPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := ini.Load([]byte("[TYPOGRAPHY]\nenabled = true\n"))
+	typography, err := loadTypographyConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: tmpDir, Provider: providerOpenAICompatible, ModelAPIURL: server.URL + "/v1/chat/completions",
+		MaxTokens: 100, Typography: typography}
+	if _, err := processFile(config, inputPath, outputPath, filepath.Join(tmpDir, "test.cfg"), newSession(NewRateLimiter(1000))); err != nil {
+		t.Fatalf("processFile() вернул ошибку: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, ok := parseEnrichedOutput(string(data))
+	if !ok {
+		t.Fatalf("Ожидался блок оригинала:\n%s", data)
+	}
+	if want := "сказала «готово»" + nbsp + "— это значит"; !strings.Contains(prev.Enriched, want) || !strings.Contains(prev.Enriched, "5"+nbsp+"МБ") {
+		t.Errorf("Типографика не применена:\n%q", prev.Enriched)
+	}
+	if prev.Original != original {
+		t.Errorf("Оригинал не должен изменяться:\n%q", prev.Original)
+	}
+}
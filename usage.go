@@ -0,0 +1,112 @@
+package main
+
+import "time"
+
+// Количество токенов, израсходованных на один запрос к API
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	// Оценка по длине текста, если API не вернул данные об использовании
+	Estimated bool
+	// Время ожидания ограничителя и ответа API
+	Timing requestTiming
+}
+
+// Время запросов к API: ожидание ограничителя частоты до отправки, ожидание ответа,
+// момент первой отправки и последнего полученного ответа
+type requestTiming struct {
+	Wait     time.Duration
+	API      time.Duration
+	Sent     time.Time
+	Received time.Time
+}
+
+// Суммирование времени запросов
+func (t requestTiming) Add(other requestTiming) requestTiming {
+	sum := requestTiming{Wait: t.Wait + other.Wait, API: t.API + other.API, Sent: t.Sent, Received: t.Received}
+	if sum.Sent.IsZero() || (!other.Sent.IsZero() && other.Sent.Before(sum.Sent)) {
+		sum.Sent = other.Sent
+	}
+	if other.Received.After(sum.Received) {
+		sum.Received = other.Received
+	}
+	return sum
+}
+
+// Извлечение данных об использовании токенов из ответа API
+// (OpenAI/OpenRouter: prompt_tokens/completion_tokens, Anthropic: input_tokens/output_tokens)
+func parseUsage(responseData map[string]interface{}) (Usage, bool) {
+	raw, ok := responseData["usage"].(map[string]interface{})
+	if !ok {
+		return Usage{}, false
+	}
+
+	intField := func(names ...string) (int, bool) {
+		for _, name := range names {
+			if v, ok := raw[name].(float64); ok {
+				return int(v), true
+			}
+		}
+		return 0, false
+	}
+
+	prompt, okPrompt := intField("prompt_tokens", "input_tokens")
+	completion, okCompletion := intField("completion_tokens", "output_tokens")
+	if !okPrompt && !okCompletion {
+		return Usage{}, false
+	}
+	// Часть OpenAI-совместимых серверов (llama.cpp, vLLM, LM Studio) возвращает
+	// нулевые счетчики вместо настоящих - такой usage считается отсутствующим
+	if prompt == 0 && completion == 0 {
+		return Usage{}, false
+	}
+
+	return Usage{PromptTokens: prompt, CompletionTokens: completion}, true
+}
+
+// Грубая оценка количества токенов по длине текста (~4 символа на токен)
+func estimateTokens(text string) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return n/4 + 1
+}
+
+// Оценка использования токенов для запроса без данных usage в ответе
+func estimateUsage(prompt, completion string) Usage {
+	return Usage{
+		PromptTokens:     estimateTokens(prompt),
+		CompletionTokens: estimateTokens(completion),
+		Estimated:        true,
+	}
+}
+
+// Суммирование использования токенов
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		Estimated:        u.Estimated || other.Estimated,
+		Timing:           u.Timing.Add(other.Timing),
+	}
+}
+
+// Стоимость запроса в долларах по ценам из конфигурации (за 1 млн токенов)
+func (u Usage) Cost(config *Config) float64 {
+	return float64(u.PromptTokens)*config.InputPrice/1e6 +
+		float64(u.CompletionTokens)*config.OutputPrice/1e6
+}
+
+// Доля использования токенов, приходящаяся на часть запроса (part из total)
+func (u Usage) Share(part, total int) Usage {
+	if total <= 0 {
+		return u
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens * part / total,
+		CompletionTokens: u.CompletionTokens * part / total,
+		Estimated:        u.Estimated,
+		Timing:           u.Timing,
+	}
+}
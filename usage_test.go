@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestParseUsage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response map[string]interface{}
+		expected Usage
+		ok       bool
+	}{
+		{
+			name: "Формат OpenAI",
+			response: map[string]interface{}{
+				"usage": map[string]interface{}{"prompt_tokens": 120.0, "completion_tokens": 30.0},
+			},
+			expected: Usage{PromptTokens: 120, CompletionTokens: 30},
+			ok:       true,
+		},
+		{
+			name: "Формат Anthropic",
+			response: map[string]interface{}{
+				"usage": map[string]interface{}{"input_tokens": 50.0, "output_tokens": 70.0},
+			},
+			expected: Usage{PromptTokens: 50, CompletionTokens: 70},
+			ok:       true,
+		},
+		{
+			name: "Нулевые счетчики",
+			response: map[string]interface{}{
+				"usage": map[string]interface{}{"prompt_tokens": 0.0, "completion_tokens": 0.0, "total_tokens": 0.0},
+			},
+			ok: false,
+		},
+		{
+			name:     "Нет поля usage",
+			response: map[string]interface{}{},
+			ok:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			usage, ok := parseUsage(tc.response)
+			if ok != tc.ok {
+				t.Fatalf("parseUsage() ok = %v, ожидалось %v", ok, tc.ok)
+			}
+			if usage != tc.expected {
+				t.Errorf("parseUsage() = %+v, ожидалось %+v", usage, tc.expected)
+			}
+		})
+	}
+}
+
+func TestUsageCost(t *testing.T) {
+	config := &Config{InputPrice: 2, OutputPrice: 8}
+	usage := Usage{PromptTokens: 500000, CompletionTokens: 250000}
+
+	if cost := usage.Cost(config); cost != 3 {
+		t.Errorf("Ожидалась стоимость 3, получено %f", cost)
+	}
+
+	estimated := estimateUsage("12345678", "")
+	if !estimated.Estimated || estimated.PromptTokens != 3 || estimated.CompletionTokens != 0 {
+		t.Errorf("Некорректная оценка использования: %+v", estimated)
+	}
+}
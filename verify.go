@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Причины, по которым результат исходного файла не актуален
+const (
+	// Файл не обогащался (или последняя обработка завершилась ошибкой)
+	VerifyMissing = "missing"
+	// Выходной файл удален после обогащения
+	VerifyNoOutput = "no output"
+	// Исходный файл изменен после обогащения
+	VerifyChanged = "changed"
+	// Результат получен с промптом, отличающимся от текущего
+	VerifyStalePrompt = "stale prompt"
+	// Новое обогащение сохранено в файл .new и не слито с ручными правками
+	VerifyConflict = "conflict"
+	// Обогащение не удалось, в выходной директории копия с отметкой об ошибке (on_failure)
+	VerifyFailed = "failed"
+)
+
+// Исходный файл с неактуальным результатом
+type verifyProblem struct {
+	Input  string
+	Reason string
+}
+
+// Последняя запись журналов о файле любого статуса
+type lastEntry struct {
+	At    time.Time
+	Entry journalEntry
+}
+
+// Последние неотмененные записи журналов по относительным путям исходных файлов
+func lastEntries(stateDir string) (map[string]lastEntry, error) {
+	runs, err := listRuns(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]lastEntry)
+	for _, j := range runs {
+		for _, e := range j.Entries {
+			if e.Undone {
+				continue
+			}
+			e.Input = normalizeRelPath(e.Input)
+			last[e.Input] = lastEntry{At: j.StartedAt, Entry: e}
+		}
+	}
+	return last, nil
+}
+
+// Проверка результатов всех исходных файлов без изменения файлов и запросов к API.
+// Файлы из excluded_files, которых нет в журналах запусков, считаются исключенными
+// вручную и не проверяются; пропущенные при обработке файлы (skipped: ...) актуальны,
+// пока не изменены после пропуска
+func verifyOutputs(config *Config) (checked int, problems []verifyProblem, err error) {
+	latest, err := latestOutputs(config.StateDir)
+	if err != nil {
+		return 0, nil, err
+	}
+	last, err := lastEntries(config.StateDir)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, rootConfig := range config.inputRoots() {
+		inputDir, err := filepath.Abs(rootConfig.InputDir)
+		if err != nil {
+			return checked, problems, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+		outputDir, err := filepath.Abs(rootConfig.OutputDir)
+		if err != nil {
+			return checked, problems, errorf("ошибка при получении абсолютного пути выходной директории: %v", err)
+		}
+		cands, err := collectCandidates(rootConfig, inputDir, outputDir, nil)
+		if err != nil {
+			return checked, problems, err
+		}
+		for _, c := range cands {
+			key := normalizeRelPath(rootKey(rootConfig.RootName, c.RelPath))
+			rec, enriched := latest[key]
+			prev, seen := last[key]
+			if !seen && isExcluded(config, key) {
+				continue
+			}
+			checked++
+			if reason := verifyInput(rootConfig, c, rec, enriched, prev, seen); reason != "" {
+				problems = append(problems, verifyProblem{Input: key, Reason: reason})
+			}
+		}
+	}
+	sort.Slice(problems, func(a, b int) bool { return problems[a].Input < problems[b].Input })
+	return checked, problems, nil
+}
+
+// Причина неактуальности результата одного файла ("" - результат актуален)
+func verifyInput(config *Config, c candidate, rec outputRecord, enriched bool, prev lastEntry, seen bool) string {
+	if seen && prev.Entry.Status == StatusFailed && prev.Entry.Fallback {
+		if data, err := os.ReadFile(prev.Entry.Output); err == nil && hasFailureMarker(data) {
+			return VerifyFailed
+		}
+	}
+	if !enriched {
+		if seen && isSkippedStatus(prev.Entry.Status) && !c.Info.ModTime().After(prev.At) {
+			return ""
+		}
+		return VerifyMissing
+	}
+	// Конфликт и пропуск файла, новое обогащение которого на проверке у другого
+	// оператора, указывают файл .new
+	if prev.Entry.Status == StatusConflict || prev.Entry.Status == StatusSkippedInReview {
+		if _, err := os.Stat(prev.Entry.Conflict); err == nil {
+			return VerifyConflict
+		}
+	}
+	if _, err := os.Stat(rec.Entry.Output); err != nil {
+		return VerifyNoOutput
+	}
+
+	content, err := os.ReadFile(c.Path)
+	if err != nil {
+		return VerifyMissing
+	}
+	// Для записей журнала без хэша исходного файла сравнивается время изменения
+	// файла со временем запуска
+	if rec.Entry.InputHash != "" {
+		if contentHash(content) != rec.Entry.InputHash {
+			return VerifyChanged
+		}
+	} else if c.Info.ModTime().After(rec.At) {
+		return VerifyChanged
+	}
+
+	// Версия промпта зависит от языка, который определяется по тексту; для документов
+	// других форматов текст известен только после конвертации, поэтому промпт не сравнивается
+	if rec.Entry.PromptHash != "" && isMarkdownPath(c.Path) {
+		fileConfig, _, _ := resolveFileConfig(config, c.RelPath, content)
+		if promptHash(fileConfig.Prompt) != rec.Entry.PromptHash {
+			return VerifyStalePrompt
+		}
+	}
+	return ""
+}
+
+// rich verify: проверка, что у всех исходных файлов есть актуальные результаты;
+// при неактуальных результатах команда завершается с ошибкой
+func runVerifyCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	configPath := fs.String("config", "rich.cfg", tr("Путь к файлу конфигурации"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadCommandConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	checked, problems, err := verifyOutputs(config)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintf(out, "%-13s %s\n", p.Reason, p.Input)
+	}
+	fmt.Fprintf(out, tr("Проверено файлов: %d, неактуальных результатов: %d\n"), checked, len(problems))
+	if len(problems) > 0 {
+		return errorf("неактуальных результатов: %d", len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.md":      "# Первая заметка о миграции",
+		"b.md":      "# Вторая заметка о сроках",
+		"tiny.md":   "# Коротко",
+		"manual.md": "# Исключена вручную",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := json.Marshal(map[string]interface{}{"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "Обогащено"}}}})
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") +
+		"\n[PROCESSING]\nmin_words = 3\n[EXCLUSIONS]\nexcluded_files = manual.md\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	// Все файлы обогащены или пропущены, manual.md исключен вручную
+	var out bytes.Buffer
+	if err := runVerifyCommand([]string{"--config", configPath}, &out); err != nil {
+		t.Fatalf("rich verify вернул ошибку: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "Проверено файлов: 3, неактуальных результатов: 0") {
+		t.Errorf("вывод rich verify:\n%s", out.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# Первая заметка о миграции, дополнена"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(outputDir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "c.md"), []byte("# Новая заметка без результата"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runVerifyCommand([]string{"--config", configPath}, &out); err == nil {
+		t.Fatal("ожидалась ошибка для неактуальных результатов")
+	}
+	want := "changed       a.md\nno output     b.md\nmissing       c.md\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("вывод rich verify:\n%s\nожидалось начало\n%s", out.String(), want)
+	}
+}
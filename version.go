@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Сведения о сборке, задаются при сборке через -ldflags:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=abc1234 -X main.buildDate=2024-06-17T10:30:00Z"
+//
+// Без них коммит и время берутся из сведений системы контроля версий, встроенных Go
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// Адрес проекта для заголовка User-Agent
+const projectURL = "https://github.com/Headcrab/rich"
+
+// Сведения о версии программы
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Сведения о текущей сборке
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+		}
+		dirty := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value[:min(len(s.Value), 12)]
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+		if dirty && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	return info
+}
+
+// Значение заголовка User-Agent запросов к API: rich/<версия> (<платформа>; +<адрес проекта>)
+func userAgent() string {
+	info := currentBuildInfo()
+	return fmt.Sprintf("rich/%s (%s; +%s)", info.Version, info.Platform, projectURL)
+}
+
+// Установка заголовка User-Agent
+func setUserAgent(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent())
+}
+
+// rich version [--json]: версия, коммит и дата сборки
+func runVersionCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, tr("Вывод в формате JSON"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	info := currentBuildInfo()
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	fmt.Fprintf(out, "rich %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Fprintf(out, tr("коммит: %s\n"), info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Fprintf(out, tr("дата сборки: %s\n"), info.BuildDate)
+	}
+	fmt.Fprintf(out, "%s, %s\n", info.GoVersion, info.Platform)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVersionCommand(t *testing.T) {
+	oldVersion, oldCommit, oldDate := version, commit, buildDate
+	defer func() { version, commit, buildDate = oldVersion, oldCommit, oldDate }()
+	version, commit, buildDate = "1.2.0", "abc1234", "2024-06-17T10:30:00Z"
+
+	var out bytes.Buffer
+	if err := runVersionCommand([]string{"--json"}, &out); err != nil {
+		t.Fatalf("runVersionCommand() вернул ошибку: %v", err)
+	}
+	var info buildInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("Некорректный JSON: %v\n%s", err, out.String())
+	}
+	if info.Version != "1.2.0" || info.Commit != "abc1234" || info.BuildDate != "2024-06-17T10:30:00Z" || info.GoVersion == "" {
+		t.Errorf("Неожиданные сведения о сборке: %+v", info)
+	}
+
+	out.Reset()
+	if err := runVersionCommand(nil, &out); err != nil {
+		t.Fatalf("runVersionCommand() вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "rich 1.2.0\n") || !strings.Contains(out.String(), "abc1234") {
+		t.Errorf("Неожиданный вывод версии: %q", out.String())
+	}
+}
+
+func TestUserAgentHeader(t *testing.T) {
+	oldVersion := version
+	defer func() { version = oldVersion }()
+	version = "1.2.0"
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		response := map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "ok"}},
+			},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{ModelName: "gpt-3.5-turbo", ModelAPIURL: server.URL + "/v1/chat/completions"}
+	if _, err := enrichContent(config, "текст", NewRateLimiter(RequestsPerMinute)); err != nil {
+		t.Fatalf("enrichContent() вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(got, "rich/1.2.0 (") || !strings.Contains(got, projectURL) {
+		t.Errorf("Неожиданный User-Agent: %q", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Регион Vertex AI по умолчанию
+const defaultVertexLocation = "us-central1"
+
+// Базовый адрес Vertex AI для региона (global - без префикса региона)
+func vertexBaseURL(location string) string {
+	if location == "" || location == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + location + "-aiplatform.googleapis.com"
+}
+
+// Адрес generateContent модели Gemini в Vertex AI или Gemini API (AI Studio).
+// Модель берется из конфигурации при каждом запросе (маршруты могут ее менять);
+// api_url с ":generateContent" используется как есть. При stream = true вызывается
+// streamGenerateContent с ответом в виде server-sent events
+func vertexURL(config *Config) (string, error) {
+	apiURL, err := geminiMethodURL(config)
+	if err != nil || !config.Stream {
+		return apiURL, err
+	}
+	return strings.Replace(apiURL, ":generateContent", ":streamGenerateContent", 1) + "?alt=sse", nil
+}
+
+// Адрес метода generateContent модели
+func geminiMethodURL(config *Config) (string, error) {
+	if strings.Contains(config.ModelAPIURL, ":generateContent") {
+		return config.ModelAPIURL, nil
+	}
+	model := config.ModelName
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if p := config.provider(); p == nil || p.Name != providerVertex {
+		// Gemini API: <база>/models/<модель>:generateContent
+		return fmt.Sprintf("%s/models/%s:generateContent", strings.TrimRight(config.ModelAPIURL, "/"), url.PathEscape(model)), nil
+	}
+	if config.VertexProject == "" {
+		return "", errorf("для Vertex AI не задан проект: укажите project в секции [MODEL] или GOOGLE_CLOUD_PROJECT")
+	}
+	location := config.VertexLocation
+	if location == "" {
+		location = defaultVertexLocation
+	}
+	return fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent",
+		strings.TrimRight(config.ModelAPIURL, "/"), url.PathEscape(config.VertexProject), url.PathEscape(location), url.PathEscape(model)), nil
+}
+
+// Тело запроса generateContent из сообщений диалога; параметры генерации передаются
+// в generationConfig
+func geminiRequest(messages []map[string]string, params map[string]interface{}) map[string]interface{} {
+	contents := make([]map[string]interface{}, 0, len(messages))
+	for _, m := range messages {
+		contents = append(contents, map[string]interface{}{
+			"role": m["role"], "parts": []map[string]string{{"text": m["content"]}},
+		})
+	}
+	return map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": params,
+	}
+}
+
+// Текст и токены ответа generateContent: текстовые части первого кандидата
+// объединяются, рассуждения модели (thought) пропускаются
+func geminiText(responseData map[string]interface{}) (string, Usage, bool, error) {
+	candidates, _ := responseData["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		if feedback, ok := responseData["promptFeedback"].(map[string]interface{}); ok {
+			if reason, _ := feedback["blockReason"].(string); reason != "" {
+				return "", Usage{}, false, errorf("запрос заблокирован Gemini: %s", reason)
+			}
+		}
+		return "", Usage{}, false, errorf("некорректный формат ответа Gemini: отсутствует поле candidates или оно пустое")
+	}
+	candidate, ok := candidates[0].(map[string]interface{})
+	if !ok {
+		return "", Usage{}, false, errorf("некорректный формат элемента candidates в ответе Gemini")
+	}
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
+
+	var text strings.Builder
+	for _, item := range parts {
+		part, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if thought, _ := part["thought"].(bool); thought {
+			continue
+		}
+		if s, ok := part["text"].(string); ok {
+			text.WriteString(s)
+		}
+	}
+	if text.Len() == 0 {
+		reason, _ := candidate["finishReason"].(string)
+		return "", Usage{}, false, errorf("ответ Gemini не содержит текста (finishReason: %s)", reason)
+	}
+
+	var usage Usage
+	meta, hasUsage := responseData["usageMetadata"].(map[string]interface{})
+	if hasUsage {
+		prompt, _ := meta["promptTokenCount"].(float64)
+		completion, _ := meta["candidatesTokenCount"].(float64)
+		usage = Usage{PromptTokens: int(prompt), CompletionTokens: int(completion)}
+	}
+	return text.String(), usage, hasUsage, nil
+}
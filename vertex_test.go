@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVertexGenerateContent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", tokenHandler(t, key, &calls))
+	mux.HandleFunc("/v1/projects/rich-test/locations/europe-west4/publishers/google/models/gemini-2.0-flash-001:generateContent", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			http.Error(w, `{"error": {"code": 401, "message": "missing token", "status": "UNAUTHENTICATED"}}`, http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Contents []struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"contents"`
+			GenerationConfig struct {
+				MaxOutputTokens int `json:"maxOutputTokens"`
+			} `json:"generationConfig"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Contents) != 1 || !strings.Contains(req.Contents[0].Parts[0].Text, "текст") {
+			t.Errorf("Неожиданный запрос: %+v, %v", req, err)
+		}
+		_, _ = w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [
+			{"text": "план ответа", "thought": true},
+			{"text": "Обогащенный "}, {"text": "текст"}]}, "finishReason": "STOP"}],
+			"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := &Config{
+		ModelName:       "gemini-2.0-flash-001",
+		ModelAPIURL:     server.URL,
+		Provider:        "vertex",
+		VertexProject:   "rich-test",
+		VertexLocation:  "europe-west4",
+		GoogleCredsFile: writeServiceAccountKey(t, key, server.URL+"/token"),
+		MaxTokens:       100,
+	}
+	text, usage, err := enrichContentWithUsage(config, "текст", NewRateLimiter(RequestsPerMinute))
+	if err != nil {
+		t.Fatalf("enrichContentWithUsage() вернул ошибку: %v", err)
+	}
+	if text != "Обогащенный текст" {
+		t.Errorf("Рассуждения не должны попадать в результат: %q", text)
+	}
+	if usage.PromptTokens != 12 || usage.CompletionTokens != 5 || usage.Estimated {
+		t.Errorf("Неожиданные токены: %+v", usage)
+	}
+
+	config.VertexProject = ""
+	if _, err := enrichContent(config, "текст", NewRateLimiter(RequestsPerMinute)); err == nil {
+		t.Error("Без проекта запрос к Vertex AI должен возвращать ошибку")
+	}
+}
+
+func TestGeminiText(t *testing.T) {
+	blocked := map[string]interface{}{"promptFeedback": map[string]interface{}{"blockReason": "SAFETY"}}
+	if _, _, _, err := geminiText(blocked); err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("Ожидалась ошибка блокировки, получено: %v", err)
+	}
+	empty := map[string]interface{}{"candidates": []interface{}{
+		map[string]interface{}{"finishReason": "MAX_TOKENS", "content": map[string]interface{}{}},
+	}}
+	if _, _, _, err := geminiText(empty); err == nil || !strings.Contains(err.Error(), "MAX_TOKENS") {
+		t.Errorf("Ожидалась ошибка пустого ответа, получено: %v", err)
+	}
+	if got := vertexBaseURL("global"); got != "https://aiplatform.googleapis.com" {
+		t.Errorf("vertexBaseURL(global) = %q", got)
+	}
+	if got := vertexBaseURL("us-east5"); got != "https://us-east5-aiplatform.googleapis.com" {
+		t.Errorf("vertexBaseURL(us-east5) = %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Параллельный обход по умолчанию: директорий, читаемых одновременно
+const defaultWalkWorkers = 4
+
+// Содержимое директории, читаемое заранее
+type dirListing struct {
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+// Обход дерева в порядке filepath.WalkDir, при котором содержимое соседних
+// поддиректорий читается заранее несколькими горутинами (workers <= 1 - обычный
+// filepath.WalkDir). На сетевых файловых системах время уходит на ожидание ответа
+// на каждое чтение директории, поэтому одновременное чтение ускоряет поиск файлов;
+// fn вызывается последовательно из одной горутины
+func walkDirParallel(root string, workers int, fn fs.WalkDirFunc) error {
+	if workers <= 1 {
+		return filepath.WalkDir(root, fn)
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		w := &parallelWalker{fn: fn, sem: make(chan struct{}, workers), window: 2 * workers}
+		err = w.walk(root, fs.FileInfoToDirEntry(info), w.list(root))
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// Состояние параллельного обхода
+type parallelWalker struct {
+	fn fs.WalkDirFunc
+	// Ограничение одновременных чтений директорий
+	sem chan struct{}
+	// Сколько следующих поддиректорий читается заранее
+	window int
+}
+
+// Чтение директории в отдельной горутине; сведения о markdown файлах получаются
+// сразу, чтобы не запрашивать их потом по одному
+func (w *parallelWalker) list(dir string) *dirListing {
+	l := &dirListing{done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		w.sem <- struct{}{}
+		defer func() { <-w.sem }()
+		l.entries, l.err = os.ReadDir(dir)
+		for i, e := range l.entries {
+			if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".md") {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				l.entries[i] = fs.FileInfoToDirEntry(info)
+			}
+		}
+	}()
+	return l
+}
+
+// Обход директории path с уже запрошенным содержимым listing
+func (w *parallelWalker) walk(path string, d fs.DirEntry, listing *dirListing) error {
+	if err := w.fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	<-listing.done
+	if listing.err != nil {
+		if err := w.fn(path, d, listing.err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	// Поддиректории читаются заранее в порядке обхода, не больше window вперед
+	var subdirs []int
+	for i, e := range listing.entries {
+		if e.IsDir() {
+			subdirs = append(subdirs, i)
+		}
+	}
+	prefetched := make(map[int]*dirListing)
+	next := 0
+	for i, e := range listing.entries {
+		for ; next < len(subdirs) && len(prefetched) < w.window; next++ {
+			j := subdirs[next]
+			prefetched[j] = w.list(filepath.Join(path, listing.entries[j].Name()))
+		}
+		child := filepath.Join(path, e.Name())
+		if !e.IsDir() {
+			if err := w.fn(child, e, nil); err != nil {
+				if err == filepath.SkipDir {
+					break
+				}
+				return err
+			}
+			continue
+		}
+		sub := prefetched[i]
+		delete(prefetched, i)
+		if err := w.walk(child, e, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWalkDirParallel(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 30; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%02d", i%6), fmt.Sprintf("s%d", i%3))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{fmt.Sprintf("n%02d.md", i), "image.png"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("текст"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Порядок и пропуски совпадают с filepath.WalkDir: d03 пропускается целиком,
+	// в d04/s1 файлы после первого пропускаются
+	visit := func(walk func(fs.WalkDirFunc) error) []string {
+		var visited []string
+		err := walk(func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			visited = append(visited, rel)
+			switch {
+			case d.IsDir() && rel == "d03":
+				return filepath.SkipDir
+			case !d.IsDir() && strings.HasPrefix(rel, filepath.Join("d04", "s1")):
+				return filepath.SkipDir
+			}
+			if !d.IsDir() {
+				if _, err := d.Info(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("обход вернул ошибку: %v", err)
+		}
+		return visited
+	}
+	expected := visit(func(fn fs.WalkDirFunc) error { return filepath.WalkDir(root, fn) })
+	for _, workers := range []int{2, 8} {
+		got := visit(func(fn fs.WalkDirFunc) error { return walkDirParallel(root, workers, fn) })
+		if !slices.Equal(got, expected) {
+			t.Errorf("workers=%d: порядок обхода отличается от filepath.WalkDir:\n%v\n%v", workers, got, expected)
+		}
+	}
+
+	// Остановка обхода
+	count := 0
+	err := walkDirParallel(root, 4, func(path string, d fs.DirEntry, err error) error {
+		count++
+		if count == 5 {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil || count != 5 {
+		t.Errorf("SkipAll: ошибка %v, посещено %d", err, count)
+	}
+
+	if err := walkDirParallel(filepath.Join(root, "нет"), 4, func(path string, d fs.DirEntry, err error) error {
+		return err
+	}); err == nil {
+		t.Error("ожидалась ошибка обхода несуществующей директории")
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Параметры режима наблюдения (--watch) по умолчанию
+const (
+	defaultWatchDebounce = 2 * time.Second
+	defaultWatchInterval = time.Second
+	// Нижняя граница интервала: каждая проверка обходит входные директории целиком
+	defaultWatchMinInterval = 100 * time.Millisecond
+)
+
+// Временные файлы клиентов синхронизации по умолчанию ({name} - имя заметки):
+// пока такой файл лежит рядом с заметкой, ее новая версия еще не получена целиком
+var defaultSyncTempPatterns = []string{
+	".syncthing.{name}.tmp", "~syncthing~{name}.tmp", // Syncthing
+	"{name}.!sync",   // Resilio Sync
+	".~lock.{name}#", // LibreOffice, Nextcloud
+	"{name}.part", "{name}.partial", "{name}.tmp", "{name}.crdownload",
+}
+
+// Наблюдение из секции [WATCH]; параметры -watch-debounce и -watch-interval
+// переопределяют debounce и interval
+type WatchConfig struct {
+	// Время без изменений размера и времени изменения файла перед обработкой
+	Debounce time.Duration
+	// Интервал проверки входных директорий
+	Interval time.Duration
+	// Наименьший допустимый интервал, в том числе заданный -watch-interval
+	MinInterval time.Duration
+	// Имена временных файлов синхронизации с {name} вместо имени заметки
+	SyncTempPatterns []string
+}
+
+// Чтение секции [WATCH]
+func loadWatchConfig(section *ini.Section) (WatchConfig, error) {
+	watch := WatchConfig{
+		Debounce:         section.Key("debounce").MustDuration(defaultWatchDebounce),
+		Interval:         section.Key("interval").MustDuration(defaultWatchInterval),
+		MinInterval:      section.Key("min_interval").MustDuration(defaultWatchMinInterval),
+		SyncTempPatterns: defaultSyncTempPatterns,
+	}
+	if watch.MinInterval <= 0 {
+		return watch, errorf("некорректное значение min_interval в секции [WATCH]: %s (ожидалась положительная длительность)", watch.MinInterval)
+	}
+	if watch.Interval < watch.MinInterval {
+		return watch, errorf("interval в секции [WATCH] (%s) меньше min_interval (%s)", watch.Interval, watch.MinInterval)
+	}
+	if section.HasKey("sync_temp_patterns") {
+		watch.SyncTempPatterns = splitList(section.Key("sync_temp_patterns").String())
+	}
+	for _, p := range watch.SyncTempPatterns {
+		if !strings.Contains(p, "{name}") || strings.ContainsAny(p, `/\`) {
+			return watch, errorf("некорректный шаблон в sync_temp_patterns секции [WATCH]: %q (ожидалось имя файла с {name})", p)
+		}
+	}
+	return watch, nil
+}
+
+// Параметры режима наблюдения
+type watchOptions struct {
+	// Время, в течение которого файл не должен меняться перед отправкой в обработку:
+	// файлы, которые еще дописываются, не отправляются в API
+	Debounce time.Duration
+	// Интервал проверки входных директорий
+	Interval time.Duration
+	// Наименьший допустимый интервал (min_interval секции [WATCH])
+	MinInterval time.Duration
+}
+
+// Размер и время изменения файла при последней проверке
+type watchedFile struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Измененный файл, ожидающий окончания записи
+type pendingFile struct {
+	state watchedFile
+	since time.Time
+	// Рядом найден временный файл синхронизации (отложен хотя бы раз)
+	syncing bool
+}
+
+// Обнаружение новых, измененных и удаленных файлов входных директорий опросом:
+// файлы сравниваются по размеру и времени изменения с предыдущей проверкой.
+// Уведомления файловой системы не используются: они различаются по платформам
+// и ненадежны в сетевых папках и при синхронизации. Цена опроса - обход всех
+// директорий и чтение атрибутов каждого файла на каждой проверке, поэтому
+// для больших деревьев интервал увеличивают, а его нижняя граница задается min_interval
+type dirWatcher struct {
+	config  *Config
+	known   map[string]watchedFile
+	pending map[string]pendingFile
+	// Удаленные файлы и время обнаружения удаления (при on_delete, отличном от keep)
+	removed map[string]time.Time
+}
+
+// Наблюдение за входными директориями; файлы, уже существующие при запуске,
+// изменениями не считаются
+func newDirWatcher(config *Config) (*dirWatcher, error) {
+	w := &dirWatcher{config: config, pending: make(map[string]pendingFile), removed: make(map[string]time.Time)}
+	known, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.known = known
+	return w, nil
+}
+
+// Файлы всех входных директорий по путям в общем состоянии запуска
+func (w *dirWatcher) snapshot() (map[string]watchedFile, error) {
+	files := make(map[string]watchedFile)
+	var outputDirs []string
+	for _, root := range w.config.inputRoots() {
+		if dir, err := filepath.Abs(root.OutputDir); err == nil {
+			outputDirs = append(outputDirs, dir)
+		}
+	}
+	ignore := newIgnoreRules(w.config.IgnorePatterns...)
+	// Конфликтные копии по ключу основной заметки
+	copies := make(map[string][]watchedFile)
+	for _, root := range w.config.inputRoots() {
+		inputDir, err := filepath.Abs(root.InputDir)
+		if err != nil {
+			return nil, errorf("ошибка при получении абсолютного пути входной директории: %v", err)
+		}
+		err = filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Файл удален между чтением директории и проверкой
+				if os.IsNotExist(err) && path != inputDir {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(inputDir, path)
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Скрытые директории (.git, каталог состояния), выходные директории внутри
+				// входной и исключенные общими шаблонами
+				if rel != "." && (strings.HasPrefix(d.Name(), ".") || containsPath(outputDirs, path) ||
+					(w.config.MaxDepth > 0 && pathDepth(rel) >= w.config.MaxDepth) || ignore.Match(rel, true)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !w.config.isInputFile(d.Name()) {
+				return nil
+			}
+			// Конфликтная копия при conflict_copies = merge - изменение основной заметки,
+			// иначе не отслеживается
+			if original, ok := conflictCopyOriginal(d.Name()); ok {
+				if info, err := d.Info(); err == nil && w.config.ConflictCopies == ConflictCopiesMerge {
+					key := rootKey(root.RootName, normalizeRelPath(filepath.Join(filepath.Dir(rel), original)))
+					copies[key] = append(copies[key], watchedFile{Size: info.Size(), ModTime: info.ModTime()})
+				}
+				return nil
+			}
+			// Временные файлы редакторов не считаются изменениями
+			if ignore.Match(rel, false) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files[rootKey(root.RootName, normalizeRelPath(rel))] = watchedFile{Size: info.Size(), ModTime: info.ModTime()}
+			return nil
+		})
+		if err != nil {
+			return nil, errorf("ошибка при обходе директории %s: %v", inputDir, err)
+		}
+	}
+	for key, c := range copies {
+		if state, ok := files[key]; ok {
+			files[key] = withConflictCopies(state, c)
+		}
+	}
+	return files, nil
+}
+
+// Проверка, что путь совпадает с одним из путей списка
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if samePath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Проверка директорий: новые и измененные файлы ставятся в ожидание, отсчет
+// ожидания начинается заново при каждом изменении файла. Удаленные файлы тоже
+// ожидают debounce: редактор может удалить файл перед повторной записью
+func (w *dirWatcher) Scan(now time.Time) error {
+	files, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	for key, state := range files {
+		if prev, ok := w.known[key]; ok && prev == state {
+			continue
+		}
+		if p, ok := w.pending[key]; !ok || p.state != state {
+			w.pending[key] = pendingFile{state: state, since: now}
+		}
+	}
+	for key := range w.pending {
+		if _, ok := files[key]; !ok {
+			delete(w.pending, key)
+		}
+	}
+	if w.config.OnDelete == OnDeleteMark || w.config.OnDelete == OnDeleteTrash {
+		for key := range w.known {
+			if _, ok := files[key]; !ok {
+				if _, seen := w.removed[key]; !seen {
+					w.removed[key] = now
+				}
+			}
+		}
+	}
+	for key := range w.removed {
+		if _, ok := files[key]; ok {
+			delete(w.removed, key)
+		}
+	}
+	w.known = files
+	return nil
+}
+
+// Файлы, удаленные дольше debounce назад: они передаются в обработку удаления
+// и убираются из ожидания
+func (w *dirWatcher) Removed(now time.Time, debounce time.Duration) []string {
+	var removed []string
+	for key, since := range w.removed {
+		if now.Sub(since) >= debounce {
+			removed = append(removed, key)
+			delete(w.removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Файлы, не менявшиеся дольше debounce: они передаются в обработку и убираются
+// из ожидания. Файл, рядом с которым лежит временный файл синхронизации, ждет
+// еще debounce: клиент синхронизации еще получает его новую версию
+func (w *dirWatcher) Ready(now time.Time, debounce time.Duration) []string {
+	var ready []string
+	for key, p := range w.pending {
+		if now.Sub(p.since) < debounce {
+			continue
+		}
+		if temp := w.syncTemp(key); temp != "" {
+			if !p.syncing {
+				logf("Файл %s еще синхронизируется (%s), обработка отложена", key, temp)
+			}
+			w.pending[key] = pendingFile{state: p.state, since: now, syncing: true}
+			continue
+		}
+		ready = append(ready, key)
+		delete(w.pending, key)
+	}
+	sort.Strings(ready)
+	return ready
+}
+
+// Временный файл синхронизации рядом с файлом ("" - не найден)
+func (w *dirWatcher) syncTemp(key string) string {
+	rootConfig, rel := w.config.rootForKey(key)
+	path := filepath.Join(rootConfig.InputDir, filepath.FromSlash(rel))
+	name := filepath.Base(path)
+	for _, p := range w.config.Watch.SyncTempPatterns {
+		temp := strings.ReplaceAll(p, "{name}", name)
+		if _, err := os.Lstat(filepath.Join(filepath.Dir(path), temp)); err == nil {
+			return temp
+		}
+	}
+	return ""
+}
+
+// Возврат файлов в ожидание: файлы, не обработанные из-за суточного лимита, снова
+// готовы к обработке сразу после его освобождения
+func (w *dirWatcher) Requeue(keys []string) {
+	for _, key := range keys {
+		w.pending[key] = pendingFile{state: w.known[key]}
+	}
+}
+
+// Файлов в ожидании окончания записи
+func (w *dirWatcher) Pending() int {
+	return len(w.pending)
+}
+
+// Счетчики режима наблюдения для /stats
+type watchCounters struct {
+	// Время начала наблюдения и последнего запуска обработки
+	StartedAt time.Time  `json:"started_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	Runs      int        `json:"runs"`
+	// Итоги файлов за все время наблюдения
+	Processed int `json:"processed"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+	// Файлы, ожидающие окончания записи, и файлы текущего запуска, еще не обработанные
+	Queue int `json:"queue"`
+	// Последняя ошибка файла или запуска
+	LastError string `json:"last_error,omitempty"`
+	// Причина приостановки обработки по суточному лимиту ("" - обработка не приостановлена)
+	DailyCap string `json:"daily_cap,omitempty"`
+}
+
+// Счетчики режима наблюдения, обновляемые по событиям обработки файлов
+type watchStats struct {
+	mu       sync.Mutex
+	counters watchCounters
+	pending  int
+	inFlight int
+	// Файлы текущего запуска, обработка которых завершена
+	finished map[string]bool
+}
+
+// Учет события обработки файла
+func (s *watchStats) observe(ev statusEvent) {
+	if ev.Event != EventFileFinished {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished != nil {
+		s.finished[pathKey(ev.Path)] = true
+	}
+	switch {
+	case ev.Error != "":
+		s.counters.Failed++
+		s.counters.LastError = ev.Path + ": " + ev.Error
+	case ev.Status == StatusEnriched || ev.Status == StatusConflict:
+		s.counters.Processed++
+	default:
+		s.counters.Skipped++
+	}
+	s.inFlight = max(s.inFlight-1, 0)
+}
+
+// Копия счетчиков для вывода
+func (s *watchStats) Snapshot() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counters := s.counters
+	counters.Queue = s.pending + s.inFlight
+	return counters
+}
+
+// Начало запуска обработки files файлов
+func (s *watchStats) startRun(files int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.Runs++
+	s.counters.LastRunAt = &now
+	s.inFlight = files
+	s.finished = make(map[string]bool, files)
+}
+
+// Файлы из keys, обработка которых в текущем запуске не завершена
+func (s *watchStats) unfinished(keys []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var left []string
+	for _, key := range keys {
+		if !s.finished[pathKey(key)] {
+			left = append(left, key)
+		}
+	}
+	return left
+}
+
+// Приостановка обработки по суточному лимиту ("" - обработка продолжается)
+func (s *watchStats) setDailyCap(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.DailyCap = reason
+}
+
+// Ошибка запуска обработки и число файлов в ожидании после запуска
+func (s *watchStats) finishRun(err error, pending int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.counters.LastError = err.Error()
+	}
+	s.inFlight = 0
+	s.pending = pending
+}
+
+// Обновление числа файлов в ожидании
+func (s *watchStats) setPending(pending int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = pending
+}
+
+// Обработка только указанных файлов (измененных во время наблюдения, проверочного):
+// ранее обогащенные файлы убираются из списка исключений и обогащаются заново
+func reprocessFiles(config *Config, configPath string, keys []string) error {
+	// Список исключений перечитывается: его обновили предыдущие запуски
+	current, err := loadConfig(configPath)
+	if err != nil {
+		return errorf("ошибка загрузки конфигурации: %v", err)
+	}
+	only := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		only[pathKey(key)] = true
+		if !isExcluded(current, key) {
+			continue
+		}
+		if err := removeFromExcludedFiles(configPath, key); err != nil {
+			return errorf("не удалось убрать %s из списка исключений: %v", key, err)
+		}
+	}
+	runConfig := *config
+	runConfig.ExcludedFiles = nil
+	for _, file := range current.ExcludedFiles {
+		if !only[pathKey(file)] {
+			runConfig.ExcludedFiles = append(runConfig.ExcludedFiles, file)
+		}
+	}
+	runConfig.OnlyFiles = only
+	return processDirectory(&runConfig, configPath)
+}
+
+// Режим наблюдения до SIGINT или SIGTERM; счетчики доступны на /stats сервера
+// проверок состояния
+func runWatchMode(config *Config, configPath string, opts watchOptions, health *healthState) error {
+	if config.InputSource != "" {
+		return withCategory(ErrorConfig, errorf("--watch работает только с входной директорией input_dir, а не с input_source"))
+	}
+	if opts.Debounce < 0 || opts.Interval <= 0 {
+		return withCategory(ErrorConfig, errorf("некорректные параметры наблюдения: -watch-debounce %s, -watch-interval %s", opts.Debounce, opts.Interval))
+	}
+	if opts.Interval < opts.MinInterval {
+		return withCategory(ErrorConfig, errorf("-watch-interval %s меньше min_interval секции [WATCH] (%s): каждая проверка обходит входные директории целиком", opts.Interval, opts.MinInterval))
+	}
+
+	stats := &watchStats{counters: watchCounters{StartedAt: time.Now()}}
+	observeStatusEvents(stats.observe)
+	health.SetStats(stats.Snapshot)
+
+	ctl := newServiceControl()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		for sig := range sigs {
+			logf("Получен сигнал %v", sig)
+			ctl.Stop()
+		}
+	}()
+	return watchLoop(config, configPath, opts, stats, ctl)
+}
+
+// Цикл наблюдения: первый запуск обрабатывает входные директории целиком, затем
+// новые и измененные файлы обрабатываются по мере появления до остановки через ctl
+func watchLoop(config *Config, configPath string, opts watchOptions, stats *watchStats, ctl *serviceControl) error {
+	watcher, err := newDirWatcher(config)
+	if err != nil {
+		return err
+	}
+	stats.startRun(0, time.Now())
+	err = processDirectory(config, configPath)
+	stats.finishRun(err, 0)
+	if err != nil {
+		logErrorf("Ошибка обработки директории: %v", err)
+	}
+
+	// Суточный лимит запросов: при исчерпании новые файлы ждут в очереди. Файлы,
+	// не обработанные первым запуском из-за лимита, тоже ставятся в очередь
+	daily := newDailyUsage(config)
+	capped := false
+	if _, exhausted := daily.Exhausted(); exhausted {
+		if current, err := loadConfig(configPath); err == nil {
+			current.OnlyFiles, current.Shard = config.OnlyFiles, config.Shard
+			if pending, err := pendingFiles(current); err == nil {
+				keys := make([]string, len(pending))
+				for i, c := range pending {
+					keys[i] = c.RelPath
+				}
+				watcher.Requeue(keys)
+			}
+		}
+	}
+	infof("Наблюдение за входными директориями: проверка каждые %s, обработка через %s после последнего изменения файла", opts.Interval, opts.Debounce)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	slowScan := false
+	for !serviceStopping.Load() {
+		select {
+		case <-ctl.stop:
+			continue
+		case now := <-ticker.C:
+			if err := watcher.Scan(now); err != nil {
+				warnf("Предупреждение: %v", err)
+				continue
+			}
+			// Обход дольше интервала: проверки идут подряд и нагружают диск
+			if took := time.Since(now); took > opts.Interval && !slowScan {
+				warnf("Предупреждение: проверка входных директорий заняла %s, больше интервала %s: увеличьте interval в секции [WATCH]", took.Round(time.Millisecond), opts.Interval)
+				slowScan = true
+			}
+			if removed := watcher.Removed(now, opts.Debounce); len(removed) > 0 {
+				infof("Удаленные файлы: %d", len(removed))
+				if err := handleDeletedInputs(config, configPath, removed, now); err != nil {
+					logErrorf("Ошибка обработки удаленных файлов: %v", err)
+				}
+			}
+			if reason, exhausted := daily.Exhausted(); exhausted {
+				stats.setPending(watcher.Pending())
+				if !capped {
+					warnf("Обработка приостановлена: %s; файлы в очереди: %d", reason, watcher.Pending())
+					stats.setDailyCap(reason)
+					capped = true
+				}
+				continue
+			}
+			if capped {
+				infof("Суточный лимит освободился, обработка продолжается")
+				stats.setDailyCap("")
+				capped = false
+			}
+			ready := watcher.Ready(now, opts.Debounce)
+			stats.setPending(watcher.Pending())
+			if len(ready) == 0 {
+				continue
+			}
+			infof("Новые и измененные файлы: %d", len(ready))
+			stats.startRun(len(ready), now)
+			err := reprocessFiles(config, configPath, ready)
+			// Файлы, не отправленные из-за суточного лимита, возвращаются в очередь
+			if _, exhausted := daily.Exhausted(); exhausted {
+				watcher.Requeue(stats.unfinished(ready))
+			}
+			stats.finishRun(err, watcher.Pending())
+			if err != nil {
+				logErrorf("Ошибка обработки директории: %v", err)
+			}
+		}
+	}
+	infof("Наблюдение остановлено")
+	return nil
+}
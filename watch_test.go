@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestDirWatcher(t *testing.T) {
+	inputDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(inputDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "# A")
+	write(".git/b.md", "# B")
+	config := &Config{InputDir: inputDir, OutputDir: filepath.Join(inputDir, "out")}
+	w, err := newDirWatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	write("notes/new.md", "# Новая")
+	write("out/result.md", "# Результат")
+	write("image.png", "png")
+	if err := w.Scan(start); err != nil {
+		t.Fatal(err)
+	}
+	if w.Pending() != 1 {
+		t.Fatalf("Ожидался один новый файл, в ожидании %d", w.Pending())
+	}
+
+	// Файл дописывается: отсчет ожидания начинается заново
+	write("notes/new.md", "# Новая\n\nТекст")
+	if err := w.Scan(start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if ready := w.Ready(start.Add(2500*time.Millisecond), 2*time.Second); len(ready) != 0 {
+		t.Errorf("Файл, изменившийся меньше debounce назад, не должен обрабатываться: %v", ready)
+	}
+	if ready := w.Ready(start.Add(3*time.Second), 2*time.Second); !reflect.DeepEqual(ready, []string{"notes/new.md"}) {
+		t.Errorf("Неожиданные файлы для обработки: %v", ready)
+	}
+
+	// Без изменений повторная проверка ничего не находит
+	if err := w.Scan(start.Add(4 * time.Second)); err != nil || w.Pending() != 0 {
+		t.Errorf("Файлы без изменений не должны попадать в ожидание: %d, %v", w.Pending(), err)
+	}
+	write("a.md", "# A изменен")
+	if err := w.Scan(start.Add(5 * time.Second)); err != nil || w.Pending() != 1 {
+		t.Errorf("Измененный файл должен попадать в ожидание: %d, %v", w.Pending(), err)
+	}
+}
+
+// Новые и измененные файлы обрабатываются без перезапуска, счетчики доступны на /stats
+func TestWatchLoop(t *testing.T) {
+	t.Cleanup(func() { serviceStopping.Store(false) })
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# A\n\nТекст"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[EXCLUSIONS]\nexcluded_files =\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+
+	prev := statusEvents
+	t.Cleanup(func() { statusEvents = prev })
+	stats := &watchStats{counters: watchCounters{StartedAt: time.Now()}}
+	observeStatusEvents(stats.observe)
+	health := &healthState{}
+	health.SetStats(stats.Snapshot)
+
+	ctl := newServiceControl()
+	done := make(chan error, 1)
+	go func() {
+		done <- watchLoop(config, configPath, watchOptions{Debounce: 50 * time.Millisecond, Interval: 10 * time.Millisecond}, stats, ctl)
+	}()
+	waitCalls := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for calls.Load() < n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if calls.Load() != n {
+			t.Fatalf("Ожидалось запросов к API: %d, получено %d", n, calls.Load())
+		}
+	}
+	waitCalls(1)
+
+	// Новый файл обрабатывается после паузы в записи
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(inputDir, "b.md"), []byte("# B\n\nТекст"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitCalls(2)
+	if _, err := os.Stat(filepath.Join(outputDir, "b.md")); err != nil {
+		t.Errorf("Новый файл не обработан: %v", err)
+	}
+
+	// Ранее обогащенный файл после изменения обогащается заново
+	if err := os.WriteFile(filepath.Join(inputDir, "a.md"), []byte("# A\n\nНовый текст заметки"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitCalls(3)
+	time.Sleep(50 * time.Millisecond)
+	if calls.Load() != 3 {
+		t.Errorf("Файлы без изменений не должны обрабатываться повторно: запросов %d", calls.Load())
+	}
+
+	rec := httptest.NewRecorder()
+	health.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var got watchCounters
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Некорректный ответ /stats: %v\n%s", err, rec.Body.String())
+	}
+	if got.Processed != 3 || got.Failed != 0 || got.Runs != 3 || got.Queue != 0 {
+		t.Errorf("Неожиданные счетчики: %s", rec.Body.String())
+	}
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), "a.md") || !strings.Contains(string(data), "b.md") {
+		t.Errorf("Обработанные файлы должны оставаться в списке исключений: %s", data)
+	}
+
+	ctl.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watchLoop() вернул ошибку: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Наблюдение не остановилось")
+	}
+}
+
+// Удаленные файлы передаются в обработку после debounce, если не появились снова
+func TestDirWatcherRemoved(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("# Заметка"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := &Config{InputDir: inputDir, OutputDir: filepath.Join(inputDir, "out"), OnDelete: OnDeleteTrash}
+	w, err := newDirWatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.Remove(filepath.Join(inputDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Scan(start); err != nil {
+		t.Fatal(err)
+	}
+	if removed := w.Removed(start.Add(time.Second), 2*time.Second); len(removed) != 0 {
+		t.Errorf("Файлы, удаленные меньше debounce назад, не должны обрабатываться: %v", removed)
+	}
+
+	// Файл записан заново: удаление не обрабатывается
+	if err := os.WriteFile(filepath.Join(inputDir, "b.md"), []byte("# Заметка"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Scan(start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if removed := w.Removed(start.Add(3*time.Second), 2*time.Second); !reflect.DeepEqual(removed, []string{"a.md"}) {
+		t.Errorf("Неожиданные удаленные файлы: %v", removed)
+	}
+
+	// on_delete = keep: удаления не отслеживаются
+	config.OnDelete = OnDeleteKeep
+	if err := os.Remove(filepath.Join(inputDir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Scan(start.Add(4 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if removed := w.Removed(start.Add(10*time.Second), 2*time.Second); len(removed) != 0 {
+		t.Errorf("При on_delete = keep удаления не должны обрабатываться: %v", removed)
+	}
+}
+
+// Файлы, возвращенные в очередь, готовы к обработке без ожидания debounce
+func TestDirWatcherRequeue(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newDirWatcher(&Config{InputDir: dir, OutputDir: filepath.Join(dir, "out")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Requeue([]string{"a.md"})
+	now := time.Now()
+	if err := w.Scan(now); err != nil {
+		t.Fatal(err)
+	}
+	if ready := w.Ready(now, time.Hour); len(ready) != 1 || ready[0] != "a.md" {
+		t.Errorf("Ожидался файл a.md, получено %v", ready)
+	}
+}
+
+// Файл, рядом с которым лежит временный файл синхронизации, не передается в обработку
+func TestDirWatcherSyncTemp(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{InputDir: dir, OutputDir: filepath.Join(dir, "out"), Watch: WatchConfig{SyncTempPatterns: defaultSyncTempPatterns}}
+	w, err := newDirWatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	temp := filepath.Join(dir, ".syncthing.a.md.tmp")
+	if err := os.WriteFile(temp, []byte("# A новая версия"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Scan(start); err != nil {
+		t.Fatal(err)
+	}
+	if ready := w.Ready(start.Add(5*time.Second), 2*time.Second); len(ready) != 0 {
+		t.Fatalf("Файл синхронизируется и не должен обрабатываться: %v", ready)
+	}
+	if err := os.Remove(temp); err != nil {
+		t.Fatal(err)
+	}
+	// После исчезновения временного файла снова выдерживается debounce
+	if ready := w.Ready(start.Add(6*time.Second), 2*time.Second); len(ready) != 0 {
+		t.Errorf("Файл должен выждать debounce после синхронизации: %v", ready)
+	}
+	if ready := w.Ready(start.Add(8*time.Second), 2*time.Second); len(ready) != 1 {
+		t.Errorf("Ожидался файл a.md, получено %v", ready)
+	}
+}
+
+func TestDirWatcherIgnore(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := ini.Load([]byte("[EXCLUSIONS]\nignore_patterns = drafts/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: dir, OutputDir: filepath.Join(dir, "out"), IgnorePatterns: mustLoadIgnorePatterns(t, cfg.Section("EXCLUSIONS"))}
+	w, err := newDirWatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "drafts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.md", ".#a.md", "~$a.md", "a.sync-conflict-20240101-120000-ABCDEF.md", "a (conflicted copy 2024-01-01).md", "drafts/b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# A"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	if err := w.Scan(start); err != nil {
+		t.Fatal(err)
+	}
+	ready := w.Ready(start.Add(5*time.Second), 2*time.Second)
+	if len(ready) != 1 || ready[0] != "a.md" {
+		t.Errorf("Временные файлы и конфликтные копии не должны обрабатываться: %v", ready)
+	}
+}
+
+func TestDirWatcherConflictCopies(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# A"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{InputDir: dir, OutputDir: filepath.Join(dir, "out"), ConflictCopies: ConflictCopiesMerge}
+	w, err := newDirWatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Новая конфликтная копия - изменение основной заметки
+	if err := os.WriteFile(filepath.Join(dir, "a (conflicted copy).md"), []byte("# A с телефона"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := w.Scan(start); err != nil {
+		t.Fatal(err)
+	}
+	ready := w.Ready(start.Add(5*time.Second), 2*time.Second)
+	if len(ready) != 1 || ready[0] != "a.md" {
+		t.Errorf("Ожидалась основная заметка a.md, получено %v", ready)
+	}
+}
+
+func TestLoadWatchConfig(t *testing.T) {
+	cfg, err := ini.Load([]byte("[WATCH]\ndebounce = 30s\nsync_temp_patterns = {name}.sync, .{name}.swp\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch, err := loadWatchConfig(cfg.Section("WATCH"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watch.Debounce != 30*time.Second || watch.Interval != defaultWatchInterval || watch.MinInterval != defaultWatchMinInterval || len(watch.SyncTempPatterns) != 2 {
+		t.Errorf("Неожиданные параметры наблюдения: %+v", watch)
+	}
+	cfg, _ = ini.Load([]byte("[WATCH]\nsync_temp_patterns = partial.tmp\n"))
+	if _, err := loadWatchConfig(cfg.Section("WATCH")); err == nil {
+		t.Error("Ожидалась ошибка для шаблона без {name}")
+	}
+
+	// Интервал не меньше нижней границы
+	for _, section := range []string{"interval = 2s\nmin_interval = 5s", "min_interval = 0s", "interval = 50ms"} {
+		cfg, _ = ini.Load([]byte("[WATCH]\n" + section + "\n"))
+		if _, err := loadWatchConfig(cfg.Section("WATCH")); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", section)
+		}
+	}
+	cfg, _ = ini.Load([]byte("[WATCH]\ninterval = 50ms\nmin_interval = 10ms\n"))
+	if watch, err := loadWatchConfig(cfg.Section("WATCH")); err != nil || watch.Interval != 50*time.Millisecond {
+		t.Errorf("loadWatchConfig() = %+v, %v", watch, err)
+	}
+	opts := watchOptions{Debounce: time.Second, Interval: 10 * time.Millisecond, MinInterval: time.Second}
+	if err := runWatchMode(&Config{InputDir: t.TempDir()}, "", opts, nil); err == nil || !strings.Contains(err.Error(), "min_interval") {
+		t.Errorf("runWatchMode() = %v, ожидалась ошибка нижней границы интервала", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Управление обработкой из frontmatter файла ([WORKFLOW]): авторы отмечают
+// черновики и готовые к обогащению заметки полем статуса
+type WorkflowConfig struct {
+	Enabled bool
+	// Поле frontmatter со статусом файла
+	Field string
+	// Статусы, при которых файл пропускается
+	Skip []string
+	// Статусы, при которых файл обогащается (пусто - любой статус, кроме Skip и Done)
+	Ready []string
+	// Статус, записываемый в frontmatter результата ("" - не записывается); файлы
+	// с этим статусом во входной директории тоже пропускаются
+	Done string
+}
+
+// Чтение секции [WORKFLOW]
+func loadWorkflowConfig(section *ini.Section) (WorkflowConfig, error) {
+	wc := WorkflowConfig{
+		Enabled: section.Key("enabled").MustBool(false),
+		Field:   strings.TrimSpace(section.Key("field").MustString("status")),
+		Skip:    []string{"draft"},
+		Ready:   workflowStatuses(section.Key("ready").String()),
+		Done:    "enriched",
+	}
+	// Пустые skip и done выключают пропуск черновиков и запись статуса
+	if section.HasKey("skip") {
+		wc.Skip = workflowStatuses(section.Key("skip").String())
+	}
+	if section.HasKey("done") {
+		wc.Done = strings.ToLower(strings.TrimSpace(section.Key("done").String()))
+	}
+	for _, status := range wc.Ready {
+		if slices.Contains(wc.Skip, status) || status == wc.Done {
+			return wc, errorf("статус %q в секции [WORKFLOW] указан и в ready, и в skip или done", status)
+		}
+	}
+	return wc, nil
+}
+
+// Список статусов через запятую без учета регистра
+func workflowStatuses(value string) []string {
+	var statuses []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
+
+// Статус файла, из-за которого файл пропускается; false - файл обрабатывается
+func (wc WorkflowConfig) Skipped(content []byte) (string, bool) {
+	if !wc.Enabled {
+		return "", false
+	}
+	fm, _, _ := parseFrontmatter(content)
+	status := strings.ToLower(strings.TrimSpace(fm[wc.Field]))
+	switch {
+	case status != "" && (slices.Contains(wc.Skip, status) || status == wc.Done):
+		return status, true
+	case len(wc.Ready) > 0 && !slices.Contains(wc.Ready, status):
+		return status, true
+	}
+	return "", false
+}
+
+// Запись статуса обогащенного файла в frontmatter результата
+func (wc WorkflowConfig) Apply(doc string) string {
+	if !wc.Enabled || wc.Done == "" {
+		return doc
+	}
+	return setFrontmatterField(doc, wc.Field, wc.Done)
+}
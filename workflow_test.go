@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestLoadWorkflowConfig(t *testing.T) {
+	cfg, err := ini.Load([]byte("[WORKFLOW]\nenabled = true\nskip = Draft, wip\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc, err := loadWorkflowConfig(cfg.Section("WORKFLOW"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wc.Field != "status" || wc.Done != "enriched" || len(wc.Skip) != 2 || wc.Skip[0] != "draft" {
+		t.Errorf("Неожиданные параметры: %+v", wc)
+	}
+
+	cfg, _ = ini.Load([]byte("[WORKFLOW]\nenabled = true\nskip =\ndone =\n"))
+	if wc, err := loadWorkflowConfig(cfg.Section("WORKFLOW")); err != nil || len(wc.Skip) != 0 || wc.Done != "" {
+		t.Errorf("Пустые skip и done должны выключать пропуск и запись статуса: %+v, %v", wc, err)
+	}
+
+	for _, bad := range []string{"ready = draft\n", "ready = enriched\n"} {
+		cfg, _ := ini.Load([]byte("[WORKFLOW]\n" + bad))
+		if _, err := loadWorkflowConfig(cfg.Section("WORKFLOW")); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", bad)
+		}
+	}
+}
+
+func TestWorkflowSkipped(t *testing.T) {
+	wc := WorkflowConfig{Enabled: true, Field: "status", Skip: []string{"draft"}, Done: "enriched"}
+	for doc, want := range map[string]bool{
+		"---\nstatus: draft\n---\nТекст":    true,
+		"---\nstatus: Enriched\n---\nТекст": true,
+		"---\nstatus: ready\n---\nТекст":    false,
+		"Текст без frontmatter":             false,
+	} {
+		if _, got := wc.Skipped([]byte(doc)); got != want {
+			t.Errorf("Skipped(%q) = %v, ожидалось %v", doc, got, want)
+		}
+	}
+	wc.Ready = []string{"ready"}
+	if _, skipped := wc.Skipped([]byte("Текст без frontmatter")); !skipped {
+		t.Error("С ready обрабатываются только файлы с этим статусом")
+	}
+	if _, skipped := (WorkflowConfig{}).Skipped([]byte("---\nstatus: draft\n---\n")); skipped {
+		t.Error("Без enabled статус не учитывается")
+	}
+}
+
+// Черновики пропускаются, готовые файлы обогащаются, а результат получает статус enriched
+func TestWorkflowRun(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "---\ntitle: Заметка\nstatus: ready\n---\n# Обогащено"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"draft.md": "---\nstatus: draft\n---\n# Черновик\n\nТекст черновика",
+		"ready.md": "---\ntitle: Заметка\nstatus: ready\n---\n# Заметка\n\nГотовый текст",
+		"done.md":  "---\nstatus: enriched\n---\n# Готово\n\nУже обогащено",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "rich.cfg")
+	cfg := "[DIRECTORIES]\ninput_dir = " + inputDir + "\noutput_dir = " + outputDir + "\n[MODEL]\napi_url = " + server.URL +
+		"/v1/chat/completions\nprovider = openai-compatible\n[WORKFLOW]\nenabled = true\n[STATE]\ndir = " + filepath.Join(tmpDir, ".rich") + "\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ReportFile = ""
+	if err := processDirectory(config, configPath); err != nil {
+		t.Fatalf("processDirectory() вернул ошибку: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Ожидался один запрос к модели (ready.md), получено %d", calls)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "ready.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm, _, _ := parseFrontmatter(data); fm["status"] != "enriched" || fm["title"] != "Заметка" {
+		t.Errorf("Статус результата не обновлен: %q", data)
+	}
+	if !strings.Contains(string(data), "```old\n---\ntitle: Заметка\nstatus: ready") {
+		t.Errorf("Оригинал должен сохраняться без изменений: %q", data)
+	}
+	for _, name := range []string{"draft.md", "done.md"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err == nil {
+			t.Errorf("Файл %s не должен обрабатываться", name)
+		}
+	}
+}